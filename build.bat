@@ -21,8 +21,9 @@ if %errorlevel%==0 (
     set GIT_COMMIT=unknown
 )
 
-REM 构建信息
-set LDFLAGS=-s -w -X main.Version=%VERSION% -X main.BuildTime=%BUILD_TIME% -X main.GitCommit=%GIT_COMMIT%
+REM 构建信息，版本信息写入internal/version包
+set VERSION_PKG=github.com/eolinker/ai-prompt-proxy/internal/version
+set LDFLAGS=-s -w -X %VERSION_PKG%.Version=%VERSION% -X %VERSION_PKG%.BuildTime=%BUILD_TIME% -X %VERSION_PKG%.GitCommit=%GIT_COMMIT%
 
 REM 输出目录
 set BUILD_DIR=build
@@ -11,8 +11,9 @@ VERSION=${VERSION:-"1.0.0"}
 BUILD_TIME=$(date -u '+%Y-%m-%d_%H:%M:%S')
 GIT_COMMIT=$(git rev-parse --short HEAD 2>/dev/null || echo "unknown")
 
-# 构建信息
-LDFLAGS="-s -w -X main.Version=${VERSION} -X main.BuildTime=${BUILD_TIME} -X main.GitCommit=${GIT_COMMIT}"
+# 构建信息，版本信息写入internal/version包
+VERSION_PKG="github.com/eolinker/ai-prompt-proxy/internal/version"
+LDFLAGS="-s -w -X ${VERSION_PKG}.Version=${VERSION} -X ${VERSION_PKG}.BuildTime=${BUILD_TIME} -X ${VERSION_PKG}.GitCommit=${GIT_COMMIT}"
 
 # 输出目录
 BUILD_DIR="build"
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// AuthProvider 管理API处理器和认证中间件使用的认证服务，*service.AuthService实现该接口，
+// 测试中可替换为不依赖数据库和密钥的实现
+type AuthProvider interface {
+	// 登录、注册和token
+	ValidateToken(tokenString string) (*service.Claims, error)
+	Login(req *service.LoginRequest) (*service.LoginResponse, error)
+	EncryptedLogin(req *service.EncryptedLoginRequest) (*service.LoginResponse, error)
+	Register(req *service.RegisterRequest) (*service.LoginResponse, error)
+	EncryptedRegister(req *service.EncryptedRegisterRequest) (*service.LoginResponse, error)
+	GetPublicKey() (*service.PublicKeyResponse, error)
+	IsFirstInstall() (bool, error)
+	TokenTTL() time.Duration
+	SetTokenTTL(ttl time.Duration) error
+
+	// 用户管理
+	GetUserByID(id uint) (*db.User, error)
+	UserInScope(scope service.TenantScope, userID uint) (*db.User, error)
+	SearchUsers(scope service.TenantScope, keyword string, q db.UserQuery) (*service.UserListResponse, error)
+	CreateUser(req *service.CreateUserRequest, creatorID uint, scope service.TenantScope) (*service.CreateUserResponse, error)
+	UpdateUser(scope service.TenantScope, userID uint, req *service.UpdateUserRequest) error
+	UpdateUserStatus(userID uint, isEnabled bool) error
+	DeleteUser(userID uint, cascade bool) error
+	ChangePassword(userID uint, req *service.ChangePasswordRequest) error
+	AdminChangePassword(userID uint, req *service.AdminChangePasswordRequest) error
+	UnlockUser(userID uint) error
+
+	// 登录会话
+	TouchSession(jti, clientIP string) error
+	ListSessions(scope service.TenantScope, userID uint) ([]service.SessionInfo, error)
+	RevokeSession(scope service.TenantScope, jti string, userID uint) error
+
+	// API Key管理
+	GetAPIKeyByID(apiKeyID uint) (*db.APIKey, error)
+	APIKeyInScope(scope service.TenantScope, apiKeyID uint) (*db.APIKey, error)
+	GetAPIKeysByUserID(userID uint, selector map[string]string) ([]db.APIKey, error)
+	GetAPIKeysByAllowedModel(modelID string) ([]db.APIKey, error)
+	GetExpiringAPIKeys(userID uint, within time.Duration) ([]db.APIKey, error)
+	GetAllAPIKeys(scope service.TenantScope, selector map[string]string) ([]db.APIKey, error)
+	CreateAPIKey(userID uint, name, keyValue, expiresAt string, allowedModels []string, labels map[string]string) (*db.APIKey, error)
+	UpdateAPIKey(apiKeyID, userID uint, req *service.UpdateAPIKeyRequest) (*db.APIKey, error)
+	UpdateAPIKeyInScope(scope service.TenantScope, apiKeyID uint, req *service.UpdateAPIKeyRequest) (*db.APIKey, error)
+	RotateAPIKey(apiKeyID, userID uint, keyValue string) (*db.APIKey, error)
+	TransferAPIKey(scope service.TenantScope, apiKeyID, toUserID uint) (*db.APIKey, error)
+	DeleteAPIKey(apiKeyID, userID uint) error
+
+	// 租户管理
+	ListTenants(scope service.TenantScope) ([]db.Tenant, error)
+	CreateTenant(scope service.TenantScope, id, name string) (*db.Tenant, error)
+}
+
+// SetAuthProvider 替换认证服务，需要在Router之前调用
+func (s *AdminServer) SetAuthProvider(provider AuthProvider) {
+	s.authService = provider
+}
@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// SetCanaryTracker 设置代理服务的灰度发布统计器
+func (s *AdminServer) SetCanaryTracker(tracker *stats.ExperimentTracker) {
+	s.canaries = tracker
+}
+
+// getCanary 获取模型的灰度发布配置和primary、canary两个分组的请求统计（GET /api/v1/models/:id/canary），
+// 灰度比例通过更新模型的canary_percent调整
+func (s *AdminServer) getCanary(c *gin.Context) {
+	modelID := c.Param("id")
+	model, exists := s.config.GetModel(modelID)
+	if !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"model_id":       model.ID,
+			"target":         model.Target,
+			"canary_target":  model.CanaryTarget,
+			"canary_url":     model.CanaryUpstream().Url,
+			"canary_percent": model.CanaryPercent,
+			"stats":          s.canaries.Summary(model.ID),
+		},
+	})
+}
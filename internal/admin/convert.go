@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+// pkg/api不依赖内部包，管理API在这里把内部结构与pkg/api中的请求和响应结构相互转换
+
+// newAPIUser 构建用户响应，不包含密码
+func newAPIUser(user *db.User) *api.User {
+	if user == nil {
+		return nil
+	}
+	return &api.User{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Role:               api.Role(user.Role),
+		IsAdmin:            user.IsAdmin,
+		IsEnabled:          user.IsEnabled,
+		MustChangePassword: user.MustChangePassword,
+		LastLoginAt:        user.LastLoginAt,
+		FailedLoginCount:   user.FailedLoginCount,
+		LockedUntil:        user.LockedUntil,
+		CreatedBy:          user.CreatedBy,
+		TenantID:           user.TenantID,
+		Global:             user.Global,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+	}
+}
+
+// newLoginResponse 构建登录响应
+func newLoginResponse(response *service.LoginResponse) *api.LoginResponse {
+	return &api.LoginResponse{
+		Token:              response.Token,
+		User:               newAPIUser(response.User),
+		ExpiresAt:          response.ExpiresAt,
+		ExpiresIn:          response.ExpiresIn,
+		MustChangePassword: response.MustChangePassword,
+	}
+}
+
+// newUserListResponse 构建用户列表响应
+func newUserListResponse(response *service.UserListResponse) *api.UserListResponse {
+	users := make([]api.UserInfo, 0, len(response.Users))
+	for _, user := range response.Users {
+		users = append(users, api.UserInfo{
+			ID:                 user.ID,
+			Username:           user.Username,
+			Role:               api.Role(user.Role),
+			IsAdmin:            user.IsAdmin,
+			IsEnabled:          user.IsEnabled,
+			MustChangePassword: user.MustChangePassword,
+			CreatedAt:          user.CreatedAt,
+			UpdatedAt:          user.UpdatedAt,
+			LastLoginAt:        user.LastLoginAt,
+			FailedLoginCount:   user.FailedLoginCount,
+			LockedUntil:        user.LockedUntil,
+			CreatedBy:          user.CreatedBy,
+			TenantID:           user.TenantID,
+			Global:             user.Global,
+		})
+	}
+	return &api.UserListResponse{Users: users, Total: response.Total, Page: response.Page, PageSize: response.PageSize}
+}
+
+// newProfile 构建当前用户信息响应
+func newProfile(user *db.User) api.Profile {
+	capabilities := []api.Capability{}
+	for _, capability := range user.Role.Capabilities() {
+		capabilities = append(capabilities, api.Capability(capability))
+	}
+	return api.Profile{User: newAPIUser(user), Capabilities: capabilities}
+}
+
+// newModelStatus 构建上游健康检查结果响应
+func newModelStatus(status healthcheck.ModelStatus) *api.ModelStatus {
+	upstreams := make([]api.HealthCheckResult, 0, len(status.Upstreams))
+	for _, upstream := range status.Upstreams {
+		upstreams = append(upstreams, api.HealthCheckResult(upstream))
+	}
+	return &api.ModelStatus{
+		ModelID:   status.ModelID,
+		Method:    api.HealthCheckMethod(status.Method),
+		Healthy:   status.Healthy,
+		CheckedAt: status.CheckedAt,
+		Upstreams: upstreams,
+	}
+}
+
+// apiTargets 转换按权重分流的目标，nil保持为nil
+func apiTargets(targets []config.WeightedTarget) []api.WeightedTarget {
+	if targets == nil {
+		return nil
+	}
+	converted := make([]api.WeightedTarget, 0, len(targets))
+	for _, target := range targets {
+		converted = append(converted, api.WeightedTarget(target))
+	}
+	return converted
+}
+
+// configTargets 转换请求中按权重分流的目标，nil保持为nil（更新时表示不修改）
+func configTargets(targets []api.WeightedTarget) []config.WeightedTarget {
+	if targets == nil {
+		return nil
+	}
+	converted := make([]config.WeightedTarget, 0, len(targets))
+	for _, target := range targets {
+		converted = append(converted, config.WeightedTarget(target))
+	}
+	return converted
+}
+
+// apiPromptVariants 转换Prompt实验的变体，nil保持为nil
+func apiPromptVariants(variants []config.PromptVariant) []api.PromptVariant {
+	if variants == nil {
+		return nil
+	}
+	converted := make([]api.PromptVariant, 0, len(variants))
+	for _, variant := range variants {
+		converted = append(converted, api.PromptVariant(variant))
+	}
+	return converted
+}
+
+// configPromptVariants 转换请求中Prompt实验的变体，nil保持为nil（更新时表示不修改）
+func configPromptVariants(variants []api.PromptVariant) []config.PromptVariant {
+	if variants == nil {
+		return nil
+	}
+	converted := make([]config.PromptVariant, 0, len(variants))
+	for _, variant := range variants {
+		converted = append(converted, config.PromptVariant(variant))
+	}
+	return converted
+}
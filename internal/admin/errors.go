@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// serviceErrorCodes 服务层哨兵错误对应的错误码
+var serviceErrorCodes = []struct {
+	err  error
+	code i18n.Code
+}{
+	{service.ErrInvalidCredentials, i18n.CodeInvalidCredentials},
+	{service.ErrUserDisabled, i18n.CodeUserDisabled},
+	{service.ErrUserLocked, i18n.CodeUserLocked},
+	{service.ErrAlreadyInstalled, i18n.CodeAlreadyInstalled},
+	{service.ErrUserExists, i18n.CodeUserExists},
+	{service.ErrUserNotFound, i18n.CodeUserNotFound},
+	{service.ErrWrongOldPassword, i18n.CodeWrongOldPassword},
+	{service.ErrAPIKeyNotFound, i18n.CodeAPIKeyNotFound},
+	{service.ErrModelNotFound, i18n.CodeModelNotFound},
+	{service.ErrInvalidRole, i18n.CodeInvalidRole},
+	{service.ErrSessionNotFound, i18n.CodeSessionNotFound},
+	{service.ErrWebhookNotFound, i18n.CodeWebhookNotFound},
+	{service.ErrInvalidWebhook, i18n.CodeWebhookInvalid},
+	{service.ErrInvalidCatalog, i18n.CodeCatalogInvalid},
+	{service.ErrTenantForbidden, i18n.CodeTenantForbidden},
+	{service.ErrTenantNotFound, i18n.CodeTenantNotFound},
+	{service.ErrInvalidTenant, i18n.CodeInvalidTenant},
+	{db.ErrUserHasAPIKeys, i18n.CodeUserHasAPIKeys},
+	{db.ErrModelNotInTrash, i18n.CodeModelNotInTrash},
+	{db.ErrModelIDInUse, i18n.CodeModelIDInUse},
+	{db.ErrTenantExists, i18n.CodeTenantExists},
+	{db.ErrTenantNotFound, i18n.CodeTenantNotFound},
+}
+
+// statusErrorCodes 没有具体错误码时按HTTP状态码使用的通用错误码
+var statusErrorCodes = map[int]i18n.Code{
+	http.StatusBadRequest:          i18n.CodeBadRequest,
+	http.StatusUnauthorized:        i18n.CodeUnauthorized,
+	http.StatusForbidden:           i18n.CodeForbidden,
+	http.StatusNotFound:            i18n.CodeNotFound,
+	http.StatusConflict:            i18n.CodeConflict,
+	http.StatusServiceUnavailable:  i18n.CodeServiceUnavailable,
+	http.StatusInternalServerError: i18n.CodeInternalError,
+}
+
+// respondError 返回错误响应：code为HTTP状态码，error_code为稳定的错误码，
+// message按请求的Accept-Language本地化，details附加在信息之后
+func respondError(c *gin.Context, status int, code i18n.Code, details ...interface{}) {
+	c.JSON(status, gin.H{
+		"code":       status,
+		"error_code": code,
+		"message":    i18n.T(c, code, details...),
+	})
+}
+
+// respondPanic 返回处理请求时发生panic的500响应，包含请求ID便于排查
+func respondPanic(c *gin.Context, requestID string) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"code":       http.StatusInternalServerError,
+		"error_code": i18n.CodeInternalError,
+		"message":    i18n.T(c, i18n.CodeInternalError),
+		"request_id": requestID,
+	})
+}
+
+// respondServiceError 根据服务层返回的错误响应，哨兵错误映射为对应的错误码和本地化信息，
+// 其他错误使用状态码对应的通用错误码并原样返回错误信息
+func respondServiceError(c *gin.Context, status int, err error) {
+	for _, mapping := range serviceErrorCodes {
+		if errors.Is(err, mapping.err) {
+			respondError(c, status, mapping.code, errorDetail(err, mapping.err))
+			return
+		}
+	}
+
+	code, ok := statusErrorCodes[status]
+	if !ok {
+		code = i18n.CodeInternalError
+	}
+	c.JSON(status, gin.H{
+		"code":       status,
+		"error_code": code,
+		"message":    err.Error(),
+	})
+}
+
+// errorDetail 返回以"哨兵错误: 细节"形式包装时附加的细节，如"API Key不存在或无权限操作: 3"中的3
+func errorDetail(err, sentinel error) string {
+	detail, ok := strings.CutPrefix(err.Error(), sentinel.Error()+": ")
+	if !ok {
+		return ""
+	}
+	return detail
+}
@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// ExperimentRequest 设置Prompt实验的请求
+type ExperimentRequest struct {
+	Variants []config.PromptVariant `json:"variants"` // 变体列表，传入空数组时结束实验并保留基础Prompt
+}
+
+// PromoteVariantRequest 将变体提升为基础Prompt的请求
+type PromoteVariantRequest struct {
+	VariantID string `json:"variant_id" binding:"required"`
+}
+
+// SetExperimentTracker 设置代理服务的Prompt实验统计器
+func (s *AdminServer) SetExperimentTracker(tracker *stats.ExperimentTracker) {
+	s.experiments = tracker
+}
+
+// experimentResponse 返回模型的Prompt实验变体和各变体的请求统计
+func (s *AdminServer) experimentResponse(model *config.ModelConfig) gin.H {
+	variants := model.PromptVariants
+	if variants == nil {
+		variants = []config.PromptVariant{}
+	}
+	return gin.H{
+		"model_id": model.ID,
+		"variants": variants,
+		"stats":    s.experiments.Summary(model.ID),
+	}
+}
+
+// getExperiment 获取模型的Prompt实验（GET /api/v1/models/:id/experiment）
+func (s *AdminServer) getExperiment(c *gin.Context) {
+	modelID := c.Param("id")
+	model, exists := s.config.GetModel(modelID)
+	if !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    s.experimentResponse(model),
+	})
+}
+
+// updateExperiment 设置模型的Prompt实验变体（PUT /api/v1/models/:id/experiment），
+// 变体列表为空时结束实验，模型恢复注入基础Prompt
+func (s *AdminServer) updateExperiment(c *gin.Context) {
+	modelID := c.Param("id")
+	model, exists := s.config.GetModel(modelID)
+	if !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	var req ExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	originalModel := *model
+	model.PromptVariants = req.Variants
+	if len(model.PromptVariants) == 0 {
+		model.PromptVariants = nil
+	}
+	if err := model.Validate(); err != nil {
+		*model = originalModel
+		respondValidationError(c, err)
+		return
+	}
+	if err := s.persistModel(model); err != nil {
+		*model = originalModel
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
+		return
+	}
+	if model.PromptVariants == nil {
+		s.experiments.Forget(modelID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Prompt实验已更新",
+		"data":    s.experimentResponse(model),
+	})
+}
+
+// promoteVariant 将变体的Prompt设为模型的基础Prompt并结束实验（POST /api/v1/models/:id/experiment/promote）
+func (s *AdminServer) promoteVariant(c *gin.Context) {
+	modelID := c.Param("id")
+	model, exists := s.config.GetModel(modelID)
+	if !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	var req PromoteVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	originalModel := *model
+	if !model.PromotePromptVariant(req.VariantID) {
+		respondError(c, http.StatusNotFound, i18n.CodePromptVariantNotFound, req.VariantID)
+		return
+	}
+	if err := model.Validate(); err != nil {
+		*model = originalModel
+		respondValidationError(c, err)
+		return
+	}
+	if err := s.persistModel(model); err != nil {
+		*model = originalModel
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
+		return
+	}
+	s.experiments.Forget(modelID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "已将变体" + req.VariantID + "设为基础Prompt，实验已结束",
+		"data":    s.experimentResponse(model),
+	})
+}
+
+// persistModel 保存修改后的模型配置，使用配置服务时写入数据库，否则写入配置文件
+func (s *AdminServer) persistModel(model *config.ModelConfig) error {
+	if s.configService != nil {
+		return s.configService.UpdateModel(model)
+	}
+	return s.saveModelToFile(model)
+}
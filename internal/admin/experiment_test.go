@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+func TestPromptExperiment(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	if err := configService.SaveModel(&config.ModelConfig{
+		ID: "assistant", Name: "Assistant", Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions",
+		Type: config.ModelTypeChat, PromptValue: map[string]interface{}{"role": "system", "content": "base"},
+	}); err != nil {
+		t.Fatalf("保存模型失败: %v", err)
+	}
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	tracker := stats.NewExperimentTracker()
+	adminServer.SetExperimentTracker(tracker)
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/v1/models/assistant/experiment"+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	type experiment struct {
+		Variants []config.PromptVariant `json:"variants"`
+		Stats    []stats.VariantStats   `json:"stats"`
+	}
+	decode := func(w *httptest.ResponseRecorder) experiment {
+		t.Helper()
+		var response struct {
+			Data experiment `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		return response.Data
+	}
+
+	// 权重全为0的变体无法生效
+	w := call(http.MethodPut, "", `{"variants":[{"id":"a","weight":0,"prompt_value":"A"}]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("权重全为0时应返回400，实际得到%d %s", w.Code, w.Body.String())
+	}
+
+	w = call(http.MethodPut, "", `{"variants":[{"id":"a","weight":1,"prompt_value":"A"},{"id":"b","weight":1,"prompt_value":"B"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("设置实验失败: %d %s", w.Code, w.Body.String())
+	}
+	if model, _ := configService.GetModel("assistant"); len(model.PromptVariants) != 2 {
+		t.Errorf("实验变体未保存: %+v", model.PromptVariants)
+	}
+
+	tracker.Record("assistant", "a", false, 100*time.Millisecond)
+	tracker.Record("assistant", "b", true, 300*time.Millisecond)
+	got := decode(call(http.MethodGet, "", ""))
+	if len(got.Variants) != 2 || len(got.Stats) != 2 {
+		t.Fatalf("获取实验 = %+v", got)
+	}
+	if got.Stats[1].Variant != "b" || got.Stats[1].Requests != 1 || got.Stats[1].Errors != 1 {
+		t.Errorf("变体b的统计 = %+v", got.Stats[1])
+	}
+
+	w = call(http.MethodPost, "/promote", `{"variant_id":"missing"}`)
+	var errResp errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil || w.Code != http.StatusNotFound || errResp.ErrorCode != "prompt_variant_not_found" {
+		t.Errorf("提升不存在的变体应返回404 prompt_variant_not_found，实际得到%d %s", w.Code, w.Body.String())
+	}
+
+	w = call(http.MethodPost, "/promote", `{"variant_id":"b"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("提升变体失败: %d %s", w.Code, w.Body.String())
+	}
+	if got := decode(w); len(got.Variants) != 0 || len(got.Stats) != 0 {
+		t.Errorf("提升后应结束实验并清空统计: %+v", got)
+	}
+	model, _ := configService.GetModel("assistant")
+	if value, _ := model.PromptValue.(map[string]interface{}); value["content"] != "B" || value["role"] != "system" {
+		t.Errorf("提升后的基础Prompt = %v", model.PromptValue)
+	}
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestCheckModel(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	content := `models:
+  - id: "checked"
+    name: "检查模型"
+    target: "gpt-4o"
+    url: "` + upstream.URL + `"
+    health_check_interval: 30
+`
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	adminServer.SetHealthMonitor(healthcheck.NewMonitor(func() []*config.ModelConfig { return nil }, time.Second))
+	admin, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+
+	call := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+admin.Token)
+		w := httptest.NewRecorder()
+		adminServer.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call(http.MethodPost, "/api/v1/models/missing/check"); w.Code != http.StatusNotFound {
+		t.Errorf("检查不存在的模型应返回404，实际%d", w.Code)
+	}
+
+	w := call(http.MethodPost, "/api/v1/models/checked/check")
+	var checked struct {
+		Data healthcheck.ModelStatus `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &checked); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("检查模型失败: %d %s", w.Code, w.Body.String())
+	}
+	if !checked.Data.Healthy || len(checked.Data.Upstreams) != 1 || checked.Data.Upstreams[0].URL != upstream.URL {
+		t.Errorf("检查结果不正确: %s", w.Body.String())
+	}
+
+	w = call(http.MethodGet, "/api/v1/models/checked")
+	var model struct {
+		Data struct {
+			HealthCheckInterval int                      `json:"health_check_interval"`
+			Health              *healthcheck.ModelStatus `json:"health"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &model); err != nil {
+		t.Fatalf("解析模型失败: %v", err)
+	}
+	if model.Data.HealthCheckInterval != 30 || model.Data.Health == nil || !model.Data.Health.Healthy {
+		t.Errorf("模型详情应包含最近一次检查结果，实际%s", w.Body.String())
+	}
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// errorResponse 管理API的错误响应
+type errorResponse struct {
+	Code      int    `json:"code"`
+	ErrorCode string `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func TestLocalizedErrorResponses(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	if _, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"}); err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	request := func(method, path, body, language string) errorResponse {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if language != "" {
+			req.Header.Set("Accept-Language", language)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("解析响应失败: %v, body = %s", err, w.Body.String())
+		}
+		if response.Code != w.Code {
+			t.Errorf("响应中的code = %d，HTTP状态码为%d", response.Code, w.Code)
+		}
+		return response
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   string
+		zh, en string
+	}{
+		{
+			name:   "服务层哨兵错误",
+			method: http.MethodPost,
+			path:   "/api/v1/auth/login",
+			body:   `{"username":"admin","password":"wrong"}`,
+			code:   "invalid_credentials",
+			zh:     "用户名或密码错误",
+			en:     "Invalid username or password",
+		},
+		{
+			name:   "处理器错误",
+			method: http.MethodGet,
+			path:   "/api/v1/models",
+			code:   "missing_token",
+			zh:     "未提供认证token",
+			en:     "Authentication token is missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zh := request(tt.method, tt.path, tt.body, "")
+			en := request(tt.method, tt.path, tt.body, "en-US,en;q=0.9")
+			if zh.ErrorCode != tt.code || en.ErrorCode != tt.code {
+				t.Errorf("期望两种语言的错误码都为%s，实际为%s和%s", tt.code, zh.ErrorCode, en.ErrorCode)
+			}
+			if zh.Message != tt.zh {
+				t.Errorf("中文信息 = %q, want %q", zh.Message, tt.zh)
+			}
+			if en.Message != tt.en {
+				t.Errorf("英文信息 = %q, want %q", en.Message, tt.en)
+			}
+		})
+	}
+}
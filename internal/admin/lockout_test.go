@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestLoginLockoutAndUnlock(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Login = config.LoginConfig{MaxFailedAttempts: 2, LockoutDuration: time.Hour}
+	adminServer, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	admin, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	user, err := adminServer.authService.CreateUser(&service.CreateUserRequest{Username: "alice"}, admin.User.ID, service.GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	router := adminServer.Router()
+	correct := `{"username":"alice","password":"` + user.GeneratedPassword + `"}`
+
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call(http.MethodPost, "/api/v1/auth/login", "", `{"username":"alice","password":"wrong"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("密码错误应返回401，实际%d %s", w.Code, w.Body.String())
+	}
+	w := call(http.MethodPost, "/api/v1/auth/login", "", `{"username":"alice","password":"wrong"}`)
+	var response errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusLocked || response.ErrorCode != "user_locked" {
+		t.Fatalf("达到失败次数上限应返回423 user_locked，实际%d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, "/api/v1/auth/login", "", correct); w.Code != http.StatusLocked {
+		t.Fatalf("锁定期内密码正确也应拒绝，实际%d %s", w.Code, w.Body.String())
+	}
+
+	unlockPath := fmt.Sprintf("/api/v1/users/%d/unlock", user.User.ID)
+	if w := call(http.MethodPost, "/api/v1/users/999/unlock", admin.Token, ""); w.Code != http.StatusNotFound {
+		t.Errorf("解锁不存在的用户应返回404，实际%d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, unlockPath, admin.Token, ""); w.Code != http.StatusOK {
+		t.Fatalf("解锁失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, "/api/v1/auth/login", "", correct); w.Code != http.StatusOK {
+		t.Errorf("解锁后应能登录，实际%d %s", w.Code, w.Body.String())
+	}
+}
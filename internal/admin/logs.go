@@ -0,0 +1,368 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+// 日志导出格式
+const (
+	exportFormatJSONL = "jsonl"
+	exportFormatCSV   = "csv"
+)
+
+// exportFlushRows 导出时每写入多少行刷新一次响应
+const exportFlushRows = 500
+
+// exportLogs 导出时间范围内的访问日志（GET /api/v1/logs/export），逐行扫描日志文件并流式写入响应，
+// jsonl格式原样输出日志行，csv格式按日志配置的字段顺序输出
+func (s *AdminServer) exportLogs(c *gin.Context) {
+	format := c.DefaultQuery("format", exportFormatJSONL)
+	if format != exportFormatJSONL && format != exportFormatCSV {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidExportFormat)
+		return
+	}
+
+	from, ok := parseExportTime(c.Query("from"))
+	if !ok {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidTimeRange, c.Query("from"))
+		return
+	}
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		if to, ok = parseExportTime(value); !ok {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidTimeRange, value)
+			return
+		}
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidTimeRange)
+		return
+	}
+
+	limit := logger.DefaultExportLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidExportLimit)
+			return
+		}
+		if limit > logger.MaxExportLimit {
+			limit = logger.MaxExportLimit
+		}
+	}
+
+	output, ok := s.exportLoggerConfig(c.Query("logger"))
+	if !ok {
+		respondError(c, http.StatusNotFound, i18n.CodeLoggerNotFound, c.Query("logger"))
+		return
+	}
+	exporter, err := logger.NewLogExporter(output)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeLogExportUnsupported, err)
+		return
+	}
+	query := logger.ExportQuery{From: from, To: to, ModelID: c.Query("model_id"), Limit: limit}
+	if query.ModelID != "" && !exporter.RecordsModel() {
+		respondError(c, http.StatusBadRequest, i18n.CodeLogExportUnsupported, "$model_id")
+		return
+	}
+
+	fileName := fmt.Sprintf("%s-%s-%s.%s", output.Name, from.Format("20060102T150405"), to.Format("20060102T150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Header("Cache-Control", "no-store")
+
+	var writeEntry func(entry logger.ExportEntry) error
+	var flush func() error
+	switch format {
+	case exportFormatCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(c.Writer)
+		if err := writer.Write(exporter.Columns()); err != nil {
+			respondError(c, http.StatusInternalServerError, i18n.CodeLogExportFailed, err)
+			return
+		}
+		record := make([]string, len(exporter.Columns()))
+		writeEntry = func(entry logger.ExportEntry) error {
+			for i, value := range entry.Values {
+				record[i] = csvValue(value)
+			}
+			return writer.Write(record)
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	default:
+		c.Header("Content-Type", "application/x-ndjson")
+		writeEntry = func(entry logger.ExportEntry) error {
+			if _, err := c.Writer.Write(entry.Raw); err != nil {
+				return err
+			}
+			_, err := c.Writer.Write([]byte{'\n'})
+			return err
+		}
+		flush = func() error { return nil }
+	}
+
+	rows := 0
+	_, err = exporter.Export(query, func(entry logger.ExportEntry) error {
+		if err := writeEntry(entry); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		if !c.Writer.Written() {
+			// 还未写出任何数据时仍可返回错误响应
+			c.Writer.Header().Del("Content-Disposition")
+			respondError(c, http.StatusInternalServerError, i18n.CodeLogExportFailed, err)
+			return
+		}
+		fmt.Printf("导出日志中断: %v\n", err)
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+}
+
+// searchLogs 在指定日志的文件中搜索匹配的行（GET /api/v1/logs/:name/search），
+// 未指定from时搜索to之前24小时，时间范围最多7天
+func (s *AdminServer) searchLogs(c *gin.Context) {
+	text := c.Query("q")
+	if text == "" {
+		respondError(c, http.StatusBadRequest, i18n.CodeMissingSearchQuery)
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		var ok bool
+		if to, ok = parseExportTime(value); !ok {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidTimeRange, value)
+			return
+		}
+	}
+	from := to.Add(-logger.DefaultSearchWindow)
+	if value := c.Query("from"); value != "" {
+		var ok bool
+		if from, ok = parseExportTime(value); !ok {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidTimeRange, value)
+			return
+		}
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidTimeRange)
+		return
+	}
+	if to.Sub(from) > logger.MaxSearchWindow {
+		respondError(c, http.StatusBadRequest, i18n.CodeSearchWindowTooLarge)
+		return
+	}
+
+	limit := logger.DefaultSearchLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidSearchLimit)
+			return
+		}
+		if limit > logger.MaxSearchLimit {
+			limit = logger.MaxSearchLimit
+		}
+	}
+
+	name := c.Param("name")
+	output, ok := s.exportLoggerConfig(name)
+	if !ok {
+		respondError(c, http.StatusNotFound, i18n.CodeLoggerNotFound, name)
+		return
+	}
+	result, err := logger.SearchLogs(output, logger.SearchQuery{Text: text, From: from, To: to, Limit: limit})
+	if err != nil {
+		if errors.Is(err, logger.ErrNotSupported) {
+			respondError(c, http.StatusBadRequest, i18n.CodeLogSearchUnsupported, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, i18n.CodeLogSearchFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// verifyLogFile 校验开启了hash_chain的日志文件的哈希链（GET /api/v1/logs/:name/verify），
+// file参数为日志目录中的文件名，未指定时校验当前文件；哈希链断开时仍返回200，data.valid为false
+func (s *AdminServer) verifyLogFile(c *gin.Context) {
+	name := c.Param("name")
+	output, ok := s.exportLoggerConfig(name)
+	if !ok {
+		respondError(c, http.StatusNotFound, i18n.CodeLoggerNotFound, name)
+		return
+	}
+	result, err := logger.VerifyHashChain(output, c.Query("file"))
+	if err != nil {
+		switch {
+		case errors.Is(err, logger.ErrNotSupported):
+			respondError(c, http.StatusBadRequest, i18n.CodeLogVerifyUnsupported, err)
+		case errors.Is(err, logger.ErrLogFileNotFound):
+			respondError(c, http.StatusNotFound, i18n.CodeLogFileNotFound, c.Query("file"))
+		default:
+			respondError(c, http.StatusInternalServerError, i18n.CodeLogVerifyFailed, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// defaultRecentLimit 未指定limit时返回的最近记录数
+const defaultRecentLimit = 100
+
+// getRecentLogs 获取内存日志中最近的记录（GET /api/v1/logs/recent），从新到旧排列；
+// 未指定name时使用配置中第一个memory驱动的日志，after为上次获取到的最大序号，用于轮询新记录
+func (s *AdminServer) getRecentLogs(c *gin.Context) {
+	limit := defaultRecentLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidRecentQuery)
+			return
+		}
+	}
+	var after uint64
+	if value := c.Query("after"); value != "" {
+		var err error
+		if after, err = strconv.ParseUint(value, 10, 64); err != nil {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidRecentQuery)
+			return
+		}
+	}
+
+	name := c.Query("name")
+	if name == "" && s.serverConfig != nil {
+		for _, output := range s.serverConfig.Loggers {
+			if output.Driver == logger.DriverMemory {
+				name = output.Name
+				break
+			}
+		}
+	}
+	requestLogger, ok := logger.GlobalLoggerManager.GetLogger(name)
+	if !ok {
+		respondError(c, http.StatusNotFound, i18n.CodeLoggerNotFound, name)
+		return
+	}
+	recent, err := requestLogger.Recent(after, limit)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeLogRecentUnsupported, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    recent,
+	})
+}
+
+// getLoggerStatus 返回各日志记录器的健康状态（GET /api/v1/loggers/status）
+func (s *AdminServer) getLoggerStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    logger.GlobalLoggerManager.Status(),
+	})
+}
+
+// getLogFiles 获取所有文件日志记录器的日志文件列表，按日志记录器名称分组
+func (s *AdminServer) getLogFiles(c *gin.Context) {
+	files, err := logger.GlobalLoggerManager.GetAllLogFiles()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListLogFilesFailed, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    files,
+	})
+}
+
+// exportLoggerConfig 返回要导出的日志配置，name为空时使用第一个JSON格式的文件日志
+func (s *AdminServer) exportLoggerConfig(name string) (logger.OutputConfig, bool) {
+	if s.serverConfig == nil {
+		return logger.OutputConfig{}, false
+	}
+	for _, output := range s.serverConfig.Loggers {
+		if name == "" && output.Driver == logger.DriverFile && output.Type == logger.FormatterJSON {
+			return output, true
+		}
+		if name != "" && output.Name == name {
+			return output, true
+		}
+	}
+	return logger.OutputConfig{}, false
+}
+
+// parseExportTime 解析RFC3339时间或YYYY-MM-DD日期（服务器本地时区的零点）
+func parseExportTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	return t, err == nil
+}
+
+// csvFormulaPrefixes 表格软件会作为公式执行的单元格开头字符
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvValue 将日志字段值转换为CSV单元格，对象和数组以JSON表示，缺失的字段为空；
+// 以公式字符开头的字符串前加'，避免日志中的Prompt和请求头在表格软件中作为公式执行
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune(csvFormulaPrefixes, rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,454 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// seedLogLine 按JSON格式化器的输出格式生成一行default分组的访问日志
+func seedLogLine(t *testing.T, requestID string, timestamp time.Time, modelID, userAgent string) string {
+	t.Helper()
+	line, err := json.Marshal(map[string]interface{}{
+		"default": map[string]interface{}{
+			"request_id":  requestID,
+			"timestamp":   timestamp.Format(time.RFC3339),
+			"model_id":    modelID,
+			"status_code": 200,
+			"ua":          userAgent,
+		},
+	})
+	if err != nil {
+		t.Fatalf("生成日志失败: %v", err)
+	}
+	return string(line)
+}
+
+func TestExportLogs(t *testing.T) {
+	logDir := t.TempDir()
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	lines := map[string]string{
+		"r0": seedLogLine(t, "r0", at(1, 12), "gpt-4o", "old"),
+		"r1": seedLogLine(t, "r1", at(9, 23), "gpt-4o", "before"),
+		"r2": seedLogLine(t, "r2", at(10, 8), "gpt-4o", `curl/8.0, "quoted"`),
+		"r3": seedLogLine(t, "r3", at(10, 9), "claude-3", "other-model"),
+		"r4": seedLogLine(t, "r4", at(11, 10), "gpt-4o", "current"),
+		"r5": seedLogLine(t, "r5", at(12, 10), "gpt-4o", "after"),
+	}
+	files := map[string][]string{
+		"access-20240301.log": {lines["r0"]},
+		"access-20240309.log": {lines["r1"]},
+		"access-20240310.log": {lines["r2"], "not json", lines["r3"]},
+		"access.log":          {lines["r4"], lines["r5"]},
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(logDir, name), []byte(strings.Join(content, "\n")+"\n"), 0644); err != nil {
+			t.Fatalf("写入日志文件失败: %v", err)
+		}
+	}
+
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Loggers = []logger.OutputConfig{{
+		Name:    "access",
+		Driver:  logger.DriverFile,
+		Enabled: true,
+		Type:    logger.FormatterJSON,
+		File:    "access.log",
+		Dir:     logDir,
+		Period:  logger.PeriodDay,
+		Formatter: logger.FormatterConfig{Fields: map[string][]string{
+			"default": {"$request_id", "$timestamp", "$model_id", "$status_code", "$user_agent as ua"},
+		}},
+	}}
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	export := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/logs/export?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const rangeQuery = "from=2024-03-10&to=2024-03-12&model_id=gpt-4o"
+
+	// jsonl原样输出匹配的日志行
+	w := export(rangeQuery + "&format=jsonl")
+	if w.Code != http.StatusOK {
+		t.Fatalf("导出jsonl失败: %d %s", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment;") || !strings.Contains(disposition, ".jsonl") {
+		t.Errorf("Content-Disposition = %q", disposition)
+	}
+	if want := lines["r2"] + "\n" + lines["r4"] + "\n"; w.Body.String() != want {
+		t.Errorf("jsonl导出内容不匹配\n got: %s\nwant: %s", w.Body.String(), want)
+	}
+
+	// csv按日志配置的字段顺序输出，并正确转义逗号和引号
+	w = export(rangeQuery + "&format=csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("导出csv失败: %d %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	if !strings.Contains(w.Body.String(), `"curl/8.0, ""quoted"""`) {
+		t.Errorf("包含逗号和引号的值未正确转义: %s", w.Body.String())
+	}
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("解析csv失败: %v", err)
+	}
+	want := [][]string{
+		{"request_id", "timestamp", "model_id", "status_code", "ua"},
+		{"r2", at(10, 8).Format(time.RFC3339), "gpt-4o", "200", `curl/8.0, "quoted"`},
+		{"r4", at(11, 10).Format(time.RFC3339), "gpt-4o", "200", "current"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("csv行数 = %d, want %d: %v", len(records), len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("第%d行 = %v, want %v", i, records[i], want[i])
+		}
+	}
+
+	// 行数上限
+	w = export(rangeQuery + "&limit=1")
+	if w.Code != http.StatusOK || w.Body.String() != lines["r2"]+"\n" {
+		t.Errorf("limit=1时应只导出第一条: %d %s", w.Code, w.Body.String())
+	}
+
+	// 不按模型筛选时包含其他模型
+	w = export("from=2024-03-10&to=2024-03-12&format=jsonl")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"r3"`) {
+		t.Errorf("未按模型筛选时应包含r3: %d %s", w.Code, w.Body.String())
+	}
+
+	for query, code := range map[string]string{
+		rangeQuery + "&format=xml":      "invalid_export_format",
+		"from=2024-03-12&to=2024-03-10": "invalid_time_range",
+		"to=2024-03-10":                 "invalid_time_range",
+		rangeQuery + "&limit=0":         "invalid_export_limit",
+		rangeQuery + "&logger=missing":  "logger_not_found",
+	} {
+		w := export(query)
+		var response errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.ErrorCode != code {
+			t.Errorf("%s: 期望错误码%s，实际得到%d %s", query, code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCSVValueNeutralizesFormulas(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-1+2", "'-1+2"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"normal", "normal"},
+		{"", ""},
+		{json.Number("-3"), "-3"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := csvValue(tt.value); got != tt.want {
+			t.Errorf("csvValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerStatus(t *testing.T) {
+	// 父路径是普通文件时日志目录无法创建，模拟只读文件系统
+	parent := filepath.Join(t.TempDir(), "readonly")
+	if err := os.WriteFile(parent, nil, 0444); err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	broken := config.DefaultLoggerConfig()
+	broken.Name = "test-broken"
+	broken.Dir = filepath.Join(parent, "logs")
+	if err := logger.GlobalLoggerManager.AddLogger(broken.Name, broken); err == nil {
+		t.Fatal("日志目录不可写时应返回错误")
+	}
+	defer logger.GlobalLoggerManager.RemoveLogger(broken.Name)
+	logger.GlobalLoggerManager.LogToAll(logger.RequestLogData{RequestID: "dropped"})
+
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/loggers/status", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("获取日志记录器状态失败: %d %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data []logger.LoggerStatus `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	var status *logger.LoggerStatus
+	for i := range response.Data {
+		if response.Data[i].Name == broken.Name {
+			status = &response.Data[i]
+		}
+	}
+	if status == nil {
+		t.Fatalf("响应中缺少%s: %s", broken.Name, w.Body.String())
+	}
+	if status.Status != logger.LoggerStatusFailed || status.Dropped != 1 || status.LastError == "" {
+		t.Errorf("日志记录器状态 = %+v, want failed且dropped=1", status)
+	}
+
+	// 健康检查返回degraded，但不暴露错误详情
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Status  string                   `json:"status"`
+		Loggers []map[string]interface{} `json:"loggers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+		t.Fatalf("解析健康检查响应失败: %v", err)
+	}
+	if w.Code != http.StatusOK || health.Status != "degraded" {
+		t.Errorf("健康检查 = %d %s, want 200且status为degraded", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "last_error") {
+		t.Errorf("健康检查不应包含错误详情: %s", w.Body.String())
+	}
+}
+
+func TestSearchLogs(t *testing.T) {
+	logDir := t.TempDir()
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	old := now.AddDate(0, 0, -30)
+	files := map[string][]string{
+		"access-" + old.Format("20060102") + ".log":       {seedLogLine(t, "r0", old, "gpt-4o", "old")},
+		"access-" + yesterday.Format("20060102") + ".log": {seedLogLine(t, "r1", yesterday, "gpt-4o", "curl"), seedLogLine(t, "r2", yesterday, "claude-3", "curl")},
+		"access.log": {seedLogLine(t, "r3", now, "gpt-4o", "curl"), seedLogLine(t, "r4", now, "claude-3", "sdk")},
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(logDir, name), []byte(strings.Join(content, "\n")+"\n"), 0644); err != nil {
+			t.Fatalf("写入日志文件失败: %v", err)
+		}
+	}
+
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Loggers = []logger.OutputConfig{
+		{Name: "access", Driver: logger.DriverFile, Enabled: true, Type: logger.FormatterJSON, File: "access.log", Dir: logDir, Period: logger.PeriodDay},
+		{Name: "remote", Driver: logger.DriverHTTP, Enabled: true, Type: logger.FormatterJSON},
+	}
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	search := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	requestIDs := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var response struct {
+			Data logger.SearchResult `json:"data"`
+		}
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &response) != nil {
+			t.Fatalf("搜索日志失败: %d %s", w.Code, w.Body.String())
+		}
+		var ids []string
+		for _, match := range response.Data.Matches {
+			ids = append(ids, strings.Split(strings.SplitAfter(match.Content, `"request_id":"`)[1], `"`)[0])
+		}
+		return ids
+	}
+
+	// 字段相等匹配，默认搜索最近24小时，当前文件最先扫描
+	if got := requestIDs(search("/api/v1/logs/access/search?q=model_id=gpt-4o")); strings.Join(got, ",") != "r3,r1" {
+		t.Errorf("按字段搜索结果 = %v, want [r3 r1]", got)
+	}
+	// 子串匹配，指定时间范围时包含更早的文件
+	from := old.Format("2006-01-02")
+	if got := requestIDs(search("/api/v1/logs/access/search?q=old&from=" + from + "&to=" + old.AddDate(0, 0, 7).Format("2006-01-02"))); strings.Join(got, ",") != "r0" {
+		t.Errorf("按子串搜索结果 = %v, want [r0]", got)
+	}
+	// 达到结果数上限时标记截断
+	w := search("/api/v1/logs/access/search?q=curl&limit=2")
+	if got := requestIDs(w); strings.Join(got, ",") != "r3,r1" || !strings.Contains(w.Body.String(), `"truncated":true`) {
+		t.Errorf("limit=2时结果 = %v: %s", got, w.Body.String())
+	}
+
+	for path, code := range map[string]string{
+		"/api/v1/logs/access/search":                    "missing_search_query",
+		"/api/v1/logs/access/search?q=x&limit=0":        "invalid_search_limit",
+		"/api/v1/logs/access/search?q=x&from=yesterday": "invalid_time_range",
+		"/api/v1/logs/access/search?q=x&from=" + from:   "search_window_too_large",
+		"/api/v1/logs/missing/search?q=x":               "logger_not_found",
+		"/api/v1/logs/remote/search?q=x":                "log_search_unsupported",
+	} {
+		w := search(path)
+		var response errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.ErrorCode != code {
+			t.Errorf("%s: 期望错误码%s，实际得到%d %s", path, code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestVerifyLogFile(t *testing.T) {
+	logDir := t.TempDir()
+	audit := logger.OutputConfig{Name: "audit", Driver: logger.DriverFile, Enabled: true, Type: logger.FormatterJSON, File: "audit.log", Dir: logDir, Period: logger.PeriodDay, HashChain: true}
+	output, err := logger.NewFileOutput(audit)
+	if err != nil {
+		t.Fatalf("创建文件输出器失败: %v", err)
+	}
+	for _, requestID := range []string{"r1", "r2"} {
+		if err := output.Write([]byte(seedLogLine(t, requestID, time.Now(), "gpt-4o", "curl") + "\n")); err != nil {
+			t.Fatalf("写入日志失败: %v", err)
+		}
+	}
+	output.Close()
+
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Loggers = []logger.OutputConfig{
+		audit,
+		{Name: "access", Driver: logger.DriverFile, Enabled: true, Type: logger.FormatterJSON, File: "access.log", Dir: logDir, Period: logger.PeriodDay},
+	}
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	runRouteSteps(t, router, login.Token, []routeStep{
+		{"校验当前文件", http.MethodGet, "/api/v1/logs/audit/verify", "", http.StatusOK, "data.records", "2"},
+		{"指定文件名", http.MethodGet, "/api/v1/logs/audit/verify?file=audit.log", "", http.StatusOK, "data.valid", "true"},
+		{"文件不存在", http.MethodGet, "/api/v1/logs/audit/verify?file=audit-20000101.log", "", http.StatusNotFound, "error_code", "log_file_not_found"},
+		{"文件名不能包含路径", http.MethodGet, "/api/v1/logs/audit/verify?file=../audit.log", "", http.StatusNotFound, "error_code", "log_file_not_found"},
+		{"未开启hash_chain", http.MethodGet, "/api/v1/logs/access/verify", "", http.StatusBadRequest, "error_code", "log_verify_unsupported"},
+		{"日志不存在", http.MethodGet, "/api/v1/logs/missing/verify", "", http.StatusNotFound, "error_code", "logger_not_found"},
+	})
+
+	// 修改第一条记录后报告第二行断开
+	path := filepath.Join(logDir, "audit.log")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取日志文件失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), `"r1"`, `"r9"`, 1)), 0644); err != nil {
+		t.Fatalf("修改日志文件失败: %v", err)
+	}
+	runRouteSteps(t, router, login.Token, []routeStep{
+		{"修改后校验", http.MethodGet, "/api/v1/logs/audit/verify", "", http.StatusOK, "data.broken_line", "2"},
+	})
+}
+
+func TestRecentLogs(t *testing.T) {
+	recent := logger.OutputConfig{Name: "test-recent", Driver: logger.DriverMemory, Enabled: true, Type: logger.FormatterLine, BufferSize: 10,
+		Formatter: logger.FormatterConfig{Fields: map[string][]string{"fields": {"$request_id"}}}}
+	stdout := logger.OutputConfig{Name: "test-stdout", Driver: logger.DriverStdout, Enabled: true, Type: logger.FormatterLine}
+	for _, output := range []logger.OutputConfig{recent, stdout} {
+		if err := logger.GlobalLoggerManager.AddLogger(output.Name, output); err != nil {
+			t.Fatalf("添加日志记录器失败: %v", err)
+		}
+		defer logger.GlobalLoggerManager.RemoveLogger(output.Name)
+	}
+	memoryLogger, _ := logger.GlobalLoggerManager.GetLogger(recent.Name)
+	for _, requestID := range []string{"r1", "r2"} {
+		if err := memoryLogger.LogRequest(logger.RequestLogData{RequestID: requestID}); err != nil {
+			t.Fatalf("记录日志失败: %v", err)
+		}
+	}
+
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Loggers = append([]logger.OutputConfig{recent}, serverConfig.Loggers...)
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+
+	runRouteSteps(t, adminServer.Router(), login.Token, []routeStep{
+		{"默认使用第一个内存日志", http.MethodGet, "/api/v1/logs/recent", "", http.StatusOK, "data.records.#", "2"},
+		{"从新到旧排列", http.MethodGet, "/api/v1/logs/recent?limit=1", "", http.StatusOK, "data.records.0.content", "r2"},
+		{"只返回新记录", http.MethodGet, "/api/v1/logs/recent?name=test-recent&after=1", "", http.StatusOK, "data.records.#.seq", "[2]"},
+		{"记录总数", http.MethodGet, "/api/v1/logs/recent", "", http.StatusOK, "data.total", "2"},
+		{"limit无效", http.MethodGet, "/api/v1/logs/recent?limit=-1", "", http.StatusBadRequest, "error_code", "invalid_recent_query"},
+		{"after无效", http.MethodGet, "/api/v1/logs/recent?after=x", "", http.StatusBadRequest, "error_code", "invalid_recent_query"},
+		{"不是内存日志", http.MethodGet, "/api/v1/logs/recent?name=test-stdout", "", http.StatusBadRequest, "error_code", "log_recent_unsupported"},
+		{"日志不存在", http.MethodGet, "/api/v1/logs/recent?name=missing", "", http.StatusNotFound, "error_code", "logger_not_found"},
+	})
+}
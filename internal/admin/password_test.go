@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestMustChangePasswordBlocksAdminAPI(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	admin, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	call := func(method, path, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call(http.MethodPost, "/api/v1/users", admin.Token, `{"username":"newcomer"}`)
+	if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+		t.Fatalf("创建用户失败: %d %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data service.CreateUserResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Data.GeneratedPassword == "" {
+		t.Fatalf("解析创建用户响应失败: %v %s", err, w.Body.String())
+	}
+
+	w = call(http.MethodPost, "/api/v1/auth/login", "", `{"username":"newcomer","password":"`+created.Data.GeneratedPassword+`"}`)
+	var login struct {
+		Data service.LoginResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("登录失败: %d %s", w.Code, w.Body.String())
+	}
+	if !login.Data.MustChangePassword {
+		t.Errorf("登录响应应包含must_change_password: %s", w.Body.String())
+	}
+	token := login.Data.Token
+
+	// 修改密码前不能使用其他管理功能
+	w = call(http.MethodGet, "/api/v1/models", token, "")
+	var response errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusForbidden || response.ErrorCode != "password_change_required" {
+		t.Errorf("修改密码前应返回403 password_change_required，实际得到%d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodGet, "/api/v1/auth/profile", token, ""); w.Code != http.StatusOK {
+		t.Errorf("修改密码前应能查看个人信息，实际得到%d %s", w.Code, w.Body.String())
+	}
+
+	w = call(http.MethodPut, "/api/v1/user/password", token, `{"old_password":"`+created.Data.GeneratedPassword+`","new_password":"my-secret"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("修改密码失败: %d %s", w.Code, w.Body.String())
+	}
+	// 修改密码后同一token恢复正常
+	if w := call(http.MethodGet, "/api/v1/models", token, ""); w.Code != http.StatusOK {
+		t.Errorf("修改密码后应能访问管理功能，实际得到%d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// requireCapability 权限中间件，当前用户的角色没有capability时返回403；
+// 只有superuser拥有的权限使用admin_required错误码，与旧版本的管理员检查保持一致；
+// system:write还要求全局管理员，租户管理员返回global_admin_required
+func (s *AdminServer) requireCapability(capability db.Capability) gin.HandlerFunc {
+	code := i18n.CodeAdminRequired
+	for _, role := range []db.Role{db.RoleOperator, db.RoleViewer} {
+		if role.Can(capability) {
+			code = i18n.CodeRoleNotAllowed
+		}
+	}
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role, ok := role.(db.Role); ok && role.Can(capability) {
+			// 系统设置对所有租户生效，租户管理员不能修改
+			if capability == db.CapSystemWrite && !requireGlobalScope(c) {
+				return
+			}
+			c.Next()
+			return
+		}
+		respondError(c, http.StatusForbidden, code, role)
+		c.Abort()
+	}
+}
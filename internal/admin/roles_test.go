@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestRolePermissions(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService := adminServer.authService.(*service.AuthService)
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	if admin.User.Role != db.RoleSuperuser || !admin.User.IsAdmin {
+		t.Fatalf("首个用户应为superuser，实际%+v", admin.User)
+	}
+
+	tokens := map[db.Role]string{db.RoleSuperuser: admin.Token}
+	for _, role := range []db.Role{db.RoleOperator, db.RoleViewer} {
+		created, err := authService.CreateUser(&service.CreateUserRequest{Username: string(role), Role: role}, admin.User.ID, service.GlobalScope)
+		if err != nil {
+			t.Fatalf("创建%s失败: %v", role, err)
+		}
+		// 清除临时密码标记，避免被要求先修改密码
+		created.User.MustChangePassword = false
+		if err := configService.GetDBManager().UpdateUser(created.User); err != nil {
+			t.Fatalf("更新用户失败: %v", err)
+		}
+		token, _, err := authService.GenerateToken(created.User)
+		if err != nil {
+			t.Fatalf("生成token失败: %v", err)
+		}
+		tokens[role] = token
+	}
+
+	router := adminServer.Router()
+	call := func(role db.Role, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens[role])
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	model := `{"id":"%s","name":"m","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions"}`
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		allowed map[db.Role]bool
+	}{
+		{"查看模型", http.MethodGet, "/api/v1/models", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true, db.RoleViewer: true}},
+		{"创建模型", http.MethodPost, "/api/v1/models", model, map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"查看用户", http.MethodGet, "/api/v1/users", "", map[db.Role]bool{db.RoleSuperuser: true}},
+		{"查看API Key", http.MethodGet, "/api/v1/api-keys", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true, db.RoleViewer: true}},
+		{"创建API Key", http.MethodPost, "/api/v1/api-keys", `{"name":"k"}`, map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"设置维护模式", http.MethodPut, "/api/v1/maintenance", `{"enabled":false}`, map[db.Role]bool{db.RoleSuperuser: true}},
+		{"查看日志记录器", http.MethodGet, "/api/v1/loggers/status", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"查看日志文件", http.MethodGet, "/api/v1/loggers/files", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"查看模型回收站", http.MethodGet, "/api/v1/models/trash", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true, db.RoleViewer: true}},
+		{"恢复模型", http.MethodPost, "/api/v1/models/missing/restore", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"永久删除模型", http.MethodDelete, "/api/v1/models/missing/purge", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"导出日志", http.MethodGet, "/api/v1/logs/export", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"搜索日志", http.MethodGet, "/api/v1/logs/access/search?q=x", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"校验日志文件", http.MethodGet, "/api/v1/logs/access/verify", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"最近的日志记录", http.MethodGet, "/api/v1/logs/recent", "", map[db.Role]bool{db.RoleSuperuser: true, db.RoleOperator: true}},
+		{"查看全部API Key", http.MethodGet, "/api/v1/admin/api-keys", "", map[db.Role]bool{db.RoleSuperuser: true}},
+		{"查看全部会话", http.MethodGet, "/api/v1/sessions", "", map[db.Role]bool{db.RoleSuperuser: true}},
+		{"管理告警Webhook", http.MethodGet, "/api/v1/webhooks", "", map[db.Role]bool{db.RoleSuperuser: true}},
+		{"查看租户", http.MethodGet, "/api/v1/tenants", "", map[db.Role]bool{db.RoleSuperuser: true}},
+	}
+	for _, tt := range tests {
+		for _, role := range []db.Role{db.RoleSuperuser, db.RoleOperator, db.RoleViewer} {
+			body := tt.body
+			if strings.Contains(body, "%s") {
+				body = strings.Replace(body, "%s", "model-"+string(role), 1)
+			}
+			w := call(role, tt.method, tt.path, body)
+			if tt.allowed[role] {
+				if w.Code == http.StatusForbidden {
+					t.Errorf("%s: %s应允许，实际%d %s", tt.name, role, w.Code, w.Body.String())
+				}
+				continue
+			}
+			wantCode := "role_not_allowed"
+			if len(tt.allowed) == 1 {
+				wantCode = "admin_required"
+			}
+			var response errorResponse
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != http.StatusForbidden || response.ErrorCode != wantCode {
+				t.Errorf("%s: %s应返回403 %s，实际%d %s", tt.name, role, wantCode, w.Code, w.Body.String())
+			}
+		}
+	}
+
+	// 个人信息返回角色拥有的权限
+	for role, want := range map[db.Role]string{
+		db.RoleSuperuser: `["models:write","keys:write","logs:read","users:write","system:write"]`,
+		db.RoleOperator:  `["models:write","keys:write","logs:read"]`,
+		db.RoleViewer:    `[]`,
+	} {
+		w := call(role, http.MethodGet, "/api/v1/auth/profile", "")
+		if got := gjson.Get(w.Body.String(), "data.capabilities").Raw; got != want || gjson.Get(w.Body.String(), "data.role").String() != string(role) {
+			t.Errorf("%s的个人信息 = %s, want capabilities %s", role, w.Body.String(), want)
+		}
+	}
+
+	w := call(db.RoleSuperuser, http.MethodPut, "/api/v1/users/2", `{"role":"owner"}`)
+	var response errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusBadRequest || response.ErrorCode != "invalid_role" {
+		t.Errorf("设置不支持的角色应返回400 invalid_role，实际%d %s", w.Code, w.Body.String())
+	}
+	if w := call(db.RoleSuperuser, http.MethodPut, "/api/v1/users/3", `{"role":"operator"}`); w.Code != http.StatusOK {
+		t.Fatalf("修改角色失败: %d %s", w.Code, w.Body.String())
+	}
+	user, _ := authService.GetUserByID(3)
+	if user.Role != db.RoleOperator {
+		t.Errorf("修改后的角色 = %s, want operator", user.Role)
+	}
+}
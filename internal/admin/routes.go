@@ -0,0 +1,263 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/clientip"
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/recovery"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// NewRouter 使用配置服务创建管理API路由器，不绑定端口，可直接用于httptest
+func NewRouter(configService *service.ConfigService, serverConfig *config.ServerConfig) (*gin.Engine, error) {
+	s, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return s.Router(), nil
+}
+
+// Router 创建注册了全部管理API路由和静态文件的路由器
+func (s *AdminServer) Router() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+
+	trustedProxies := config.DefaultTrustedProxies
+	if s.serverConfig != nil {
+		trustedProxies = s.serverConfig.TrustedProxies
+	}
+	if err := clientip.Configure(r, trustedProxies); err != nil {
+		fmt.Printf("%v，不采信转发请求头\n", err)
+	}
+
+	// 添加中间件
+	r.Use(gin.Logger())
+	r.Use(recovery.Middleware(respondPanic))
+	r.Use(s.corsMiddleware())
+
+	// 健康检查 - 放在最前面避免路由冲突
+	r.GET("/health", s.healthCheck)
+
+	// 设置嵌入式静态文件服务
+	s.setupEmbeddedStaticFiles(r)
+
+	// 根路径处理 - 放在最后避免覆盖其他路由
+	r.NoRoute(func(c *gin.Context) {
+		// 如果是API请求，返回404
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			respondError(c, http.StatusNotFound, i18n.CodeAPINotFound)
+			return
+		}
+		// 其他请求返回前端页面
+		s.serveIndexHTML(c)
+	})
+
+	// API路由组
+	api := r.Group("/api/v1")
+	s.registerPublicRoutes(api)
+
+	// 需要认证的API
+	protected := api.Group("")
+	protected.Use(s.authMiddleware())
+	s.registerAccountRoutes(protected)
+	s.registerModelRoutes(protected)
+	s.registerSystemRoutes(protected)
+	s.registerUserRoutes(protected)
+	s.registerAPIKeyRoutes(protected)
+	s.registerSessionRoutes(protected)
+	s.registerWebhookRoutes(protected)
+	s.registerTenantRoutes(protected)
+
+	return r
+}
+
+// registerPublicRoutes 注册无需认证的安装、登录和公开配置API
+func (s *AdminServer) registerPublicRoutes(api *gin.RouterGroup) {
+	auth := api.Group("/auth")
+	{
+		auth.GET("/check-install", s.checkInstall)            // 检查是否首次安装
+		auth.GET("/public-key", s.getPublicKey)               // 获取公钥
+		auth.POST("/register", s.register)                    // 用户注册（仅首次安装）
+		auth.POST("/encrypted-register", s.encryptedRegister) // 加密用户注册
+		auth.POST("/login", s.login)                          // 用户登录
+		auth.POST("/encrypted-login", s.encryptedLogin)       // 加密用户登录
+	}
+
+	api.GET("/version", s.getVersion) // 获取版本信息
+
+	publicConfig := api.Group("/config")
+	{
+		publicConfig.GET("/system", s.getSystemConfig) // 获取系统配置
+	}
+}
+
+// registerAccountRoutes 注册当前用户的注销、个人信息、修改密码和token有效期设置API
+func (s *AdminServer) registerAccountRoutes(protected *gin.RouterGroup) {
+	protected.POST("/auth/logout", s.logout)          // 用户注销
+	protected.GET("/auth/profile", s.getProfile)      // 获取用户信息
+	protected.GET("/auth/sessions", s.getOwnSessions) // 获取自己的登录会话
+
+	// 用户个人相关API（所有用户都可以访问）
+	user := protected.Group("/user")
+	{
+		user.PUT("/password", s.changePassword) // 修改自己的密码
+	}
+
+	// 访问token设置API（需要管理员权限）
+	tokenSettings := protected.Group("/auth/token-settings")
+	tokenSettings.Use(s.requireCapability(db.CapSystemWrite))
+	{
+		tokenSettings.GET("", s.getTokenSettings)    // 获取token有效期
+		tokenSettings.PUT("", s.updateTokenSettings) // 修改token有效期
+	}
+}
+
+// registerModelRoutes 注册模型API，所有角色可查看，修改需要models:write权限；其他租户的模型视为不存在
+func (s *AdminServer) registerModelRoutes(protected *gin.RouterGroup) {
+	models := protected.Group("/models")
+	models.Use(s.requireModelInScope())
+	{
+		models.GET("", s.getModels)                    // 获取模型列表
+		models.GET("/schema", s.getModelSchema)        // 获取模型配置的JSON Schema
+		models.GET("/trash", s.getTrashedModels)       // 获取回收站中的模型
+		models.GET("/:id", s.getModel)                 // 根据模型ID获取模型信息
+		models.GET("/:id/errors", s.getModelErrors)    // 获取模型最近的错误统计
+		models.GET("/:id/load", s.getModelLoad)        // 获取模型当前的并发负载
+		models.GET("/:id/experiment", s.getExperiment) // 获取模型的Prompt实验和各变体的统计
+		models.GET("/:id/canary", s.getCanary)         // 获取模型的灰度发布配置和各分组的统计
+	}
+	modelWrites := protected.Group("/models")
+	modelWrites.Use(s.requireCapability(db.CapModelsWrite), s.requireModelInScope())
+	{
+		modelWrites.PUT("/:id", s.updateModel)                        // 根据模型ID配置模型信息
+		modelWrites.PUT("/:id/upsert", s.upsertModel)                 // 模型不存在时创建，存在时整体替换
+		modelWrites.POST("", s.createModel)                           // 创建模型配置
+		modelWrites.DELETE("/:id", s.deleteModel)                     // 删除模型配置（移入回收站）
+		modelWrites.POST("/:id/restore", s.restoreModel)              // 从回收站恢复模型配置
+		modelWrites.DELETE("/:id/purge", s.purgeModel)                // 永久删除回收站中的模型配置
+		modelWrites.POST("/:id/check", s.checkModel)                  // 立即检查模型上游的连通性
+		modelWrites.PUT("/:id/experiment", s.updateExperiment)        // 设置Prompt实验的变体，空列表结束实验
+		modelWrites.POST("/:id/experiment/promote", s.promoteVariant) // 将变体设为基础Prompt并结束实验
+	}
+	models.POST("/seed", s.requireCapability(db.CapSystemWrite), s.seedModels) // 导入默认模型目录中尚不存在的模型（需要管理员权限）
+}
+
+// registerSystemRoutes 注册配置、维护模式和日志API
+func (s *AdminServer) registerSystemRoutes(protected *gin.RouterGroup) {
+	config := protected.Group("/config")
+	{
+		config.GET("/status", s.getStatus)     // 获取服务状态
+		config.GET("/drift", s.getConfigDrift) // 比较YAML文件与数据库中的模型配置，租户管理员只能看到所属租户的模型
+
+		config.POST("/reload", s.requireCapability(db.CapModelsWrite), requireGlobal(), s.reloadConfig) // 重新加载配置（需要models:write权限，影响所有租户，只有全局用户可以执行）
+		config.POST("/backup", s.requireCapability(db.CapSystemWrite), s.backupModels)                  // 备份模型配置（需要管理员权限）
+		config.POST("/restore", s.requireCapability(db.CapSystemWrite), s.restoreModels)                // 从备份恢复模型配置（需要管理员权限）
+	}
+
+	// 全局维护模式API（设置需要管理员权限）
+	maintenance := protected.Group("/maintenance")
+	{
+		maintenance.GET("", s.getMaintenance)                                            // 获取全局维护模式
+		maintenance.PUT("", s.requireCapability(db.CapSystemWrite), s.updateMaintenance) // 设置全局维护模式
+	}
+
+	// 访问日志API（需要logs:read权限，superuser和operator拥有）
+	logs := protected.Group("/logs")
+	logs.Use(s.requireCapability(db.CapLogsRead))
+	{
+		logs.GET("/export", s.exportLogs)          // 按时间范围导出访问日志(jsonl/csv)
+		logs.GET("/recent", s.getRecentLogs)       // 内存日志中最近的记录
+		logs.GET("/:name/search", s.searchLogs)    // 在日志文件中搜索子串或字段值
+		logs.GET("/:name/verify", s.verifyLogFile) // 校验日志文件的哈希链
+	}
+
+	// 日志记录器状态API（需要logs:read权限）
+	loggers := protected.Group("/loggers")
+	loggers.Use(s.requireCapability(db.CapLogsRead))
+	{
+		loggers.GET("/status", s.getLoggerStatus) // 各日志记录器的健康状态、丢弃数和最近的写入错误
+		loggers.GET("/files", s.getLogFiles)      // 所有文件日志记录器的日志文件列表
+	}
+}
+
+// registerUserRoutes 注册用户管理API，需要管理员权限；租户管理员只能管理所属租户的用户
+func (s *AdminServer) registerUserRoutes(protected *gin.RouterGroup) {
+	users := protected.Group("/users")
+	users.Use(s.requireCapability(db.CapUsersWrite), s.requireUserInScope())
+	{
+		users.GET("", s.getUsers)                         // 获取用户列表
+		users.POST("", s.createUser)                      // 创建用户
+		users.PUT("/:id", s.updateUser)                   // 更新用户信息
+		users.DELETE("/:id", s.deleteUser)                // 删除用户
+		users.PUT("/:id/status", s.updateUserStatus)      // 更新用户状态
+		users.PUT("/:id/password", s.adminChangePassword) // 管理员修改用户密码
+		users.POST("/:id/unlock", s.unlockUser)           // 解除连续登录失败导致的账户锁定
+		users.POST("/:id/api-keys", s.createUserAPIKey)   // 为指定用户创建API Key
+	}
+}
+
+// registerAPIKeyRoutes 注册API Key管理API，所有用户都可以查看自己的API Key，viewer不能修改
+func (s *AdminServer) registerAPIKeyRoutes(protected *gin.RouterGroup) {
+	// 全部用户的API Key（需要管理员权限）
+	adminAPIKeys := protected.Group("/admin/api-keys")
+	adminAPIKeys.Use(s.requireCapability(db.CapUsersWrite))
+	{
+		adminAPIKeys.GET("", s.getAllAPIKeys) // 获取所有用户的API Key列表
+	}
+
+	apiKeys := protected.Group("/api-keys")
+	{
+		apiKeys.GET("", s.getAPIKeys)                  // 获取当前用户的API Key列表
+		apiKeys.GET("/expiring", s.getExpiringAPIKeys) // 获取当前用户即将过期的API Key
+	}
+	apiKeyWrites := protected.Group("/api-keys")
+	apiKeyWrites.Use(s.requireCapability(db.CapKeysWrite))
+	{
+		apiKeyWrites.POST("", s.createAPIKey)            // 创建API Key
+		apiKeyWrites.PUT("/:id", s.updateAPIKey)         // 更新API Key
+		apiKeyWrites.DELETE("/:id", s.deleteAPIKey)      // 删除API Key
+		apiKeyWrites.POST("/:id/rotate", s.rotateAPIKey) // 重新生成API Key的值
+
+		apiKeyWrites.POST("/:id/simulate", s.requireCapability(db.CapUsersWrite), s.simulateAPIKey) // 模拟API Key的代理授权检查（需要管理员权限）
+		apiKeyWrites.POST("/:id/transfer", s.requireCapability(db.CapUsersWrite), s.transferAPIKey) // 将API Key转移给其他用户（需要管理员权限）
+	}
+}
+
+// registerSessionRoutes 注册登录会话API，查看所有会话需要管理员权限，撤销时普通用户只能撤销自己的会话
+func (s *AdminServer) registerSessionRoutes(protected *gin.RouterGroup) {
+	sessions := protected.Group("/sessions")
+	{
+		sessions.GET("", s.requireCapability(db.CapUsersWrite), s.getSessions) // 获取所有用户的登录会话
+		sessions.DELETE("/:jti", s.revokeSession)                              // 撤销登录会话，对应的token立即失效
+	}
+}
+
+// registerWebhookRoutes 注册告警Webhook管理API，需要管理员权限
+func (s *AdminServer) registerWebhookRoutes(protected *gin.RouterGroup) {
+	webhooks := protected.Group("/webhooks")
+	webhooks.Use(s.requireCapability(db.CapSystemWrite))
+	{
+		webhooks.GET("", s.getWebhooks)           // 获取Webhook列表
+		webhooks.POST("", s.createWebhook)        // 创建Webhook
+		webhooks.PUT("/:id", s.updateWebhook)     // 更新Webhook
+		webhooks.DELETE("/:id", s.deleteWebhook)  // 删除Webhook
+		webhooks.POST("/:id/test", s.testWebhook) // 发送一条测试告警
+	}
+}
+
+// registerTenantRoutes 注册租户API，需要管理员权限；租户管理员只能查看所属的租户，创建租户需要全局管理员
+func (s *AdminServer) registerTenantRoutes(protected *gin.RouterGroup) {
+	tenants := protected.Group("/tenants")
+	tenants.Use(s.requireCapability(db.CapUsersWrite))
+	{
+		tenants.GET("", s.getTenants)    // 获取租户列表
+		tenants.POST("", s.createTenant) // 创建租户
+	}
+}
@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// routeStep 按顺序执行的一次管理API调用，check为响应JSON中需要等于want的路径
+type routeStep struct {
+	name   string
+	method string
+	path   string
+	body   string
+	status int
+	check  string
+	want   string
+}
+
+// runRouteSteps 依次执行调用并检查状态码和响应内容
+func runRouteSteps(t *testing.T, handler http.Handler, token string, steps []routeStep) {
+	t.Helper()
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != step.status {
+			t.Errorf("%s: %s %s 期望状态码%d，实际%d %s", step.name, step.method, step.path, step.status, w.Code, w.Body.String())
+			continue
+		}
+		if step.check != "" {
+			if got := gjson.GetBytes(w.Body.Bytes(), step.check).String(); got != step.want {
+				t.Errorf("%s: %s = %q, want %q", step.name, step.check, got, step.want)
+			}
+		}
+	}
+}
+
+func TestRouterHandlers(t *testing.T) {
+	dir := t.TempDir()
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	// 备份和重新加载使用临时目录，不写入工作目录
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.ConfigDir = dir
+	router, err := NewRouter(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建路由器失败: %v", err)
+	}
+
+	runRouteSteps(t, router, "", []routeStep{
+		{"首次安装", http.MethodGet, "/api/v1/auth/check-install", "", http.StatusOK, "data.is_first_install", "true"},
+		{"版本信息", http.MethodGet, "/api/v1/version", "", http.StatusOK, "data.version", "dev"},
+		{"未登录", http.MethodGet, "/api/v1/models", "", http.StatusUnauthorized, "error_code", "missing_token"},
+		{"注册", http.MethodPost, "/api/v1/auth/register", `{"username":"admin","password":"password"}`, http.StatusOK, "data.user.role", "superuser"},
+		{"登录失败", http.MethodPost, "/api/v1/auth/login", `{"username":"admin","password":"wrong"}`, http.StatusUnauthorized, "error_code", "invalid_credentials"},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username":"admin","password":"password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	token := gjson.GetBytes(w.Body.Bytes(), "data.token").String()
+	if token == "" {
+		t.Fatalf("登录失败: %d %s", w.Code, w.Body.String())
+	}
+
+	model := `{"id":"chat","name":"聊天","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions"}`
+	runRouteSteps(t, router, token, []routeStep{
+		// 模型
+		{"创建模型", http.MethodPost, "/api/v1/models", model, http.StatusCreated, "data.id", "chat"},
+		{"重复创建模型", http.MethodPost, "/api/v1/models", model, http.StatusConflict, "error_code", "model_exists"},
+		{"无效的模型", http.MethodPost, "/api/v1/models", `{"id":"bad","name":"x","target":"gpt-4o"}`, http.StatusBadRequest, "", ""},
+		{"模型列表", http.MethodGet, "/api/v1/models", "", http.StatusOK, "data.total", "1"},
+		{"模型列表默认每页数量", http.MethodGet, "/api/v1/models", "", http.StatusOK, "data.page_size", "20"},
+		{"模型列表每页数量上限", http.MethodGet, "/api/v1/models?page_size=1000", "", http.StatusOK, "data.page_size", "100"},
+		{"模型列表排序", http.MethodGet, "/api/v1/models?sort=name:desc", "", http.StatusOK, "data.models.0.id", "chat"},
+		{"无效的排序方向", http.MethodGet, "/api/v1/models?sort=name:down", "", http.StatusBadRequest, "error_code", "invalid_request"},
+		{"无效的排序字段", http.MethodGet, "/api/v1/models?sort=id", "", http.StatusBadRequest, "error_code", "invalid_request"},
+		{"获取模型", http.MethodGet, "/api/v1/models/chat", "", http.StatusOK, "data.target", "gpt-4o"},
+		{"获取不存在的模型", http.MethodGet, "/api/v1/models/missing", "", http.StatusNotFound, "error_code", "model_not_found"},
+		{"更新模型", http.MethodPut, "/api/v1/models/chat", `{"target":"gpt-4.1"}`, http.StatusOK, "data.target", "gpt-4.1"},
+		{"模型Schema", http.MethodGet, "/api/v1/models/schema", "", http.StatusOK, "data.type", "object"},
+		{"删除模型", http.MethodDelete, "/api/v1/models/chat", "", http.StatusOK, "", ""},
+		{"删除不存在的模型", http.MethodDelete, "/api/v1/models/chat", "", http.StatusNotFound, "", ""},
+
+		// 用户
+		{"创建用户", http.MethodPost, "/api/v1/users", `{"username":"alice","role":"viewer"}`, http.StatusOK, "data.user.role", "viewer"},
+		{"重复创建用户", http.MethodPost, "/api/v1/users", `{"username":"alice"}`, http.StatusBadRequest, "error_code", "user_exists"},
+		{"用户列表", http.MethodGet, "/api/v1/users", "", http.StatusOK, "data.users.0.username", "alice"},
+		{"用户列表默认第1页", http.MethodGet, "/api/v1/users", "", http.StatusOK, "data.page", "1"},
+		{"用户列表默认每页数量", http.MethodGet, "/api/v1/users", "", http.StatusOK, "data.page_size", "20"},
+		{"用户列表包含管理员", http.MethodGet, "/api/v1/users?page=2&page_size=1", "", http.StatusOK, "data.users.0.username", "admin"},
+		{"用户列表总数", http.MethodGet, "/api/v1/users?page=2&page_size=1", "", http.StatusOK, "data.total", "2"},
+		{"用户列表不含管理员", http.MethodGet, "/api/v1/users?include_admins=false", "", http.StatusOK, "data.total", "1"},
+		{"搜索用户", http.MethodGet, "/api/v1/users?q=LIC", "", http.StatusOK, "data.users.0.username", "alice"},
+		{"搜索用户总数", http.MethodGet, "/api/v1/users?q=ADM&page=1", "", http.StatusOK, "data.total", "1"},
+		{"搜索通配符按字面匹配", http.MethodGet, "/api/v1/users?q=%25", "", http.StatusOK, "data.total", "0"},
+		{"无效的分页参数", http.MethodGet, "/api/v1/users?page=0", "", http.StatusBadRequest, "error_code", "invalid_request"},
+		{"更新用户", http.MethodPut, "/api/v1/users/2", `{"role":"operator"}`, http.StatusOK, "", ""},
+		{"禁用用户", http.MethodPut, "/api/v1/users/2/status", `{"is_enabled":false}`, http.StatusOK, "", ""},
+		{"无效的用户ID", http.MethodPut, "/api/v1/users/abc", `{}`, http.StatusBadRequest, "error_code", "invalid_user_id"},
+
+		// API Key
+		{"创建API Key", http.MethodPost, "/api/v1/api-keys", `{"name":"k1"}`, http.StatusOK, "data.name", "k1"},
+		{"API Key列表", http.MethodGet, "/api/v1/api-keys", "", http.StatusOK, "data.api_keys.0.name", "k1"},
+		{"更新API Key", http.MethodPut, "/api/v1/api-keys/1", `{"name":"k2"}`, http.StatusOK, "data.name", "k2"},
+		{"为用户创建API Key", http.MethodPost, "/api/v1/users/2/api-keys", `{"name":"alice"}`, http.StatusOK, "data.user_id", "2"},
+		{"管理员为其他用户的Key开启覆盖Prompt", http.MethodPut, "/api/v1/api-keys/2", `{"allow_prompt_override":true}`, http.StatusOK, "data.allow_prompt_override", "true"},
+		{"全部API Key", http.MethodGet, "/api/v1/admin/api-keys", "", http.StatusOK, "data.total", "2"},
+		{"删除有API Key的用户", http.MethodDelete, "/api/v1/users/2", "", http.StatusConflict, "error_code", "user_has_api_keys"},
+		{"级联删除用户", http.MethodDelete, "/api/v1/users/2?cascade=true", "", http.StatusOK, "", ""},
+		{"删除API Key", http.MethodDelete, "/api/v1/api-keys/1", "", http.StatusOK, "", ""},
+		{"删除不存在的API Key", http.MethodDelete, "/api/v1/api-keys/1", "", http.StatusBadRequest, "", ""},
+		{"未知API", http.MethodGet, "/api/v1/unknown", "", http.StatusNotFound, "error_code", "api_not_found"},
+	})
+
+	// 每个注册的路由都应到达处理函数：路径参数使用不存在的ID，退出登录会撤销token，放在最后执行
+	const logoutPath = "/api/v1/auth/logout"
+	routes := router.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path != logoutPath && routes[j].Path == logoutPath
+	})
+	for _, route := range routes {
+		path := routeParamPattern.ReplaceAllString(route.Path, "999")
+		req := httptest.NewRequest(route.Method, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code == http.StatusMethodNotAllowed || w.Code >= http.StatusInternalServerError ||
+			gjson.GetBytes(w.Body.Bytes(), "error_code").String() == "api_not_found" {
+			t.Errorf("%s %s 未正确处理: %d %s", route.Method, route.Path, w.Code, w.Body.String())
+		}
+	}
+}
+
+// routeParamPattern 匹配路由中的路径参数和通配参数
+var routeParamPattern = regexp.MustCompile(`[:*][^/]+`)
+
+// stubAuth 只实现token验证的认证服务，其余方法未实现，调用时panic
+type stubAuth struct {
+	AuthProvider
+	claims map[string]*service.Claims
+}
+
+func (a *stubAuth) ValidateToken(token string) (*service.Claims, error) {
+	if claims, ok := a.claims[token]; ok {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestRouterWithStubAuth(t *testing.T) {
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {ID: "chat", Name: "聊天", Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions"},
+	}}
+	s := NewAdminServer(cfg, t.TempDir())
+	s.SetAuthProvider(&stubAuth{claims: map[string]*service.Claims{
+		"viewer-token": {UserID: 1, Username: "viewer", Role: db.RoleViewer},
+	}})
+	router := s.Router()
+
+	runRouteSteps(t, router, "viewer-token", []routeStep{
+		{"查看模型", http.MethodGet, "/api/v1/models/chat", "", http.StatusOK, "data.id", "chat"},
+		{"修改模型", http.MethodPut, "/api/v1/models/chat", `{}`, http.StatusForbidden, "error_code", "role_not_allowed"},
+		{"用户管理", http.MethodGet, "/api/v1/users", "", http.StatusForbidden, "error_code", "admin_required"},
+	})
+	runRouteSteps(t, router, "bad-token", []routeStep{
+		{"无效token", http.MethodGet, "/api/v1/models", "", http.StatusUnauthorized, "error_code", "invalid_token"},
+	})
+}
+
+func TestModelLoadByAlias(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	// 代理的限制器只按模型ID记录负载
+	adminServer.SetModelLoad(func(modelID string) stats.ModelLoad {
+		if modelID == "assistant" {
+			return stats.ModelLoad{InFlight: 3}
+		}
+		return stats.ModelLoad{}
+	})
+
+	runRouteSteps(t, adminServer.Router(), login.Token, []routeStep{
+		{"创建模型", http.MethodPost, "/api/v1/models", `{"id":"assistant","name":"Assistant","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions","aliases":["helper"],"max_concurrency":5}`, http.StatusCreated, "", ""},
+		{"按ID查询负载", http.MethodGet, "/api/v1/models/assistant/load", "", http.StatusOK, "data.in_flight", "3"},
+		{"按别名查询负载", http.MethodGet, "/api/v1/models/helper/load", "", http.StatusOK, "data.in_flight", "3"},
+		{"上限来自配置", http.MethodGet, "/api/v1/models/helper/load", "", http.StatusOK, "data.limit", "5"},
+	})
+}
@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+// seedModels 导入模型目录中尚不存在的模型。请求体为空时导入内置的默认模型目录，
+// 否则请求体为与模型配置文件格式相同的YAML目录；已存在的模型ID被跳过，重复调用不会创建新模型
+func (s *AdminServer) seedModels(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeConfigUnavailable)
+		return
+	}
+	catalog, err := c.GetRawData()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if len(bytes.TrimSpace(catalog)) == 0 {
+		catalog = service.DefaultCatalog()
+	}
+
+	result, err := s.configService.SeedModels(catalog)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCatalog) {
+			respondServiceError(c, http.StatusBadRequest, err)
+		} else {
+			respondError(c, http.StatusInternalServerError, i18n.CodeSeedModelsFailed, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "模型目录导入完成",
+		"data":    api.SeedResult(*result),
+	})
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestSeedModelsRoute(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService := adminServer.authService.(*service.AuthService)
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	catalog, err := config.ParseConfig(service.DefaultCatalog())
+	if err != nil {
+		t.Fatalf("内置模型目录无效: %v", err)
+	}
+	total := strconv.Itoa(len(catalog.Models))
+
+	custom := "models:\n  - id: \"gpt-4o\"\n    name: \"覆盖\"\n    target: \"x\"\n    url: \"https://example.com/v1\"\n  - id: \"custom\"\n    name: \"自定义\"\n    target: \"custom\"\n    url: \"https://example.com/v1\"\n"
+	runRouteSteps(t, adminServer.Router(), admin.Token, []routeStep{
+		{"安装后没有模型", http.MethodGet, "/api/v1/auth/check-install", "", http.StatusOK, "data.model_count", "0"},
+		{"导入默认目录", http.MethodPost, "/api/v1/models/seed", "", http.StatusOK, "data.created.#", total},
+		{"导入后的模型可查询", http.MethodGet, "/api/v1/models/gpt-4o", "", http.StatusOK, "data.source", "api"},
+		{"重复导入不创建模型", http.MethodPost, "/api/v1/models/seed", "", http.StatusOK, "data.created.#", "0"},
+		{"重复导入全部跳过", http.MethodPost, "/api/v1/models/seed", "", http.StatusOK, "data.skipped.#", total},
+		{"自定义目录只创建新模型", http.MethodPost, "/api/v1/models/seed", custom, http.StatusOK, "data.created.0", "custom"},
+		{"已存在的模型不被覆盖", http.MethodGet, "/api/v1/models/gpt-4o", "", http.StatusOK, "data.name", "GPT-4o"},
+		{"无效目录", http.MethodPost, "/api/v1/models/seed", "models: [", http.StatusBadRequest, "error_code", "catalog_invalid"},
+	})
+}
+
+func TestSeedModelsConcurrentLookup(t *testing.T) {
+	dir := t.TempDir()
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.ConfigDir = dir
+	adminServer, err := NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService := adminServer.authService.(*service.AuthService)
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+
+	// 导入期间代理持续查找模型，配置通过加锁的方法原地替换，-race下不应报告数据竞争
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				adminServer.config.GetModel("gpt-4o")
+			}
+		}
+	}()
+	runRouteSteps(t, adminServer.Router(), admin.Token, []routeStep{
+		{"导入默认目录", http.MethodPost, "/api/v1/models/seed", "", http.StatusOK, "code", "0"},
+		{"重新加载配置", http.MethodPost, "/api/v1/config/reload", "", http.StatusOK, "code", "0"},
+	})
+	close(done)
+	<-stopped
+
+	if _, ok := adminServer.config.GetModel("gpt-4o"); !ok {
+		t.Error("导入的模型应在管理服务器持有的配置中可见")
+	}
+}
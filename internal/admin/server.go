@@ -1,19 +1,32 @@
 package admin
 
 import (
-	"crypto/rand"
+	"context"
 	"embed"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+	"github.com/eolinker/ai-prompt-proxy/internal/recovery"
 	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+	"github.com/eolinker/ai-prompt-proxy/internal/version"
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
 )
 
 //go:embed web/*
@@ -24,213 +37,352 @@ type AdminServer struct {
 	config        *config.Config
 	configDir     string
 	configService *service.ConfigService
-	authService   *service.AuthService
+	authService   AuthProvider
 	proxyPort     string // 代理服务端口
 	adminPort     string // 管理服务端口
+	serverConfig  *config.ServerConfig
+	errorTracker  *stats.ErrorTracker                  // 代理服务的按模型错误统计
+	experiments   *stats.ExperimentTracker             // 代理服务的Prompt实验统计
+	canaries      *stats.ExperimentTracker             // 代理服务的灰度发布统计
+	authorizer    *service.Authorizer                  // 与代理服务共用的授权检查器
+	modelLoad     func(modelID string) stats.ModelLoad // 读取代理服务中模型的实时负载
+	health        *healthcheck.Monitor                 // 与代理服务共用的上游健康检查器
+	webAssets     fs.FS                                // 管理页面静态文件，未找到时为nil
+	webhooks      *service.WebhookService              // 告警Webhook管理，未使用数据库时为nil
+	webhookClient *http.Client                         // 发送测试告警的客户端
 }
 
 // NewAdminServer 创建新的管理API服务器
 func NewAdminServer(cfg *config.Config, configDir string) *AdminServer {
 	return &AdminServer{
-		config:    cfg,
-		configDir: configDir,
+		config:     cfg,
+		configDir:  configDir,
+		authorizer: service.NewAuthorizer(cfg, nil),
 	}
 }
 
-// NewAdminServerWithService 使用配置服务创建新的管理API服务器
-func NewAdminServerWithService(configService *service.ConfigService, configDir string, proxyPort, adminPort string) (*AdminServer, error) {
+// NewAdminServerWithService 使用配置服务和服务器配置创建新的管理API服务器
+func NewAdminServerWithService(configService *service.ConfigService, serverConfig *config.ServerConfig) (*AdminServer, error) {
 	// 创建认证服务
 	authService, err := service.NewAuthService(configService.GetDBManager())
 	if err != nil {
 		return nil, fmt.Errorf("创建认证服务失败: %w", err)
 	}
 
+	authService.SetLockoutPolicy(serverConfig.Login.MaxFailedAttempts, serverConfig.Login.LockoutDuration)
+
 	return &AdminServer{
 		config:        configService.GetConfig(),
-		configDir:     configDir,
+		configDir:     serverConfig.ConfigDir,
 		configService: configService,
 		authService:   authService,
-		proxyPort:     proxyPort,
-		adminPort:     adminPort,
+		proxyPort:     serverConfig.Proxy.Port,
+		adminPort:     serverConfig.Admin.Port,
+		serverConfig:  serverConfig,
+		authorizer:    service.NewAuthorizer(configService.GetConfig(), configService),
+		webhooks:      service.NewWebhookService(configService.GetDBManager()),
+		webhookClient: &http.Client{Timeout: serverConfig.Alerts.Timeout},
 	}, nil
 }
 
 // Start 启动管理API服务器
 func (s *AdminServer) Start(port string) error {
-	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-
-	// 添加中间件
-	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-	r.Use(s.corsMiddleware())
-
-	// 健康检查 - 放在最前面避免路由冲突
-	r.GET("/health", s.healthCheck)
-
-	// 设置嵌入式静态文件服务
-	s.setupEmbeddedStaticFiles(r)
-
-	// 根路径处理 - 放在最后避免覆盖其他路由
-	r.NoRoute(func(c *gin.Context) {
-		// 如果是API请求，返回404
-		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    404,
-				"message": "API接口不存在",
-			})
-			return
+	r := s.Router()
+	if s.serverConfig != nil && s.serverConfig.Admin.TLS.Enabled() {
+		return r.RunTLS(fmt.Sprintf(":%s", port), s.serverConfig.Admin.TLS.CertFile, s.serverConfig.Admin.TLS.KeyFile)
+	}
+	return r.Run(fmt.Sprintf(":%s", port))
+}
+
+// corsMiddleware CORS中间件
+func (s *AdminServer) corsMiddleware() gin.HandlerFunc {
+	cors := config.DefaultServerConfig().AdminCORS()
+	if s.serverConfig != nil {
+		cors = s.serverConfig.AdminCORS()
+	}
+	allowMethods := strings.Join(cors.AllowMethods, ", ")
+	allowHeaders := strings.Join(cors.AllowHeaders, ", ")
+
+	return func(c *gin.Context) {
+		if origin := cors.AllowedOrigin(c.GetHeader("Origin")); origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
 		}
-		// 其他请求返回前端页面
-		s.serveIndexHTML(c)
-	})
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
 
-	// API路由组
-	api := r.Group("/api/v1")
-	{
-		// 认证相关API（无需认证）
-		auth := api.Group("/auth")
-		{
-			auth.GET("/check-install", s.checkInstall)            // 检查是否首次安装
-			auth.GET("/public-key", s.getPublicKey)               // 获取公钥
-			auth.POST("/register", s.register)                    // 用户注册（仅首次安装）
-			auth.POST("/encrypted-register", s.encryptedRegister) // 加密用户注册
-			auth.POST("/login", s.login)                          // 用户登录
-			auth.POST("/encrypted-login", s.encryptedLogin)       // 加密用户登录
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
 		}
 
-		// 公开配置API（无需认证）
-		publicConfig := api.Group("/config")
-		{
-			publicConfig.GET("/system", s.getSystemConfig) // 获取系统配置
+		c.Next()
+	}
+}
+
+// newModelResponse 构建模型响应，dbModel为nil时不包含时间信息
+func newModelResponse(model *config.ModelConfig, dbModel *db.ModelConfigDB) api.ModelResponse {
+	response := api.ModelResponse{
+		ID:                  model.ID,
+		Name:                model.Name,
+		Target:              model.Target,
+		Prompt:              model.Prompt,
+		Url:                 model.Url,
+		Type:                api.ModelType(model.Type),
+		PromptPath:          model.PromptPath,
+		PromptValue:         model.PromptValue,
+		PromptValueType:     api.ValueType(model.PromptValueType),
+		ModelIDSource:       api.ModelIDSource(model.ModelIDSource),
+		ModelIDKey:          model.ModelIDKey,
+		Disabled:            model.Disabled,
+		CacheTTL:            model.CacheTTL,
+		MaxConcurrency:      model.MaxConcurrency,
+		QueueOnLimit:        model.QueueOnLimit,
+		QueueTimeout:        model.QueueTimeout,
+		Maintenance:         model.Maintenance,
+		MaintenanceMessage:  model.MaintenanceMessage,
+		MaintenanceStatus:   model.MaintenanceStatus,
+		Aliases:             model.Aliases,
+		SigningEnabled:      model.SigningSecret != "",
+		Tools:               model.Tools,
+		ToolsMode:           api.ToolsMode(model.ToolsMode),
+		MaxTimeoutMs:        model.MaxTimeoutMs,
+		RequestHeaders:      model.RequestHeaders,
+		ResponseHeaders:     model.ResponseHeaders,
+		QueueTimeoutMs:      model.QueueTimeoutMs,
+		Source:              api.ModelSource(model.Source),
+		Pipeline:            model.Pipeline,
+		StreamMode:          api.StreamMode(model.StreamMode),
+		Targets:             apiTargets(model.Targets),
+		MinifyBody:          model.MinifyBody,
+		CompressUpstream:    model.CompressUpstream,
+		PromptVariants:      apiPromptVariants(model.PromptVariants),
+		HealthCheckInterval: model.HealthCheckInterval,
+		HealthCheckMethod:   api.HealthCheckMethod(model.HealthCheckMethod),
+		PromptPosition:      api.PromptPosition(model.PromptPosition),
+		DedupePrompt:        model.DedupePrompt,
+		CanaryTarget:        model.CanaryTarget,
+		CanaryUrl:           model.CanaryUrl,
+		CanaryPercent:       model.CanaryPercent,
+		HedgeAfterMs:        model.HedgeAfterMs,
+		MaxHedges:           model.MaxHedges,
+		Tenant:              model.Tenant,
+	}
+	if response.Aliases == nil {
+		response.Aliases = []string{}
+	}
+	if response.Tools == nil {
+		response.Tools = []interface{}{}
+	}
+	if response.Pipeline == nil {
+		response.Pipeline = []string{}
+	}
+	if response.Targets == nil {
+		response.Targets = []api.WeightedTarget{}
+	}
+	if response.PromptVariants == nil {
+		response.PromptVariants = []api.PromptVariant{}
+	}
+	if response.RequestHeaders == nil {
+		response.RequestHeaders = map[string]string{}
+	}
+	if response.ResponseHeaders == nil {
+		response.ResponseHeaders = map[string]string{}
+	}
+	if dbModel != nil {
+		response.CreatedAt = dbModel.CreatedAt.Format("2006-01-02T15:04:05Z07:00")
+		response.UpdatedAt = dbModel.UpdatedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	return response
+}
+
+// savedModelResponse 构建保存后的模型响应，使用配置服务时从数据库读取时间信息
+func (s *AdminServer) savedModelResponse(model *config.ModelConfig) api.ModelResponse {
+	if s.configService != nil {
+		if dbModel, err := s.configService.GetModelWithTime(model.ID); err == nil {
+			return newModelResponse(model, dbModel)
 		}
+	}
+	// 无配置服务或读取失败时不包含时间信息
+	return newModelResponse(model, nil)
+}
 
-		// 需要认证的API
-		protected := api.Group("")
-		protected.Use(s.authMiddleware())
-		{
-			// 认证相关API
-			protected.POST("/auth/logout", s.logout)     // 用户注销
-			protected.GET("/auth/profile", s.getProfile) // 获取用户信息
-
-			// 模型相关API
-			models := protected.Group("/models")
-			{
-				models.GET("", s.getModels)          // 获取模型列表
-				models.GET("/:id", s.getModel)       // 根据模型ID获取模型信息
-				models.PUT("/:id", s.updateModel)    // 根据模型ID配置模型信息
-				models.POST("", s.createModel)       // 创建模型配置
-				models.DELETE("/:id", s.deleteModel) // 删除模型配置
+// fillModelUsage 为模型响应填充调用统计，未使用配置服务时没有统计
+func (s *AdminServer) fillModelUsage(models []api.ModelResponse) error {
+	if s.configService == nil || len(models) == 0 {
+		return nil
+	}
+	modelIDs := make([]string, len(models))
+	for i := range models {
+		modelIDs[i] = models[i].ID
+	}
+	usage, err := s.configService.GetModelUsage(modelIDs)
+	if err != nil {
+		return err
+	}
+	for i := range models {
+		if record, ok := usage[models[i].ID]; ok {
+			models[i].TotalRequestCount = record.TotalRequestCount
+			if record.LastUsedAt != nil {
+				models[i].LastUsedAt = record.LastUsedAt.Format("2006-01-02T15:04:05Z07:00")
 			}
+		}
+	}
+	return nil
+}
 
-			// 配置相关API
-			config := protected.Group("/config")
-			{
-				config.POST("/reload", s.reloadConfig) // 重新加载配置
-				config.GET("/status", s.getStatus)     // 获取服务状态
-			}
+// recentlyUsedWindow 删除在该时长内被调用过的模型时给出警告
+const recentlyUsedWindow = 7 * 24 * time.Hour
 
-			// 用户管理API（需要管理员权限）
-			users := protected.Group("/users")
-			users.Use(s.adminMiddleware()) // 添加管理员权限检查
-			{
-				users.GET("", s.getUsers)                         // 获取用户列表
-				users.POST("", s.createUser)                      // 创建用户
-				users.PUT("/:id", s.updateUser)                   // 更新用户信息
-				users.DELETE("/:id", s.deleteUser)                // 删除用户
-				users.PUT("/:id/status", s.updateUserStatus)      // 更新用户状态
-				users.PUT("/:id/password", s.adminChangePassword) // 管理员修改用户密码
-			}
+// maxModelPageSize 模型列表每页最大数量
+const maxModelPageSize = 100
 
-			// 用户个人相关API（所有用户都可以访问）
-			user := protected.Group("/user")
-			{
-				user.PUT("/password", s.changePassword) // 修改自己的密码
-			}
+// defaultModelPageSize 模型列表默认每页数量
+const defaultModelPageSize = 20
 
-			// API Key管理API（所有用户都可以访问自己的API Key）
-			apiKeys := protected.Group("/api-keys")
-			{
-				apiKeys.GET("", s.getAPIKeys)          // 获取当前用户的API Key列表
-				apiKeys.POST("", s.createAPIKey)       // 创建API Key
-				apiKeys.DELETE("/:id", s.deleteAPIKey) // 删除API Key
-			}
+// 用户列表的默认和最大每页数量
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// parseModelQuery 解析模型列表的分页、过滤和排序参数，
+// sort的格式为字段或字段:方向，如name、name:desc，未指定方向时按正序
+func parseModelQuery(c *gin.Context) (db.ModelQuery, error) {
+	q := db.ModelQuery{
+		Type:   c.Query("type"),
+		Search: c.Query("search"),
+	}
+
+	sort := c.Query("sort")
+	if sort == "" {
+		// 默认按更新时间倒序
+		sort = "updated_at:desc"
+	}
+	field, direction, _ := strings.Cut(sort, ":")
+	switch field {
+	case "created_at", "updated_at", "name":
+		q.SortBy = field
+	default:
+		return q, fmt.Errorf("无效的排序字段: %s", field)
+	}
+	switch strings.ToLower(direction) {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("无效的排序方向: %s", direction)
+	}
+
+	// unused_since=720h 只返回最近720小时内没有被调用过的模型，用于找出可以清理的模型
+	if unusedSince := c.Query("unused_since"); unusedSince != "" {
+		duration, err := time.ParseDuration(unusedSince)
+		if err != nil || duration <= 0 {
+			return q, fmt.Errorf("无效的未使用时长: %s", unusedSince)
 		}
+		q.UnusedSince = time.Now().Add(-duration)
 	}
 
-	return r.Run(fmt.Sprintf(":%s", port))
+	var err error
+	q.Page, q.PageSize, err = parsePagination(c, defaultModelPageSize, maxModelPageSize)
+	return q, err
 }
 
-// corsMiddleware CORS中间件
-func (s *AdminServer) corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// parsePagination 解析page和page_size参数，未指定时使用第1页和defaultSize，
+// 每页数量超过maxSize时按maxSize
+func parsePagination(c *gin.Context, defaultSize, maxSize int) (int, int, error) {
+	pageStr, pageSizeStr := c.Query("page"), c.Query("page_size")
+	page, pageSize := 1, defaultSize
+	if pageStr != "" {
+		value, err := strconv.Atoi(pageStr)
+		if err != nil || value < 1 {
+			return 0, 0, fmt.Errorf("无效的页码: %s", pageStr)
+		}
+		page = value
+	}
+	if pageSizeStr != "" {
+		value, err := strconv.Atoi(pageSizeStr)
+		if err != nil || value < 1 {
+			return 0, 0, fmt.Errorf("无效的每页数量: %s", pageSizeStr)
+		}
+		pageSize = value
+	}
+	if pageSize > maxSize {
+		pageSize = maxSize
+	}
+	return page, pageSize, nil
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+// queryMemoryModels 在内存配置快照上按查询条件过滤、排序和分页（无时间信息时按ID排序）
+func queryMemoryModels(models map[string]*config.ModelConfig, q db.ModelQuery) ([]*config.ModelConfig, int) {
+	q.Normalize()
+	search := strings.ToLower(q.Search)
+
+	var matched []*config.ModelConfig
+	for _, model := range models {
+		if q.Type != "" && string(model.Type) != q.Type {
+			continue
 		}
+		if q.Tenant != "" && config.TenantOrDefault(model.Tenant) != q.Tenant {
+			continue
+		}
+		if search != "" &&
+			!strings.Contains(strings.ToLower(model.ID), search) &&
+			!strings.Contains(strings.ToLower(model.Name), search) &&
+			!strings.Contains(strings.ToLower(model.Target), search) {
+			continue
+		}
+		matched = append(matched, model)
+	}
 
-		c.Next()
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if q.SortBy == "name" && a.Name != b.Name {
+			if q.Desc {
+				return a.Name > b.Name
+			}
+			return a.Name < b.Name
+		}
+		if q.SortBy != "name" && q.Desc {
+			return a.ID > b.ID
+		}
+		return a.ID < b.ID
+	})
+
+	total := len(matched)
+	if q.PageSize > 0 {
+		start := (q.Page - 1) * q.PageSize
+		if start > total {
+			start = total
+		}
+		end := start + q.PageSize
+		if end > total {
+			end = total
+		}
+		matched = matched[start:end]
 	}
-}
 
-// ModelResponse 模型响应结构
-type ModelResponse struct {
-	ID              string           `json:"id"`
-	Name            string           `json:"name"`
-	Target          string           `json:"target"`
-	Prompt          string           `json:"prompt"`
-	Url             string           `json:"url"`
-	Type            config.ModelType `json:"type"`
-	PromptPath      string           `json:"prompt_path"`
-	PromptValue     interface{}      `json:"prompt_value"`
-	PromptValueType config.ValueType `json:"prompt_value_type"`
-	CreatedAt       string           `json:"created_at"`
-	UpdatedAt       string           `json:"updated_at"`
-}
-
-// CreateModelRequest 创建模型请求结构
-type CreateModelRequest struct {
-	ID              string           `json:"id" binding:"required"`
-	Name            string           `json:"name" binding:"required"`
-	Target          string           `json:"target" binding:"required"`
-	Prompt          string           `json:"prompt"`
-	Url             string           `json:"url" binding:"required"`
-	Type            config.ModelType `json:"type" binding:"required"`
-	PromptPath      string           `json:"prompt_path"`
-	PromptValue     interface{}      `json:"prompt_value"`
-	PromptValueType config.ValueType `json:"prompt_value_type"`
-}
-
-// UpdateModelRequest 更新模型请求结构
-type UpdateModelRequest struct {
-	Name            string           `json:"name"`
-	Target          string           `json:"target"`
-	Prompt          string           `json:"prompt"`
-	Url             string           `json:"url"`
-	Type            config.ModelType `json:"type"`
-	PromptPath      string           `json:"prompt_path"`
-	PromptValue     interface{}      `json:"prompt_value"`
-	PromptValueType config.ValueType `json:"prompt_value_type"`
+	return matched, total
 }
 
 // getModels 获取模型列表
 func (s *AdminServer) getModels(c *gin.Context) {
-	var models []ModelResponse
+	query, err := parseModelQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	models := []api.ModelResponse{}
+	var total int64
 
 	if s.configService != nil {
 		// 使用配置服务获取包含时间信息的模型数据
-		dbModels, err := s.configService.GetAllModelsWithTime()
+		dbModels, count, err := s.configService.QueryModelsWithTime(tenantScope(c), query)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code":    500,
-				"message": fmt.Sprintf("获取模型列表失败: %v", err),
-			})
+			respondError(c, http.StatusInternalServerError, i18n.CodeListModelsFailed, err)
 			return
 		}
+		total = count
 
 		for _, dbModel := range dbModels {
 			// 转换为配置模型
@@ -239,45 +391,31 @@ func (s *AdminServer) getModels(c *gin.Context) {
 				continue // 跳过转换失败的模型
 			}
 
-			models = append(models, ModelResponse{
-				ID:              model.ID,
-				Name:            model.Name,
-				Target:          model.Target,
-				Prompt:          model.Prompt,
-				Url:             model.Url,
-				Type:            model.Type,
-				PromptPath:      model.PromptPath,
-				PromptValue:     model.PromptValue,
-				PromptValueType: model.PromptValueType,
-				CreatedAt:       dbModel.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-				UpdatedAt:       dbModel.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			})
+			models = append(models, newModelResponse(model, &dbModel))
 		}
 	} else {
 		// 降级方案：从内存配置获取（无时间信息）
-		for _, model := range s.config.Models {
-			models = append(models, ModelResponse{
-				ID:              model.ID,
-				Name:            model.Name,
-				Target:          model.Target,
-				Prompt:          model.Prompt,
-				Url:             model.Url,
-				Type:            model.Type,
-				PromptPath:      model.PromptPath,
-				PromptValue:     model.PromptValue,
-				PromptValueType: model.PromptValueType,
-				CreatedAt:       "",
-				UpdatedAt:       "",
-			})
+		query.Tenant = tenantScope(c).Filter()
+		memModels, count := queryMemoryModels(s.config.SnapshotModels(), query)
+		total = int64(count)
+		for _, model := range memModels {
+			models = append(models, newModelResponse(model, nil))
 		}
 	}
+	if err := s.fillModelUsage(models); err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeModelUsageFailed, err)
+		return
+	}
+	s.fillModelHealth(models)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
-		"data": gin.H{
-			"models": models,
-			"total":  len(models),
+		"data": api.ModelList{
+			Models:   models,
+			Total:    total,
+			Page:     query.Page,
+			PageSize: query.PageSize,
 		},
 	})
 }
@@ -286,67 +424,41 @@ func (s *AdminServer) getModels(c *gin.Context) {
 func (s *AdminServer) getModel(c *gin.Context) {
 	modelID := c.Param("id")
 
-	var response ModelResponse
+	var response api.ModelResponse
 
 	if s.configService != nil {
 		// 使用配置服务获取包含时间信息的模型数据
 		dbModel, err := s.configService.GetModelWithTime(modelID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    404,
-				"message": fmt.Sprintf("模型 %s 不存在", modelID),
-			})
+			respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
 			return
 		}
 
 		// 转换为配置模型
 		model, err := dbModel.ToModelConfig()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code":    500,
-				"message": fmt.Sprintf("模型数据转换失败: %v", err),
-			})
+			respondError(c, http.StatusInternalServerError, i18n.CodeModelConvertFailed, err)
 			return
 		}
 
-		response = ModelResponse{
-			ID:              model.ID,
-			Name:            model.Name,
-			Target:          model.Target,
-			Prompt:          model.Prompt,
-			Url:             model.Url,
-			Type:            model.Type,
-			PromptPath:      model.PromptPath,
-			PromptValue:     model.PromptValue,
-			PromptValueType: model.PromptValueType,
-			CreatedAt:       dbModel.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:       dbModel.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		}
+		response = newModelResponse(model, dbModel)
 	} else {
 		// 降级方案：从内存配置获取（无时间信息）
 		model, exists := s.config.GetModel(modelID)
 		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    404,
-				"message": fmt.Sprintf("模型 %s 不存在", modelID),
-			})
+			respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
 			return
 		}
 
-		response = ModelResponse{
-			ID:              model.ID,
-			Name:            model.Name,
-			Target:          model.Target,
-			Prompt:          model.Prompt,
-			Url:             model.Url,
-			Type:            model.Type,
-			PromptPath:      model.PromptPath,
-			PromptValue:     model.PromptValue,
-			PromptValueType: model.PromptValueType,
-			CreatedAt:       "",
-			UpdatedAt:       "",
-		}
+		response = newModelResponse(model, nil)
+	}
+	models := []api.ModelResponse{response}
+	if err := s.fillModelUsage(models); err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeModelUsageFailed, err)
+		return
 	}
+	s.fillModelHealth(models)
+	response = models[0]
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
@@ -355,37 +467,107 @@ func (s *AdminServer) getModel(c *gin.Context) {
 	})
 }
 
+// getModelSchema 获取描述模型配置的JSON Schema，供Web界面生成模型表单
+func (s *AdminServer) getModelSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    config.ModelConfigSchema(),
+	})
+}
+
+// respondValidationError 返回模型配置验证错误，data.errors中列出每个字段的错误以便前端标出对应输入
+func respondValidationError(c *gin.Context, err error) {
+	var fieldErrs config.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		fieldErrs = config.ValidationErrors{{Message: err.Error()}}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"code":       400,
+		"error_code": i18n.CodeModelInvalid,
+		"message":    i18n.T(c, i18n.CodeModelInvalid, err),
+		"data":       gin.H{"errors": fieldErrs},
+	})
+}
+
+// newModelFromRequest 根据创建请求构建模型配置，通过管理API创建的模型不会被YAML文件覆盖
+func newModelFromRequest(req *api.CreateModelRequest) *config.ModelConfig {
+	return &config.ModelConfig{
+		ID:                  req.ID,
+		Name:                req.Name,
+		Target:              req.Target,
+		Prompt:              req.Prompt,
+		Url:                 req.Url,
+		Type:                config.ModelType(req.Type),
+		PromptPath:          req.PromptPath,
+		PromptValue:         req.PromptValue,
+		PromptValueType:     config.ValueType(req.PromptValueType),
+		ModelIDSource:       config.ModelIDSource(req.ModelIDSource),
+		ModelIDKey:          req.ModelIDKey,
+		Disabled:            req.Disabled,
+		CacheTTL:            req.CacheTTL,
+		MaxConcurrency:      req.MaxConcurrency,
+		QueueOnLimit:        req.QueueOnLimit,
+		QueueTimeout:        req.QueueTimeout,
+		Maintenance:         req.Maintenance,
+		MaintenanceMessage:  req.MaintenanceMessage,
+		MaintenanceStatus:   req.MaintenanceStatus,
+		Aliases:             req.Aliases,
+		SigningSecret:       req.SigningSecret,
+		Tools:               req.Tools,
+		ToolsMode:           config.ToolsMode(req.ToolsMode),
+		MaxTimeoutMs:        req.MaxTimeoutMs,
+		RequestHeaders:      req.RequestHeaders,
+		ResponseHeaders:     req.ResponseHeaders,
+		QueueTimeoutMs:      req.QueueTimeoutMs,
+		Source:              config.ModelSourceAPI,
+		Pipeline:            req.Pipeline,
+		StreamMode:          config.StreamMode(req.StreamMode),
+		Targets:             configTargets(req.Targets),
+		MinifyBody:          req.MinifyBody,
+		CompressUpstream:    req.CompressUpstream,
+		PromptVariants:      configPromptVariants(req.PromptVariants),
+		HealthCheckInterval: req.HealthCheckInterval,
+		HealthCheckMethod:   config.HealthCheckMethod(req.HealthCheckMethod),
+		PromptPosition:      config.PromptPosition(req.PromptPosition),
+		DedupePrompt:        req.DedupePrompt,
+		CanaryTarget:        req.CanaryTarget,
+		CanaryUrl:           req.CanaryUrl,
+		CanaryPercent:       req.CanaryPercent,
+		HedgeAfterMs:        req.HedgeAfterMs,
+		MaxHedges:           req.MaxHedges,
+		Tenant:              req.Tenant,
+	}
+}
+
 // createModel 创建模型配置
 func (s *AdminServer) createModel(c *gin.Context) {
-	var req CreateModelRequest
+	var req api.CreateModelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 检查模型ID是否已存在
 	if _, exists := s.config.GetModel(req.ID); exists {
-		c.JSON(http.StatusConflict, gin.H{
-			"code":    409,
-			"message": fmt.Sprintf("模型 %s 已存在", req.ID),
-		})
+		respondError(c, http.StatusConflict, i18n.CodeModelExists, req.ID)
 		return
 	}
 
 	// 创建新的模型配置
-	newModel := &config.ModelConfig{
-		ID:              req.ID,
-		Name:            req.Name,
-		Target:          req.Target,
-		Prompt:          req.Prompt,
-		Url:             req.Url,
-		Type:            req.Type,
-		PromptPath:      req.PromptPath,
-		PromptValue:     req.PromptValue,
-		PromptValueType: req.PromptValueType,
+	newModel := newModelFromRequest(&req)
+	if !s.assignModelTenant(c, newModel) {
+		return
+	}
+
+	// 验证模型配置
+	if err := newModel.Validate(); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	if err := s.config.CheckAliases(newModel); err != nil {
+		respondValidationError(c, config.ValidationErrors{{Field: "aliases", Message: err.Error()}})
+		return
 	}
 
 	// 保存模型配置
@@ -394,85 +576,23 @@ func (s *AdminServer) createModel(c *gin.Context) {
 		// 使用配置服务保存
 		err = s.configService.SaveModel(newModel)
 	} else {
-		// 验证模型配置
-		if err := newModel.Validate(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    400,
-				"message": fmt.Sprintf("模型配置验证失败: %v", err),
-			})
-			return
-		}
-
 		// 添加到内存配置
-		s.config.Models[req.ID] = newModel
+		s.config.AddModel(newModel)
 
 		// 保存到文件
 		err = s.saveModelToFile(newModel)
 		if err != nil {
 			// 如果保存失败，从内存中移除
-			delete(s.config.Models, req.ID)
+			s.config.RemoveModel(req.ID)
 		}
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("保存模型配置失败: %v", err),
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
 		return
 	}
 
-	// 构建响应数据
-	var response ModelResponse
-	if s.configService != nil {
-		// 从数据库获取包含时间信息的模型数据
-		dbModel, err := s.configService.GetModelWithTime(newModel.ID)
-		if err == nil {
-			response = ModelResponse{
-				ID:              newModel.ID,
-				Name:            newModel.Name,
-				Target:          newModel.Target,
-				Prompt:          newModel.Prompt,
-				Url:             newModel.Url,
-				Type:            newModel.Type,
-				PromptPath:      newModel.PromptPath,
-				PromptValue:     newModel.PromptValue,
-				PromptValueType: newModel.PromptValueType,
-				CreatedAt:       dbModel.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-				UpdatedAt:       dbModel.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			}
-		} else {
-			// 降级方案：无时间信息
-			response = ModelResponse{
-				ID:              newModel.ID,
-				Name:            newModel.Name,
-				Target:          newModel.Target,
-				Prompt:          newModel.Prompt,
-				Url:             newModel.Url,
-				Type:            newModel.Type,
-				PromptPath:      newModel.PromptPath,
-				PromptValue:     newModel.PromptValue,
-				PromptValueType: newModel.PromptValueType,
-				CreatedAt:       "",
-				UpdatedAt:       "",
-			}
-		}
-	} else {
-		// 无配置服务时的响应（无时间信息）
-		response = ModelResponse{
-			ID:              newModel.ID,
-			Name:            newModel.Name,
-			Target:          newModel.Target,
-			Prompt:          newModel.Prompt,
-			Url:             newModel.Url,
-			Type:            newModel.Type,
-			PromptPath:      newModel.PromptPath,
-			PromptValue:     newModel.PromptValue,
-			PromptValueType: newModel.PromptValueType,
-			CreatedAt:       "",
-			UpdatedAt:       "",
-		}
-	}
+	response := s.savedModelResponse(newModel)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"code":    0,
@@ -487,19 +607,13 @@ func (s *AdminServer) updateModel(c *gin.Context) {
 
 	model, exists := s.config.GetModel(modelID)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code":    404,
-			"message": fmt.Sprintf("模型 %s 不存在", modelID),
-		})
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
 		return
 	}
 
-	var req UpdateModelRequest
+	var req api.UpdateModelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
@@ -516,13 +630,132 @@ func (s *AdminServer) updateModel(c *gin.Context) {
 	// Prompt相关字段允许为空，直接更新
 	model.Prompt = req.Prompt
 	model.PromptPath = req.PromptPath
-	model.PromptValueType = req.PromptValueType
+	model.PromptValueType = config.ValueType(req.PromptValueType)
 	model.PromptValue = req.PromptValue // 允许设置为nil来清空字段
 	if req.Url != "" {
 		model.Url = req.Url
 	}
 	if req.Type != "" {
-		model.Type = req.Type
+		model.Type = config.ModelType(req.Type)
+	}
+	if req.ModelIDSource != "" {
+		model.ModelIDSource = config.ModelIDSource(req.ModelIDSource)
+	}
+	if req.ModelIDKey != "" {
+		model.ModelIDKey = req.ModelIDKey
+	}
+	if req.Disabled != nil {
+		model.Disabled = *req.Disabled
+	}
+	if req.CacheTTL != nil {
+		model.CacheTTL = *req.CacheTTL
+	}
+	if req.MaxConcurrency != nil {
+		model.MaxConcurrency = *req.MaxConcurrency
+	}
+	if req.QueueOnLimit != nil {
+		model.QueueOnLimit = *req.QueueOnLimit
+	}
+	if req.QueueTimeout != nil {
+		model.QueueTimeout = *req.QueueTimeout
+	}
+	if req.Maintenance != nil {
+		model.Maintenance = *req.Maintenance
+	}
+	if req.MaintenanceMessage != nil {
+		model.MaintenanceMessage = *req.MaintenanceMessage
+	}
+	if req.MaintenanceStatus != nil {
+		model.MaintenanceStatus = *req.MaintenanceStatus
+	}
+	if req.Aliases != nil {
+		model.Aliases = req.Aliases
+	}
+	if req.SigningSecret != nil {
+		model.SigningSecret = *req.SigningSecret
+	}
+	if req.Tools != nil {
+		model.Tools = req.Tools
+	}
+	if req.ToolsMode != nil {
+		model.ToolsMode = config.ToolsMode(*req.ToolsMode)
+	}
+	if req.MaxTimeoutMs != nil {
+		model.MaxTimeoutMs = *req.MaxTimeoutMs
+	}
+	if req.RequestHeaders != nil {
+		model.RequestHeaders = req.RequestHeaders
+	}
+	if req.ResponseHeaders != nil {
+		model.ResponseHeaders = req.ResponseHeaders
+	}
+	if req.QueueTimeoutMs != nil {
+		model.QueueTimeoutMs = *req.QueueTimeoutMs
+	}
+	if req.Pipeline != nil {
+		model.Pipeline = req.Pipeline
+	}
+	if req.StreamMode != nil {
+		model.StreamMode = config.StreamMode(*req.StreamMode)
+	}
+	if req.Targets != nil {
+		model.Targets = configTargets(req.Targets)
+	}
+	if req.MinifyBody != nil {
+		model.MinifyBody = *req.MinifyBody
+	}
+	if req.CompressUpstream != nil {
+		model.CompressUpstream = *req.CompressUpstream
+	}
+	if req.PromptVariants != nil {
+		model.PromptVariants = configPromptVariants(req.PromptVariants)
+	}
+	if req.HealthCheckInterval != nil {
+		model.HealthCheckInterval = *req.HealthCheckInterval
+	}
+	if req.HealthCheckMethod != nil {
+		model.HealthCheckMethod = config.HealthCheckMethod(*req.HealthCheckMethod)
+	}
+	if req.PromptPosition != nil {
+		model.PromptPosition = config.PromptPosition(*req.PromptPosition)
+	}
+	if req.DedupePrompt != nil {
+		model.DedupePrompt = *req.DedupePrompt
+	}
+	if req.CanaryTarget != nil {
+		model.CanaryTarget = *req.CanaryTarget
+	}
+	if req.CanaryUrl != nil {
+		model.CanaryUrl = *req.CanaryUrl
+	}
+	if req.CanaryPercent != nil {
+		model.CanaryPercent = *req.CanaryPercent
+	}
+	if req.HedgeAfterMs != nil {
+		model.HedgeAfterMs = *req.HedgeAfterMs
+	}
+	if req.MaxHedges != nil {
+		model.MaxHedges = *req.MaxHedges
+	}
+	if req.Tenant != nil && config.TenantOrDefault(*req.Tenant) != config.TenantOrDefault(model.Tenant) {
+		model.Tenant = *req.Tenant
+		if !s.assignModelTenant(c, model) {
+			*model = originalModel
+			return
+		}
+	}
+
+	// 验证更新后的配置
+	if err := model.Validate(); err != nil {
+		// 恢复原始配置
+		*model = originalModel
+		respondValidationError(c, err)
+		return
+	}
+	if err := s.config.CheckAliases(model); err != nil {
+		*model = originalModel
+		respondValidationError(c, config.ValidationErrors{{Field: "aliases", Message: err.Error()}})
+		return
 	}
 
 	// 保存更新后的配置
@@ -531,17 +764,6 @@ func (s *AdminServer) updateModel(c *gin.Context) {
 		// 使用配置服务更新
 		err = s.configService.UpdateModel(model)
 	} else {
-		// 验证更新后的配置
-		if err := model.Validate(); err != nil {
-			// 恢复原始配置
-			*model = originalModel
-			c.JSON(http.StatusBadRequest, gin.H{
-				"code":    400,
-				"message": fmt.Sprintf("模型配置验证失败: %v", err),
-			})
-			return
-		}
-
 		// 保存到文件
 		err = s.saveModelToFile(model)
 	}
@@ -549,69 +771,106 @@ func (s *AdminServer) updateModel(c *gin.Context) {
 	if err != nil {
 		// 恢复原始配置
 		*model = originalModel
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("保存模型配置失败: %v", err),
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
 		return
 	}
 
-	// 构建响应数据
-	var response ModelResponse
-	if s.configService != nil {
-		// 从数据库获取包含时间信息的模型数据
-		dbModel, err := s.configService.GetModelWithTime(model.ID)
-		if err == nil {
-			response = ModelResponse{
-				ID:              model.ID,
-				Name:            model.Name,
-				Target:          model.Target,
-				Prompt:          model.Prompt,
-				Url:             model.Url,
-				Type:            model.Type,
-				PromptPath:      model.PromptPath,
-				PromptValue:     model.PromptValue,
-				PromptValueType: model.PromptValueType,
-				CreatedAt:       dbModel.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-				UpdatedAt:       dbModel.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			}
+	response := s.savedModelResponse(model)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "模型更新成功",
+		"data":    response,
+	})
+}
+
+// upsertModel 按模型ID创建或整体替换模型配置（PUT /api/v1/models/:id/upsert），
+// 模型不存在时创建并返回201，存在时按请求体覆盖全部字段并返回200，请求体可省略id
+func (s *AdminServer) upsertModel(c *gin.Context) {
+	modelID := c.Param("id")
+
+	var req api.CreateModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if req.ID == "" {
+		req.ID = modelID
+	}
+	if req.ID != modelID {
+		respondError(c, http.StatusBadRequest, i18n.CodeModelIDMismatch, req.ID)
+		return
+	}
+
+	model, exists := s.config.GetModel(modelID)
+	if exists && model.ID != modelID {
+		// 路径中的ID是其他模型的别名
+		respondError(c, http.StatusConflict, i18n.CodeModelExists, modelID)
+		return
+	}
+	newModel := newModelFromRequest(&req)
+	if exists {
+		// 保留模型来源，YAML中定义的模型仍会被配置文件覆盖
+		newModel.Source = model.Source
+	}
+	if exists && req.Tenant == "" {
+		// 未指定租户时保留原有租户
+		newModel.Tenant = model.Tenant
+	} else if !s.assignModelTenant(c, newModel) {
+		return
+	}
+
+	if err := newModel.Validate(); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	if err := s.config.CheckAliases(newModel); err != nil {
+		respondValidationError(c, config.ValidationErrors{{Field: "aliases", Message: err.Error()}})
+		return
+	}
+
+	if !exists {
+		var err error
+		if s.configService != nil {
+			err = s.configService.SaveModel(newModel)
 		} else {
-			// 降级方案：无时间信息
-			response = ModelResponse{
-				ID:              model.ID,
-				Name:            model.Name,
-				Target:          model.Target,
-				Prompt:          model.Prompt,
-				Url:             model.Url,
-				Type:            model.Type,
-				PromptPath:      model.PromptPath,
-				PromptValue:     model.PromptValue,
-				PromptValueType: model.PromptValueType,
-				CreatedAt:       "",
-				UpdatedAt:       "",
+			s.config.AddModel(newModel)
+			if err = s.saveModelToFile(newModel); err != nil {
+				s.config.RemoveModel(newModel.ID)
 			}
 		}
-	} else {
-		// 无配置服务时的响应（无时间信息）
-		response = ModelResponse{
-			ID:              model.ID,
-			Name:            model.Name,
-			Target:          model.Target,
-			Prompt:          model.Prompt,
-			Url:             model.Url,
-			Type:            model.Type,
-			PromptPath:      model.PromptPath,
-			PromptValue:     model.PromptValue,
-			PromptValueType: model.PromptValueType,
-			CreatedAt:       "",
-			UpdatedAt:       "",
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
+			return
 		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"code":    0,
+			"message": "模型创建成功",
+			"data":    s.savedModelResponse(newModel),
+		})
+		return
+	}
+
+	// 原地替换，代理服务持有的模型指针随之更新
+	originalModel := *model
+	*model = *newModel
+	if err := s.persistModel(model); err != nil {
+		*model = originalModel
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
+		return
+	}
+	if model.PromptVariants == nil {
+		s.experiments.Forget(modelID)
+	}
+	if model.CanaryTarget == "" {
+		s.canaries.Forget(modelID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "模型更新成功",
-		"data":    response,
+		"data":    s.savedModelResponse(model),
 	})
 }
 
@@ -619,14 +878,21 @@ func (s *AdminServer) updateModel(c *gin.Context) {
 func (s *AdminServer) deleteModel(c *gin.Context) {
 	modelID := c.Param("id")
 
-	_, exists := s.config.GetModel(modelID)
+	model, exists := s.config.GetModel(modelID)
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code":    404,
-			"message": fmt.Sprintf("模型 %s 不存在", modelID),
-		})
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
 		return
 	}
+	// 传入别名时删除别名对应的模型
+	modelID = model.ID
+
+	// 删除前读取调用统计，最近仍被调用的模型在响应中给出警告
+	var usage db.ModelUsage
+	if s.configService != nil {
+		if records, err := s.configService.GetModelUsage([]string{modelID}); err == nil {
+			usage = records[modelID]
+		}
+	}
 
 	// 删除模型配置
 	var err error
@@ -635,16 +901,48 @@ func (s *AdminServer) deleteModel(c *gin.Context) {
 		err = s.configService.DeleteModel(modelID)
 	} else {
 		// 从内存中删除
-		delete(s.config.Models, modelID)
+		s.config.RemoveModel(modelID)
 
 		// 从文件中删除（重新保存所有配置）
 		err = s.saveAllModelsToFile()
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("删除模型配置失败: %v", err),
+		respondError(c, http.StatusInternalServerError, i18n.CodeDeleteModelFailed, err)
+		return
+	}
+
+	s.errorTracker.Forget(modelID)
+	s.experiments.Forget(modelID)
+	s.canaries.Forget(modelID)
+	s.health.Forget(modelID)
+
+	var warnings []string
+	data := gin.H{}
+	// 模型在最近一段时间内仍被调用时，在响应中给出最后调用时间和累计请求数
+	if usage.LastUsedAt != nil && time.Since(*usage.LastUsedAt) < recentlyUsedWindow {
+		lastUsedAt := usage.LastUsedAt.Format("2006-01-02T15:04:05Z07:00")
+		warnings = append(warnings, fmt.Sprintf("该模型在最近 %d 天内仍被调用过（最后调用时间 %s）", int(recentlyUsedWindow.Hours()/24), lastUsedAt))
+		data["last_used_at"] = lastUsedAt
+		data["total_request_count"] = usage.TotalRequestCount
+	}
+	// 模型仍被API Key的允许列表引用时，在响应中列出受影响的Key作为警告
+	if s.authService != nil {
+		if apiKeys, err := s.authService.GetAPIKeysByAllowedModel(modelID); err == nil && len(apiKeys) > 0 {
+			affected := make([]gin.H, 0, len(apiKeys))
+			for _, apiKey := range apiKeys {
+				affected = append(affected, gin.H{"id": apiKey.ID, "name": apiKey.Name, "user_id": apiKey.UserID})
+			}
+			warnings = append(warnings, fmt.Sprintf("仍有 %d 个API Key的允许列表引用了该模型", len(apiKeys)))
+			data["affected_api_keys"] = affected
+		}
+	}
+
+	if len(warnings) > 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    0,
+			"message": "模型删除成功，但" + strings.Join(warnings, "；"),
+			"data":    data,
 		})
 		return
 	}
@@ -655,40 +953,246 @@ func (s *AdminServer) deleteModel(c *gin.Context) {
 	})
 }
 
-// reloadConfig 重新加载配置
-func (s *AdminServer) reloadConfig(c *gin.Context) {
-	var err error
-	if s.configService != nil {
-		// 使用配置服务重新加载
-		err = s.configService.ReloadConfig(s.configDir)
-		if err == nil {
-			// 更新本地配置引用
-			s.config = s.configService.GetConfig()
-		}
-	} else {
-		// 从文件重新加载
-		newConfig, loadErr := config.LoadConfig(s.configDir)
-		if loadErr != nil {
-			err = loadErr
-		} else {
-			s.config.Models = newConfig.Models
-		}
+// SetErrorTracker 设置代理服务的错误统计器
+func (s *AdminServer) SetErrorTracker(tracker *stats.ErrorTracker) {
+	s.errorTracker = tracker
+}
+
+// SetAuthorizer 设置与代理服务共用的授权检查器，以便模拟时能读取代理的并发状态
+func (s *AdminServer) SetAuthorizer(authorizer *service.Authorizer) {
+	s.authorizer = authorizer
+}
+
+// SimulateRequest 模拟授权检查请求
+type SimulateRequest struct {
+	Model string          `json:"model"` // 模型ID，为空时从请求体的model字段读取
+	Body  json.RawMessage `json:"body"`  // 示例请求体
+}
+
+// simulateAPIKey 对指定API Key执行代理侧的授权检查，不转发任何请求
+func (s *AdminServer) simulateAPIKey(c *gin.Context) {
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
 	}
 
+	id, err := parseUint(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("重新加载配置失败: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidAPIKeyID)
+		return
+	}
+
+	var req SimulateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	modelID := req.Model
+	if modelID == "" {
+		modelID = gjson.GetBytes(req.Body, "model").String()
+	}
+	if modelID == "" {
+		respondError(c, http.StatusBadRequest, i18n.CodeMissingModelID)
+		return
+	}
+
+	apiKey, err := s.authService.APIKeyInScope(tenantScope(c), uint(id))
+	if err != nil {
+		respondServiceError(c, http.StatusNotFound, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "配置重新加载成功",
-		"data": gin.H{
-			"total_models": len(s.config.Models),
-		},
+		"message": "success",
+		"data":    s.authorizer.Simulate(apiKey, modelID, time.Now()),
+	})
+}
+
+// getModelErrors 获取模型在统计窗口内的错误分类计数和最近的错误样本
+func (s *AdminServer) getModelErrors(c *gin.Context) {
+	modelID := c.Param("id")
+
+	if _, exists := s.config.GetModel(modelID); !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    s.errorTracker.Summary(modelID),
+	})
+}
+
+// SetModelLoad 设置读取代理服务模型负载的函数
+func (s *AdminServer) SetModelLoad(load func(modelID string) stats.ModelLoad) {
+	s.modelLoad = load
+}
+
+// getModelLoad 获取模型当前进行中和排队等待的请求数
+func (s *AdminServer) getModelLoad(c *gin.Context) {
+	modelID := c.Param("id")
+
+	modelConfig, exists := s.config.GetModel(modelID)
+	if !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	// 限制器按模型ID记录，通过别名查询时使用解析后的ID
+	var load stats.ModelLoad
+	if s.modelLoad != nil {
+		load = s.modelLoad(modelConfig.ID)
+	}
+	// 上限以当前配置为准，模型还没有收到请求时限制器中尚无记录
+	load.Limit = modelConfig.MaxConcurrency
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    load,
+	})
+}
+
+// SetHealthMonitor 设置与代理服务共用的上游健康检查器
+func (s *AdminServer) SetHealthMonitor(monitor *healthcheck.Monitor) {
+	s.health = monitor
+}
+
+// fillModelHealth 为模型响应填充最近一次上游健康检查的结果
+func (s *AdminServer) fillModelHealth(models []api.ModelResponse) {
+	for i := range models {
+		if status, ok := s.health.Status(models[i].ID); ok {
+			models[i].Health = newModelStatus(status)
+		}
+	}
+}
+
+// checkModel 立即检查模型上游的DNS解析、TCP/TLS连接，按模型的health_check_method发送HEAD或最小请求；
+// 检查有超时限制，结果同时作为模型最近一次的检查结果
+func (s *AdminServer) checkModel(c *gin.Context) {
+	modelID := c.Param("id")
+
+	modelConfig, exists := s.config.GetModel(modelID)
+	if !exists {
+		respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthcheck.DefaultTimeout)
+	defer cancel()
+	var status healthcheck.ModelStatus
+	if s.health != nil {
+		status = s.health.Check(ctx, modelConfig)
+	} else {
+		status = healthcheck.Probe(ctx, http.DefaultClient, modelConfig)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    status,
+	})
+}
+
+// backupDir 模型配置备份目录
+func (s *AdminServer) backupDir() string {
+	return filepath.Join(s.configDir, "backup")
+}
+
+// backupModels 将当前模型配置备份到带时间戳的文件
+func (s *AdminServer) backupModels(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeConfigUnavailable)
+		return
+	}
+
+	path, err := s.configService.BackupFile(s.backupDir())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeBackupFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "配置备份成功",
+		"data": gin.H{
+			"name":         filepath.Base(path),
+			"path":         path,
+			"total_models": s.config.ModelCount(),
+		},
+	})
+}
+
+// RestoreRequest 恢复备份请求
+type RestoreRequest struct {
+	Name   string `json:"name" binding:"required"` // 备份文件名
+	DryRun bool   `json:"dry_run"`                 // 只返回差异，不应用
+}
+
+// restoreModels 从备份文件恢复模型配置，替换当前全部模型
+func (s *AdminServer) restoreModels(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeConfigUnavailable)
+		return
+	}
+
+	var req RestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	changes, err := s.configService.RestoreBackup(s.backupDir(), req.Name, req.DryRun)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeRestoreFailed, err)
+		return
+	}
+
+	message := "配置恢复成功"
+	if req.DryRun {
+		message = "预检完成，未应用任何修改"
+	} else {
+		for _, modelID := range changes.Removed {
+			s.errorTracker.Forget(modelID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data": gin.H{
+			"dry_run": req.DryRun,
+			"changes": changes,
+		},
+	})
+}
+
+// reloadConfig 重新加载配置
+func (s *AdminServer) reloadConfig(c *gin.Context) {
+	var err error
+	if s.configService != nil {
+		// 使用配置服务重新加载
+		err = s.configService.ReloadConfig(s.configDir)
+	} else {
+		// 从文件重新加载
+		newConfig, loadErr := config.LoadConfig(s.configDir)
+		if loadErr != nil {
+			err = loadErr
+		} else {
+			err = s.config.Replace(newConfig.Models, newConfig.DefaultModel, newConfig.CaseInsensitiveModels)
+		}
+	}
+
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeReloadFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "配置重新加载成功",
+		"data":    api.ReloadResult{TotalModels: s.config.ModelCount()},
 	})
 }
 
@@ -699,12 +1203,142 @@ func (s *AdminServer) getStatus(c *gin.Context) {
 		"message": "success",
 		"data": gin.H{
 			"status":       "running",
-			"total_models": len(s.config.Models),
+			"total_models": s.config.ModelCount(),
 			"config_dir":   s.configDir,
+			"panics":       recovery.Count(),
+		},
+	})
+}
+
+// getConfigDrift 比较当前YAML文件与数据库中的模型配置（GET /api/v1/config/drift），
+// 同时返回最近一次加载配置时按策略处理的结果
+func (s *AdminServer) getConfigDrift(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusServiceUnavailable, i18n.CodeConfigUnavailable)
+		return
+	}
+
+	report, err := s.configService.CheckDrift(s.configDir)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeDriftCheckFailed, err)
+		return
+	}
+	// 租户管理员只能看到所属租户的模型
+	scope := tenantScope(c)
+	report = report.InScope(scope)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"policy":         report.Policy,
+			"checked_at":     report.CheckedAt,
+			"items":          report.Items,
+			"last_reconcile": s.configService.LastReconcile().InScope(scope),
 		},
 	})
 }
 
+// MaintenanceRequest 设置维护模式请求
+type MaintenanceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+}
+
+// getMaintenance 获取全局维护模式
+func (s *AdminServer) getMaintenance(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusServiceUnavailable, i18n.CodeConfigUnavailable)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    s.configService.GetMaintenance(),
+	})
+}
+
+// updateMaintenance 设置全局维护模式
+func (s *AdminServer) updateMaintenance(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusServiceUnavailable, i18n.CodeConfigUnavailable)
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	maintenance := config.MaintenanceConfig{
+		Enabled:    req.Enabled,
+		Message:    req.Message,
+		StatusCode: req.StatusCode,
+	}
+	if err := s.configService.SetMaintenance(maintenance); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeSetMaintenanceFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "维护模式设置成功",
+		"data":    s.configService.GetMaintenance(),
+	})
+}
+
+// TokenSettingsRequest 修改访问token设置请求
+type TokenSettingsRequest struct {
+	TTLSeconds int64 `json:"ttl_seconds" binding:"required"`
+}
+
+// tokenSettingsResponse 生成访问token设置的响应数据
+func (s *AdminServer) tokenSettingsResponse() gin.H {
+	return gin.H{
+		"ttl_seconds":         int64(s.authService.TokenTTL() / time.Second),
+		"default_ttl_seconds": int64(service.DefaultTokenTTL / time.Second),
+		"min_ttl_seconds":     int64(service.MinTokenTTL / time.Second),
+		"max_ttl_seconds":     int64(service.MaxTokenTTL / time.Second),
+	}
+}
+
+// getTokenSettings 获取访问token设置
+func (s *AdminServer) getTokenSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    s.tokenSettingsResponse(),
+	})
+}
+
+// updateTokenSettings 修改新签发访问token的有效期，已签发的token不受影响
+func (s *AdminServer) updateTokenSettings(c *gin.Context) {
+	var req TokenSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	// 先限制秒数再换算，避免换算为time.Duration时溢出
+	ttl := service.MaxTokenTTL + time.Second
+	if req.TTLSeconds <= int64(service.MaxTokenTTL/time.Second) {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if err := s.authService.SetTokenTTL(ttl); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeUpdateTokenTTLFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "token有效期修改成功，已签发的token在原过期时间前仍然有效",
+		"data":    s.tokenSettingsResponse(),
+	})
+}
+
 // getSystemConfig 获取系统配置
 func (s *AdminServer) getSystemConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -717,312 +1351,609 @@ func (s *AdminServer) getSystemConfig(c *gin.Context) {
 	})
 }
 
-// healthCheck 健康检查
-func (s *AdminServer) healthCheck(c *gin.Context) {
+// healthCheck 健康检查，日志记录器写入失败时status为degraded；错误详情只在管理员接口中返回
+func (s *AdminServer) healthCheck(c *gin.Context) {
+	status := "ok"
+	loggers := []gin.H{}
+	for _, item := range logger.GlobalLoggerManager.Status() {
+		if item.Status != logger.LoggerStatusOK {
+			status = "degraded"
+		}
+		loggers = append(loggers, gin.H{"name": item.Name, "status": item.Status, "dropped": item.Dropped})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": status,
+		"timestamp": gin.H{
+			"unix": gin.H{},
+		},
+		"loggers": loggers,
+		"panics":  recovery.Count(),
+	})
+}
+
+// getVersion 获取版本信息
+func (s *AdminServer) getVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    version.Info(),
+	})
+}
+
+// externalWebDir 未嵌入管理页面时使用的外部静态文件目录
+const externalWebDir = "./web"
+
+// findWebAssets 返回管理页面静态文件所在的文件系统：优先使用嵌入的文件，其次是外部目录；
+// 两者都没有index.html时返回nil，此时只提供管理API
+func findWebAssets(embedded fs.FS, externalDir string) fs.FS {
+	if sub, err := fs.Sub(embedded, "web"); err == nil {
+		if _, err := fs.Stat(sub, "index.html"); err == nil {
+			return sub
+		}
+	}
+	external := os.DirFS(externalDir)
+	if _, err := fs.Stat(external, "index.html"); err == nil {
+		return external
+	}
+	return nil
+}
+
+// setupEmbeddedStaticFiles 设置嵌入式静态文件服务
+func (s *AdminServer) setupEmbeddedStaticFiles(r *gin.Engine) {
+	s.webAssets = findWebAssets(webFS, externalWebDir)
+	r.GET("/admin", s.serveIndexHTML)
+	if s.webAssets == nil {
+		fmt.Println("未找到管理页面静态文件，只提供管理API: /api/v1")
+		return
+	}
+
+	r.StaticFS("/static", http.FS(s.webAssets))
+
+	// 单独处理 app.js
+	r.GET("/app.js", func(c *gin.Context) {
+		data, err := fs.ReadFile(s.webAssets, "app.js")
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Header("Content-Type", "application/javascript")
+		c.Data(http.StatusOK, "application/javascript", data)
+	})
+}
+
+// serveIndexHTML 提供管理页面的 index.html，没有管理页面时返回说明管理API地址的JSON
+func (s *AdminServer) serveIndexHTML(c *gin.Context) {
+	if s.webAssets != nil {
+		if data, err := fs.ReadFile(s.webAssets, "index.html"); err == nil {
+			c.Header("Content-Type", "text/html")
+			c.Data(http.StatusOK, "text/html", data)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "未打包管理页面，请通过管理API管理",
+		"data": gin.H{
+			"api":     "/api/v1",
+			"health":  "/health",
+			"version": "/api/v1/version",
+		},
+	})
+}
+
+// authMiddleware 认证中间件
+func (s *AdminServer) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 获取Authorization头
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			respondError(c, http.StatusUnauthorized, i18n.CodeMissingToken)
+			c.Abort()
+			return
+		}
+
+		// 检查Bearer前缀
+		if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
+			respondError(c, http.StatusUnauthorized, i18n.CodeMalformedToken)
+			c.Abort()
+			return
+		}
+
+		// 提取token
+		tokenString := authHeader[7:]
+
+		// 验证token
+		claims, err := s.authService.ValidateToken(tokenString)
+		if errors.Is(err, service.ErrSessionRevoked) {
+			respondError(c, http.StatusUnauthorized, i18n.CodeSessionRevoked)
+			c.Abort()
+			return
+		}
+		if errors.Is(err, service.ErrUserDisabled) {
+			respondError(c, http.StatusUnauthorized, i18n.CodeUserDisabled)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, i18n.CodeInvalidToken)
+			c.Abort()
+			return
+		}
+		if claims.ID != "" {
+			if err := s.authService.TouchSession(claims.ID, c.ClientIP()); err != nil {
+				// 记录错误但不影响请求
+				fmt.Printf("更新会话访问时间失败: %v\n", err)
+			}
+		}
+
+		// 使用临时密码时只允许修改密码、查看和注销，ValidateToken已按用户当前状态刷新，修改密码后同一token恢复正常
+		if claims.MustChangePassword && !passwordChangeExempt[c.FullPath()] {
+			respondError(c, http.StatusForbidden, i18n.CodePasswordChangeRequired)
+			c.Abort()
+			return
+		}
+
+		// 将用户信息存储到上下文
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Set("is_admin", claims.Role == db.RoleSuperuser)
+		c.Set("session_id", claims.ID)
+		c.Set("tenant_scope", claims.Scope())
+		c.Set("tenant_id", claims.Tenant())
+
+		c.Next()
+	}
+}
+
+// passwordChangeExempt 用户修改临时密码前仍可访问的接口
+var passwordChangeExempt = map[string]bool{
+	"/api/v1/user/password": true,
+	"/api/v1/auth/profile":  true,
+	"/api/v1/auth/logout":   true,
+}
+
+// checkInstall 检查是否首次安装
+func (s *AdminServer) checkInstall(c *gin.Context) {
+	isFirstInstall, err := s.authService.IsFirstInstall()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeCheckInstallFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"is_first_install": isFirstInstall,
+			"model_count":      s.config.ModelCount(), // 为0时前端可在安装后提示导入默认模型目录
+		},
+	})
+}
+
+// register 用户注册（仅首次安装）
+func (s *AdminServer) register(c *gin.Context) {
+	var req service.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	// 注册用户
+	response, err := s.authService.Register(&req)
+	if err != nil {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "注册成功",
+		"data":    newLoginResponse(response),
+	})
+}
+
+// newAPIKeyResponse 构建API Key响应，includeKey为true时返回完整key
+func newAPIKeyResponse(apiKey *db.APIKey, includeKey bool) api.APIKeyResponse {
+	lastUsedAt := ""
+	if apiKey.LastUsedAt != nil {
+		lastUsedAt = apiKey.LastUsedAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+
+	expiresAt := ""
+	if apiKey.ExpiresAt != nil {
+		expiresAt = apiKey.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+
+	response := api.APIKeyResponse{
+		ID:            apiKey.ID,
+		Name:          apiKey.Name,
+		KeyPreview:    apiKey.Preview(),
+		IsEnabled:     apiKey.IsEnabled,
+		LastUsedAt:    lastUsedAt,
+		LastUsedIP:    apiKey.LastUsedIP,
+		ExpiresAt:     expiresAt,
+		CreatedAt:     apiKey.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:     apiKey.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		AllowedModels: apiKey.AllowedModels,
+	}
+	if response.AllowedModels == nil {
+		response.AllowedModels = []string{}
+	}
+	response.AllowPromptOverride = apiKey.AllowPromptOverride
+	response.Labels = apiKey.Labels
+	if response.Labels == nil {
+		response.Labels = map[string]string{}
+	}
+	if includeKey {
+		response.KeyValue = apiKey.KeyValue
+	}
+	return response
+}
+
+// checkAllowedModels 检查允许列表中的模型是否都存在且属于API Key的租户，其他租户的模型视为不存在
+func (s *AdminServer) checkAllowedModels(tenantID string, models []string) error {
+	scope := service.TenantScope(config.TenantOrDefault(tenantID))
+	for _, modelID := range models {
+		if _, exists := scope.Model(s.config, modelID); !exists {
+			return fmt.Errorf("模型 %s 不存在", modelID)
+		}
+	}
+	return nil
+}
+
+// getAPIKeys 获取当前用户的API Key列表
+func (s *AdminServer) getAPIKeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
+		return
+	}
+
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
+	}
+
+	selector, err := service.ParseLabelSelector(c.QueryArray("label"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	apiKeys, err := s.authService.GetAPIKeysByUserID(userID.(uint), selector)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListAPIKeysFailed, err)
+		return
+	}
+
+	var response []api.APIKeyResponse
+	for i := range apiKeys {
+		response = append(response, newAPIKeyResponse(&apiKeys[i], false))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    api.APIKeyList{APIKeys: response, Total: len(response)},
+	})
+}
+
+// 即将过期API Key的查询窗口(天)
+const (
+	defaultExpiringDays = 7
+	maxExpiringDays     = 365
+)
+
+// ExpiringAPIKeyResponse 即将过期的API Key，在API Key信息之外返回剩余有效时长
+type ExpiringAPIKeyResponse struct {
+	api.APIKeyResponse
+	RemainingSeconds int64  `json:"remaining_seconds"` // 距离过期的秒数
+	Remaining        string `json:"remaining"`         // 距离过期的时长，如72h0m0s
+}
+
+// getExpiringAPIKeys 获取当前用户在days天内过期的API Key，供界面提前提醒
+func (s *AdminServer) getExpiringAPIKeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
+		return
+	}
+
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
+	}
+
+	days := defaultExpiringDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		value, err := strconv.Atoi(daysStr)
+		if err != nil || value < 1 || value > maxExpiringDays {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidDays, daysStr)
+			return
+		}
+		days = value
+	}
+
+	apiKeys, err := s.authService.GetExpiringAPIKeys(userID.(uint), time.Duration(days)*24*time.Hour)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListExpiringAPIKeysFailed, err)
+		return
+	}
+
+	now := time.Now()
+	response := make([]ExpiringAPIKeyResponse, 0, len(apiKeys))
+	for i := range apiKeys {
+		remaining := apiKeys[i].ExpiresAt.Sub(now).Truncate(time.Second)
+		response = append(response, ExpiringAPIKeyResponse{
+			APIKeyResponse:   newAPIKeyResponse(&apiKeys[i], false),
+			RemainingSeconds: int64(remaining.Seconds()),
+			Remaining:        remaining.String(),
+		})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-		"timestamp": gin.H{
-			"unix": gin.H{},
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"api_keys": response,
+			"total":    len(response),
+			"days":     days,
 		},
 	})
 }
 
-// setupEmbeddedStaticFiles 设置嵌入式静态文件服务
-func (s *AdminServer) setupEmbeddedStaticFiles(r *gin.Engine) {
-	// 创建子文件系统，去掉 "web" 前缀
-	webSubFS, err := fs.Sub(webFS, "web")
+// getAllAPIKeys 获取所有用户的API Key列表，支持按标签过滤
+func (s *AdminServer) getAllAPIKeys(c *gin.Context) {
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
+	}
+
+	selector, err := service.ParseLabelSelector(c.QueryArray("label"))
 	if err != nil {
-		// 如果嵌入文件不可用，尝试使用外部文件
-		r.Static("/static", "./web")
-		r.StaticFile("/app.js", "./web/app.js")
-		r.StaticFile("/admin", "./web/index.html")
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
-	// 使用嵌入的文件系统
-	r.StaticFS("/static", http.FS(webSubFS))
+	apiKeys, err := s.authService.GetAllAPIKeys(tenantScope(c), selector)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListAPIKeysFailed, err)
+		return
+	}
 
-	// 单独处理 app.js
-	r.GET("/app.js", func(c *gin.Context) {
-		data, err := webFS.ReadFile("web/app.js")
-		if err != nil {
-			c.Status(http.StatusNotFound)
-			return
-		}
-		c.Header("Content-Type", "application/javascript")
-		c.Data(http.StatusOK, "application/javascript", data)
-	})
+	response := make([]api.APIKeyResponse, 0, len(apiKeys))
+	for i := range apiKeys {
+		item := newAPIKeyResponse(&apiKeys[i], false)
+		item.UserID = apiKeys[i].UserID
+		item.Username = apiKeys[i].User.Username
+		response = append(response, item)
+	}
 
-	// 单独处理 admin 路径
-	r.GET("/admin", s.serveIndexHTML)
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    api.APIKeyList{APIKeys: response, Total: len(response)},
+	})
 }
 
-// serveIndexHTML 提供嵌入的 index.html
-func (s *AdminServer) serveIndexHTML(c *gin.Context) {
-	data, err := webFS.ReadFile("web/index.html")
-	if err != nil {
-		// 如果嵌入文件不可用，尝试使用外部文件
-		c.File("./web/index.html")
+// createAPIKey 为当前用户创建API Key
+func (s *AdminServer) createAPIKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
-	c.Header("Content-Type", "text/html")
-	c.Data(http.StatusOK, "text/html", data)
-}
-
-// authMiddleware 认证中间件
-func (s *AdminServer) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 获取Authorization头
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "未提供认证token",
-			})
-			c.Abort()
-			return
-		}
-
-		// 检查Bearer前缀
-		if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "认证token格式错误",
-			})
-			c.Abort()
-			return
-		}
 
-		// 提取token
-		tokenString := authHeader[7:]
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
+	}
 
-		// 验证token
-		claims, err := s.authService.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "认证token无效",
-			})
-			c.Abort()
-			return
-		}
+	s.createAPIKeyFor(c, userID.(uint), nil)
+}
 
-		// 将用户信息存储到上下文
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("is_admin", claims.IsAdmin)
+// createUserAPIKey 管理员为指定用户创建API Key
+func (s *AdminServer) createUserAPIKey(c *gin.Context) {
+	id, err := parseUint(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidUserID)
+		return
+	}
 
-		c.Next()
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
 	}
-}
 
-// checkInstall 检查是否首次安装
-func (s *AdminServer) checkInstall(c *gin.Context) {
-	isFirstInstall, err := s.authService.IsFirstInstall()
+	owner, err := s.authService.UserInScope(tenantScope(c), uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("检查安装状态失败: %v", err),
-		})
+		respondError(c, http.StatusNotFound, i18n.CodeUserNotFound, id)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "success",
-		"data": gin.H{
-			"is_first_install": isFirstInstall,
-		},
-	})
+	s.createAPIKeyFor(c, owner.ID, owner)
 }
 
-// register 用户注册（仅首次安装）
-func (s *AdminServer) register(c *gin.Context) {
-	var req service.RegisterRequest
+// createAPIKeyFor 创建属于userID的API Key并返回完整key值，owner不为空时响应中包含所属用户
+func (s *AdminServer) createAPIKeyFor(c *gin.Context, userID uint, owner *db.User) {
+	var req api.CreateAPIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
-	// 注册用户
-	response, err := s.authService.Register(&req)
+	tenantID := c.GetString("tenant_id")
+	if owner != nil {
+		tenantID = owner.TenantID
+	}
+	if err := s.checkAllowedModels(tenantID, req.AllowedModels); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if err := service.ValidateLabels(req.Labels); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	// 如果没有提供KeyValue，则自动生成
+	keyValue := req.KeyValue
+	if keyValue == "" {
+		keyValue = service.GenerateAPIKeyValue()
+	}
+
+	// 创建API Key
+	apiKey, err := s.authService.CreateAPIKey(userID, req.Name, keyValue, req.ExpiresAt, req.AllowedModels, req.Labels)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeCreateAPIKeyFailed, err)
 		return
 	}
 
+	// 返回创建的API Key（包含完整key值）
+	response := newAPIKeyResponse(apiKey, true)
+	if owner != nil {
+		response.UserID = owner.ID
+		response.Username = owner.Username
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "注册成功",
+		"message": "API Key创建成功",
 		"data":    response,
 	})
 }
 
-// APIKeyResponse API Key响应结构
-type APIKeyResponse struct {
-	ID         uint   `json:"id"`
-	Name       string `json:"name"`
-	KeyValue   string `json:"key_value,omitempty"` // 只在创建时返回完整key
-	KeyPreview string `json:"key_preview"`         // 显示用的预览（前几位+***）
-	IsEnabled  bool   `json:"is_enabled"`
-	LastUsedAt string `json:"last_used_at"`
-	ExpiresAt  string `json:"expires_at"`
-	CreatedAt  string `json:"created_at"`
-	UpdatedAt  string `json:"updated_at"`
-}
-
-// CreateAPIKeyRequest 创建API Key请求结构
-type CreateAPIKeyRequest struct {
-	Name      string `json:"name" binding:"required"`
-	KeyValue  string `json:"key_value"` // 可选，如果不提供则自动生成
-	ExpiresAt string `json:"expires_at"` // 可选的过期时间
-}
-
-// getAPIKeys 获取当前用户的API Key列表
-func (s *AdminServer) getAPIKeys(c *gin.Context) {
+// updateAPIKey 更新API Key（名称、启用状态、允许调用的模型等），管理员可以更新范围内其他用户的Key
+func (s *AdminServer) updateAPIKey(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户信息不存在",
-		})
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
 
 	if s.authService == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "认证服务不可用",
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
 		return
 	}
 
-	apiKeys, err := s.authService.GetAPIKeysByUserID(userID.(uint))
+	id, err := parseUint(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("获取API Key列表失败: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidAPIKeyID)
 		return
 	}
 
-	var response []APIKeyResponse
-	for _, apiKey := range apiKeys {
-		// 生成key预览（显示前8位+***）
-		keyPreview := ""
-		if len(apiKey.KeyValue) > 8 {
-			keyPreview = apiKey.KeyValue[:8] + "***"
-		} else {
-			keyPreview = apiKey.KeyValue + "***"
-		}
-
-		lastUsedAt := ""
-		if apiKey.LastUsedAt != nil {
-			lastUsedAt = apiKey.LastUsedAt.Format("2006-01-02T15:04:05Z07:00")
+	var req service.UpdateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	if req.AllowedModels != nil {
+		if err := s.checkAllowedModels(c.GetString("tenant_id"), *req.AllowedModels); err != nil {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
 		}
-
-		expiresAt := ""
-		if apiKey.ExpiresAt != nil {
-			expiresAt = apiKey.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+	}
+	if req.Labels != nil {
+		if err := service.ValidateLabels(*req.Labels); err != nil {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+			return
 		}
+	}
+	// 覆盖Prompt的权限只能由管理员授予，避免普通用户绕过模型配置的Prompt
+	if req.AllowPromptOverride != nil && !c.GetBool("is_admin") {
+		respondError(c, http.StatusForbidden, i18n.CodePromptOverrideAdminOnly)
+		return
+	}
 
-		response = append(response, APIKeyResponse{
-			ID:         apiKey.ID,
-			Name:       apiKey.Name,
-			KeyPreview: keyPreview,
-			IsEnabled:  apiKey.IsEnabled,
-			LastUsedAt: lastUsedAt,
-			ExpiresAt:  expiresAt,
-			CreatedAt:  apiKey.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-			UpdatedAt:  apiKey.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		})
+	// 管理员可以更新范围内其他用户的API Key，普通用户只能更新自己的
+	var apiKey *db.APIKey
+	if c.GetBool("is_admin") {
+		apiKey, err = s.authService.UpdateAPIKeyInScope(tenantScope(c), uint(id), &req)
+	} else {
+		apiKey, err = s.authService.UpdateAPIKey(uint(id), userID.(uint), &req)
+	}
+	if err != nil {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "success",
-		"data": gin.H{
-			"api_keys": response,
-			"total":    len(response),
-		},
+		"message": "API Key更新成功",
+		"data":    newAPIKeyResponse(apiKey, false),
 	})
 }
 
-// createAPIKey 创建API Key
-func (s *AdminServer) createAPIKey(c *gin.Context) {
+// rotateAPIKey 重新生成API Key的值，新值只在本次响应中返回，旧值立即失效
+func (s *AdminServer) rotateAPIKey(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户信息不存在",
-		})
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
 
 	if s.authService == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "认证服务不可用",
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
 		return
 	}
 
-	var req CreateAPIKeyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+	id, err := parseUint(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidAPIKeyID)
 		return
 	}
 
-	// 如果没有提供KeyValue，则自动生成
-	keyValue := req.KeyValue
-	if keyValue == "" {
-		keyValue = s.generateAPIKey()
+	apiKey, err := s.authService.RotateAPIKey(uint(id), userID.(uint), service.GenerateAPIKeyValue())
+	if err != nil {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
 	}
 
-	// 创建API Key
-	apiKey, err := s.authService.CreateAPIKey(userID.(uint), req.Name, keyValue, req.ExpiresAt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("创建API Key失败: %v", err),
-		})
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "API Key已重新生成",
+		"data":    newAPIKeyResponse(apiKey, true),
+	})
+}
+
+// TransferAPIKeyRequest 转移API Key请求
+type TransferAPIKeyRequest struct {
+	UserID uint `json:"user_id" binding:"required"` // 目标用户ID
+}
+
+// transferAPIKey 将API Key转移给其他用户，Key值和使用记录保持不变
+func (s *AdminServer) transferAPIKey(c *gin.Context) {
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
 		return
 	}
 
-	// 返回创建的API Key（包含完整key值）
-	lastUsedAt := ""
-	if apiKey.LastUsedAt != nil {
-		lastUsedAt = apiKey.LastUsedAt.Format("2006-01-02T15:04:05Z07:00")
+	id, err := parseUint(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidAPIKeyID)
+		return
 	}
 
-	expiresAtStr := ""
-	if apiKey.ExpiresAt != nil {
-		expiresAtStr = apiKey.ExpiresAt.Format("2006-01-02T15:04:05Z07:00")
+	var req TransferAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
 	}
 
-	response := APIKeyResponse{
-		ID:        apiKey.ID,
-		Name:      apiKey.Name,
-		KeyValue:  apiKey.KeyValue, // 创建时返回完整key
-		IsEnabled: apiKey.IsEnabled,
-		LastUsedAt: lastUsedAt,
-		ExpiresAt: expiresAtStr,
-		CreatedAt: apiKey.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt: apiKey.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	apiKey, err := s.authService.TransferAPIKey(tenantScope(c), uint(id), req.UserID)
+	if err != nil {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
 	}
 
+	response := newAPIKeyResponse(apiKey, false)
+	if owner, err := s.authService.GetUserByID(apiKey.UserID); err == nil {
+		response.UserID = owner.ID
+		response.Username = owner.Username
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "API Key创建成功",
+		"message": "API Key转移成功",
 		"data":    response,
 	})
 }
@@ -1031,37 +1962,25 @@ func (s *AdminServer) createAPIKey(c *gin.Context) {
 func (s *AdminServer) deleteAPIKey(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户信息不存在",
-		})
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
 
 	if s.authService == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "认证服务不可用",
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
 		return
 	}
 
 	idStr := c.Param("id")
 	id, err := parseUint(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "无效的API Key ID",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidAPIKeyID)
 		return
 	}
 
 	err = s.authService.DeleteAPIKey(uint(id), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -1071,70 +1990,67 @@ func (s *AdminServer) deleteAPIKey(c *gin.Context) {
 	})
 }
 
-// adminMiddleware 管理员权限中间件
-func (s *AdminServer) adminMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		isAdmin, exists := c.Get("is_admin")
-		if !exists || !isAdmin.(bool) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"code":    403,
-				"message": "需要管理员权限",
-			})
-			c.Abort()
+// getUsers 获取用户列表，支持page/page_size分页和按用户名搜索(q)，include_admins=false时不返回管理员
+func (s *AdminServer) getUsers(c *gin.Context) {
+	page, pageSize, err := parsePagination(c, defaultUserPageSize, maxUserPageSize)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	includeAdmins := true
+	if value := c.Query("include_admins"); value != "" {
+		if includeAdmins, err = strconv.ParseBool(value); err != nil {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, fmt.Errorf("无效的include_admins: %s", value))
 			return
 		}
-		c.Next()
 	}
-}
 
-// getUsers 获取用户列表
-func (s *AdminServer) getUsers(c *gin.Context) {
-	response, err := s.authService.GetAllUsers()
+	response, err := s.authService.SearchUsers(tenantScope(c), c.Query("q"), db.UserQuery{Page: page, PageSize: pageSize, IncludeAdmins: includeAdmins})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("获取用户列表失败: %v", err),
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeListUsersFailed, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
-		"data":    response,
+		"data":    newUserListResponse(response),
 	})
 }
 
 // createUser 创建用户
 func (s *AdminServer) createUser(c *gin.Context) {
-	var req service.CreateUserRequest
+	var req api.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 获取创建者ID
 	creatorID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户信息不存在",
-		})
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
 
-	response, err := s.authService.CreateUser(&req, creatorID.(uint))
+	created, err := s.authService.CreateUser(&service.CreateUserRequest{
+		Username:      req.Username,
+		Role:          db.Role(req.Role),
+		IsAdmin:       req.IsAdmin,
+		AutoCreateKey: req.AutoCreateKey,
+		TenantID:      req.TenantID,
+		Global:        req.Global,
+	}, creatorID.(uint), tenantScope(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, tenantErrorStatus(err, http.StatusBadRequest), err)
 		return
 	}
 
+	response := api.CreateUserResponse{User: newAPIUser(created.User), GeneratedPassword: created.GeneratedPassword}
+	if created.APIKey != nil {
+		apiKey := newAPIKeyResponse(created.APIKey, true)
+		response.APIKey = &apiKey
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "用户创建成功",
@@ -1147,28 +2063,19 @@ func (s *AdminServer) updateUser(c *gin.Context) {
 	userID := c.Param("id")
 	id, err := parseUint(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "用户ID格式错误",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidUserID)
 		return
 	}
 
 	var req service.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
-	err = s.authService.UpdateUser(uint(id), &req)
+	err = s.authService.UpdateUser(tenantScope(c), uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, tenantErrorStatus(err, http.StatusBadRequest), err)
 		return
 	}
 
@@ -1183,29 +2090,32 @@ func (s *AdminServer) deleteUser(c *gin.Context) {
 	userID := c.Param("id")
 	id, err := parseUint(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "用户ID格式错误",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidUserID)
 		return
 	}
 
 	// 不允许删除自己
 	currentUserID, exists := c.Get("user_id")
 	if exists && currentUserID.(uint) == uint(id) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "不能删除自己",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeCannotDeleteSelf)
 		return
 	}
 
-	err = s.authService.DeleteUser(uint(id))
+	cascade := false
+	if value := c.Query("cascade"); value != "" {
+		if cascade, err = strconv.ParseBool(value); err != nil {
+			respondError(c, http.StatusBadRequest, i18n.CodeInvalidCascade, value)
+			return
+		}
+	}
+
+	err = s.authService.DeleteUser(uint(id), cascade)
+	if errors.Is(err, db.ErrUserHasAPIKeys) {
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -1220,10 +2130,7 @@ func (s *AdminServer) updateUserStatus(c *gin.Context) {
 	userID := c.Param("id")
 	id, err := parseUint(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "用户ID格式错误",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidUserID)
 		return
 	}
 
@@ -1231,29 +2138,20 @@ func (s *AdminServer) updateUserStatus(c *gin.Context) {
 		IsEnabled bool `json:"is_enabled"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 不允许禁用自己
 	currentUserID, exists := c.Get("user_id")
 	if exists && currentUserID.(uint) == uint(id) && !req.IsEnabled {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "不能禁用自己",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeCannotDisableSelf)
 		return
 	}
 
 	err = s.authService.UpdateUserStatus(uint(id), req.IsEnabled)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -1263,33 +2161,47 @@ func (s *AdminServer) updateUserStatus(c *gin.Context) {
 	})
 }
 
+// unlockUser 解除用户的账户锁定并清零登录失败次数（POST /api/v1/users/:id/unlock）
+func (s *AdminServer) unlockUser(c *gin.Context) {
+	id, err := parseUint(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidUserID)
+		return
+	}
+
+	if err := s.authService.UnlockUser(uint(id)); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			respondServiceError(c, http.StatusNotFound, err)
+			return
+		}
+		respondServiceError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "用户已解锁",
+	})
+}
+
 // adminChangePassword 管理员修改用户密码
 func (s *AdminServer) adminChangePassword(c *gin.Context) {
 	userID := c.Param("id")
 	id, err := parseUint(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "用户ID格式错误",
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidUserID)
 		return
 	}
 
 	var req service.AdminChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	err = s.authService.AdminChangePassword(uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -1303,28 +2215,19 @@ func (s *AdminServer) adminChangePassword(c *gin.Context) {
 func (s *AdminServer) changePassword(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户信息不存在",
-		})
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
 
 	var req service.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	err := s.authService.ChangePassword(userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -1339,50 +2242,45 @@ func parseUint(s string) (uint64, error) {
 	return strconv.ParseUint(s, 10, 32)
 }
 
-// generateAPIKey 生成API Key
-func (s *AdminServer) generateAPIKey() string {
-	// 生成32字节的随机数据
-	bytes := make([]byte, 32)
-	_, err := rand.Read(bytes)
-	if err != nil {
-		// 如果随机数生成失败，使用时间戳作为后备方案
-		return fmt.Sprintf("ak_%d", time.Now().UnixNano())
-	}
-	// 转换为十六进制字符串并添加前缀
-	return "ak_" + hex.EncodeToString(bytes)
-}
-
 // login 用户登录
 func (s *AdminServer) login(c *gin.Context) {
 	var req service.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 用户登录
 	response, err := s.authService.Login(&req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": err.Error(),
-		})
+		respondServiceError(c, loginErrorStatus(err), err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "登录成功",
-		"data":    response,
+		"data":    newLoginResponse(response),
 	})
 }
 
+// loginErrorStatus 登录失败时的HTTP状态码，账户锁定时返回423，其他情况返回401
+func loginErrorStatus(err error) int {
+	if errors.Is(err, service.ErrUserLocked) {
+		return http.StatusLocked
+	}
+	return http.StatusUnauthorized
+}
+
 // logout 用户注销
 func (s *AdminServer) logout(c *gin.Context) {
-	// 简单的注销响应，客户端需要删除本地token
+	// 撤销当前会话，token在过期前也不能再使用；升级前签发的token没有会话，只需客户端删除本地token
+	if jti := c.GetString("session_id"); jti != "" {
+		if err := s.authService.RevokeSession(service.GlobalScope, jti, 0); err != nil {
+			respondServiceError(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "注销成功",
@@ -1393,27 +2291,21 @@ func (s *AdminServer) logout(c *gin.Context) {
 func (s *AdminServer) getProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "用户信息不存在",
-		})
+		respondError(c, http.StatusUnauthorized, i18n.CodeUserContextMissing)
 		return
 	}
 
 	// 从数据库获取用户信息
 	user, err := s.authService.GetUserByID(userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("获取用户信息失败: %v", err),
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodeGetUserFailed, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "success",
-		"data":    user,
+		"data":    newProfile(user),
 	})
 }
 
@@ -1421,10 +2313,7 @@ func (s *AdminServer) getProfile(c *gin.Context) {
 func (s *AdminServer) getPublicKey(c *gin.Context) {
 	response, err := s.authService.GetPublicKey()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": fmt.Sprintf("获取公钥失败: %v", err),
-		})
+		respondError(c, http.StatusInternalServerError, i18n.CodePublicKeyFailed, err)
 		return
 	}
 
@@ -1439,27 +2328,21 @@ func (s *AdminServer) getPublicKey(c *gin.Context) {
 func (s *AdminServer) encryptedLogin(c *gin.Context) {
 	var req service.EncryptedLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 加密登录
 	response, err := s.authService.EncryptedLogin(&req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": err.Error(),
-		})
+		respondServiceError(c, loginErrorStatus(err), err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "登录成功",
-		"data":    response,
+		"data":    newLoginResponse(response),
 	})
 }
 
@@ -1467,26 +2350,20 @@ func (s *AdminServer) encryptedLogin(c *gin.Context) {
 func (s *AdminServer) encryptedRegister(c *gin.Context) {
 	var req service.EncryptedRegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": fmt.Sprintf("请求参数错误: %v", err),
-		})
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
 		return
 	}
 
 	// 加密注册
 	response, err := s.authService.EncryptedRegister(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": err.Error(),
-		})
+		respondServiceError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "注册成功",
-		"data":    response,
+		"data":    newLoginResponse(response),
 	})
 }
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// listSessions 返回范围内userID的登录会话（0表示所有用户），并标记发起本次请求的会话
+func (s *AdminServer) listSessions(c *gin.Context, scope service.TenantScope, userID uint) {
+	sessions, err := s.authService.ListSessions(scope, userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListSessionsFailed, err)
+		return
+	}
+	current := c.GetString("session_id")
+	for i := range sessions {
+		sessions[i].Current = current != "" && sessions[i].JTI == current
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"sessions": sessions,
+			"total":    len(sessions),
+		},
+	})
+}
+
+// getSessions 获取范围内所有用户未过期且未撤销的登录会话（需要管理员权限），租户管理员只能看到所属租户的会话
+func (s *AdminServer) getSessions(c *gin.Context) {
+	s.listSessions(c, tenantScope(c), 0)
+}
+
+// getOwnSessions 获取当前用户未过期且未撤销的登录会话
+func (s *AdminServer) getOwnSessions(c *gin.Context) {
+	s.listSessions(c, service.GlobalScope, c.GetUint("user_id"))
+}
+
+// revokeSession 撤销登录会话，对应的token立即失效；管理员可以撤销范围内用户的会话，其他用户只能撤销自己的会话
+func (s *AdminServer) revokeSession(c *gin.Context) {
+	scope, owner := service.GlobalScope, c.GetUint("user_id")
+	if c.GetBool("is_admin") {
+		scope, owner = tenantScope(c), 0
+	}
+	if err := s.authService.RevokeSession(scope, c.Param("jti"), owner); err != nil {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "会话已撤销",
+	})
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestSessionsListAndRevoke(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService := adminServer.authService.(*service.AuthService)
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	created, err := authService.CreateUser(&service.CreateUserRequest{Username: "alice"}, admin.User.ID, service.GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	created.User.MustChangePassword = false
+	if err := configService.GetDBManager().UpdateUser(created.User); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	aliceToken, _, err := authService.GenerateToken(created.User)
+	if err != nil {
+		t.Fatalf("生成token失败: %v", err)
+	}
+	aliceClaims := mustClaims(t, authService, aliceToken)
+	if aliceClaims.ID == "" {
+		t.Fatal("token应包含jti")
+	}
+	aliceSession := "/api/v1/sessions/" + aliceClaims.ID
+	adminSession := "/api/v1/sessions/" + mustClaims(t, authService, admin.Token).ID
+
+	router := adminServer.Router()
+	runRouteSteps(t, router, aliceToken, []routeStep{
+		{"查看自己的会话", http.MethodGet, "/api/v1/auth/sessions", "", http.StatusOK, "data.total", "1"},
+		{"标记当前会话", http.MethodGet, "/api/v1/auth/sessions", "", http.StatusOK, "data.sessions.0.current", "true"},
+		{"非管理员查看全部会话", http.MethodGet, "/api/v1/sessions", "", http.StatusForbidden, "error_code", "admin_required"},
+		{"撤销其他用户的会话", http.MethodDelete, adminSession, "", http.StatusNotFound, "error_code", "session_not_found"},
+	})
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"全部会话", http.MethodGet, "/api/v1/sessions", "", http.StatusOK, "data.total", "2"},
+		{"撤销用户会话", http.MethodDelete, aliceSession, "", http.StatusOK, "", ""},
+		{"撤销后的会话列表", http.MethodGet, "/api/v1/sessions", "", http.StatusOK, "data.sessions.0.username", "admin"},
+		{"撤销不存在的会话", http.MethodDelete, "/api/v1/sessions/missing", "", http.StatusNotFound, "error_code", "session_not_found"},
+	})
+	runRouteSteps(t, router, aliceToken, []routeStep{
+		{"已撤销的token", http.MethodGet, "/api/v1/auth/profile", "", http.StatusUnauthorized, "error_code", "session_revoked"},
+	})
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"注销", http.MethodPost, "/api/v1/auth/logout", "", http.StatusOK, "", ""},
+		{"注销后的token", http.MethodGet, "/api/v1/auth/profile", "", http.StatusUnauthorized, "error_code", "session_revoked"},
+	})
+}
+
+// mustClaims 解析token的claims
+func mustClaims(t *testing.T, authService *service.AuthService, token string) *service.Claims {
+	t.Helper()
+	claims, err := authService.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("验证token失败: %v", err)
+	}
+	return claims
+}
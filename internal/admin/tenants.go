@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// tenantScope 当前用户可以访问的租户范围，由认证中间件根据JWT声明设置
+func tenantScope(c *gin.Context) service.TenantScope {
+	scope, _ := c.Get("tenant_scope")
+	if scope, ok := scope.(service.TenantScope); ok {
+		return scope
+	}
+	return service.TenantScope(config.DefaultTenant)
+}
+
+// requireGlobalScope 只有全局管理员可以执行的操作（system:write），租户管理员返回403
+func requireGlobalScope(c *gin.Context) bool {
+	if tenantScope(c).IsGlobal() {
+		return true
+	}
+	respondError(c, http.StatusForbidden, i18n.CodeGlobalAdminRequired)
+	c.Abort()
+	return false
+}
+
+// requireGlobal 只有全局管理员可以访问的路由，用于影响所有租户但不属于系统设置的操作（如重新加载配置）
+func requireGlobal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if requireGlobalScope(c) {
+			c.Next()
+		}
+	}
+}
+
+// requireModelInScope 模型路由的租户隔离中间件：路径中的模型属于其他租户时返回404，与模型不存在相同，
+// 不泄露其他租户的模型ID；模型不存在时交给处理器按原有逻辑处理
+func (s *AdminServer) requireModelInScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		modelID := c.Param("id")
+		if model, exists := s.config.GetModel(modelID); exists && !tenantScope(c).Allows(model.Tenant) {
+			respondError(c, http.StatusNotFound, i18n.CodeModelNotFound, modelID)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireUserInScope 用户路由的租户隔离中间件：路径中的用户属于其他租户时返回404
+func (s *AdminServer) requireUserInScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := parseUint(c.Param("id"))
+		if err != nil || s.authService == nil {
+			c.Next()
+			return
+		}
+		if user, err := s.authService.GetUserByID(uint(id)); err == nil && !tenantScope(c).Allows(user.TenantID) {
+			respondError(c, http.StatusNotFound, i18n.CodeUserNotFound, id)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// assignModelTenant 设置新建或修改的模型所属的租户，非全局管理员只能使用自己的租户；
+// 失败时返回响应并返回false。未使用数据库时没有租户表，不检查租户是否存在
+func (s *AdminServer) assignModelTenant(c *gin.Context, model *config.ModelConfig) bool {
+	scope := tenantScope(c)
+	var err error
+	if s.configService != nil {
+		err = s.configService.AssignModelTenant(scope, model)
+	} else if !scope.IsGlobal() {
+		if model.Tenant != "" && model.Tenant != scope.Filter() {
+			err = service.ErrTenantForbidden
+		}
+		model.Tenant = scope.Filter()
+	}
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrTenantForbidden):
+		respondServiceError(c, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrTenantNotFound):
+		respondServiceError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveModelFailed, err)
+	}
+	return false
+}
+
+// tenantErrorStatus 租户管理员越权操作其他租户时使用403，其他错误使用status
+func tenantErrorStatus(err error, status int) int {
+	if errors.Is(err, service.ErrTenantForbidden) {
+		return http.StatusForbidden
+	}
+	return status
+}
+
+// getTenants 获取租户列表，租户管理员只能看到所属的租户
+func (s *AdminServer) getTenants(c *gin.Context) {
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
+	}
+
+	tenants, err := s.authService.ListTenants(tenantScope(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListTenantsFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gin.H{"tenants": tenants, "total": len(tenants)},
+	})
+}
+
+// CreateTenantRequest 创建租户请求
+type CreateTenantRequest struct {
+	ID   string `json:"id" binding:"required"` // 租户ID，小写字母、数字、下划线和连字符，最长64个字符
+	Name string `json:"name"`                  // 显示名称
+}
+
+// createTenant 创建租户（只有全局管理员可以创建）
+func (s *AdminServer) createTenant(c *gin.Context) {
+	if s.authService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable)
+		return
+	}
+
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+
+	tenant, err := s.authService.CreateTenant(tenantScope(c), req.ID, req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTenantForbidden):
+			respondServiceError(c, http.StatusForbidden, err)
+		case errors.Is(err, db.ErrTenantExists):
+			respondServiceError(c, http.StatusConflict, err)
+		case errors.Is(err, service.ErrInvalidTenant):
+			respondServiceError(c, http.StatusBadRequest, err)
+		default:
+			respondServiceError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    0,
+		"message": "租户创建成功",
+		"data":    tenant,
+	})
+}
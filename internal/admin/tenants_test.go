@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService := adminServer.authService.(*service.AuthService)
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	model := func(id, tenant string) string {
+		return `{"id":"` + id + `","name":"` + id + `","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions","tenant":"` + tenant + `"}`
+	}
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"创建租户", http.MethodPost, "/api/v1/tenants", `{"id":"team-a","name":"A"}`, http.StatusCreated, "data.id", "team-a"},
+		{"租户ID重复", http.MethodPost, "/api/v1/tenants", `{"id":"team-a"}`, http.StatusConflict, "error_code", "tenant_exists"},
+		{"无效的租户ID", http.MethodPost, "/api/v1/tenants", `{"id":"Team A"}`, http.StatusBadRequest, "error_code", "invalid_tenant"},
+		{"全局管理员查看全部租户", http.MethodGet, "/api/v1/tenants", "", http.StatusOK, "data.total", "2"},
+		{"创建默认租户的模型", http.MethodPost, "/api/v1/models", model("shared", ""), http.StatusCreated, "data.tenant", "default"},
+		{"不存在的租户", http.MethodPost, "/api/v1/models", model("orphan", "team-b"), http.StatusBadRequest, "error_code", "tenant_not_found"},
+	})
+
+	// team-a的租户管理员
+	created, err := authService.CreateUser(&service.CreateUserRequest{Username: "lead", Role: db.RoleSuperuser, TenantID: "team-a"}, admin.User.ID, service.GlobalScope)
+	if err != nil {
+		t.Fatalf("创建租户管理员失败: %v", err)
+	}
+	lead := created.User
+	lead.MustChangePassword = false
+	if err := configService.GetDBManager().UpdateUser(lead); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	leadToken, _, err := authService.GenerateToken(lead)
+	if err != nil {
+		t.Fatalf("生成token失败: %v", err)
+	}
+	adminKey, err := authService.CreateAPIKey(admin.User.ID, "admin-key", "sk-admin", "", nil, nil)
+	if err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+
+	adminKeyPath := fmt.Sprintf("/api/v1/api-keys/%d", adminKey.ID)
+	adminSessionPath := "/api/v1/sessions/" + mustClaims(t, authService, admin.Token).ID
+	adminUserPath := fmt.Sprintf("/api/v1/users/%d", admin.User.ID)
+	runRouteSteps(t, router, leadToken, []routeStep{
+		// 其他租户的模型视为不存在
+		{"列表不含其他租户的模型", http.MethodGet, "/api/v1/models", "", http.StatusOK, "data.total", "0"},
+		{"查看其他租户的模型", http.MethodGet, "/api/v1/models/shared", "", http.StatusNotFound, "error_code", "model_not_found"},
+		{"修改其他租户的模型", http.MethodPut, "/api/v1/models/shared", `{"name":"x"}`, http.StatusNotFound, "error_code", "model_not_found"},
+		{"覆盖其他租户的模型", http.MethodPut, "/api/v1/models/shared/upsert", model("shared", ""), http.StatusNotFound, "error_code", "model_not_found"},
+		{"删除其他租户的模型", http.MethodDelete, "/api/v1/models/shared", "", http.StatusNotFound, "error_code", "model_not_found"},
+		{"创建的模型属于所属租户", http.MethodPost, "/api/v1/models", model("team-chat", ""), http.StatusCreated, "data.tenant", "team-a"},
+		{"不能在其他租户创建模型", http.MethodPost, "/api/v1/models", model("stray", "default"), http.StatusForbidden, "error_code", "tenant_forbidden"},
+		{"不能将模型转到其他租户", http.MethodPut, "/api/v1/models/team-chat", `{"tenant":"default"}`, http.StatusForbidden, "error_code", "tenant_forbidden"},
+		{"转移失败后租户不变", http.MethodGet, "/api/v1/models/team-chat", "", http.StatusOK, "data.tenant", "team-a"},
+		{"列表只含所属租户的模型", http.MethodGet, "/api/v1/models", "", http.StatusOK, "data.models.0.id", "team-chat"},
+		{"不能重新加载所有租户的配置", http.MethodPost, "/api/v1/config/reload", "", http.StatusForbidden, "error_code", "global_admin_required"},
+
+		// API Key只能允许所属租户的模型，其他租户的Key视为不存在
+		{"允许其他租户的模型", http.MethodPost, "/api/v1/api-keys", `{"name":"k","allowed_models":["shared"]}`, http.StatusBadRequest, "error_code", "invalid_request"},
+		{"允许所属租户的模型", http.MethodPost, "/api/v1/api-keys", `{"name":"k","allowed_models":["team-chat"]}`, http.StatusOK, "data.allowed_models.0", "team-chat"},
+		{"全部API Key只含所属租户", http.MethodGet, "/api/v1/admin/api-keys", "", http.StatusOK, "data.total", "1"},
+		{"模拟其他租户的Key", http.MethodPost, adminKeyPath + "/simulate", `{"model":"team-chat"}`, http.StatusNotFound, "error_code", "api_key_not_found"},
+		{"转移其他租户的Key", http.MethodPost, adminKeyPath + "/transfer", fmt.Sprintf(`{"user_id":%d}`, lead.ID), http.StatusBadRequest, "error_code", "api_key_not_found"},
+
+		// 其他租户的用户视为不存在
+		{"用户列表只含所属租户", http.MethodGet, "/api/v1/users?include_admins=true", "", http.StatusOK, "data.total", "1"},
+		{"修改其他租户的用户", http.MethodPut, adminUserPath, `{"username":"mallory"}`, http.StatusNotFound, "error_code", "user_not_found"},
+		{"禁用其他租户的用户", http.MethodPut, adminUserPath + "/status", `{"is_enabled":false}`, http.StatusNotFound, "error_code", "user_not_found"},
+		{"为其他租户的用户创建Key", http.MethodPost, adminUserPath + "/api-keys", `{"name":"k"}`, http.StatusNotFound, "error_code", "user_not_found"},
+		{"不能在其他租户创建用户", http.MethodPost, "/api/v1/users", `{"username":"bob","tenant_id":"default"}`, http.StatusForbidden, "error_code", "tenant_forbidden"},
+		{"不能创建全局管理员", http.MethodPost, "/api/v1/users", `{"username":"bob","global":true}`, http.StatusForbidden, "error_code", "tenant_forbidden"},
+
+		// 其他租户和全局管理员的会话视为不存在
+		{"会话列表只含所属租户", http.MethodGet, "/api/v1/sessions", "", http.StatusOK, "data.sessions.#.username", `["lead"]`},
+		{"撤销全局管理员的会话", http.MethodDelete, adminSessionPath, "", http.StatusNotFound, "error_code", "session_not_found"},
+
+		// 租户和系统设置
+		{"只能看到所属租户", http.MethodGet, "/api/v1/tenants", "", http.StatusOK, "data.tenants.0.id", "team-a"},
+		{"不能创建租户", http.MethodPost, "/api/v1/tenants", `{"id":"team-b"}`, http.StatusForbidden, "error_code", "tenant_forbidden"},
+		{"不能修改系统设置", http.MethodPut, "/api/v1/maintenance", `{"enabled":false}`, http.StatusForbidden, "error_code", "global_admin_required"},
+	})
+
+	// 全局管理员可以看到和转移所有租户的模型
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"查看全部租户的模型", http.MethodGet, "/api/v1/models", "", http.StatusOK, "data.total", "2"},
+		{"查看其他租户的模型", http.MethodGet, "/api/v1/models/team-chat", "", http.StatusOK, "data.tenant", "team-a"},
+		{"按租户覆盖时保留租户", http.MethodPut, "/api/v1/models/team-chat/upsert", model("team-chat", ""), http.StatusOK, "data.tenant", "team-a"},
+		{"转到默认租户", http.MethodPut, "/api/v1/models/team-chat", `{"tenant":"default"}`, http.StatusOK, "data.tenant", "default"},
+		{"全部API Key", http.MethodGet, "/api/v1/admin/api-keys", "", http.StatusOK, "data.total", "2"},
+		{"全部会话", http.MethodGet, "/api/v1/sessions", "", http.StatusOK, "data.total", "2"},
+	})
+	runRouteSteps(t, router, leadToken, []routeStep{
+		{"转走的模型不再可见", http.MethodGet, "/api/v1/models/team-chat", "", http.StatusNotFound, "error_code", "model_not_found"},
+	})
+}
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+// getTrashedModels 获取回收站中的模型，按删除时间从新到旧排序；同一个ID多次删除时每次删除各占一项
+func (s *AdminServer) getTrashedModels(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeConfigUnavailable)
+		return
+	}
+	dbModels, err := s.configService.TrashedModels(tenantScope(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListTrashFailed, err)
+		return
+	}
+
+	models := make([]api.TrashedModel, 0, len(dbModels))
+	for i := range dbModels {
+		dbModel := &dbModels[i]
+		dbModel.ID = dbModel.TrashedID
+		model, err := dbModel.ToModelConfig()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, i18n.CodeModelConvertFailed, err)
+			return
+		}
+		models = append(models, api.TrashedModel{
+			ModelResponse: newModelResponse(model, dbModel),
+			DeletedAt:     dbModel.DeletedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    models,
+	})
+}
+
+// restoreModel 从回收站恢复最近删除的模型配置，恢复前重新验证配置；
+// 模型ID已被新模型使用时返回409，需要先删除或改名新模型
+func (s *AdminServer) restoreModel(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeConfigUnavailable)
+		return
+	}
+	modelID := c.Param("id")
+
+	model, err := s.configService.RestoreModel(tenantScope(c), modelID)
+	if err != nil {
+		var fieldErrs config.ValidationErrors
+		switch {
+		case errors.Is(err, db.ErrModelNotInTrash):
+			respondServiceError(c, http.StatusNotFound, err)
+		case errors.Is(err, db.ErrModelIDInUse):
+			respondServiceError(c, http.StatusConflict, err)
+		case errors.As(err, &fieldErrs):
+			respondValidationError(c, err)
+		default:
+			respondError(c, http.StatusInternalServerError, i18n.CodeRestoreModelFailed, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "模型恢复成功",
+		"data":    s.savedModelResponse(model),
+	})
+}
+
+// purgeModel 永久删除回收站中的模型配置，同一个ID多次删除的记录全部删除
+func (s *AdminServer) purgeModel(c *gin.Context) {
+	if s.configService == nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeConfigUnavailable)
+		return
+	}
+	modelID := c.Param("id")
+
+	if err := s.configService.PurgeModel(tenantScope(c), modelID); err != nil {
+		if errors.Is(err, db.ErrModelNotInTrash) {
+			respondServiceError(c, http.StatusNotFound, err)
+		} else {
+			respondError(c, http.StatusInternalServerError, i18n.CodePurgeModelFailed, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "模型已永久删除",
+	})
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestModelTrashRoutes(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	admin, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	model := func(id, name string) string {
+		return `{"id":"` + id + `","name":"` + name + `","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions","type":"chat"}`
+	}
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"创建模型", http.MethodPost, "/api/v1/models", model("assistant", "原模型"), http.StatusCreated, "", ""},
+		{"删除模型", http.MethodDelete, "/api/v1/models/assistant", "", http.StatusOK, "", ""},
+		{"删除后查询不到", http.MethodGet, "/api/v1/models/assistant", "", http.StatusNotFound, "error_code", "model_not_found"},
+		{"回收站中可见", http.MethodGet, "/api/v1/models/trash", "", http.StatusOK, "data.0.id", "assistant"},
+		{"恢复模型", http.MethodPost, "/api/v1/models/assistant/restore", "", http.StatusOK, "data.name", "原模型"},
+		{"恢复后可查询", http.MethodGet, "/api/v1/models/assistant", "", http.StatusOK, "data.name", "原模型"},
+		{"恢复后回收站为空", http.MethodGet, "/api/v1/models/trash", "", http.StatusOK, "data.#", "0"},
+
+		// 删除后用相同的ID创建了新模型，恢复时冲突
+		{"再次删除", http.MethodDelete, "/api/v1/models/assistant", "", http.StatusOK, "", ""},
+		{"复用模型ID", http.MethodPost, "/api/v1/models", model("assistant", "新模型"), http.StatusCreated, "", ""},
+		{"恢复冲突", http.MethodPost, "/api/v1/models/assistant/restore", "", http.StatusConflict, "error_code", "model_id_in_use"},
+		{"新模型不受影响", http.MethodGet, "/api/v1/models/assistant", "", http.StatusOK, "data.name", "新模型"},
+		{"永久删除", http.MethodDelete, "/api/v1/models/assistant/purge", "", http.StatusOK, "", ""},
+		{"永久删除后不能恢复", http.MethodPost, "/api/v1/models/assistant/restore", "", http.StatusNotFound, "error_code", "model_not_in_trash"},
+		{"未删除的模型不能永久删除", http.MethodDelete, "/api/v1/models/assistant/purge", "", http.StatusNotFound, "error_code", "model_not_in_trash"},
+
+		{"创建待失效的模型", http.MethodPost, "/api/v1/models", model("stale", "失效"), http.StatusCreated, "", ""},
+		{"删除待失效的模型", http.MethodDelete, "/api/v1/models/stale", "", http.StatusOK, "", ""},
+	})
+
+	// 回收站中的配置已不再有效时恢复失败，模型仍留在回收站中
+	if err := configService.GetDBManager().GetDB().Unscoped().Model(&db.ModelConfigDB{}).Where("trashed_id = ?", "stale").Update("url", "").Error; err != nil {
+		t.Fatalf("修改回收站中的模型失败: %v", err)
+	}
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"恢复前重新验证", http.MethodPost, "/api/v1/models/stale/restore", "", http.StatusBadRequest, "error_code", "model_invalid"},
+		{"验证失败后仍在回收站", http.MethodGet, "/api/v1/models/trash", "", http.StatusOK, "data.0.id", "stale"},
+	})
+}
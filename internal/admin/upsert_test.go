@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestUpsertModel(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	login, err := adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	router := adminServer.Router()
+
+	upsert := func(id, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/models/"+id+"/upsert", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	const body = `{"name":"Assistant","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions","type":"chat","aliases":["helper"],"cache_ttl":60}`
+
+	// 模型不存在时创建，请求体可省略id
+	w := upsert("assistant", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建模型应返回201，实际得到%d %s", w.Code, w.Body.String())
+	}
+	model, exists := configService.GetModel("assistant")
+	if !exists || model.Source != config.ModelSourceAPI || model.CacheTTL != 60 {
+		t.Fatalf("创建的模型 = %+v", model)
+	}
+	created, err := configService.GetModelWithTime("assistant")
+	if err != nil {
+		t.Fatalf("读取模型失败: %v", err)
+	}
+
+	// 相同的请求重复执行时更新并返回200
+	w = upsert("assistant", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("重复执行应返回200，实际得到%d %s", w.Code, w.Body.String())
+	}
+
+	// 存在时整体替换，未传的字段恢复默认值
+	w = upsert("assistant", `{"id":"assistant","name":"Assistant v2","target":"gpt-4o-mini","url":"https://api.openai.com/v1/chat/completions","type":"chat"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("更新模型应返回200，实际得到%d %s", w.Code, w.Body.String())
+	}
+	model, _ = configService.GetModel("assistant")
+	if model.Target != "gpt-4o-mini" || model.CacheTTL != 0 || len(model.Aliases) != 0 {
+		t.Errorf("更新后的模型 = %+v", model)
+	}
+	if _, exists := configService.GetModel("helper"); exists {
+		t.Error("替换后旧别名应失效")
+	}
+	updated, err := configService.GetModelWithTime("assistant")
+	if err != nil || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("更新不应改变创建时间: %v -> %v (%v)", created.CreatedAt, updated.CreatedAt, err)
+	}
+
+	for _, tc := range []struct {
+		id, body  string
+		status    int
+		errorCode string
+	}{
+		{"assistant", `{"id":"other","name":"Other","target":"gpt-4o","url":"https://api.openai.com/v1/chat/completions"}`, http.StatusBadRequest, "model_id_mismatch"},
+		{"assistant", `{"name":"Assistant"}`, http.StatusBadRequest, "model_invalid"},
+		{"assistant", `not json`, http.StatusBadRequest, "invalid_request"},
+	} {
+		w := upsert(tc.id, tc.body)
+		var response errorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != tc.status || response.ErrorCode != tc.errorCode {
+			t.Errorf("%s: 期望%d %s，实际得到%d %s", tc.body, tc.status, tc.errorCode, w.Code, w.Body.String())
+		}
+	}
+	if model, _ := configService.GetModel("assistant"); model.Name != "Assistant v2" {
+		t.Errorf("请求无效时不应修改模型: %+v", model)
+	}
+}
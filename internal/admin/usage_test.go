@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/proxy"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// usageModelResponse 模型列表中与调用统计相关的字段
+type usageModelResponse struct {
+	ID                string `json:"id"`
+	LastUsedAt        string `json:"last_used_at"`
+	TotalRequestCount int64  `json:"total_request_count"`
+}
+
+// listUsageModels 通过管理API获取模型列表
+func listUsageModels(t *testing.T, s *AdminServer, token, query string) []usageModelResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/models"+query, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("获取模型列表失败: %d %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data struct {
+			Models []usageModelResponse `json:"models"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("解析模型列表失败: %v", err)
+	}
+	return body.Data.Models
+}
+
+func TestModelUsageAfterProxyRequest(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	content := `models:
+  - id: "used-model"
+    name: "常用模型"
+    target: "gpt-4o"
+    url: "` + upstream.URL + `"
+  - id: "idle-model"
+    name: "闲置模型"
+    target: "gpt-4o-mini"
+    url: "` + upstream.URL + `"
+`
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	serverConfig := config.DefaultServerConfig()
+	open := func() (*service.ConfigService, *AdminServer, string) {
+		configService, err := service.NewConfigService(dir)
+		if err != nil {
+			t.Fatalf("创建配置服务失败: %v", err)
+		}
+		adminServer, err := NewAdminServerWithService(configService, serverConfig)
+		if err != nil {
+			t.Fatalf("创建管理API服务器失败: %v", err)
+		}
+		login, err := adminServer.authService.Login(&service.LoginRequest{Username: "admin", Password: "password"})
+		if err != nil {
+			login, err = adminServer.authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+		}
+		if err != nil {
+			t.Fatalf("登录失败: %v", err)
+		}
+		return configService, adminServer, login.Token
+	}
+
+	configService, adminServer, token := open()
+	apiKey, err := adminServer.authService.CreateAPIKey(1, "usage", "sk-usage-test", "", nil, nil)
+	if err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+
+	recorder := service.NewUsageRecorder(configService.GetDBManager(), time.Hour)
+	proxyServer := proxy.NewServerWithService(configService, adminServer.authService.(*service.AuthService), serverConfig)
+	proxyServer.SetUsageRecorder(recorder)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"used-model","messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Proxy-Key", apiKey.KeyValue)
+	w := httptest.NewRecorder()
+	proxyServer.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("代理请求失败: %d %s", w.Code, w.Body.String())
+	}
+
+	if models := listUsageModels(t, adminServer, token, ""); len(models) != 2 || models[0].TotalRequestCount+models[1].TotalRequestCount != 0 {
+		t.Errorf("写入数据库前不应有调用统计，实际得到%+v", models)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("写入调用统计失败: %v", err)
+	}
+
+	// 统计保存在数据库中，重启后仍然存在
+	configService.Close()
+	configService, adminServer, token = open()
+	defer configService.Close()
+
+	for _, model := range listUsageModels(t, adminServer, token, "") {
+		switch model.ID {
+		case "used-model":
+			if model.TotalRequestCount != 1 || model.LastUsedAt == "" {
+				t.Errorf("used-model期望调用1次并有最后调用时间，实际得到%+v", model)
+			}
+		case "idle-model":
+			if model.TotalRequestCount != 0 || model.LastUsedAt != "" {
+				t.Errorf("idle-model不应有调用统计，实际得到%+v", model)
+			}
+		}
+	}
+
+	unused := listUsageModels(t, adminServer, token, "?unused_since=720h")
+	if len(unused) != 1 || unused[0].ID != "idle-model" {
+		t.Errorf("unused_since=720h期望只返回idle-model，实际得到%+v", unused)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/models/used-model", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	adminServer.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "最近 7 天内仍被调用过") {
+		t.Errorf("删除最近调用过的模型应返回警告，实际得到%d %s", w.Code, w.Body.String())
+	}
+}
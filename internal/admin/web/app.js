@@ -782,10 +782,24 @@ class AIPromptProxyAdmin {
         }
     }
 
+    // 列表接口默认只返回第一页，按最大每页数量依次请求全部页
+    async fetchAllPages(endpoint, key) {
+        const pageSize = 100;
+        const items = [];
+        for (let page = 1; ; page++) {
+            const separator = endpoint.includes('?') ? '&' : '?';
+            const response = await this.apiRequest(`${endpoint}${separator}page=${page}&page_size=${pageSize}`);
+            const pageItems = response.data[key] || [];
+            items.push(...pageItems);
+            if (pageItems.length < pageSize || items.length >= response.data.total) {
+                return items;
+            }
+        }
+    }
+
     async loadModels() {
         try {
-            const response = await this.apiRequest('/models');
-            this.models = response.data.models || [];
+            this.models = await this.fetchAllPages('/models', 'models');
             this.filteredModels = [...this.models];
             this.renderModels();
             this.updateTotalCount();
@@ -1001,7 +1015,8 @@ class AIPromptProxyAdmin {
             'chat': 'bg-blue-100 text-blue-800',
             'image': 'bg-green-100 text-green-800',
             'audio': 'bg-purple-100 text-purple-800',
-            'video': 'bg-orange-100 text-orange-800'
+            'video': 'bg-orange-100 text-orange-800',
+            'embedding': 'bg-teal-100 text-teal-800'
         };
         return colors[type] || 'bg-gray-100 text-gray-800';
     }
@@ -1011,7 +1026,8 @@ class AIPromptProxyAdmin {
             'chat': 'bg-gradient-to-br from-blue-400 to-blue-600',
             'image': 'bg-gradient-to-br from-green-400 to-green-600',
             'audio': 'bg-gradient-to-br from-purple-400 to-purple-600',
-            'video': 'bg-gradient-to-br from-orange-400 to-orange-600'
+            'video': 'bg-gradient-to-br from-orange-400 to-orange-600',
+            'embedding': 'bg-gradient-to-br from-teal-400 to-teal-600'
         };
         return gradients[type] || 'bg-gradient-to-br from-gray-400 to-gray-600';
     }
@@ -1021,7 +1037,8 @@ class AIPromptProxyAdmin {
             'chat': '<i class="fas fa-comments text-white"></i>',
             'image': '<i class="fas fa-image text-white"></i>',
             'audio': '<i class="fas fa-volume-up text-white"></i>',
-            'video': '<i class="fas fa-video text-white"></i>'
+            'video': '<i class="fas fa-video text-white"></i>',
+            'embedding': '<i class="fas fa-project-diagram text-white"></i>'
         };
         return icons[type] || '<i class="fas fa-cog text-white"></i>';
     }
@@ -1031,7 +1048,8 @@ class AIPromptProxyAdmin {
             'chat': '💬',
             'image': '🖼️',
             'audio': '🔊',
-            'video': '🎬'
+            'video': '🎬',
+            'embedding': '🧬'
         };
         return emojis[type] || '⚙️';
     }
@@ -1041,7 +1059,8 @@ class AIPromptProxyAdmin {
             'chat': '对话',
             'image': '图像',
             'audio': '音频',
-            'video': '视频'
+            'video': '视频',
+            'embedding': '向量'
         };
         return labels[type] || '其他';
     }
@@ -1869,8 +1888,7 @@ class AIPromptProxyAdmin {
 
     async loadUsers() {
         try {
-            const response = await this.apiRequest('/users');
-            this.users = response.data.users || [];
+            this.users = await this.fetchAllPages('/users', 'users');
             this.filteredUsers = [...this.users];
             this.updateUserCounts();
             this.renderUsers();
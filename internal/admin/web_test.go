@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestFindWebAssets(t *testing.T) {
+	embedded := fstest.MapFS{"web/index.html": {Data: []byte("embedded")}}
+	external := t.TempDir()
+	if err := os.WriteFile(filepath.Join(external, "index.html"), []byte("external"), 0644); err != nil {
+		t.Fatalf("写入index.html失败: %v", err)
+	}
+
+	if assets := findWebAssets(embedded, external); assets == nil || readIndex(t, assets) != "embedded" {
+		t.Error("应优先使用嵌入的管理页面")
+	}
+	if assets := findWebAssets(fstest.MapFS{}, external); assets == nil || readIndex(t, assets) != "external" {
+		t.Error("未嵌入管理页面时应使用外部目录")
+	}
+	if assets := findWebAssets(fstest.MapFS{}, t.TempDir()); assets != nil {
+		t.Error("两者都没有index.html时应返回nil")
+	}
+}
+
+func TestServeIndexWithoutWebAssets(t *testing.T) {
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	router := adminServer.Router()
+	adminServer.webAssets = nil
+
+	runRouteSteps(t, router, "", []routeStep{
+		{"根路径", http.MethodGet, "/", "", http.StatusOK, "data.api", "/api/v1"},
+		{"管理页面", http.MethodGet, "/admin", "", http.StatusOK, "data.api", "/api/v1"},
+		{"未知API", http.MethodGet, "/api/v1/missing", "", http.StatusNotFound, "error_code", "api_not_found"},
+	})
+}
+
+// readIndex 读取静态文件中的index.html
+func readIndex(t *testing.T, assets fs.FS) string {
+	t.Helper()
+	data, err := fs.ReadFile(assets, "index.html")
+	if err != nil {
+		t.Fatalf("读取index.html失败: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/alert"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+// newWebhookResponse 构建Webhook响应，不包含签名密钥
+func newWebhookResponse(webhook *db.Webhook) api.WebhookResponse {
+	events := []string(webhook.Events)
+	if events == nil {
+		events = []string{}
+	}
+	return api.WebhookResponse{
+		ID:        webhook.ID,
+		Name:      webhook.Name,
+		URL:       webhook.URL,
+		Events:    events,
+		IsEnabled: webhook.IsEnabled,
+		SecretSet: webhook.Secret != "",
+		CreatedAt: webhook.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: webhook.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+// webhookAvailable 检查Webhook服务是否可用，不可用时返回错误响应
+func (s *AdminServer) webhookAvailable(c *gin.Context) bool {
+	if s.webhooks == nil {
+		respondError(c, http.StatusServiceUnavailable, i18n.CodeConfigUnavailable)
+		return false
+	}
+	return true
+}
+
+// webhookID 解析路径中的Webhook ID，格式错误时返回错误响应
+func webhookID(c *gin.Context) (uint, bool) {
+	id, err := parseUint(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidWebhookID)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// getWebhooks 获取全部Webhook
+func (s *AdminServer) getWebhooks(c *gin.Context) {
+	if !s.webhookAvailable(c) {
+		return
+	}
+	webhooks, err := s.webhooks.ListWebhooks()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, i18n.CodeListWebhooksFailed, err)
+		return
+	}
+	list := api.WebhookList{Webhooks: make([]api.WebhookResponse, len(webhooks)), Total: len(webhooks)}
+	for i := range webhooks {
+		list.Webhooks[i] = newWebhookResponse(&webhooks[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    list,
+	})
+}
+
+// createWebhook 创建Webhook
+func (s *AdminServer) createWebhook(c *gin.Context) {
+	if !s.webhookAvailable(c) {
+		return
+	}
+	var req api.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	webhook, err := s.webhooks.CreateWebhook((*service.CreateWebhookRequest)(&req))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Webhook创建成功",
+		"data":    newWebhookResponse(webhook),
+	})
+}
+
+// updateWebhook 更新Webhook，未提供的字段保持不变
+func (s *AdminServer) updateWebhook(c *gin.Context) {
+	if !s.webhookAvailable(c) {
+		return
+	}
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	var req api.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, i18n.CodeInvalidRequest, err)
+		return
+	}
+	webhook, err := s.webhooks.UpdateWebhook(id, (*service.UpdateWebhookRequest)(&req))
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Webhook更新成功",
+		"data":    newWebhookResponse(webhook),
+	})
+}
+
+// deleteWebhook 删除Webhook
+func (s *AdminServer) deleteWebhook(c *gin.Context) {
+	if !s.webhookAvailable(c) {
+		return
+	}
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if err := s.webhooks.DeleteWebhook(id); err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Webhook删除成功",
+	})
+}
+
+// testWebhook 立即向Webhook发送一条测试事件，不重试；Webhook未启用或未订阅测试事件时也会发送
+func (s *AdminServer) testWebhook(c *gin.Context) {
+	if !s.webhookAvailable(c) {
+		return
+	}
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	webhook, err := s.webhooks.GetWebhook(id)
+	if err != nil {
+		respondWebhookError(c, err)
+		return
+	}
+
+	event := alert.TestEvent(webhook.ID)
+	start := time.Now()
+	if err := alert.Deliver(c.Request.Context(), s.webhookClient, webhook, event); err != nil {
+		respondError(c, http.StatusBadGateway, i18n.CodeWebhookTestFailed, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "测试告警已发送",
+		"data": api.WebhookTestResult{
+			EventID:    event.ID,
+			DurationMs: time.Since(start).Milliseconds(),
+		},
+	})
+}
+
+// respondWebhookError 返回Webhook服务的错误：不存在为404，配置无效为400，其他为500
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrInvalidWebhook):
+		respondServiceError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, i18n.CodeSaveWebhookFailed, err)
+	}
+}
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/alert"
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/signing"
+)
+
+func TestWebhookRoutes(t *testing.T) {
+	received := make(chan alert.Event, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := signing.Verify(r, "hook-secret", body, signing.DefaultTolerance, time.Now()); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event alert.Event
+		json.Unmarshal(body, &event)
+		received <- event
+	}))
+	defer receiver.Close()
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer dead.Close()
+
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := NewAdminServerWithService(configService, config.DefaultServerConfig())
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService := adminServer.authService.(*service.AuthService)
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+
+	create := fmt.Sprintf(`{"name":"oncall","url":%q,"secret":"hook-secret","events":["model.error_rate","webhook.test"]}`, receiver.URL)
+	router := adminServer.Router()
+	runRouteSteps(t, router, admin.Token, []routeStep{
+		{"创建Webhook", http.MethodPost, "/api/v1/webhooks", create, http.StatusOK, "data.secret_set", "true"},
+		{"不返回签名密钥", http.MethodGet, "/api/v1/webhooks", "", http.StatusOK, "data.webhooks.0.secret", ""},
+		{"Webhook列表", http.MethodGet, "/api/v1/webhooks", "", http.StatusOK, "data.webhooks.0.events.#", "2"},
+		{"新建的Webhook默认启用", http.MethodGet, "/api/v1/webhooks", "", http.StatusOK, "data.webhooks.0.is_enabled", "true"},
+		{"无效地址", http.MethodPost, "/api/v1/webhooks", `{"url":"ftp://example.com"}`, http.StatusBadRequest, "error_code", "webhook_invalid"},
+		{"未知事件类型", http.MethodPost, "/api/v1/webhooks", fmt.Sprintf(`{"url":%q,"events":["model.exploded"]}`, receiver.URL), http.StatusBadRequest, "error_code", "webhook_invalid"},
+		{"发送测试告警", http.MethodPost, "/api/v1/webhooks/1/test", "", http.StatusOK, "code", "0"},
+		{"更新Webhook", http.MethodPut, "/api/v1/webhooks/1", `{"is_enabled":false,"events":[]}`, http.StatusOK, "data.is_enabled", "false"},
+		{"更新后保留签名密钥", http.MethodGet, "/api/v1/webhooks", "", http.StatusOK, "data.webhooks.0.secret_set", "true"},
+		{"更新不存在的Webhook", http.MethodPut, "/api/v1/webhooks/99", `{}`, http.StatusNotFound, "error_code", "webhook_not_found"},
+		{"无效ID", http.MethodDelete, "/api/v1/webhooks/abc", "", http.StatusBadRequest, "error_code", "invalid_webhook_id"},
+		{"创建不可用的Webhook", http.MethodPost, "/api/v1/webhooks", fmt.Sprintf(`{"url":%q}`, dead.URL), http.StatusOK, "data.id", "2"},
+		{"测试不可用的Webhook", http.MethodPost, "/api/v1/webhooks/2/test", "", http.StatusBadGateway, "error_code", "webhook_test_failed"},
+		{"删除Webhook", http.MethodDelete, "/api/v1/webhooks/2", "", http.StatusOK, "", ""},
+		{"删除不存在的Webhook", http.MethodDelete, "/api/v1/webhooks/2", "", http.StatusNotFound, "error_code", "webhook_not_found"},
+	})
+
+	select {
+	case event := <-received:
+		if event.Type != alert.EventTest || event.DedupKey == "" {
+			t.Errorf("测试告警 = %+v", event)
+		}
+	default:
+		t.Error("接收端应收到带签名的测试告警")
+	}
+
+	created, err := authService.CreateUser(&service.CreateUserRequest{Username: "alice"}, admin.User.ID, service.GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	created.User.MustChangePassword = false
+	if err := configService.GetDBManager().UpdateUser(created.User); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	aliceToken, _, err := authService.GenerateToken(created.User)
+	if err != nil {
+		t.Fatalf("生成token失败: %v", err)
+	}
+	runRouteSteps(t, router, aliceToken, []routeStep{
+		{"非管理员", http.MethodGet, "/api/v1/webhooks", "", http.StatusForbidden, "error_code", "admin_required"},
+	})
+}
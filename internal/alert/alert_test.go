@@ -0,0 +1,255 @@
+package alert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/signing"
+)
+
+// receiver 记录收到的告警并验证签名的Webhook接收端
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mutex  sync.Mutex
+	events []Event
+	errors []string
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	t.Helper()
+	r := &receiver{secret: secret}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if err := signing.Verify(req, r.secret, body, signing.DefaultTolerance, time.Now()); err != nil {
+			r.errors = append(r.errors, err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil || req.Header.Get(HeaderEvent) != string(event.Type) || req.Header.Get(HeaderDelivery) != event.ID {
+			r.errors = append(r.errors, "请求体或请求头不正确: "+string(body))
+		}
+		r.events = append(r.events, event)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// received 等待收到n个告警后返回
+func (r *receiver) received(t *testing.T, n int) []Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mutex.Lock()
+		if len(r.errors) > 0 {
+			r.mutex.Unlock()
+			t.Fatalf("接收端验证失败: %v", r.errors)
+		}
+		if len(r.events) >= n {
+			events := append([]Event(nil), r.events...)
+			r.mutex.Unlock()
+			return events
+		}
+		r.mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("等待%d个告警超时", n)
+	return nil
+}
+
+func TestErrorRateSpikeDelivered(t *testing.T) {
+	spikes := newReceiver(t, "hook-secret")
+	expiry := newReceiver(t, "")
+	webhooks := []db.Webhook{
+		{ID: 1, URL: spikes.URL, Secret: "hook-secret", Events: db.StringList{string(EventErrorRate)}, IsEnabled: true},
+		{ID: 2, URL: expiry.URL, Events: db.StringList{string(EventAPIKeyExpiring)}, IsEnabled: true},
+		{ID: 3, URL: spikes.URL, IsEnabled: false},
+	}
+	dispatcher := NewDispatcher(func() ([]db.Webhook, error) { return webhooks, nil }, config.DefaultServerConfig().Alerts)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	cfg := config.AlertsConfig{ErrorRateThreshold: 0.5, ErrorRateWindow: time.Minute, ErrorRateMinRequests: 10}
+	monitor := NewErrorRateMonitor(cfg, dispatcher.Emit)
+	for i := 0; i < 10; i++ {
+		monitor.Observe("gpt", false)
+	}
+	// 错误率在第20个请求时达到50%，之后持续失败不重复告警
+	for i := 0; i < 20; i++ {
+		monitor.Observe("gpt", true)
+		monitor.Observe("other", false)
+	}
+
+	events := spikes.received(t, 1)
+	time.Sleep(50 * time.Millisecond)
+	if events = spikes.received(t, 1); len(events) != 1 {
+		t.Fatalf("期望只告警一次，实际%d次", len(events))
+	}
+	event := events[0]
+	if event.Type != EventErrorRate || event.Data["model_id"] != "gpt" || event.Data["total"] != float64(20) || event.DedupKey == "" {
+		t.Errorf("告警内容 = %+v", event)
+	}
+	expiry.mutex.Lock()
+	defer expiry.mutex.Unlock()
+	if len(expiry.events) != 0 {
+		t.Errorf("未订阅错误率事件的Webhook不应收到告警，实际%v", expiry.events)
+	}
+}
+
+func TestErrorRateMonitorWindow(t *testing.T) {
+	var fired []Event
+	cfg := config.AlertsConfig{ErrorRateThreshold: 0.5, ErrorRateWindow: time.Minute, ErrorRateMinRequests: 4}
+	monitor := NewErrorRateMonitor(cfg, func(event Event) bool {
+		fired = append(fired, event)
+		return true
+	})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		monitor.Observe("gpt", true)
+	}
+	if len(fired) != 0 {
+		t.Fatal("请求数少于error_rate_min_requests时不应告警")
+	}
+	monitor.Observe("gpt", true)
+	if len(fired) != 1 {
+		t.Fatalf("错误率达到阈值时应告警，实际%d次", len(fired))
+	}
+
+	// 窗口滑过后旧的失败不再计入，错误率恢复后再次突增时重新告警
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 4; i++ {
+		monitor.Observe("gpt", false)
+	}
+	for i := 0; i < 4; i++ {
+		monitor.Observe("gpt", true)
+	}
+	if len(fired) != 2 || fired[1].DedupKey == fired[0].DedupKey {
+		t.Errorf("恢复后再次突增应重新告警并使用新的去重键，实际%+v", fired)
+	}
+
+	if NewErrorRateMonitor(config.AlertsConfig{}, nil) != nil {
+		t.Error("阈值为0时应关闭错误率告警")
+	}
+	var disabled *ErrorRateMonitor
+	disabled.Observe("gpt", true)
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	var attempts atomic.Int32
+	deliveries := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		deliveries <- req.Header.Get(HeaderDelivery)
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultServerConfig().Alerts
+	cfg.MaxRetries = 2
+	dispatcher := NewDispatcher(func() ([]db.Webhook, error) {
+		return []db.Webhook{{ID: 1, URL: server.URL, IsEnabled: true}}, nil
+	}, cfg)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	if !dispatcher.Emit(Event{Type: EventTest, DedupKey: "retry"}) {
+		t.Fatal("事件应进入发送队列")
+	}
+	var ids []string
+	for len(ids) < 3 {
+		select {
+		case id := <-deliveries:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("期望重试到第3次成功，实际只收到%d次", len(ids))
+		}
+	}
+	if ids[0] == "" || ids[0] != ids[1] || ids[1] != ids[2] {
+		t.Errorf("重试时应使用相同的事件ID，实际%v", ids)
+	}
+}
+
+func TestDispatcherDoesNotBlockOnDeadWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultServerConfig().Alerts
+	cfg.QueueSize = 2
+	dispatcher := NewDispatcher(func() ([]db.Webhook, error) {
+		return []db.Webhook{{ID: 1, URL: server.URL, IsEnabled: true}}, nil
+	}, cfg)
+	dispatcher.Start()
+	defer dispatcher.Close()
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		dispatcher.Emit(Event{Type: EventTest})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Webhook无响应时Emit不应阻塞，耗时%s", elapsed)
+	}
+	if dispatcher.Dropped() == 0 {
+		t.Error("队列满时应丢弃事件并计数")
+	}
+}
+
+func TestExpiryChecker(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		expires := now.Add(d)
+		return &expires
+	}
+	apiKeys := []db.APIKey{
+		{ID: 1, Name: "soon", IsEnabled: true, ExpiresAt: at(60 * time.Hour), User: db.User{Username: "alice"}},
+		{ID: 2, Name: "later", IsEnabled: true, ExpiresAt: at(30 * 24 * time.Hour)},
+		{ID: 3, Name: "disabled", IsEnabled: false, ExpiresAt: at(time.Hour)},
+		{ID: 4, Name: "expired", IsEnabled: true, ExpiresAt: at(-time.Hour)},
+		{ID: 5, Name: "forever", IsEnabled: true},
+	}
+
+	dispatcher := NewDispatcher(func() ([]db.Webhook, error) { return nil, nil }, config.DefaultServerConfig().Alerts)
+	var queued []Event
+	checker := NewExpiryChecker(func() ([]db.APIKey, error) { return apiKeys, nil }, config.DefaultServerConfig().Alerts, func(event Event) bool {
+		if !dispatcher.Emit(event) {
+			return false
+		}
+		queued = append(queued, event)
+		return true
+	})
+	for i := 0; i < 2; i++ {
+		if err := checker.Check(now); err != nil {
+			t.Fatalf("检查失败: %v", err)
+		}
+	}
+
+	if len(queued) != 1 {
+		t.Fatalf("期望只告警即将过期的Key一次，实际%+v", queued)
+	}
+	if event := queued[0]; event.Type != EventAPIKeyExpiring || event.Data["api_key_id"] != uint(1) || event.Data["days_left"] != 3 || event.Data["username"] != "alice" {
+		t.Errorf("告警内容 = %+v", event)
+	}
+
+	if NewExpiryChecker(nil, config.AlertsConfig{}, nil) != nil {
+		t.Error("天数为0时应关闭过期告警")
+	}
+}
@@ -0,0 +1,223 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/signing"
+)
+
+// 告警请求头
+const (
+	HeaderEvent    = "X-Alert-Event"    // 事件类型
+	HeaderDelivery = "X-Alert-Delivery" // 事件ID，重试时不变
+)
+
+// retryBaseDelay 第一次重试前的等待时间，之后每次翻倍
+var retryBaseDelay = time.Second
+
+// maxSuppressed 记录的去重键超过该数量时清理已过抑制期的记录
+const maxSuppressed = 1024
+
+// EmitFunc 提交告警事件，返回事件是否进入发送队列
+type EmitFunc func(Event) bool
+
+// Deliver 向Webhook发送一次事件，设置了签名密钥时用与上游请求签名相同的方式签名；
+// 返回非2xx状态码时返回错误
+func Deliver(ctx context.Context, client *http.Client, webhook *db.Webhook, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化告警事件失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建告警请求失败: %w", err)
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/" // 与接收方看到的路径一致，签名基于请求路径
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderDelivery, event.ID)
+	if webhook.Secret != "" {
+		signing.SignRequest(req, webhook.Secret, body, time.Now())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Webhook返回状态码 %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return nil
+}
+
+// Dispatcher 在后台向订阅了事件类型的Webhook发送告警。Emit只把事件放入有界队列，
+// 队列满时丢弃事件，Webhook不可用不会阻塞调用方
+type Dispatcher struct {
+	webhooks   func() ([]db.Webhook, error)
+	client     *http.Client
+	maxRetries int
+	queue      chan Event
+	dropped    atomic.Int64
+
+	mutex      sync.Mutex
+	suppressed map[string]time.Time // 去重键 -> 抑制截止时间
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	started  bool
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDispatcher 创建告警发送器，webhooks返回当前全部Webhook，调用Start后开始发送
+func NewDispatcher(webhooks func() ([]db.Webhook, error), cfg config.AlertsConfig) *Dispatcher {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = config.DefaultServerConfig().Alerts.QueueSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		webhooks:   webhooks,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		maxRetries: cfg.MaxRetries,
+		queue:      make(chan Event, queueSize),
+		suppressed: make(map[string]time.Time),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start 开始后台发送
+func (d *Dispatcher) Start() {
+	d.started = true
+	go d.run()
+}
+
+// Close 停止发送，未发送的事件和进行中的重试被放弃
+func (d *Dispatcher) Close() {
+	d.stopOnce.Do(d.cancel)
+	if d.started {
+		<-d.done
+	}
+}
+
+// Emit 提交事件，补全事件ID和时间；相同去重键的事件在抑制期内或队列已满时不提交并返回false
+func (d *Dispatcher) Emit(event Event) bool {
+	if d == nil {
+		return false
+	}
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Suppress > 0 && !d.suppress(event.DedupKey, event.Suppress) {
+		return false
+	}
+
+	select {
+	case d.queue <- event:
+		return true
+	default:
+		d.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped 因队列已满丢弃的事件数
+func (d *Dispatcher) Dropped() int64 {
+	if d == nil {
+		return 0
+	}
+	return d.dropped.Load()
+}
+
+// suppress 记录去重键的抑制期，仍在抑制期内时返回false
+func (d *Dispatcher) suppress(key string, period time.Duration) bool {
+	now := time.Now()
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if until, exists := d.suppressed[key]; exists && now.Before(until) {
+		return false
+	}
+	if len(d.suppressed) >= maxSuppressed {
+		for k, until := range d.suppressed {
+			if !now.Before(until) {
+				delete(d.suppressed, k)
+			}
+		}
+	}
+	d.suppressed[key] = now.Add(period)
+	return true
+}
+
+// run 发送循环，一次处理一个事件，并发发送给订阅了该事件的全部Webhook
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case event := <-d.queue:
+			d.dispatch(event)
+		}
+	}
+}
+
+// dispatch 把事件发送给全部启用且订阅了该事件类型的Webhook
+func (d *Dispatcher) dispatch(event Event) {
+	webhooks, err := d.webhooks()
+	if err != nil {
+		fmt.Printf("获取Webhook列表失败，告警 %s 未发送: %v\n", event.DedupKey, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range webhooks {
+		webhook := &webhooks[i]
+		if !webhook.IsEnabled || !webhook.Subscribes(string(event.Type)) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.deliverWithRetry(webhook, event); err != nil {
+				fmt.Printf("发送告警 %s 到Webhook %d 失败: %v\n", event.DedupKey, webhook.ID, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deliverWithRetry 发送事件，失败后按指数退避重试，最多重试maxRetries次
+func (d *Dispatcher) deliverWithRetry(webhook *db.Webhook, event Event) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := Deliver(d.ctx, d.client, webhook, event)
+		if err == nil || attempt >= d.maxRetries {
+			return err
+		}
+		select {
+		case <-d.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
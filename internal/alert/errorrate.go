@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// rateBuckets 错误率统计窗口划分的桶数，窗口按桶滑动
+const rateBuckets = 10
+
+// rateBucket 一个时间桶内的请求数和失败数
+type rateBucket struct {
+	start  int64 // 桶的开始时间（UnixNano）
+	total  int
+	failed int
+}
+
+// rateWindow 一个模型的滑动窗口统计
+type rateWindow struct {
+	buckets  [rateBuckets]rateBucket
+	alerting bool // 错误率已达到阈值且尚未恢复，期间不重复告警
+}
+
+// ErrorRateMonitor 按模型统计滑动窗口内的请求错误率，错误率达到阈值时产生一次告警，
+// 降到阈值以下后再次达到阈值时重新告警
+type ErrorRateMonitor struct {
+	threshold   float64
+	window      time.Duration
+	minRequests int
+	emit        EmitFunc
+	now         func() time.Time
+
+	mutex  sync.Mutex
+	models map[string]*rateWindow
+}
+
+// NewErrorRateMonitor 创建错误率监控，未开启错误率告警（阈值为0）时返回nil，nil监控的Observe不做任何事
+func NewErrorRateMonitor(cfg config.AlertsConfig, emit EmitFunc) *ErrorRateMonitor {
+	if cfg.ErrorRateThreshold <= 0 || cfg.ErrorRateWindow <= 0 {
+		return nil
+	}
+	return &ErrorRateMonitor{
+		threshold:   cfg.ErrorRateThreshold,
+		window:      cfg.ErrorRateWindow,
+		minRequests: cfg.ErrorRateMinRequests,
+		emit:        emit,
+		now:         time.Now,
+		models:      make(map[string]*rateWindow),
+	}
+}
+
+// Observe 记录模型的一次请求结果
+func (m *ErrorRateMonitor) Observe(modelID string, failed bool) {
+	if m == nil {
+		return
+	}
+	now := m.now()
+	width := int64(m.window / rateBuckets)
+	if width <= 0 {
+		width = 1
+	}
+	start := now.UnixNano() / width * width
+
+	m.mutex.Lock()
+	window, exists := m.models[modelID]
+	if !exists {
+		window = &rateWindow{}
+		m.models[modelID] = window
+	}
+	bucket := &window.buckets[start/width%rateBuckets]
+	if bucket.start != start {
+		*bucket = rateBucket{start: start}
+	}
+	bucket.total++
+	if failed {
+		bucket.failed++
+	}
+
+	var total, failures int
+	cutoff := start - int64(m.window)
+	for _, b := range window.buckets {
+		if b.start > cutoff {
+			total += b.total
+			failures += b.failed
+		}
+	}
+	if total == 0 || total < m.minRequests {
+		m.mutex.Unlock()
+		return
+	}
+	rate := float64(failures) / float64(total)
+	fire := rate >= m.threshold && !window.alerting
+	window.alerting = rate >= m.threshold
+	m.mutex.Unlock()
+
+	if fire {
+		m.emit(Event{
+			Type:      EventErrorRate,
+			DedupKey:  fmt.Sprintf("%s:%s:%d", EventErrorRate, modelID, time.Unix(0, start).Unix()),
+			Timestamp: now,
+			Message:   fmt.Sprintf("模型 %s 最近%s的错误率为%.1f%%（%d/%d），达到告警阈值%.1f%%", modelID, m.window, rate*100, failures, total, m.threshold*100),
+			Data: map[string]interface{}{
+				"model_id":   modelID,
+				"error_rate": rate,
+				"failed":     failures,
+				"total":      total,
+				"window":     m.window.String(),
+				"threshold":  m.threshold,
+			},
+		})
+	}
+}
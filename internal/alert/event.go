@@ -0,0 +1,95 @@
+// Package alert 在模型错误率突增、API Key即将过期和上游健康检查结果变化时向Webhook发送告警
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
+)
+
+// EventType 告警事件类型，Webhook按类型订阅
+type EventType string
+
+const (
+	EventErrorRate      EventType = "model.error_rate"     // 模型在统计窗口内的错误率达到阈值
+	EventAPIKeyExpiring EventType = "apikey.expiring"      // API Key即将过期
+	EventHealthChanged  EventType = "model.health_changed" // 上游健康检查结果变化
+	EventTest           EventType = "webhook.test"         // 管理API发送的测试事件
+)
+
+// EventTypes 所有事件类型
+var EventTypes = []EventType{EventErrorRate, EventAPIKeyExpiring, EventHealthChanged, EventTest}
+
+// ValidEventType 是否为支持的事件类型
+func ValidEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if string(known) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event 发送给Webhook的告警事件，序列化后作为请求体
+type Event struct {
+	ID        string                 `json:"id"`        // 事件ID，重试时不变
+	Type      EventType              `json:"type"`      // 事件类型
+	DedupKey  string                 `json:"dedup_key"` // 去重键，同一告警重复产生的事件使用相同的值，接收方可据此合并
+	Timestamp time.Time              `json:"timestamp"` // 事件产生时间
+	Message   string                 `json:"message"`   // 告警信息
+	Data      map[string]interface{} `json:"data"`      // 事件详情，字段随事件类型不同
+
+	// Suppress 相同去重键的事件在该时长内只发送一次，0表示不抑制
+	Suppress time.Duration `json:"-"`
+}
+
+// newEventID 生成事件ID
+func newEventID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// TestEvent 生成管理API测试Webhook时发送的事件
+func TestEvent(webhookID uint) Event {
+	now := time.Now()
+	return Event{
+		ID:        newEventID(),
+		Type:      EventTest,
+		DedupKey:  fmt.Sprintf("%s:%d:%d", EventTest, webhookID, now.Unix()),
+		Timestamp: now,
+		Message:   "这是一条测试告警",
+		Data:      map[string]interface{}{"webhook_id": webhookID},
+	}
+}
+
+// HealthEvent 生成模型上游健康检查结果变化的事件
+func HealthEvent(status healthcheck.ModelStatus) Event {
+	state, message := "healthy", fmt.Sprintf("模型 %s 的上游已恢复", status.ModelID)
+	if !status.Healthy {
+		state, message = "unhealthy", fmt.Sprintf("模型 %s 的上游健康检查失败", status.ModelID)
+	}
+	var errors []string
+	for _, upstream := range status.Upstreams {
+		if upstream.Error != "" {
+			errors = append(errors, fmt.Sprintf("%s: %s", upstream.URL, upstream.Error))
+		}
+	}
+	return Event{
+		Type:      EventHealthChanged,
+		DedupKey:  fmt.Sprintf("%s:%s:%s:%d", EventHealthChanged, status.ModelID, state, status.CheckedAt.Unix()),
+		Timestamp: status.CheckedAt,
+		Message:   message,
+		Data: map[string]interface{}{
+			"model_id": status.ModelID,
+			"healthy":  status.Healthy,
+			"method":   status.Method,
+			"errors":   errors,
+		},
+	}
+}
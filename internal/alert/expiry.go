@@ -0,0 +1,113 @@
+package alert
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// expiryReminderInterval 同一个即将过期的API Key最多每隔多久告警一次
+const expiryReminderInterval = 24 * time.Hour
+
+// ExpiryChecker 定期检查即将过期的API Key并产生告警
+type ExpiryChecker struct {
+	apiKeys  func() ([]db.APIKey, error)
+	within   time.Duration
+	interval time.Duration
+	emit     EmitFunc
+
+	started  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewExpiryChecker 创建过期检查器，apiKeys返回全部API Key；未开启过期告警（天数为0）时返回nil
+func NewExpiryChecker(apiKeys func() ([]db.APIKey, error), cfg config.AlertsConfig, emit EmitFunc) *ExpiryChecker {
+	if cfg.KeyExpiryDays <= 0 || cfg.CheckInterval <= 0 {
+		return nil
+	}
+	return &ExpiryChecker{
+		apiKeys:  apiKeys,
+		within:   time.Duration(cfg.KeyExpiryDays) * 24 * time.Hour,
+		interval: cfg.CheckInterval,
+		emit:     emit,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 立即检查一次，之后按检查间隔定期检查
+func (c *ExpiryChecker) Start() {
+	if c == nil {
+		return
+	}
+	c.started = true
+	go c.run()
+}
+
+// Close 停止定期检查
+func (c *ExpiryChecker) Close() {
+	if c == nil {
+		return
+	}
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	if c.started {
+		<-c.done
+	}
+}
+
+// run 检查循环
+func (c *ExpiryChecker) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Check(time.Now()); err != nil {
+			fmt.Printf("检查即将过期的API Key失败: %v\n", err)
+		}
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check 为启用且在检查范围内过期的API Key产生告警，同一个Key每天最多告警一次
+func (c *ExpiryChecker) Check(now time.Time) error {
+	apiKeys, err := c.apiKeys()
+	if err != nil {
+		return err
+	}
+	for _, apiKey := range apiKeys {
+		if !apiKey.IsEnabled || apiKey.ExpiresAt == nil || !apiKey.ExpiresAt.After(now) || apiKey.ExpiresAt.Sub(now) > c.within {
+			continue
+		}
+		daysLeft := int(math.Ceil(apiKey.ExpiresAt.Sub(now).Hours() / 24))
+		c.emit(Event{
+			Type:      EventAPIKeyExpiring,
+			DedupKey:  fmt.Sprintf("%s:%d:%d", EventAPIKeyExpiring, apiKey.ID, apiKey.ExpiresAt.Unix()),
+			Timestamp: now,
+			Message:   fmt.Sprintf("用户 %s 的API Key %s 将在%d天内过期", apiKey.User.Username, apiKey.Name, daysLeft),
+			Data: map[string]interface{}{
+				"api_key_id": apiKey.ID,
+				"name":       apiKey.Name,
+				"key_prefix": apiKey.KeyPrefix,
+				"user_id":    apiKey.UserID,
+				"username":   apiKey.User.Username,
+				"expires_at": apiKey.ExpiresAt,
+				"days_left":  daysLeft,
+			},
+			Suppress: expiryReminderInterval,
+		})
+	}
+	return nil
+}
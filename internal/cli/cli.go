@@ -0,0 +1,193 @@
+// Package cli 实现不启动HTTP服务器、直接通过服务层操作数据库的运维子命令，
+// 用于管理后台无法访问（忘记密码、前端构建损坏等）时管理实例
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gorm.io/gorm/logger"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// command 子命令，args为去掉命令名和参数后的位置参数
+type command struct {
+	usage string
+	args  int // 位置参数个数
+	run   func(e *Env, fs *options, args []string) error
+}
+
+// commands 按"分组 子命令"索引的全部子命令
+var commands = map[string]command{
+	"user reset-password": {"user reset-password <username>", 1, func(e *Env, _ *options, args []string) error { return e.ResetPassword(args[0]) }},
+	"user list":           {"user list", 0, func(e *Env, _ *options, _ []string) error { return e.ListUsers() }},
+	"apikey list":         {"apikey list --user <username>", 0, func(e *Env, o *options, _ []string) error { return e.ListAPIKeys(o.user) }},
+	"model list":          {"model list", 0, func(e *Env, _ *options, _ []string) error { return e.ListModels() }},
+	"model import":        {"model import <file>", 1, func(e *Env, _ *options, args []string) error { return e.ImportModels(args[0]) }},
+	"model seed":          {"model seed [--catalog <file>]", 0, func(e *Env, o *options, _ []string) error { return e.SeedModels(o.catalog) }},
+	"config backup":       {"config backup <dir>", 1, func(e *Env, _ *options, args []string) error { return e.BackupConfig(args[0]) }},
+}
+
+// IsCommand 判断命令行第一个参数是否为子命令分组
+func IsCommand(name string) bool {
+	for key := range commands {
+		if group, _, _ := strings.Cut(key, " "); group == name {
+			return true
+		}
+	}
+	return false
+}
+
+// options 子命令的公共参数
+type options struct {
+	configFile string
+	configDir  string
+	dbDSN      string
+	json       bool
+	force      bool
+	user       string
+	catalog    string
+}
+
+// Env 子命令的运行环境
+type Env struct {
+	Config *service.ConfigService
+	Auth   *service.AuthService
+	Out    io.Writer
+	JSON   bool // 以JSON而不是表格输出
+}
+
+// Run 执行args指定的子命令（不包括程序名），如 user list --config ./configs
+func Run(args []string, stdout io.Writer) error {
+	if len(args) < 2 {
+		return usageError()
+	}
+	name := args[0] + " " + args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		return usageError()
+	}
+
+	opts := &options{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.configFile, "config-file", "", "服务器配置文件路径(server.yaml)")
+	fs.StringVar(&opts.configDir, "config", "./configs", "配置文件目录")
+	fs.StringVar(&opts.dbDSN, "db-dsn", "", "数据库连接串，为空时使用配置目录下的SQLite数据库")
+	fs.BoolVar(&opts.json, "json", false, "以JSON格式输出")
+	fs.BoolVar(&opts.force, "force", false, "服务器正在运行时仍然执行")
+	if name == "apikey list" {
+		fs.StringVar(&opts.user, "user", "", "API Key所属的用户名")
+	}
+	if name == "model seed" {
+		fs.StringVar(&opts.catalog, "catalog", "", "模型目录文件，格式与模型配置文件相同，为空时使用内置的默认目录")
+	}
+	positional, err := parseInterspersed(fs, args[2:])
+	if err != nil {
+		return fmt.Errorf("%s: %w\n用法: %s", name, err, cmd.usage)
+	}
+	if len(positional) != cmd.args || (name == "apikey list" && opts.user == "") {
+		return fmt.Errorf("参数错误\n用法: %s", cmd.usage)
+	}
+
+	env, err := open(fs, opts, stdout)
+	if err != nil {
+		return err
+	}
+	defer env.Close()
+	return cmd.run(env, opts, positional)
+}
+
+// usageError 返回列出全部子命令的用法说明
+func usageError() error {
+	usages := make([]string, 0, len(commands))
+	for _, key := range sortedKeys(commands) {
+		usages = append(usages, "  "+commands[key].usage)
+	}
+	return fmt.Errorf("未知的子命令，可用的子命令:\n%s\n公共参数: --config <dir> --config-file <file> --db-dsn <dsn> --json --force", strings.Join(usages, "\n"))
+}
+
+// parseInterspersed 解析参数，允许参数出现在位置参数之后，返回位置参数
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// open 按与服务器相同的方式加载服务器配置并创建服务；服务器正在使用数据库时除非指定--force否则返回错误
+func open(fs *flag.FlagSet, opts *options, stdout io.Writer) (*Env, error) {
+	configFile := opts.configFile
+	if configFile == "" {
+		configFile = os.Getenv(config.EnvServerConfigFile)
+	}
+	serverConfig, err := config.LoadServerConfig(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载服务器配置失败: %w", err)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "config":
+			serverConfig.ConfigDir = opts.configDir
+		case "db-dsn":
+			serverConfig.Database.DSN = opts.dbDSN
+		}
+	})
+	if err := serverConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := service.CheckServerLock(service.ServerLockPath(serverConfig.ConfigDir, serverConfig.Database)); err != nil {
+		if !opts.force || !errors.Is(err, service.ErrServerRunning) {
+			return nil, fmt.Errorf("%w，请先停止服务器或使用--force", err)
+		}
+	}
+
+	db.LogLevel = logger.Silent
+	configService, err := service.NewConfigServiceWithDatabase(serverConfig.ConfigDir, serverConfig.Database, serverConfig.Drift.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("创建配置服务失败: %w", err)
+	}
+	authService, err := service.NewAuthService(configService.GetDBManager())
+	if err != nil {
+		configService.Close()
+		return nil, fmt.Errorf("创建认证服务失败: %w", err)
+	}
+	return &Env{Config: configService, Auth: authService, Out: stdout, JSON: opts.json}, nil
+}
+
+// Close 关闭数据库连接
+func (e *Env) Close() error {
+	return e.Config.Close()
+}
+
+// print 以JSON输出value，或以表格输出header和rows
+func (e *Env) print(value interface{}, header []string, rows [][]string) error {
+	if e.JSON {
+		encoder := json.NewEncoder(e.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	w := tabwriter.NewWriter(e.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+const importModels = `models:
+  - id: "imported"
+    name: "导入模型"
+    target: "gpt-4o-mini"
+    url: "https://api.openai.com/v1/chat/completions"
+    type: "chat"
+    prompt_path: "messages"
+    prompt_type: "object"
+    prompt_value:
+      role: "system"
+      content: "你是一个助手"
+    request_headers:
+      api-key: "upstream-secret"
+    signing_secret: "signing-secret"
+`
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	authService, err := service.NewAuthService(configService.GetDBManager())
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	if _, err := authService.CreateUser(&service.CreateUserRequest{Username: "alice", AutoCreateKey: true}, admin.User.ID, service.GlobalScope); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	configService.Close()
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := Run(append(args, "--config", dir), &out); err != nil {
+			t.Fatalf("%s: %v", strings.Join(args, " "), err)
+		}
+		return out.String()
+	}
+
+	if out := run("user", "list", "--json"); gjson.Get(out, "#").Int() != 2 || gjson.Get(out, `#(username=="alice").role`).String() != string(db.RoleViewer) {
+		t.Errorf("user list = %s", out)
+	}
+	if out := run("user", "list"); !strings.HasPrefix(out, "ID") || !strings.Contains(out, "alice") {
+		t.Errorf("user list 表格输出 = %s", out)
+	}
+
+	password := gjson.Get(run("user", "reset-password", "alice", "--json"), "password").String()
+	if password == "" {
+		t.Fatal("reset-password应输出临时密码")
+	}
+
+	out := run("apikey", "list", "--user", "alice", "--json")
+	if gjson.Get(out, "#").Int() != 1 || gjson.Get(out, "0.name").String() != service.DefaultAPIKeyName {
+		t.Errorf("apikey list = %s", out)
+	}
+
+	importFile := filepath.Join(t.TempDir(), "models.yaml")
+	if err := os.WriteFile(importFile, []byte(importModels), 0644); err != nil {
+		t.Fatalf("写入导入文件失败: %v", err)
+	}
+	if out := run("model", "import", importFile, "--json"); gjson.Get(out, "0.action").String() != "created" {
+		t.Errorf("model import = %s", out)
+	}
+	if out := run("model", "import", importFile, "--json"); gjson.Get(out, "0.action").String() != "updated" {
+		t.Errorf("再次导入 = %s", out)
+	}
+	out = run("model", "list", "--json")
+	if gjson.Get(out, "0.id").String() != "imported" || !gjson.Get(out, "0.signing_enabled").Bool() || gjson.Get(out, "0.request_headers.0").String() != "api-key" {
+		t.Errorf("model list = %s", out)
+	}
+	if strings.Contains(out, "upstream-secret") || strings.Contains(out, "signing-secret") {
+		t.Errorf("model list不应输出签名密钥和请求头的值: %s", out)
+	}
+	if out := run("model", "seed", "--catalog", importFile, "--json"); gjson.Get(out, "created.#").Int() != 0 || gjson.Get(out, "skipped.0").String() != "imported" {
+		t.Errorf("model seed不应覆盖已存在的模型: %s", out)
+	}
+	seeded := gjson.Get(run("model", "seed", "--json"), "created.#").Int()
+	if out := run("model", "seed", "--json"); seeded == 0 || gjson.Get(out, "created.#").Int() != 0 || gjson.Get(out, "skipped.#").Int() != seeded {
+		t.Errorf("重复导入默认模型目录不应创建新模型: %s", out)
+	}
+
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	backup, err := config.LoadConfigFile(gjson.Get(run("config", "backup", backupDir, "--json"), "path").String())
+	if err != nil {
+		t.Fatalf("加载备份文件失败: %v", err)
+	}
+	if _, ok := backup.Models["imported"]; !ok {
+		t.Errorf("备份文件应包含导入的模型，实际%v", backup.Models)
+	}
+
+	// 重置后的密码是临时密码，登录后需修改
+	configService, err = service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	authService, err = service.NewAuthService(configService.GetDBManager())
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	login, err := authService.Login(&service.LoginRequest{Username: "alice", Password: password})
+	if err != nil {
+		t.Fatalf("使用重置后的密码登录失败: %v", err)
+	}
+	if !login.User.MustChangePassword {
+		t.Error("重置后的密码应要求修改")
+	}
+}
+
+func TestCommandsRefuseWhileServerRunning(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	if err := Run([]string{"model", "list", "--config", dir}, &out); err != nil {
+		t.Fatalf("model list: %v", err)
+	}
+
+	lock, err := service.AcquireServerLock(service.ServerLockPath(dir, config.DatabaseConfig{}))
+	if err != nil {
+		t.Fatalf("写入服务器锁文件失败: %v", err)
+	}
+	if err := Run([]string{"model", "list", "--config", dir}, &out); !errors.Is(err, service.ErrServerRunning) {
+		t.Errorf("服务器运行时期望ErrServerRunning，实际%v", err)
+	}
+	if err := Run([]string{"model", "list", "--config", dir, "--force"}, &out); err != nil {
+		t.Errorf("--force时应执行，实际%v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("删除服务器锁文件失败: %v", err)
+	}
+	if err := Run([]string{"model", "list", "--config", dir}, &out); err != nil {
+		t.Errorf("服务器停止后应执行，实际%v", err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var out bytes.Buffer
+	if err := Run([]string{"user", "remove"}, &out); err == nil || !strings.Contains(err.Error(), "user reset-password <username>") {
+		t.Errorf("未知子命令应列出用法，实际%v", err)
+	}
+	if err := Run([]string{"apikey", "list"}, &out); err == nil || !strings.Contains(err.Error(), "--user") {
+		t.Errorf("缺少--user应返回用法，实际%v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// ResetPassword 将用户密码重置为随机生成的临时密码并解除账户锁定，用户登录后需修改密码
+func (e *Env) ResetPassword(username string) error {
+	user, err := e.Config.GetDBManager().GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("%w: %s", service.ErrUserNotFound, username)
+	}
+	password := e.Auth.GenerateRandomPassword()
+	if err := e.Auth.AdminChangePassword(user.ID, &service.AdminChangePasswordRequest{NewPassword: password}); err != nil {
+		return fmt.Errorf("重置密码失败: %w", err)
+	}
+	if err := e.Auth.UnlockUser(user.ID); err != nil {
+		return fmt.Errorf("解除账户锁定失败: %w", err)
+	}
+
+	result := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{user.Username, password}
+	return e.print(result, []string{"USERNAME", "TEMPORARY_PASSWORD"}, [][]string{{result.Username, result.Password}})
+}
+
+// ListUsers 列出全部用户，包括管理员
+func (e *Env) ListUsers() error {
+	users, err := e.Auth.SearchUsers(service.GlobalScope, "", db.UserQuery{IncludeAdmins: true})
+	if err != nil {
+		return fmt.Errorf("获取用户列表失败: %w", err)
+	}
+	rows := make([][]string, 0, len(users.Users))
+	for _, user := range users.Users {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(user.ID), 10), user.Username, string(user.Role),
+			strconv.FormatBool(user.IsEnabled), formatTime(user.LockedUntil), formatTime(user.LastLoginAt),
+		})
+	}
+	return e.print(users.Users, []string{"ID", "USERNAME", "ROLE", "ENABLED", "LOCKED_UNTIL", "LAST_LOGIN"}, rows)
+}
+
+// ListAPIKeys 列出用户的API Key，只输出Key的前缀
+func (e *Env) ListAPIKeys(username string) error {
+	user, err := e.Config.GetDBManager().GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("%w: %s", service.ErrUserNotFound, username)
+	}
+	apiKeys, err := e.Auth.GetAPIKeysByUserID(user.ID, nil)
+	if err != nil {
+		return fmt.Errorf("获取API Key列表失败: %w", err)
+	}
+	rows := make([][]string, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(apiKey.ID), 10), apiKey.Name, apiKey.KeyPrefix + "...",
+			strconv.FormatBool(apiKey.IsEnabled), formatTime(apiKey.ExpiresAt), formatTime(apiKey.LastUsedAt),
+		})
+	}
+	return e.print(apiKeys, []string{"ID", "NAME", "KEY", "ENABLED", "EXPIRES_AT", "LAST_USED"}, rows)
+}
+
+// modelSummary 模型列表的输出，不包含签名密钥和请求头的值等敏感信息
+type modelSummary struct {
+	ID             string             `json:"id"`
+	Name           string             `json:"name"`
+	Type           config.ModelType   `json:"type"`
+	Target         string             `json:"target"`
+	Url            string             `json:"url"`
+	Enabled        bool               `json:"enabled"`
+	Tenant         string             `json:"tenant"`
+	Source         config.ModelSource `json:"source"`
+	SigningEnabled bool               `json:"signing_enabled"`
+	RequestHeaders []string           `json:"request_headers"` // 只输出请求头名称
+}
+
+// ListModels 按模型ID顺序列出全部模型配置，签名密钥和附加请求头的值不输出
+func (e *Env) ListModels() error {
+	models := e.Config.GetConfig().SnapshotModels()
+	summaries := make([]modelSummary, 0, len(models))
+	rows := make([][]string, 0, len(models))
+	for _, id := range sortedKeys(models) {
+		model := models[id]
+		summaries = append(summaries, modelSummary{
+			ID: model.ID, Name: model.Name, Type: model.Type, Target: model.Target, Url: model.Url,
+			Enabled: !model.Disabled, Tenant: config.TenantOrDefault(model.Tenant), Source: model.Source,
+			SigningEnabled: model.SigningSecret != "", RequestHeaders: sortedKeys(model.RequestHeaders),
+		})
+		rows = append(rows, []string{
+			model.ID, string(model.Type), model.Target, model.Url, strconv.FormatBool(!model.Disabled),
+		})
+	}
+	return e.print(summaries, []string{"ID", "TYPE", "TARGET", "URL", "ENABLED"}, rows)
+}
+
+// ImportModels 从与模型配置文件格式相同的YAML文件导入模型，已存在的模型被覆盖；
+// 文件中全部模型通过验证后才开始导入
+func (e *Env) ImportModels(file string) error {
+	imported, err := config.LoadConfigFile(file)
+	if err != nil {
+		return err
+	}
+	existing := e.Config.GetConfig().SnapshotModels()
+
+	type result struct {
+		ID     string `json:"id"`
+		Action string `json:"action"` // created或updated
+	}
+	results := make([]result, 0, len(imported.Models))
+	rows := make([][]string, 0, len(imported.Models))
+	for _, id := range sortedKeys(imported.Models) {
+		action := "created"
+		if _, ok := existing[id]; ok {
+			action = "updated"
+		}
+		if err := e.Config.SaveModel(imported.Models[id]); err != nil {
+			return fmt.Errorf("导入模型 %s 失败: %w", id, err)
+		}
+		results = append(results, result{id, action})
+		rows = append(rows, []string{id, action})
+	}
+	return e.print(results, []string{"ID", "ACTION"}, rows)
+}
+
+// SeedModels 导入模型目录中尚不存在的模型，已存在的模型ID被跳过；file为空时导入内置的默认模型目录
+func (e *Env) SeedModels(file string) error {
+	catalog := service.DefaultCatalog()
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("读取模型目录失败: %w", err)
+		}
+		catalog = data
+	}
+	result, err := e.Config.SeedModels(catalog)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(result.Created)+len(result.Skipped))
+	for _, id := range result.Created {
+		rows = append(rows, []string{id, "created"})
+	}
+	for _, id := range result.Skipped {
+		rows = append(rows, []string{id, "skipped"})
+	}
+	return e.print(result, []string{"ID", "ACTION"}, rows)
+}
+
+// BackupConfig 将全部模型配置备份到dir下带时间戳的文件，该文件可用管理API恢复或用model import导入
+func (e *Env) BackupConfig(dir string) error {
+	path, err := e.Config.BackupFile(dir)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		Path   string `json:"path"`
+		Models int    `json:"models"`
+	}{path, e.Config.GetConfig().ModelCount()}
+	return e.print(result, []string{"PATH", "MODELS"}, [][]string{{result.Path, strconv.Itoa(result.Models)}})
+}
+
+// formatTime 格式化表格中的可选时间，为空时输出-
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// sortedKeys 返回按字典序排列的map键
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
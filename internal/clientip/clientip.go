@@ -0,0 +1,50 @@
+// Package clientip 统一代理服务和管理服务获取客户端IP的方式。
+//
+// 只有直连对端（socket地址）属于可信代理时才采信转发请求头：先从右向左遍历X-Forwarded-For，
+// 跳过可信代理，取第一个不可信的地址（全部可信时取最左侧的地址）；X-Forwarded-For缺失或
+// 包含无效地址时使用X-Real-IP；都不可用时使用连接地址。不可信的对端发送的转发请求头一律忽略，
+// 防止客户端伪造IP。
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 按顺序采信的转发请求头
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+	HeaderRealIP       = "X-Real-IP"
+)
+
+// Configure 设置路由器的可信代理，之后c.ClientIP()按包文档描述的规则获取客户端IP。
+// trusted为IP或CIDR列表，为空时不采信任何转发请求头；包含无效条目时同样不采信并返回错误
+func Configure(engine *gin.Engine, trusted []string) error {
+	engine.ForwardedByClientIP = true
+	engine.RemoteIPHeaders = []string{HeaderForwardedFor, HeaderRealIP}
+	engine.TrustedPlatform = ""
+	if trusted == nil {
+		trusted = []string{}
+	}
+	if err := engine.SetTrustedProxies(trusted); err != nil {
+		_ = engine.SetTrustedProxies([]string{})
+		return fmt.Errorf("可信代理配置无效: %w", err)
+	}
+	return nil
+}
+
+// RemoteAddr 返回直连对端的IP（不含端口），即$remote_addr；无法解析时返回原始地址
+func RemoteAddr(c *gin.Context) string {
+	addr := strings.TrimSpace(c.Request.RemoteAddr)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = strings.Trim(addr, "[]")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return addr
+}
@@ -0,0 +1,163 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 创建按trusted配置可信代理的路由器，响应头返回解析出的客户端IP和对端地址
+func newTestRouter(t *testing.T, trusted []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := Configure(r, trusted); err != nil {
+		t.Fatalf("配置可信代理失败: %v", err)
+	}
+	r.GET("/", func(c *gin.Context) {
+		c.Header("X-Client-IP", c.ClientIP())
+		c.Header("X-Remote-Addr", RemoteAddr(c))
+	})
+	return r
+}
+
+func TestClientIP(t *testing.T) {
+	r := newTestRouter(t, []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		wantClient   string
+		wantRemote   string
+	}{
+		{
+			name:       "无转发请求头时使用连接地址",
+			remoteAddr: "203.0.113.7:5000",
+			wantClient: "203.0.113.7",
+			wantRemote: "203.0.113.7",
+		},
+		{
+			name:         "不可信对端伪造的请求头被忽略",
+			remoteAddr:   "203.0.113.7:5000",
+			forwardedFor: "1.2.3.4",
+			realIP:       "5.6.7.8",
+			wantClient:   "203.0.113.7",
+			wantRemote:   "203.0.113.7",
+		},
+		{
+			name:         "可信对端的单跳X-Forwarded-For",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: "198.51.100.9",
+			wantClient:   "198.51.100.9",
+			wantRemote:   "10.0.0.2",
+		},
+		{
+			name:         "多跳时跳过可信代理",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: "198.51.100.9, 192.168.1.1, 10.0.0.3",
+			wantClient:   "198.51.100.9",
+			wantRemote:   "10.0.0.2",
+		},
+		{
+			name:         "客户端在最左侧伪造的地址被忽略",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: "6.6.6.6, 198.51.100.9, 10.0.0.3",
+			wantClient:   "198.51.100.9",
+			wantRemote:   "10.0.0.2",
+		},
+		{
+			name:         "全部为可信代理时取最左侧地址",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: "10.0.0.4, 10.0.0.3",
+			wantClient:   "10.0.0.4",
+			wantRemote:   "10.0.0.2",
+		},
+		{
+			name:       "可信对端的X-Real-IP",
+			remoteAddr: "10.0.0.2:5000",
+			realIP:     "198.51.100.10",
+			wantClient: "198.51.100.10",
+			wantRemote: "10.0.0.2",
+		},
+		{
+			name:         "X-Forwarded-For包含无效地址时使用X-Real-IP",
+			remoteAddr:   "10.0.0.2:5000",
+			forwardedFor: "garbage, 10.0.0.3",
+			realIP:       "198.51.100.10",
+			wantClient:   "198.51.100.10",
+			wantRemote:   "10.0.0.2",
+		},
+		{
+			name:         "可信的IPv6对端",
+			remoteAddr:   "[fd00::1]:443",
+			forwardedFor: "2001:db8::1, fd00::2",
+			wantClient:   "2001:db8::1",
+			wantRemote:   "fd00::1",
+		},
+		{
+			name:         "不可信的IPv6对端",
+			remoteAddr:   "[2001:db8::2]:443",
+			forwardedFor: "198.51.100.9",
+			wantClient:   "2001:db8::2",
+			wantRemote:   "2001:db8::2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set(HeaderForwardedFor, tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(HeaderRealIP, tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got := w.Header().Get("X-Client-IP"); got != tt.wantClient {
+				t.Errorf("client_ip = %q, want %q", got, tt.wantClient)
+			}
+			if got := w.Header().Get("X-Remote-Addr"); got != tt.wantRemote {
+				t.Errorf("remote_addr = %q, want %q", got, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestConfigureWithoutTrustedProxies(t *testing.T) {
+	for _, trusted := range [][]string{nil, {}} {
+		r := newTestRouter(t, trusted)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "127.0.0.1:5000"
+		req.Header.Set(HeaderForwardedFor, "1.2.3.4")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get("X-Client-IP"); got != "127.0.0.1" {
+			t.Errorf("trusted=%v: client_ip = %q, want 127.0.0.1", trusted, got)
+		}
+	}
+}
+
+func TestConfigureInvalidEntry(t *testing.T) {
+	r := gin.New()
+	if err := Configure(r, []string{"127.0.0.1", "not-an-ip"}); err == nil {
+		t.Fatal("期望无效条目返回错误")
+	}
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set(HeaderForwardedFor, "1.2.3.4")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "127.0.0.1" {
+		t.Errorf("配置无效时不应采信转发请求头, client_ip = %q", w.Body.String())
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// buildModelIndex 建立别名（忽略大小写时还包括小写的模型ID）到模型ID的索引，
+// 同一名称对应多个模型时返回错误，冲突的名称保留ID排序靠前的模型
+func buildModelIndex(models map[string]*ModelConfig, caseInsensitive bool) (map[string]string, error) {
+	ids := make([]string, 0, len(models))
+	for id := range models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	key := func(name string) string {
+		if caseInsensitive {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+
+	index := make(map[string]string)
+	var conflicts []string
+	claim := func(name, modelID string) {
+		if owner, exists := index[key(name)]; exists && owner != modelID {
+			conflicts = append(conflicts, fmt.Sprintf("%s 同时属于模型 %s 和 %s", name, owner, modelID))
+			return
+		}
+		index[key(name)] = modelID
+	}
+
+	// 先登记模型ID，别名不能与其他模型的ID相同
+	for _, id := range ids {
+		claim(id, id)
+	}
+	for _, id := range ids {
+		for _, alias := range models[id].Aliases {
+			claim(alias, id)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return index, fmt.Errorf("模型别名冲突: %s", strings.Join(conflicts, "; "))
+	}
+	return index, nil
+}
+
+// ModelIDLocation 请求中读取模型ID的位置
+type ModelIDLocation struct {
+	Source ModelIDSource
+	Key    string
+}
+
+// buildModelIDLocations 按模型ID顺序收集各模型配置的模型ID来源，相同的来源和键只保留一个
+func buildModelIDLocations(models map[string]*ModelConfig) []ModelIDLocation {
+	ids := make([]string, 0, len(models))
+	for id := range models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var locations []ModelIDLocation
+	seen := make(map[ModelIDLocation]bool)
+	for _, id := range ids {
+		location := ModelIDLocation{Source: models[id].ModelIDSource, Key: models[id].ModelIDKey}
+		if location.Source == "" || seen[location] {
+			continue
+		}
+		seen[location] = true
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// ModelIDLocations 返回各模型配置的模型ID来源，按首个使用该来源的模型ID排序且不重复；
+// 返回的切片在重建索引时整体替换，调用方不能修改
+func (c *Config) ModelIDLocations() []ModelIDLocation {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.modelIDLocations
+}
+
+// RebuildIndex 重建模型别名索引，模型表变化后调用；存在冲突时仍建立索引并返回错误
+func (c *Config) RebuildIndex() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.rebuildIndex()
+}
+
+// rebuildIndex 重建别名索引和模型ID来源列表，调用方需持有写锁
+func (c *Config) rebuildIndex() error {
+	index, err := buildModelIndex(c.Models, c.CaseInsensitiveModels)
+	c.modelIndex = index
+	c.modelIDLocations = buildModelIDLocations(c.Models)
+	return err
+}
+
+// CheckAliases 检查添加或更新model后模型ID和别名是否与其他模型冲突
+func (c *Config) CheckAliases(model *ModelConfig) error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	models := make(map[string]*ModelConfig, len(c.Models)+1)
+	for id, existing := range c.Models {
+		models[id] = existing
+	}
+	models[model.ID] = model
+	_, err := buildModelIndex(models, c.CaseInsensitiveModels)
+	return err
+}
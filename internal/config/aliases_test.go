@@ -0,0 +1,107 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func newAliasConfig(caseInsensitive bool) *Config {
+	cfg := &Config{
+		Models: map[string]*ModelConfig{
+			"gpt-4":  {ID: "gpt-4", Name: "GPT-4", Target: "gpt-4o", Aliases: []string{"gpt4", "gpt-4-latest"}},
+			"claude": {ID: "claude", Name: "Claude", Target: "claude-3"},
+		},
+		CaseInsensitiveModels: caseInsensitive,
+	}
+	cfg.RebuildIndex()
+	return cfg
+}
+
+func TestGetModelByAlias(t *testing.T) {
+	cfg := newAliasConfig(false)
+	for _, name := range []string{"gpt-4", "gpt4", "gpt-4-latest"} {
+		model, ok := cfg.GetModel(name)
+		if !ok || model.ID != "gpt-4" {
+			t.Errorf("期望%s解析为gpt-4，实际得到%v", name, model)
+		}
+	}
+	if _, ok := cfg.GetModel("GPT-4"); ok {
+		t.Error("未开启忽略大小写时不应匹配大小写不同的模型ID")
+	}
+}
+
+func TestGetModelCaseInsensitive(t *testing.T) {
+	cfg := newAliasConfig(true)
+	for _, name := range []string{"GPT-4", "Gpt4", "CLAUDE"} {
+		if _, ok := cfg.GetModel(name); !ok {
+			t.Errorf("开启忽略大小写时期望匹配%s", name)
+		}
+	}
+}
+
+func TestModelIndexRebuiltOnChange(t *testing.T) {
+	cfg := newAliasConfig(false)
+
+	cfg.AddModel(&ModelConfig{ID: "embed", Name: "Embed", Target: "text-embedding-3-small", Aliases: []string{"ada"}})
+	if model, ok := cfg.GetModel("ada"); !ok || model.ID != "embed" {
+		t.Errorf("添加模型后期望别名ada可用，实际得到%v", model)
+	}
+
+	cfg.UpdateModel(&ModelConfig{ID: "gpt-4", Name: "GPT-4", Target: "gpt-4o", Aliases: []string{"gpt4"}})
+	if _, ok := cfg.GetModel("gpt-4-latest"); ok {
+		t.Error("更新模型后已删除的别名不应再匹配")
+	}
+
+	cfg.RemoveModel("gpt-4")
+	if _, ok := cfg.GetModel("gpt4"); ok {
+		t.Error("删除模型后其别名不应再匹配")
+	}
+}
+
+func TestAliasCollisions(t *testing.T) {
+	cfg := newAliasConfig(false)
+
+	tests := []struct {
+		name  string
+		model *ModelConfig
+	}{
+		{"别名与其他模型的别名相同", &ModelConfig{ID: "other", Aliases: []string{"gpt4"}}},
+		{"别名与其他模型的ID相同", &ModelConfig{ID: "other", Aliases: []string{"claude"}}},
+		{"模型ID与其他模型的别名相同", &ModelConfig{ID: "gpt-4-latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cfg.CheckAliases(tt.model); err == nil || !strings.Contains(err.Error(), "模型别名冲突") {
+				t.Errorf("期望返回别名冲突错误，实际得到%v", err)
+			}
+		})
+	}
+
+	// 更新模型自身的别名不算冲突
+	if err := cfg.CheckAliases(&ModelConfig{ID: "gpt-4", Aliases: []string{"gpt4", "gpt-four"}}); err != nil {
+		t.Errorf("更新模型自身的别名不应冲突: %v", err)
+	}
+
+	// 忽略大小写时仅大小写不同的别名也视为冲突
+	if err := newAliasConfig(true).CheckAliases(&ModelConfig{ID: "other", Aliases: []string{"GPT4"}}); err == nil {
+		t.Error("忽略大小写时期望仅大小写不同的别名冲突")
+	}
+}
+
+func TestLoadConfigAliasCollision(t *testing.T) {
+	dir := writeModelConfig(t, `models:
+  - id: a
+    name: A
+    target: gpt-4o
+    url: https://api.openai.com/v1/chat/completions
+    aliases: [shared]
+  - id: b
+    name: B
+    target: gpt-4o
+    url: https://api.openai.com/v1/chat/completions
+    aliases: [shared]
+`)
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "模型别名冲突") {
+		t.Errorf("期望两个模型使用相同别名时加载失败，实际得到%v", err)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,78 +23,442 @@ const (
 	ModelTypeAudio ModelType = "audio"
 	ModelTypeVideo ModelType = "video"
 
+	ModelTypeEmbedding ModelType = "embedding"
+
 	ValueTypeString ValueType = "string"
 	ValueTypeArray  ValueType = "array"
 	ValueTypeObject ValueType = "object"
 )
 
+// ModelIDSource 模型ID来源
+type ModelIDSource string
+
+const (
+	ModelIDSourceBody   ModelIDSource = "body"   // JSON请求体中的字段
+	ModelIDSourceQuery  ModelIDSource = "query"  // URL查询参数
+	ModelIDSourceHeader ModelIDSource = "header" // 请求头
+	ModelIDSourceForm   ModelIDSource = "form"   // 表单字段(urlencoded/multipart)
+)
+
+// ToolsMode 注入tools时对客户端已定义tools的处理方式
+type ToolsMode string
+
+const (
+	ToolsModeAppend  ToolsMode = "append"  // 追加到客户端的tools之后，同名函数以客户端定义为准
+	ToolsModeReplace ToolsMode = "replace" // 用配置的tools替换客户端的tools
+)
+
+// StreamMode 上游响应的转发方式
+type StreamMode string
+
+const (
+	StreamModeAuto      StreamMode = "auto"       // 根据响应的Content-Type、分块传输和请求的stream字段判断（默认）
+	StreamModeSSE       StreamMode = "sse"        // 按行转发的SSE流
+	StreamModeJSONArray StreamMode = "json_array" // 分块返回的JSON数组（如Gemini的streamGenerateContent），收到数据即转发
+	StreamModeNDJSON    StreamMode = "ndjson"     // 按行转发的NDJSON流
+	StreamModeNone      StreamMode = "none"       // 不作为流处理，读取完整响应后返回
+)
+
+// HealthCheckMethod 上游健康检查的方式
+type HealthCheckMethod string
+
+const (
+	HealthCheckConnect HealthCheckMethod = "connect" // 只做DNS解析和TCP/TLS连接（默认）
+	HealthCheckHead    HealthCheckMethod = "head"    // 连接后发送HEAD请求
+	HealthCheckRequest HealthCheckMethod = "request" // 发送带模型请求头的最小请求，部分服务商会对此计费，需按模型开启
+)
+
+// PromptPosition 聊天模型的Prompt消息在messages数组中的位置
+type PromptPosition string
+
+const (
+	PromptPositionPrepend       PromptPosition = "prepend"        // 插入到数组首位（默认）
+	PromptPositionAppend        PromptPosition = "append"         // 追加到历史消息之后
+	PromptPositionReplaceSystem PromptPosition = "replace_system" // 删除客户端的system消息后插入到首位
+	PromptPositionMergeSystem   PromptPosition = "merge_system"   // 拼接到客户端第一条system消息的内容之后，没有时插入到首位
+)
+
+// ModelSource 模型配置的来源
+type ModelSource string
+
+const (
+	ModelSourceYAML ModelSource = "yaml" // 从配置目录的YAML文件加载，文件变化时随之更新
+	ModelSourceAPI  ModelSource = "api"  // 通过管理API创建，重新加载YAML文件时不受影响
+)
+
 // ModelConfig 模型配置
 type ModelConfig struct {
-	ID              string      `yaml:"id"`           // 模型ID
-	Name            string      `yaml:"name"`         // 模型名称
-	Target          string      `yaml:"target"`       // 目标模型ID
-	Prompt          string      `yaml:"prompt"`       // Prompt描述
-	Url             string      `yaml:"url"`          // 转发的URL
-	Type            ModelType   `yaml:"type"`         // 模型类型
-	PromptPath      string      `yaml:"prompt_path"`  // Prompt插入位置(JSON Path)
-	PromptValue     interface{} `yaml:"prompt_value"` // Prompt值
-	PromptValueType ValueType   `yaml:"prompt_type"`  // Prompt值类型
+	ID              string      `yaml:"id" json:"id"`                         // 模型ID
+	Name            string      `yaml:"name" json:"name"`                     // 模型名称
+	Target          string      `yaml:"target" json:"target"`                 // 目标模型ID
+	Prompt          string      `yaml:"prompt" json:"prompt"`                 // Prompt描述
+	Url             string      `yaml:"url" json:"url"`                       // 转发的URL
+	Type            ModelType   `yaml:"type" json:"type"`                     // 模型类型
+	PromptPath      string      `yaml:"prompt_path" json:"prompt_path"`       // Prompt插入位置(JSON Path)
+	PromptValue     interface{} `yaml:"prompt_value" json:"prompt_value"`     // Prompt值
+	PromptValueType ValueType   `yaml:"prompt_type" json:"prompt_value_type"` // Prompt值类型
+
+	PromptPosition PromptPosition `yaml:"prompt_position,omitempty" json:"prompt_position"` // Prompt消息的插入位置，只支持聊天模型，默认prepend
+
+	// DedupePrompt 客户端重发的历史消息中已有内容与Prompt相同的system消息时不再注入，只支持聊天模型
+	DedupePrompt bool `yaml:"dedupe_injected_prompt,omitempty" json:"dedupe_injected_prompt"`
+
+	ModelIDSource ModelIDSource `yaml:"model_id_source" json:"model_id_source"` // 模型ID来源，默认body
+	ModelIDKey    string        `yaml:"model_id_key" json:"model_id_key"`       // 模型ID所在的字段/参数/头部名称
+
+	Disabled bool `yaml:"disabled" json:"disabled"`   // 是否禁用，禁用后代理不再接受该模型的请求
+	CacheTTL int  `yaml:"cache_ttl" json:"cache_ttl"` // 响应缓存时间(秒)，0表示不缓存
+
+	MaxConcurrency int  `yaml:"max_concurrency" json:"max_concurrency"`   // 最大并发请求数，0表示不限制
+	QueueOnLimit   bool `yaml:"queue_on_limit" json:"queue_on_limit"`     // 达到并发上限时排队等待，否则直接返回429
+	QueueTimeout   int  `yaml:"queue_timeout" json:"queue_timeout"`       // 排队等待超时时间(秒)，默认30
+	QueueTimeoutMs int  `yaml:"queue_timeout_ms" json:"queue_timeout_ms"` // 排队等待超时时间(毫秒)，设置后开启排队并优先于queue_timeout
+	MaxTimeoutMs   int  `yaml:"max_timeout_ms" json:"max_timeout_ms"`     // 客户端X-Proxy-Timeout-Ms超时预算的上限(毫秒)，0表示只受全局上限限制
+
+	Maintenance        bool   `yaml:"maintenance" json:"maintenance"`                 // 是否处于维护模式
+	MaintenanceMessage string `yaml:"maintenance_message" json:"maintenance_message"` // 维护提示信息，为空时使用默认信息
+	MaintenanceStatus  int    `yaml:"maintenance_status" json:"maintenance_status"`   // 维护时返回的HTTP状态码，默认503
+
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases"` // 模型别名，请求中使用别名时与模型ID等效
+
+	Tools     []interface{} `yaml:"tools,omitempty" json:"tools"`           // 注入到聊天请求tools数组的工具定义
+	ToolsMode ToolsMode     `yaml:"tools_mode,omitempty" json:"tools_mode"` // 客户端已定义tools时的处理方式，默认append
+
+	RequestHeaders  map[string]string `yaml:"request_headers,omitempty" json:"request_headers"`   // 转发时添加的请求头，覆盖客户端的同名请求头
+	ResponseHeaders map[string]string `yaml:"response_headers,omitempty" json:"response_headers"` // 返回客户端时添加的响应头，覆盖上游的同名响应头，值支持{model_id}、{target}、{request_id}
+
+	// SigningSecret 请求签名密钥，设置后转发的请求带有X-Proxy-Signature签名（见signing包），
+	// 数据库中加密保存，管理API不返回
+	SigningSecret string `yaml:"signing_secret,omitempty" json:"signing_secret"`
+
+	StreamMode StreamMode `yaml:"stream_mode,omitempty" json:"stream_mode"` // 上游响应的转发方式，默认auto
+	MinifyBody bool       `yaml:"minify_body,omitempty" json:"minify_body"` // 转发前将JSON请求体压缩为紧凑格式
+
+	// CompressUpstream 客户端发送gzip/deflate压缩的请求体时，按相同格式重新压缩后转发，
+	// 否则解压后转发并去掉Content-Encoding请求头
+	CompressUpstream bool `yaml:"compress_upstream,omitempty" json:"compress_upstream"`
+
+	// HealthCheckInterval 后台检查上游连通性的间隔(秒)，0表示不检查；检查失败时代理直接返回503
+	HealthCheckInterval int               `yaml:"health_check_interval,omitempty" json:"health_check_interval"`
+	HealthCheckMethod   HealthCheckMethod `yaml:"health_check_method,omitempty" json:"health_check_method"` // 健康检查方式，默认connect
+
+	// Targets 按权重分流的目标列表，配置后每个请求按权重随机选择一个目标转发，
+	// target和url作为条目未设置时的默认值
+	Targets []WeightedTarget `yaml:"targets,omitempty" json:"targets"`
+
+	// 灰度发布：每个请求按canary_percent的比例转发到canary_target，其余请求转发到target，
+	// 比例为0时与未配置灰度完全相同
+	CanaryTarget  string `yaml:"canary_target,omitempty" json:"canary_target"`   // 灰度目标模型ID
+	CanaryUrl     string `yaml:"canary_url,omitempty" json:"canary_url"`         // 灰度目标的URL，支持URL模板，为空时使用url
+	CanaryPercent int    `yaml:"canary_percent,omitempty" json:"canary_percent"` // 转发到灰度目标的请求百分比(0-100)
+
+	// 对冲请求：非流式请求等待hedge_after_ms仍未收到上游响应时，再发送一个相同的请求，
+	// 使用先响应的结果并取消其他请求；对冲请求同样占用并发名额，没有名额时不发送
+	HedgeAfterMs int `yaml:"hedge_after_ms,omitempty" json:"hedge_after_ms"` // 发送对冲请求前等待的时间(毫秒)，0表示不对冲
+	MaxHedges    int `yaml:"max_hedges,omitempty" json:"max_hedges"`         // 最多发送的对冲请求数，默认1
+
+	// PromptVariants Prompt实验的变体列表，配置后每个请求按权重选择一个变体的Prompt注入，
+	// 为空时注入模型配置的Prompt
+	PromptVariants []PromptVariant `yaml:"prompt_variants,omitempty" json:"prompt_variants"`
+
+	// Pipeline 请求处理阶段的顺序，为空时使用DefaultPipeline
+	Pipeline []string `yaml:"pipeline,omitempty" json:"pipeline"`
+
+	// Source 模型来源，由加载方式决定，YAML文件中的设置会被忽略；升级前保存的模型为空
+	Source ModelSource `yaml:"source,omitempty" json:"source"`
+
+	// Tenant 模型所属的租户，默认为default；API Key只能调用所属租户的模型
+	Tenant string `yaml:"tenant,omitempty" json:"tenant"`
+
+	// envTemplates 引用了环境变量的字段展开前的模板，保存时用于还原（见Templated）
+	envTemplates map[string]envTemplate
 }
 
-func (m *ModelConfig) Validate() error {
-	if m.ID == "" {
-		return fmt.Errorf("模型ID不能为空")
+// MaxModelHedges 单个请求最多发送的对冲请求数
+const MaxModelHedges = 3
+
+// validateHedge 验证对冲请求配置，只支持非流式响应
+func (m *ModelConfig) validateHedge(errs *ValidationErrors) {
+	if m.HedgeAfterMs < 0 {
+		errs.add("hedge_after_ms", "对冲等待时间不能为负数: %d", m.HedgeAfterMs)
 	}
-	if m.Name == "" {
-		return fmt.Errorf("模型名称不能为空")
+	if m.MaxHedges < 0 || m.MaxHedges > MaxModelHedges {
+		errs.add("max_hedges", "对冲请求数应在0到%d之间: %d", MaxModelHedges, m.MaxHedges)
 	}
-	if m.Target == "" {
-		return fmt.Errorf("目标模型ID不能为空")
+	if m.HedgeAfterMs <= 0 {
+		// 未设置等待时间时不对冲，max_hedges不生效
+		return
 	}
-	if m.Url == "" {
-		return fmt.Errorf("转发的URL不能为空")
+	switch m.StreamMode {
+	case StreamModeSSE, StreamModeNDJSON, StreamModeJSONArray:
+		errs.add("hedge_after_ms", "对冲请求只支持非流式响应，不能与stream_mode %s同时使用", m.StreamMode)
 	}
-	u, err := url.Parse(m.Url)
-	if err != nil {
-		return fmt.Errorf("转发的URL无效: %w", err)
+}
+
+// Validate 验证模型配置并填充默认值，返回包含全部字段错误的ValidationErrors
+func (m *ModelConfig) Validate() error {
+	var errs ValidationErrors
+	m.expandEnv(&errs)
+	envValues := m.envValues()
+	if m.ID == "" {
+		errs.add("id", "模型ID不能为空")
 	}
-	if u.Scheme == "" || u.Host == "" {
-		return fmt.Errorf("转发的URL无效: %s", m.Url)
+	if m.Name == "" {
+		errs.add("name", "模型名称不能为空")
+	}
+	if len(m.Targets) == 0 {
+		m.Url = validateUpstream(&errs, "", m.Target, m.Url)
+	} else {
+		// 配置了targets时target和url只作为条目的默认值，可以为空
+		if m.Url != "" {
+			m.Url = validateUpstream(&errs, "", m.Target, m.Url)
+		}
+		m.validateTargets(&errs)
 	}
+	m.validatePromptVariants(&errs)
+	m.validateCanary(&errs)
 	if m.Type == "" {
 		m.Type = ModelTypeChat
 	}
 
 	// 验证模型类型
 	switch m.Type {
-	case ModelTypeChat, ModelTypeImage, ModelTypeAudio, ModelTypeVideo:
+	case ModelTypeChat, ModelTypeImage, ModelTypeAudio, ModelTypeVideo, ModelTypeEmbedding:
 		// 有效类型
 	default:
-		return fmt.Errorf("无效的模型类型: %s", m.Type)
+		errs.add("type", "无效的模型类型: %s", m.Type)
+	}
+
+	switch m.PromptValueType {
+	case "", ValueTypeString, ValueTypeArray, ValueTypeObject:
+	default:
+		errs.add("prompt_value_type", "无效的Prompt值类型: %s", m.PromptValueType)
+	}
+
+	if m.ModelIDSource == "" {
+		m.ModelIDSource = ModelIDSourceBody
+	}
+	switch m.ModelIDSource {
+	case ModelIDSourceBody, ModelIDSourceQuery, ModelIDSourceForm:
+		if m.ModelIDKey == "" {
+			m.ModelIDKey = "model"
+		}
+	case ModelIDSourceHeader:
+		if m.ModelIDKey == "" {
+			m.ModelIDKey = "X-Model"
+		}
+	default:
+		errs.add("model_id_source", "无效的模型ID来源: %s", m.ModelIDSource)
+	}
+
+	if m.CacheTTL < 0 {
+		errs.add("cache_ttl", "缓存时间不能为负数: %d", m.CacheTTL)
+	}
+	if m.MaxConcurrency < 0 {
+		errs.add("max_concurrency", "最大并发数不能为负数: %d", m.MaxConcurrency)
+	}
+	if m.QueueTimeout < 0 {
+		errs.add("queue_timeout", "排队超时时间不能为负数: %d", m.QueueTimeout)
+	}
+	if m.QueueTimeoutMs < 0 {
+		errs.add("queue_timeout_ms", "排队超时时间不能为负数: %d", m.QueueTimeoutMs)
+	}
+	if m.MaxTimeoutMs < 0 {
+		errs.add("max_timeout_ms", "超时预算上限不能为负数: %d", m.MaxTimeoutMs)
+	}
+	if m.MaintenanceStatus != 0 && (m.MaintenanceStatus < 200 || m.MaintenanceStatus > 599) {
+		errs.add("maintenance_status", "无效的维护状态码: %d", m.MaintenanceStatus)
+	}
+	seen := make(map[string]bool, len(m.Aliases))
+	for _, alias := range m.Aliases {
+		switch {
+		case strings.TrimSpace(alias) == "":
+			errs.add("aliases", "模型别名不能为空")
+		case alias == m.ID:
+			errs.add("aliases", "模型别名不能与模型ID相同: %s", alias)
+		case seen[alias]:
+			errs.add("aliases", "模型别名重复: %s", alias)
+		}
+		seen[alias] = true
+	}
+	if len(m.Tools) > 0 && m.Type != ModelTypeChat {
+		errs.add("tools", "只有聊天模型支持注入tools")
+	}
+	for i, tool := range m.Tools {
+		if _, ok := tool.(map[string]interface{}); !ok {
+			errs.add("tools", "第%d个tool不是对象", i+1)
+		}
+	}
+	for name, value := range m.RequestHeaders {
+		if err := validateHeader(name, value); err != nil {
+			errs.add("request_headers", "%v", err)
+		}
+	}
+	for name, value := range m.ResponseHeaders {
+		if err := validateHeader(name, value); err != nil {
+			errs.add("response_headers", "%v", err)
+		}
+	}
+	switch m.ToolsMode {
+	case "", ToolsModeAppend, ToolsModeReplace:
+	default:
+		errs.add("tools_mode", "无效的tools注入方式: %s", m.ToolsMode)
+	}
+	for _, problem := range validatePipeline(m.Pipeline) {
+		errs.add("pipeline", "%s", problem)
+	}
+	switch m.StreamMode {
+	case "", StreamModeAuto, StreamModeSSE, StreamModeJSONArray, StreamModeNDJSON, StreamModeNone:
+	default:
+		errs.add("stream_mode", "无效的流式转发方式: %s", m.StreamMode)
+	}
+	if m.HealthCheckInterval < 0 {
+		errs.add("health_check_interval", "健康检查间隔不能为负数: %d", m.HealthCheckInterval)
+	}
+	switch m.HealthCheckMethod {
+	case "", HealthCheckConnect, HealthCheckHead:
+	case HealthCheckRequest:
+		if m.Type != "" && m.Type != ModelTypeChat && m.Type != ModelTypeEmbedding {
+			errs.add("health_check_method", "只有聊天和向量模型支持request方式的健康检查")
+		}
+	default:
+		errs.add("health_check_method", "无效的健康检查方式: %s", m.HealthCheckMethod)
+	}
+	m.validateHedge(&errs)
+	switch m.PromptPosition {
+	case "":
+	case PromptPositionPrepend, PromptPositionAppend, PromptPositionReplaceSystem, PromptPositionMergeSystem:
+		if m.Type != ModelTypeChat {
+			errs.add("prompt_position", "只有聊天模型支持设置Prompt位置")
+		}
+	default:
+		errs.add("prompt_position", "无效的Prompt位置: %s", m.PromptPosition)
+	}
+	if m.DedupePrompt && m.Type != ModelTypeChat {
+		errs.add("dedupe_injected_prompt", "只有聊天模型支持Prompt去重")
+	}
+	switch m.Source {
+	case "", ModelSourceYAML, ModelSourceAPI:
+	default:
+		errs.add("source", "无效的模型来源: %s", m.Source)
+	}
+	if m.Tenant == "" {
+		m.Tenant = DefaultTenant
+	} else if !ValidTenantID(m.Tenant) {
+		errs.add("tenant", "无效的租户ID: %s", m.Tenant)
+	}
+	m.syncEnvTemplates(envValues)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if m.QueueOnLimit && m.QueueTimeout == 0 {
+		m.QueueTimeout = 30
+	}
+	if m.HedgeAfterMs > 0 && m.MaxHedges == 0 {
+		m.MaxHedges = 1
 	}
 
 	if m.PromptPath == "" {
 		switch m.Type {
 		case ModelTypeChat:
+			// PromptValue为空时由代理按prompt生成system消息，修改prompt后随之生效
 			m.PromptPath = "messages"
-			if m.PromptValue == nil {
-				m.PromptValue = map[string]interface{}{
-					"role":    "system",
-					"content": m.PromptValue,
-				}
-			}
 		case ModelTypeImage:
 			m.PromptPath = "prompt"
+		case ModelTypeEmbedding:
+			m.PromptPath = "input"
 		}
 	}
 
 	return nil
 }
 
-// Config 全局配置
+// QueueWait 达到并发上限时是否排队以及最长等待时间，queue_timeout_ms优先于queue_on_limit和queue_timeout
+func (m *ModelConfig) QueueWait() (bool, time.Duration) {
+	if m.QueueTimeoutMs > 0 {
+		return true, time.Duration(m.QueueTimeoutMs) * time.Millisecond
+	}
+	return m.QueueOnLimit, time.Duration(m.QueueTimeout) * time.Second
+}
+
+// reservedHeaders 由代理维护、不能通过模型配置设置的头部，包括只对单个连接有效的逐跳头部
+var reservedHeaders = map[string]bool{
+	"content-length":      true,
+	"transfer-encoding":   true,
+	"host":                true,
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-connection":    true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"te":                  true,
+	"trailer":             true,
+	"upgrade":             true,
+}
+
+// validateHeader 验证头部名称是合法的HTTP token且值不包含换行
+func validateHeader(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("头部名称不能为空")
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", r) {
+			return fmt.Errorf("无效的头部名称: %q", name)
+		}
+	}
+	if reservedHeaders[strings.ToLower(name)] {
+		return fmt.Errorf("不能设置头部: %s", name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("头部%s的值不能包含换行", name)
+	}
+	return nil
+}
+
+// normalizeUpstreamURL 验证上游URL只使用http/https且不含用户信息和片段，并去掉末尾的斜杠
+func normalizeUpstreamURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("转发的URL无效: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "":
+		return "", fmt.Errorf("转发的URL缺少协议，应以http://或https://开头: %s", raw)
+	default:
+		return "", fmt.Errorf("转发的URL协议不支持: %s，只支持http和https", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("转发的URL缺少主机名: %s", raw)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("转发的URL不能包含用户信息，请通过请求头传递认证信息")
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return "", fmt.Errorf("转发的URL不能包含片段(#): %s", raw)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return u.String(), nil
+}
+
+// Config 全局配置。模型表、别名索引和全局模型设置由mutex保护，代理处理请求时与配置重新加载并发访问，
+// 初始化之后应通过GetModel、SnapshotModels、Replace等方法读写，不要直接访问字段
 type Config struct {
 	Models map[string]*ModelConfig `yaml:"models"`
-	dbPath string                  // 数据库路径
+	// DefaultModel 请求的模型ID未匹配任何配置时使用的模型ID，为空时返回404；
+	// 使用默认模型时保留客户端原始的模型ID，不做替换
+	DefaultModel string `yaml:"default_model"`
+	// CaseInsensitiveModels 查找模型时忽略模型ID和别名的大小写
+	CaseInsensitiveModels bool `yaml:"case_insensitive_models"`
+
+	mutex            sync.RWMutex
+	modelIndex       map[string]string // 别名到模型ID的索引，见RebuildIndex
+	modelIDLocations []ModelIDLocation // 各模型配置的模型ID来源，见RebuildIndex
+	dbPath           string            // 数据库路径
 }
 
 // LoadConfig 从指定目录加载配置文件
@@ -122,6 +488,13 @@ func LoadConfig(configDir string) (*Config, error) {
 			return nil, fmt.Errorf("加载配置文件 %s 失败: %w", filePath, err)
 		}
 	}
+	for _, model := range config.Models {
+		model.Source = ModelSourceYAML
+	}
+
+	if err := config.RebuildIndex(); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
@@ -159,17 +532,46 @@ func LoadConfigWithDB(configDir string) (*Config, error) {
 	return config, nil
 }
 
+// LoadConfigFile 从单个配置文件加载配置
+func LoadConfigFile(filePath string) (*Config, error) {
+	config := &Config{
+		Models: make(map[string]*ModelConfig),
+	}
+	if err := loadConfigFile(filePath, config); err != nil {
+		return nil, fmt.Errorf("加载配置文件 %s 失败: %w", filePath, err)
+	}
+	return config, nil
+}
+
+// ParseConfig 解析与模型配置文件格式相同的YAML内容
+func ParseConfig(data []byte) (*Config, error) {
+	config := &Config{
+		Models: make(map[string]*ModelConfig),
+	}
+	if err := parseConfigData(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
 // loadConfigFile 加载单个配置文件
 func loadConfigFile(filePath string, config *Config) error {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return err
 	}
+	return parseConfigData(data, config)
+}
 
+// parseConfigData 解析配置文件内容并合并到config
+func parseConfigData(data []byte, config *Config) error {
 	var fileConfig struct {
-		Models []ModelConfig `yaml:"models"`
+		Models                []ModelConfig `yaml:"models"`
+		DefaultModel          string        `yaml:"default_model"`
+		CaseInsensitiveModels bool          `yaml:"case_insensitive_models"`
 	}
 
+	// URL、请求头和签名密钥中的环境变量引用在验证时展开，见envFields
 	if err := yaml.Unmarshal(data, &fileConfig); err != nil {
 		return err
 	}
@@ -183,13 +585,87 @@ func loadConfigFile(filePath string, config *Config) error {
 		config.Models[model.ID] = model
 	}
 
+	if fileConfig.CaseInsensitiveModels {
+		config.CaseInsensitiveModels = true
+	}
+	if fileConfig.DefaultModel != "" {
+		if config.DefaultModel != "" && config.DefaultModel != fileConfig.DefaultModel {
+			return fmt.Errorf("默认模型重复配置: %s 和 %s", config.DefaultModel, fileConfig.DefaultModel)
+		}
+		config.DefaultModel = fileConfig.DefaultModel
+	}
+
 	return nil
 }
 
-// GetModel 根据模型ID获取模型配置
+// GetModel 根据模型ID或别名获取模型配置
 func (c *Config) GetModel(modelID string) (*ModelConfig, bool) {
-	model, exists := c.Models[modelID]
-	return model, exists
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.getModel(modelID)
+}
+
+// getModel 查找模型配置，调用方需持有读锁
+func (c *Config) getModel(modelID string) (*ModelConfig, bool) {
+	if model, exists := c.Models[modelID]; exists {
+		return model, true
+	}
+
+	key := modelID
+	if c.CaseInsensitiveModels {
+		key = strings.ToLower(modelID)
+	}
+	if id, exists := c.modelIndex[key]; exists {
+		model, exists := c.Models[id]
+		return model, exists
+	}
+	return nil, false
+}
+
+// DefaultModelConfig 获取未匹配模型ID时使用的默认模型配置，未设置或默认模型已不存在时返回false
+func (c *Config) DefaultModelConfig() (*ModelConfig, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.DefaultModel == "" {
+		return nil, false
+	}
+	return c.getModel(c.DefaultModel)
+}
+
+// SnapshotModels 返回当前模型表的副本，遍历模型时使用，不受之后的重新加载影响
+func (c *Config) SnapshotModels() map[string]*ModelConfig {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	models := make(map[string]*ModelConfig, len(c.Models))
+	for id, model := range c.Models {
+		models[id] = model
+	}
+	return models
+}
+
+// ModelCount 返回当前的模型数量
+func (c *Config) ModelCount() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.Models)
+}
+
+// Settings 返回全局模型设置：默认模型ID和查找模型时是否忽略大小写
+func (c *Config) Settings() (defaultModel string, caseInsensitive bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.DefaultModel, c.CaseInsensitiveModels
+}
+
+// Replace 一次性替换模型表和全局模型设置并重建别名索引，并发的查找只会看到替换前或替换后的完整配置；
+// 存在别名冲突时仍完成替换并返回错误
+func (c *Config) Replace(models map[string]*ModelConfig, defaultModel string, caseInsensitive bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Models = models
+	c.DefaultModel = defaultModel
+	c.CaseInsensitiveModels = caseInsensitive
+	return c.rebuildIndex()
 }
 
 // SetDBPath 设置数据库路径
@@ -204,16 +680,22 @@ func (c *Config) GetDBPath() string {
 
 // AddModel 添加模型配置
 func (c *Config) AddModel(model *ModelConfig) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	if c.Models == nil {
 		c.Models = make(map[string]*ModelConfig)
 	}
 	c.Models[model.ID] = model
+	c.rebuildIndex()
 }
 
 // RemoveModel 移除模型配置
 func (c *Config) RemoveModel(modelID string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	if _, exists := c.Models[modelID]; exists {
 		delete(c.Models, modelID)
+		c.rebuildIndex()
 		return true
 	}
 	return false
@@ -221,8 +703,11 @@ func (c *Config) RemoveModel(modelID string) bool {
 
 // UpdateModel 更新模型配置
 func (c *Config) UpdateModel(model *ModelConfig) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	if _, exists := c.Models[model.ID]; exists {
 		c.Models[model.ID] = model
+		c.rebuildIndex()
 		return true
 	}
 	return false
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envRefPattern 匹配 ${VAR} 和 ${VAR:-default} 形式的环境变量引用，以及转义的 $${VAR}
+var envRefPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv 展开字符串中的环境变量引用，变量未设置(或为空)时使用默认值，无默认值则返回错误；
+// $${VAR} 展开为字面的 ${VAR}
+func expandEnv(value string) (string, error) {
+	var missing []string
+	result := envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		match := envRefPattern.FindStringSubmatch(ref)
+		name, hasDefault, defaultValue := match[1], match[2] != "", match[3]
+
+		if env, ok := os.LookupEnv(name); ok && (env != "" || !hasDefault) {
+			return env
+		}
+		if hasDefault {
+			return defaultValue
+		}
+		missing = append(missing, name)
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("环境变量未设置: %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// envTemplate 字段展开前的模板和展开后的值
+type envTemplate struct {
+	template string
+	value    string
+}
+
+// envField 支持环境变量引用的字段，path与验证错误的字段名一致
+type envField struct {
+	path string
+	get  func() string
+	set  func(string)
+}
+
+// envFields 返回支持环境变量引用的字段：URL、附加请求头和签名密钥，其他字段中的 ${VAR} 按字面保留
+func (m *ModelConfig) envFields() []envField {
+	fields := []envField{
+		{"url", func() string { return m.Url }, func(v string) { m.Url = v }},
+		{"canary_url", func() string { return m.CanaryUrl }, func(v string) { m.CanaryUrl = v }},
+		{"signing_secret", func() string { return m.SigningSecret }, func(v string) { m.SigningSecret = v }},
+	}
+	for i := range m.Targets {
+		i := i
+		fields = append(fields, envField{fmt.Sprintf("targets[%d].url", i),
+			func() string { return m.Targets[i].Url }, func(v string) { m.Targets[i].Url = v }})
+	}
+	names := make([]string, 0, len(m.RequestHeaders))
+	for name := range m.RequestHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		name := name
+		fields = append(fields, envField{"request_headers." + name,
+			func() string { return m.RequestHeaders[name] }, func(v string) { m.RequestHeaders[name] = v }})
+	}
+	return fields
+}
+
+// expandEnv 展开支持环境变量引用的字段并记录原始模板，已展开且未被修改的字段不再重复展开
+func (m *ModelConfig) expandEnv(errs *ValidationErrors) {
+	cloned := false
+	for _, field := range m.envFields() {
+		value := field.get()
+		if t, ok := m.envTemplates[field.path]; ok && t.value == value {
+			continue
+		}
+		if !strings.Contains(value, "${") {
+			continue
+		}
+		expanded, err := expandEnv(value)
+		if err != nil {
+			errs.add(field.path, "%s: %v", field.path, err)
+			continue
+		}
+		if !cloned {
+			m.cloneEnvFields()
+			cloned = true
+		}
+		field.set(expanded)
+		m.setEnvTemplate(field.path, envTemplate{template: value, value: expanded})
+	}
+}
+
+// cloneEnvFields 复制请求头和分流目标，修改后不影响共享它们的配置副本
+func (m *ModelConfig) cloneEnvFields() {
+	if m.RequestHeaders != nil {
+		headers := make(map[string]string, len(m.RequestHeaders))
+		for name, value := range m.RequestHeaders {
+			headers[name] = value
+		}
+		m.RequestHeaders = headers
+	}
+	if m.Targets != nil {
+		m.Targets = append([]WeightedTarget(nil), m.Targets...)
+	}
+}
+
+// setEnvTemplate 设置字段的模板记录，按写时复制更新，不影响共享同一记录的配置副本
+func (m *ModelConfig) setEnvTemplate(path string, t envTemplate) {
+	templates := make(map[string]envTemplate, len(m.envTemplates)+1)
+	for existing, template := range m.envTemplates {
+		templates[existing] = template
+	}
+	templates[path] = t
+	m.envTemplates = templates
+}
+
+// envValues 返回支持环境变量引用的字段的当前值
+func (m *ModelConfig) envValues() map[string]string {
+	values := make(map[string]string)
+	for _, field := range m.envFields() {
+		values[field.path] = field.get()
+	}
+	return values
+}
+
+// syncEnvTemplates 验证时规范化了字段的值（如去掉URL末尾的/）时，更新模板记录中展开后的值；
+// 未设置url的分流目标使用模型的url作为默认值，一并使用其模板
+func (m *ModelConfig) syncEnvTemplates(before map[string]string) {
+	current := m.envValues()
+	for path, t := range m.envTemplates {
+		if before[path] == t.value && current[path] != t.value {
+			m.setEnvTemplate(path, envTemplate{template: t.template, value: current[path]})
+		}
+	}
+
+	t, ok := m.envTemplates["url"]
+	if !ok || t.value != m.Url {
+		return
+	}
+	for i, target := range m.Targets {
+		path := fmt.Sprintf("targets[%d].url", i)
+		if _, exists := m.envTemplates[path]; !exists && before[path] == "" && target.Url == m.Url {
+			m.setEnvTemplate(path, t)
+		}
+	}
+}
+
+// hasEnvRefs 支持环境变量引用的字段中是否有未展开的引用
+func (m *ModelConfig) hasEnvRefs() bool {
+	for _, field := range m.envFields() {
+		if strings.Contains(field.get(), "${") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandStoredEnv 展开从数据库读取的模型配置中保存的环境变量模板，并按加载YAML时的规则验证和规范化
+func (m *ModelConfig) ExpandStoredEnv() error {
+	if !m.hasEnvRefs() {
+		return nil
+	}
+	return m.Validate()
+}
+
+// Templated 返回用于保存到数据库或写入YAML的副本，展开后未被修改的字段还原为环境变量模板，
+// 避免将环境变量中的密钥写入数据库和备份文件；没有模板时返回原配置
+func (m *ModelConfig) Templated() *ModelConfig {
+	if len(m.envTemplates) == 0 {
+		return m
+	}
+	templated := *m
+	templated.cloneEnvFields()
+	for _, field := range templated.envFields() {
+		if t, ok := m.envTemplates[field.path]; ok && field.get() == t.value {
+			field.set(t.template)
+		}
+	}
+	templated.envTemplates = nil
+	return &templated
+}
@@ -0,0 +1,86 @@
+package config
+
+import "fmt"
+
+// PromptVariant Prompt实验的一个变体，每个请求按权重选择一个变体注入
+type PromptVariant struct {
+	ID          string      `yaml:"id" json:"id"`                     // 变体ID，记录到访问日志的$experiment_variant
+	Weight      int         `yaml:"weight" json:"weight"`             // 权重，按占全部权重的比例分配请求，0表示不分配
+	PromptValue interface{} `yaml:"prompt_value" json:"prompt_value"` // 替换模型Prompt值的值
+}
+
+// WithPromptVariant 返回注入指定变体Prompt的模型配置副本，原配置不受影响。
+// 变体的值为文本时：模型的Prompt值是消息对象则只替换其content，模型未配置Prompt值则替换prompt文本
+func (m *ModelConfig) WithPromptVariant(variant PromptVariant) *ModelConfig {
+	adjusted := *m
+	text, isText := variant.PromptValue.(string)
+	switch value := m.PromptValue.(type) {
+	case nil:
+		if isText {
+			adjusted.Prompt = text
+			return &adjusted
+		}
+	case map[string]interface{}:
+		if _, ok := value["content"]; ok && isText {
+			message := make(map[string]interface{}, len(value))
+			for k, v := range value {
+				message[k] = v
+			}
+			message["content"] = text
+			adjusted.PromptValue = message
+			return &adjusted
+		}
+	}
+	adjusted.PromptValue = variant.PromptValue
+	return &adjusted
+}
+
+// PromptVariant 按ID查找Prompt实验的变体
+func (m *ModelConfig) PromptVariant(id string) (PromptVariant, bool) {
+	for _, variant := range m.PromptVariants {
+		if variant.ID == id {
+			return variant, true
+		}
+	}
+	return PromptVariant{}, false
+}
+
+// PromotePromptVariant 将变体的Prompt设为模型的基础Prompt并结束实验，变体不存在时返回false
+func (m *ModelConfig) PromotePromptVariant(id string) bool {
+	variant, ok := m.PromptVariant(id)
+	if !ok {
+		return false
+	}
+	*m = *m.WithPromptVariant(variant)
+	m.PromptVariants = nil
+	return true
+}
+
+// validatePromptVariants 验证Prompt实验的变体列表，未配置变体时不做检查
+func (m *ModelConfig) validatePromptVariants(errs *ValidationErrors) {
+	if len(m.PromptVariants) == 0 {
+		return
+	}
+	ids := make(map[string]bool, len(m.PromptVariants))
+	totalWeight := 0
+	for i, variant := range m.PromptVariants {
+		field := fmt.Sprintf("prompt_variants[%d].", i)
+		if variant.ID == "" {
+			errs.add(field+"id", "变体ID不能为空")
+		} else if ids[variant.ID] {
+			errs.add(field+"id", "变体ID重复: %s", variant.ID)
+		}
+		ids[variant.ID] = true
+		if variant.PromptValue == nil {
+			errs.add(field+"prompt_value", "变体的Prompt值不能为空")
+		}
+		if variant.Weight < 0 {
+			errs.add(field+"weight", "权重不能为负数: %d", variant.Weight)
+		} else {
+			totalWeight += variant.Weight
+		}
+	}
+	if totalWeight == 0 {
+		errs.add("prompt_variants", "至少需要一个变体的权重大于0")
+	}
+}
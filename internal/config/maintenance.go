@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaintenanceMessage 维护模式默认提示信息
+const DefaultMaintenanceMessage = "服务正在维护中，请稍后再试。"
+
+// MaintenanceConfig 维护模式配置
+type MaintenanceConfig struct {
+	Enabled    bool   `json:"enabled" yaml:"enabled"`         // 是否开启维护模式
+	Message    string `json:"message" yaml:"message"`         // 返回给客户端的提示信息
+	StatusCode int    `json:"status_code" yaml:"status_code"` // 返回的HTTP状态码，默认503
+}
+
+// Validate 验证维护模式配置并填充默认值
+func (m *MaintenanceConfig) Validate() error {
+	if m.StatusCode == 0 {
+		m.StatusCode = http.StatusServiceUnavailable
+	}
+	if m.StatusCode < 200 || m.StatusCode > 599 {
+		return fmt.Errorf("无效的维护状态码: %d", m.StatusCode)
+	}
+	if m.Message == "" {
+		m.Message = DefaultMaintenanceMessage
+	}
+	return nil
+}
+
+// MaintenanceMode 返回模型当前生效的维护配置，模型自身的维护设置优先于全局设置
+func (m *ModelConfig) MaintenanceMode(global MaintenanceConfig) (MaintenanceConfig, bool) {
+	if m.Maintenance {
+		maintenance := MaintenanceConfig{
+			Enabled:    true,
+			Message:    m.MaintenanceMessage,
+			StatusCode: m.MaintenanceStatus,
+		}
+		maintenance.Validate()
+		return maintenance, true
+	}
+	if global.Enabled {
+		global.Validate()
+		return global, true
+	}
+	return MaintenanceConfig{}, false
+}
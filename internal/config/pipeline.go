@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 内置的请求处理阶段，解析模型配置的resolve阶段总是最先执行，不在pipeline中配置
+const (
+	PipelineStageInject  = "inject"  // 注入Prompt和tools
+	PipelineStageRewrite = "rewrite" // 替换模型ID并生成上游URL
+	PipelineStageCache   = "cache"   // 命中响应缓存时直接返回
+	PipelineStageLimits  = "limits"  // 限制模型的并发请求数
+	PipelineStageForward = "forward" // 转发请求到上游
+)
+
+// DefaultPipeline 模型未配置pipeline时使用的处理阶段顺序
+var DefaultPipeline = []string{
+	PipelineStageInject,
+	PipelineStageRewrite,
+	PipelineStageCache,
+	PipelineStageLimits,
+	PipelineStageForward,
+}
+
+var (
+	pipelineStagesMutex sync.RWMutex
+	pipelineStages      = map[string]bool{
+		PipelineStageInject:  true,
+		PipelineStageRewrite: true,
+		PipelineStageCache:   true,
+		PipelineStageLimits:  true,
+		PipelineStageForward: true,
+	}
+)
+
+// RegisterPipelineStage 登记可在pipeline中使用的阶段名称，由代理注册阶段实现时调用
+func RegisterPipelineStage(name string) {
+	pipelineStagesMutex.Lock()
+	defer pipelineStagesMutex.Unlock()
+	pipelineStages[name] = true
+}
+
+// pipelineStageRegistered 阶段名称是否已登记
+func pipelineStageRegistered(name string) bool {
+	pipelineStagesMutex.RLock()
+	defer pipelineStagesMutex.RUnlock()
+	return pipelineStages[name]
+}
+
+// Stages 返回模型的请求处理阶段顺序，未配置时返回DefaultPipeline
+func (m *ModelConfig) Stages() []string {
+	if len(m.Pipeline) == 0 {
+		return DefaultPipeline
+	}
+	return m.Pipeline
+}
+
+// validatePipeline 验证pipeline中的阶段均已登记且不重复，rewrite在forward之前，forward为最后一个阶段
+func validatePipeline(pipeline []string) []string {
+	if len(pipeline) == 0 {
+		return nil
+	}
+
+	var problems []string
+	seen := make(map[string]bool, len(pipeline))
+	for _, name := range pipeline {
+		switch {
+		case !pipelineStageRegistered(name):
+			problems = append(problems, fmt.Sprintf("未知的处理阶段: %s", name))
+		case seen[name]:
+			problems = append(problems, fmt.Sprintf("处理阶段重复: %s", name))
+		}
+		seen[name] = true
+	}
+	if pipeline[len(pipeline)-1] != PipelineStageForward {
+		problems = append(problems, fmt.Sprintf("最后一个处理阶段必须是%s", PipelineStageForward))
+	}
+	if !seen[PipelineStageRewrite] {
+		problems = append(problems, fmt.Sprintf("缺少%s阶段，无法生成上游URL", PipelineStageRewrite))
+	}
+	return problems
+}
@@ -0,0 +1,188 @@
+package config
+
+// ModelConfigSchema 返回描述ModelConfig的JSON Schema，属性名与管理API的JSON字段名一致，
+// 供Web界面生成模型表单。新增ModelConfig字段时需同步修改此处，TestModelConfigSchemaInSync会检查两者是否一致
+func ModelConfigSchema() map[string]interface{} {
+	stringProp := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "description": description}
+	}
+	intProp := func(description string, minimum int) map[string]interface{} {
+		return map[string]interface{}{"type": "integer", "minimum": minimum, "description": description}
+	}
+	boolProp := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "boolean", "default": false, "description": description}
+	}
+
+	return map[string]interface{}{
+		"$schema":  "https://json-schema.org/draft/2020-12/schema",
+		"title":    "ModelConfig",
+		"type":     "object",
+		"required": []string{"id", "name"},
+		"anyOf": []interface{}{
+			map[string]interface{}{"required": []string{"target", "url"}},
+			map[string]interface{}{"required": []string{"targets"}},
+		},
+		"properties": map[string]interface{}{
+			"id":     stringProp("模型ID，客户端请求时使用"),
+			"name":   stringProp("模型名称"),
+			"target": stringProp("目标模型ID，转发时替换请求中的模型ID"),
+			"prompt": stringProp("Prompt描述"),
+			"url": map[string]interface{}{
+				"type":        "string",
+				"format":      "uri",
+				"pattern":     "^[Hh][Tt][Tt][Pp][Ss]?://",
+				"description": "转发的上游URL，只支持http和https，不能包含用户信息和片段",
+			},
+			"type": map[string]interface{}{
+				"type":        "string",
+				"enum":        []ModelType{ModelTypeChat, ModelTypeImage, ModelTypeAudio, ModelTypeVideo, ModelTypeEmbedding},
+				"default":     ModelTypeChat,
+				"description": "模型类型",
+			},
+			"prompt_path": stringProp("Prompt插入位置(JSON Path)，为空时按模型类型使用默认位置"),
+			"prompt_value": map[string]interface{}{
+				"description": "Prompt值，可以是字符串、数组或对象",
+			},
+			"prompt_value_type": map[string]interface{}{
+				"type":        "string",
+				"enum":        []ValueType{ValueTypeString, ValueTypeArray, ValueTypeObject},
+				"description": "Prompt值类型",
+			},
+			"model_id_source": map[string]interface{}{
+				"type":        "string",
+				"enum":        []ModelIDSource{ModelIDSourceBody, ModelIDSourceQuery, ModelIDSourceHeader, ModelIDSourceForm},
+				"default":     ModelIDSourceBody,
+				"description": "模型ID来源",
+			},
+			"model_id_key":          stringProp("模型ID所在的字段/参数/头部名称，默认model（header来源默认X-Model）"),
+			"disabled":              boolProp("是否禁用，禁用后代理不再接受该模型的请求"),
+			"cache_ttl":             intProp("响应缓存时间(秒)，0表示不缓存", 0),
+			"max_concurrency":       intProp("最大并发请求数，0表示不限制", 0),
+			"queue_on_limit":        boolProp("达到并发上限时排队等待，否则直接返回429"),
+			"queue_timeout":         intProp("排队等待超时时间(秒)，开启排队时默认30", 0),
+			"queue_timeout_ms":      intProp("排队等待超时时间(毫秒)，设置后开启排队并优先于queue_timeout", 0),
+			"max_timeout_ms":        intProp("客户端X-Proxy-Timeout-Ms超时预算的上限(毫秒)，0表示只受全局上限限制", 0),
+			"maintenance":           boolProp("是否处于维护模式"),
+			"maintenance_message":   stringProp("维护提示信息，为空时使用默认信息"),
+			"minify_body":           boolProp("转发前将JSON请求体压缩为紧凑格式（去掉多余空白），非JSON请求体不受影响"),
+			"compress_upstream":     boolProp("客户端发送gzip/deflate压缩的请求体时，处理后按相同格式重新压缩再转发；默认解压后转发"),
+			"health_check_interval": intProp("后台检查上游连通性的间隔(秒)，0表示不检查；最近一次检查失败时代理直接返回503", 0),
+			"health_check_method": map[string]interface{}{
+				"type":        "string",
+				"enum":        []HealthCheckMethod{HealthCheckConnect, HealthCheckHead, HealthCheckRequest},
+				"default":     HealthCheckConnect,
+				"description": "健康检查方式：connect只做DNS解析和TCP/TLS连接（默认），head发送HEAD请求，request发送带模型请求头的最小请求（部分服务商会计费）",
+			},
+			"prompt_position": map[string]interface{}{
+				"type":        "string",
+				"enum":        []PromptPosition{PromptPositionPrepend, PromptPositionAppend, PromptPositionReplaceSystem, PromptPositionMergeSystem},
+				"default":     PromptPositionPrepend,
+				"description": "Prompt消息的插入位置（只支持聊天模型）：prepend插入到首位（默认），append追加到历史消息之后，replace_system删除客户端的system消息后插入到首位，merge_system拼接到客户端第一条system消息之后",
+			},
+			"dedupe_injected_prompt": boolProp("客户端重发的历史消息中已有内容与Prompt相同的system消息时不再注入（只支持聊天模型），避免多轮对话中重复的Prompt"),
+			"aliases": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"uniqueItems": true,
+				"description": "模型别名，请求中使用别名时与模型ID等效，不能与其他模型的ID或别名重复",
+			},
+			"stream_mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []StreamMode{StreamModeAuto, StreamModeSSE, StreamModeJSONArray, StreamModeNDJSON, StreamModeNone},
+				"description": "上游响应的转发方式：auto自动判断（默认），sse/ndjson按行转发，json_array收到数据即转发分块的JSON数组，none读取完整响应后返回",
+			},
+			"targets": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name":   stringProp("变体名称，记录到访问日志的$variant，为空时使用目标模型ID"),
+						"target": stringProp("目标模型ID，为空时使用模型的target"),
+						"url":    stringProp("转发的URL，支持URL模板，为空时使用模型的url"),
+						"weight": intProp("权重，按占全部权重的比例分配请求，0表示不分配", 0),
+					},
+					"required": []string{"weight"},
+				},
+				"description": "按权重分流的目标列表，配置后每个请求按权重随机选择一个目标转发，用于A/B测试",
+			},
+			"canary_target": stringProp("灰度目标模型ID，按canary_percent的比例转发到该目标，不能与targets同时使用"),
+			"canary_url":    stringProp("灰度目标的URL，支持URL模板，为空时使用url"),
+			"canary_percent": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     0,
+				"maximum":     100,
+				"description": "转发到灰度目标的请求百分比，0表示不转发；带X-Proxy-User-ID请求头时同一用户固定转发到同一目标",
+			},
+			"hedge_after_ms": intProp("非流式请求等待上游响应超过该时间(毫秒)后发送相同的对冲请求，使用先响应的结果，0表示不对冲", 0),
+			"max_hedges": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     0,
+				"maximum":     3,
+				"description": "最多发送的对冲请求数，设置hedge_after_ms时默认1；对冲请求同样占用max_concurrency名额",
+			},
+			"prompt_variants": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"id":           stringProp("变体ID，记录到访问日志的$experiment_variant"),
+						"weight":       intProp("权重，按占全部权重的比例分配请求，0表示不分配", 0),
+						"prompt_value": map[string]interface{}{"description": "替换模型Prompt值的值，文本值替换消息的content或prompt文本"},
+					},
+					"required": []string{"id", "weight", "prompt_value"},
+				},
+				"description": "Prompt实验的变体列表，配置后每个请求按权重选择一个变体的Prompt注入；带X-Proxy-Experiment-Key请求头或用户身份时同一会话固定使用同一变体",
+			},
+			"pipeline": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"uniqueItems": true,
+				"description": "请求处理阶段的顺序（inject、rewrite、cache、limits、forward），必须包含rewrite并以forward结尾，为空时使用默认顺序",
+			},
+			"tools": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "object"},
+				"description": "注入到聊天请求tools数组的工具定义，格式与OpenAI的tools相同",
+			},
+			"tools_mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []ToolsMode{ToolsModeAppend, ToolsModeReplace},
+				"description": "客户端已定义tools时的处理方式：append追加（同名函数以客户端为准，默认），replace替换",
+			},
+			"request_headers": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "转发时添加的请求头，覆盖客户端发送的同名请求头",
+			},
+			"response_headers": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+				"description":          "返回客户端时在上游响应头之外添加的响应头",
+			},
+			"signing_secret": map[string]interface{}{
+				"type":        "string",
+				"writeOnly":   true,
+				"description": "请求签名密钥，设置后转发的请求带有X-Proxy-Signature签名；只写，查询接口不返回",
+			},
+			"source": map[string]interface{}{
+				"type":        "string",
+				"enum":        []ModelSource{ModelSourceYAML, ModelSourceAPI},
+				"readOnly":    true,
+				"description": "模型来源：yaml从配置文件加载，api通过管理API创建；重新加载配置文件时不会覆盖api来源的模型",
+			},
+			"tenant": map[string]interface{}{
+				"type":        "string",
+				"pattern":     "^[a-z0-9][a-z0-9_-]{0,63}$",
+				"default":     DefaultTenant,
+				"description": "模型所属的租户，API Key只能调用所属租户的模型；通过管理API创建时，非全局管理员创建的模型属于自己的租户",
+			},
+			"maintenance_status": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     200,
+				"maximum":     599,
+				"default":     503,
+				"description": "维护时返回的HTTP状态码",
+			},
+		},
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+func TestLoadServerConfigExample(t *testing.T) {
+	cfg, err := LoadServerConfig(filepath.Join("..", "..", "server.example.yaml"))
+	if err != nil {
+		t.Fatalf("加载示例配置失败: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("示例配置验证失败: %v", err)
+	}
+
+	want := &ServerConfig{
+		ConfigDir: "./data/configs",
+		Proxy: ListenConfig{Port: "9080", TLS: TLSConfig{
+			CertFile: "/etc/ai-prompt-proxy/proxy.crt",
+			KeyFile:  "/etc/ai-prompt-proxy/proxy.key",
+		}, CORS: CORSConfig{
+			AllowOrigins: []string{"https://playground.example.com"},
+			AllowMethods: []string{"GET", "POST", "OPTIONS"},
+		}},
+		Admin: ListenConfig{Port: "9081", TLS: TLSConfig{
+			CertFile: "/etc/ai-prompt-proxy/admin.crt",
+			KeyFile:  "/etc/ai-prompt-proxy/admin.key",
+		}},
+		Loggers: []logger.OutputConfig{{
+			Name:        "access",
+			Driver:      "file",
+			Description: "访问日志",
+			Enabled:     true,
+			Type:        logger.FormatterJSON,
+			File:        "access.log",
+			Dir:         "./data/logs",
+			Period:      logger.PeriodDay,
+			Expire:      7,
+			Formatter: logger.FormatterConfig{Fields: map[string][]string{
+				"fields": {"$request_id", "$time_iso8601", "$status_code"},
+			}},
+		}},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"https://admin.example.com"},
+			AllowMethods: []string{"GET", "POST"},
+			AllowHeaders: []string{"Content-Type", "Authorization", "X-Request-ID"},
+		},
+		Transport: TransportConfig{
+			Timeout:               5 * time.Minute,
+			DialTimeout:           5 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 2 * time.Minute,
+			IdleConnTimeout:       60 * time.Second,
+			MaxIdleConns:          200,
+			MaxIdleConnsPerHost:   20,
+		},
+		Limits: LimitsConfig{
+			MaxRequestBodySize: 10485760,
+			RequestTimeout:     2 * time.Minute,
+			StreamTimeout:      30 * time.Minute,
+			MaxTimeoutBudget:   time.Minute,
+		},
+		Cache:       CacheConfig{MaxEntries: 500},
+		Idempotency: IdempotencyConfig{TTL: 24 * time.Hour, MaxEntries: 1000},
+		Watch:       WatchConfig{Enabled: true, Interval: 2 * time.Second, Debounce: time.Second},
+		Database: DatabaseConfig{
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		AccessLog: AccessLogConfig{
+			MaskHeaders:   []string{"X-Proxy-Key", "Authorization", "api-key", "x-api-key", "X-Upstream-Token"},
+			StartupPolicy: LoggerStartupFail,
+		},
+		Drift:          DriftConfig{Policy: DriftPolicyManual},
+		Login:          LoginConfig{MaxFailedAttempts: 10, LockoutDuration: 30 * time.Minute},
+		TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "fd00::/8"},
+		Alerts: AlertsConfig{
+			ErrorRateThreshold:   0.2,
+			ErrorRateWindow:      10 * time.Minute,
+			ErrorRateMinRequests: 50,
+			KeyExpiryDays:        14,
+			CheckInterval:        time.Hour,
+			QueueSize:            1000,
+			MaxRetries:           5,
+			Timeout:              5 * time.Second,
+		},
+		Trash: TrashConfig{Retention: 7 * 24 * time.Hour, PurgeInterval: 30 * time.Minute},
+	}
+
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("示例配置不匹配\n got: %+v\nwant: %+v", cfg, want)
+	}
+}
+
+func TestLoadServerConfigMissingFile(t *testing.T) {
+	for _, path := range []string{"", filepath.Join(t.TempDir(), "server.yaml")} {
+		cfg, err := LoadServerConfig(path)
+		if err != nil {
+			t.Fatalf("LoadServerConfig(%q) failed: %v", path, err)
+		}
+		if !reflect.DeepEqual(cfg, DefaultServerConfig()) {
+			t.Errorf("LoadServerConfig(%q) 应返回默认配置, got %+v", path, cfg)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("默认配置验证失败: %v", err)
+		}
+	}
+
+	// 默认启用内存日志，管理API可以直接查看最近的请求
+	recent := DefaultServerConfig().Loggers[1]
+	if recent.Driver != logger.DriverMemory || !recent.Enabled || recent.BufferBytes <= 0 || recent.BufferBytes > logger.MaxMemoryBufferBytes {
+		t.Errorf("默认的内存日志配置 = %+v", recent)
+	}
+}
+
+func TestServerConfigEnvOverride(t *testing.T) {
+	t.Setenv("APP_PROXY_PORT", "18080")
+	t.Setenv("APP_CORS_ALLOW_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("APP_TRANSPORT_TIMEOUT", "30s")
+	t.Setenv("APP_DATABASE_DSN", "postgres://proxy@db.internal:5432/proxy")
+	t.Setenv("APP_DATABASE_MAX_OPEN_CONNS", "50")
+	t.Setenv("APP_ACCESS_LOG_MASK_HEADERS", "X-Proxy-Key, X-Secret")
+	t.Setenv("APP_TRUSTED_PROXIES", "192.168.0.0/16, ::1")
+	t.Setenv("APP_ACCESS_LOG_STARTUP_POLICY", "warn")
+	t.Setenv("APP_DRIFT_POLICY", "yaml_wins")
+
+	cfg, err := LoadServerConfig(filepath.Join("..", "..", "server.example.yaml"))
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.Proxy.Port != "18080" {
+		t.Errorf("proxy.port = %q, want 18080", cfg.Proxy.Port)
+	}
+	if !reflect.DeepEqual(cfg.CORS.AllowOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("cors.allow_origins = %v", cfg.CORS.AllowOrigins)
+	}
+	if cfg.Transport.Timeout != 30*time.Second {
+		t.Errorf("transport.timeout = %v, want 30s", cfg.Transport.Timeout)
+	}
+	if cfg.Database.DSN != "postgres://proxy@db.internal:5432/proxy" || cfg.Database.MaxOpenConns != 50 {
+		t.Errorf("database = %+v", cfg.Database)
+	}
+	if !reflect.DeepEqual(cfg.AccessLog.MaskHeaders, []string{"X-Proxy-Key", "X-Secret"}) {
+		t.Errorf("access_log.mask_headers = %v", cfg.AccessLog.MaskHeaders)
+	}
+	if cfg.AccessLog.StartupPolicy != LoggerStartupWarn {
+		t.Errorf("access_log.startup_policy = %q, want warn", cfg.AccessLog.StartupPolicy)
+	}
+	if cfg.Drift.Policy != DriftPolicyYAMLWins {
+		t.Errorf("drift.policy = %q, want yaml_wins", cfg.Drift.Policy)
+	}
+	if !reflect.DeepEqual(cfg.TrustedProxies, []string{"192.168.0.0/16", "::1"}) {
+		t.Errorf("trusted_proxies = %v", cfg.TrustedProxies)
+	}
+}
+
+func TestServerConfigValidateListsAllErrors(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.Proxy.Port = "abc"
+	cfg.Admin.TLS.CertFile = "admin.crt"
+	cfg.Loggers[0].Type = "xml"
+	cfg.Limits.MaxRequestBodySize = -1
+	cfg.TrustedProxies = []string{"10.0.0.0/33"}
+	cfg.AccessLog.StartupPolicy = "ignore"
+	cfg.Idempotency.TTL = 0
+	cfg.Drift.Policy = "newest"
+	cfg.Login.LockoutDuration = 0
+	cfg.Alerts.ErrorRateThreshold = 1.5
+	cfg.Trash.PurgeInterval = 0
+	cfg.Loggers = append(cfg.Loggers, logger.OutputConfig{Name: "stdout", Driver: logger.DriverStdout, Type: logger.FormatterLine, HashChain: true},
+		logger.OutputConfig{Name: "buffer", Driver: logger.DriverMemory, Type: logger.FormatterJSON, BufferSize: logger.MaxMemoryBufferSize + 1, BufferBytes: -1})
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("期望验证失败")
+	}
+	for _, field := range []string{"proxy.port", "admin.tls", "loggers[0].type", "limits.max_request_body_size", "trusted_proxies[0]", "access_log.startup_policy", "idempotency.ttl", "drift.policy", "login.lockout_duration", "alerts.error_rate_threshold", "trash.purge_interval", "loggers[2].hash_chain", "loggers[3].buffer_size", "loggers[3].buffer_bytes"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("错误信息缺少 %s: %v", field, err)
+		}
+	}
+}
+
+func TestCORSMerge(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.CORS.AllowOrigins = []string{"https://admin.example.com"}
+	cfg.Proxy.CORS.AllowOrigins = []string{"https://app.example.com"}
+
+	proxy := cfg.ProxyCORS()
+	if proxy.AllowedOrigin("https://APP.example.com") != "https://APP.example.com" || proxy.AllowedOrigin("https://admin.example.com") != "" {
+		t.Errorf("代理应只允许proxy.cors中的来源，实际%v", proxy.AllowOrigins)
+	}
+	if !reflect.DeepEqual(proxy.AllowHeaders, cfg.CORS.AllowHeaders) || !proxy.AllowsMethod("post") {
+		t.Errorf("proxy.cors未设置的项应使用顶层配置，实际%+v", proxy)
+	}
+	if admin := cfg.AdminCORS(); admin.AllowedOrigin("https://admin.example.com") == "" {
+		t.Errorf("管理API应使用顶层cors配置，实际%v", admin.AllowOrigins)
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WeightedTarget 按权重分流的一个上游目标，用于A/B测试等按比例转发的场景
+type WeightedTarget struct {
+	Name   string `yaml:"name,omitempty" json:"name"` // 变体名称，记录到访问日志的$variant，为空时使用目标模型ID
+	Target string `yaml:"target" json:"target"`       // 目标模型ID，为空时使用模型的target
+	Url    string `yaml:"url" json:"url"`             // 转发的URL，支持URL模板，为空时使用模型的url
+	Weight int    `yaml:"weight" json:"weight"`       // 权重，按占全部权重的比例分配请求，0表示不分配
+}
+
+// WithTarget 返回转发到指定目标的模型配置副本，原配置不受影响
+func (m *ModelConfig) WithTarget(target WeightedTarget) *ModelConfig {
+	variant := *m
+	variant.Target = target.Target
+	variant.Url = target.Url
+	return &variant
+}
+
+// validateUpstream 验证目标模型ID和转发的URL，field为错误所属字段的前缀（如targets[0].），
+// 返回规范化后的URL
+func validateUpstream(errs *ValidationErrors, field, target, rawURL string) string {
+	if target == "" {
+		errs.add(field+"target", "目标模型ID不能为空")
+	}
+	if rawURL == "" {
+		errs.add(field+"url", "转发的URL不能为空")
+		return rawURL
+	}
+	if urlPlaceholderPattern.MatchString(rawURL) {
+		// URL模板在请求时展开，只检查展开后的URL，保留模板本身
+		if err := validateURLTemplate(rawURL); err != nil {
+			errs.add(field+"url", "%v", err)
+		} else {
+			rawURL = strings.TrimRight(strings.TrimSpace(rawURL), "/")
+		}
+		if strings.Contains(rawURL, "{"+URLPlaceholderTarget+"}") && target != "" {
+			if _, err := escapeURLSegment(URLPlaceholderTarget, target); err != nil {
+				errs.add(field+"target", "%v", err)
+			}
+		}
+		return rawURL
+	}
+	normalized, err := normalizeUpstreamURL(rawURL)
+	if err != nil {
+		errs.add(field+"url", "%v", err)
+		return rawURL
+	}
+	return normalized
+}
+
+// validateTargets 验证按权重分流的目标列表，未设置target或url的条目使用模型的值，
+// 未设置名称的条目使用目标模型ID作为名称
+func (m *ModelConfig) validateTargets(errs *ValidationErrors) {
+	names := make(map[string]bool, len(m.Targets))
+	totalWeight := 0
+	for i := range m.Targets {
+		target := &m.Targets[i]
+		field := fmt.Sprintf("targets[%d].", i)
+		if target.Target == "" {
+			target.Target = m.Target
+		}
+		if target.Url == "" {
+			target.Url = m.Url
+		}
+		target.Url = validateUpstream(errs, field, target.Target, target.Url)
+		if target.Name == "" {
+			target.Name = target.Target
+		}
+		if names[target.Name] {
+			errs.add(field+"name", "变体名称重复: %s，同一目标模型配置多个条目时需设置name", target.Name)
+		}
+		names[target.Name] = true
+		if target.Weight < 0 {
+			errs.add(field+"weight", "权重不能为负数: %d", target.Weight)
+		} else {
+			totalWeight += target.Weight
+		}
+	}
+	if totalWeight == 0 {
+		errs.add("targets", "至少需要一个目标的权重大于0")
+	}
+}
+
+// CanaryEnabled 是否有请求需要转发到灰度目标
+func (m *ModelConfig) CanaryEnabled() bool {
+	return m.CanaryPercent > 0 && m.CanaryTarget != ""
+}
+
+// CanaryUpstream 返回灰度目标，未设置canary_url时使用模型的url
+func (m *ModelConfig) CanaryUpstream() WeightedTarget {
+	url := m.CanaryUrl
+	if url == "" {
+		url = m.Url
+	}
+	return WeightedTarget{Name: m.CanaryTarget, Target: m.CanaryTarget, Url: url}
+}
+
+// validateCanary 验证灰度发布配置，未设置灰度目标和比例时不做检查
+func (m *ModelConfig) validateCanary(errs *ValidationErrors) {
+	if m.CanaryPercent < 0 || m.CanaryPercent > 100 {
+		errs.add("canary_percent", "灰度比例应在0到100之间: %d", m.CanaryPercent)
+	}
+	if m.CanaryTarget == "" && m.CanaryUrl == "" {
+		if m.CanaryPercent > 0 {
+			errs.add("canary_target", "设置canary_percent时灰度目标模型ID不能为空")
+		}
+		return
+	}
+	if len(m.Targets) > 0 {
+		errs.add("canary_target", "灰度发布不能与targets同时使用")
+		return
+	}
+	if m.CanaryUrl != "" {
+		m.CanaryUrl = validateUpstream(errs, "canary_", m.CanaryTarget, m.CanaryUrl)
+	} else if m.CanaryTarget == "" {
+		errs.add("canary_target", "灰度目标模型ID不能为空")
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import "regexp"
+
+// DefaultTenant 默认租户ID，未指定租户的模型、用户和API Key属于该租户，升级前的数据迁移到该租户
+const DefaultTenant = "default"
+
+// tenantIDPattern 租户ID只能包含小写字母、数字、下划线和短横线，以字母或数字开头
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidTenantID 租户ID是否有效
+func ValidTenantID(id string) bool {
+	return tenantIDPattern.MatchString(id)
+}
+
+// TenantOrDefault 返回租户ID，为空时返回DefaultTenant
+func TenantOrDefault(id string) string {
+	if id == "" {
+		return DefaultTenant
+	}
+	return id
+}
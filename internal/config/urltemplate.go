@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// urlPlaceholderPattern 匹配转发URL中的{name}占位符
+var urlPlaceholderPattern = regexp.MustCompile(`\{([A-Za-z_]+)\}`)
+
+// 转发URL模板支持的占位符
+const (
+	URLPlaceholderTarget = "target" // 目标模型ID
+	URLPlaceholderModel  = "model"  // 客户端请求的模型ID
+	URLPlaceholderPath   = "path"   // 客户端请求的路径，不含开头的/
+)
+
+// ExpandURL 展开转发URL中的{target}、{model}和{path}占位符，替换的值经过URL转义；
+// 不含占位符时原样返回Url
+func (m *ModelConfig) ExpandURL(model, requestPath string) (string, error) {
+	return expandURLTemplate(m.Url, m.Target, model, requestPath)
+}
+
+// expandURLTemplate 展开URL模板中的占位符
+func expandURLTemplate(template, target, model, requestPath string) (string, error) {
+	if !urlPlaceholderPattern.MatchString(template) {
+		return template, nil
+	}
+
+	var expandErr error
+	result := urlPlaceholderPattern.ReplaceAllStringFunc(template, func(ref string) string {
+		var value string
+		var err error
+		switch name := ref[1 : len(ref)-1]; name {
+		case URLPlaceholderTarget:
+			value, err = escapeURLSegment(name, target)
+		case URLPlaceholderModel:
+			value, err = escapeURLSegment(name, model)
+		case URLPlaceholderPath:
+			value, err = escapeURLPath(requestPath)
+		default:
+			err = fmt.Errorf("未知的URL占位符: %s", ref)
+		}
+		if err != nil && expandErr == nil {
+			expandErr = err
+		}
+		return value
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
+
+// escapeURLSegment 转义作为单个路径段的值，拒绝为空或包含路径穿越字符(..、/、\)的值
+func escapeURLSegment(name, value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("URL占位符{%s}的值为空", name)
+	}
+	if strings.Contains(value, "..") || strings.ContainsAny(value, "/\\") {
+		return "", fmt.Errorf("URL占位符{%s}的值不能包含路径穿越字符: %q", name, value)
+	}
+	return url.PathEscape(value), nil
+}
+
+// escapeURLPath 逐段转义请求路径，拒绝包含.或..路径段的路径
+func escapeURLPath(requestPath string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(requestPath, "/"), "/")
+	for i, segment := range segments {
+		if segment == "." || segment == ".." || strings.Contains(segment, "\\") {
+			return "", fmt.Errorf("URL占位符{path}的值不能包含路径穿越: %q", requestPath)
+		}
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// validateURLTemplate 使用示例值展开URL模板，检查展开后的URL是否有效
+func validateURLTemplate(template string) error {
+	expanded, err := expandURLTemplate(template, "target", "model", "/v1/path")
+	if err != nil {
+		return err
+	}
+	_, err = normalizeUpstreamURL(expanded)
+	return err
+}
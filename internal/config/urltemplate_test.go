@@ -0,0 +1,73 @@
+package config
+
+import "testing"
+
+func TestExpandURL(t *testing.T) {
+	tests := []struct {
+		template string
+		target   string
+		model    string
+		path     string
+		want     string
+	}{
+		// 不含占位符时原样返回
+		{"https://api.openai.com/v1/images/generations", "dall-e-3", "image", "/v1/images/generations", "https://api.openai.com/v1/images/generations"},
+		{"https://azure.example.com/openai/deployments/{target}/images/generations?api-version=2024-02-01", "dalle3-prod", "image", "/v1/images/generations",
+			"https://azure.example.com/openai/deployments/dalle3-prod/images/generations?api-version=2024-02-01"},
+		{"https://sd.internal/{model}/{path}", "sdxl", "my model", "/sdapi/v1/txt2img", "https://sd.internal/my%20model/sdapi/v1/txt2img"},
+		{"https://gateway.internal/{target}", "a?b#c", "m", "/", "https://gateway.internal/a%3Fb%23c"},
+	}
+	for _, tt := range tests {
+		model := &ModelConfig{Url: tt.template, Target: tt.target}
+		got, err := model.ExpandURL(tt.model, tt.path)
+		if err != nil {
+			t.Errorf("ExpandURL(%q) 返回错误: %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandURL(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestExpandURLRejectsTraversal(t *testing.T) {
+	tests := []struct {
+		template string
+		target   string
+		model    string
+		path     string
+	}{
+		{"https://h/deployments/{target}", "../admin", "m", "/"},
+		{"https://h/deployments/{target}", "a/b", "m", "/"},
+		{"https://h/deployments/{model}", "t", `..\admin`, "/"},
+		{"https://h/{path}", "t", "m", "/v1/../admin"},
+		{"https://h/{unknown}", "t", "m", "/"},
+	}
+	for _, tt := range tests {
+		model := &ModelConfig{Url: tt.template, Target: tt.target}
+		if got, err := model.ExpandURL(tt.model, tt.path); err == nil {
+			t.Errorf("ExpandURL(%q, target=%q, model=%q, path=%q) 应返回错误，实际得到%q", tt.template, tt.target, tt.model, tt.path, got)
+		}
+	}
+}
+
+func TestValidateURLTemplate(t *testing.T) {
+	model := &ModelConfig{ID: "img", Name: "图片", Target: "dalle3", Type: ModelTypeImage,
+		Url: "https://azure.example.com/openai/deployments/{target}/images/generations/"}
+	if err := model.Validate(); err != nil {
+		t.Fatalf("有效的URL模板验证失败: %v", err)
+	}
+	if model.Url != "https://azure.example.com/openai/deployments/{target}/images/generations" {
+		t.Errorf("验证后应保留URL模板，实际得到%q", model.Url)
+	}
+
+	for _, invalid := range []*ModelConfig{
+		{ID: "a", Name: "a", Target: "t", Url: "ftp://h/{target}"},
+		{ID: "b", Name: "b", Target: "t", Url: "https://h/{deployment}"},
+		{ID: "c", Name: "c", Target: "../t", Url: "https://h/{target}"},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("URL模板%q(target=%q)应验证失败", invalid.Url, invalid.Target)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError 单个字段的验证错误，Field为管理API中的JSON字段名
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 模型配置的全部字段验证错误，便于前端同时标出所有有问题的输入
+type ValidationErrors []FieldError
+
+// Error 实现error接口，按顺序拼接所有错误信息
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add 添加一个字段错误
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefixLength 保存的API Key前缀长度，用于列表中的预览
+const apiKeyPrefixLength = 8
+
+// HashAPIKey 计算API Key值的SHA-256哈希，数据库按哈希查找API Key
+func HashAPIKey(keyValue string) string {
+	sum := sha256.Sum256([]byte(keyValue))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyPrefix 截取API Key值的前缀
+func apiKeyPrefix(keyValue string) string {
+	if len(keyValue) > apiKeyPrefixLength {
+		return keyValue[:apiKeyPrefixLength]
+	}
+	return keyValue
+}
+
+// Preview 返回API Key的预览（前8位+***），不需要完整的Key值
+func (k *APIKey) Preview() string {
+	return k.KeyPrefix + "***"
+}
+
+// BeforeSave 设置了Key值（创建或重新生成）时更新哈希和前缀，Key值本身不保存
+func (k *APIKey) BeforeSave(tx *gorm.DB) error {
+	if k.KeyValue != "" {
+		k.KeyHash = HashAPIKey(k.KeyValue)
+		k.KeyPrefix = apiKeyPrefix(k.KeyValue)
+	}
+	return nil
+}
+
+// BeforeCreate 创建的API Key必须有Key值，未指定租户时属于默认租户
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.KeyHash == "" {
+		return fmt.Errorf("API Key值不能为空")
+	}
+	k.TenantID = config.TenantOrDefault(k.TenantID)
+	return nil
+}
+
+// legacyAPIKey 升级前以明文保存Key值的API Key记录
+type legacyAPIKey struct {
+	ID       uint
+	KeyValue string
+}
+
+// migrateAPIKeyHashes 将旧版本以明文保存的API Key转换为哈希和前缀，并删除明文列；
+// 明文列不存在时不做任何操作，可以重复执行
+func migrateAPIKeyHashes(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&APIKey{}, "key_value") {
+		return nil
+	}
+
+	var rows []legacyAPIKey
+	if err := db.Table(APIKey{}.TableName()).Select("id, key_value").
+		Where("key_value <> ? AND (key_hash IS NULL OR key_hash = ?)", "", "").Find(&rows).Error; err != nil {
+		return fmt.Errorf("读取明文API Key失败: %w", err)
+	}
+	for _, row := range rows {
+		if err := db.Table(APIKey{}.TableName()).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"key_hash":   HashAPIKey(row.KeyValue),
+			"key_prefix": apiKeyPrefix(row.KeyValue),
+		}).Error; err != nil {
+			return fmt.Errorf("转换API Key %d失败: %w", row.ID, err)
+		}
+	}
+
+	if migrator.HasIndex(&APIKey{}, "idx_api_keys_key_value") {
+		if err := migrator.DropIndex(&APIKey{}, "idx_api_keys_key_value"); err != nil {
+			return fmt.Errorf("删除明文API Key索引失败: %w", err)
+		}
+	}
+	if err := migrator.DropColumn(&APIKey{}, "key_value"); err != nil {
+		return fmt.Errorf("删除明文API Key列失败: %w", err)
+	}
+	// SQLite删除数据后旧内容仍可能留在空闲页中，整理数据库文件以清除明文
+	if db.Dialector.Name() == DriverSQLite {
+		if err := db.Exec("VACUUM").Error; err != nil {
+			return fmt.Errorf("整理数据库文件失败: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build mysql
+
+package db
+
+import "gorm.io/driver/mysql"
+
+func init() {
+	registerDialector(DriverMySQL, mysql.Open)
+}
@@ -0,0 +1,9 @@
+//go:build postgres
+
+package db
+
+import "gorm.io/driver/postgres"
+
+func init() {
+	registerDialector(DriverPostgres, postgres.Open)
+}
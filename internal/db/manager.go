@@ -1,41 +1,73 @@
 package db
 
 import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 // Manager 数据库管理器
 type Manager struct {
-	db *gorm.DB
+	db      *gorm.DB
+	secrets cipher.AEAD // 加密模型签名密钥等敏感字段
 }
 
-// NewManager 创建数据库管理器
+// LogLevel 数据库SQL日志的级别，命令行子命令设为logger.Silent，避免SQL日志混入命令输出
+var LogLevel = logger.Info
+
+// NewManager 创建使用dbPath目录下SQLite数据库的管理器
 func NewManager(dbPath string) (*Manager, error) {
-	// 确保数据库目录存在
-	if err := os.MkdirAll(dbPath, 0755); err != nil {
-		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+	return NewManagerWithConfig(dbPath, config.DatabaseConfig{})
+}
+
+// NewManagerWithConfig 根据数据库配置创建数据库管理器，DSN为空时使用dbPath目录下的SQLite数据库
+func NewManagerWithConfig(dbPath string, dbConfig config.DatabaseConfig) (*Manager, error) {
+	dsn := dbConfig.DSN
+	if dsn == "" {
+		// 确保数据库目录存在
+		if err := os.MkdirAll(dbPath, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+		}
+		dsn = DriverSQLite + "://" + filepath.Join(dbPath, "config.db")
 	}
 
-	// 设置数据库文件路径
-	dbFile := filepath.Join(dbPath, "config.db")
+	dialector, err := openDialector(dsn)
+	if err != nil {
+		return nil, err
+	}
 
 	// 打开数据库连接
-	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(LogLevel),
 	})
 
 	if err != nil {
 		return nil, fmt.Errorf("连接数据库失败: %w", err)
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库连接池失败: %w", err)
+	}
+	if dbConfig.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(dbConfig.MaxOpenConns)
+	}
+	if dbConfig.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(dbConfig.MaxIdleConns)
+	}
+	if dbConfig.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(dbConfig.ConnMaxLifetime)
+	}
+
 	manager := &Manager{db: db}
 
 	// 自动迁移数据库表
@@ -43,18 +75,31 @@ func NewManager(dbPath string) (*Manager, error) {
 		return nil, fmt.Errorf("数据库迁移失败: %w", err)
 	}
 
+	if manager.secrets, err = manager.loadSecretCipher(dbConfig.MasterKey); err != nil {
+		return nil, err
+	}
+
 	return manager, nil
 }
 
 // migrate 执行数据库迁移
 func (m *Manager) migrate() error {
-	return m.db.AutoMigrate(&ModelConfigDB{}, &ConfigMetadata{}, &User{}, &APIKey{})
+	if err := m.db.AutoMigrate(&ModelConfigDB{}, &ConfigMetadata{}, &User{}, &APIKey{}, &ModelUsage{}, &Session{}, &Webhook{}, &Tenant{}); err != nil {
+		return err
+	}
+	if err := migrateUserRoles(m.db); err != nil {
+		return err
+	}
+	if err := migrateTenants(m.db); err != nil {
+		return err
+	}
+	return migrateAPIKeyHashes(m.db)
 }
 
 // SaveModelConfig 保存模型配置
 func (m *Manager) SaveModelConfig(cfg *config.ModelConfig) error {
-	dbModel := &ModelConfigDB{}
-	if err := dbModel.FromModelConfig(cfg); err != nil {
+	dbModel, err := m.toDBModel(cfg)
+	if err != nil {
 		return fmt.Errorf("转换模型配置失败: %w", err)
 	}
 
@@ -67,6 +112,43 @@ func (m *Manager) SaveModelConfig(cfg *config.ModelConfig) error {
 	return nil
 }
 
+// ReplaceModelConfigs 在一个事务中用给定的模型配置替换全部模型配置，不在其中的模型移入回收站，
+// 任一步失败时不做任何修改
+func (m *Manager) ReplaceModelConfigs(models map[string]*config.ModelConfig) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]string, 0, len(models))
+		for id := range models {
+			ids = append(ids, id)
+		}
+
+		query := tx.Model(&ModelConfigDB{})
+		if len(ids) > 0 {
+			query = query.Where("id NOT IN ?", ids)
+		}
+		var removed []string
+		if err := query.Pluck("id", &removed).Error; err != nil {
+			return fmt.Errorf("查询模型配置失败: %w", err)
+		}
+		now := time.Now()
+		for _, id := range removed {
+			if _, err := trashModelConfig(tx, id, now); err != nil {
+				return fmt.Errorf("删除模型配置失败 %s: %w", id, err)
+			}
+		}
+
+		for _, cfg := range models {
+			dbModel, err := m.toDBModel(cfg)
+			if err != nil {
+				return fmt.Errorf("转换模型配置失败 %s: %w", cfg.ID, err)
+			}
+			if err := tx.Save(dbModel).Error; err != nil {
+				return fmt.Errorf("保存模型配置失败 %s: %w", cfg.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
 // GetModelConfig 获取模型配置
 func (m *Manager) GetModelConfig(id string) (*config.ModelConfig, error) {
 	var dbModel ModelConfigDB
@@ -78,7 +160,7 @@ func (m *Manager) GetModelConfig(id string) (*config.ModelConfig, error) {
 		return nil, fmt.Errorf("获取模型配置失败: %w", result.Error)
 	}
 
-	return dbModel.ToModelConfig()
+	return m.fromDBModel(&dbModel)
 }
 
 // GetModelConfigWithTime 获取模型配置（包含时间信息）
@@ -91,6 +173,9 @@ func (m *Manager) GetModelConfigWithTime(id string) (*ModelConfigDB, error) {
 		}
 		return nil, fmt.Errorf("获取模型配置失败: %w", result.Error)
 	}
+	if err := m.openRequestHeaders(&dbModel); err != nil {
+		return nil, err
+	}
 
 	return &dbModel, nil
 }
@@ -104,8 +189,9 @@ func (m *Manager) GetAllModelConfigs() (map[string]*config.ModelConfig, error) {
 	}
 
 	configs := make(map[string]*config.ModelConfig)
-	for _, dbModel := range dbModels {
-		cfg, err := dbModel.ToModelConfig()
+	for i := range dbModels {
+		dbModel := &dbModels[i]
+		cfg, err := m.fromDBModel(dbModel)
 		if err != nil {
 			return nil, fmt.Errorf("转换模型配置失败 %s: %w", dbModel.ID, err)
 		}
@@ -122,17 +208,101 @@ func (m *Manager) GetAllModelConfigsWithTime() ([]ModelConfigDB, error) {
 	if result.Error != nil {
 		return nil, fmt.Errorf("获取所有模型配置失败: %w", result.Error)
 	}
+	for i := range dbModels {
+		if err := m.openRequestHeaders(&dbModels[i]); err != nil {
+			return nil, fmt.Errorf("解密模型配置失败 %s: %w", dbModels[i].ID, err)
+		}
+	}
 
 	return dbModels, nil
 }
 
-// DeleteModelConfig 删除模型配置
+// ModelQuery 模型列表查询条件
+type ModelQuery struct {
+	Page     int    // 页码，从1开始
+	PageSize int    // 每页数量，0表示不分页
+	Type     string // 模型类型过滤
+	Search   string // 按ID/名称/目标模型搜索（不区分大小写）
+	SortBy   string // 排序字段：created_at/updated_at/name
+	Desc     bool   // 是否倒序
+
+	UnusedSince time.Time // 只返回该时间之后没有被调用过的模型，零值表示不过滤
+	Tenant      string    // 只返回该租户的模型，为空表示不过滤
+}
+
+// modelSortColumns 允许排序的字段
+var modelSortColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"name":       true,
+}
+
+// Normalize 规范化查询条件
+func (q *ModelQuery) Normalize() {
+	if !modelSortColumns[q.SortBy] {
+		q.SortBy = "updated_at"
+		q.Desc = true
+	}
+	if q.PageSize > 0 && q.Page < 1 {
+		q.Page = 1
+	}
+	q.Search = strings.TrimSpace(q.Search)
+}
+
+// QueryModelConfigs 按条件分页查询模型配置（包含时间信息），返回当前页数据和总数
+func (m *Manager) QueryModelConfigs(q ModelQuery) ([]ModelConfigDB, int64, error) {
+	q.Normalize()
+
+	query := m.db.Model(&ModelConfigDB{})
+	if q.Tenant != "" {
+		query = query.Where("tenant_id = ?", q.Tenant)
+	}
+	if q.Type != "" {
+		query = query.Where("type = ?", q.Type)
+	}
+	if q.Search != "" {
+		pattern := containsPattern(q.Search)
+		query = query.Where("LOWER(id) LIKE ? ESCAPE '!' OR LOWER(name) LIKE ? ESCAPE '!' OR LOWER(target) LIKE ? ESCAPE '!'", pattern, pattern, pattern)
+	}
+	if !q.UnusedSince.IsZero() {
+		used := m.db.Model(&ModelUsage{}).Select("model_id").Where("last_used_at >= ?", q.UnusedSince)
+		query = query.Where("id NOT IN (?)", used)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计模型配置失败: %w", err)
+	}
+
+	order := q.SortBy
+	if q.Desc {
+		order += " DESC"
+	}
+	query = query.Order(order).Order("id")
+	if q.PageSize > 0 {
+		query = query.Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize)
+	}
+
+	var dbModels []ModelConfigDB
+	if err := query.Find(&dbModels).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询模型配置失败: %w", err)
+	}
+	for i := range dbModels {
+		if err := m.openRequestHeaders(&dbModels[i]); err != nil {
+			return nil, 0, fmt.Errorf("解密模型配置失败 %s: %w", dbModels[i].ID, err)
+		}
+	}
+
+	return dbModels, total, nil
+}
+
+// DeleteModelConfig 删除模型配置，模型移入回收站，可以恢复或永久删除
 func (m *Manager) DeleteModelConfig(id string) error {
-	result := m.db.Where("id = ?", id).Delete(&ModelConfigDB{})
-	if result.Error != nil {
-		return fmt.Errorf("删除模型配置失败: %w", result.Error)
+	rows, err := trashModelConfig(m.db, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("删除模型配置失败: %w", err)
 	}
-	if result.RowsAffected == 0 {
+	if rows == 0 {
 		return fmt.Errorf("模型配置不存在: %s", id)
 	}
 	return nil
@@ -151,13 +321,13 @@ func (m *Manager) UpdateModelConfig(cfg *config.ModelConfig) error {
 	}
 
 	// 更新配置
-	dbModel := &ModelConfigDB{}
-	if err := dbModel.FromModelConfig(cfg); err != nil {
+	dbModel, err := m.toDBModel(cfg)
+	if err != nil {
 		return fmt.Errorf("转换模型配置失败: %w", err)
 	}
 
 	// 使用Select明确指定要更新的字段，包括可能为空的字段
-	result = m.db.Model(&existing).Select("name", "target", "prompt", "url", "type", "prompt_path", "prompt_value", "prompt_value_type").Updates(dbModel)
+	result = m.db.Model(&existing).Select("name", "target", "prompt", "url", "type", "prompt_path", "prompt_value", "prompt_value_type", "model_id_source", "model_id_key", "disabled", "cache_ttl", "max_concurrency", "queue_on_limit", "queue_timeout", "maintenance", "maintenance_message", "maintenance_status", "aliases", "signing_secret", "tools", "tools_mode", "max_timeout_ms", "request_headers", "response_headers", "queue_timeout_ms", "source", "pipeline", "stream_mode", "targets", "minify_body", "compress_upstream", "prompt_variants", "health_check_interval", "health_check_method", "prompt_position", "dedupe_injected_prompt", "canary_target", "canary_url", "canary_percent", "hedge_after_ms", "max_hedges", "tenant_id").Updates(dbModel)
 	if result.Error != nil {
 		return fmt.Errorf("更新模型配置失败: %w", result.Error)
 	}
@@ -168,7 +338,8 @@ func (m *Manager) UpdateModelConfig(cfg *config.ModelConfig) error {
 // GetMetadata 获取配置元数据
 func (m *Manager) GetMetadata(key string) (string, error) {
 	var metadata ConfigMetadata
-	result := m.db.Where("key = ?", key).First(&metadata)
+	// key是MySQL的保留字，使用结构体条件由GORM转义列名
+	result := m.db.Where(&ConfigMetadata{Key: key}).First(&metadata)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return "", fmt.Errorf("元数据不存在: %s", key)
@@ -200,6 +371,21 @@ func (m *Manager) CreateUser(user *User) error {
 	return nil
 }
 
+// CreateUserWithAPIKey 在同一事务中创建用户和属于该用户的第一个API Key
+func (m *Manager) CreateUserWithAPIKey(user *User, apiKey *APIKey) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("创建用户失败: %w", err)
+		}
+		apiKey.UserID = user.ID
+		apiKey.TenantID = user.TenantID
+		if err := tx.Omit("User").Create(apiKey).Error; err != nil {
+			return fmt.Errorf("创建API Key失败: %w", err)
+		}
+		return nil
+	})
+}
+
 // GetUserByUsername 根据用户名获取用户
 func (m *Manager) GetUserByUsername(username string) (*User, error) {
 	var user User
@@ -245,26 +431,98 @@ func (m *Manager) GetUserCount() (int64, error) {
 	return count, nil
 }
 
-// GetAllUsers 获取所有用户列表
-func (m *Manager) GetAllUsers() ([]User, error) {
-	var users []User
-	result := m.db.Order("created_at DESC").Find(&users)
-	if result.Error != nil {
-		return nil, fmt.Errorf("获取用户列表失败: %w", result.Error)
-	}
-	return users, nil
+// UserQuery 用户列表查询条件
+type UserQuery struct {
+	Page          int    // 页码，从1开始
+	PageSize      int    // 每页数量，0表示不分页
+	IncludeAdmins bool   // 是否包含角色为superuser的管理员
+	Tenant        string // 只返回该租户的用户，为空表示不过滤
 }
 
-// DeleteUser 删除用户
-func (m *Manager) DeleteUser(id uint) error {
-	result := m.db.Where("id = ?", id).Delete(&User{})
-	if result.Error != nil {
-		return fmt.Errorf("删除用户失败: %w", result.Error)
+// QueryUsers 按创建时间倒序分页查询用户，返回当前页数据和总数
+func (m *Manager) QueryUsers(q UserQuery) ([]User, int64, error) {
+	return m.SearchUsers("", q)
+}
+
+// SearchUsers 按用户名搜索用户（不区分大小写的子串匹配），keyword为空时不过滤；
+// 按创建时间倒序分页，返回当前页数据和匹配的总数
+func (m *Manager) SearchUsers(keyword string, q UserQuery) ([]User, int64, error) {
+	query := m.db.Model(&User{})
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		query = query.Where("LOWER(username) LIKE ? ESCAPE '!'", containsPattern(keyword))
 	}
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("用户不存在: %d", id)
+	if !q.IncludeAdmins {
+		query = query.Where("role <> ?", RoleSuperuser)
 	}
-	return nil
+	if q.Tenant != "" {
+		query = query.Where("tenant_id = ?", q.Tenant)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计用户失败: %w", err)
+	}
+
+	query = query.Order("created_at DESC").Order("id DESC")
+	if q.PageSize > 0 {
+		page := q.Page
+		if page < 1 {
+			page = 1
+		}
+		query = query.Offset((page - 1) * q.PageSize).Limit(q.PageSize)
+	}
+
+	var users []User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+	return users, total, nil
+}
+
+// likeEscaper 转义LIKE中的通配符，转义字符使用!以兼容各数据库对反斜杠的不同处理
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// containsPattern 构建不区分大小写的子串匹配LIKE模式，关键字中的%和_按字面匹配，
+// 需要配合ESCAPE '!'使用
+func containsPattern(keyword string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(keyword)) + "%"
+}
+
+// ErrUserHasAPIKeys 用户仍有API Key，未指定级联删除时不能删除
+var ErrUserHasAPIKeys = errors.New("用户仍有API Key")
+
+// DeleteUser 删除用户，cascade为true时同时删除用户的API Key，
+// 否则用户仍有API Key时返回ErrUserHasAPIKeys，避免留下不属于任何用户的API Key
+func (m *Manager) DeleteUser(id uint, cascade bool) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if cascade {
+			if err := tx.Where("user_id = ?", id).Delete(&APIKey{}).Error; err != nil {
+				return fmt.Errorf("删除用户的API Key失败: %w", err)
+			}
+		} else {
+			var count int64
+			if err := tx.Model(&APIKey{}).Where("user_id = ?", id).Count(&count).Error; err != nil {
+				return fmt.Errorf("统计用户的API Key失败: %w", err)
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: %d", ErrUserHasAPIKeys, count)
+			}
+		}
+
+		// 删除用户的会话，已签发的token随之失效
+		if err := tx.Where("user_id = ?", id).Delete(&Session{}).Error; err != nil {
+			return fmt.Errorf("删除用户的会话失败: %w", err)
+		}
+
+		result := tx.Where("id = ?", id).Delete(&User{})
+		if result.Error != nil {
+			return fmt.Errorf("删除用户失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("用户不存在: %d", id)
+		}
+		return nil
+	})
 }
 
 // UpdateUserStatus 更新用户状态
@@ -279,9 +537,12 @@ func (m *Manager) UpdateUserStatus(id uint, isEnabled bool) error {
 	return nil
 }
 
-// UpdateUserPassword 更新用户密码
-func (m *Manager) UpdateUserPassword(id uint, hashedPassword string) error {
-	result := m.db.Model(&User{}).Where("id = ?", id).Update("password", hashedPassword)
+// UpdateUserPassword 更新用户密码，mustChange表示新密码是否为需要用户修改的临时密码
+func (m *Manager) UpdateUserPassword(id uint, hashedPassword string, mustChange bool) error {
+	result := m.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":             hashedPassword,
+		"must_change_password": mustChange,
+	})
 	if result.Error != nil {
 		return fmt.Errorf("更新用户密码失败: %w", result.Error)
 	}
@@ -301,6 +562,55 @@ func (m *Manager) UpdateUserLastLogin(id uint) error {
 	return nil
 }
 
+// RecordFailedLogin 累加用户连续登录失败的次数，达到maxAttempts时锁定账户到now+lockout并清零次数，
+// 返回锁定到期时间，未锁定时返回nil；maxAttempts不大于0时只累加次数
+func (m *Manager) RecordFailedLogin(id uint, maxAttempts int, lockout time.Duration) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", id).Update("failed_login_count", gorm.Expr("failed_login_count + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("更新登录失败次数失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("用户不存在: %d", id)
+		}
+
+		var user User
+		if err := tx.Select("failed_login_count").First(&user, id).Error; err != nil {
+			return fmt.Errorf("读取登录失败次数失败: %w", err)
+		}
+		if maxAttempts <= 0 || user.FailedLoginCount < maxAttempts {
+			return nil
+		}
+
+		until := time.Now().Add(lockout)
+		if err := tx.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"failed_login_count": 0,
+			"locked_until":       &until,
+		}).Error; err != nil {
+			return fmt.Errorf("锁定用户失败: %w", err)
+		}
+		lockedUntil = &until
+		return nil
+	})
+	return lockedUntil, err
+}
+
+// UnlockUser 解除用户的账户锁定并清零登录失败次数
+func (m *Manager) UnlockUser(id uint) error {
+	result := m.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("解除用户锁定失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("用户不存在: %d", id)
+	}
+	return nil
+}
+
 // Close 关闭数据库连接
 func (m *Manager) Close() error {
 	sqlDB, err := m.db.DB()
@@ -334,16 +644,44 @@ func (m *Manager) GetAPIKeysByUserID(userID uint) ([]APIKey, error) {
 	return apiKeys, nil
 }
 
-// GetAPIKeyByValue 根据Key值获取API Key
+// GetExpiringAPIKeys 获取用户在now之后、before之前（含）过期的API Key，按过期时间升序排列
+func (m *Manager) GetExpiringAPIKeys(userID uint, now, before time.Time) ([]APIKey, error) {
+	var apiKeys []APIKey
+	result := m.db.Where("user_id = ? AND expires_at IS NOT NULL AND expires_at > ? AND expires_at <= ?", userID, now, before).
+		Order("expires_at").Find(&apiKeys)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取即将过期的API Key失败: %w", result.Error)
+	}
+	return apiKeys, nil
+}
+
+// GetAllAPIKeys 获取所有用户的API Key（包含所属用户），tenantID不为空时只返回该租户的API Key
+func (m *Manager) GetAllAPIKeys(tenantID string) ([]APIKey, error) {
+	var apiKeys []APIKey
+	query := m.db.Preload("User")
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	result := query.Order("user_id, created_at DESC").Find(&apiKeys)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取API Key列表失败: %w", result.Error)
+	}
+	return apiKeys, nil
+}
+
+// GetAPIKeyByValue 根据Key值的哈希获取启用的API Key，所属用户已删除的Key视为不存在
 func (m *Manager) GetAPIKeyByValue(keyValue string) (*APIKey, error) {
 	var apiKey APIKey
-	result := m.db.Where("key_value = ? AND is_enabled = ?", keyValue, true).First(&apiKey)
+	result := m.db.Where("key_hash = ? AND is_enabled = ?", HashAPIKey(keyValue), true).
+		Where("user_id IN (?)", m.db.Model(&User{}).Select("id")).
+		First(&apiKey)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("API Key不存在或已禁用")
 		}
 		return nil, fmt.Errorf("获取API Key失败: %w", result.Error)
 	}
+	apiKey.KeyValue = keyValue
 	return &apiKey, nil
 }
 
@@ -362,13 +700,28 @@ func (m *Manager) GetAPIKeyByID(id uint) (*APIKey, error) {
 
 // UpdateAPIKey 更新API Key
 func (m *Manager) UpdateAPIKey(apiKey *APIKey) error {
-	result := m.db.Save(apiKey)
+	result := m.db.Omit("User").Save(apiKey)
 	if result.Error != nil {
 		return fmt.Errorf("更新API Key失败: %w", result.Error)
 	}
 	return nil
 }
 
+// TransferAPIKey 将API Key转移给另一个用户，只修改所属用户和租户，Key值和使用记录保持不变
+func (m *Manager) TransferAPIKey(id, userID uint, tenantID string) error {
+	result := m.db.Model(&APIKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"user_id":   userID,
+		"tenant_id": tenantID,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("转移API Key失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("API Key不存在: %d", id)
+	}
+	return nil
+}
+
 // DeleteAPIKey 删除API Key
 func (m *Manager) DeleteAPIKey(id uint, userID uint) error {
 	result := m.db.Where("id = ? AND user_id = ?", id, userID).Delete(&APIKey{})
@@ -381,10 +734,85 @@ func (m *Manager) DeleteAPIKey(id uint, userID uint) error {
 	return nil
 }
 
-// UpdateAPIKeyLastUsed 更新API Key最后使用时间
-func (m *Manager) UpdateAPIKeyLastUsed(keyValue string) error {
+// GetAPIKeysByAllowedModel 获取允许列表中包含指定模型的API Key
+func (m *Manager) GetAPIKeysByAllowedModel(modelID string) ([]APIKey, error) {
+	pattern, err := json.Marshal(modelID)
+	if err != nil {
+		return nil, fmt.Errorf("序列化模型ID失败: %w", err)
+	}
+
+	var candidates []APIKey
+	result := m.db.Where("allowed_models LIKE ?", "%"+string(pattern)+"%").Find(&candidates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取API Key列表失败: %w", result.Error)
+	}
+
+	// LIKE只做初筛，按解析后的列表精确匹配
+	var apiKeys []APIKey
+	for _, apiKey := range candidates {
+		for _, allowed := range apiKey.AllowedModels {
+			if allowed == modelID {
+				apiKeys = append(apiKeys, apiKey)
+				break
+			}
+		}
+	}
+	return apiKeys, nil
+}
+
+// ModelUsageDelta 一段时间内累计的模型请求数和最后调用时间
+type ModelUsageDelta struct {
+	Count      int64
+	LastUsedAt time.Time
+}
+
+// AddModelUsage 在一个事务中累加多个模型的调用统计，模型没有统计记录时创建
+func (m *Manager) AddModelUsage(deltas map[string]ModelUsageDelta) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for modelID, delta := range deltas {
+			lastUsedAt := delta.LastUsedAt
+			result := tx.Model(&ModelUsage{}).Where("model_id = ?", modelID).Updates(map[string]interface{}{
+				"total_request_count": gorm.Expr("total_request_count + ?", delta.Count),
+				"last_used_at":        &lastUsedAt,
+			})
+			if result.Error != nil {
+				return fmt.Errorf("更新模型 %s 的调用统计失败: %w", modelID, result.Error)
+			}
+			if result.RowsAffected > 0 {
+				continue
+			}
+			usage := &ModelUsage{ModelID: modelID, LastUsedAt: &lastUsedAt, TotalRequestCount: delta.Count}
+			if err := tx.Create(usage).Error; err != nil {
+				return fmt.Errorf("创建模型 %s 的调用统计失败: %w", modelID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetModelUsage 获取多个模型的调用统计，没有调用记录的模型不在结果中
+func (m *Manager) GetModelUsage(modelIDs []string) (map[string]ModelUsage, error) {
+	usage := make(map[string]ModelUsage, len(modelIDs))
+	if len(modelIDs) == 0 {
+		return usage, nil
+	}
+	var rows []ModelUsage
+	if err := m.db.Where("model_id IN ?", modelIDs).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取模型调用统计失败: %w", err)
+	}
+	for _, row := range rows {
+		usage[row.ModelID] = row
+	}
+	return usage, nil
+}
+
+// UpdateAPIKeyLastUsed 更新API Key最后使用时间和客户端IP
+func (m *Manager) UpdateAPIKeyLastUsed(keyValue, clientIP string) error {
 	now := time.Now()
-	result := m.db.Model(&APIKey{}).Where("key_value = ?", keyValue).Update("last_used_at", &now)
+	result := m.db.Model(&APIKey{}).Where("key_hash = ?", HashAPIKey(keyValue)).Updates(map[string]interface{}{
+		"last_used_at": &now,
+		"last_used_ip": clientIP,
+	})
 	if result.Error != nil {
 		return fmt.Errorf("更新API Key最后使用时间失败: %w", result.Error)
 	}
@@ -0,0 +1,757 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// newTestManager 创建使用临时目录的数据库管理器，并写入50个模型
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库管理器失败: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+
+	for i := 0; i < 50; i++ {
+		modelType := config.ModelTypeChat
+		if i%5 == 0 {
+			modelType = config.ModelTypeImage
+		}
+		cfg := &config.ModelConfig{
+			ID:     fmt.Sprintf("model-%02d", i),
+			Name:   fmt.Sprintf("Model %02d", i),
+			Target: "gpt-4o",
+			Url:    "https://api.openai.com/v1/chat/completions",
+			Type:   modelType,
+		}
+		if i == 42 {
+			cfg.Name = "Special Assistant"
+		}
+		if err := manager.SaveModelConfig(cfg); err != nil {
+			t.Fatalf("保存模型配置失败: %v", err)
+		}
+	}
+
+	return manager
+}
+
+// newTestUser 创建测试用户
+func newTestUser(t *testing.T, manager *Manager, username string) *User {
+	t.Helper()
+
+	user := &User{Username: username, Password: "hash", IsEnabled: true}
+	if err := manager.CreateUser(user); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	return user
+}
+
+func TestQueryModelConfigsPagination(t *testing.T) {
+	manager := newTestManager(t)
+
+	models, total, err := manager.QueryModelConfigs(ModelQuery{Page: 3, PageSize: 20, SortBy: "name"})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if total != 50 {
+		t.Errorf("期望总数50，实际得到%d", total)
+	}
+	if len(models) != 10 {
+		t.Fatalf("期望最后一页10个模型，实际得到%d个", len(models))
+	}
+	if models[0].Name != "Model 40" || models[9].Name != "Special Assistant" {
+		t.Errorf("第三页边界不正确，首个为%s，末个为%s", models[0].Name, models[9].Name)
+	}
+
+	models, _, err = manager.QueryModelConfigs(ModelQuery{Page: 4, PageSize: 20})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if len(models) != 0 {
+		t.Errorf("超出范围的页码应返回空列表，实际得到%d个", len(models))
+	}
+
+	models, _, err = manager.QueryModelConfigs(ModelQuery{})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if len(models) != 50 {
+		t.Errorf("不分页时期望返回全部50个模型，实际得到%d个", len(models))
+	}
+}
+
+func TestQueryModelConfigsFilterAndSort(t *testing.T) {
+	manager := newTestManager(t)
+
+	models, total, err := manager.QueryModelConfigs(ModelQuery{Type: string(config.ModelTypeImage)})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if total != 10 || len(models) != 10 {
+		t.Errorf("期望10个image模型，实际总数%d、返回%d个", total, len(models))
+	}
+
+	models, total, err = manager.QueryModelConfigs(ModelQuery{Search: "SPECIAL"})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if total != 1 || len(models) != 1 || models[0].ID != "model-42" {
+		t.Errorf("搜索应不区分大小写并只匹配model-42，实际得到%v", models)
+	}
+
+	if _, total, _ = manager.QueryModelConfigs(ModelQuery{Search: "model_4"}); total != 0 {
+		t.Errorf("搜索中的_应按字面匹配，实际匹配%d个", total)
+	}
+
+	models, _, err = manager.QueryModelConfigs(ModelQuery{SortBy: "name", Desc: true, Page: 1, PageSize: 1})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "Special Assistant" {
+		t.Errorf("按名称倒序第一个应为Special Assistant，实际得到%v", models)
+	}
+}
+
+func TestModelUsage(t *testing.T) {
+	manager := newTestManager(t)
+
+	earlier := time.Now().Add(-48 * time.Hour)
+	recent := time.Now()
+	if err := manager.AddModelUsage(map[string]ModelUsageDelta{
+		"model-01": {Count: 3, LastUsedAt: earlier},
+		"model-02": {Count: 1, LastUsedAt: earlier},
+	}); err != nil {
+		t.Fatalf("写入调用统计失败: %v", err)
+	}
+	if err := manager.AddModelUsage(map[string]ModelUsageDelta{"model-01": {Count: 2, LastUsedAt: recent}}); err != nil {
+		t.Fatalf("累加调用统计失败: %v", err)
+	}
+
+	usage, err := manager.GetModelUsage([]string{"model-01", "model-02", "model-03"})
+	if err != nil {
+		t.Fatalf("获取调用统计失败: %v", err)
+	}
+	if usage["model-01"].TotalRequestCount != 5 || !usage["model-01"].LastUsedAt.Equal(recent) {
+		t.Errorf("model-01的统计应累加并更新最后调用时间，实际得到%+v", usage["model-01"])
+	}
+	if _, ok := usage["model-03"]; ok {
+		t.Error("没有调用记录的模型不应出现在结果中")
+	}
+
+	_, total, err := manager.QueryModelConfigs(ModelQuery{UnusedSince: time.Now().Add(-24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("查询模型失败: %v", err)
+	}
+	if total != 49 {
+		t.Errorf("24小时内未调用的模型应为49个，实际得到%d个", total)
+	}
+}
+
+func TestAPIKeyAllowedModels(t *testing.T) {
+	manager := newTestManager(t)
+	owner := newTestUser(t, manager, "owner")
+
+	scoped := &APIKey{UserID: owner.ID, Name: "scoped", KeyValue: "key-scoped", IsEnabled: true, AllowedModels: StringList{"model-01"}}
+	unscoped := &APIKey{UserID: owner.ID, Name: "unscoped", KeyValue: "key-unscoped", IsEnabled: true}
+	for _, apiKey := range []*APIKey{scoped, unscoped} {
+		if err := manager.CreateAPIKey(apiKey); err != nil {
+			t.Fatalf("创建API Key失败: %v", err)
+		}
+	}
+
+	loaded, err := manager.GetAPIKeyByValue("key-scoped")
+	if err != nil {
+		t.Fatalf("获取API Key失败: %v", err)
+	}
+	if !loaded.AllowsModel("model-01") || loaded.AllowsModel("model-02") {
+		t.Errorf("允许列表未正确保存: %v", loaded.AllowedModels)
+	}
+
+	keys, err := manager.GetAPIKeysByAllowedModel("model-01")
+	if err != nil {
+		t.Fatalf("查询引用模型的API Key失败: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Name != "scoped" {
+		t.Errorf("期望只返回scoped，实际得到%v", keys)
+	}
+	if keys, _ := manager.GetAPIKeysByAllowedModel("model-0"); len(keys) != 0 {
+		t.Errorf("模型ID应精确匹配，实际得到%d个", len(keys))
+	}
+}
+
+func TestUpdateAPIKeyLastUsed(t *testing.T) {
+	manager := newTestManager(t)
+	owner := newTestUser(t, manager, "owner")
+
+	if err := manager.CreateAPIKey(&APIKey{UserID: owner.ID, Name: "used", KeyValue: "key-used", IsEnabled: true}); err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	if err := manager.UpdateAPIKeyLastUsed("key-used", "203.0.113.7"); err != nil {
+		t.Fatalf("更新最后使用信息失败: %v", err)
+	}
+
+	loaded, err := manager.GetAPIKeyByValue("key-used")
+	if err != nil {
+		t.Fatalf("获取API Key失败: %v", err)
+	}
+	if loaded.LastUsedAt == nil || loaded.LastUsedIP != "203.0.113.7" {
+		t.Errorf("最后使用时间和IP未保存: %v, %q", loaded.LastUsedAt, loaded.LastUsedIP)
+	}
+}
+
+func TestDeleteUserWithAPIKeys(t *testing.T) {
+	manager := newTestManager(t)
+	owner := newTestUser(t, manager, "owner")
+	if err := manager.CreateAPIKey(&APIKey{UserID: owner.ID, Name: "owned", KeyValue: "key-owned", IsEnabled: true}); err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+
+	// 用户仍有API Key时不指定级联删除会被拒绝，用户和Key都保留
+	if err := manager.DeleteUser(owner.ID, false); !errors.Is(err, ErrUserHasAPIKeys) {
+		t.Fatalf("期望返回ErrUserHasAPIKeys，实际得到%v", err)
+	}
+	if _, err := manager.GetUserByID(owner.ID); err != nil {
+		t.Errorf("拒绝删除后用户应保留: %v", err)
+	}
+	if _, err := manager.GetAPIKeyByValue("key-owned"); err != nil {
+		t.Errorf("拒绝删除后API Key应仍然有效: %v", err)
+	}
+
+	if err := manager.DeleteUser(owner.ID, true); err != nil {
+		t.Fatalf("级联删除用户失败: %v", err)
+	}
+	if keys, err := manager.GetAPIKeysByUserID(owner.ID); err != nil || len(keys) != 0 {
+		t.Errorf("级联删除后不应留下API Key，实际得到%v, %v", keys, err)
+	}
+
+	// 所属用户不存在的Key（如旧版本删除用户后遗留的记录）不能再用于认证
+	if err := manager.CreateAPIKey(&APIKey{UserID: owner.ID, Name: "orphan", KeyValue: "key-orphan", IsEnabled: true}); err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	if _, err := manager.GetAPIKeyByValue("key-orphan"); err == nil {
+		t.Error("所属用户已删除的API Key应视为无效")
+	}
+
+	// 没有API Key的用户可以直接删除
+	empty := newTestUser(t, manager, "empty")
+	if err := manager.DeleteUser(empty.ID, false); err != nil {
+		t.Errorf("删除没有API Key的用户失败: %v", err)
+	}
+}
+
+func TestGetExpiringAPIKeys(t *testing.T) {
+	manager := newTestManager(t)
+
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		expiresAt := now.Add(d)
+		return &expiresAt
+	}
+	for _, apiKey := range []*APIKey{
+		{UserID: 1, Name: "in-3-days", KeyValue: "key-3d", ExpiresAt: at(72 * time.Hour)},
+		{UserID: 1, Name: "in-1-day", KeyValue: "key-1d", ExpiresAt: at(24 * time.Hour)},
+		{UserID: 1, Name: "in-30-days", KeyValue: "key-30d", ExpiresAt: at(30 * 24 * time.Hour)},
+		{UserID: 1, Name: "expired", KeyValue: "key-expired", ExpiresAt: at(-time.Hour)},
+		{UserID: 1, Name: "never", KeyValue: "key-never"},
+		{UserID: 2, Name: "other-user", KeyValue: "key-other", ExpiresAt: at(time.Hour)},
+	} {
+		if err := manager.CreateAPIKey(apiKey); err != nil {
+			t.Fatalf("创建API Key失败: %v", err)
+		}
+	}
+
+	apiKeys, err := manager.GetExpiringAPIKeys(1, now, now.Add(7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("查询即将过期的API Key失败: %v", err)
+	}
+	var names []string
+	for _, apiKey := range apiKeys {
+		names = append(names, apiKey.Name)
+	}
+	if strings.Join(names, ",") != "in-1-day,in-3-days" {
+		t.Errorf("期望按过期时间返回in-1-day,in-3-days，实际得到%v", names)
+	}
+}
+
+func TestSigningSecretEncryptedAtRest(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("创建数据库管理器失败: %v", err)
+	}
+
+	cfg := &config.ModelConfig{
+		ID:            "signed",
+		Name:          "Signed",
+		Target:        "gpt-4o",
+		Url:           "https://inference.internal/v1/chat/completions",
+		Type:          config.ModelTypeChat,
+		SigningSecret: "super-secret-value",
+	}
+	if err := manager.SaveModelConfig(cfg); err != nil {
+		t.Fatalf("保存模型配置失败: %v", err)
+	}
+
+	raw, err := manager.GetModelConfigWithTime("signed")
+	if err != nil {
+		t.Fatalf("获取模型配置失败: %v", err)
+	}
+	if !strings.HasPrefix(raw.SigningSecret, sealedSecretPrefix) || strings.Contains(raw.SigningSecret, cfg.SigningSecret) {
+		t.Fatalf("签名密钥应加密保存，实际得到%q", raw.SigningSecret)
+	}
+
+	cfg.SigningSecret = "rotated-secret"
+	if err := manager.UpdateModelConfig(cfg); err != nil {
+		t.Fatalf("更新模型配置失败: %v", err)
+	}
+	manager.Close()
+
+	// 重新打开数据库后使用保存的数据加密密钥解密
+	manager, err = NewManager(dir)
+	if err != nil {
+		t.Fatalf("重新打开数据库失败: %v", err)
+	}
+	defer manager.Close()
+	got, err := manager.GetModelConfig("signed")
+	if err != nil {
+		t.Fatalf("获取模型配置失败: %v", err)
+	}
+	if got.SigningSecret != "rotated-secret" {
+		t.Errorf("期望解密得到rotated-secret，实际得到%q", got.SigningSecret)
+	}
+}
+
+func TestEnvTemplatesPersisted(t *testing.T) {
+	t.Setenv("TEST_UPSTREAM_HOST", "upstream.internal")
+	t.Setenv("TEST_API_KEY", "sk-secret")
+	manager := newTestManager(t)
+
+	cfg := &config.ModelConfig{
+		ID:             "env",
+		Name:           "Env",
+		Target:         "gpt-4o",
+		Url:            "https://${TEST_UPSTREAM_HOST}/v1/chat/completions",
+		RequestHeaders: map[string]string{"Authorization": "Bearer ${TEST_API_KEY}"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("验证失败: %v", err)
+	}
+	if err := manager.SaveModelConfig(cfg); err != nil {
+		t.Fatalf("保存模型配置失败: %v", err)
+	}
+
+	raw, err := manager.GetModelConfigWithTime("env")
+	if err != nil {
+		t.Fatalf("获取模型配置失败: %v", err)
+	}
+	if raw.Url != "https://${TEST_UPSTREAM_HOST}/v1/chat/completions" || raw.RequestHeaders["Authorization"] != "Bearer ${TEST_API_KEY}" {
+		t.Errorf("数据库中应保存模板而不是展开后的值，实际得到%s、%s", raw.Url, raw.RequestHeaders["Authorization"])
+	}
+
+	got, err := manager.GetModelConfig("env")
+	if err != nil {
+		t.Fatalf("获取模型配置失败: %v", err)
+	}
+	if got.Url != cfg.Url || got.RequestHeaders["Authorization"] != "Bearer sk-secret" {
+		t.Errorf("读取时应展开模板，实际得到%s、%s", got.Url, got.RequestHeaders["Authorization"])
+	}
+}
+
+func TestMigrateUserRoles(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("创建数据库管理器失败: %v", err)
+	}
+	admin := newTestUser(t, manager, "admin")
+	member := newTestUser(t, manager, "member")
+	// 模拟升级前的数据：只有is_admin，没有角色
+	manager.db.Model(&User{}).Where("id = ?", admin.ID).Updates(map[string]interface{}{"role": "", "is_admin": true})
+	manager.db.Model(&User{}).Where("id = ?", member.ID).Update("role", "")
+	manager.Close()
+
+	manager, err = NewManager(dir)
+	if err != nil {
+		t.Fatalf("重新打开数据库失败: %v", err)
+	}
+	defer manager.Close()
+	for id, want := range map[uint]Role{admin.ID: RoleSuperuser, member.ID: RoleViewer} {
+		var raw struct{ Role string }
+		manager.db.Model(&User{}).Select("role").Where("id = ?", id).Scan(&raw)
+		if Role(raw.Role) != want {
+			t.Errorf("用户%d迁移后的角色 = %q, want %q", id, raw.Role, want)
+		}
+	}
+
+	user, err := manager.GetUserByID(admin.ID)
+	if err != nil || !user.IsAdmin || user.Role != RoleSuperuser {
+		t.Fatalf("superuser的IsAdmin应为true，实际%+v", user)
+	}
+	user.SetRole(RoleViewer)
+	if err := manager.UpdateUser(user); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	if user, _ = manager.GetUserByID(admin.ID); user.IsAdmin || user.Role != RoleViewer {
+		t.Errorf("改为viewer后IsAdmin应为false，实际%+v", user)
+	}
+}
+
+func TestMigrateTenants(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("创建数据库管理器失败: %v", err)
+	}
+	admin := &User{Username: "admin", Password: "hash", Role: RoleSuperuser, IsEnabled: true}
+	if err := manager.CreateUser(admin); err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	member := newTestUser(t, manager, "member")
+	apiKey := &APIKey{UserID: member.ID, Name: "legacy", KeyValue: "sk-legacy", IsEnabled: true}
+	if err := manager.CreateAPIKey(apiKey); err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	if err := manager.SaveModelConfig(&config.ModelConfig{ID: "legacy", Name: "Legacy", Target: "gpt-4o"}); err != nil {
+		t.Fatalf("保存模型配置失败: %v", err)
+	}
+	// 模拟升级前的数据：没有租户
+	manager.db.Model(&User{}).Where("1 = 1").Updates(map[string]interface{}{"tenant_id": "", "global": false})
+	manager.db.Model(&APIKey{}).Where("1 = 1").Update("tenant_id", "")
+	manager.db.Model(&ModelConfigDB{}).Where("1 = 1").Update("tenant_id", "")
+	manager.Close()
+
+	manager, err = NewManager(dir)
+	if err != nil {
+		t.Fatalf("重新打开数据库失败: %v", err)
+	}
+	defer manager.Close()
+
+	if tenants, err := manager.GetTenants(); err != nil || len(tenants) != 1 || tenants[0].ID != config.DefaultTenant {
+		t.Fatalf("期望创建默认租户，实际%+v, %v", tenants, err)
+	}
+	if user, _ := manager.GetUserByID(admin.ID); user.TenantID != config.DefaultTenant || !user.Global {
+		t.Errorf("升级前的superuser应为默认租户的全局管理员，实际%+v", user)
+	}
+	if user, _ := manager.GetUserByID(member.ID); user.TenantID != config.DefaultTenant || user.Global {
+		t.Errorf("升级前的用户应属于默认租户，实际%+v", user)
+	}
+	if keys, _ := manager.GetAllAPIKeys(config.DefaultTenant); len(keys) != 1 || keys[0].TenantID != config.DefaultTenant {
+		t.Errorf("升级前的API Key应属于默认租户，实际%+v", keys)
+	}
+	if _, total, _ := manager.QueryModelConfigs(ModelQuery{Tenant: config.DefaultTenant}); total != 1 {
+		t.Errorf("升级前的模型应属于默认租户，实际%d个", total)
+	}
+
+	// 按租户过滤
+	if err := manager.CreateTenant(&Tenant{ID: "team-a"}); err != nil {
+		t.Fatalf("创建租户失败: %v", err)
+	}
+	if err := manager.SaveModelConfig(&config.ModelConfig{ID: "team-chat", Name: "Team", Target: "gpt-4o", Tenant: "team-a"}); err != nil {
+		t.Fatalf("保存模型配置失败: %v", err)
+	}
+	if models, total, _ := manager.QueryModelConfigs(ModelQuery{Tenant: "team-a"}); total != 1 || models[0].ID != "team-chat" {
+		t.Errorf("期望只返回team-a的模型，实际%+v", models)
+	}
+	if _, total, _ := manager.QueryModelConfigs(ModelQuery{}); total != 2 {
+		t.Errorf("不按租户过滤时期望返回全部模型，实际%d个", total)
+	}
+	if keys, _ := manager.GetAllAPIKeys("team-a"); len(keys) != 0 {
+		t.Errorf("期望team-a没有API Key，实际%d个", len(keys))
+	}
+	if err := manager.UpdateUserTenant(member.ID, "team-a", false); err != nil {
+		t.Fatalf("修改用户租户失败: %v", err)
+	}
+	if keys, _ := manager.GetAllAPIKeys("team-a"); len(keys) != 1 {
+		t.Errorf("用户的API Key应随用户转到新租户，实际%d个", len(keys))
+	}
+	if users, total, _ := manager.SearchUsers("", UserQuery{Tenant: "team-a", IncludeAdmins: true}); total != 1 || users[0].ID != member.ID {
+		t.Errorf("期望只返回team-a的用户，实际%+v", users)
+	}
+}
+
+func TestQueryUsers(t *testing.T) {
+	manager := newTestManager(t)
+	admin := &User{Username: "admin", Password: "hash", Role: RoleSuperuser, IsEnabled: true}
+	if err := manager.CreateUser(admin); err != nil {
+		t.Fatalf("创建管理员失败: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		newTestUser(t, manager, fmt.Sprintf("user-%d", i))
+	}
+
+	users, total, err := manager.QueryUsers(UserQuery{Page: 2, PageSize: 2, IncludeAdmins: true})
+	if err != nil {
+		t.Fatalf("查询用户失败: %v", err)
+	}
+	if total != 5 || len(users) != 2 {
+		t.Fatalf("期望总数5、本页2个，实际总数%d、本页%d个", total, len(users))
+	}
+	if users[0].Username != "user-1" || users[1].Username != "user-0" {
+		t.Errorf("应按创建时间倒序分页，实际%s、%s", users[0].Username, users[1].Username)
+	}
+
+	users, total, err = manager.QueryUsers(UserQuery{IncludeAdmins: false})
+	if err != nil {
+		t.Fatalf("查询用户失败: %v", err)
+	}
+	if total != 4 || len(users) != 4 {
+		t.Fatalf("不包含管理员时期望4个用户，实际总数%d、%d个", total, len(users))
+	}
+	for _, user := range users {
+		if user.IsAdmin {
+			t.Errorf("不应返回管理员%s", user.Username)
+		}
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	manager := newTestManager(t)
+	for _, username := range []string{"Alice", "alicia", "bob", "Malice", "a_b", "a%b!"} {
+		newTestUser(t, manager, username)
+	}
+
+	users, total, err := manager.SearchUsers("ALI", UserQuery{Page: 1, PageSize: 2, IncludeAdmins: true})
+	if err != nil {
+		t.Fatalf("搜索用户失败: %v", err)
+	}
+	if total != 3 || len(users) != 2 {
+		t.Fatalf("期望匹配3个、本页2个，实际总数%d、本页%d个", total, len(users))
+	}
+	if users[0].Username != "Malice" {
+		t.Errorf("应按创建时间倒序返回，实际第一个为%s", users[0].Username)
+	}
+
+	if _, total, _ = manager.SearchUsers("  ", UserQuery{IncludeAdmins: true}); total != 6 {
+		t.Errorf("关键字为空时应返回全部用户，实际%d个", total)
+	}
+	if users, total, _ = manager.SearchUsers("carol", UserQuery{IncludeAdmins: true}); total != 0 || len(users) != 0 {
+		t.Errorf("没有匹配时应返回空列表，实际总数%d", total)
+	}
+	// %、_和转义字符本身按字面匹配
+	for keyword, want := range map[string]string{"_": "a_b", "%": "a%b!", "B!": "a%b!"} {
+		users, total, _ = manager.SearchUsers(keyword, UserQuery{IncludeAdmins: true})
+		if total != 1 || len(users) != 1 || users[0].Username != want {
+			t.Errorf("搜索%q应只匹配%s，实际总数%d", keyword, want, total)
+		}
+	}
+}
+
+func TestAPIKeyLookupByHash(t *testing.T) {
+	manager := newTestManager(t)
+	user := newTestUser(t, manager, "hash-user")
+	apiKey := &APIKey{UserID: user.ID, Name: "k", KeyValue: "ak_lookup_by_hash_value", IsEnabled: true}
+	if err := manager.CreateAPIKey(apiKey); err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	if apiKey.KeyHash != HashAPIKey("ak_lookup_by_hash_value") || apiKey.Preview() != "ak_looku***" {
+		t.Fatalf("应保存哈希和前缀，实际hash=%s preview=%s", apiKey.KeyHash, apiKey.Preview())
+	}
+
+	got, err := manager.GetAPIKeyByValue("ak_lookup_by_hash_value")
+	if err != nil || got.ID != apiKey.ID || got.KeyValue != "ak_lookup_by_hash_value" {
+		t.Fatalf("按Key值查找失败: %+v, %v", got, err)
+	}
+	if _, err := manager.GetAPIKeyByValue(apiKey.KeyHash); err == nil {
+		t.Error("不应能用哈希本身作为Key值认证")
+	}
+	stored, err := manager.GetAPIKeyByID(apiKey.ID)
+	if err != nil || stored.KeyValue != "" {
+		t.Errorf("从数据库读取的API Key不应包含Key值，实际%q, %v", stored.KeyValue, err)
+	}
+	if err := manager.CreateAPIKey(&APIKey{UserID: user.ID, Name: "empty", IsEnabled: true}); err == nil {
+		t.Error("没有Key值时应创建失败")
+	}
+}
+
+func TestMigrateAPIKeyHashes(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("创建数据库管理器失败: %v", err)
+	}
+	user := newTestUser(t, manager, "legacy")
+	// 模拟升级前的数据：Key值以明文保存在key_value列中
+	const plaintext = "ak_legacy_plaintext_0123456789abcdef"
+	for _, statement := range []string{
+		"ALTER TABLE `api_keys` ADD COLUMN `key_value` varchar(191)",
+		"CREATE UNIQUE INDEX `idx_api_keys_key_value` ON `api_keys`(`key_value`)",
+	} {
+		if err := manager.db.Exec(statement).Error; err != nil {
+			t.Fatalf("添加明文列失败: %v", err)
+		}
+	}
+	if err := manager.db.Exec("INSERT INTO api_keys (user_id, name, key_value, is_enabled, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		user.ID, "legacy", plaintext, true, time.Now(), time.Now()).Error; err != nil {
+		t.Fatalf("写入明文API Key失败: %v", err)
+	}
+	manager.Close()
+
+	// 重复打开数据库，迁移只执行一次
+	for i := 0; i < 2; i++ {
+		manager, err = NewManager(dir)
+		if err != nil {
+			t.Fatalf("第%d次打开数据库失败: %v", i+1, err)
+		}
+		if manager.db.Migrator().HasColumn(&APIKey{}, "key_value") {
+			t.Error("迁移后应删除明文列")
+		}
+		apiKey, err := manager.GetAPIKeyByValue(plaintext)
+		if err != nil || apiKey.Preview() != "ak_legac***" {
+			t.Errorf("迁移后应能按Key值认证，实际%+v, %v", apiKey, err)
+		}
+		manager.Close()
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("读取数据库目录失败: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatalf("读取数据库文件失败: %v", err)
+		}
+		if bytes.Contains(data, []byte(plaintext)) {
+			t.Errorf("迁移后%s中不应包含明文Key", entry.Name())
+		}
+	}
+}
+
+func TestMasterKeyWrapsDataKey(t *testing.T) {
+	dir := t.TempDir()
+	manager, err := NewManager(dir)
+	if err != nil {
+		t.Fatalf("创建数据库管理器失败: %v", err)
+	}
+	cfg := &config.ModelConfig{
+		ID: "upstream", Name: "Upstream", Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions",
+		Type: config.ModelTypeChat, RequestHeaders: map[string]string{"Authorization": "Bearer sk-upstream-secret"},
+	}
+	if err := manager.SaveModelConfig(cfg); err != nil {
+		t.Fatalf("保存模型配置失败: %v", err)
+	}
+	var raw struct{ RequestHeaders string }
+	manager.db.Model(&ModelConfigDB{}).Select("request_headers").Where("id = ?", cfg.ID).Scan(&raw)
+	if strings.Contains(raw.RequestHeaders, "sk-upstream-secret") {
+		t.Errorf("请求头应加密保存，实际%s", raw.RequestHeaders)
+	}
+	manager.Close()
+
+	// 设置主密钥后，已有的数据加密密钥改为加密保存
+	dbConfig := config.DatabaseConfig{MasterKey: "operator-master-key"}
+	if manager, err = NewManagerWithConfig(dir, dbConfig); err != nil {
+		t.Fatalf("使用主密钥打开数据库失败: %v", err)
+	}
+	if value, _ := manager.GetMetadata(dataKeyMetadataKey); !strings.HasPrefix(value, wrappedKeyPrefix) {
+		t.Errorf("数据加密密钥应用主密钥加密保存，实际%q", value)
+	}
+	manager.Close()
+
+	if _, err := NewManager(dir); err == nil {
+		t.Error("没有主密钥时应无法打开数据库")
+	}
+	if _, err := NewManagerWithConfig(dir, config.DatabaseConfig{MasterKey: "wrong"}); err == nil {
+		t.Error("主密钥不正确时应无法打开数据库")
+	}
+	manager, err = NewManagerWithConfig(dir, dbConfig)
+	if err != nil {
+		t.Fatalf("重新打开数据库失败: %v", err)
+	}
+	defer manager.Close()
+	got, err := manager.GetModelConfig(cfg.ID)
+	if err != nil || got.RequestHeaders["Authorization"] != "Bearer sk-upstream-secret" {
+		t.Errorf("应解密得到原请求头，实际%v, %v", got, err)
+	}
+	withTime, err := manager.GetModelConfigWithTime(cfg.ID)
+	if err != nil || withTime.RequestHeaders["Authorization"] != "Bearer sk-upstream-secret" {
+		t.Errorf("包含时间信息的模型配置也应解密请求头，实际%v, %v", withTime, err)
+	}
+}
+
+func TestModelTrash(t *testing.T) {
+	manager := newTestManager(t)
+
+	if err := manager.DeleteModelConfig("model-01"); err != nil {
+		t.Fatalf("删除模型失败: %v", err)
+	}
+	if _, err := manager.GetModelConfig("model-01"); err == nil {
+		t.Error("回收站中的模型不应出现在普通查询中")
+	}
+	if models, _ := manager.GetAllModelConfigs(); len(models) != 49 {
+		t.Errorf("删除后应剩余49个模型，实际%d个", len(models))
+	}
+	trashed, err := manager.GetTrashedModelConfigs("")
+	if err != nil || len(trashed) != 1 || trashed[0].TrashedID != "model-01" || !trashed[0].DeletedAt.Valid {
+		t.Fatalf("回收站内容 = %+v, err=%v", trashed, err)
+	}
+
+	// 删除后可以用相同的ID创建新模型，此时不能恢复
+	replacement := &config.ModelConfig{ID: "model-01", Name: "New", Target: "gpt-4o-mini", Url: "https://api.openai.com/v1/chat/completions", Type: config.ModelTypeChat}
+	if err := manager.SaveModelConfig(replacement); err != nil {
+		t.Fatalf("创建同名模型失败: %v", err)
+	}
+	if _, err := manager.RestoreModelConfig("model-01", ""); !errors.Is(err, ErrModelIDInUse) {
+		t.Errorf("ID已被使用时应返回ErrModelIDInUse，实际%v", err)
+	}
+
+	// 删除新模型后恢复最近删除的一个
+	if err := manager.DeleteModelConfig("model-01"); err != nil {
+		t.Fatalf("删除模型失败: %v", err)
+	}
+	restored, err := manager.RestoreModelConfig("model-01", "")
+	if err != nil || restored.Name != "New" {
+		t.Fatalf("应恢复最近删除的模型，实际%+v, err=%v", restored, err)
+	}
+	if cfg, err := manager.GetModelConfig("model-01"); err != nil || cfg.Target != "gpt-4o-mini" {
+		t.Errorf("恢复后应能正常查询，实际%+v, err=%v", cfg, err)
+	}
+
+	// 永久删除和按保留时长清理
+	if err := manager.PurgeModelConfig("model-01", ""); err != nil {
+		t.Fatalf("永久删除失败: %v", err)
+	}
+	if _, err := manager.RestoreModelConfig("model-01", ""); !errors.Is(err, ErrModelNotInTrash) {
+		t.Errorf("永久删除后回收站中不应再有该模型，实际%v", err)
+	}
+	if err := manager.PurgeModelConfig("model-02", ""); !errors.Is(err, ErrModelNotInTrash) {
+		t.Errorf("未删除的模型不能永久删除，实际%v", err)
+	}
+	manager.DeleteModelConfig("model-02")
+	if n, err := manager.PurgeTrashedModelConfigs(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("不应清理保留期内的模型: n=%d err=%v", n, err)
+	}
+	if n, err := manager.PurgeTrashedModelConfigs(time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("应清理超过保留期的模型: n=%d err=%v", n, err)
+	}
+
+	// 不同租户先后删除的同ID模型，按租户恢复和永久删除
+	manager.DeleteModelConfig("model-03")
+	teamModel := &config.ModelConfig{ID: "model-03", Name: "Team", Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions", Type: config.ModelTypeChat, Tenant: "team-a"}
+	if err := manager.SaveModelConfig(teamModel); err != nil {
+		t.Fatalf("创建同名模型失败: %v", err)
+	}
+	manager.DeleteModelConfig("model-03")
+	if model, err := manager.GetTrashedModelConfig("model-03", config.DefaultTenant); err != nil || model.Tenant != config.DefaultTenant {
+		t.Errorf("应返回所属租户删除的模型，实际%+v, err=%v", model, err)
+	}
+	if err := manager.PurgeModelConfig("model-03", "team-a"); err != nil {
+		t.Fatalf("永久删除失败: %v", err)
+	}
+	if _, err := manager.GetTrashedModelConfig("model-03", "team-a"); !errors.Is(err, ErrModelNotInTrash) {
+		t.Errorf("永久删除后回收站中不应再有该租户的模型，实际%v", err)
+	}
+	if restored, err := manager.RestoreModelConfig("model-03", config.DefaultTenant); err != nil || restored.Tenant != config.DefaultTenant {
+		t.Errorf("其他租户的永久删除不应影响默认租户的模型，实际%+v, err=%v", restored, err)
+	}
+}
@@ -1,25 +1,65 @@
 package db
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"gorm.io/gorm"
 )
 
 // ModelConfigDB 数据库中的模型配置表
 type ModelConfigDB struct {
-	ID              string    `gorm:"primaryKey;column:id" json:"id"`
-	Name            string    `gorm:"column:name;not null" json:"name"`
-	Target          string    `gorm:"column:target;not null" json:"target"`
-	Prompt          string    `gorm:"column:prompt" json:"prompt"`
-	Url             string    `gorm:"column:url;not null" json:"url"`
-	Type            string    `gorm:"column:type;not null" json:"type"`
-	PromptPath      string    `gorm:"column:prompt_path" json:"prompt_path"`
-	PromptValue     string    `gorm:"column:prompt_value;type:text" json:"prompt_value"` // JSON字符串
-	PromptValueType string    `gorm:"column:prompt_value_type" json:"prompt_value_type"`
-	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt       time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	ID                  string          `gorm:"primaryKey;column:id;size:191" json:"id"`
+	Name                string          `gorm:"column:name;not null" json:"name"`
+	Target              string          `gorm:"column:target;not null" json:"target"`
+	Prompt              string          `gorm:"column:prompt" json:"prompt"`
+	Url                 string          `gorm:"column:url;not null" json:"url"`
+	Type                string          `gorm:"column:type;not null" json:"type"`
+	PromptPath          string          `gorm:"column:prompt_path" json:"prompt_path"`
+	PromptValue         string          `gorm:"column:prompt_value;type:text" json:"prompt_value"` // JSON字符串
+	PromptValueType     string          `gorm:"column:prompt_value_type" json:"prompt_value_type"`
+	ModelIDSource       string          `gorm:"column:model_id_source" json:"model_id_source"`
+	ModelIDKey          string          `gorm:"column:model_id_key" json:"model_id_key"`
+	Disabled            bool            `gorm:"column:disabled;default:false" json:"disabled"`
+	CacheTTL            int             `gorm:"column:cache_ttl" json:"cache_ttl"`
+	MaxConcurrency      int             `gorm:"column:max_concurrency" json:"max_concurrency"`
+	QueueOnLimit        bool            `gorm:"column:queue_on_limit" json:"queue_on_limit"`
+	QueueTimeout        int             `gorm:"column:queue_timeout" json:"queue_timeout"`
+	Maintenance         bool            `gorm:"column:maintenance" json:"maintenance"`
+	MaintenanceMessage  string          `gorm:"column:maintenance_message" json:"maintenance_message"`
+	MaintenanceStatus   int             `gorm:"column:maintenance_status" json:"maintenance_status"`
+	Aliases             StringList      `gorm:"column:aliases;type:text" json:"aliases"`  // 模型别名
+	SigningSecret       string          `gorm:"column:signing_secret;type:text" json:"-"` // 请求签名密钥，由Manager加密后保存
+	Tools               JSONList        `gorm:"column:tools;type:text" json:"tools"`      // 注入的工具定义
+	ToolsMode           string          `gorm:"column:tools_mode" json:"tools_mode"`
+	MaxTimeoutMs        int             `gorm:"column:max_timeout_ms" json:"max_timeout_ms"`
+	RequestHeaders      StringMap       `gorm:"column:request_headers;type:text" json:"request_headers"`   // 转发时添加的请求头
+	ResponseHeaders     StringMap       `gorm:"column:response_headers;type:text" json:"response_headers"` // 返回客户端时添加的响应头
+	QueueTimeoutMs      int             `gorm:"column:queue_timeout_ms" json:"queue_timeout_ms"`
+	Source              string          `gorm:"column:source;size:16" json:"source"`           // 模型来源：yaml或api，旧数据为空
+	Pipeline            StringList      `gorm:"column:pipeline;type:text" json:"pipeline"`     // 请求处理阶段顺序，为空使用默认顺序
+	StreamMode          string          `gorm:"column:stream_mode;size:16" json:"stream_mode"` // 上游响应的转发方式，为空表示auto
+	Targets             WeightedTargets `gorm:"column:targets;type:text" json:"targets"`       // 按权重分流的目标列表
+	MinifyBody          bool            `gorm:"column:minify_body" json:"minify_body"`
+	CompressUpstream    bool            `gorm:"column:compress_upstream" json:"compress_upstream"`
+	PromptVariants      PromptVariants  `gorm:"column:prompt_variants;type:text" json:"prompt_variants"` // Prompt实验的变体列表
+	HealthCheckInterval int             `gorm:"column:health_check_interval" json:"health_check_interval"`
+	HealthCheckMethod   string          `gorm:"column:health_check_method;size:16" json:"health_check_method"`
+	PromptPosition      string          `gorm:"column:prompt_position;size:16" json:"prompt_position"` // Prompt消息的插入位置，为空表示prepend
+	DedupePrompt        bool            `gorm:"column:dedupe_injected_prompt" json:"dedupe_injected_prompt"`
+	CanaryTarget        string          `gorm:"column:canary_target" json:"canary_target"`
+	CanaryUrl           string          `gorm:"column:canary_url" json:"canary_url"`
+	CanaryPercent       int             `gorm:"column:canary_percent" json:"canary_percent"`
+	HedgeAfterMs        int             `gorm:"column:hedge_after_ms" json:"hedge_after_ms"`
+	MaxHedges           int             `gorm:"column:max_hedges" json:"max_hedges"`
+	Tenant              string          `gorm:"column:tenant_id;size:64;index" json:"tenant"` // 所属租户
+	CreatedAt           time.Time       `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time       `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	DeletedAt           gorm.DeletedAt  `gorm:"column:deleted_at;index" json:"-"`          // 移入回收站的时间，普通查询不包含回收站中的模型
+	TrashedID           string          `gorm:"column:trashed_id;size:191;index" json:"-"` // 回收站中模型的原ID，主键改为回收站内唯一的键
 }
 
 // TableName 指定表名
@@ -38,15 +78,50 @@ func (m *ModelConfigDB) ToModelConfig() (*config.ModelConfig, error) {
 	}
 
 	return &config.ModelConfig{
-		ID:              m.ID,
-		Name:            m.Name,
-		Target:          m.Target,
-		Prompt:          m.Prompt,
-		Url:             m.Url,
-		Type:            config.ModelType(m.Type),
-		PromptPath:      m.PromptPath,
-		PromptValue:     promptValue,
-		PromptValueType: config.ValueType(m.PromptValueType),
+		ID:                  m.ID,
+		Name:                m.Name,
+		Target:              m.Target,
+		Prompt:              m.Prompt,
+		Url:                 m.Url,
+		Type:                config.ModelType(m.Type),
+		PromptPath:          m.PromptPath,
+		PromptValue:         promptValue,
+		PromptValueType:     config.ValueType(m.PromptValueType),
+		ModelIDSource:       config.ModelIDSource(m.ModelIDSource),
+		ModelIDKey:          m.ModelIDKey,
+		Disabled:            m.Disabled,
+		CacheTTL:            m.CacheTTL,
+		MaxConcurrency:      m.MaxConcurrency,
+		QueueOnLimit:        m.QueueOnLimit,
+		QueueTimeout:        m.QueueTimeout,
+		Maintenance:         m.Maintenance,
+		MaintenanceMessage:  m.MaintenanceMessage,
+		MaintenanceStatus:   m.MaintenanceStatus,
+		Aliases:             m.Aliases.orNil(),
+		SigningSecret:       m.SigningSecret,
+		Tools:               m.Tools.orNil(),
+		ToolsMode:           config.ToolsMode(m.ToolsMode),
+		MaxTimeoutMs:        m.MaxTimeoutMs,
+		RequestHeaders:      m.RequestHeaders.orNil(),
+		ResponseHeaders:     m.ResponseHeaders.orNil(),
+		QueueTimeoutMs:      m.QueueTimeoutMs,
+		Source:              config.ModelSource(m.Source),
+		Pipeline:            m.Pipeline.orNil(),
+		StreamMode:          config.StreamMode(m.StreamMode),
+		Targets:             m.Targets.orNil(),
+		MinifyBody:          m.MinifyBody,
+		CompressUpstream:    m.CompressUpstream,
+		PromptVariants:      m.PromptVariants.orNil(),
+		HealthCheckInterval: m.HealthCheckInterval,
+		HealthCheckMethod:   config.HealthCheckMethod(m.HealthCheckMethod),
+		PromptPosition:      config.PromptPosition(m.PromptPosition),
+		DedupePrompt:        m.DedupePrompt,
+		CanaryTarget:        m.CanaryTarget,
+		CanaryUrl:           m.CanaryUrl,
+		CanaryPercent:       m.CanaryPercent,
+		HedgeAfterMs:        m.HedgeAfterMs,
+		MaxHedges:           m.MaxHedges,
+		Tenant:              m.Tenant,
 	}, nil
 }
 
@@ -60,6 +135,41 @@ func (m *ModelConfigDB) FromModelConfig(cfg *config.ModelConfig) error {
 	m.Type = string(cfg.Type)
 	m.PromptPath = cfg.PromptPath
 	m.PromptValueType = string(cfg.PromptValueType)
+	m.ModelIDSource = string(cfg.ModelIDSource)
+	m.ModelIDKey = cfg.ModelIDKey
+	m.Disabled = cfg.Disabled
+	m.CacheTTL = cfg.CacheTTL
+	m.MaxConcurrency = cfg.MaxConcurrency
+	m.QueueOnLimit = cfg.QueueOnLimit
+	m.QueueTimeout = cfg.QueueTimeout
+	m.Maintenance = cfg.Maintenance
+	m.MaintenanceMessage = cfg.MaintenanceMessage
+	m.MaintenanceStatus = cfg.MaintenanceStatus
+	m.Aliases = StringList(cfg.Aliases)
+	m.SigningSecret = cfg.SigningSecret
+	m.Tools = JSONList(cfg.Tools)
+	m.ToolsMode = string(cfg.ToolsMode)
+	m.MaxTimeoutMs = cfg.MaxTimeoutMs
+	m.RequestHeaders = StringMap(cfg.RequestHeaders)
+	m.ResponseHeaders = StringMap(cfg.ResponseHeaders)
+	m.QueueTimeoutMs = cfg.QueueTimeoutMs
+	m.Source = string(cfg.Source)
+	m.Pipeline = StringList(cfg.Pipeline)
+	m.StreamMode = string(cfg.StreamMode)
+	m.Targets = WeightedTargets(cfg.Targets)
+	m.MinifyBody = cfg.MinifyBody
+	m.CompressUpstream = cfg.CompressUpstream
+	m.PromptVariants = PromptVariants(cfg.PromptVariants)
+	m.HealthCheckInterval = cfg.HealthCheckInterval
+	m.HealthCheckMethod = string(cfg.HealthCheckMethod)
+	m.PromptPosition = string(cfg.PromptPosition)
+	m.DedupePrompt = cfg.DedupePrompt
+	m.CanaryTarget = cfg.CanaryTarget
+	m.CanaryUrl = cfg.CanaryUrl
+	m.CanaryPercent = cfg.CanaryPercent
+	m.HedgeAfterMs = cfg.HedgeAfterMs
+	m.MaxHedges = cfg.MaxHedges
+	m.Tenant = config.TenantOrDefault(cfg.Tenant)
 
 	// 将PromptValue序列化为JSON字符串
 	if cfg.PromptValue != nil {
@@ -78,7 +188,7 @@ func (m *ModelConfigDB) FromModelConfig(cfg *config.ModelConfig) error {
 
 // ConfigMetadata 配置元数据表
 type ConfigMetadata struct {
-	Key       string    `gorm:"primaryKey;column:key" json:"key"`
+	Key       string    `gorm:"primaryKey;column:key;size:191" json:"key"`
 	Value     string    `gorm:"column:value;type:text" json:"value"`
 	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 }
@@ -90,15 +200,21 @@ func (ConfigMetadata) TableName() string {
 
 // User 用户表
 type User struct {
-	ID          uint       `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username    string     `gorm:"column:username;uniqueIndex;not null" json:"username"`
-	Password    string     `gorm:"column:password;not null" json:"-"` // 不在JSON中显示密码
-	IsAdmin     bool       `gorm:"column:is_admin;default:false" json:"is_admin"`
-	IsEnabled   bool       `gorm:"column:is_enabled;default:true" json:"is_enabled"` // 用户是否启用
-	LastLoginAt *time.Time `gorm:"column:last_login_at" json:"last_login_at"`        // 最后登录时间
-	CreatedBy   uint       `gorm:"column:created_by;default:0" json:"created_by"`    // 创建者ID，0表示系统创建
-	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+	ID                 uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username           string     `gorm:"column:username;size:191;uniqueIndex;not null" json:"username"`
+	Password           string     `gorm:"column:password;not null" json:"-"`                                     // 不在JSON中显示密码
+	Role               Role       `gorm:"column:role;size:16" json:"role"`                                       // 用户角色
+	IsAdmin            bool       `gorm:"column:is_admin;default:false" json:"is_admin"`                         // 兼容旧版本，由角色计算，角色为superuser时为true
+	IsEnabled          bool       `gorm:"column:is_enabled;default:true" json:"is_enabled"`                      // 用户是否启用
+	MustChangePassword bool       `gorm:"column:must_change_password;default:false" json:"must_change_password"` // 使用管理员设置的临时密码，需修改后才能使用管理功能
+	LastLoginAt        *time.Time `gorm:"column:last_login_at" json:"last_login_at"`                             // 最后登录时间
+	FailedLoginCount   int        `gorm:"column:failed_login_count;default:0" json:"failed_login_count"`         // 上次成功登录或锁定后连续登录失败的次数
+	LockedUntil        *time.Time `gorm:"column:locked_until" json:"locked_until"`                               // 账户锁定到期时间，期间即使密码正确也不能登录
+	CreatedBy          uint       `gorm:"column:created_by;default:0" json:"created_by"`                         // 创建者ID，0表示系统创建
+	TenantID           string     `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`                       // 所属租户
+	Global             bool       `gorm:"column:global;default:false" json:"global"`                             // 全局管理员，可以查看和管理所有租户的资源
+	CreatedAt          time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
 }
 
 // TableName 指定表名
@@ -108,16 +224,24 @@ func (User) TableName() string {
 
 // APIKey API密钥表
 type APIKey struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID      uint      `gorm:"column:user_id;not null;index" json:"user_id"`           // 所属用户ID
-	Name        string    `gorm:"column:name;not null" json:"name"`                       // API Key名称/描述
-	KeyValue    string    `gorm:"column:key_value;uniqueIndex;not null" json:"key_value"` // API Key值
-	IsEnabled   bool      `gorm:"column:is_enabled;default:true" json:"is_enabled"`       // 是否启用
-	LastUsedAt  *time.Time `gorm:"column:last_used_at" json:"last_used_at"`               // 最后使用时间
-	ExpiresAt   *time.Time `gorm:"column:expires_at" json:"expires_at"`                   // 过期时间，null表示永不过期
-	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
-	
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        uint       `gorm:"column:user_id;not null;index" json:"user_id"`          // 所属用户ID
+	Name          string     `gorm:"column:name;not null" json:"name"`                      // API Key名称/描述
+	KeyValue      string     `gorm:"-" json:"-"`                                            // API Key值，只在创建和认证时保存在内存中，数据库不保存明文
+	KeyHash       string     `gorm:"column:key_hash;size:64;uniqueIndex" json:"-"`          // API Key值的SHA-256哈希，用于认证时查找
+	KeyPrefix     string     `gorm:"column:key_prefix;size:16" json:"key_prefix"`           // API Key值的前8位，用于预览
+	IsEnabled     bool       `gorm:"column:is_enabled;default:true" json:"is_enabled"`      // 是否启用
+	LastUsedAt    *time.Time `gorm:"column:last_used_at" json:"last_used_at"`               // 最后使用时间
+	LastUsedIP    string     `gorm:"column:last_used_ip;size:64" json:"last_used_ip"`       // 最后使用时的客户端IP
+	ExpiresAt     *time.Time `gorm:"column:expires_at" json:"expires_at"`                   // 过期时间，null表示永不过期
+	AllowedModels StringList `gorm:"column:allowed_models;type:text" json:"allowed_models"` // 允许调用的模型ID，为空表示不限制
+	Labels        Labels     `gorm:"column:labels;type:text" json:"labels"`                 // 标签，如team=search
+	TenantID      string     `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`       // 所属租户，与所属用户相同，只能调用该租户的模型
+
+	AllowPromptOverride bool      `gorm:"column:allow_prompt_override;default:false" json:"allow_prompt_override"` // 是否允许通过请求头跳过或追加Prompt
+	CreatedAt           time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+
 	// 关联用户
 	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
@@ -126,3 +250,294 @@ type APIKey struct {
 func (APIKey) TableName() string {
 	return "api_keys"
 }
+
+// ModelUsage 模型调用统计表，与模型配置分开保存，重新加载YAML或更新模型配置时不受影响
+type ModelUsage struct {
+	ModelID           string     `gorm:"column:model_id;primaryKey;size:191" json:"model_id"`
+	LastUsedAt        *time.Time `gorm:"column:last_used_at;index" json:"last_used_at"`                            // 最后调用时间
+	TotalRequestCount int64      `gorm:"column:total_request_count;not null;default:0" json:"total_request_count"` // 累计请求数
+}
+
+// TableName 指定表名
+func (ModelUsage) TableName() string {
+	return "model_usage"
+}
+
+// AllowsModel 检查API Key是否允许调用指定模型，未设置允许列表时不限制
+func (k *APIKey) AllowsModel(modelID string) bool {
+	if len(k.AllowedModels) == 0 {
+		return true
+	}
+	for _, allowed := range k.AllowedModels {
+		if allowed == modelID {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLabels 检查API Key是否包含selector中的全部标签
+func (k *APIKey) HasLabels(selector map[string]string) bool {
+	for key, value := range selector {
+		if actual, ok := k.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// StringList 以JSON数组形式存储的字符串列表
+type StringList []string
+
+// Value 实现driver.Valuer接口
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// orNil 空列表返回nil，与YAML中未配置的字段保持一致
+func (l StringList) orNil() []string {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Scan 实现sql.Scanner接口
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析字符串列表: %T", value)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+// Labels 以JSON对象形式存储的标签
+type Labels map[string]string
+
+// Value 实现driver.Valuer接口
+func (l Labels) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现sql.Scanner接口
+func (l *Labels) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析标签: %T", value)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*map[string]string)(l))
+}
+
+// JSONList 以JSON数组形式存储的任意值列表
+type JSONList []interface{}
+
+// Value 实现driver.Valuer接口
+func (l JSONList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]interface{}(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// orNil 空列表返回nil，与YAML中未配置的字段保持一致
+func (l JSONList) orNil() []interface{} {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Scan 实现sql.Scanner接口
+func (l *JSONList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析JSON列表: %T", value)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*[]interface{})(l))
+}
+
+// StringMap 以JSON对象形式存储的字符串映射
+type StringMap map[string]string
+
+// Value 实现driver.Valuer接口
+func (m StringMap) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// orNil 空映射返回nil，与YAML中未配置的字段保持一致
+func (m StringMap) orNil() map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// Scan 实现sql.Scanner接口
+func (m *StringMap) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析字符串映射: %T", value)
+	}
+	if len(data) == 0 {
+		*m = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*map[string]string)(m))
+}
+
+// WeightedTargets 以JSON数组形式存储的按权重分流的目标列表
+type WeightedTargets []config.WeightedTarget
+
+// Value 实现driver.Valuer接口
+func (l WeightedTargets) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]config.WeightedTarget(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// orNil 空列表返回nil，与YAML中未配置的字段保持一致
+func (l WeightedTargets) orNil() []config.WeightedTarget {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Scan 实现sql.Scanner接口
+func (l *WeightedTargets) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析目标列表: %T", value)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*[]config.WeightedTarget)(l))
+}
+
+// PromptVariants 以JSON数组形式存储的Prompt实验的变体列表
+type PromptVariants []config.PromptVariant
+
+// Value 实现driver.Valuer接口
+func (l PromptVariants) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]config.PromptVariant(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// orNil 空列表返回nil，与YAML中未配置的字段保持一致
+func (l PromptVariants) orNil() []config.PromptVariant {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// Scan 实现sql.Scanner接口
+func (l *PromptVariants) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析Prompt变体列表: %T", value)
+	}
+	if len(data) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(data, (*[]config.PromptVariant)(l))
+}
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"gorm.io/gorm"
+)
+
+// Role 用户角色
+type Role string
+
+const (
+	RoleSuperuser Role = "superuser" // 全部权限，包括用户管理和系统设置
+	RoleOperator  Role = "operator"  // 管理模型和自己的API Key，不能管理用户
+	RoleViewer    Role = "viewer"    // 只能查看，不能修改任何配置
+)
+
+// Valid 是否为支持的角色
+func (r Role) Valid() bool {
+	switch r {
+	case RoleSuperuser, RoleOperator, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// Capability 管理API的操作权限，路由按所需的权限检查当前用户的角色
+type Capability string
+
+const (
+	CapModelsWrite Capability = "models:write" // 修改模型配置、重新加载配置
+	CapKeysWrite   Capability = "keys:write"   // 创建、修改和删除自己的API Key
+	CapLogsRead    Capability = "logs:read"    // 导出访问日志、查看日志记录器状态
+	CapUsersWrite  Capability = "users:write"  // 管理用户、其他用户的API Key和登录会话
+	CapSystemWrite Capability = "system:write" // 修改系统设置：维护模式、token有效期、备份恢复、告警Webhook等
+)
+
+// roleCapabilities 各角色拥有的权限，所有角色都可以查看模型、服务状态和自己的API Key
+var roleCapabilities = map[Role][]Capability{
+	RoleSuperuser: {CapModelsWrite, CapKeysWrite, CapLogsRead, CapUsersWrite, CapSystemWrite},
+	RoleOperator:  {CapModelsWrite, CapKeysWrite, CapLogsRead},
+	RoleViewer:    {},
+}
+
+// Can 角色是否拥有权限
+func (r Role) Can(capability Capability) bool {
+	for _, granted := range roleCapabilities[r] {
+		if granted == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities 角色拥有的全部权限
+func (r Role) Capabilities() []Capability {
+	return append([]Capability{}, roleCapabilities[r]...)
+}
+
+// RoleFromAdmin 根据旧的is_admin标志确定角色，用于迁移和未指定角色的请求；
+// 非管理员为只读的viewer，需要修改权限时应显式指定角色
+func RoleFromAdmin(isAdmin bool) Role {
+	if isAdmin {
+		return RoleSuperuser
+	}
+	return RoleViewer
+}
+
+// SetRole 设置用户角色，同时更新兼容旧版本的IsAdmin
+func (u *User) SetRole(role Role) {
+	u.Role = role
+	u.IsAdmin = role == RoleSuperuser
+}
+
+// syncRole 没有角色的记录（如直接写入的旧数据）按IsAdmin确定角色，IsAdmin总是由角色计算
+func (u *User) syncRole() {
+	if u.Role == "" {
+		u.Role = RoleFromAdmin(u.IsAdmin)
+	}
+	u.IsAdmin = u.Role == RoleSuperuser
+}
+
+// BeforeCreate 创建用户前同步角色和IsAdmin，未指定租户时属于默认租户
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	u.syncRole()
+	u.TenantID = config.TenantOrDefault(u.TenantID)
+	return nil
+}
+
+// AfterFind 读取用户后同步角色和IsAdmin
+func (u *User) AfterFind(tx *gorm.DB) error {
+	u.syncRole()
+	return nil
+}
+
+// migrateUserRoles 为升级前的用户设置角色：管理员为superuser，其他用户为只读的viewer
+func migrateUserRoles(db *gorm.DB) error {
+	if err := db.Model(&User{}).Where("(role = ? OR role IS NULL) AND is_admin = ?", "", true).
+		Update("role", RoleSuperuser).Error; err != nil {
+		return err
+	}
+	return db.Model(&User{}).Where("role = ? OR role IS NULL", "").Update("role", RoleFromAdmin(false)).Error
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// dataKeyMetadataKey 保存数据加密密钥的元数据键
+const dataKeyMetadataKey = "data_encryption_key"
+
+// sealedSecretPrefix 加密后的密钥前缀，没有前缀的值视为旧版本保存的明文
+const sealedSecretPrefix = "enc:v1:"
+
+// wrappedKeyPrefix 用主密钥加密后的数据加密密钥前缀，没有前缀的值为base64编码的明文密钥
+const wrappedKeyPrefix = "wrapped:v1:"
+
+// newGCM 使用key创建AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建数据加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// masterKeyCipher 由主密钥派生加密数据加密密钥的AES-GCM
+func masterKeyCipher(masterKey string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(masterKey))
+	return newGCM(key[:])
+}
+
+// encodeDataKey 编码要保存到元数据的数据加密密钥，设置了主密钥时用主密钥加密
+func encodeDataKey(key []byte, masterKey string) (string, error) {
+	if masterKey == "" {
+		return base64.StdEncoding.EncodeToString(key), nil
+	}
+	aead, err := masterKeyCipher(masterKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成加密随机数失败: %w", err)
+	}
+	return wrappedKeyPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, key, nil)), nil
+}
+
+// decodeDataKey 解析元数据中保存的数据加密密钥，wrapped表示是否用主密钥加密保存
+func decodeDataKey(value, masterKey string) (key []byte, wrapped bool, err error) {
+	encoded, wrapped := strings.CutPrefix(value, wrappedKeyPrefix)
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, wrapped, fmt.Errorf("解析数据加密密钥失败: %w", err)
+	}
+	if !wrapped {
+		return data, false, nil
+	}
+	if masterKey == "" {
+		return nil, true, fmt.Errorf("数据加密密钥已用主密钥加密，需要设置APP_DATABASE_MASTER_KEY")
+	}
+	aead, err := masterKeyCipher(masterKey)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, true, fmt.Errorf("数据加密密钥格式错误")
+	}
+	key, err = aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, true, fmt.Errorf("主密钥不正确，无法解密数据加密密钥")
+	}
+	return key, true, nil
+}
+
+// loadSecretCipher 获取或创建数据加密密钥，返回用于加密模型密钥的AES-GCM。
+// masterKey不为空时数据加密密钥用主密钥加密保存，已有的明文密钥在启动时改为加密保存
+func (m *Manager) loadSecretCipher(masterKey string) (cipher.AEAD, error) {
+	var key []byte
+	if value, err := m.GetMetadata(dataKeyMetadataKey); err == nil {
+		var wrapped bool
+		if key, wrapped, err = decodeDataKey(value, masterKey); err != nil {
+			return nil, err
+		}
+		if !wrapped && masterKey != "" {
+			encoded, err := encodeDataKey(key, masterKey)
+			if err != nil {
+				return nil, err
+			}
+			if err := m.SetMetadata(dataKeyMetadataKey, encoded); err != nil {
+				return nil, fmt.Errorf("保存数据加密密钥失败: %w", err)
+			}
+		}
+	} else {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("生成数据加密密钥失败: %w", err)
+		}
+		encoded, err := encodeDataKey(key, masterKey)
+		if err != nil {
+			return nil, err
+		}
+		// 只插入不覆盖：多个副本共用数据库并同时启动时，以先写入的密钥为准
+		metadata := ConfigMetadata{Key: dataKeyMetadataKey, Value: encoded}
+		if err := m.db.Create(&metadata).Error; err != nil {
+			value, getErr := m.GetMetadata(dataKeyMetadataKey)
+			if getErr != nil {
+				return nil, fmt.Errorf("保存数据加密密钥失败: %w", err)
+			}
+			if key, _, err = decodeDataKey(value, masterKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newGCM(key)
+}
+
+// sealSecret 加密要保存到数据库的密钥，空值保持为空
+func (m *Manager) sealSecret(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, m.secrets.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成加密随机数失败: %w", err)
+	}
+	sealed := m.secrets.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret 解密数据库中保存的密钥
+func (m *Manager) openSecret(stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedSecretPrefix)
+	if !ok {
+		return stored, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("解析加密的密钥失败: %w", err)
+	}
+	nonceSize := m.secrets.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", fmt.Errorf("加密的密钥格式错误")
+	}
+	plaintext, err := m.secrets.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密密钥失败: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// toDBModel 转换模型配置并加密其中的签名密钥和请求头（通常包含上游服务的凭证）
+func (m *Manager) toDBModel(cfg *config.ModelConfig) (*ModelConfigDB, error) {
+	cfg = cfg.Templated()
+	dbModel := &ModelConfigDB{}
+	if err := dbModel.FromModelConfig(cfg); err != nil {
+		return nil, err
+	}
+	sealed, err := m.sealSecret(cfg.SigningSecret)
+	if err != nil {
+		return nil, err
+	}
+	dbModel.SigningSecret = sealed
+	if len(cfg.RequestHeaders) > 0 {
+		headers := make(StringMap, len(cfg.RequestHeaders))
+		for name, value := range cfg.RequestHeaders {
+			if headers[name], err = m.sealSecret(value); err != nil {
+				return nil, err
+			}
+		}
+		dbModel.RequestHeaders = headers
+	}
+	return dbModel, nil
+}
+
+// openRequestHeaders 就地解密数据库中模型配置的请求头；签名密钥保持加密，
+// 只在转换为配置模型时解密，管理API只需要知道是否设置了签名密钥
+func (m *Manager) openRequestHeaders(dbModel *ModelConfigDB) error {
+	var err error
+	for name, value := range dbModel.RequestHeaders {
+		if dbModel.RequestHeaders[name], err = m.openSecret(value); err != nil {
+			return fmt.Errorf("解密请求头%s失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// fromDBModel 转换数据库中的模型配置并解密其中的密钥
+func (m *Manager) fromDBModel(dbModel *ModelConfigDB) (*config.ModelConfig, error) {
+	if err := m.openRequestHeaders(dbModel); err != nil {
+		return nil, err
+	}
+	cfg, err := dbModel.ToModelConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SigningSecret, err = m.openSecret(dbModel.SigningSecret); err != nil {
+		return nil, err
+	}
+	if err := cfg.ExpandStoredEnv(); err != nil {
+		return nil, fmt.Errorf("展开模型配置%s的环境变量失败: %w", cfg.ID, err)
+	}
+	return cfg, nil
+}
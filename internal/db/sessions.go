@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionNotFound 会话不存在
+var ErrSessionNotFound = errors.New("会话不存在")
+
+// Session 管理后台的登录会话，每次签发token时创建，以token的jti为主键；撤销后token立即失效
+type Session struct {
+	JTI        string     `gorm:"primaryKey;column:jti;size:64" json:"jti"`
+	UserID     uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	IssuedAt   time.Time  `gorm:"column:issued_at" json:"issued_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;index" json:"expires_at"`
+	LastSeenAt *time.Time `gorm:"column:last_seen_at" json:"last_seen_at"`         // 最后一次使用token访问管理API的时间
+	LastSeenIP string     `gorm:"column:last_seen_ip;size:64" json:"last_seen_ip"` // 最后一次访问时的客户端IP
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`             // 撤销时间，null表示未撤销
+}
+
+// TableName 指定表名
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// SessionWithUser 包含用户名的会话
+type SessionWithUser struct {
+	Session
+	Username string `json:"username"`
+}
+
+// CreateSession 保存新签发token的会话，同时清理已过期的会话
+func (m *Manager) CreateSession(session *Session) error {
+	if err := m.db.Where("expires_at < ?", session.IssuedAt).Delete(&Session{}).Error; err != nil {
+		return fmt.Errorf("清理过期会话失败: %w", err)
+	}
+	if err := m.db.Create(session).Error; err != nil {
+		return fmt.Errorf("创建会话失败: %w", err)
+	}
+	return nil
+}
+
+// GetSession 根据jti获取会话
+func (m *Manager) GetSession(jti string) (*Session, error) {
+	var session Session
+	if err := m.db.Where("jti = ?", jti).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("获取会话失败: %w", err)
+	}
+	return &session, nil
+}
+
+// TouchSession 更新会话的最后访问时间和IP，距上次更新不足interval时不写数据库
+func (m *Manager) TouchSession(jti, clientIP string, now time.Time, interval time.Duration) error {
+	result := m.db.Model(&Session{}).
+		Where("jti = ? AND (last_seen_at IS NULL OR last_seen_at < ? OR last_seen_ip <> ?)", jti, now.Add(-interval), clientIP).
+		Updates(map[string]interface{}{"last_seen_at": now, "last_seen_ip": clientIP})
+	if result.Error != nil {
+		return fmt.Errorf("更新会话访问时间失败: %w", result.Error)
+	}
+	return nil
+}
+
+// ListActiveSessions 获取未过期且未撤销的会话，按最后访问时间倒序；userID为0时返回所有用户的会话，
+// tenantID不为空时只返回该租户中非全局管理员用户的会话
+func (m *Manager) ListActiveSessions(userID uint, tenantID string, now time.Time) ([]SessionWithUser, error) {
+	query := m.db.Model(&Session{}).
+		Select("sessions.*, users.username").
+		Joins("LEFT JOIN users ON users.id = sessions.user_id").
+		Where("sessions.revoked_at IS NULL AND sessions.expires_at > ?", now)
+	if userID != 0 {
+		query = query.Where("sessions.user_id = ?", userID)
+	}
+	if tenantID != "" {
+		query = query.Where("users.tenant_id = ? AND users.global = ?", tenantID, false)
+	}
+
+	var sessions []SessionWithUser
+	if err := query.Order("sessions.last_seen_at DESC").Order("sessions.issued_at DESC").Scan(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("获取会话列表失败: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession 撤销会话，已撤销的会话保持原撤销时间；会话不存在时返回ErrSessionNotFound
+func (m *Manager) RevokeSession(jti string, now time.Time) error {
+	if _, err := m.GetSession(jti); err != nil {
+		return err
+	}
+	result := m.db.Model(&Session{}).Where("jti = ? AND revoked_at IS NULL", jti).Update("revoked_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("撤销会话失败: %w", result.Error)
+	}
+	return nil
+}
@@ -45,31 +45,22 @@ func (s *SQLiteDB) Init() error {
 	return nil
 }
 
-// SaveModelConfig 保存模型配置
+// SaveModelConfig 保存模型配置，字段与数据库表一致；更新已存在的模型时保留创建时间
 func (s *SQLiteDB) SaveModelConfig(cfg *config.ModelConfig) error {
+	cfg = cfg.Templated()
 	modelsDir := filepath.Join(filepath.Dir(s.path), "models")
 	modelFile := filepath.Join(modelsDir, cfg.ID+".json")
 
 	// 创建数据库模型
-	dbModel := &ModelRecord{
-		ID:              cfg.ID,
-		Name:            cfg.Name,
-		Target:          cfg.Target,
-		Url:             cfg.Url,
-		Type:            string(cfg.Type),
-		PromptPath:      cfg.PromptPath,
-		PromptValueType: string(cfg.PromptValueType),
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
-	}
-
-	// 序列化PromptValue
-	if cfg.PromptValue != nil {
-		promptValueBytes, err := json.Marshal(cfg.PromptValue)
-		if err != nil {
-			return fmt.Errorf("序列化PromptValue失败: %w", err)
-		}
-		dbModel.PromptValue = string(promptValueBytes)
+	dbModel := &ModelRecord{SigningSecret: cfg.SigningSecret}
+	if err := dbModel.FromModelConfig(cfg); err != nil {
+		return fmt.Errorf("序列化PromptValue失败: %w", err)
+	}
+	now := time.Now()
+	dbModel.CreatedAt = now
+	dbModel.UpdatedAt = now
+	if existing, err := s.readModelRecord(modelFile); err == nil && !existing.CreatedAt.IsZero() {
+		dbModel.CreatedAt = existing.CreatedAt
 	}
 
 	// 保存到文件
@@ -78,19 +69,33 @@ func (s *SQLiteDB) SaveModelConfig(cfg *config.ModelConfig) error {
 		return fmt.Errorf("序列化模型配置失败: %w", err)
 	}
 
-	if err := os.WriteFile(modelFile, data, 0644); err != nil {
+	// 文件中可能包含明文的签名密钥，只允许所有者读写
+	if err := os.WriteFile(modelFile, data, 0600); err != nil {
 		return fmt.Errorf("保存模型配置文件失败: %w", err)
 	}
 
 	return nil
 }
 
+// readModelRecord 读取模型配置文件
+func (s *SQLiteDB) readModelRecord(modelFile string) (*ModelRecord, error) {
+	data, err := os.ReadFile(modelFile)
+	if err != nil {
+		return nil, err
+	}
+	var dbModel ModelRecord
+	if err := json.Unmarshal(data, &dbModel); err != nil {
+		return nil, fmt.Errorf("解析模型配置失败: %w", err)
+	}
+	return &dbModel, nil
+}
+
 // GetModelConfig 获取模型配置
 func (s *SQLiteDB) GetModelConfig(id string) (*config.ModelConfig, error) {
 	modelsDir := filepath.Join(filepath.Dir(s.path), "models")
 	modelFile := filepath.Join(modelsDir, id+".json")
 
-	data, err := os.ReadFile(modelFile)
+	dbModel, err := s.readModelRecord(modelFile)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("模型配置不存在: %s", id)
@@ -98,11 +103,6 @@ func (s *SQLiteDB) GetModelConfig(id string) (*config.ModelConfig, error) {
 		return nil, fmt.Errorf("读取模型配置文件失败: %w", err)
 	}
 
-	var dbModel ModelRecord
-	if err := json.Unmarshal(data, &dbModel); err != nil {
-		return nil, fmt.Errorf("解析模型配置失败: %w", err)
-	}
-
 	return dbModel.ToModelConfig()
 }
 
@@ -174,39 +174,24 @@ func (s *SQLiteDB) Close() error {
 	return nil
 }
 
-// ModelRecord 数据库记录结构
+// ModelRecord 文件存储的模型记录，字段和JSON格式与数据库表ModelConfigDB一致，
+// 两种存储之间切换时不丢失数据；旧版本只包含部分字段的文件仍可读取
 type ModelRecord struct {
-	ID              string    `json:"id"`
-	Name            string    `json:"name"`
-	Target          string    `json:"target"`
-	Prompt          string    `json:"prompt"`
-	Url             string    `json:"url"`
-	Type            string    `json:"type"`
-	PromptPath      string    `json:"prompt_path"`
-	PromptValue     string    `json:"prompt_value"` // JSON字符串
-	PromptValueType string    `json:"prompt_value_type"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ModelConfigDB
+
+	// SigningSecret 请求签名密钥，文件存储没有数据加密密钥，以明文保存
+	SigningSecret string `json:"signing_secret,omitempty"`
 }
 
 // ToModelConfig 转换为配置模型
 func (m *ModelRecord) ToModelConfig() (*config.ModelConfig, error) {
-	var promptValue interface{}
-	if m.PromptValue != "" {
-		if err := json.Unmarshal([]byte(m.PromptValue), &promptValue); err != nil {
-			// 如果JSON解析失败，当作字符串处理
-			promptValue = m.PromptValue
-		}
+	cfg, err := m.ModelConfigDB.ToModelConfig()
+	if err != nil {
+		return nil, err
 	}
-
-	return &config.ModelConfig{
-		ID:              m.ID,
-		Name:            m.Name,
-		Target:          m.Target,
-		Url:             m.Url,
-		Type:            config.ModelType(m.Type),
-		PromptPath:      m.PromptPath,
-		PromptValue:     promptValue,
-		PromptValueType: config.ValueType(m.PromptValueType),
-	}, nil
+	cfg.SigningSecret = m.SigningSecret
+	if err := cfg.ExpandStoredEnv(); err != nil {
+		return nil, fmt.Errorf("展开模型配置%s的环境变量失败: %w", cfg.ID, err)
+	}
+	return cfg, nil
 }
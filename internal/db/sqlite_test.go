@@ -0,0 +1,115 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestSQLiteDBModelRoundTrip(t *testing.T) {
+	store, err := NewSQLiteDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建文件存储失败: %v", err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatalf("初始化文件存储失败: %v", err)
+	}
+
+	cfg := &config.ModelConfig{
+		ID: "assistant", Name: "Assistant", Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions",
+		Type:               config.ModelTypeChat,
+		Prompt:             "你是一个乐于助人的助手",
+		PromptPath:         "messages.0",
+		PromptValue:        map[string]interface{}{"role": "system", "content": "你好"},
+		PromptValueType:    config.ValueTypeObject,
+		ModelIDSource:      config.ModelIDSourceQuery,
+		ModelIDKey:         "model",
+		CacheTTL:           60,
+		MaxConcurrency:     4,
+		Maintenance:        true,
+		MaintenanceMessage: "升级中",
+		Aliases:            []string{"helper"},
+		SigningSecret:      "secret",
+		RequestHeaders:     map[string]string{"X-Team": "proxy"},
+		Pipeline:           []string{config.PipelineStageInject, config.PipelineStageRewrite, config.PipelineStageForward},
+		StreamMode:         config.StreamModeSSE,
+		Targets:            []config.WeightedTarget{{Name: "a", Target: "gpt-4o", Weight: 1}},
+		MinifyBody:         true,
+		CompressUpstream:   true,
+		DedupePrompt:       true,
+		Tenant:             config.DefaultTenant,
+	}
+	if err := store.SaveModelConfig(cfg); err != nil {
+		t.Fatalf("保存模型配置失败: %v", err)
+	}
+
+	got, err := store.GetModelConfig(cfg.ID)
+	if err != nil {
+		t.Fatalf("读取模型配置失败: %v", err)
+	}
+	if got.Prompt != cfg.Prompt {
+		t.Errorf("Prompt = %q, want %q", got.Prompt, cfg.Prompt)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("文件存储读写不一致\n got: %+v\nwant: %+v", got, cfg)
+	}
+
+	// 与数据库存储的转换结果一致
+	var dbModel ModelConfigDB
+	if err := dbModel.FromModelConfig(cfg); err != nil {
+		t.Fatalf("转换模型配置失败: %v", err)
+	}
+	fromDB, _ := dbModel.ToModelConfig()
+	fromDB.SigningSecret = cfg.SigningSecret
+	if !reflect.DeepEqual(got, fromDB) {
+		t.Errorf("文件存储与数据库存储的字段不一致\nfile: %+v\n  db: %+v", got, fromDB)
+	}
+}
+
+func TestSQLiteDBKeepsCreatedAt(t *testing.T) {
+	store, err := NewSQLiteDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建文件存储失败: %v", err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatalf("初始化文件存储失败: %v", err)
+	}
+	modelFile := filepath.Join(filepath.Dir(store.path), "models", "legacy.json")
+
+	// 旧版本只包含部分字段的文件
+	legacy := `{"id":"legacy","name":"Legacy","target":"gpt-4","prompt":"旧的提示词","url":"https://example.com","type":"chat",` +
+		`"created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-02T03:04:05Z"}`
+	if err := os.WriteFile(modelFile, []byte(legacy), 0644); err != nil {
+		t.Fatalf("写入模型配置文件失败: %v", err)
+	}
+	cfg, err := store.GetModelConfig("legacy")
+	if err != nil {
+		t.Fatalf("读取旧版本模型配置失败: %v", err)
+	}
+	if cfg.Prompt != "旧的提示词" || cfg.Target != "gpt-4" {
+		t.Errorf("旧版本模型配置 = %+v", cfg)
+	}
+
+	cfg.Name = "Legacy v2"
+	if err := store.UpdateModelConfig(cfg); err != nil {
+		t.Fatalf("更新模型配置失败: %v", err)
+	}
+	record, err := store.readModelRecord(modelFile)
+	if err != nil {
+		t.Fatalf("读取模型记录失败: %v", err)
+	}
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !record.CreatedAt.Equal(created) {
+		t.Errorf("更新后created_at = %v, want %v", record.CreatedAt, created)
+	}
+	if !record.UpdatedAt.After(created) {
+		t.Errorf("更新后updated_at应为当前时间，实际得到%v", record.UpdatedAt)
+	}
+	if record.Name != "Legacy v2" || record.Prompt != "旧的提示词" {
+		t.Errorf("更新后的模型记录 = %+v", record)
+	}
+}
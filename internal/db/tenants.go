@@ -0,0 +1,100 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"gorm.io/gorm"
+)
+
+// 租户操作的哨兵错误
+var (
+	ErrTenantNotFound = errors.New("租户不存在")
+	ErrTenantExists   = errors.New("租户已存在")
+)
+
+// Tenant 租户，用户、API Key和模型配置按租户划分，非全局管理员只能查看和管理所属租户的资源
+type Tenant struct {
+	ID        string    `gorm:"primaryKey;column:id;size:64" json:"id"`
+	Name      string    `gorm:"column:name;size:100" json:"name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// migrateTenants 创建默认租户，并将升级前的用户、API Key和模型配置（包括回收站中的）分配到默认租户；
+// 升级前的superuser设为全局管理员，保留查看和管理全部资源的权限。只处理没有租户的记录，可以重复执行
+func migrateTenants(db *gorm.DB) error {
+	if err := db.Where(Tenant{ID: config.DefaultTenant}).Attrs(Tenant{Name: "默认租户"}).
+		FirstOrCreate(&Tenant{}).Error; err != nil {
+		return fmt.Errorf("创建默认租户失败: %w", err)
+	}
+	if err := db.Model(&User{}).Where("(tenant_id = ? OR tenant_id IS NULL) AND role = ?", "", RoleSuperuser).
+		Update("global", true).Error; err != nil {
+		return fmt.Errorf("设置全局管理员失败: %w", err)
+	}
+	for _, model := range []interface{}{&User{}, &APIKey{}, &ModelConfigDB{}} {
+		if err := db.Unscoped().Model(model).Where("tenant_id = ? OR tenant_id IS NULL", "").
+			Update("tenant_id", config.DefaultTenant).Error; err != nil {
+			return fmt.Errorf("分配默认租户失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateTenant 创建租户，ID已存在时返回ErrTenantExists
+func (m *Manager) CreateTenant(tenant *Tenant) error {
+	if _, err := m.GetTenant(tenant.ID); err == nil {
+		return fmt.Errorf("%w: %s", ErrTenantExists, tenant.ID)
+	}
+	if err := m.db.Create(tenant).Error; err != nil {
+		return fmt.Errorf("创建租户失败: %w", err)
+	}
+	return nil
+}
+
+// GetTenant 根据ID获取租户，不存在时返回ErrTenantNotFound
+func (m *Manager) GetTenant(id string) (*Tenant, error) {
+	var tenant Tenant
+	if err := m.db.Where("id = ?", id).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, id)
+		}
+		return nil, fmt.Errorf("获取租户失败: %w", err)
+	}
+	return &tenant, nil
+}
+
+// GetTenants 获取全部租户，按ID排序
+func (m *Manager) GetTenants() ([]Tenant, error) {
+	var tenants []Tenant
+	if err := m.db.Order("id").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("获取租户列表失败: %w", err)
+	}
+	return tenants, nil
+}
+
+// UpdateUserTenant 修改用户所属的租户和是否为全局管理员，用户的API Key随之转到新租户
+func (m *Manager) UpdateUserTenant(id uint, tenantID string, global bool) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"tenant_id": tenantID,
+			"global":    global,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("更新用户租户失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("用户不存在: %d", id)
+		}
+		if err := tx.Model(&APIKey{}).Where("user_id = ?", id).Update("tenant_id", tenantID).Error; err != nil {
+			return fmt.Errorf("更新用户API Key的租户失败: %w", err)
+		}
+		return nil
+	})
+}
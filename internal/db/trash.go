@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"gorm.io/gorm"
+)
+
+// 回收站操作的哨兵错误
+var (
+	ErrModelNotInTrash = errors.New("回收站中没有该模型")
+	ErrModelIDInUse    = errors.New("模型ID已被其他模型使用")
+)
+
+// trashKeyPrefix 回收站中模型主键的前缀，主键改为回收站内唯一的键后，可以用原ID创建新模型
+const trashKeyPrefix = "trash:"
+
+// maxModelIDSize 模型ID列的长度
+const maxModelIDSize = 191
+
+// trashKey 返回模型移入回收站后的主键，超过列长度时截断原ID
+func trashKey(id string, deletedAt time.Time) string {
+	key := fmt.Sprintf("%s%d:%s", trashKeyPrefix, deletedAt.UnixNano(), id)
+	if len(key) > maxModelIDSize {
+		key = key[:maxModelIDSize]
+	}
+	return key
+}
+
+// trashModelConfig 在事务中将模型配置移入回收站，返回移动的行数
+func trashModelConfig(tx *gorm.DB, id string, now time.Time) (int64, error) {
+	result := tx.Model(&ModelConfigDB{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"id":         trashKey(id, now),
+		"trashed_id": id,
+		"deleted_at": now,
+	})
+	return result.RowsAffected, result.Error
+}
+
+// GetTrashedModelConfigs 获取回收站中的模型配置（包含时间信息），按删除时间从新到旧排序，
+// tenantID不为空时只返回该租户的模型
+func (m *Manager) GetTrashedModelConfigs(tenantID string) ([]ModelConfigDB, error) {
+	var dbModels []ModelConfigDB
+	query := m.db.Unscoped().Where("deleted_at IS NOT NULL")
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	result := query.Order("deleted_at DESC").Find(&dbModels)
+	if result.Error != nil {
+		return nil, fmt.Errorf("获取回收站中的模型配置失败: %w", result.Error)
+	}
+	for i := range dbModels {
+		if err := m.openRequestHeaders(&dbModels[i]); err != nil {
+			return nil, fmt.Errorf("解密模型配置失败 %s: %w", dbModels[i].TrashedID, err)
+		}
+	}
+	return dbModels, nil
+}
+
+// trashedQuery 回收站中原ID为id的模型，tenantID不为空时只包括该租户的模型
+func trashedQuery(tx *gorm.DB, id, tenantID string) *gorm.DB {
+	query := tx.Unscoped().Where("trashed_id = ? AND deleted_at IS NOT NULL", id)
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	return query
+}
+
+// findTrashedModel 查找回收站中原ID为id的模型，同一个ID多次删除时返回最近删除的；
+// tenantID不为空时只查找该租户的模型
+func findTrashedModel(tx *gorm.DB, id, tenantID string) (*ModelConfigDB, error) {
+	var dbModel ModelConfigDB
+	result := trashedQuery(tx, id, tenantID).Order("deleted_at DESC").First(&dbModel)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrModelNotInTrash, id)
+		}
+		return nil, fmt.Errorf("查询回收站失败: %w", result.Error)
+	}
+	return &dbModel, nil
+}
+
+// GetTrashedModelConfig 获取回收站中最近删除的原ID为id的模型配置，tenantID不为空时只查找该租户的模型；
+// 不存在时返回ErrModelNotInTrash
+func (m *Manager) GetTrashedModelConfig(id, tenantID string) (*config.ModelConfig, error) {
+	dbModel, err := findTrashedModel(m.db, id, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	dbModel.ID = dbModel.TrashedID
+	return m.fromDBModel(dbModel)
+}
+
+// RestoreModelConfig 从回收站恢复最近删除的原ID为id的模型配置，tenantID不为空时只恢复该租户的模型；
+// 该ID已有模型时返回ErrModelIDInUse，回收站中没有时返回ErrModelNotInTrash
+func (m *Manager) RestoreModelConfig(id, tenantID string) (*config.ModelConfig, error) {
+	var restored *config.ModelConfig
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		dbModel, err := findTrashedModel(tx, id, tenantID)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&ModelConfigDB{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询模型配置失败: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", ErrModelIDInUse, id)
+		}
+
+		result := tx.Unscoped().Model(&ModelConfigDB{}).Where("id = ?", dbModel.ID).Updates(map[string]interface{}{
+			"id":         id,
+			"trashed_id": "",
+			"deleted_at": nil,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("恢复模型配置失败: %w", result.Error)
+		}
+		dbModel.ID = id
+		restored, err = m.fromDBModel(dbModel)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeModelConfig 永久删除回收站中原ID为id的模型配置（包括多次删除的），tenantID不为空时只删除该租户的模型；
+// 回收站中没有时返回ErrModelNotInTrash
+func (m *Manager) PurgeModelConfig(id, tenantID string) error {
+	result := trashedQuery(m.db, id, tenantID).Delete(&ModelConfigDB{})
+	if result.Error != nil {
+		return fmt.Errorf("永久删除模型配置失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrModelNotInTrash, id)
+	}
+	return nil
+}
+
+// PurgeTrashedModelConfigs 永久删除回收站中在before之前删除的模型配置，返回删除的数量
+func (m *Manager) PurgeTrashedModelConfigs(before time.Time) (int64, error) {
+	result := m.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(&ModelConfigDB{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理回收站失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
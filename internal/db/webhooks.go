@@ -0,0 +1,126 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrWebhookNotFound Webhook不存在
+var ErrWebhookNotFound = errors.New("Webhook不存在")
+
+// Webhook 接收告警的Webhook
+type Webhook struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string     `gorm:"column:name;size:100" json:"name"`
+	URL       string     `gorm:"column:url;type:text;not null" json:"url"`
+	Secret    string     `gorm:"column:secret;type:text" json:"-"`      // 请求签名密钥，由Manager加密后保存，为空时不签名
+	Events    StringList `gorm:"column:events;type:text" json:"events"` // 订阅的事件类型，为空表示全部
+	IsEnabled bool       `gorm:"column:is_enabled" json:"is_enabled"`   // 是否启用
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Subscribes 是否订阅了该类型的事件
+func (w *Webhook) Subscribes(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// sealWebhook 返回签名密钥加密后的副本
+func (m *Manager) sealWebhook(webhook *Webhook) (*Webhook, error) {
+	sealed := *webhook
+	var err error
+	if sealed.Secret, err = m.sealSecret(webhook.Secret); err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// CreateWebhook 创建Webhook，创建后webhook.ID为新记录的ID
+func (m *Manager) CreateWebhook(webhook *Webhook) error {
+	sealed, err := m.sealWebhook(webhook)
+	if err != nil {
+		return err
+	}
+	if err := m.db.Create(sealed).Error; err != nil {
+		return fmt.Errorf("创建Webhook失败: %w", err)
+	}
+	webhook.ID, webhook.CreatedAt, webhook.UpdatedAt = sealed.ID, sealed.CreatedAt, sealed.UpdatedAt
+	return nil
+}
+
+// GetWebhook 根据ID获取Webhook，签名密钥已解密
+func (m *Manager) GetWebhook(id uint) (*Webhook, error) {
+	var webhook Webhook
+	if err := m.db.First(&webhook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("获取Webhook失败: %w", err)
+	}
+	secret, err := m.openSecret(webhook.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("解密Webhook %d的签名密钥失败: %w", webhook.ID, err)
+	}
+	webhook.Secret = secret
+	return &webhook, nil
+}
+
+// ListWebhooks 获取全部Webhook，签名密钥已解密
+func (m *Manager) ListWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := m.db.Order("id").Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("获取Webhook列表失败: %w", err)
+	}
+	for i := range webhooks {
+		secret, err := m.openSecret(webhooks[i].Secret)
+		if err != nil {
+			return nil, fmt.Errorf("解密Webhook %d的签名密钥失败: %w", webhooks[i].ID, err)
+		}
+		webhooks[i].Secret = secret
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook 保存Webhook的全部字段，Webhook不存在时返回ErrWebhookNotFound
+func (m *Manager) UpdateWebhook(webhook *Webhook) error {
+	if _, err := m.GetWebhook(webhook.ID); err != nil {
+		return err
+	}
+	sealed, err := m.sealWebhook(webhook)
+	if err != nil {
+		return err
+	}
+	if err := m.db.Select("*").Omit("created_at").Save(sealed).Error; err != nil {
+		return fmt.Errorf("更新Webhook失败: %w", err)
+	}
+	webhook.UpdatedAt = sealed.UpdatedAt
+	return nil
+}
+
+// DeleteWebhook 删除Webhook，Webhook不存在时返回ErrWebhookNotFound
+func (m *Manager) DeleteWebhook(id uint) error {
+	result := m.db.Delete(&Webhook{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除Webhook失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package healthcheck
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// tickInterval 后台检查循环查找到期模型的间隔
+const tickInterval = time.Second
+
+// staleFactor 检查结果超过该倍数的检查间隔未更新时视为过期，不再据此拒绝请求
+const staleFactor = 3
+
+// Monitor 按模型配置的health_check_interval在后台定期检查上游连通性，保存每个模型最近的检查结果。
+// 检查在独立的goroutine中进行，不持有配置的锁，读取结果不会等待检查完成
+type Monitor struct {
+	models  func() []*config.ModelConfig
+	timeout time.Duration
+	client  *http.Client
+
+	mutex   sync.RWMutex
+	status  map[string]ModelStatus
+	running map[string]bool
+
+	onChange func(status ModelStatus) // 检查结果在可用和不可用之间变化时调用
+
+	started  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewMonitor 创建健康检查器，models返回当前全部模型配置，timeout为一次检查的超时时间，
+// 调用Start后开始后台检查
+func NewMonitor(models func() []*config.ModelConfig, timeout time.Duration) *Monitor {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Monitor{
+		models:  models,
+		timeout: timeout,
+		client: &http.Client{
+			// 不跟随重定向，重定向响应本身说明上游可达
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		status:  make(map[string]ModelStatus),
+		running: make(map[string]bool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// SetTransitionHandler 设置检查结果变化时的回调：模型由可用变为不可用或恢复可用时调用，
+// 第一次检查只在不可用时调用。需在Start之前设置
+func (m *Monitor) SetTransitionHandler(handler func(status ModelStatus)) {
+	m.onChange = handler
+}
+
+// Start 开始后台检查
+func (m *Monitor) Start() {
+	m.started = true
+	go m.run()
+}
+
+// Close 停止后台检查并等待进行中的检查结束
+func (m *Monitor) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	if m.started {
+		<-m.done
+	}
+	m.wg.Wait()
+}
+
+// run 检查循环，每次找出到达检查间隔的模型并发检查
+func (m *Monitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			for _, model := range m.models() {
+				if m.due(model, now) {
+					m.wg.Add(1)
+					go func(model *config.ModelConfig) {
+						defer m.wg.Done()
+						m.check(model)
+					}(model)
+				}
+			}
+		}
+	}
+}
+
+// due 模型是否需要检查：开启了定期检查、没有进行中的检查且距上次检查已超过间隔
+func (m *Monitor) due(model *config.ModelConfig, now time.Time) bool {
+	if model.HealthCheckInterval <= 0 || model.Disabled {
+		return false
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if m.running[model.ID] {
+		return false
+	}
+	last, exists := m.status[model.ID]
+	return !exists || now.Sub(last.CheckedAt) >= time.Duration(model.HealthCheckInterval)*time.Second
+}
+
+// check 在后台检查一个模型，同一模型同时只有一个后台检查
+func (m *Monitor) check(model *config.ModelConfig) {
+	m.mutex.Lock()
+	if m.running[model.ID] {
+		m.mutex.Unlock()
+		return
+	}
+	m.running[model.ID] = true
+	m.mutex.Unlock()
+
+	defer func() {
+		m.mutex.Lock()
+		delete(m.running, model.ID)
+		m.mutex.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	m.Check(ctx, model)
+}
+
+// Check 立即检查模型并保存结果，ctx没有截止时间时使用检查器的超时时间
+func (m *Monitor) Check(ctx context.Context, model *config.ModelConfig) ModelStatus {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	status := Probe(ctx, m.client, model)
+
+	m.mutex.Lock()
+	previous, exists := m.status[model.ID]
+	m.status[model.ID] = status
+	m.mutex.Unlock()
+
+	if m.onChange != nil && (exists && previous.Healthy != status.Healthy || !exists && !status.Healthy) {
+		m.onChange(status)
+	}
+	return status
+}
+
+// Status 获取模型最近一次的检查结果，没有检查过时返回false
+func (m *Monitor) Status(modelID string) (ModelStatus, bool) {
+	if m == nil {
+		return ModelStatus{}, false
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	status, exists := m.status[modelID]
+	return status, exists
+}
+
+// Down 模型是否开启了定期检查且最近一次检查失败；结果已过期（超过检查间隔的staleFactor倍未更新）时不认为不可用
+func (m *Monitor) Down(model *config.ModelConfig) bool {
+	if m == nil || model.HealthCheckInterval <= 0 {
+		return false
+	}
+	status, exists := m.Status(model.ID)
+	if !exists || status.Healthy {
+		return false
+	}
+	return time.Since(status.CheckedAt) <= staleFactor*time.Duration(model.HealthCheckInterval)*time.Second
+}
+
+// Forget 删除模型的检查结果，模型被删除后调用
+func (m *Monitor) Forget(modelID string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.status, modelID)
+}
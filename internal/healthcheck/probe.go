@@ -0,0 +1,184 @@
+// Package healthcheck 检查模型上游服务的连通性
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/signing"
+)
+
+// DefaultTimeout 一次检查（一个模型的全部上游）的默认超时时间
+const DefaultTimeout = 5 * time.Second
+
+// Result 一个上游地址的检查结果
+type Result struct {
+	Target     string `json:"target"`
+	URL        string `json:"url"`
+	DNSMs      int64  `json:"dns_ms"`      // DNS解析耗时
+	ConnectMs  int64  `json:"connect_ms"`  // TCP连接耗时，不含TLS握手
+	TLSOK      *bool  `json:"tls_ok"`      // TLS握手是否成功，http上游为null
+	HTTPStatus int    `json:"http_status"` // head和request方式的响应状态码，connect方式为0
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error"`
+}
+
+// ModelStatus 一个模型的检查结果，任意一个上游可用即认为模型可用
+type ModelStatus struct {
+	ModelID   string                   `json:"model_id"`
+	Method    config.HealthCheckMethod `json:"method"`
+	Healthy   bool                     `json:"healthy"`
+	CheckedAt time.Time                `json:"checked_at"`
+	Upstreams []Result                 `json:"upstreams"`
+}
+
+// Probe 检查模型的全部上游，配置了按权重分流时检查每个权重大于0的目标，
+// ctx的截止时间即检查的超时时间
+func Probe(ctx context.Context, client *http.Client, model *config.ModelConfig) ModelStatus {
+	method := model.HealthCheckMethod
+	if method == "" {
+		method = config.HealthCheckConnect
+	}
+	status := ModelStatus{ModelID: model.ID, Method: method, CheckedAt: time.Now()}
+
+	upstreams := []*config.ModelConfig{model}
+	if len(model.Targets) > 0 {
+		upstreams = upstreams[:0]
+		for _, target := range model.Targets {
+			if target.Weight > 0 {
+				upstreams = append(upstreams, model.WithTarget(target))
+			}
+		}
+	}
+	for _, upstream := range upstreams {
+		result := probeUpstream(ctx, client, upstream, method)
+		status.Healthy = status.Healthy || result.Healthy
+		status.Upstreams = append(status.Upstreams, result)
+	}
+	return status
+}
+
+// probeUpstream 依次进行DNS解析、TCP连接、TLS握手，按方式发送HEAD或最小请求，任意一步失败即停止
+func probeUpstream(ctx context.Context, client *http.Client, model *config.ModelConfig, method config.HealthCheckMethod) Result {
+	result := Result{Target: model.Target}
+	rawURL, err := model.ExpandURL(model.ID, "")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.URL = rawURL
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		result.Error = fmt.Sprintf("无效的URL: %v", err)
+		return result
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	result.DNSMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("DNS解析失败: %v", err)
+		return result
+	}
+
+	start = time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], port))
+	result.ConnectMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = fmt.Sprintf("连接失败: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	if u.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		err := tlsConn.HandshakeContext(ctx)
+		ok := err == nil
+		result.TLSOK = &ok
+		if err != nil {
+			result.Error = fmt.Sprintf("TLS握手失败: %v", err)
+			return result
+		}
+	}
+
+	if method == config.HealthCheckConnect {
+		result.Healthy = true
+		return result
+	}
+
+	req, err := newProbeRequest(ctx, model, method, rawURL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = fmt.Sprintf("请求失败: %v", err)
+		return result
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	result.HTTPStatus = resp.StatusCode
+	result.Healthy = statusHealthy(method, resp.StatusCode)
+	if !result.Healthy {
+		result.Error = fmt.Sprintf("上游返回状态码 %d", resp.StatusCode)
+	}
+	return result
+}
+
+// statusHealthy HEAD请求只要上游没有返回5xx即认为可用（很多接口不支持HEAD）；
+// 最小请求需要成功，429表示上游可用但被限流
+func statusHealthy(method config.HealthCheckMethod, status int) bool {
+	if method == config.HealthCheckHead {
+		return status < http.StatusInternalServerError
+	}
+	return status/100 == 2 || status == http.StatusTooManyRequests
+}
+
+// newProbeRequest 构建HEAD请求或最小请求，最小请求带模型配置的请求头，配置了签名密钥时签名
+func newProbeRequest(ctx context.Context, model *config.ModelConfig, method config.HealthCheckMethod, rawURL string) (*http.Request, error) {
+	if method == config.HealthCheckHead {
+		return http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	}
+
+	payload := map[string]interface{}{"model": model.Target}
+	if model.Type == config.ModelTypeEmbedding {
+		payload["input"] = "ping"
+	} else {
+		payload["messages"] = []map[string]string{{"role": "user", "content": "ping"}}
+		payload["max_tokens"] = 1
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range model.RequestHeaders {
+		req.Header.Set(key, value)
+	}
+	if model.SigningSecret != "" {
+		signing.SignRequest(req, model.SigningSecret, body, time.Now())
+	}
+	return req, nil
+}
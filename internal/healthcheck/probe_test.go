@@ -0,0 +1,200 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// closedURL 返回一个没有服务监听的本地地址
+func closedURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听端口失败: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr + "/v1/chat/completions"
+}
+
+func probe(model *config.ModelConfig) ModelStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return Probe(ctx, http.DefaultClient, model)
+}
+
+func TestProbeConnect(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("connect方式不应发送HTTP请求，收到%s", r.Method)
+	}))
+	defer upstream.Close()
+
+	status := probe(&config.ModelConfig{ID: "m", Target: "gpt-4o", Url: upstream.URL})
+	if !status.Healthy || status.Method != config.HealthCheckConnect || len(status.Upstreams) != 1 {
+		t.Fatalf("期望连接成功，实际%+v", status)
+	}
+	if result := status.Upstreams[0]; result.TLSOK != nil || result.HTTPStatus != 0 || result.Error != "" {
+		t.Errorf("http上游不应有TLS和HTTP结果，实际%+v", result)
+	}
+
+	status = probe(&config.ModelConfig{ID: "m", Target: "gpt-4o", Url: closedURL(t)})
+	if status.Healthy || status.Upstreams[0].Error == "" {
+		t.Errorf("连接被拒绝时应不可用并给出错误，实际%+v", status)
+	}
+}
+
+func TestProbeTLS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.NotFoundHandler())
+	defer upstream.Close()
+
+	// 测试服务器使用自签名证书，握手应失败
+	status := probe(&config.ModelConfig{ID: "m", Target: "gpt-4o", Url: upstream.URL})
+	result := status.Upstreams[0]
+	if status.Healthy || result.TLSOK == nil || *result.TLSOK {
+		t.Errorf("证书不受信任时TLS握手应失败，实际%+v", result)
+	}
+}
+
+func TestProbeRequest(t *testing.T) {
+	var received map[string]interface{}
+	var auth string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		if auth != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer upstream.Close()
+
+	model := &config.ModelConfig{
+		ID: "m", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+		HealthCheckMethod: config.HealthCheckHead,
+	}
+	status := probe(model)
+	if !status.Healthy || status.Upstreams[0].HTTPStatus != http.StatusMethodNotAllowed {
+		t.Errorf("HEAD请求返回4xx时上游可用，实际%+v", status)
+	}
+
+	model.HealthCheckMethod = config.HealthCheckRequest
+	model.RequestHeaders = map[string]string{"Authorization": "Bearer sk-test"}
+	status = probe(model)
+	if !status.Healthy || status.Upstreams[0].HTTPStatus != http.StatusOK {
+		t.Errorf("最小请求成功时上游可用，实际%+v", status)
+	}
+	if received["model"] != "gpt-4o" || received["max_tokens"] != float64(1) {
+		t.Errorf("最小请求体不正确: %v", received)
+	}
+
+	model.RequestHeaders = nil
+	if status = probe(model); status.Healthy || status.Upstreams[0].HTTPStatus != http.StatusUnauthorized {
+		t.Errorf("认证失败时上游不可用，实际%+v", status)
+	}
+}
+
+func TestProbeTargets(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	defer upstream.Close()
+
+	model := &config.ModelConfig{ID: "m", Target: "gpt-4o", Url: upstream.URL, Targets: []config.WeightedTarget{
+		{Target: "a", Url: upstream.URL, Weight: 1},
+		{Target: "b", Url: closedURL(t), Weight: 1},
+		{Target: "c", Url: closedURL(t), Weight: 0},
+	}}
+	status := probe(model)
+	if !status.Healthy || len(status.Upstreams) != 2 {
+		t.Fatalf("应检查权重大于0的目标，任意一个可用即可用，实际%+v", status)
+	}
+	if !status.Upstreams[0].Healthy || status.Upstreams[1].Healthy {
+		t.Errorf("各目标的结果不正确: %+v", status.Upstreams)
+	}
+}
+
+func TestMonitorDown(t *testing.T) {
+	model := &config.ModelConfig{ID: "m", Target: "gpt-4o", Url: closedURL(t), HealthCheckInterval: 10}
+	monitor := NewMonitor(func() []*config.ModelConfig { return []*config.ModelConfig{model} }, time.Second)
+
+	if monitor.Down(model) {
+		t.Error("没有检查结果时不应认为不可用")
+	}
+	monitor.Check(context.Background(), model)
+	if !monitor.Down(model) {
+		t.Error("最近一次检查失败时应认为不可用")
+	}
+
+	disabled := *model
+	disabled.HealthCheckInterval = 0
+	if monitor.Down(&disabled) {
+		t.Error("关闭定期检查后不应再拒绝请求")
+	}
+
+	monitor.mutex.Lock()
+	status := monitor.status[model.ID]
+	status.CheckedAt = time.Now().Add(-time.Minute)
+	monitor.status[model.ID] = status
+	monitor.mutex.Unlock()
+	if monitor.Down(model) {
+		t.Error("检查结果过期后不应认为不可用")
+	}
+
+	monitor.Forget(model.ID)
+	if _, ok := monitor.Status(model.ID); ok {
+		t.Error("Forget后不应保留检查结果")
+	}
+}
+
+func TestMonitorBackgroundCheck(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	defer upstream.Close()
+
+	model := &config.ModelConfig{ID: "m", Target: "gpt-4o", Url: upstream.URL, HealthCheckInterval: 1}
+	monitor := NewMonitor(func() []*config.ModelConfig { return []*config.ModelConfig{model} }, time.Second)
+	monitor.Start()
+	defer monitor.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, ok := monitor.Status(model.ID); ok {
+			if !status.Healthy {
+				t.Errorf("期望上游可用，实际%+v", status)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("后台检查没有产生结果")
+}
+
+func TestMonitorTransitionHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	defer upstream.Close()
+	model := &config.ModelConfig{ID: "m", Target: "gpt-4o", Url: upstream.URL, HealthCheckInterval: 10}
+	monitor := NewMonitor(func() []*config.ModelConfig { return []*config.ModelConfig{model} }, time.Second)
+	var transitions []bool
+	monitor.SetTransitionHandler(func(status ModelStatus) {
+		transitions = append(transitions, status.Healthy)
+	})
+
+	monitor.Check(context.Background(), model) // 第一次检查可用，不算变化
+	monitor.Check(context.Background(), model)
+	down := *model
+	down.Url = closedURL(t)
+	monitor.Check(context.Background(), &down)
+	monitor.Check(context.Background(), &down)
+	monitor.Check(context.Background(), model)
+
+	if len(transitions) != 2 || transitions[0] || !transitions[1] {
+		t.Errorf("期望变为不可用和恢复各一次，实际%v", transitions)
+	}
+}
@@ -0,0 +1,141 @@
+package i18n
+
+// 通用错误码，用于没有更具体错误码的错误
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeForbidden          Code = "forbidden"
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodeInternalError      Code = "internal_error"
+	CodeServiceUnavailable Code = "service_unavailable"
+)
+
+// 请求参数错误码
+const (
+	CodeInvalidRequest         Code = "invalid_request"
+	CodeInvalidAPIKeyID        Code = "invalid_api_key_id"
+	CodeInvalidUserID          Code = "invalid_user_id"
+	CodeMissingModelID         Code = "missing_model_id"
+	CodeInvalidCascade         Code = "invalid_cascade"
+	CodeInvalidDays            Code = "invalid_days"
+	CodeInvalidTimeRange       Code = "invalid_time_range"
+	CodeInvalidExportFormat    Code = "invalid_export_format"
+	CodeInvalidExportLimit     Code = "invalid_export_limit"
+	CodeLogExportUnsupported   Code = "log_export_unsupported"
+	CodeMissingSearchQuery     Code = "missing_search_query"
+	CodeInvalidSearchLimit     Code = "invalid_search_limit"
+	CodeSearchWindowTooLarge   Code = "search_window_too_large"
+	CodeLogSearchUnsupported   Code = "log_search_unsupported"
+	CodeLogVerifyUnsupported   Code = "log_verify_unsupported"
+	CodeInvalidRecentQuery     Code = "invalid_recent_query"
+	CodeLogRecentUnsupported   Code = "log_recent_unsupported"
+	CodeRequestTooLarge        Code = "request_too_large"
+	CodeInvalidContentEncoding Code = "invalid_content_encoding"
+	CodeMethodNotAllowed       Code = "method_not_allowed"
+	CodeIdempotencyKeyConflict Code = "idempotency_key_conflict"
+	CodeAPINotFound            Code = "api_not_found"
+	CodeModelInvalid           Code = "model_invalid"
+	CodeCannotDeleteSelf       Code = "cannot_delete_self"
+	CodeCannotDisableSelf      Code = "cannot_disable_self"
+	CodeInvalidRole            Code = "invalid_role"
+	CodeInvalidWebhookID       Code = "invalid_webhook_id"
+	CodeWebhookInvalid         Code = "webhook_invalid"
+	CodeCatalogInvalid         Code = "catalog_invalid"
+	CodeInvalidTenant          Code = "invalid_tenant"
+)
+
+// 认证与权限错误码
+const (
+	CodeMissingToken             Code = "missing_token"
+	CodeMalformedToken           Code = "malformed_token"
+	CodeInvalidToken             Code = "invalid_token"
+	CodeUserContextMissing       Code = "user_context_missing"
+	CodeAdminRequired            Code = "admin_required"
+	CodeRoleNotAllowed           Code = "role_not_allowed"
+	CodeGlobalAdminRequired      Code = "global_admin_required"
+	CodeTenantForbidden          Code = "tenant_forbidden"
+	CodePromptOverrideAdminOnly  Code = "prompt_override_admin_only"
+	CodeInvalidCredentials       Code = "invalid_credentials"
+	CodeUserDisabled             Code = "user_disabled"
+	CodeUserLocked               Code = "user_locked"
+	CodeAlreadyInstalled         Code = "already_installed"
+	CodeWrongOldPassword         Code = "wrong_old_password"
+	CodePasswordChangeRequired   Code = "password_change_required"
+	CodeMissingAPIKey            Code = "missing_api_key"
+	CodeInvalidAPIKey            Code = "invalid_api_key"
+	CodeAPIKeyDisabled           Code = "api_key_disabled"
+	CodeAPIKeyExpired            Code = "api_key_expired"
+	CodeKeyNotAllowedForModel    Code = "key_not_allowed_for_model"
+	CodeModelTenantMismatch      Code = "model_tenant_mismatch"
+	CodePromptOverrideNotAllowed Code = "prompt_override_not_allowed"
+	CodeSessionRevoked           Code = "session_revoked"
+)
+
+// 资源错误码
+const (
+	CodeUserNotFound          Code = "user_not_found"
+	CodeUserExists            Code = "user_exists"
+	CodeUserHasAPIKeys        Code = "user_has_api_keys"
+	CodeAPIKeyNotFound        Code = "api_key_not_found"
+	CodeModelNotFound         Code = "model_not_found"
+	CodePromptVariantNotFound Code = "prompt_variant_not_found"
+	CodeModelExists           Code = "model_exists"
+	CodeModelNotInTrash       Code = "model_not_in_trash"
+	CodeModelIDInUse          Code = "model_id_in_use"
+	CodeModelIDMismatch       Code = "model_id_mismatch"
+	CodeModelDisabled         Code = "model_disabled"
+	CodeAuthUnavailable       Code = "auth_unavailable"
+	CodeConfigUnavailable     Code = "config_unavailable"
+	CodeLoggerNotFound        Code = "logger_not_found"
+	CodeLogFileNotFound       Code = "log_file_not_found"
+	CodeModelOverloaded       Code = "model_overloaded"
+	CodeDeadlineExceeded      Code = "deadline_exceeded"
+	CodeUpstreamDown          Code = "upstream_down"
+	CodeSessionNotFound       Code = "session_not_found"
+	CodeWebhookNotFound       Code = "webhook_not_found"
+	CodeTenantNotFound        Code = "tenant_not_found"
+	CodeTenantExists          Code = "tenant_exists"
+	CodeRequestTimeout        Code = "request_timeout"
+	CodeUnknownStage          Code = "unknown_stage"
+	CodeUpstreamURLMissing    Code = "upstream_url_missing"
+)
+
+// 操作失败错误码，信息中包含底层错误
+const (
+	CodeListModelsFailed          Code = "list_models_failed"
+	CodeModelUsageFailed          Code = "model_usage_failed"
+	CodeModelConvertFailed        Code = "model_convert_failed"
+	CodeSaveModelFailed           Code = "save_model_failed"
+	CodeDeleteModelFailed         Code = "delete_model_failed"
+	CodeBackupFailed              Code = "backup_failed"
+	CodeRestoreFailed             Code = "restore_failed"
+	CodeSeedModelsFailed          Code = "seed_models_failed"
+	CodeReloadFailed              Code = "reload_failed"
+	CodeDriftCheckFailed          Code = "drift_check_failed"
+	CodeSetMaintenanceFailed      Code = "set_maintenance_failed"
+	CodeUpdateTokenTTLFailed      Code = "update_token_ttl_failed"
+	CodeCheckInstallFailed        Code = "check_install_failed"
+	CodePublicKeyFailed           Code = "public_key_failed"
+	CodeListUsersFailed           Code = "list_users_failed"
+	CodeGetUserFailed             Code = "get_user_failed"
+	CodeListAPIKeysFailed         Code = "list_api_keys_failed"
+	CodeListExpiringAPIKeysFailed Code = "list_expiring_api_keys_failed"
+	CodeCreateAPIKeyFailed        Code = "create_api_key_failed"
+	CodeLogExportFailed           Code = "log_export_failed"
+	CodeListSessionsFailed        Code = "list_sessions_failed"
+	CodeListWebhooksFailed        Code = "list_webhooks_failed"
+	CodeSaveWebhookFailed         Code = "save_webhook_failed"
+	CodeWebhookTestFailed         Code = "webhook_test_failed"
+	CodeListLogFilesFailed        Code = "list_log_files_failed"
+	CodeLogSearchFailed           Code = "log_search_failed"
+	CodeLogVerifyFailed           Code = "log_verify_failed"
+	CodeListTrashFailed           Code = "list_trash_failed"
+	CodeRestoreModelFailed        Code = "restore_model_failed"
+	CodePurgeModelFailed          Code = "purge_model_failed"
+	CodeListTenantsFailed         Code = "list_tenants_failed"
+	CodeInjectPromptFailed        Code = "inject_prompt_failed"
+	CodeRewriteModelFailed        Code = "rewrite_model_failed"
+	CodeUpstreamURLFailed         Code = "upstream_url_failed"
+	CodeForwardFailed             Code = "forward_failed"
+)
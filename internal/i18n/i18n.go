@@ -0,0 +1,95 @@
+// Package i18n 管理API和代理错误响应的错误码及其多语言信息
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lang 响应信息使用的语言
+type Lang string
+
+// 支持的语言
+const (
+	ZH Lang = "zh"
+	EN Lang = "en"
+
+	// Default 请求未指定或指定了不支持的语言时使用的语言
+	Default = ZH
+)
+
+// Code 稳定的错误码，供客户端判断错误类型，不随语言变化
+type Code string
+
+// catalogs 各语言的错误信息
+var catalogs = map[Lang]map[Code]string{
+	ZH: zhMessages,
+	EN: enMessages,
+}
+
+// Negotiate 根据Accept-Language请求头选择语言，按q值从高到低匹配支持的语言，
+// 没有匹配时返回默认语言
+func Negotiate(acceptLanguage string) Lang {
+	type candidate struct {
+		lang    Lang
+		quality float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		primary, _, _ := strings.Cut(tag, "-")
+		if _, ok := catalogs[Lang(primary)]; ok && quality > 0 {
+			candidates = append(candidates, candidate{lang: Lang(primary), quality: quality})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].lang
+}
+
+// Message 返回错误码在指定语言中的信息，缺少该语言的信息时使用默认语言，都没有时返回错误码本身。
+// details为与语言无关的细节（如模型ID、底层错误），以": "附加在信息之后
+func Message(lang Lang, code Code, details ...interface{}) string {
+	message, ok := catalogs[lang][code]
+	if !ok {
+		if message, ok = catalogs[Default][code]; !ok {
+			message = string(code)
+		}
+	}
+	for _, detail := range details {
+		if text := fmt.Sprint(detail); text != "" {
+			message += ": " + text
+		}
+	}
+	return message
+}
+
+// FromContext 根据请求的Accept-Language请求头选择语言
+func FromContext(c *gin.Context) Lang {
+	return Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// T 返回错误码按当前请求语言本地化的信息，管理API和代理共用
+func T(c *gin.Context, code Code, details ...interface{}) string {
+	return Message(FromContext(c), code, details...)
+}
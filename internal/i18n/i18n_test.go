@@ -0,0 +1,57 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Lang
+	}{
+		{"", ZH},
+		{"en", EN},
+		{"en-US,en;q=0.9", EN},
+		{"zh-CN,zh;q=0.9,en;q=0.8", ZH},
+		{"fr-FR,en;q=0.5", EN},
+		{"zh;q=0.3,en;q=0.7", EN},
+		{"en;q=0", ZH},
+		{"fr, de", ZH},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	if got := Message(ZH, CodeModelNotFound, "gpt-x"); got != "模型配置未找到: gpt-x" {
+		t.Errorf("中文信息 = %q", got)
+	}
+	if got := Message(EN, CodeModelNotFound, "gpt-x"); got != "Model configuration not found: gpt-x" {
+		t.Errorf("英文信息 = %q", got)
+	}
+	if got := Message(EN, CodeUserExists, ""); got != "Username already exists" {
+		t.Errorf("空细节不应附加分隔符，实际为%q", got)
+	}
+	if got := Message(Lang("fr"), CodeUserExists); got != "用户名已存在" {
+		t.Errorf("不支持的语言应使用默认语言，实际为%q", got)
+	}
+	if got := Message(EN, Code("unknown_code")); got != "unknown_code" {
+		t.Errorf("未知错误码应返回错误码本身，实际为%q", got)
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for code := range catalogs[Default] {
+			if catalog[code] == "" {
+				t.Errorf("%s缺少错误码%s的信息", lang, code)
+			}
+		}
+		for code := range catalog {
+			if _, ok := catalogs[Default][code]; !ok {
+				t.Errorf("%s的错误码%s在默认语言中不存在", lang, code)
+			}
+		}
+	}
+}
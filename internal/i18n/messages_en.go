@@ -0,0 +1,125 @@
+package i18n
+
+// enMessages 英文错误信息
+var enMessages = map[Code]string{
+	CodeBadRequest:                "Bad request",
+	CodeUnauthorized:              "Unauthorized",
+	CodeForbidden:                 "Forbidden",
+	CodeNotFound:                  "Not found",
+	CodeConflict:                  "Conflict",
+	CodeInternalError:             "Internal server error",
+	CodeServiceUnavailable:        "Service unavailable",
+	CodeInvalidRequest:            "Invalid request",
+	CodeInvalidAPIKeyID:           "Invalid API key ID",
+	CodeInvalidUserID:             "Invalid user ID",
+	CodeMissingModelID:            "Invalid request: model ID is required",
+	CodeInvalidCascade:            "Invalid cascade parameter",
+	CodeInvalidDays:               "Invalid days, must be an integer between 1 and 365",
+	CodeInvalidTimeRange:          "Invalid time range, from and to must be RFC3339 times or YYYY-MM-DD dates with from before to",
+	CodeInvalidExportFormat:       "Invalid export format, must be jsonl or csv",
+	CodeInvalidExportLimit:        "Invalid export limit, must be a positive integer",
+	CodeLogExportUnsupported:      "This log does not support export",
+	CodeMissingSearchQuery:        "Missing search query q",
+	CodeInvalidSearchLimit:        "Invalid search limit, must be a positive integer",
+	CodeSearchWindowTooLarge:      "Search time range is too large, at most 7 days",
+	CodeLogSearchUnsupported:      "This log does not support search",
+	CodeLogVerifyUnsupported:      "This log does not have hash chain verification",
+	CodeInvalidRecentQuery:        "limit and after must be non-negative integers",
+	CodeLogRecentUnsupported:      "This log does not keep recent records in memory",
+	CodeRequestTooLarge:           "Request body too large, maximum allowed bytes",
+	CodeInvalidContentEncoding:    "Failed to decompress request body",
+	CodeMethodNotAllowed:          "Request method is not allowed",
+	CodeIdempotencyKeyConflict:    "Idempotency-Key was already used for a different request",
+	CodeAPINotFound:               "API endpoint not found",
+	CodeModelInvalid:              "Model configuration is invalid",
+	CodeCannotDeleteSelf:          "You cannot delete your own account",
+	CodeCannotDisableSelf:         "You cannot disable your own account",
+	CodeInvalidRole:               "Unsupported user role",
+	CodeInvalidWebhookID:          "Invalid webhook ID",
+	CodeWebhookInvalid:            "Webhook configuration is invalid",
+	CodeCatalogInvalid:            "Model catalog is invalid",
+	CodeInvalidTenant:             "Invalid tenant ID",
+	CodeMissingToken:              "Authentication token is missing",
+	CodeMalformedToken:            "Authentication token is malformed",
+	CodeInvalidToken:              "Authentication token is invalid",
+	CodeUserContextMissing:        "User information is missing",
+	CodeAdminRequired:             "Administrator privileges are required",
+	CodeRoleNotAllowed:            "Your role is not allowed to perform this operation",
+	CodeGlobalAdminRequired:       "Global administrator privileges are required",
+	CodeTenantForbidden:           "Only global administrators can manage resources of other tenants",
+	CodePromptOverrideAdminOnly:   "Administrator privileges are required to change allow_prompt_override",
+	CodeInvalidCredentials:        "Invalid username or password",
+	CodeUserDisabled:              "User is disabled",
+	CodeUserLocked:                "Account is locked after too many failed logins, locked until",
+	CodeAlreadyInstalled:          "The system is already initialized, registration is closed",
+	CodeWrongOldPassword:          "Old password is incorrect",
+	CodePasswordChangeRequired:    "Please change your temporary password first",
+	CodeMissingAPIKey:             "Missing credentials, add an X-Proxy-Key request header",
+	CodeInvalidAPIKey:             "Invalid API key",
+	CodeAPIKeyDisabled:            "API key is disabled",
+	CodeAPIKeyExpired:             "API key has expired",
+	CodeKeyNotAllowedForModel:     "API key is not allowed to use model",
+	CodeModelTenantMismatch:       "API key cannot use models of another tenant",
+	CodePromptOverrideNotAllowed:  "API key is not allowed to override the prompt with X-Proxy-Skip-Prompt or X-Proxy-Extra-Prompt",
+	CodeSessionRevoked:            "Session has been revoked, please log in again",
+	CodeUserNotFound:              "User not found",
+	CodeUserExists:                "Username already exists",
+	CodeUserHasAPIKeys:            "User still owns API keys, transfer them first or delete with cascade=true. API key count",
+	CodeAPIKeyNotFound:            "API key not found or not accessible",
+	CodeModelNotFound:             "Model configuration not found",
+	CodePromptVariantNotFound:     "Prompt experiment variant not found",
+	CodeModelExists:               "Model already exists",
+	CodeModelNotInTrash:           "Model is not in the trash",
+	CodeModelIDInUse:              "Model ID is already used by another model",
+	CodeModelIDMismatch:           "Model ID in the request body does not match the path",
+	CodeModelDisabled:             "Model is disabled",
+	CodeAuthUnavailable:           "Authentication service unavailable",
+	CodeConfigUnavailable:         "Configuration service unavailable",
+	CodeLoggerNotFound:            "Logger not found",
+	CodeLogFileNotFound:           "Log file not found",
+	CodeModelOverloaded:           "Model concurrency limit reached",
+	CodeDeadlineExceeded:          "Client timeout budget exceeded",
+	CodeUpstreamDown:              "Upstream failed its last health check",
+	CodeSessionNotFound:           "Session not found",
+	CodeWebhookNotFound:           "Webhook not found",
+	CodeTenantNotFound:            "Tenant not found",
+	CodeTenantExists:              "Tenant already exists",
+	CodeRequestTimeout:            "Request timed out",
+	CodeUnknownStage:              "Unknown pipeline stage",
+	CodeUpstreamURLMissing:        "No upstream URL was generated; the pipeline is missing the rewrite stage",
+	CodeListModelsFailed:          "Failed to list models",
+	CodeModelUsageFailed:          "Failed to load model usage",
+	CodeModelConvertFailed:        "Failed to convert model data",
+	CodeSaveModelFailed:           "Failed to save model configuration",
+	CodeDeleteModelFailed:         "Failed to delete model configuration",
+	CodeBackupFailed:              "Failed to back up configuration",
+	CodeRestoreFailed:             "Failed to restore configuration",
+	CodeSeedModelsFailed:          "Failed to seed models",
+	CodeReloadFailed:              "Failed to reload configuration",
+	CodeDriftCheckFailed:          "Failed to compare YAML and database model configuration",
+	CodeSetMaintenanceFailed:      "Failed to set maintenance mode",
+	CodeUpdateTokenTTLFailed:      "Failed to update token lifetime",
+	CodeCheckInstallFailed:        "Failed to check installation status",
+	CodePublicKeyFailed:           "Failed to get public key",
+	CodeListUsersFailed:           "Failed to list users",
+	CodeGetUserFailed:             "Failed to get user information",
+	CodeListAPIKeysFailed:         "Failed to list API keys",
+	CodeListExpiringAPIKeysFailed: "Failed to list expiring API keys",
+	CodeCreateAPIKeyFailed:        "Failed to create API key",
+	CodeLogExportFailed:           "Failed to export logs",
+	CodeListSessionsFailed:        "Failed to list sessions",
+	CodeListWebhooksFailed:        "Failed to list webhooks",
+	CodeSaveWebhookFailed:         "Failed to save webhook",
+	CodeWebhookTestFailed:         "Failed to deliver test alert",
+	CodeListLogFilesFailed:        "Failed to list log files",
+	CodeLogSearchFailed:           "Failed to search logs",
+	CodeLogVerifyFailed:           "Failed to verify the log file",
+	CodeListTrashFailed:           "Failed to list the model trash",
+	CodeRestoreModelFailed:        "Failed to restore model",
+	CodePurgeModelFailed:          "Failed to permanently delete model",
+	CodeListTenantsFailed:         "Failed to list tenants",
+	CodeInjectPromptFailed:        "Failed to inject prompt",
+	CodeRewriteModelFailed:        "Failed to replace model ID",
+	CodeUpstreamURLFailed:         "Failed to build upstream URL",
+	CodeForwardFailed:             "Failed to forward request",
+}
@@ -0,0 +1,125 @@
+package i18n
+
+// zhMessages 中文错误信息，也是默认语言的信息
+var zhMessages = map[Code]string{
+	CodeBadRequest:                "请求错误",
+	CodeUnauthorized:              "未认证",
+	CodeForbidden:                 "没有权限",
+	CodeNotFound:                  "资源不存在",
+	CodeConflict:                  "资源冲突",
+	CodeInternalError:             "服务器内部错误",
+	CodeServiceUnavailable:        "服务不可用",
+	CodeInvalidRequest:            "请求参数错误",
+	CodeInvalidAPIKeyID:           "无效的API Key ID",
+	CodeInvalidUserID:             "用户ID格式错误",
+	CodeMissingModelID:            "请求参数错误: 缺少模型ID",
+	CodeInvalidCascade:            "cascade参数无效",
+	CodeInvalidDays:               "无效的天数，应为1到365之间的整数",
+	CodeInvalidTimeRange:          "时间范围无效，from和to应为RFC3339时间或YYYY-MM-DD日期且from早于to",
+	CodeInvalidExportFormat:       "导出格式无效，应为jsonl或csv",
+	CodeInvalidExportLimit:        "导出行数上限无效，应为正整数",
+	CodeLogExportUnsupported:      "该日志不支持导出",
+	CodeMissingSearchQuery:        "缺少搜索内容q",
+	CodeInvalidSearchLimit:        "搜索结果数上限无效，应为正整数",
+	CodeSearchWindowTooLarge:      "搜索的时间范围过大，最多7天",
+	CodeLogSearchUnsupported:      "该日志不支持搜索",
+	CodeLogVerifyUnsupported:      "该日志未开启哈希链校验",
+	CodeInvalidRecentQuery:        "limit和after必须是非负整数",
+	CodeLogRecentUnsupported:      "该日志不在内存中保留最近的记录",
+	CodeRequestTooLarge:           "请求体过大，最大允许的字节数",
+	CodeInvalidContentEncoding:    "请求体解压失败",
+	CodeMethodNotAllowed:          "不允许的请求方法",
+	CodeIdempotencyKeyConflict:    "Idempotency-Key已用于内容不同的请求",
+	CodeAPINotFound:               "API接口不存在",
+	CodeModelInvalid:              "模型配置验证失败",
+	CodeCannotDeleteSelf:          "不能删除自己",
+	CodeCannotDisableSelf:         "不能禁用自己",
+	CodeInvalidRole:               "不支持的用户角色",
+	CodeInvalidWebhookID:          "Webhook ID格式错误",
+	CodeWebhookInvalid:            "Webhook配置无效",
+	CodeCatalogInvalid:            "模型目录无效",
+	CodeInvalidTenant:             "无效的租户ID",
+	CodeMissingToken:              "未提供认证token",
+	CodeMalformedToken:            "认证token格式错误",
+	CodeInvalidToken:              "认证token无效",
+	CodeUserContextMissing:        "用户信息不存在",
+	CodeAdminRequired:             "需要管理员权限",
+	CodeRoleNotAllowed:            "当前角色无权执行此操作",
+	CodeGlobalAdminRequired:       "需要全局管理员权限",
+	CodeTenantForbidden:           "只有全局管理员可以管理其他租户的资源",
+	CodePromptOverrideAdminOnly:   "需要管理员权限才能修改allow_prompt_override",
+	CodeInvalidCredentials:        "用户名或密码错误",
+	CodeUserDisabled:              "用户已被禁用",
+	CodeUserLocked:                "连续登录失败次数过多，账户已锁定，解锁时间",
+	CodeAlreadyInstalled:          "系统已初始化，不允许注册新用户",
+	CodeWrongOldPassword:          "旧密码错误",
+	CodePasswordChangeRequired:    "请先修改临时密码",
+	CodeMissingAPIKey:             "缺少认证信息，请在请求头中添加X-Proxy-Key",
+	CodeInvalidAPIKey:             "无效的API Key",
+	CodeAPIKeyDisabled:            "API Key已被禁用",
+	CodeAPIKeyExpired:             "API Key已过期",
+	CodeKeyNotAllowedForModel:     "API Key无权调用模型",
+	CodeModelTenantMismatch:       "API Key不能调用其他租户的模型",
+	CodePromptOverrideNotAllowed:  "API Key不允许使用X-Proxy-Skip-Prompt或X-Proxy-Extra-Prompt覆盖Prompt",
+	CodeSessionRevoked:            "会话已被撤销，请重新登录",
+	CodeUserNotFound:              "用户不存在",
+	CodeUserExists:                "用户名已存在",
+	CodeUserHasAPIKeys:            "用户仍有API Key，请先转移或使用cascade=true同时删除，API Key数量",
+	CodeAPIKeyNotFound:            "API Key不存在或无权限操作",
+	CodeModelNotFound:             "模型配置未找到",
+	CodePromptVariantNotFound:     "Prompt实验变体不存在",
+	CodeModelExists:               "模型已存在",
+	CodeModelNotInTrash:           "回收站中没有该模型",
+	CodeModelIDInUse:              "模型ID已被其他模型使用",
+	CodeModelIDMismatch:           "请求体中的模型ID与路径不一致",
+	CodeModelDisabled:             "模型已禁用",
+	CodeAuthUnavailable:           "认证服务不可用",
+	CodeConfigUnavailable:         "配置服务不可用",
+	CodeLoggerNotFound:            "日志记录器不存在",
+	CodeLogFileNotFound:           "日志文件不存在",
+	CodeModelOverloaded:           "模型并发受限",
+	CodeDeadlineExceeded:          "超过客户端设置的超时预算",
+	CodeUpstreamDown:              "上游服务最近一次健康检查失败",
+	CodeSessionNotFound:           "会话不存在",
+	CodeWebhookNotFound:           "Webhook不存在",
+	CodeTenantNotFound:            "租户不存在",
+	CodeTenantExists:              "租户已存在",
+	CodeRequestTimeout:            "请求超时",
+	CodeUnknownStage:              "未知的处理阶段",
+	CodeUpstreamURLMissing:        "未生成上游URL，pipeline中缺少rewrite阶段",
+	CodeListModelsFailed:          "获取模型列表失败",
+	CodeModelUsageFailed:          "获取模型调用统计失败",
+	CodeModelConvertFailed:        "模型数据转换失败",
+	CodeSaveModelFailed:           "保存模型配置失败",
+	CodeDeleteModelFailed:         "删除模型配置失败",
+	CodeBackupFailed:              "备份配置失败",
+	CodeRestoreFailed:             "恢复配置失败",
+	CodeSeedModelsFailed:          "导入模型目录失败",
+	CodeReloadFailed:              "重新加载配置失败",
+	CodeDriftCheckFailed:          "比较YAML文件与数据库中的模型配置失败",
+	CodeSetMaintenanceFailed:      "设置维护模式失败",
+	CodeUpdateTokenTTLFailed:      "修改token有效期失败",
+	CodeCheckInstallFailed:        "检查安装状态失败",
+	CodePublicKeyFailed:           "获取公钥失败",
+	CodeListUsersFailed:           "获取用户列表失败",
+	CodeGetUserFailed:             "获取用户信息失败",
+	CodeListAPIKeysFailed:         "获取API Key列表失败",
+	CodeListExpiringAPIKeysFailed: "获取即将过期的API Key失败",
+	CodeCreateAPIKeyFailed:        "创建API Key失败",
+	CodeLogExportFailed:           "导出日志失败",
+	CodeListSessionsFailed:        "获取会话列表失败",
+	CodeListWebhooksFailed:        "获取Webhook列表失败",
+	CodeSaveWebhookFailed:         "保存Webhook失败",
+	CodeWebhookTestFailed:         "发送测试告警失败",
+	CodeListLogFilesFailed:        "获取日志文件列表失败",
+	CodeLogSearchFailed:           "搜索日志失败",
+	CodeLogVerifyFailed:           "校验日志文件失败",
+	CodeListTrashFailed:           "获取模型回收站失败",
+	CodeRestoreModelFailed:        "恢复模型配置失败",
+	CodePurgeModelFailed:          "永久删除模型配置失败",
+	CodeListTenantsFailed:         "获取租户列表失败",
+	CodeInjectPromptFailed:        "注入Prompt失败",
+	CodeRewriteModelFailed:        "替换模型ID失败",
+	CodeUpstreamURLFailed:         "生成上游URL失败",
+	CodeForwardFailed:             "转发请求失败",
+}
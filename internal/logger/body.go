@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"sync"
+	"unicode/utf8"
+)
+
+// BodyPolicy 日志记录器记录请求/响应体的策略
+type BodyPolicy struct {
+	MaxBytes     int      // 每个body最多记录的字节数，0表示不限制
+	SampleRate   float64  // 记录body的请求比例(0.0-1.0)，出错的请求总是记录
+	ExcludePaths []string // 不记录body的URL路径，支持path.Match通配符
+	OnErrorOnly  bool     // 只记录出错请求的body，成功请求的body由格式化器替换为大小标记
+}
+
+// BodyPolicy 获取输出器配置中的body记录策略，未配置采样率时记录全部请求
+func (c OutputConfig) BodyPolicy() BodyPolicy {
+	policy := BodyPolicy{
+		MaxBytes:     c.MaxBodyBytes,
+		SampleRate:   1,
+		ExcludePaths: c.ExcludePaths,
+		OnErrorOnly:  c.BodyOnErrorOnly,
+	}
+	if c.BodySampleRate != nil {
+		policy.SampleRate = *c.BodySampleRate
+	}
+	return policy
+}
+
+// Excluded 该路径是否不记录body
+func (p BodyPolicy) Excluded(urlPath string) bool {
+	for _, pattern := range p.ExcludePaths {
+		if matched, _ := path.Match(pattern, urlPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// TruncateBody 将body截断到max字节（不拆分UTF-8字符和JSON转义序列），total为body的实际大小，
+// 被截断或body本身不完整时在末尾添加标记
+func TruncateBody(body string, max int, total int64) string {
+	if max > 0 && len(body) > max {
+		cut := max
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		body = body[:jsonEscapeStart(body, cut)]
+	}
+	if total > int64(len(body)) {
+		return fmt.Sprintf("%s...[truncated %d bytes]", body, total-int64(len(body)))
+	}
+	return body
+}
+
+// jsonEscapeStart 截断位置cut落在JSON转义序列（如\n、\u4f60）中间时返回该转义序列的起始位置，否则返回cut，
+// 使截断后的JSON前缀不以不完整的转义结尾
+func jsonEscapeStart(body string, cut int) int {
+	for i := cut - 1; i >= 0 && i >= cut-6; i-- {
+		if body[i] != '\\' {
+			continue
+		}
+		// 前面有奇数个反斜杠时这个反斜杠是上一个转义序列的第二个字符
+		escaped := 0
+		for j := i - 1; j >= 0 && body[j] == '\\'; j-- {
+			escaped++
+		}
+		if escaped%2 == 1 {
+			continue
+		}
+		length := 2
+		if i+1 < len(body) && body[i+1] == 'u' {
+			length = 6
+		}
+		if i+length > cut {
+			return i
+		}
+		return cut
+	}
+	return cut
+}
+
+// bodyFilter 按日志记录器的body策略裁剪日志数据
+type bodyFilter struct {
+	policy BodyPolicy
+	rng    *rand.Rand
+	mutex  sync.Mutex
+}
+
+// newBodyFilter 创建body过滤器，seed用于采样的随机数生成器
+func newBodyFilter(policy BodyPolicy, seed int64) *bodyFilter {
+	return &bodyFilter{
+		policy: policy,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// NewBodySample 为一次请求抽取(0,1]之间的body采样值。同一请求只抽取一次，保存响应体和各记录器
+// 写入日志时都按该值判断：采样值不大于记录器的body_sample_rate时记录body
+func NewBodySample() float64 {
+	return 1 - rand.Float64()
+}
+
+// sampledBy 采样值sample是否被采样率rate选中
+func sampledBy(sample, rate float64) bool {
+	return sample <= rate
+}
+
+// sampled 判断本次请求是否采样记录body，sample为请求开始时抽取的采样值，为0时由记录器自行抽取
+func (f *bodyFilter) sampled(sample float64) bool {
+	if f.policy.SampleRate >= 1 {
+		return true
+	}
+	if f.policy.SampleRate <= 0 {
+		return false
+	}
+	if sample > 0 {
+		return sampledBy(sample, f.policy.SampleRate)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.rng.Float64() < f.policy.SampleRate
+}
+
+// apply 根据策略清空或截断日志数据中的body
+func (f *bodyFilter) apply(data *RequestLogData) {
+	failed := data.Failed()
+	if f.policy.Excluded(data.Path) || (!failed && !f.policy.OnErrorOnly && !f.sampled(data.BodySample)) {
+		data.RequestBody = ""
+		data.UpstreamBody = ""
+		data.ResponseBody = ""
+		return
+	}
+	if !failed && f.policy.OnErrorOnly {
+		// 保留完整的body，格式化时只记录其大小
+		return
+	}
+
+	data.RequestBody = TruncateBody(data.RequestBody, f.policy.MaxBytes, int64(len(data.RequestBody)))
+	data.UpstreamBody = TruncateBody(data.UpstreamBody, f.policy.MaxBytes, int64(len(data.UpstreamBody)))
+	data.ResponseBody = TruncateBody(data.ResponseBody, f.policy.MaxBytes, int64(len(data.ResponseBody))+data.ResponseBodyDropped)
+}
+
+// BodyCaptureLimit 根据所有启用的日志记录器计算该路径需要在内存中保存的body字节数：
+// capture为false表示没有记录器会记录该路径的body，limit为0表示不限制。
+// sample为请求的body采样值（见NewBodySample），未被采样的记录器不参与计算，为0时视为全部采样
+func (m *LoggerManager) BodyCaptureLimit(urlPath string, sample float64) (limit int, capture bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, logger := range m.loggers {
+		if !logger.IsEnabled() {
+			continue
+		}
+		policy := logger.bodies.policy
+		if policy.Excluded(urlPath) {
+			continue
+		}
+		if sample > 0 && !policy.OnErrorOnly && !sampledBy(sample, policy.SampleRate) {
+			continue
+		}
+		if !capture || policy.MaxBytes == 0 || (limit != 0 && policy.MaxBytes > limit) {
+			limit = policy.MaxBytes
+		}
+		capture = true
+	}
+	return limit, capture
+}
+
+// BodyExcluded 是否所有启用的日志记录器都不记录该路径的body，没有启用的记录器时返回false
+func (m *LoggerManager) BodyExcluded(urlPath string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	excluded := false
+	for _, logger := range m.loggers {
+		if !logger.IsEnabled() {
+			continue
+		}
+		if !logger.bodies.policy.Excluded(urlPath) {
+			return false
+		}
+		excluded = true
+	}
+	return excluded
+}
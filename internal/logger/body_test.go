@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateBody(t *testing.T) {
+	if got := TruncateBody("hello world", 5, 11); got != "hello...[truncated 6 bytes]" {
+		t.Errorf("截断结果不正确: %q", got)
+	}
+	if got := TruncateBody("hello", 10, 5); got != "hello" {
+		t.Errorf("未超过上限时不应截断: %q", got)
+	}
+	// 代理保存时已丢弃的部分也计入标记
+	if got := TruncateBody("hello", 0, 8); got != "hello...[truncated 3 bytes]" {
+		t.Errorf("应标记保存时丢弃的字节数: %q", got)
+	}
+	// 不拆分多字节字符
+	if got := TruncateBody("你好世界", 4, 12); got != "你...[truncated 9 bytes]" {
+		t.Errorf("截断不应拆分UTF-8字符: %q", got)
+	}
+}
+
+func TestTruncateBodyKeepsJSONEscapes(t *testing.T) {
+	tests := []struct {
+		body string
+		max  int
+		want string
+	}{
+		{`{"a":"x\ny"}`, 8, `{"a":"x...[truncated 5 bytes]`},
+		{`{"a":"\u4f60"}`, 9, `{"a":"...[truncated 8 bytes]`},
+		{`{"a":"\u4f60"}`, 12, `{"a":"\u4f60...[truncated 2 bytes]`},
+		{`{"a":"\\\\b"}`, 8, `{"a":"\\...[truncated 5 bytes]`},
+		{`{"a":"\\\\b"}`, 7, `{"a":"...[truncated 7 bytes]`},
+	}
+	for _, tt := range tests {
+		if got := TruncateBody(tt.body, tt.max, int64(len(tt.body))); got != tt.want {
+			t.Errorf("TruncateBody(%s, %d) = %s, want %s", tt.body, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestBodyFilterMaxBytes(t *testing.T) {
+	filter := newBodyFilter(BodyPolicy{MaxBytes: 4, SampleRate: 1}, 1)
+	data := &RequestLogData{
+		Path:                "/v1/chat/completions",
+		RequestBody:         "request",
+		UpstreamBody:        "upstream",
+		ResponseBody:        "resp",
+		ResponseBodyDropped: 10,
+	}
+	filter.apply(data)
+
+	if data.RequestBody != "requ...[truncated 3 bytes]" {
+		t.Errorf("请求体截断不正确: %q", data.RequestBody)
+	}
+	if data.UpstreamBody != "upst...[truncated 4 bytes]" {
+		t.Errorf("上游请求体截断不正确: %q", data.UpstreamBody)
+	}
+	if data.ResponseBody != "resp...[truncated 10 bytes]" {
+		t.Errorf("响应体截断不正确: %q", data.ResponseBody)
+	}
+}
+
+func TestBodyOnErrorOnly(t *testing.T) {
+	policy := OutputConfig{MaxBodyBytes: 4, BodyOnErrorOnly: true}.BodyPolicy()
+	formatterConfig := FormatterConfig{
+		Fields:          map[string][]string{"fields": {"$status_code", "$request_body", "$upstream_body", "$response_body"}},
+		BodyOnErrorOnly: true,
+	}
+	format := func(formatter Formatter, data RequestLogData) string {
+		newBodyFilter(policy, 1).apply(&data)
+		formatted, err := formatter.Format(&data)
+		if err != nil {
+			t.Fatalf("格式化失败: %v", err)
+		}
+		return strings.TrimSpace(string(formatted))
+	}
+	success := RequestLogData{StatusCode: 200, RequestBody: "request", ResponseBody: "resp", ResponseBodyDropped: 10}
+	failure := success
+	failure.StatusCode = 502
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		data      RequestLogData
+		want      string
+	}{
+		{"JSON成功请求", NewJSONFormatter(formatterConfig), success,
+			`{"request_body":"[omitted 7 bytes]","response_body":"[omitted 14 bytes]","status_code":200,"upstream_body":""}`},
+		{"JSON失败请求", NewJSONFormatter(formatterConfig), failure,
+			`{"request_body":"requ...[truncated 3 bytes]","response_body":"resp...[truncated 10 bytes]","status_code":502,"upstream_body":""}`},
+		{"Line成功请求", NewLineFormatter(formatterConfig), success, "200\t[omitted 7 bytes]\t\t[omitted 14 bytes]"},
+		{"Line出错请求", NewLineFormatter(formatterConfig), RequestLogData{StatusCode: 200, Error: "timeout", RequestBody: "req"}, "200\treq\t\t"},
+	}
+	for _, tt := range tests {
+		if got := format(tt.formatter, tt.data); got != strings.TrimSpace(tt.want) {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// sampledCount 统计n次请求中保留body的次数
+func sampledCount(filter *bodyFilter, n int, data RequestLogData) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		d := data
+		filter.apply(&d)
+		if d.ResponseBody != "" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestBodyFilterSamplingDeterministic(t *testing.T) {
+	policy := BodyPolicy{SampleRate: 0.25}
+	data := RequestLogData{Path: "/v1/chat/completions", StatusCode: 200, ResponseBody: "ok"}
+
+	first := sampledCount(newBodyFilter(policy, 42), 1000, data)
+	second := sampledCount(newBodyFilter(policy, 42), 1000, data)
+	if first != second {
+		t.Fatalf("相同种子的采样结果应一致: %d != %d", first, second)
+	}
+	if first < 200 || first > 300 {
+		t.Errorf("采样比例偏离过大，1000次中保留了%d次", first)
+	}
+}
+
+func TestBodyFilterUsesRequestSample(t *testing.T) {
+	filter := newBodyFilter(BodyPolicy{SampleRate: 0.25}, 1)
+	data := RequestLogData{Path: "/v1/chat/completions", StatusCode: 200, ResponseBody: "ok"}
+
+	// 请求已抽取采样值时按该值判断，结果与保存响应体时一致
+	data.BodySample = 0.2
+	if sampledCount(filter, 10, data) != 10 {
+		t.Error("采样值不大于采样率时应记录body")
+	}
+	data.BodySample = 0.3
+	if sampledCount(filter, 10, data) != 0 {
+		t.Error("采样值大于采样率时不应记录body")
+	}
+}
+
+func TestBodyFilterAlwaysKeepsErrors(t *testing.T) {
+	filter := newBodyFilter(BodyPolicy{SampleRate: 0}, 1)
+
+	ok := RequestLogData{StatusCode: 200, ResponseBody: "ok"}
+	if sampledCount(filter, 10, ok) != 0 {
+		t.Error("采样率为0时成功请求不应记录body")
+	}
+	for _, data := range []RequestLogData{
+		{StatusCode: 502, ResponseBody: "bad gateway"},
+		{StatusCode: 200, Error: "client disconnected", ResponseBody: "partial"},
+	} {
+		if sampledCount(filter, 10, data) != 10 {
+			t.Errorf("出错的请求应总是记录body: %+v", data)
+		}
+	}
+}
+
+func TestBodyFilterExcludePaths(t *testing.T) {
+	filter := newBodyFilter(BodyPolicy{SampleRate: 1, ExcludePaths: []string{"/v1/audio/*"}}, 1)
+
+	data := RequestLogData{Path: "/v1/audio/transcriptions", StatusCode: 500, RequestBody: "binary", ResponseBody: "error"}
+	filter.apply(&data)
+	if data.RequestBody != "" || data.ResponseBody != "" {
+		t.Errorf("排除路径即使出错也不应记录body: %+v", data)
+	}
+
+	data = RequestLogData{Path: "/v1/chat/completions", ResponseBody: "ok"}
+	filter.apply(&data)
+	if data.ResponseBody != "ok" {
+		t.Errorf("未排除的路径应记录body: %q", data.ResponseBody)
+	}
+}
+
+func TestBodyCaptureLimit(t *testing.T) {
+	rate := 0.1
+	m := NewLoggerManager()
+	for _, cfg := range []OutputConfig{
+		{Name: "small", Driver: DriverStdout, Type: FormatterJSON, MaxBodyBytes: 100},
+		{Name: "large", Driver: DriverStdout, Type: FormatterJSON, MaxBodyBytes: 1000, BodySampleRate: &rate, ExcludePaths: []string{"/v1/embeddings"}},
+	} {
+		if err := m.AddLogger(cfg.Name, cfg); err != nil {
+			t.Fatalf("添加日志记录器失败: %v", err)
+		}
+	}
+	defer m.Close()
+
+	if limit, capture := m.BodyCaptureLimit("/v1/chat/completions", 0); !capture || limit != 1000 {
+		t.Errorf("应取所有记录器中的最大上限，实际%d/%v", limit, capture)
+	}
+	if limit, capture := m.BodyCaptureLimit("/v1/embeddings", 0); !capture || limit != 100 {
+		t.Errorf("排除该路径的记录器不参与计算，实际%d/%v", limit, capture)
+	}
+
+	// 按请求的采样值只计算采样到的记录器
+	if limit, capture := m.BodyCaptureLimit("/v1/chat/completions", 0.5); !capture || limit != 100 {
+		t.Errorf("未采样到的记录器不参与计算，实际%d/%v", limit, capture)
+	}
+	if limit, capture := m.BodyCaptureLimit("/v1/chat/completions", 0.05); !capture || limit != 1000 {
+		t.Errorf("采样到的记录器应参与计算，实际%d/%v", limit, capture)
+	}
+
+	m.loggers["small"].bodies.policy.ExcludePaths = []string{"/v1/embeddings"}
+	if _, capture := m.BodyCaptureLimit("/v1/embeddings", 0); capture {
+		t.Error("所有记录器都排除的路径不应保存body")
+	}
+	if !m.BodyExcluded("/v1/embeddings") || m.BodyExcluded("/v1/chat/completions") {
+		t.Error("BodyExcluded结果不正确")
+	}
+
+	m.loggers["small"].bodies.policy.SampleRate = 0.2
+	if _, capture := m.BodyCaptureLimit("/v1/chat/completions", 0.5); capture {
+		t.Error("没有记录器采样到的请求不应保存body")
+	}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvDeployment 内置值$env读取的环境变量，用于标记部署环境(如prod、staging)
+const EnvDeployment = "APP_ENV"
+
+// builtinConstants 自定义字段可使用的内置值，在创建格式化器时计算一次
+var builtinConstants = map[string]func() interface{}{
+	"hostname": func() interface{} {
+		hostname, _ := os.Hostname()
+		return hostname
+	},
+	"pid": func() interface{} {
+		return os.Getpid()
+	},
+	"env": func() interface{} {
+		return os.Getenv(EnvDeployment)
+	},
+}
+
+// ResolveConstants 计算自定义字段的值：以$开头的为内置值，其他为原样输出的常量；
+// 引用了不支持的内置值时返回错误
+func (c FormatterConfig) ResolveConstants() (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(c.Constants))
+	var unknown []string
+	for name, value := range c.Constants {
+		if !strings.HasPrefix(value, "$") {
+			values[name] = value
+			continue
+		}
+		builtin, ok := builtinConstants[strings.TrimPrefix(value, "$")]
+		if !ok {
+			unknown = append(unknown, fmt.Sprintf("%s: %s", name, value))
+			values[name] = value
+			continue
+		}
+		values[name] = builtin()
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return values, fmt.Errorf("不支持的内置值: %s", strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
@@ -0,0 +1,321 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 日志导出的行数限制
+const (
+	DefaultExportLimit = 10000  // 未指定时最多导出的行数
+	MaxExportLimit     = 100000 // 允许指定的最大行数
+)
+
+// maxExportLineSize 导出时单行日志的最大字节数，超过时导出失败
+const maxExportLineSize = 16 * 1024 * 1024
+
+// ExportQuery 日志导出的筛选条件
+type ExportQuery struct {
+	From    time.Time // 起始时间（包含）
+	To      time.Time // 结束时间（不包含）
+	ModelID string    // 只导出该模型的请求，为空时不筛选
+	Limit   int       // 最多导出的行数
+}
+
+// ExportEntry 一条匹配的日志
+type ExportEntry struct {
+	Raw    []byte        // 日志文件中的原始行，不含换行符
+	Values []interface{} // 按Columns顺序排列的字段值，缺失的字段为nil
+}
+
+// exportColumn 导出的一列，path为该字段在JSON日志中的位置
+type exportColumn struct {
+	name    string
+	path    []string
+	pattern string // 系统变量名（不含$），常量和引用为空
+}
+
+// LogExporter 从JSON格式的文件日志中按时间范围和模型导出访问日志
+type LogExporter struct {
+	config   OutputConfig
+	columns  []exportColumn
+	timeCol  int // 时间字段所在的列
+	modelCol int // $model_id所在的列，-1表示未记录
+}
+
+// exportTimePatterns 可用于按时间筛选的系统变量
+var exportTimePatterns = map[string]bool{"timestamp": true, "time_iso8601": true, "msec": true, "time_local": true}
+
+// NewLogExporter 创建日志导出器，只支持记录了时间字段的JSON格式文件日志
+func NewLogExporter(config OutputConfig) (*LogExporter, error) {
+	if config.Driver != DriverFile || config.Type != FormatterJSON {
+		return nil, fmt.Errorf("只支持导出JSON格式的文件日志")
+	}
+	e := &LogExporter{config: config, timeCol: -1, modelCol: -1}
+
+	// 列顺序与格式化器的字段配置一致：先fields，再按名称排序的其他分组
+	var groups []string
+	for key := range config.Formatter.Fields {
+		if key != "fields" {
+			groups = append(groups, key)
+		}
+	}
+	sort.Strings(groups)
+	if _, ok := config.Formatter.Fields["fields"]; ok {
+		groups = append([]string{"fields"}, groups...)
+	}
+	names := make(map[string]int)
+	for _, group := range groups {
+		for _, field := range config.Formatter.Fields[group] {
+			name, pattern := exportFieldName(field)
+			column := exportColumn{name: name, path: []string{name}, pattern: pattern}
+			if group != "fields" {
+				column.path = []string{group, name}
+			}
+			e.columns = append(e.columns, column)
+			names[name]++
+		}
+	}
+	for i := range e.columns {
+		column := &e.columns[i]
+		if names[column.name] > 1 && len(column.path) > 1 {
+			// 不同分组中的同名字段以分组名区分
+			column.name = strings.Join(column.path, ".")
+		}
+		if e.timeCol < 0 && exportTimePatterns[column.pattern] {
+			e.timeCol = i
+		}
+		if e.modelCol < 0 && column.pattern == "model_id" {
+			e.modelCol = i
+		}
+	}
+	if e.timeCol < 0 {
+		return nil, fmt.Errorf("日志%s未记录时间字段($timestamp、$time_iso8601、$msec或$time_local)", config.Name)
+	}
+	return e, nil
+}
+
+// exportFieldName 按JSON格式化器的规则返回字段在日志中的名称和系统变量名
+func exportFieldName(field string) (name, pattern string) {
+	field = strings.TrimSpace(field)
+	alias := ""
+	if parts := strings.Split(field, " as "); len(parts) == 2 {
+		field, alias = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	field = strings.TrimSuffix(field, "#")
+	name = field
+	switch {
+	case strings.HasPrefix(field, "$"):
+		pattern = strings.TrimPrefix(field, "$")
+		name = pattern
+	case strings.HasPrefix(field, "@"):
+		name = strings.TrimPrefix(field, "@")
+	}
+	if alias != "" {
+		name = alias
+	}
+	return name, pattern
+}
+
+// Columns 返回导出的列名，顺序与日志格式化器配置的字段一致
+func (e *LogExporter) Columns() []string {
+	names := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// RecordsModel 日志是否记录了$model_id，未记录时不能按模型筛选
+func (e *LogExporter) RecordsModel() bool {
+	return e.modelCol >= 0
+}
+
+// Export 按时间顺序逐行扫描与时间范围重叠的日志文件，对每条匹配的日志调用fn，
+// 达到query.Limit或fn返回错误时停止，返回导出的行数
+func (e *LogExporter) Export(query ExportQuery, fn func(entry ExportEntry) error) (int, error) {
+	if query.ModelID != "" && !e.RecordsModel() {
+		return 0, fmt.Errorf("日志%s未记录$model_id，不能按模型筛选", e.config.Name)
+	}
+	files, err := logFilesInRange(e.config, query.From, query.To)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, path := range files {
+		if query.Limit > 0 && count >= query.Limit {
+			break
+		}
+		n, err := e.exportFile(path, query, query.Limit-count, fn)
+		count += n
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// logFilesInRange 返回可能包含时间范围内日志的文件，已轮转的文件按周期排序，当前文件在最后。
+// 当前文件中的日志都写于最近一次轮转之后，时间范围在最新的轮转周期结束之前时跳过当前文件
+func logFilesInRange(config OutputConfig, from, to time.Time) ([]string, error) {
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+	base := strings.TrimSuffix(config.File, ".log")
+
+	type rotatedFile struct {
+		path  string
+		start time.Time
+	}
+	var rotated []rotatedFile
+	var rotatedUntil time.Time // 最新的轮转文件周期的结束时间
+	current := ""
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if name == base+".log" {
+			current = filepath.Join(config.Dir, name)
+			continue
+		}
+		stamp, _, ok := parseRotatedName(name, base)
+		if !ok {
+			continue
+		}
+		start, end, ok := rotationPeriod(stamp)
+		if !ok {
+			continue
+		}
+		if end.After(rotatedUntil) {
+			rotatedUntil = end
+		}
+		if !start.Before(to) || !end.After(from) {
+			continue
+		}
+		rotated = append(rotated, rotatedFile{path: filepath.Join(config.Dir, name), start: start})
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].start.Before(rotated[j].start)
+	})
+
+	files := make([]string, 0, len(rotated)+1)
+	for _, file := range rotated {
+		files = append(files, file.path)
+	}
+	if current != "" && rotatedUntil.Before(to) {
+		files = append(files, current)
+	}
+	return files, nil
+}
+
+// rotationPeriod 解析轮转文件名中的周期（按小时或按天），返回周期的起止时间
+func rotationPeriod(stamp string) (start, end time.Time, ok bool) {
+	switch len(stamp) {
+	case len("2006010215"):
+		start, err := time.ParseInLocation("2006010215", stamp, time.Local)
+		return start, start.Add(time.Hour), err == nil
+	case len("20060102"):
+		start, err := time.ParseInLocation("20060102", stamp, time.Local)
+		return start, start.AddDate(0, 0, 1), err == nil
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// exportFile 扫描一个日志文件，最多导出limit行；无法解析或不含时间的行会被跳过
+func (e *LogExporter) exportFile(path string, query ExportQuery, limit int, fn func(entry ExportEntry) error) (int, error) {
+	file, err := openLogFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 扫描期间文件被轮转或清理
+			return 0, nil
+		}
+		return 0, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+	count := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		values, ok := e.parseLine(line)
+		if !ok {
+			continue
+		}
+		timestamp, ok := exportTime(e.columns[e.timeCol].pattern, values[e.timeCol])
+		if !ok || timestamp.Before(query.From) || !timestamp.Before(query.To) {
+			continue
+		}
+		if query.ModelID != "" && fmt.Sprint(values[e.modelCol]) != query.ModelID {
+			continue
+		}
+		if err := fn(ExportEntry{Raw: line, Values: values}); err != nil {
+			return count, err
+		}
+		count++
+		if limit > 0 && count >= limit {
+			return count, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("读取日志文件%s失败: %w", filepath.Base(path), err)
+	}
+	return count, nil
+}
+
+// parseLine 解析一行JSON日志，按列顺序返回字段值
+func (e *LogExporter) parseLine(line []byte) ([]interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, false
+	}
+	values := make([]interface{}, len(e.columns))
+	for i, column := range e.columns {
+		var value interface{} = record
+		for _, key := range column.path {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[key]
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// exportTime 解析时间字段的值
+func exportTime(pattern string, value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		if pattern != "msec" {
+			return time.Time{}, false
+		}
+		msec, err := v.Int64()
+		return time.UnixMilli(msec), err == nil
+	case string:
+		layout := time.RFC3339
+		if pattern == "time_local" {
+			layout = "2006-01-02 15:04:05"
+		}
+		t, err := time.ParseInLocation(layout, v, time.Local)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
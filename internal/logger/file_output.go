@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -18,8 +19,14 @@ type FileOutput struct {
 	currentDate string
 	mutex       sync.RWMutex
 	closed      bool
+	lastHash    string // 开启hash_chain时当前文件最后一条记录的哈希
+
+	cleanupMutex sync.Mutex // 保证同一时间只有一个清理任务处理轮转文件
 }
 
+// compressedSuffix 压缩后的轮转文件在.log之后的后缀
+const compressedSuffix = ".gz"
+
 // NewFileOutput 创建文件输出器
 func NewFileOutput(config OutputConfig) (*FileOutput, error) {
 	output := &FileOutput{
@@ -51,29 +58,40 @@ func (f *FileOutput) Write(data []byte) error {
 		return fmt.Errorf("文件输出器已关闭")
 	}
 
-	// 检查是否需要轮转文件
-	if f.needRotate() {
+	// 检查是否需要轮转文件；之前打开或写入失败时重新打开
+	if f.currentFile == nil || f.needRotate() {
 		if err := f.rotateFile(); err != nil {
 			return fmt.Errorf("轮转日志文件失败: %w", err)
 		}
 	}
 
-	// 写入数据
-	if f.currentFile != nil {
-		_, err := f.currentFile.Write(data)
-		if err != nil {
-			return fmt.Errorf("写入日志文件失败: %w", err)
-		}
+	// 写入数据，开启hash_chain时在记录中加入上一条记录的哈希
+	if f.config.HashChain {
+		data = chainRecord(f.config.Type, data, f.lastHash)
+	}
+	if _, err := f.currentFile.Write(data); err != nil {
+		f.dropFile()
+		return fmt.Errorf("写入日志文件失败: %w", err)
+	}
 
-		// 立即刷新到磁盘
-		if err := f.currentFile.Sync(); err != nil {
-			return fmt.Errorf("刷新日志文件失败: %w", err)
-		}
+	// 立即刷新到磁盘
+	if err := f.currentFile.Sync(); err != nil {
+		f.dropFile()
+		return fmt.Errorf("刷新日志文件失败: %w", err)
+	}
+	if f.config.HashChain {
+		f.lastHash = recordHash(data[:len(data)-1])
 	}
 
 	return nil
 }
 
+// dropFile 关闭写入失败的文件，下次写入时重新打开，目录恢复可写后自动恢复记录
+func (f *FileOutput) dropFile() {
+	f.currentFile.Close()
+	f.currentFile = nil
+}
+
 // Close 关闭输出器
 func (f *FileOutput) Close() error {
 	f.mutex.Lock()
@@ -121,20 +139,24 @@ func (f *FileOutput) rotateFile() error {
 		newDate = now.Format("20060102")
 	}
 	fileName := strings.TrimSuffix(f.config.File, ".log")
-	// 如果有当前文件，先关闭并重命名
+	// 如果有当前文件，先关闭
 	if f.currentFile != nil {
 		f.currentFile.Close()
+		f.currentFile = nil
+	}
 
-		// 重命名旧文件
-		oldPath := filepath.Join(f.config.Dir, fileName+".log")
-		newPath := filepath.Join(f.config.Dir, fmt.Sprintf("%s-%s.log", fileName, f.currentDate))
-
-		// 只有当文件存在且不是当前周期时才重命名
-		if _, err := os.Stat(oldPath); err == nil && f.currentDate != "" && f.currentDate != newDate {
-			if err := os.Rename(oldPath, newPath); err != nil {
-				// 重命名失败不应该阻止创建新文件
-				fmt.Printf("重命名日志文件失败: %v\n", err)
-			}
+	// 重命名旧文件，写入失败后重新打开时同样需要
+	oldPath := filepath.Join(f.config.Dir, fileName+".log")
+	newPath := filepath.Join(f.config.Dir, fmt.Sprintf("%s-%s.log", fileName, f.currentDate))
+
+	// 只有当文件存在且不是当前周期时才重命名
+	if _, err := os.Stat(oldPath); err == nil && f.currentDate != "" && f.currentDate != newDate {
+		if err := os.Rename(oldPath, newPath); err != nil {
+			// 重命名失败不应该阻止创建新文件
+			fmt.Printf("重命名日志文件失败: %v\n", err)
+		} else if f.config.Compress || f.config.MaxTotalSizeMB > 0 {
+			// 压缩和总大小检查不占用写入锁
+			go f.cleanup()
 		}
 	}
 
@@ -145,6 +167,16 @@ func (f *FileOutput) rotateFile() error {
 		return fmt.Errorf("创建日志文件失败: %w", err)
 	}
 
+	// 从文件已有的最后一条记录继续哈希链，新文件从genesisHash开始
+	if f.config.HashChain {
+		lastHash, err := lastRecordHash(filePath)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("读取日志文件最后一条记录失败: %w", err)
+		}
+		f.lastHash = lastHash
+	}
+
 	f.currentFile = file
 	f.currentDate = newDate
 
@@ -159,7 +191,7 @@ func (f *FileOutput) cleanupTask() {
 	for {
 		select {
 		case <-ticker.C:
-			f.cleanupExpiredFiles()
+			f.cleanup()
 		}
 
 		// 检查是否已关闭
@@ -173,104 +205,254 @@ func (f *FileOutput) cleanupTask() {
 	}
 }
 
-// cleanupExpiredFiles 清理过期文件
-func (f *FileOutput) cleanupExpiredFiles() {
-	if f.config.Expire <= 0 {
-		return // 不清理
-	}
+// rotatedLogFile 已轮转的日志文件
+type rotatedLogFile struct {
+	name       string
+	path       string
+	size       int64
+	modTime    time.Time
+	compressed bool
+}
 
-	expireTime := time.Now().AddDate(0, 0, -f.config.Expire)
+// baseName 返回不含.log后缀的日志文件名，轮转文件名为<baseName>-<周期>.log
+func (f *FileOutput) baseName() string {
+	return strings.TrimSuffix(f.config.File, ".log")
+}
 
-	// 读取目录中的所有文件
-	files, err := os.ReadDir(f.config.Dir)
-	if err != nil {
-		fmt.Printf("读取日志目录失败: %v\n", err)
-		return
+// parseRotatedName 解析轮转文件名<base>-<周期>.log或压缩后的<base>-<周期>.log.gz，返回周期和是否已压缩
+func parseRotatedName(name, base string) (stamp string, compressed, ok bool) {
+	name, compressed = strings.CutSuffix(name, compressedSuffix)
+	stamp, found := strings.CutPrefix(name, base+"-")
+	stamp, isLog := strings.CutSuffix(stamp, ".log")
+	if !found || !isLog {
+		return "", false, false
 	}
+	_, _, ok = rotationPeriod(stamp)
+	return stamp, compressed, ok
+}
 
-	// 查找需要删除的文件
-	var filesToDelete []string
-	prefix := f.config.File + "-"
-	suffix := ".log"
-
-	for _, file := range files {
-		if file.IsDir() {
+// rotatedFiles 返回目录中已轮转的日志文件，按修改时间从早到晚排序
+func (f *FileOutput) rotatedFiles() ([]rotatedLogFile, error) {
+	entries, err := os.ReadDir(f.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+	base := f.baseName()
+	var files []rotatedLogFile
+	for _, entry := range entries {
+		if entry.IsDir() {
 			continue
 		}
-
-		name := file.Name()
-		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		_, compressed, ok := parseRotatedName(entry.Name(), base)
+		if !ok {
 			continue
 		}
-
-		// 获取文件信息
-		info, err := file.Info()
+		info, err := entry.Info()
 		if err != nil {
 			continue
 		}
+		files = append(files, rotatedLogFile{
+			name:       entry.Name(),
+			path:       filepath.Join(f.config.Dir, entry.Name()),
+			size:       info.Size(),
+			modTime:    info.ModTime(),
+			compressed: compressed,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	return files, nil
+}
 
-		// 检查文件是否过期
-		if info.ModTime().Before(expireTime) {
-			filesToDelete = append(filesToDelete, filepath.Join(f.config.Dir, name))
-		}
+// cleanup 压缩轮转文件、删除过期文件，并按总大小上限删除最早的轮转文件
+func (f *FileOutput) cleanup() {
+	f.cleanupMutex.Lock()
+	defer f.cleanupMutex.Unlock()
+
+	if f.config.Compress {
+		f.compressRotatedFiles()
 	}
+	f.cleanupExpiredFiles()
+	f.enforceTotalSize()
+}
 
-	// 删除过期文件
-	for _, filePath := range filesToDelete {
-		if err := os.Remove(filePath); err != nil {
-			fmt.Printf("删除过期日志文件失败 %s: %v\n", filePath, err)
-		} else {
-			fmt.Printf("删除过期日志文件: %s\n", filePath)
+// compressRotatedFiles 将未压缩的轮转文件压缩为.log.gz，保留原文件的修改时间
+func (f *FileOutput) compressRotatedFiles() {
+	files, err := f.rotatedFiles()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	for _, file := range files {
+		if file.compressed {
+			continue
+		}
+		if err := compressFile(file.path, file.modTime); err != nil {
+			fmt.Printf("压缩日志文件失败 %s: %v\n", file.path, err)
 		}
 	}
 }
 
-// GetLogFiles 获取日志文件列表
-func (f *FileOutput) GetLogFiles() ([]LogFileInfo, error) {
-	files, err := os.ReadDir(f.config.Dir)
+// compressFile 将文件压缩为同名的.gz文件并删除原文件；先写入临时文件，压缩失败时保留原文件
+func compressFile(path string, modTime time.Time) error {
+	src, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+		return err
+	}
+	defer src.Close()
+
+	target := path + compressedSuffix
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(dst)
+	_, err = io.Copy(writer, src)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp, modTime, modTime)
 	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
 
-	var logFiles []LogFileInfo
-	prefix := f.config.File
-	suffix := ".log"
+// cleanupExpiredFiles 清理过期文件
+func (f *FileOutput) cleanupExpiredFiles() {
+	if f.config.Expire <= 0 {
+		return // 不清理
+	}
 
+	expireTime := time.Now().AddDate(0, 0, -f.config.Expire)
+	files, err := f.rotatedFiles()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
 	for _, file := range files {
-		if file.IsDir() {
-			continue
+		if file.modTime.Before(expireTime) {
+			removeLogFile(file.path, "过期")
 		}
+	}
+}
 
-		name := file.Name()
-		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
-			continue
-		}
+// enforceTotalSize 日志文件（包括当前文件）总大小超过max_total_size_mb时，
+// 不论是否过期，从最早的轮转文件开始删除，直到不超过上限；当前文件不会被删除
+func (f *FileOutput) enforceTotalSize() {
+	if f.config.MaxTotalSizeMB <= 0 {
+		return
+	}
+	files, err := f.rotatedFiles()
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
 
-		info, err := file.Info()
-		if err != nil {
-			continue
+	var total int64
+	if info, err := os.Stat(filepath.Join(f.config.Dir, f.baseName()+".log")); err == nil {
+		total = info.Size()
+	}
+	for _, file := range files {
+		total += file.size
+	}
+	budget := int64(f.config.MaxTotalSizeMB) * 1024 * 1024
+	for _, file := range files {
+		if total <= budget {
+			return
 		}
+		if removeLogFile(file.path, "超出总大小上限的") {
+			total -= file.size
+		}
+	}
+}
+
+// removeLogFile 删除日志文件并输出结果，reason说明删除原因
+func removeLogFile(path, reason string) bool {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("删除%s日志文件失败 %s: %v\n", reason, path, err)
+		return false
+	}
+	fmt.Printf("删除%s日志文件: %s\n", reason, path)
+	return true
+}
 
-		logFile := LogFileInfo{
-			Name:      name,
-			Path:      filepath.Join(f.config.Dir, name),
+// GetLogFiles 获取日志文件列表，包括当前文件和已轮转的文件（含压缩的文件）
+func (f *FileOutput) GetLogFiles() ([]LogFileInfo, error) {
+	rotated, err := f.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	logFiles := make([]LogFileInfo, 0, len(rotated)+1)
+	currentName := f.baseName() + ".log"
+	if info, err := os.Stat(filepath.Join(f.config.Dir, currentName)); err == nil {
+		logFiles = append(logFiles, LogFileInfo{
+			Name:      currentName,
+			Path:      filepath.Join(f.config.Dir, currentName),
 			Size:      info.Size(),
 			ModTime:   info.ModTime(),
-			IsCurrent: name == f.config.File+".log",
-		}
-
-		logFiles = append(logFiles, logFile)
+			IsCurrent: true,
+		})
+	}
+	for _, file := range rotated {
+		logFiles = append(logFiles, LogFileInfo{
+			Name:       file.name,
+			Path:       file.path,
+			Size:       file.size,
+			ModTime:    file.modTime,
+			Compressed: file.compressed,
+		})
 	}
 
 	// 按修改时间排序
-	sort.Slice(logFiles, func(i, j int) bool {
+	sort.SliceStable(logFiles, func(i, j int) bool {
 		return logFiles[i].ModTime.After(logFiles[j].ModTime)
 	})
 
 	return logFiles, nil
 }
 
-// ReadLogFile 读取日志文件内容
+// openLogFile 打开日志文件，.gz文件返回解压后的内容
+func openLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, compressedSuffix) {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("解压日志文件失败: %w", err)
+	}
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+// gzipFile 关闭时同时关闭解压器和文件
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// ReadLogFile 读取日志文件内容，压缩的文件(.log.gz)按解压后的内容读取，offset为解压后的偏移量
 func (f *FileOutput) ReadLogFile(filename string, offset int64, limit int64) ([]byte, error) {
 	filePath := filepath.Join(f.config.Dir, filename)
 
@@ -279,15 +461,20 @@ func (f *FileOutput) ReadLogFile(filename string, offset int64, limit int64) ([]
 		return nil, fmt.Errorf("日志文件不存在: %s", filename)
 	}
 
-	file, err := os.Open(filePath)
+	file, err := openLogFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("打开日志文件失败: %w", err)
 	}
 	defer file.Close()
 
-	// 移动到指定偏移量
+	// 移动到指定偏移量，压缩的文件不能随机访问，跳过解压后的内容
 	if offset > 0 {
-		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		if seeker, ok := file.(io.Seeker); ok {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		} else if _, err = io.CopyN(io.Discard, file, offset); err == io.EOF {
+			err = nil
+		}
+		if err != nil {
 			return nil, fmt.Errorf("移动文件指针失败: %w", err)
 		}
 	}
@@ -298,8 +485,8 @@ func (f *FileOutput) ReadLogFile(filename string, offset int64, limit int64) ([]
 	}
 
 	buffer := make([]byte, limit)
-	n, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return nil, fmt.Errorf("读取日志文件失败: %w", err)
 	}
 
@@ -313,4 +500,6 @@ type LogFileInfo struct {
 	Size      int64     `json:"size"`
 	ModTime   time.Time `json:"mod_time"`
 	IsCurrent bool      `json:"is_current"`
+
+	Compressed bool `json:"compressed"` // 是否为压缩的轮转文件(.log.gz)，读取时自动解压
 }
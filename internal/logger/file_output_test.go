@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRotatedFile 写入一个轮转文件并设置修改时间
+func writeRotatedFile(t *testing.T, dir, name string, data []byte, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("写入日志文件失败: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+}
+
+func TestFileOutputCompressAndSizeBudget(t *testing.T) {
+	dir := t.TempDir()
+	output, err := NewFileOutput(OutputConfig{
+		Name: "access", Driver: DriverFile, File: "access.log", Dir: dir, Period: PeriodHour,
+		Compress: true, MaxTotalSizeMB: 1,
+	})
+	if err != nil {
+		t.Fatalf("创建文件输出器失败: %v", err)
+	}
+	defer output.Close()
+
+	// 随机内容几乎无法压缩，两个较早的文件压缩后仍超过1MB的上限
+	random := func() []byte {
+		data := make([]byte, 600*1024)
+		rand.Read(data)
+		return data
+	}
+	text := []byte(strings.Repeat(`{"request_id":"req","status":200}`+"\n", 1000))
+	base := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	writeRotatedFile(t, dir, "access-2024010101.log", random(), base)
+	writeRotatedFile(t, dir, "access-2024010102.log", random(), base.Add(time.Hour))
+	writeRotatedFile(t, dir, "access-2024010103.log", text, base.Add(2*time.Hour))
+	writeRotatedFile(t, dir, "other-2024010103.log", text, base) // 其他日志记录器的文件不受影响
+
+	output.cleanup()
+
+	files, err := output.GetLogFiles()
+	if err != nil {
+		t.Fatalf("获取日志文件列表失败: %v", err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+		if file.Compressed != strings.HasSuffix(file.Name, ".gz") || file.IsCurrent != (file.Name == "access.log") {
+			t.Errorf("文件信息不正确: %+v", file)
+		}
+	}
+	// 超出上限时先删除最早的轮转文件，按修改时间从新到旧返回
+	want := []string{"access.log", "access-2024010103.log.gz", "access-2024010102.log.gz"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("日志文件 = %v, want %v", names, want)
+	}
+	if !files[1].ModTime.Equal(base.Add(2 * time.Hour)) {
+		t.Errorf("压缩后应保留原文件的修改时间，实际为%v", files[1].ModTime)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other-2024010103.log")); err != nil {
+		t.Errorf("不应处理其他日志记录器的文件: %v", err)
+	}
+
+	// 压缩的文件读取时自动解压，offset为解压后的偏移量
+	data, err := output.ReadLogFile("access-2024010103.log.gz", 34, 34)
+	if err != nil {
+		t.Fatalf("读取压缩的日志文件失败: %v", err)
+	}
+	if !bytes.Equal(data, text[34:68]) {
+		t.Errorf("读取内容 = %q, want %q", data, text[34:68])
+	}
+}
+
+func TestGetAllLogFiles(t *testing.T) {
+	manager := NewLoggerManager()
+	defer manager.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"access", "audit"} {
+		if err := manager.AddLogger(name, OutputConfig{
+			Name: name, Driver: DriverFile, Enabled: true, Type: FormatterJSON, File: name + ".log", Dir: dir, Period: PeriodDay,
+		}); err != nil {
+			t.Fatalf("添加日志记录器失败: %v", err)
+		}
+	}
+	if err := manager.AddLogger("console", OutputConfig{Name: "console", Driver: DriverStdout, Enabled: true, Type: FormatterJSON}); err != nil {
+		t.Fatalf("添加日志记录器失败: %v", err)
+	}
+	writeRotatedFile(t, dir, "audit-20240101.log", []byte("{}\n"), time.Now().Add(-time.Hour))
+
+	files, err := manager.GetAllLogFiles()
+	if err != nil {
+		t.Fatalf("获取日志文件列表失败: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("应只包含文件日志记录器，实际为%v", files)
+	}
+	if got := files["access"]; len(got) != 1 || got[0].Name != "access.log" {
+		t.Errorf("access的日志文件 = %+v", got)
+	}
+	if got := files["audit"]; len(got) != 2 || got[1].Name != "audit-20240101.log" {
+		t.Errorf("audit的日志文件 = %+v", got)
+	}
+}
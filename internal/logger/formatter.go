@@ -3,20 +3,27 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // JSONFormatter JSON格式化器
 type JSONFormatter struct {
-	config FormatterConfig
+	config    FormatterConfig
+	constants map[string]interface{}
+	cycleWarn sync.Once
 }
 
 // NewJSONFormatter 创建JSON格式化器
 func NewJSONFormatter(config FormatterConfig) *JSONFormatter {
+	constants, _ := config.ResolveConstants()
 	return &JSONFormatter{
-		config: config,
+		config:    config,
+		constants: constants,
 	}
 }
 
@@ -27,7 +34,7 @@ func (f *JSONFormatter) Format(data *RequestLogData) ([]byte, error) {
 	// 处理fields配置
 	if fields, exists := f.config.Fields["fields"]; exists {
 		for _, field := range fields {
-			f.processField(field, data, result)
+			f.processField(field, data, result, map[string]bool{})
 		}
 	}
 	
@@ -36,7 +43,7 @@ func (f *JSONFormatter) Format(data *RequestLogData) ([]byte, error) {
 		if key != "fields" {
 			customData := make(map[string]interface{})
 			for _, field := range fields {
-				f.processField(field, data, customData)
+				f.processField(field, data, customData, map[string]bool{key: true})
 			}
 			result[key] = customData
 		}
@@ -47,13 +54,17 @@ func (f *JSONFormatter) Format(data *RequestLogData) ([]byte, error) {
 
 // LineFormatter Line格式化器
 type LineFormatter struct {
-	config FormatterConfig
+	config    FormatterConfig
+	constants map[string]interface{}
+	cycleWarn sync.Once
 }
 
 // NewLineFormatter 创建Line格式化器
 func NewLineFormatter(config FormatterConfig) *LineFormatter {
+	constants, _ := config.ResolveConstants()
 	return &LineFormatter{
-		config: config,
+		config:    config,
+		constants: constants,
 	}
 }
 
@@ -64,7 +75,7 @@ func (f *LineFormatter) Format(data *RequestLogData) ([]byte, error) {
 	// 处理fields配置
 	if fields, exists := f.config.Fields["fields"]; exists {
 		for _, field := range fields {
-			value := f.extractFieldValue(field, data)
+			value := f.extractFieldValue(field, data, map[string]bool{})
 			parts = append(parts, fmt.Sprintf("%v", value))
 		}
 	}
@@ -73,8 +84,8 @@ func (f *LineFormatter) Format(data *RequestLogData) ([]byte, error) {
 	return []byte(result), nil
 }
 
-// processField 处理字段配置
-func (f *JSONFormatter) processField(field string, data *RequestLogData, result map[string]interface{}) {
+// processField 处理字段配置，visited为当前正在解析的引用，用于发现循环引用
+func (f *JSONFormatter) processField(field string, data *RequestLogData, result map[string]interface{}, visited map[string]bool) {
 	// 解析字段格式: ($|@){pattern}[#] as {name}
 	field = strings.TrimSpace(field)
 	
@@ -97,7 +108,7 @@ func (f *JSONFormatter) processField(field string, data *RequestLogData, result
 	}
 	
 	// 提取字段值
-	value := f.extractFieldValue(field, data)
+	value := f.extractFieldValue(field, data, visited)
 	
 	// 确定最终的字段名
 	if alias != "" {
@@ -112,18 +123,14 @@ func (f *JSONFormatter) processField(field string, data *RequestLogData, result
 	
 	// 处理数组类型
 	if isArray {
-		if arr, ok := value.([]interface{}); ok {
-			result[fieldName] = arr
-		} else {
-			result[fieldName] = []interface{}{value}
-		}
+		result[fieldName] = arrayElements(value)
 	} else {
 		result[fieldName] = value
 	}
 }
 
 // extractFieldValue 提取字段值
-func (f *JSONFormatter) extractFieldValue(field string, data *RequestLogData) interface{} {
+func (f *JSONFormatter) extractFieldValue(field string, data *RequestLogData, visited map[string]bool) interface{} {
 	// 处理系统变量 ($pattern)
 	if strings.HasPrefix(field, "$") {
 		return f.getSystemValue(strings.TrimPrefix(field, "$"), data)
@@ -132,10 +139,16 @@ func (f *JSONFormatter) extractFieldValue(field string, data *RequestLogData) in
 	// 处理引用 (@pattern)
 	if strings.HasPrefix(field, "@") {
 		refKey := strings.TrimPrefix(field, "@")
+		if visited[refKey] {
+			warnCycle(&f.cycleWarn, refKey)
+			return nil
+		}
 		if refFields, exists := f.config.Fields[refKey]; exists {
+			visited[refKey] = true
+			defer delete(visited, refKey)
 			refResult := make(map[string]interface{})
 			for _, refField := range refFields {
-				f.processField(refField, data, refResult)
+				f.processField(refField, data, refResult, visited)
 			}
 			return refResult
 		}
@@ -147,15 +160,17 @@ func (f *JSONFormatter) extractFieldValue(field string, data *RequestLogData) in
 }
 
 // extractFieldValue Line格式化器的字段值提取
-func (f *LineFormatter) extractFieldValue(field string, data *RequestLogData) interface{} {
+func (f *LineFormatter) extractFieldValue(field string, data *RequestLogData, visited map[string]bool) interface{} {
 	// 移除别名部分，只保留字段名
 	if strings.Contains(field, " as ") {
 		parts := strings.Split(field, " as ")
 		field = strings.TrimSpace(parts[0])
 	}
 	
-	// 移除数组标记
-	field = strings.TrimSuffix(field, "#")
+	// 数组字段的元素按JSON格式输出并以分隔符连接
+	if strings.HasSuffix(field, "#") {
+		return f.formatArray(arrayElements(f.extractFieldValue(strings.TrimSuffix(field, "#"), data, visited)))
+	}
 	
 	// 处理系统变量
 	if strings.HasPrefix(field, "$") {
@@ -165,10 +180,16 @@ func (f *LineFormatter) extractFieldValue(field string, data *RequestLogData) in
 	// 处理引用
 	if strings.HasPrefix(field, "@") {
 		refKey := strings.TrimPrefix(field, "@")
+		if visited[refKey] {
+			warnCycle(&f.cycleWarn, refKey)
+			return ""
+		}
 		if refFields, exists := f.config.Fields[refKey]; exists {
+			visited[refKey] = true
+			defer delete(visited, refKey)
 			var values []string
 			for _, refField := range refFields {
-				value := f.extractFieldValue(refField, data)
+				value := f.extractFieldValue(refField, data, visited)
 				values = append(values, fmt.Sprintf("%v", value))
 			}
 			return strings.Join(values, " ")
@@ -180,16 +201,105 @@ func (f *LineFormatter) extractFieldValue(field string, data *RequestLogData) in
 	return field
 }
 
+// warnCycle 发现循环引用时输出警告，每个格式化器只输出一次
+func warnCycle(once *sync.Once, refKey string) {
+	once.Do(func() {
+		fmt.Printf("日志格式化配置存在循环引用 @%s，循环处的字段输出为空\n", refKey)
+	})
+}
+
+// formatArray 将数组元素按JSON格式输出（字符串带引号），以配置的分隔符连接
+func (f *LineFormatter) formatArray(elements []interface{}) string {
+	separator := f.config.ArraySeparator
+	if separator == "" {
+		separator = DefaultArraySeparator
+	}
+	parts := make([]string, 0, len(elements))
+	for _, element := range elements {
+		data, err := json.Marshal(element)
+		if err != nil {
+			data = []byte(strconv.Quote(fmt.Sprintf("%v", element)))
+		}
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, separator)
+}
+
+// arrayElements 返回数组字段的元素：切片按元素展开，其他值作为唯一的元素
+func arrayElements(value interface{}) []interface{} {
+	if arr, ok := value.([]interface{}); ok {
+		return arr
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []interface{}{value}
+	}
+	elements := make([]interface{}, v.Len())
+	for i := range elements {
+		elements[i] = v.Index(i).Interface()
+	}
+	return elements
+}
+
 // getSystemValue 获取系统变量值
 func (f *JSONFormatter) getSystemValue(pattern string, data *RequestLogData) interface{} {
-	return getSystemValue(pattern, data)
+	if value, exists := f.constants[pattern]; exists {
+		return value
+	}
+	return formatSystemValue(pattern, data, f.config.BodyOnErrorOnly)
 }
 
 // getSystemValue Line格式化器的系统变量获取
 func (f *LineFormatter) getSystemValue(pattern string, data *RequestLogData) interface{} {
+	if value, exists := f.constants[pattern]; exists {
+		return value
+	}
+	return formatSystemValue(pattern, data, f.config.BodyOnErrorOnly)
+}
+
+// formatSystemValue 获取系统变量值，bodyOnErrorOnly为true时成功请求的body替换为[omitted N bytes]标记
+func formatSystemValue(pattern string, data *RequestLogData, bodyOnErrorOnly bool) interface{} {
+	if bodyOnErrorOnly && !data.Failed() {
+		var size int64
+		switch pattern {
+		case "request_body":
+			size = int64(len(data.RequestBody))
+		case "upstream_body":
+			size = int64(len(data.UpstreamBody))
+		case "response_body":
+			size = int64(len(data.ResponseBody)) + data.ResponseBodyDropped
+		default:
+			return getSystemValue(pattern, data)
+		}
+		if size == 0 {
+			return ""
+		}
+		return fmt.Sprintf("[omitted %d bytes]", size)
+	}
 	return getSystemValue(pattern, data)
 }
 
+// extraValue 获取Extra中的值，不存在时返回空字符串
+func extraValue(data *RequestLogData, key string) interface{} {
+	if value, exists := data.Extra[key]; exists {
+		return value
+	}
+	return ""
+}
+
+// headerValue 获取请求头的值，名称不区分大小写，不存在时返回空字符串
+func headerValue(headers map[string]string, name string) string {
+	if value, exists := headers[http.CanonicalHeaderKey(name)]; exists {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
 // getSystemValue 通用的系统变量获取函数
 func getSystemValue(pattern string, data *RequestLogData) interface{} {
 	switch pattern {
@@ -208,8 +318,12 @@ func getSystemValue(pattern string, data *RequestLogData) interface{} {
 		return data.Path
 	case "user_agent":
 		return data.UserAgent
-	case "client_ip", "remote_addr":
+	case "client_ip":
 		return data.ClientIP
+	case "remote_addr":
+		return data.RemoteAddr
+	case "headers":
+		return data.Headers
 		
 	// 认证信息
 	case "api_key":
@@ -246,12 +360,27 @@ func getSystemValue(pattern string, data *RequestLogData) interface{} {
 		return data.ResponseTime
 	case "response_body":
 		return data.ResponseBody
+
+	// 耗时信息(毫秒)，请求未经过对应阶段时为空
+	case "inject_time":
+		return extraValue(data, ExtraInjectTime)
+	case "upstream_connect_time":
+		return extraValue(data, ExtraUpstreamConnect)
+	case "ttfb":
+		return extraValue(data, ExtraUpstreamTTFB)
+	case "upstream_time":
+		return extraValue(data, ExtraUpstreamTime)
 		
 	// 错误信息
 	case "error":
 		return data.Error
 		
 	default:
+		// 单个请求头 ($header.<name>)，名称不区分大小写
+		if name := strings.TrimPrefix(pattern, "header."); name != pattern {
+			return headerValue(data.Headers, name)
+		}
+
 		// 尝试从Extra中获取
 		if data.Extra != nil {
 			if value, exists := data.Extra[pattern]; exists {
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatterConstants(t *testing.T) {
+	t.Setenv(EnvDeployment, "staging")
+	hostname, _ := os.Hostname()
+	config := FormatterConfig{
+		Fields: map[string][]string{
+			"fields": {"$schema_version", "$host", "$pid", "$env", "$status"},
+		},
+		Constants: map[string]string{
+			"schema_version": "2",
+			"host":           "$hostname",
+			"pid":            "$pid",
+			"env":            "$env",
+		},
+	}
+	data := &RequestLogData{StatusCode: 200}
+
+	line, err := NewLineFormatter(config).Format(data)
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	want := fmt.Sprintf("2\t%s\t%d\tstaging\t200\n", hostname, os.Getpid())
+	if string(line) != want {
+		t.Errorf("日志 = %q, want %q", line, want)
+	}
+
+	line, err = NewJSONFormatter(config).Format(data)
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	want = fmt.Sprintf(`{"env":"staging","host":%q,"pid":%d,"schema_version":"2","status":200}`, hostname, os.Getpid())
+	if got := strings.TrimSpace(string(line)); got != want {
+		t.Errorf("日志 = %s, want %s", got, want)
+	}
+}
+
+func TestResolveConstantsUnknownBuiltin(t *testing.T) {
+	config := FormatterConfig{Constants: map[string]string{"region": "$region"}}
+	if _, err := config.ResolveConstants(); err == nil || !strings.Contains(err.Error(), "region") {
+		t.Errorf("不支持的内置值应返回错误，实际为%v", err)
+	}
+}
+
+func TestHeaderFields(t *testing.T) {
+	config := FormatterConfig{Fields: map[string][]string{
+		"fields": {"$header.user-agent as ua", "$header.X-REQUEST-ID", "$header.missing", "$headers"},
+	}}
+	data := &RequestLogData{Headers: map[string]string{
+		"User-Agent":   "curl/8.0",
+		"X-Request-Id": "abc",
+	}}
+
+	line, err := NewJSONFormatter(config).Format(data)
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	want := `{"header.X-REQUEST-ID":"abc","header.missing":"","headers":{"User-Agent":"curl/8.0","X-Request-Id":"abc"},"ua":"curl/8.0"}`
+	if got := strings.TrimSpace(string(line)); got != want {
+		t.Errorf("日志 = %s, want %s", got, want)
+	}
+
+	// 非规范形式的请求头名称同样不区分大小写匹配
+	data.Headers = map[string]string{"x-trace": "t1"}
+	if got := headerValue(data.Headers, "X-Trace"); got != "t1" {
+		t.Errorf("headerValue = %q, want t1", got)
+	}
+}
+
+func TestArrayFieldsInBothFormatters(t *testing.T) {
+	fields := map[string][]string{
+		"fields": {"$tags#", "$model_id#", "$scores# as score_list"},
+	}
+	data := &RequestLogData{ModelID: "gpt-4o", Extra: map[string]interface{}{
+		"tags":   []string{"a", "b c"},
+		"scores": []interface{}{1, 2.5},
+	}}
+
+	line, err := NewJSONFormatter(FormatterConfig{Fields: fields}).Format(data)
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	want := `{"model_id":["gpt-4o"],"score_list":[1,2.5],"tags":["a","b c"]}`
+	if got := strings.TrimSpace(string(line)); got != want {
+		t.Errorf("JSON日志 = %s, want %s", got, want)
+	}
+
+	// Line格式化器输出相同的元素，以分隔符连接
+	line, err = NewLineFormatter(FormatterConfig{Fields: fields}).Format(data)
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	if want := "\"a\",\"b c\"\t\"gpt-4o\"\t1,2.5\n"; string(line) != want {
+		t.Errorf("Line日志 = %q, want %q", line, want)
+	}
+
+	line, _ = NewLineFormatter(FormatterConfig{Fields: fields, ArraySeparator: "|"}).Format(data)
+	if want := "\"a\"|\"b c\"\t\"gpt-4o\"\t1|2.5\n"; string(line) != want {
+		t.Errorf("自定义分隔符的Line日志 = %q, want %q", line, want)
+	}
+}
+
+func TestCircularFieldReferences(t *testing.T) {
+	config := FormatterConfig{Fields: map[string][]string{
+		"fields": {"$request_id", "@a"},
+		"a":      {"$method", "@b"},
+		"b":      {"$status", "@a"},
+	}}
+	data := &RequestLogData{RequestID: "req-1", Method: "POST", StatusCode: 200}
+
+	done := make(chan [2]string, 1)
+	go func() {
+		jsonLine, _ := NewJSONFormatter(config).Format(data)
+		line, _ := NewLineFormatter(config).Format(data)
+		done <- [2]string{strings.TrimSpace(string(jsonLine)), string(line)}
+	}()
+	select {
+	case got := <-done:
+		want := `{"a":{"b":{"a":null,"status":200},"method":"POST"},"b":{"a":{"b":null,"method":"POST"},"status":200},"request_id":"req-1"}`
+		if got[0] != want {
+			t.Errorf("JSON日志 = %s, want %s", got[0], want)
+		}
+		if got[1] != "req-1\tPOST 200 \n" {
+			t.Errorf("Line日志 = %q", got[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("循环引用的字段配置不应导致格式化无法结束")
+	}
+}
@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// HashChainField 开启hash_chain时记录上一条记录哈希的字段：JSON格式为对象的第一个字段，
+// Line格式以" _prev_hash=<哈希>"附加在行尾
+const HashChainField = "_prev_hash"
+
+// genesisHash 每个日志文件第一条记录的上一条哈希
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+// recordHash 返回一条记录（日志文件中的一行，不含换行符）的哈希
+func recordHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// chainRecord 在一条记录中加入上一条记录的哈希，record以换行符结尾
+func chainRecord(formatter FormatterType, record []byte, prevHash string) []byte {
+	line := bytes.TrimSuffix(record, []byte("\n"))
+	chained := make([]byte, 0, len(line)+len(prevHash)+len(HashChainField)+8)
+	if formatter == FormatterJSON && len(line) > 0 && line[0] == '{' {
+		chained = append(chained, `{"`+HashChainField+`":"`+prevHash+`"`...)
+		rest := line[1:]
+		if len(bytes.TrimSpace(rest)) > 0 && bytes.TrimSpace(rest)[0] != '}' {
+			chained = append(chained, ',')
+		}
+		chained = append(chained, rest...)
+	} else {
+		chained = append(chained, line...)
+		chained = append(chained, " "+HashChainField+"="+prevHash...)
+	}
+	return append(chained, '\n')
+}
+
+// chainedPrevHash 从一条记录中取出上一条记录的哈希，没有时返回false
+func chainedPrevHash(formatter FormatterType, line []byte) (string, bool) {
+	if formatter == FormatterJSON {
+		result := gjson.GetBytes(line, HashChainField)
+		return result.String(), result.Type == gjson.String
+	}
+	marker := []byte(" " + HashChainField + "=")
+	i := bytes.LastIndex(line, marker)
+	if i < 0 || len(line)-i-len(marker) != len(genesisHash) {
+		return "", false
+	}
+	return string(line[i+len(marker):]), true
+}
+
+// lastRecordHash 返回日志文件最后一条记录的哈希，用于重新打开文件后继续哈希链；文件为空或不存在时返回genesisHash
+func lastRecordHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return genesisHash, nil
+		}
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	// 从文件末尾向前按块读取，直到找到最后一行的开头
+	const chunkSize = 64 * 1024
+	end := info.Size()
+	var tail []byte
+	for offset := end; offset > 0; {
+		size := int64(chunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size)
+		if _, err := file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return "", err
+		}
+		tail = append(chunk, tail...)
+		if i := bytes.LastIndexByte(bytes.TrimSuffix(tail, []byte("\n")), '\n'); i >= 0 {
+			tail = tail[i+1:]
+			break
+		}
+	}
+	line := bytes.TrimSuffix(tail, []byte("\n"))
+	if len(line) == 0 {
+		return genesisHash, nil
+	}
+	return recordHash(line), nil
+}
+
+// ChainVerification 日志文件哈希链的校验结果
+type ChainVerification struct {
+	File       string `json:"file"`
+	Valid      bool   `json:"valid"`
+	Records    int    `json:"records"`               // 校验通过的记录数
+	BrokenLine int    `json:"broken_line,omitempty"` // 第一个断开的记录所在的行号，从1开始
+	Reason     string `json:"reason,omitempty"`      // 断开的原因
+	LastHash   string `json:"last_hash"`             // 最后一条通过校验的记录的哈希，可保存在外部，用于发现末尾记录被修改或删除
+}
+
+// VerifyHashChain 校验开启了hash_chain的日志文件，从第一条记录开始逐条检查上一条记录的哈希，
+// 报告第一个断开的位置；filename为日志目录中的文件名，为空时校验当前文件，压缩的轮转文件会被解压校验，
+// 文件不存在时返回ErrLogFileNotFound
+func VerifyHashChain(config OutputConfig, filename string) (*ChainVerification, error) {
+	if config.Driver != DriverFile {
+		return nil, fmt.Errorf("%w: %s驱动不提供日志文件校验", ErrNotSupported, config.Driver)
+	}
+	if !config.HashChain {
+		return nil, fmt.Errorf("%w: 日志%s未开启hash_chain", ErrNotSupported, config.Name)
+	}
+	if filename == "" {
+		filename = strings.TrimSuffix(config.File, ".log") + ".log"
+	}
+	if filepath.Base(filename) != filename || filename == "." || filename == ".." {
+		return nil, fmt.Errorf("%w: %s", ErrLogFileNotFound, filename)
+	}
+	file, err := openLogFile(filepath.Join(config.Dir, filename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrLogFileNotFound, filename)
+		}
+		return nil, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	defer file.Close()
+
+	result := &ChainVerification{File: filename, Valid: true, LastHash: genesisHash}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		prevHash, ok := chainedPrevHash(config.Type, line)
+		switch {
+		case !ok:
+			result.Reason = "记录中缺少上一条记录的哈希"
+		case prevHash != result.LastHash:
+			result.Reason = fmt.Sprintf("上一条记录的哈希不匹配: 期望%s，记录中为%s", result.LastHash, prevHash)
+		}
+		if result.Reason != "" {
+			result.Valid = false
+			result.BrokenLine = lineNo
+			return result, nil
+		}
+		result.LastHash = recordHash(line)
+		result.Records++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取日志文件%s失败: %w", filename, err)
+	}
+	return result, nil
+}
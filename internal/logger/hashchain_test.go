@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashChain(t *testing.T) {
+	for _, formatter := range []FormatterType{FormatterJSON, FormatterLine} {
+		t.Run(string(formatter), func(t *testing.T) {
+			dir := t.TempDir()
+			config := OutputConfig{Name: "audit", Driver: DriverFile, Type: formatter, File: "audit.log", Dir: dir, Period: PeriodDay, HashChain: true}
+			records := []string{"{\"id\":1}\n", "{}\n", "{\"id\":3}\n"}
+			if formatter == FormatterLine {
+				records = []string{"GET /a 200\n", "POST /b 500\n", "GET /c 200\n"}
+			}
+
+			output, err := NewFileOutput(config)
+			if err != nil {
+				t.Fatalf("创建文件输出器失败: %v", err)
+			}
+			for _, record := range records[:2] {
+				if err := output.Write([]byte(record)); err != nil {
+					t.Fatalf("写入日志失败: %v", err)
+				}
+			}
+			output.Close()
+
+			// 重新打开文件后从最后一条记录继续哈希链
+			output, err = NewFileOutput(config)
+			if err != nil {
+				t.Fatalf("重新创建文件输出器失败: %v", err)
+			}
+			if err := output.Write([]byte(records[2])); err != nil {
+				t.Fatalf("写入日志失败: %v", err)
+			}
+			output.Close()
+
+			result, err := VerifyHashChain(config, "")
+			if err != nil {
+				t.Fatalf("校验日志文件失败: %v", err)
+			}
+			if !result.Valid || result.Records != 3 || result.File != "audit.log" {
+				t.Fatalf("校验结果 = %+v, want 3条记录全部通过", result)
+			}
+			path := filepath.Join(dir, "audit.log")
+			data, _ := os.ReadFile(path)
+			lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			if result.LastHash != recordHash([]byte(lines[2])) {
+				t.Errorf("last_hash = %s, want 最后一条记录的哈希", result.LastHash)
+			}
+
+			// 修改第二条记录（即使只在行首加一个空格），第三条记录中的哈希不再匹配
+			tampered := strings.Replace(string(data), lines[1], " "+lines[1], 1)
+			if err := os.WriteFile(path, []byte(tampered), 0644); err != nil {
+				t.Fatalf("修改日志文件失败: %v", err)
+			}
+			result, err = VerifyHashChain(config, "audit.log")
+			if err != nil {
+				t.Fatalf("校验日志文件失败: %v", err)
+			}
+			if result.Valid || result.BrokenLine != 3 || result.Records != 2 {
+				t.Errorf("修改后校验结果 = %+v, want 第3行断开", result)
+			}
+
+			// 删除第一条记录，第二条记录中的哈希不再匹配
+			if err := os.WriteFile(path, []byte(strings.Join(lines[1:], "\n")+"\n"), 0644); err != nil {
+				t.Fatalf("修改日志文件失败: %v", err)
+			}
+			if result, _ = VerifyHashChain(config, ""); result.Valid || result.BrokenLine != 1 {
+				t.Errorf("删除后校验结果 = %+v, want 第1行断开", result)
+			}
+		})
+	}
+}
+
+func TestVerifyHashChainErrors(t *testing.T) {
+	config := OutputConfig{Name: "audit", Driver: DriverFile, Type: FormatterJSON, File: "audit.log", Dir: t.TempDir(), HashChain: true}
+	for _, name := range []string{"missing.log", "../audit.log", ".."} {
+		if _, err := VerifyHashChain(config, name); !errors.Is(err, ErrLogFileNotFound) {
+			t.Errorf("VerifyHashChain(%q) error = %v, want ErrLogFileNotFound", name, err)
+		}
+	}
+
+	config.HashChain = false
+	if _, err := VerifyHashChain(config, ""); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("未开启hash_chain时 error = %v, want ErrNotSupported", err)
+	}
+	if _, err := VerifyHashChain(OutputConfig{Driver: DriverStdout, HashChain: true}, ""); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("非文件日志 error = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 日志记录器的健康状态
+const (
+	LoggerStatusOK       = "ok"       // 正常写入
+	LoggerStatusDegraded = "degraded" // 最近一次写入失败，期间的日志被丢弃
+	LoggerStatusFailed   = "failed"   // 初始化失败，所有日志被丢弃
+)
+
+// LoggerStatus 日志记录器的健康状态
+type LoggerStatus struct {
+	Name        string     `json:"name"`
+	Driver      string     `json:"driver"`
+	Status      string     `json:"status"`
+	Dropped     int64      `json:"dropped"`                 // 写入失败而丢弃的日志条数（累计）
+	LastError   string     `json:"last_error,omitempty"`    // 最近一次失败的原因
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // 最近一次失败的时间
+}
+
+// loggerHealth 记录日志记录器的写入结果，并发安全
+type loggerHealth struct {
+	mutex       sync.Mutex
+	failing     bool
+	dropped     int64
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// succeed 记录一次成功写入，之前处于失败状态时恢复正常
+func (h *loggerHealth) succeed() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.failing = false
+}
+
+// fail 记录一次写入失败并计入丢弃数，返回是否刚从正常状态转为失败（用于只打印一次错误）
+func (h *loggerHealth) fail(err error) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.dropped++
+	return h.setError(err)
+}
+
+// setError 记录失败原因，调用方需持有锁
+func (h *loggerHealth) setError(err error) bool {
+	first := !h.failing
+	h.failing = true
+	h.lastError = err.Error()
+	h.lastErrorAt = time.Now()
+	return first
+}
+
+// drop 只计入丢弃数，不更新错误信息
+func (h *loggerHealth) drop() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.dropped++
+}
+
+// status 返回当前状态
+func (h *loggerHealth) status(name string, config OutputConfig) LoggerStatus {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	driver := config.Driver
+	if driver == "" {
+		driver = DriverFile
+	}
+	status := LoggerStatus{Name: name, Driver: driver, Status: LoggerStatusOK, Dropped: h.dropped, LastError: h.lastError}
+	if h.failing {
+		status.Status = LoggerStatusDegraded
+	}
+	if !h.lastErrorAt.IsZero() {
+		at := h.lastErrorAt
+		status.LastErrorAt = &at
+	}
+	return status
+}
+
+// failedLogger 初始化失败的日志记录器，保留配置和错误以便查询状态，收到的日志计入丢弃数
+type failedLogger struct {
+	config OutputConfig
+	health loggerHealth
+}
+
+// newFailedLogger 记录初始化失败的原因
+func newFailedLogger(config OutputConfig, err error) *failedLogger {
+	failed := &failedLogger{config: config}
+	failed.health.setError(err)
+	return failed
+}
+
+// Status 返回日志记录器的健康状态
+func (l *RequestLogger) Status(name string) LoggerStatus {
+	return l.health.status(name, l.config)
+}
+
+// Status 返回所有日志记录器（包括初始化失败的）的健康状态，按名称排序
+func (m *LoggerManager) Status() []LoggerStatus {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	statuses := make([]LoggerStatus, 0, len(m.loggers)+len(m.failed))
+	for name, logger := range m.loggers {
+		statuses = append(statuses, logger.Status(name))
+	}
+	for name, failed := range m.failed {
+		status := failed.health.status(name, failed.config)
+		status.Status = LoggerStatusFailed
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Healthy 所有日志记录器是否都正常写入
+func (m *LoggerManager) Healthy() bool {
+	for _, status := range m.Status() {
+		if status.Status != LoggerStatusOK {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unwritableDir 返回一个无法创建的日志目录：父路径是普通文件。
+// 测试可能以root运行，chmod无法让目录变为只读，因此用这种方式模拟不可写的目录
+func unwritableDir(t *testing.T) string {
+	t.Helper()
+	parent := filepath.Join(t.TempDir(), "readonly")
+	if err := os.WriteFile(parent, nil, 0444); err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	return filepath.Join(parent, "logs")
+}
+
+func testFileConfig(name, dir string) OutputConfig {
+	return OutputConfig{
+		Name:    name,
+		Driver:  DriverFile,
+		Enabled: true,
+		Type:    FormatterJSON,
+		File:    "access.log",
+		Dir:     dir,
+		Period:  PeriodDay,
+		Formatter: FormatterConfig{Fields: map[string][]string{
+			"fields": {"$request_id"},
+		}},
+	}
+}
+
+func findStatus(t *testing.T, m *LoggerManager, name string) LoggerStatus {
+	t.Helper()
+	for _, status := range m.Status() {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("状态中缺少日志记录器%s: %+v", name, m.Status())
+	return LoggerStatus{}
+}
+
+func TestLoggerStatusInitFailure(t *testing.T) {
+	m := NewLoggerManager()
+	defer m.Close()
+
+	if err := m.AddLogger("default", testFileConfig("default", unwritableDir(t))); err == nil {
+		t.Fatal("日志目录不可写时应返回错误")
+	}
+	if err := m.AddLogger("stdout", OutputConfig{Name: "stdout", Driver: DriverStdout, Type: FormatterJSON}); err != nil {
+		t.Fatalf("添加stdout日志记录器失败: %v", err)
+	}
+
+	status := findStatus(t, m, "default")
+	if status.Status != LoggerStatusFailed || status.Driver != DriverFile {
+		t.Errorf("初始化失败的日志记录器状态 = %+v", status)
+	}
+	if !strings.Contains(status.LastError, "日志目录") || status.LastErrorAt == nil {
+		t.Errorf("应记录初始化失败的原因: %+v", status)
+	}
+	if status.Dropped != 0 {
+		t.Errorf("初始化失败本身不应计入丢弃数，dropped = %d", status.Dropped)
+	}
+	if m.Healthy() {
+		t.Error("存在初始化失败的日志记录器时Healthy应为false")
+	}
+
+	for i := 0; i < 3; i++ {
+		m.LogToAll(RequestLogData{RequestID: "r"})
+	}
+	if status := findStatus(t, m, "default"); status.Dropped != 3 {
+		t.Errorf("初始化失败后收到的日志应计入丢弃数，dropped = %d, want 3", status.Dropped)
+	}
+	if status := findStatus(t, m, "stdout"); status.Status != LoggerStatusOK {
+		t.Errorf("其他日志记录器不受影响: %+v", status)
+	}
+
+	// 目录恢复后重新添加，状态恢复正常
+	if err := m.AddLogger("default", testFileConfig("default", t.TempDir())); err != nil {
+		t.Fatalf("重新添加日志记录器失败: %v", err)
+	}
+	if status := findStatus(t, m, "default"); status.Status != LoggerStatusOK || status.Dropped != 0 {
+		t.Errorf("重新添加后的状态 = %+v", status)
+	}
+	if names := m.ListLoggers(); len(names) != 2 {
+		t.Errorf("ListLoggers() = %v", names)
+	}
+}
+
+func TestLoggerStatusWriteFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	m := NewLoggerManager()
+	defer m.Close()
+	if err := m.AddLogger("default", testFileConfig("default", dir)); err != nil {
+		t.Fatalf("添加日志记录器失败: %v", err)
+	}
+	l, _ := m.GetLogger("default")
+	output := l.output.(*FileOutput)
+
+	// 运行中日志目录变为不可写：关闭当前文件并用普通文件替换目录，之后重新打开也会失败
+	output.mutex.Lock()
+	output.currentFile.Close()
+	output.mutex.Unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("删除日志目录失败: %v", err)
+	}
+	if err := os.WriteFile(dir, nil, 0444); err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := l.LogRequest(RequestLogData{RequestID: "dropped"}); err == nil {
+			t.Fatal("日志目录不可写时写入应失败")
+		}
+	}
+	status := findStatus(t, m, "default")
+	if status.Status != LoggerStatusDegraded || status.Dropped != 5 {
+		t.Errorf("写入失败后的状态 = %+v, want degraded且dropped=5", status)
+	}
+	if status.LastError == "" || status.LastErrorAt == nil {
+		t.Errorf("应记录最近一次写入错误: %+v", status)
+	}
+
+	// 目录恢复可写后自动重新打开文件
+	if err := os.Remove(dir); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建日志目录失败: %v", err)
+	}
+	if err := l.LogRequest(RequestLogData{RequestID: "recovered"}); err != nil {
+		t.Fatalf("目录恢复后写入失败: %v", err)
+	}
+	status = findStatus(t, m, "default")
+	if status.Status != LoggerStatusOK || status.Dropped != 5 {
+		t.Errorf("恢复后的状态 = %+v, want ok且保留累计的dropped=5", status)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "access.log"))
+	if err != nil || !strings.Contains(string(data), "recovered") {
+		t.Errorf("恢复后的日志应写入文件: %q %v", data, err)
+	}
+}
@@ -0,0 +1,181 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPOutput 将日志批量POST到外部采集器的输出器
+type HTTPOutput struct {
+	config       OutputConfig
+	client       *http.Client
+	queue        chan []byte
+	done         chan struct{}
+	wg           sync.WaitGroup
+	closeOnce    sync.Once
+	dropped      atomic.Int64
+	retryBackoff time.Duration // 首次重试等待时间，之后指数递增
+}
+
+// NewHTTPOutput 创建HTTP输出器
+func NewHTTPOutput(config OutputConfig) (*HTTPOutput, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("HTTP输出器的url不能为空")
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	output := &HTTPOutput{
+		config:       config,
+		client:       &http.Client{Timeout: config.Timeout},
+		queue:        make(chan []byte, config.QueueSize),
+		done:         make(chan struct{}),
+		retryBackoff: 500 * time.Millisecond,
+	}
+
+	output.wg.Add(1)
+	go output.run()
+
+	return output, nil
+}
+
+// Write 将日志加入发送队列，队列已满时丢弃并计数
+func (h *HTTPOutput) Write(data []byte) error {
+	select {
+	case <-h.done:
+		return fmt.Errorf("HTTP输出器已关闭")
+	default:
+	}
+
+	entry := make([]byte, len(data))
+	copy(entry, data)
+	select {
+	case h.queue <- entry:
+	default:
+		h.dropped.Add(1)
+	}
+	return nil
+}
+
+// Dropped 返回因队列已满被丢弃的日志条数
+func (h *HTTPOutput) Dropped() int64 {
+	return h.dropped.Load()
+}
+
+// Close 关闭输出器，发送队列中剩余的日志
+func (h *HTTPOutput) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
+	h.wg.Wait()
+	return nil
+}
+
+// run 按批量大小或时间间隔发送日志
+func (h *HTTPOutput) run() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, h.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := h.send(batch); err != nil {
+			fmt.Printf("发送日志到 %s 失败: %v\n", h.config.URL, err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-h.queue:
+			batch = append(batch, entry)
+			if len(batch) >= h.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-h.done:
+			// 发送剩余日志后退出
+			for {
+				select {
+				case entry := <-h.queue:
+					batch = append(batch, entry)
+					if len(batch) >= h.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send 发送一批日志，5xx、429和网络错误按指数退避重试
+func (h *HTTPOutput) send(batch [][]byte) error {
+	body := bytes.Join(batch, nil)
+	contentType := "text/plain; charset=utf-8"
+	if h.config.Type == FormatterJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	var lastErr error
+	backoff := h.retryBackoff
+	for attempt := 0; attempt <= h.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodPost, h.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("创建请求失败: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		if h.config.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+h.config.Token)
+		}
+
+		resp, err := h.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("采集器返回状态码 %d", resp.StatusCode)
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			// 客户端错误重试也不会成功
+			return lastErr
+		}
+	}
+
+	return fmt.Errorf("重试%d次后仍失败: %w", h.config.MaxRetries, lastErr)
+}
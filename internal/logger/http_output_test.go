@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCollector 记录收到的每批日志，前failures次请求返回500
+type testCollector struct {
+	mutex    sync.Mutex
+	failures int
+	requests int
+	batches  [][]string
+	auth     string
+}
+
+func (tc *testCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	tc.requests++
+	tc.auth = r.Header.Get("Authorization")
+	if tc.requests <= tc.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var lines []string
+	for _, line := range bytes.Split(bytes.TrimSpace(body), []byte("\n")) {
+		lines = append(lines, string(line))
+	}
+	tc.batches = append(tc.batches, lines)
+}
+
+func newTestHTTPOutput(t *testing.T, collector *testCollector, batchSize int) *HTTPOutput {
+	t.Helper()
+
+	server := httptest.NewServer(collector)
+	t.Cleanup(server.Close)
+
+	output, err := NewHTTPOutput(OutputConfig{
+		Driver:        DriverHTTP,
+		Type:          FormatterJSON,
+		URL:           server.URL,
+		Token:         "secret",
+		BatchSize:     batchSize,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("创建HTTP输出器失败: %v", err)
+	}
+	output.retryBackoff = time.Millisecond
+	return output
+}
+
+func TestHTTPOutputBatching(t *testing.T) {
+	collector := &testCollector{}
+	output := newTestHTTPOutput(t, collector, 2)
+
+	for _, entry := range []string{"a", "b", "c", "d", "e"} {
+		if err := output.Write([]byte(entry + "\n")); err != nil {
+			t.Fatalf("写入日志失败: %v", err)
+		}
+	}
+	// 关闭时发送剩余不足一批的日志
+	output.Close()
+
+	if len(collector.batches) != 3 {
+		t.Fatalf("期望3批日志，实际得到%d批: %v", len(collector.batches), collector.batches)
+	}
+	if len(collector.batches[0]) != 2 || len(collector.batches[2]) != 1 {
+		t.Errorf("批量大小不正确: %v", collector.batches)
+	}
+	if collector.auth != "Bearer secret" {
+		t.Errorf("期望携带Bearer Token，实际得到%q", collector.auth)
+	}
+}
+
+func TestHTTPOutputRetryOn500(t *testing.T) {
+	collector := &testCollector{failures: 2}
+	output := newTestHTTPOutput(t, collector, 3)
+
+	for _, entry := range []string{"a", "b", "c"} {
+		output.Write([]byte(entry + "\n"))
+	}
+	output.Close()
+
+	if collector.requests != 3 {
+		t.Errorf("期望重试后共请求3次，实际请求%d次", collector.requests)
+	}
+	if len(collector.batches) != 1 || len(collector.batches[0]) != 3 {
+		t.Errorf("重试后应完整送达一批日志，实际得到%v", collector.batches)
+	}
+}
+
+func TestHTTPOutputDropsWhenQueueFull(t *testing.T) {
+	output := &HTTPOutput{queue: make(chan []byte, 1), done: make(chan struct{})}
+	output.Write([]byte("a\n"))
+	output.Write([]byte("b\n"))
+	if output.Dropped() != 1 {
+		t.Errorf("期望丢弃1条日志，实际丢弃%d条", output.Dropped())
+	}
+}
+
+func TestNonFileDriverLogFiles(t *testing.T) {
+	logger, err := NewRequestLogger(OutputConfig{Name: "stdout", Driver: DriverStdout, Type: FormatterJSON})
+	if err != nil {
+		t.Fatalf("创建stdout日志记录器失败: %v", err)
+	}
+	defer logger.Close()
+
+	if _, err := logger.GetLogFiles(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("期望返回不支持错误，实际得到%v", err)
+	}
+	if _, err := logger.ReadLogFile("access.log", 0, 10); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("期望返回不支持错误，实际得到%v", err)
+	}
+}
@@ -1,16 +1,25 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
+// ErrNotSupported 输出驱动不支持该操作
+var ErrNotSupported = errors.New("不支持该操作")
+
+// ErrLogFileNotFound 日志目录中没有指定的日志文件
+var ErrLogFileNotFound = errors.New("日志文件不存在")
+
 // RequestLogger 请求日志记录器
 type RequestLogger struct {
 	formatter Formatter
 	output    Output
 	config    OutputConfig
+	bodies    *bodyFilter
+	health    loggerHealth
 	mutex     sync.RWMutex
 	enabled   bool
 }
@@ -19,32 +28,59 @@ type RequestLogger struct {
 func NewRequestLogger(config OutputConfig) (*RequestLogger, error) {
 	// 创建格式化器
 	var formatter Formatter
+	formatterConfig := config.Formatter
+	formatterConfig.BodyOnErrorOnly = config.BodyOnErrorOnly
 
 	switch config.Type {
 	case FormatterJSON:
-		formatter = NewJSONFormatter(config.Formatter)
+		formatter = NewJSONFormatter(formatterConfig)
 	case FormatterLine:
-		formatter = NewLineFormatter(config.Formatter)
+		formatter = NewLineFormatter(formatterConfig)
 	default:
 		return nil, fmt.Errorf("不支持的格式化器类型: %s", config.Type)
 	}
 
 	// 创建输出器
-	output, err := NewFileOutput(config)
+	output, err := NewOutput(config)
 	if err != nil {
-		return nil, fmt.Errorf("创建文件输出器失败: %w", err)
+		return nil, err
 	}
 
 	logger := &RequestLogger{
 		formatter: formatter,
 		output:    output,
 		config:    config,
+		bodies:    newBodyFilter(config.BodyPolicy(), time.Now().UnixNano()),
 		enabled:   true,
 	}
 
 	return logger, nil
 }
 
+// NewOutput 根据驱动类型创建输出器，未指定驱动时使用文件输出
+func NewOutput(config OutputConfig) (Output, error) {
+	switch config.Driver {
+	case "", DriverFile:
+		output, err := NewFileOutput(config)
+		if err != nil {
+			return nil, fmt.Errorf("创建文件输出器失败: %w", err)
+		}
+		return output, nil
+	case DriverHTTP:
+		output, err := NewHTTPOutput(config)
+		if err != nil {
+			return nil, fmt.Errorf("创建HTTP输出器失败: %w", err)
+		}
+		return output, nil
+	case DriverStdout:
+		return NewStdoutOutput(), nil
+	case DriverMemory:
+		return NewMemoryOutput(config.BufferSize, config.BufferBytes), nil
+	default:
+		return nil, fmt.Errorf("不支持的输出驱动: %s", config.Driver)
+	}
+}
+
 // LogRequest 记录请求日志
 func (l *RequestLogger) LogRequest(data RequestLogData) error {
 	l.mutex.RLock()
@@ -60,8 +96,24 @@ func (l *RequestLogger) LogRequest(data RequestLogData) error {
 		data.Timestamp = time.Now()
 	}
 
+	// 按body策略裁剪请求/响应体
+	l.bodies.apply(&data)
+
+	if err := l.write(&data); err != nil {
+		// 连续失败时只在第一次打印，之后只计入丢弃数
+		if l.health.fail(err) {
+			fmt.Printf("日志记录器 %s 写入失败，恢复前的日志将被丢弃: %v\n", l.config.Name, err)
+		}
+		return err
+	}
+	l.health.succeed()
+	return nil
+}
+
+// write 格式化并输出一条日志
+func (l *RequestLogger) write(data *RequestLogData) error {
 	// 格式化数据
-	formatted, err := l.formatter.Format(&data)
+	formatted, err := l.formatter.Format(data)
 	if err != nil {
 		return fmt.Errorf("格式化日志数据失败: %w", err)
 	}
@@ -122,7 +174,7 @@ func (l *RequestLogger) GetLogFiles() ([]LogFileInfo, error) {
 	if fileOutput, ok := l.output.(*FileOutput); ok {
 		return fileOutput.GetLogFiles()
 	}
-	return nil, fmt.Errorf("输出器不支持文件列表功能")
+	return nil, fmt.Errorf("%w: %s驱动不提供日志文件列表", ErrNotSupported, l.config.Driver)
 }
 
 // ReadLogFile 读取日志文件内容
@@ -130,12 +182,13 @@ func (l *RequestLogger) ReadLogFile(filename string, offset int64, limit int64)
 	if fileOutput, ok := l.output.(*FileOutput); ok {
 		return fileOutput.ReadLogFile(filename, offset, limit)
 	}
-	return nil, fmt.Errorf("输出器不支持文件读取功能")
+	return nil, fmt.Errorf("%w: %s驱动不提供日志文件读取", ErrNotSupported, l.config.Driver)
 }
 
 // LoggerManager 日志管理器
 type LoggerManager struct {
 	loggers map[string]*RequestLogger
+	failed  map[string]*failedLogger // 初始化失败的日志记录器
 	mutex   sync.RWMutex
 }
 
@@ -143,6 +196,7 @@ type LoggerManager struct {
 func NewLoggerManager() *LoggerManager {
 	return &LoggerManager{
 		loggers: make(map[string]*RequestLogger),
+		failed:  make(map[string]*failedLogger),
 	}
 }
 
@@ -159,9 +213,13 @@ func (m *LoggerManager) AddLogger(name string, config OutputConfig) error {
 	// 创建新的日志记录器
 	logger, err := NewRequestLogger(config)
 	if err != nil {
+		// 保留失败的记录器以便查询状态，之后的日志计入丢弃数
+		delete(m.loggers, name)
+		m.failed[name] = newFailedLogger(config, err)
 		return fmt.Errorf("创建日志记录器失败: %w", err)
 	}
 
+	delete(m.failed, name)
 	m.loggers[name] = logger
 	return nil
 }
@@ -186,6 +244,7 @@ func (m *LoggerManager) RemoveLogger(name string) error {
 		}
 		delete(m.loggers, name)
 	}
+	delete(m.failed, name)
 
 	return nil
 }
@@ -203,6 +262,29 @@ func (m *LoggerManager) ListLoggers() []string {
 	return names
 }
 
+// GetAllLogFiles 返回所有日志记录器的日志文件列表，按日志记录器名称分组；
+// 不提供日志文件的驱动（如http、stdout）和初始化失败的日志记录器不包含在结果中
+func (m *LoggerManager) GetAllLogFiles() (map[string][]LogFileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make(map[string][]LogFileInfo, len(m.loggers))
+	for name, logger := range m.loggers {
+		files, err := logger.GetLogFiles()
+		if errors.Is(err, ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("日志记录器%s: %w", name, err)
+		}
+		if files == nil {
+			files = []LogFileInfo{}
+		}
+		result[name] = files
+	}
+	return result, nil
+}
+
 // LogToAll 向所有启用的日志记录器记录日志
 func (m *LoggerManager) LogToAll(data RequestLogData) {
 	m.mutex.RLock()
@@ -210,14 +292,15 @@ func (m *LoggerManager) LogToAll(data RequestLogData) {
 
 	for _, logger := range m.loggers {
 		if logger.IsEnabled() {
-			// 异步记录，避免阻塞
+			// 异步记录，避免阻塞；写入失败由记录器计入丢弃数
 			go func(l *RequestLogger) {
-				if err := l.LogRequest(data); err != nil {
-					fmt.Printf("记录日志失败: %v\n", err)
-				}
+				_ = l.LogRequest(data)
 			}(logger)
 		}
 	}
+	for _, failed := range m.failed {
+		failed.health.drop()
+	}
 }
 
 // Close 关闭所有日志记录器
@@ -235,6 +318,7 @@ func (m *LoggerManager) Close() error {
 
 	// 清空映射
 	m.loggers = make(map[string]*RequestLogger)
+	m.failed = make(map[string]*failedLogger)
 
 	return lastErr
 }
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内存日志的容量限制
+const (
+	DefaultMemoryBufferSize  = 1000             // 未设置buffer_size时保留的记录数
+	MaxMemoryBufferSize      = 100000           // 允许设置的最大记录数
+	DefaultMemoryBufferBytes = 8 * 1024 * 1024  // 未设置buffer_bytes时保留的记录最多占用的字节数
+	MaxMemoryBufferBytes     = 64 * 1024 * 1024 // 允许设置的最大字节数
+	MaxMemoryRecordBytes     = 64 * 1024        // 每条记录最多保存的字节数，超出部分截断
+)
+
+// MemoryRecord 内存日志中的一条记录
+type MemoryRecord struct {
+	Seq       uint64    `json:"seq"`       // 记录序号，从1开始递增，可用于增量获取
+	Time      time.Time `json:"time"`      // 写入时间
+	Content   string    `json:"content"`   // 格式化后的记录，不含换行符
+	Truncated bool      `json:"truncated"` // 记录超过MaxMemoryRecordBytes或buffer_bytes被截断
+}
+
+// MemoryOutput 内存输出器，在环形缓冲区中保留最近的记录，不写磁盘；
+// 记录数不超过buffer_size，记录内容的总字节数不超过buffer_bytes，超出任一限制时丢弃最早的记录
+type MemoryOutput struct {
+	mutex    sync.RWMutex
+	records  []MemoryRecord
+	start    int    // 最早的记录的位置
+	count    int    // 保留的记录数
+	bytes    int    // 保留的记录内容的总字节数
+	maxBytes int    // 记录内容总字节数的上限
+	seq      uint64 // 已写入的记录数
+}
+
+// NewMemoryOutput 创建内存输出器，size<=0时使用DefaultMemoryBufferSize，maxBytes<=0时使用DefaultMemoryBufferBytes
+func NewMemoryOutput(size, maxBytes int) *MemoryOutput {
+	if size <= 0 {
+		size = DefaultMemoryBufferSize
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultMemoryBufferBytes
+	}
+	return &MemoryOutput{records: make([]MemoryRecord, size), maxBytes: maxBytes}
+}
+
+// Write 写入日志数据，记录数或总字节数超出限制时丢弃最早的记录
+func (m *MemoryOutput) Write(data []byte) error {
+	data = bytes.TrimRight(data, "\r\n")
+	limit := MaxMemoryRecordBytes
+	if m.maxBytes < limit {
+		limit = m.maxBytes
+	}
+	truncated := len(data) > limit
+	if truncated {
+		data = data[:limit]
+	}
+	content := string(data)
+	if truncated {
+		// 截断处可能位于多字节字符中间，去掉不完整的字符
+		content = strings.ToValidUTF8(content, "")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for m.count > 0 && (m.count == len(m.records) || m.bytes+len(content) > m.maxBytes) {
+		m.bytes -= len(m.records[m.start].Content)
+		m.records[m.start] = MemoryRecord{}
+		m.start = (m.start + 1) % len(m.records)
+		m.count--
+	}
+	m.seq++
+	m.records[(m.start+m.count)%len(m.records)] = MemoryRecord{Seq: m.seq, Time: time.Now(), Content: content, Truncated: truncated}
+	m.count++
+	m.bytes += len(content)
+	return nil
+}
+
+// Recent 返回序号大于after的最近limit条记录，从新到旧排列；limit<=0时返回全部
+func (m *MemoryOutput) Recent(after uint64, limit int) []MemoryRecord {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if limit <= 0 || limit > m.count {
+		limit = m.count
+	}
+	result := make([]MemoryRecord, 0, limit)
+	for i := m.count - 1; i >= 0 && len(result) < limit; i-- {
+		record := m.records[(m.start+i)%len(m.records)]
+		if record.Seq <= after {
+			break
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+// Stats 返回缓冲区容量和已写入的记录总数
+func (m *MemoryOutput) Stats() (capacity int, total uint64) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.records), m.seq
+}
+
+// Close 关闭输出器，保留的记录随之释放
+func (m *MemoryOutput) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i := range m.records {
+		m.records[i] = MemoryRecord{}
+	}
+	m.start, m.count, m.bytes = 0, 0, 0
+	return nil
+}
+
+// RecentRecords 内存日志最近的记录
+type RecentRecords struct {
+	Logger   string         `json:"logger"`
+	Capacity int            `json:"capacity"` // 最多保留的记录数
+	Total    uint64         `json:"total"`    // 启动以来写入的记录总数
+	Records  []MemoryRecord `json:"records"`  // 从新到旧排列
+}
+
+// Recent 返回内存日志中序号大于after的最近limit条记录，其他驱动返回ErrNotSupported
+func (l *RequestLogger) Recent(after uint64, limit int) (*RecentRecords, error) {
+	memoryOutput, ok := l.output.(*MemoryOutput)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s驱动不保留最近的记录", ErrNotSupported, l.config.Driver)
+	}
+	capacity, total := memoryOutput.Stats()
+	return &RecentRecords{
+		Logger:   l.config.Name,
+		Capacity: capacity,
+		Total:    total,
+		Records:  memoryOutput.Recent(after, limit),
+	}, nil
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMemoryOutput(t *testing.T) {
+	output := NewMemoryOutput(3, 0)
+	for i := 1; i <= 5; i++ {
+		if err := output.Write([]byte("record-" + strconv.Itoa(i) + "\n")); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+	}
+
+	// 缓冲区满后覆盖最早的记录，从新到旧返回
+	var got []string
+	for _, record := range output.Recent(0, 0) {
+		got = append(got, strconv.FormatUint(record.Seq, 10)+":"+record.Content)
+	}
+	if strings.Join(got, ",") != "5:record-5,4:record-4,3:record-3" {
+		t.Errorf("最近的记录 = %v", got)
+	}
+	if records := output.Recent(0, 1); len(records) != 1 || records[0].Seq != 5 {
+		t.Errorf("limit=1 = %+v", records)
+	}
+	if records := output.Recent(4, 0); len(records) != 1 || records[0].Seq != 5 {
+		t.Errorf("after=4 = %+v", records)
+	}
+	if capacity, total := output.Stats(); capacity != 3 || total != 5 {
+		t.Errorf("Stats() = %d, %d, want 3, 5", capacity, total)
+	}
+
+	// 超长的记录截断，不保留不完整的多字节字符
+	output.Write([]byte(strings.Repeat("a", MaxMemoryRecordBytes-1) + "日志\n"))
+	if record := output.Recent(0, 1)[0]; !record.Truncated || len(record.Content) != MaxMemoryRecordBytes-1 {
+		t.Errorf("截断后长度 = %d, truncated = %v", len(record.Content), record.Truncated)
+	}
+}
+
+func TestMemoryOutputByteLimit(t *testing.T) {
+	output := NewMemoryOutput(100, 10)
+	for _, record := range []string{"aaaa", "bbbb", "cccc"} {
+		output.Write([]byte(record + "\n"))
+	}
+	// 总字节数超过buffer_bytes时丢弃最早的记录，即使记录数未满
+	var got []string
+	for _, record := range output.Recent(0, 0) {
+		got = append(got, record.Content)
+	}
+	if strings.Join(got, ",") != "cccc,bbbb" {
+		t.Errorf("最近的记录 = %v", got)
+	}
+
+	// 单条记录超过buffer_bytes时截断到buffer_bytes
+	output.Write([]byte(strings.Repeat("d", 20)))
+	records := output.Recent(0, 0)
+	if len(records) != 1 || records[0].Content != strings.Repeat("d", 10) || !records[0].Truncated {
+		t.Errorf("超过buffer_bytes的记录 = %+v", records)
+	}
+	if capacity, total := output.Stats(); capacity != 100 || total != 4 {
+		t.Errorf("Stats() = %d, %d, want 100, 4", capacity, total)
+	}
+}
+
+func TestMemoryOutputConcurrent(t *testing.T) {
+	output := NewMemoryOutput(0, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				output.Write([]byte("x\n"))
+				output.Recent(0, 10)
+			}
+		}()
+	}
+	wg.Wait()
+	if capacity, total := output.Stats(); capacity != DefaultMemoryBufferSize || total != 4000 {
+		t.Errorf("Stats() = %d, %d", capacity, total)
+	}
+	if records := output.Recent(0, 0); len(records) != DefaultMemoryBufferSize || records[0].Seq != 4000 {
+		t.Errorf("应保留最近%d条记录，实际%d条", DefaultMemoryBufferSize, len(records))
+	}
+}
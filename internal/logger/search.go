@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// 日志搜索的结果数和时间范围限制
+const (
+	DefaultSearchLimit  = 100                // 未指定时最多返回的匹配行数
+	MaxSearchLimit      = 1000               // 允许指定的最大匹配行数
+	DefaultSearchWindow = 24 * time.Hour     // 未指定from时向前搜索的时长
+	MaxSearchWindow     = 7 * 24 * time.Hour // 一次搜索允许的最大时间范围
+)
+
+// searchFieldPattern 字段相等查询的格式，如request_id=abc、default.model_id=gpt-4o
+var searchFieldPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)=(.*)$`)
+
+// SearchQuery 日志搜索的条件
+type SearchQuery struct {
+	Text  string    // 查询内容，field=value形式按字段相等匹配JSON日志，其他按子串匹配
+	From  time.Time // 起始时间（包含）
+	To    time.Time // 结束时间（不包含）
+	Limit int       // 最多返回的匹配行数
+}
+
+// SearchMatch 一条匹配的日志行
+type SearchMatch struct {
+	File    string `json:"file"`    // 日志文件名
+	Line    int    `json:"line"`    // 行号，从1开始
+	Offset  int64  `json:"offset"`  // 行首在文件（压缩文件为解压后的内容）中的字节偏移，可用于读取日志文件
+	Content string `json:"content"` // 日志行内容，不含换行符
+}
+
+// SearchResult 日志搜索的结果
+type SearchResult struct {
+	Matches   []SearchMatch `json:"matches"`
+	Files     []string      `json:"files"`     // 扫描过的日志文件，按从新到旧排列
+	Truncated bool          `json:"truncated"` // 达到结果数上限，可能还有未返回的匹配
+}
+
+// logMatcher 判断一行日志是否匹配查询
+type logMatcher func(line []byte) bool
+
+// newLogMatcher 按查询内容创建匹配函数，字段相等查询先在JSON日志的顶层查找字段，
+// 再在各分组中查找；不是JSON的行按子串匹配整个查询
+func newLogMatcher(text string) logMatcher {
+	substring := []byte(text)
+	parts := searchFieldPattern.FindStringSubmatch(text)
+	if parts == nil {
+		return func(line []byte) bool {
+			return bytes.Contains(line, substring)
+		}
+	}
+	field, value := parts[1], parts[2]
+	return func(line []byte) bool {
+		if !gjson.ValidBytes(line) {
+			return bytes.Contains(line, substring)
+		}
+		parsed := gjson.ParseBytes(line)
+		if result := parsed.Get(field); result.Exists() {
+			return result.String() == value
+		}
+		matched := false
+		parsed.ForEach(func(_, group gjson.Result) bool {
+			if group.IsObject() {
+				if result := group.Get(field); result.Exists() && result.String() == value {
+					matched = true
+				}
+			}
+			return !matched
+		})
+		return matched
+	}
+}
+
+// SearchLogs 在文件日志中搜索匹配的行，从新到旧扫描与时间范围重叠的日志文件（当前文件最先扫描），
+// 达到query.Limit后停止；压缩的轮转文件会被解压扫描。记录了时间字段的JSON日志与导出相同，
+// 逐行按时间范围筛选，无法解析时间的行被跳过；其他格式的日志只按文件的周期筛选
+func SearchLogs(config OutputConfig, query SearchQuery) (*SearchResult, error) {
+	if config.Driver != DriverFile {
+		return nil, fmt.Errorf("%w: %s驱动不提供日志搜索", ErrNotSupported, config.Driver)
+	}
+	if query.Text == "" {
+		return nil, fmt.Errorf("搜索内容不能为空")
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	files, err := logFilesInRange(config, query.From, query.To)
+	if err != nil {
+		return nil, err
+	}
+
+	match := newLogMatcher(query.Text)
+	if exporter, err := NewLogExporter(config); err == nil {
+		match = exporter.inRange(match, query.From, query.To)
+	}
+	result := &SearchResult{Matches: []SearchMatch{}, Files: []string{}}
+	for i := len(files) - 1; i >= 0 && !result.Truncated; i-- {
+		if err := searchFile(files[i], match, query.Limit, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// inRange 在match之后检查日志行的时间是否在[from, to)内，只解析匹配的行
+func (e *LogExporter) inRange(match logMatcher, from, to time.Time) logMatcher {
+	return func(line []byte) bool {
+		if !match(line) {
+			return false
+		}
+		values, ok := e.parseLine(line)
+		if !ok {
+			return false
+		}
+		timestamp, ok := exportTime(e.columns[e.timeCol].pattern, values[e.timeCol])
+		return ok && !timestamp.Before(from) && timestamp.Before(to)
+	}
+}
+
+// searchFile 扫描一个日志文件，将匹配的行追加到result，达到limit时标记结果被截断
+func searchFile(path string, match logMatcher, limit int, result *SearchResult) error {
+	file, err := openLogFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 扫描期间文件被轮转或清理
+			return nil
+		}
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	defer file.Close()
+
+	name := filepath.Base(path)
+	result.Files = append(result.Files, name)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+	scanner.Split(scanRawLines)
+	var offset int64
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Bytes()
+		start := offset
+		offset += int64(len(raw)) + 1
+		line := bytes.TrimRight(raw, "\r")
+		if len(line) == 0 || !match(line) {
+			continue
+		}
+		if len(result.Matches) >= limit {
+			result.Truncated = true
+			return nil
+		}
+		result.Matches = append(result.Matches, SearchMatch{File: name, Line: lineNo, Offset: start, Content: string(line)})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取日志文件%s失败: %w", name, err)
+	}
+	return nil
+}
+
+// scanRawLines 按换行符分割，与bufio.ScanLines不同的是保留行尾的\r，以便准确计算字节偏移
+func scanRawLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSearchLogsOffsetsAndCompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := OutputConfig{Name: "access", Driver: DriverFile, File: "access.log", Dir: dir, Period: PeriodHour}
+	now := time.Now()
+	rotated := "access-" + now.Add(-time.Hour).Format("2006010215") + ".log"
+	writeRotatedFile(t, dir, rotated, []byte("{\"id\":\"a\",\"default\":{\"request_id\":\"r1\"}}\n"), now)
+	if err := compressFile(filepath.Join(dir, rotated), now); err != nil {
+		t.Fatalf("压缩日志文件失败: %v", err)
+	}
+	writeRotatedFile(t, dir, "access.log", []byte("plain line\r\n\n{\"request_id\":\"r1\"}\r\nrequest_id=r1 in text\n"), now)
+
+	result, err := SearchLogs(config, SearchQuery{Text: "request_id=r1", From: now.Add(-2 * time.Hour), To: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("搜索日志失败: %v", err)
+	}
+	want := []SearchMatch{
+		{File: "access.log", Line: 3, Offset: 13, Content: `{"request_id":"r1"}`},
+		{File: "access.log", Line: 4, Offset: 34, Content: "request_id=r1 in text"},
+		{File: rotated + compressedSuffix, Line: 1, Offset: 0, Content: `{"id":"a","default":{"request_id":"r1"}}`},
+	}
+	if len(result.Matches) != len(want) {
+		t.Fatalf("匹配结果 = %+v, want %+v", result.Matches, want)
+	}
+	for i := range want {
+		if result.Matches[i] != want[i] {
+			t.Errorf("第%d条匹配 = %+v, want %+v", i, result.Matches[i], want[i])
+		}
+	}
+
+	// 偏移量可直接用于读取日志文件
+	output := &FileOutput{config: config}
+	data, err := output.ReadLogFile("access.log", want[0].Offset, int64(len(want[0].Content)))
+	if err != nil || string(data) != want[0].Content {
+		t.Errorf("按偏移读取 = %q, %v", data, err)
+	}
+
+	if _, err := SearchLogs(OutputConfig{Driver: DriverHTTP}, SearchQuery{Text: "x"}); err == nil {
+		t.Error("非文件日志应不支持搜索")
+	}
+}
+
+func TestSearchLogsTimeRange(t *testing.T) {
+	dir := t.TempDir()
+	config := OutputConfig{Name: "access", Driver: DriverFile, Type: FormatterJSON, File: "access.log", Dir: dir, Period: PeriodHour,
+		Formatter: FormatterConfig{Fields: map[string][]string{"fields": {"$time_iso8601", "$request_id"}}}}
+	line := func(at time.Time) string {
+		return `{"time_iso8601":"` + at.Format(time.RFC3339) + `","request_id":"r1"}`
+	}
+	now := time.Now()
+	past := now.Add(-3 * time.Hour)
+	start := time.Date(past.Year(), past.Month(), past.Day(), past.Hour(), 0, 0, 0, time.Local)
+	writeRotatedFile(t, dir, "access-"+start.Format("2006010215")+".log", []byte(line(start.Add(time.Minute))+"\n"), now)
+	writeRotatedFile(t, dir, "access.log", []byte(line(now.Add(-2*time.Hour))+"\n"+line(now)+"\n"), now)
+
+	// 时间范围在最新的轮转周期内时不扫描当前文件
+	result, err := SearchLogs(config, SearchQuery{Text: "request_id=r1", From: start, To: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("搜索日志失败: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Content != line(start.Add(time.Minute)) || len(result.Files) != 1 {
+		t.Errorf("过去时间范围的搜索结果 = %+v", result)
+	}
+
+	// 当前文件中时间不在范围内的行被跳过
+	result, err = SearchLogs(config, SearchQuery{Text: "request_id=r1", From: now.Add(-10 * time.Minute), To: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("搜索日志失败: %v", err)
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Line != 2 {
+		t.Errorf("最近时间范围的搜索结果 = %+v", result)
+	}
+}
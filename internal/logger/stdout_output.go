@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// StdoutOutput 标准输出输出器，适用于由容器平台采集日志的部署方式
+type StdoutOutput struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// NewStdoutOutput 创建标准输出输出器
+func NewStdoutOutput() *StdoutOutput {
+	return &StdoutOutput{writer: os.Stdout}
+}
+
+// Write 写入日志数据
+func (s *StdoutOutput) Write(data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.writer.Write(data); err != nil {
+		return fmt.Errorf("写入标准输出失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭输出器，标准输出无需关闭
+func (s *StdoutOutput) Close() error {
+	return nil
+}
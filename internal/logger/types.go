@@ -38,6 +38,14 @@ const (
 	FormatterLine FormatterType = "line"
 )
 
+// 输出驱动类型
+const (
+	DriverFile   = "file"
+	DriverHTTP   = "http"
+	DriverStdout = "stdout"
+	DriverMemory = "memory"
+)
+
 // Period 日志文件轮转周期
 type Period string
 
@@ -49,12 +57,13 @@ const (
 // RequestLogData 请求日志数据结构
 type RequestLogData struct {
 	// 基础信息
-	RequestID string    `json:"request_id"`
-	Timestamp time.Time `json:"timestamp"`
-	Method    string    `json:"method"`
-	Path      string    `json:"path"`
-	UserAgent string    `json:"user_agent"`
-	ClientIP  string    `json:"client_ip"`
+	RequestID  string    `json:"request_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	UserAgent  string    `json:"user_agent"`
+	ClientIP   string    `json:"client_ip"`   // 按可信代理规则解析出的客户端IP
+	RemoteAddr string    `json:"remote_addr"` // 直连对端的IP
 
 	// 认证信息
 	APIKey string `json:"api_key,omitempty"`
@@ -66,18 +75,22 @@ type RequestLogData struct {
 	Headers     map[string]string `json:"headers,omitempty"`
 
 	// 代理信息
-	ModelID       string `json:"model_id"`
-	TargetModel   string `json:"target_model"`
-	ProxyURL      string `json:"proxy_url"`
-	ProxyScheme   string `json:"proxy_scheme"`
-	ProxyHost     string `json:"proxy_host"`
-	UpstreamBody  string `json:"upstream_body,omitempty"`  // 发送给上游服务的body
+	ModelID      string `json:"model_id"`
+	TargetModel  string `json:"target_model"`
+	ProxyURL     string `json:"proxy_url"`
+	ProxyScheme  string `json:"proxy_scheme"`
+	ProxyHost    string `json:"proxy_host"`
+	UpstreamBody string `json:"upstream_body,omitempty"` // 发送给上游服务的body
 
 	// 响应信息
 	StatusCode   int    `json:"status_code"`
 	ResponseSize int64  `json:"response_size"`
-	ResponseTime int64  `json:"response_time_ms"` // 毫秒
-	ResponseBody string `json:"response_body,omitempty"`  // 响应body
+	ResponseTime int64  `json:"response_time_ms"`        // 毫秒
+	ResponseBody string `json:"response_body,omitempty"` // 响应body
+
+	ResponseBodyDropped int64 `json:"-"` // 代理保存响应body时已丢弃的字节数
+	// BodySample 请求开始时抽取的body采样值，见NewBodySample；为0时各记录器自行抽取
+	BodySample float64 `json:"-"`
 
 	// 错误信息
 	Error string `json:"error,omitempty"`
@@ -86,6 +99,22 @@ type RequestLogData struct {
 	Extra map[string]interface{} `json:"extra,omitempty"`
 }
 
+// 代理记录的各阶段耗时在Extra中的键，单位为毫秒，精确到微秒
+const (
+	ExtraInjectTime      = "inject_time_ms"      // inject阶段注入Prompt和tools的耗时
+	ExtraUpstreamConnect = "upstream_connect_ms" // 从开始请求上游到获得连接的耗时，复用连接时接近0
+	ExtraUpstreamTTFB    = "upstream_ttfb_ms"    // 从开始请求上游到收到响应第一个字节的耗时
+	ExtraUpstreamTime    = "upstream_time_ms"    // 从开始请求上游到响应转发完成的耗时
+)
+
+// ExtraPanicStack 处理请求时发生panic的调用栈（截断）在Extra中的键
+const ExtraPanicStack = "panic_stack"
+
+// Failed 请求是否出错：有错误信息或状态码>=400
+func (d *RequestLogData) Failed() bool {
+	return d.Error != "" || d.StatusCode >= 400
+}
+
 // OutputConfig 输出器配置
 type OutputConfig struct {
 	// 基础配置
@@ -100,14 +129,51 @@ type OutputConfig struct {
 	Period Period `json:"period" yaml:"period"`
 	Expire int    `json:"expire" yaml:"expire"` // 保留天数
 
+	Compress       bool `json:"compress" yaml:"compress"`                   // 轮转后的文件压缩为.log.gz
+	MaxTotalSizeMB int  `json:"max_total_size_mb" yaml:"max_total_size_mb"` // 日志文件总大小上限(MB)，超出时从最早的轮转文件开始删除，0表示不限制
+	HashChain      bool `json:"hash_chain" yaml:"hash_chain"`               // 每条记录包含上一条记录的哈希，修改或删除记录会使哈希链断开
+
+	// HTTP采集器配置
+	URL           string        `json:"url" yaml:"url"`                       // 采集器地址
+	Token         string        `json:"token" yaml:"token"`                   // Bearer Token
+	BatchSize     int           `json:"batch_size" yaml:"batch_size"`         // 每批最多条数，默认100
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"` // 批量发送间隔，默认1s
+	QueueSize     int           `json:"queue_size" yaml:"queue_size"`         // 发送队列容量，默认10000，队列满时丢弃
+	MaxRetries    int           `json:"max_retries" yaml:"max_retries"`       // 发送失败重试次数，默认3
+	Timeout       time.Duration `json:"timeout" yaml:"timeout"`               // 单次请求超时，默认10s
+
+	// 内存日志配置
+	BufferSize  int `json:"buffer_size" yaml:"buffer_size"`   // 保留的最近记录数，默认1000
+	BufferBytes int `json:"buffer_bytes" yaml:"buffer_bytes"` // 保留的记录最多占用的字节数，默认8MB
+
+	// body记录策略
+	MaxBodyBytes   int      `json:"max_body_bytes" yaml:"max_body_bytes"`     // 每个body最多记录的字节数，0表示不限制
+	BodySampleRate *float64 `json:"body_sample_rate" yaml:"body_sample_rate"` // 记录body的请求比例(0.0-1.0)，默认1，出错的请求总是记录
+	ExcludePaths   []string `json:"exclude_paths" yaml:"exclude_paths"`       // 不记录body的URL路径，支持*通配符
+
+	// BodyOnErrorOnly 只在出错的请求（有错误信息或状态码>=400）中记录body，成功请求的body替换为大小标记
+	BodyOnErrorOnly bool `json:"body_on_error_only" yaml:"body_on_error_only"`
+
 	// 格式化配置
 	Type      FormatterType   `json:"type" yaml:"type"`
 	Formatter FormatterConfig `json:"formatter" yaml:"formatter"`
 }
 
+// DefaultArraySeparator Line格式化器中数组字段元素之间的默认分隔符
+const DefaultArraySeparator = ","
+
 // FormatterConfig 格式化器配置
 type FormatterConfig struct {
 	Fields map[string][]string `json:"fields" yaml:"fields"`
+
+	// Constants 自定义字段，在fields中以$字段名引用，优先于同名的系统变量；
+	// 值为常量或内置值$hostname、$pid、$env（环境变量APP_ENV）
+	Constants map[string]string `json:"constants" yaml:"constants"`
+
+	// ArraySeparator Line格式化器中数组字段(#)元素之间的分隔符，默认为逗号
+	ArraySeparator string `json:"array_separator" yaml:"array_separator"`
+
+	BodyOnErrorOnly bool `json:"-" yaml:"-"` // 由日志记录器根据OutputConfig.BodyOnErrorOnly设置
 }
 
 // Logger 日志记录器接口
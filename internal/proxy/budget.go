@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// HeaderTimeoutBudget 客户端设置整体超时预算(毫秒)的请求头
+const HeaderTimeoutBudget = "X-Proxy-Timeout-Ms"
+
+// timeoutBudget 解析超时预算请求头，超过上限时取上限；无效值(非数字或不大于0)按上限处理，
+// 没有上限时忽略。limits中为0的上限不生效，返回0表示不设置预算
+func timeoutBudget(header string, limits ...time.Duration) time.Duration {
+	var max time.Duration
+	for _, limit := range limits {
+		if limit > 0 && (max == 0 || limit < max) {
+			max = limit
+		}
+	}
+
+	ms, err := strconv.ParseInt(strings.TrimSpace(header), 10, 64)
+	if err != nil || ms <= 0 || ms > math.MaxInt64/int64(time.Millisecond) {
+		return max
+	}
+	budget := time.Duration(ms) * time.Millisecond
+	if max > 0 && budget > max {
+		return max
+	}
+	return budget
+}
+
+// startTimeoutBudget 根据X-Proxy-Timeout-Ms请求头为排队和上游请求设置截止时间，
+// 返回的函数在请求处理结束时调用：预算耗尽且尚未写入响应时返回504
+func (s *Server) startTimeoutBudget(c *gin.Context, modelConfig *config.ModelConfig) func() {
+	header := c.GetHeader(HeaderTimeoutBudget)
+	if header == "" {
+		return func() {}
+	}
+
+	var globalMax time.Duration
+	if s.serverConfig != nil {
+		globalMax = s.serverConfig.Limits.MaxTimeoutBudget
+	}
+	budget := timeoutBudget(header, time.Duration(modelConfig.MaxTimeoutMs)*time.Millisecond, globalMax)
+	if budget <= 0 {
+		return func() {}
+	}
+	setLogExtra(c, "timeout_budget_ms", budget.Milliseconds())
+
+	parent := c.Request.Context()
+	ctx, cancel := context.WithTimeout(parent, budget)
+	c.Request = c.Request.WithContext(ctx)
+	start := time.Now()
+
+	return func() {
+		defer cancel()
+		// 外层的请求超时先到期时由RequestTimeoutMiddleware处理
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) || parent.Err() != nil {
+			return
+		}
+		elapsed := time.Since(start)
+		detail := fmt.Sprintf("%dms", budget.Milliseconds())
+		c.Set("error", i18n.Message(i18n.Default, i18n.CodeDeadlineExceeded, detail))
+		setErrorClass(c, stats.ErrorClassTimeout)
+		setLogExtra(c, "timeout_elapsed_ms", elapsed.Milliseconds())
+		if !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, errorBody(c, i18n.CodeDeadlineExceeded, "timeout_error", detail))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestTimeoutBudgetClamp(t *testing.T) {
+	tests := []struct {
+		header string
+		limits []time.Duration
+		want   time.Duration
+	}{
+		{"1500", nil, 1500 * time.Millisecond},
+		{"1500", []time.Duration{time.Second, 0}, time.Second},
+		{"1500", []time.Duration{0, 2 * time.Second}, 1500 * time.Millisecond},
+		{"90000", []time.Duration{time.Minute, 30 * time.Second}, 30 * time.Second},
+		{"abc", []time.Duration{time.Second}, time.Second},
+		{"-5", []time.Duration{time.Second}, time.Second},
+		{"0", nil, 0},
+		{"abc", nil, 0},
+		{"99999999999999999999", []time.Duration{time.Second}, time.Second},
+	}
+	for _, tt := range tests {
+		if got := timeoutBudget(tt.header, tt.limits...); got != tt.want {
+			t.Errorf("timeoutBudget(%q, %v) = %v, want %v", tt.header, tt.limits, got, tt.want)
+		}
+	}
+}
+
+func TestTimeoutBudgetReturns504(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"slow": {ID: "slow", Name: "Slow", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat, MaxTimeoutMs: 200},
+	}}
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"slow","messages":[]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+
+	// 请求的预算超过模型上限，按200ms处理
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimeoutBudget, "10000")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	r.ServeHTTP(w, req)
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("期望返回504，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	if elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("期望在预算200ms附近返回，实际耗时%v", elapsed)
+	}
+	var resp struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error.Code != "deadline_exceeded" {
+		t.Errorf("期望错误码deadline_exceeded，实际得到%s", w.Body.String())
+	}
+}
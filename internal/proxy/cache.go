@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// cachedResponse 缓存的上游响应
+type cachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ExpiresAt  time.Time
+}
+
+// cacheEntry LRU链表节点
+type cacheEntry struct {
+	key      string
+	response *cachedResponse
+}
+
+// ResponseCache 基于LRU的内存响应缓存
+type ResponseCache struct {
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	mutex      sync.Mutex
+}
+
+// NewResponseCache 创建响应缓存，maxEntries<=0时不缓存
+func NewResponseCache(maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 获取未过期的缓存响应
+func (c *ResponseCache) Get(key string) (*cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.response.ExpiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.response, true
+}
+
+// Set 写入缓存，超过容量时淘汰最久未使用的条目
+func (c *ResponseCache) Set(key string, response *cachedResponse) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*cacheEntry).response = response
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, response: response})
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Len 返回缓存条目数
+func (c *ResponseCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ll.Len()
+}
+
+// removeElement 删除缓存条目，调用方需持有锁
+func (c *ResponseCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
+
+// responseCacheKey 根据模型ID、请求方法、上游URL和发送给上游的body计算缓存键，
+// URL模板展开为不同上游URL（如按请求路径）的请求分别缓存
+func responseCacheKey(modelID, method, upstreamURL string, upstreamBody []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{modelID, method, upstreamURL} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(upstreamBody)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestResponseCacheLRU(t *testing.T) {
+	cache := NewResponseCache(2)
+	expires := time.Now().Add(time.Minute)
+	cache.Set("a", &cachedResponse{Body: []byte("a"), ExpiresAt: expires})
+	cache.Set("b", &cachedResponse{Body: []byte("b"), ExpiresAt: expires})
+
+	// 访问a后，b成为最久未使用的条目
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	cache.Set("c", &cachedResponse{Body: []byte("c"), ExpiresAt: expires})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b should be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("a should still be cached")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+
+	cache.Set("expired", &cachedResponse{ExpiresAt: time.Now().Add(-time.Second)})
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("expired entry should not be returned")
+	}
+}
+
+func TestProxyResponseCache(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), "fail") {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		w.Write([]byte(`{"id":"resp"}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"cached": {ID: "cached", Name: "cached", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat, CacheTTL: 60},
+	}}
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"model":"cached","messages":[{"role":"user","content":"hi"}]}`
+	if w := send(body); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("first request X-Cache = %q, want MISS", w.Header().Get("X-Cache"))
+	}
+	w := send(body)
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != `{"id":"resp"}` {
+		t.Errorf("second request X-Cache = %q, body = %s", w.Header().Get("X-Cache"), w.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", calls)
+	}
+
+	// 流式请求和非2xx响应不缓存
+	stream := `{"model":"cached","stream":true,"messages":[]}`
+	send(stream)
+	send(stream)
+	failed := `{"model":"cached","messages":[{"role":"user","content":"fail"}]}`
+	send(failed)
+	send(failed)
+	if calls != 5 {
+		t.Errorf("upstream calls = %d, want 5", calls)
+	}
+}
+
+func TestProxyResponseCacheKeyedByUpstreamURL(t *testing.T) {
+	var paths []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"sd": {ID: "sd", Name: "sd", Target: "sdxl", Url: upstream.URL + "/{path}", Type: config.ModelTypeChat, CacheTTL: 60},
+	}}
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	// 请求体相同但展开后的上游URL不同，不应命中对方的缓存
+	body := `{"model":"sd","prompt":"cat"}`
+	for _, path := range []string{"/sdapi/v1/txt2img", "/sdapi/v1/img2img", "/sdapi/v1/txt2img"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if !strings.Contains(w.Body.String(), path) {
+			t.Errorf("%s: 响应应来自对应的上游路径，实际%s", path, w.Body.String())
+		}
+	}
+	if len(paths) != 2 {
+		t.Errorf("期望上游收到2次请求，实际%v", paths)
+	}
+}
+
+func TestProxyResponseCacheSkipsIncompleteBody(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(string(body), "truncated") {
+			w.Write([]byte("{\n  \"id\": \"resp\"\n}\n"))
+			return
+		}
+		// 声明的长度大于实际发送的内容后断开连接，代理读取响应体时出错
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("{\n  \"id\""))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"cached": {ID: "cached", Name: "cached", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat, CacheTTL: 60},
+	}}
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 多行的响应体原样转发和缓存
+	body := `{"model":"cached","messages":[{"role":"user","content":"hi"}]}`
+	send(body)
+	if w := send(body); w.Header().Get("X-Cache") != "HIT" || w.Body.String() != "{\n  \"id\": \"resp\"\n}\n" {
+		t.Errorf("缓存的多行响应体 = %q, X-Cache = %q", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+
+	// 读取上游响应体失败时不缓存不完整的响应
+	truncated := `{"model":"cached","messages":[{"role":"user","content":"truncated"}]}`
+	send(truncated)
+	if w := send(truncated); w.Header().Get("X-Cache") == "HIT" {
+		t.Errorf("不完整的响应不应被缓存，实际返回%q", w.Body.String())
+	}
+	if calls != 3 {
+		t.Errorf("upstream calls = %d, want 3", calls)
+	}
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// HeaderCanaryUser 客户端的用户标识，带此请求头时同一用户的请求总是转发到灰度发布的同一目标
+const HeaderCanaryUser = "X-Proxy-User-ID"
+
+// 灰度发布的两个分组，记录到灰度统计
+const (
+	canaryArmPrimary = "primary" // 转发到target
+	canaryArmCanary  = "canary"  // 转发到canary_target
+)
+
+// canaryBucket 返回请求所在的桶[0,100)，桶号小于canary_percent的请求转发到灰度目标。
+// stickyKey不为空时按模型ID和stickyKey的哈希确定，比例调大时已在灰度中的用户保持不变；否则使用intn随机选择
+func canaryBucket(modelID, stickyKey string, intn func(n int) int) int {
+	if stickyKey == "" {
+		return intn(100)
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(modelID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(stickyKey))
+	return int(hash.Sum64() % 100)
+}
+
+// selectCanary 模型配置了灰度发布时决定本次请求是否转发到灰度目标，选中时将rc.Model替换为转发到灰度目标的配置，
+// 并在访问日志的$canary和灰度统计中记录所在的分组
+func (rc *RequestContext) selectCanary() {
+	model := rc.Model
+	arm := canaryArmPrimary
+	if canaryBucket(model.ID, rc.Gin.GetHeader(HeaderCanaryUser), rand.Intn) < model.CanaryPercent {
+		arm = canaryArmCanary
+		rc.Model = model.WithTarget(model.CanaryUpstream())
+		rc.Canary = true
+	}
+	rc.Gin.Set("canary_model_id", model.ID)
+	rc.Gin.Set("canary_arm", arm)
+	rc.SetLogExtra("canary", rc.Canary)
+}
+
+// SetCanaryTracker 设置灰度发布的统计器
+func (s *Server) SetCanaryTracker(tracker *stats.ExperimentTracker) {
+	s.canaries = tracker
+}
+
+// canaryTrackingMiddleware 请求结束后按模型和灰度分组记录请求数、失败数和响应时间
+func (s *Server) canaryTrackingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		arm := c.GetString("canary_arm")
+		if s.canaries == nil || arm == "" {
+			return
+		}
+		class, _ := classifyError(c)
+		s.canaries.Record(c.GetString("canary_model_id"), arm, class != "", time.Since(start))
+	}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+func newCanaryModel(percent int) *config.ModelConfig {
+	return &config.ModelConfig{
+		ID: "assistant-prod", Name: "助手", Target: "gpt-4o-mini", Url: "https://api.openai.com/v1/chat/completions",
+		Type: config.ModelTypeChat, CanaryTarget: "ft:gpt-4o-mini:assistant", CanaryPercent: percent,
+	}
+}
+
+func TestCanarySplit(t *testing.T) {
+	const draws = 20000
+	canary := 0
+	for i := 0; i < draws; i++ {
+		rc := newStageContext(newCanaryModel(25), `{}`)
+		rc.selectCanary()
+		if rc.Canary {
+			canary++
+			if rc.Model.Target != "ft:gpt-4o-mini:assistant" || rc.Model.Url != "https://api.openai.com/v1/chat/completions" {
+				t.Fatalf("灰度请求应转发到canary_target并使用模型的url，实际%s %s", rc.Model.Target, rc.Model.Url)
+			}
+		} else if rc.Model.Target != "gpt-4o-mini" {
+			t.Fatalf("其余请求应转发到target，实际%s", rc.Model.Target)
+		}
+	}
+	if got := float64(canary) / draws; math.Abs(got-0.25) > 0.02 {
+		t.Errorf("灰度比例 = %.4f, want 0.25±0.02", got)
+	}
+
+	if newCanaryModel(0).CanaryEnabled() {
+		t.Error("canary_percent为0时不应启用灰度")
+	}
+}
+
+func TestCanaryStickyByUser(t *testing.T) {
+	arm := func(percent int, user string) bool {
+		rc := newStageContext(newCanaryModel(percent), `{}`)
+		rc.Gin.Request.Header.Set(HeaderCanaryUser, user)
+		rc.selectCanary()
+		return rc.Canary
+	}
+
+	inCanary := 0
+	for i := 0; i < 1000; i++ {
+		user := "user-" + strconv.Itoa(i)
+		first := arm(25, user)
+		for j := 0; j < 5; j++ {
+			if arm(25, user) != first {
+				t.Fatalf("同一用户的请求应转发到同一目标: %s", user)
+			}
+		}
+		if first {
+			inCanary++
+			// 比例调大后已在灰度中的用户保持不变
+			if !arm(50, user) || !arm(100, user) {
+				t.Errorf("比例调大后用户%s不应离开灰度", user)
+			}
+		}
+	}
+	if inCanary < 200 || inCanary > 300 {
+		t.Errorf("1000个用户中期望约250个进入灰度，实际%d", inCanary)
+	}
+}
+
+func TestCanaryProxy(t *testing.T) {
+	var received string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = gjson.GetBytes(body, "model").String() + " " + r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	model := newCanaryModel(100)
+	model.Url = upstream.URL + "/primary"
+	model.CanaryUrl = upstream.URL + "/canary"
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{model.ID: model}}, nil)
+	tracker := stats.NewExperimentTracker()
+	s.SetCanaryTracker(tracker)
+
+	body := `{"model":"assistant-prod","messages":[]}`
+	var extra map[string]interface{}
+	var targetModel string
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Next()
+		value, _ := c.Get("log_extra")
+		extra, _ = value.(map[string]interface{})
+		targetModel = c.GetString("target_model")
+	})
+	r.Use(s.canaryTrackingMiddleware())
+	r.Any("/*path", s.proxyHandler)
+
+	send := func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("期望状态码200，实际%d %s", w.Code, w.Body.String())
+		}
+	}
+
+	send()
+	if received != "ft:gpt-4o-mini:assistant /canary" {
+		t.Errorf("期望转发到灰度目标，实际%q", received)
+	}
+	if extra["canary"] != true || targetModel != "ft:gpt-4o-mini:assistant" {
+		t.Errorf("访问日志应记录$canary=true和灰度目标，实际%v %s", extra["canary"], targetModel)
+	}
+
+	// 比例为0时与未配置灰度完全相同
+	model.CanaryPercent = 0
+	send()
+	if received != "gpt-4o-mini /primary" {
+		t.Errorf("期望转发到主目标，实际%q", received)
+	}
+	if _, ok := extra["canary"]; ok {
+		t.Errorf("未启用灰度时不应记录$canary，实际%v", extra)
+	}
+
+	summary := tracker.Summary(model.ID)
+	if len(summary) != 1 || summary[0].Variant != canaryArmCanary || summary[0].Requests != 1 {
+		t.Errorf("灰度统计 = %+v", summary)
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+// responseCapture 按访问日志的body策略保存响应体，超过上限的部分只计数，
+// 避免为不会被记录的内容占用内存
+type responseCapture struct {
+	enabled bool
+	limit   int // 0表示不限制
+	body    strings.Builder
+	dropped int64
+}
+
+// newResponseCapture 根据所有启用的日志记录器和请求的body采样值创建响应体保存器，未被任何记录器采样的
+// 成功请求不保存；日志记录器总是记录出错请求的body，因此force为true时只要路径未被排除就保存
+func newResponseCapture(c *gin.Context, force bool) *responseCapture {
+	limit, capture := logger.GlobalLoggerManager.BodyCaptureLimit(c.Request.URL.Path, c.GetFloat64("body_sample"))
+	return &responseCapture{
+		enabled: capture || (force && !logger.GlobalLoggerManager.BodyExcluded(c.Request.URL.Path)),
+		limit:   limit,
+	}
+}
+
+// Write 保存响应数据，超过上限的部分只计数，总是返回len(p)以便与其他Writer组合使用
+func (r *responseCapture) Write(p []byte) (int, error) {
+	if !r.enabled {
+		r.dropped += int64(len(p))
+		return len(p), nil
+	}
+	if r.limit > 0 && r.body.Len()+len(p) > r.limit {
+		keep := r.limit - r.body.Len()
+		r.body.Write(p[:keep])
+		r.dropped += int64(len(p) - keep)
+		return len(p), nil
+	}
+	r.body.Write(p)
+	return len(p), nil
+}
+
+// save 将保存的响应体写入上下文供访问日志使用
+func (r *responseCapture) save(c *gin.Context) {
+	c.Set("response_body", r.body.String())
+	c.Set("response_body_dropped", r.dropped)
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// errConcurrencyLimit 达到并发上限
+var errConcurrencyLimit = errors.New("已达到模型最大并发数")
+
+// errQueueTimeout 排队等待超时
+var errQueueTimeout = errors.New("排队等待超时")
+
+// semaphore 单个模型的并发信号量，上限可在运行时修改而不丢失进行中的计数
+type semaphore struct {
+	mutex    sync.Mutex
+	limit    int
+	inFlight int
+	queued   int
+	changed  chan struct{} // 名额释放或上限变化时关闭，唤醒排队的请求
+}
+
+// notify 唤醒所有排队的请求重新尝试获取名额，调用方需持有锁
+func (s *semaphore) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// tryAcquire 尝试获取名额，失败时返回等待名额变化的通道
+func (s *semaphore) tryAcquire() (bool, <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.inFlight < s.limit {
+		s.inFlight++
+		return true, nil
+	}
+	return false, s.changed
+}
+
+// release 释放名额
+func (s *semaphore) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.inFlight--
+	s.notify()
+}
+
+// addQueued 调整排队请求数
+func (s *semaphore) addQueued(delta int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queued += delta
+}
+
+// load 获取信号量的负载
+func (s *semaphore) load() stats.ModelLoad {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return stats.ModelLoad{InFlight: s.inFlight, Queued: s.queued, Limit: s.limit}
+}
+
+// ConcurrencyLimiter 按模型限制同时进行中的上游请求数
+type ConcurrencyLimiter struct {
+	semaphores map[string]*semaphore
+	mutex      sync.Mutex
+}
+
+// NewConcurrencyLimiter 创建并发限制器
+func NewConcurrencyLimiter() *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		semaphores: make(map[string]*semaphore),
+	}
+}
+
+// get 获取模型的信号量。运行时修改并发上限时只更新上限，进行中的请求仍然计数，
+// 调低上限后新请求需等待进行中的请求降到新上限以下
+func (l *ConcurrencyLimiter) get(modelID string, limit int) *semaphore {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	sem, ok := l.semaphores[modelID]
+	if !ok {
+		sem = &semaphore{limit: limit, changed: make(chan struct{})}
+		l.semaphores[modelID] = sem
+		return sem
+	}
+
+	sem.mutex.Lock()
+	if sem.limit != limit {
+		sem.limit = limit
+		sem.notify()
+	}
+	sem.mutex.Unlock()
+	return sem
+}
+
+// Acquire 获取并发名额，返回的release函数必须调用且只会生效一次；
+// limit<=0时不限制。queue为true时最多等待timeout，期间ctx取消则立即返回
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, modelID string, limit int, queue bool, timeout time.Duration) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	sem := l.get(modelID, limit)
+	var once sync.Once
+	release := func() {
+		once.Do(sem.release)
+	}
+
+	acquired, changed := sem.tryAcquire()
+	if acquired {
+		return release, nil
+	}
+	if !queue {
+		return nil, errConcurrencyLimit
+	}
+
+	sem.addQueued(1)
+	defer sem.addQueued(-1)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, errQueueTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if acquired, changed = sem.tryAcquire(); acquired {
+			return release, nil
+		}
+	}
+}
+
+// Load 获取模型当前的负载，模型还没有请求时返回零值
+func (l *ConcurrencyLimiter) Load(modelID string) stats.ModelLoad {
+	l.mutex.Lock()
+	sem, ok := l.semaphores[modelID]
+	l.mutex.Unlock()
+	if !ok {
+		return stats.ModelLoad{}
+	}
+	return sem.load()
+}
+
+// InFlight 返回各模型当前进行中的请求数
+func (l *ConcurrencyLimiter) InFlight() map[string]int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stats := make(map[string]int, len(l.semaphores))
+	for modelID, sem := range l.semaphores {
+		stats[modelID] = sem.load().InFlight
+	}
+	return stats
+}
@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestConcurrencyLimiterReject(t *testing.T) {
+	limiter := NewConcurrencyLimiter()
+
+	release, err := limiter.Acquire(context.Background(), "m", 1, false, 0)
+	if err != nil {
+		t.Fatalf("获取并发名额失败: %v", err)
+	}
+	if _, err := limiter.Acquire(context.Background(), "m", 1, false, 0); !errors.Is(err, errConcurrencyLimit) {
+		t.Errorf("期望达到并发上限错误，实际得到%v", err)
+	}
+	if stats := limiter.InFlight(); stats["m"] != 1 {
+		t.Errorf("期望进行中请求数为1，实际得到%d", stats["m"])
+	}
+
+	// 重复释放只生效一次
+	release()
+	release()
+	if stats := limiter.InFlight(); stats["m"] != 0 {
+		t.Errorf("释放后期望进行中请求数为0，实际得到%d", stats["m"])
+	}
+	if _, err := limiter.Acquire(context.Background(), "m", 1, false, 0); err != nil {
+		t.Errorf("释放后应能再次获取名额: %v", err)
+	}
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	limiter := NewConcurrencyLimiter()
+
+	release, err := limiter.Acquire(context.Background(), "m", 1, true, time.Second)
+	if err != nil {
+		t.Fatalf("获取并发名额失败: %v", err)
+	}
+
+	if _, err := limiter.Acquire(context.Background(), "m", 1, true, 10*time.Millisecond); !errors.Is(err, errQueueTimeout) {
+		t.Errorf("期望排队超时错误，实际得到%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Acquire(ctx, "m", 1, true, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("期望客户端断开错误，实际得到%v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	next, err := limiter.Acquire(context.Background(), "m", 1, true, time.Second)
+	if err != nil {
+		t.Fatalf("排队请求应在名额释放后获取成功: %v", err)
+	}
+	next()
+}
+
+func TestConcurrencyLimiterUnlimited(t *testing.T) {
+	limiter := NewConcurrencyLimiter()
+	for i := 0; i < 10; i++ {
+		if _, err := limiter.Acquire(context.Background(), "m", 0, false, 0); err != nil {
+			t.Fatalf("未限制并发时不应返回错误: %v", err)
+		}
+	}
+}
+
+func TestConcurrencyLimiterLimitChange(t *testing.T) {
+	limiter := NewConcurrencyLimiter()
+
+	first, err := limiter.Acquire(context.Background(), "m", 2, false, 0)
+	if err != nil {
+		t.Fatalf("获取并发名额失败: %v", err)
+	}
+	second, err := limiter.Acquire(context.Background(), "m", 2, false, 0)
+	if err != nil {
+		t.Fatalf("获取并发名额失败: %v", err)
+	}
+
+	// 调低上限后进行中的请求仍然计数，新请求需等待降到新上限以下
+	if _, err := limiter.Acquire(context.Background(), "m", 1, false, 0); !errors.Is(err, errConcurrencyLimit) {
+		t.Errorf("调低上限后期望达到并发上限错误，实际得到%v", err)
+	}
+	first()
+	if _, err := limiter.Acquire(context.Background(), "m", 1, false, 0); !errors.Is(err, errConcurrencyLimit) {
+		t.Errorf("进行中请求数未降到新上限以下时期望拒绝，实际得到%v", err)
+	}
+	second()
+	if load := limiter.Load("m"); load.InFlight != 0 || load.Limit != 1 {
+		t.Errorf("期望进行中请求数为0、上限为1，实际得到%+v", load)
+	}
+
+	// 调高上限会唤醒排队的请求
+	hold, err := limiter.Acquire(context.Background(), "m", 1, false, 0)
+	if err != nil {
+		t.Fatalf("获取并发名额失败: %v", err)
+	}
+	defer hold()
+	done := make(chan error, 1)
+	go func() {
+		release, err := limiter.Acquire(context.Background(), "m", 1, true, time.Second)
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	waitFor(t, func() bool { return limiter.Load("m").Queued == 1 })
+	if _, err := limiter.Acquire(context.Background(), "m", 3, false, 0); err != nil {
+		t.Fatalf("调高上限后应能获取名额: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("调高上限后排队请求应获取成功: %v", err)
+	}
+}
+
+// waitFor 等待条件成立，超时则测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待条件成立超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newConcurrencyTestRouter 创建只挂载代理处理函数的测试路由
+func newConcurrencyTestRouter(s *Server, body string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+	return r
+}
+
+func TestConcurrencyLimitUnderLoad(t *testing.T) {
+	const limit = 4
+	const requests = 32
+
+	var current, peak int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"busy": {ID: "busy", Name: "Busy", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			MaxConcurrency: limit, QueueTimeoutMs: 10000},
+	}}
+	s := NewServer(cfg, nil)
+	body := `{"model":"busy","messages":[]}`
+	r := newConcurrencyTestRouter(s, body)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("排队的请求都应成功，实际得到%d", code)
+		}
+	}
+	if peak > limit {
+		t.Errorf("上游同时处理的请求数不应超过%d，实际峰值%d", limit, peak)
+	}
+	if load := s.ModelLoad("busy"); load.InFlight != 0 || load.Queued != 0 {
+		t.Errorf("请求结束后期望负载为0，实际得到%+v", load)
+	}
+}
+
+func TestConcurrencyLimitOverloadedResponse(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"busy": {ID: "busy", Name: "Busy", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			MaxConcurrency: 1},
+	}}
+	s := NewServer(cfg, nil)
+	body := `{"model":"busy","messages":[]}`
+	r := newConcurrencyTestRouter(s, body)
+
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	waitFor(t, func() bool { return s.ModelLoad("busy").InFlight == 1 })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("期望返回429，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error struct {
+			Code       string `json:"code"`
+			Type       string `json:"type"`
+			InFlight   int    `json:"in_flight"`
+			QueueDepth *int   `json:"queue_depth"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if resp.Error.Code != "model_overloaded" || resp.Error.Type != "rate_limit_error" {
+		t.Errorf("期望错误码model_overloaded，实际得到%+v", resp.Error)
+	}
+	if resp.Error.InFlight != 1 || resp.Error.QueueDepth == nil || *resp.Error.QueueDepth != 0 {
+		t.Errorf("期望响应包含in_flight=1和queue_depth=0，实际得到%s", w.Body.String())
+	}
+}
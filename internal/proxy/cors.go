@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// proxyCORSHeaders 代理自身使用的请求头，总是允许浏览器跨域发送
+var proxyCORSHeaders = []string{
+	"X-Proxy-Key", HeaderTimeoutBudget, HeaderSkipPrompt, HeaderExtraPrompt,
+	HeaderExperimentKey, HeaderIdempotencyKey, HeaderCanaryUser,
+}
+
+// corsMiddleware 处理浏览器跨域请求：预检请求在API Key验证之前直接返回且不记录访问日志，
+// 实际请求（包括流式响应）在转发前添加跨域响应头
+func (s *Server) corsMiddleware() gin.HandlerFunc {
+	cors := config.DefaultServerConfig().ProxyCORS()
+	if s.serverConfig != nil {
+		cors = s.serverConfig.ProxyCORS()
+	}
+	allowMethods := strings.Join(cors.AllowMethods, ", ")
+	allowHeaders := strings.Join(mergeHeaderNames(cors.AllowHeaders, proxyCORSHeaders), ", ")
+
+	enabled := len(cors.AllowOrigins) > 0
+
+	return func(c *gin.Context) {
+		if enabled {
+			c.Set("cors_enabled", true)
+		}
+		origin := cors.AllowedOrigin(c.GetHeader("Origin"))
+		if origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Set("cors_origin", origin)
+		}
+		if origin != "" && origin != "*" {
+			c.Writer.Header().Add("Vary", "Origin")
+		}
+
+		if c.Request.Method != http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		// OPTIONS请求由代理直接响应，不转发给上游；预检请求的方法不在允许列表中时返回405
+		c.Header("Allow", allowMethods)
+		if method := c.GetHeader("Access-Control-Request-Method"); method != "" {
+			if !cors.AllowsMethod(method) {
+				c.AbortWithStatusJSON(http.StatusMethodNotAllowed, errorBody(c, i18n.CodeMethodNotAllowed, errorTypeInvalidRequest, method))
+				return
+			}
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// isCORSResponseHeader 代理配置了跨域来源时由代理决定跨域响应头，上游或保存的响应中的Access-Control-*响应头不再复制，
+// 避免不允许的来源通过上游的宽松跨域响应头读取响应
+func isCORSResponseHeader(c *gin.Context, key string) bool {
+	return c.GetBool("cors_enabled") && strings.HasPrefix(http.CanonicalHeaderKey(key), "Access-Control-")
+}
+
+// mergeHeaderNames 合并请求头名称列表，忽略大小写重复的名称
+func mergeHeaderNames(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, list := range lists {
+		for _, name := range list {
+			key := http.CanonicalHeaderKey(name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
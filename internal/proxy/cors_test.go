@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestProxyCORS(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Write([]byte("data: {\"choices\":[]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	writePromptConfig(t, dir, upstream.URL, "系统提示")
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	authService, err := service.NewAuthService(configService.GetDBManager())
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	apiKey, err := authService.CreateAPIKey(admin.User.ID, "test", service.GenerateAPIKeyValue(), "", nil, nil)
+	if err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Proxy.CORS.AllowOrigins = []string{"https://app.example.com"}
+	handler := NewHandler(configService, authService, serverConfig)
+
+	preflight := func(origin, method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/chat/completions", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-proxy-key")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://app.example.com", http.MethodPost)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("预检请求期望状态码204，实际%d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-Proxy-Key") {
+		t.Errorf("Access-Control-Allow-Headers应包含X-Proxy-Key，实际%q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, http.MethodPost) {
+		t.Errorf("Access-Control-Allow-Methods应包含POST，实际%q", got)
+	}
+
+	if w := preflight("https://evil.example.com", http.MethodPost); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("不允许的来源不应返回Access-Control-Allow-Origin，实际%q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w := preflight("https://app.example.com", "TRACE"); w.Code != http.StatusMethodNotAllowed || !strings.Contains(w.Body.String(), "method_not_allowed") {
+		t.Errorf("不允许的方法期望405，实际%d %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"watched","stream":true,"messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("X-Proxy-Key", apiKey.KeyValue)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("流式请求期望状态码200，实际%d %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "[DONE]") {
+		t.Errorf("期望转发流式响应，实际%q", w.Body.String())
+	}
+	if got := w.Header().Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != "https://app.example.com" {
+		t.Errorf("流式响应的Access-Control-Allow-Origin = %v", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+
+	// 不允许的来源不应通过上游的宽松跨域响应头读取响应
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"watched","stream":true,"messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("X-Proxy-Key", apiKey.KeyValue)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("流式请求期望状态码200，实际%d %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Values("Access-Control-Allow-Origin"); len(got) != 0 {
+		t.Errorf("不允许的来源不应返回上游的Access-Control-Allow-Origin，实际%v", got)
+	}
+}
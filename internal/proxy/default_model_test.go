@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// serveDefaultModel 发送请求并返回响应及上游收到的请求体
+func serveDefaultModel(t *testing.T, defaultModel, modelID string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	var upstreamBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		upstreamBody = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"chat.completion"}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := &config.Config{
+		Models: map[string]*config.ModelConfig{
+			"chat":     {ID: "chat", Name: "Chat", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat, Aliases: []string{"chat-latest"}},
+			"fallback": {ID: "fallback", Name: "Fallback", Target: "gpt-4o-mini", Url: upstream.URL, Type: config.ModelTypeChat},
+		},
+		DefaultModel: defaultModel,
+	}
+	cfg.RebuildIndex()
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"` + modelID + `","messages":[]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w, upstreamBody
+}
+
+func TestUnknownModelWithoutDefault(t *testing.T) {
+	w, _ := serveDefaultModel(t, "", "llama-3")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未设置默认模型时期望状态码404，实际得到%d", w.Code)
+	}
+}
+
+func TestUnknownModelUsesDefault(t *testing.T) {
+	w, upstreamBody := serveDefaultModel(t, "fallback", "llama-3")
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望转发到默认模型，实际状态码%d，响应%s", w.Code, w.Body.String())
+	}
+	if model := gjson.Get(upstreamBody, "model").String(); model != "llama-3" {
+		t.Errorf("使用默认模型时应透传原始模型ID，实际得到%q", model)
+	}
+}
+
+func TestKnownModelIgnoresDefault(t *testing.T) {
+	_, upstreamBody := serveDefaultModel(t, "fallback", "chat")
+	if model := gjson.Get(upstreamBody, "model").String(); model != "gpt-4o" {
+		t.Errorf("匹配到的模型应替换为目标模型ID，实际得到%q", model)
+	}
+}
+
+func TestAliasTakesPrecedenceOverDefault(t *testing.T) {
+	w, upstreamBody := serveDefaultModel(t, "fallback", "chat-latest")
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望通过别名转发成功，实际状态码%d", w.Code)
+	}
+	if model := gjson.Get(upstreamBody, "model").String(); model != "gpt-4o" {
+		t.Errorf("通过别名匹配的模型应替换为目标模型ID，实际得到%q", model)
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// 支持解压的请求体压缩格式
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// defaultMaxDecompressedSize 未限制请求体大小时解压后请求体的最大字节数，防止压缩炸弹
+const defaultMaxDecompressedSize int64 = 16 << 20
+
+// errDecompressedTooLarge 解压后的请求体超过大小限制
+var errDecompressedTooLarge = errors.New("解压后的请求体过大")
+
+// requestEncoding 返回请求体的压缩格式，未压缩或不支持的格式返回空字符串
+func requestEncoding(header http.Header) string {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		return encodingGzip
+	case "deflate":
+		return encodingDeflate
+	}
+	return ""
+}
+
+// maxDecompressedSize 解压后请求体的最大字节数，与请求体大小限制相同
+func (s *Server) maxDecompressedSize() int64 {
+	if s.serverConfig != nil && s.serverConfig.Limits.MaxRequestBodySize > 0 {
+		return s.serverConfig.Limits.MaxRequestBodySize
+	}
+	return defaultMaxDecompressedSize
+}
+
+// decodeRequestBody 解压gzip/deflate压缩的请求体，去掉Content-Encoding请求头并记录压缩格式，
+// 转发时由模型的compress_upstream决定是否重新压缩。解压失败或解压后过大时返回错误响应和false
+func (s *Server) decodeRequestBody(c *gin.Context, body []byte) ([]byte, bool) {
+	encoding := requestEncoding(c.Request.Header)
+	if encoding == "" || len(body) == 0 {
+		return body, true
+	}
+	limit := s.maxDecompressedSize()
+	decoded, err := decompressBody(body, encoding, limit)
+	if errors.Is(err, errDecompressedTooLarge) {
+		abortWithError(c, http.StatusRequestEntityTooLarge, i18n.CodeRequestTooLarge, errorTypeInvalidRequest, limit)
+		return nil, false
+	}
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, i18n.CodeInvalidContentEncoding, errorTypeInvalidRequest, err.Error())
+		return nil, false
+	}
+	c.Request.Header.Del("Content-Encoding")
+	c.Set("request_encoding", encoding)
+	return decoded, true
+}
+
+// decompressBody 解压请求体，解压后超过limit字节时返回errDecompressedTooLarge。
+// deflate按RFC 9110为zlib格式，部分客户端发送不带zlib头的原始deflate数据，也可以解压
+func decompressBody(body []byte, encoding string, limit int64) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case encodingGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case encodingDeflate:
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if errors.Is(err, zlib.ErrHeader) {
+			reader, err = io.NopCloser(flate.NewReader(bytes.NewReader(body))), nil
+		}
+	default:
+		return nil, fmt.Errorf("不支持的压缩格式: %s", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errDecompressedTooLarge
+	}
+	return data, nil
+}
+
+// compressBody 按客户端使用的压缩格式压缩转发给上游的请求体
+func compressBody(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case encodingGzip:
+		writer = gzip.NewWriter(&buf)
+	case encodingDeflate:
+		writer = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("不支持的压缩格式: %s", encoding)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
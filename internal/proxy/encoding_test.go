@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// encodedRequest 记录上游收到的请求体和Content-Encoding请求头
+type encodedRequest struct {
+	body     []byte
+	encoding string
+}
+
+func newEncodingRouter(t *testing.T, serverConfig *config.ServerConfig) (*gin.Engine, *encodedRequest) {
+	t.Helper()
+	forwarded := &encodedRequest{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.body, _ = io.ReadAll(r.Body)
+		forwarded.encoding = r.Header.Get("Content-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"plain": {ID: "plain", Name: "Plain", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			Prompt: "你是助手", PromptPath: "messages", PromptValueType: config.ValueTypeArray},
+		"packed": {ID: "packed", Name: "Packed", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat, CompressUpstream: true},
+	}}
+	s := NewServer(cfg, nil)
+	s.serverConfig = serverConfig
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		body, ok := s.decodeRequestBody(c, body)
+		if !ok {
+			return
+		}
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+	return r, forwarded
+}
+
+func sendEncoded(r *gin.Engine, encoding string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", encoding)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGzipRequestBody(t *testing.T) {
+	r, forwarded := newEncodingRouter(t, nil)
+	body := `{"model":"plain","messages":[{"role":"user","content":"hi"}]}`
+	compressed, err := compressBody([]byte(body), encodingGzip)
+	if err != nil {
+		t.Fatalf("压缩失败: %v", err)
+	}
+
+	// 默认解压后转发，并去掉Content-Encoding请求头
+	if w := sendEncoded(r, "gzip", compressed); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if forwarded.encoding != "" {
+		t.Errorf("解压后转发不应带Content-Encoding，实际为%q", forwarded.encoding)
+	}
+	if got := gjson.GetBytes(forwarded.body, "model").String(); got != "gpt-4o" {
+		t.Errorf("应替换解压后请求体中的模型ID，实际为%q: %s", got, forwarded.body)
+	}
+	if got := gjson.GetBytes(forwarded.body, "messages.0.content").String(); got != "你是助手" {
+		t.Errorf("应向解压后的请求体注入Prompt，实际为%s", forwarded.body)
+	}
+
+	// 开启compress_upstream时按客户端的压缩格式重新压缩后转发
+	body = strings.Replace(body, "plain", "packed", 1)
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		compressed, _ := compressBody([]byte(body), encoding)
+		if w := sendEncoded(r, encoding, compressed); w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", encoding, w.Code, w.Body.String())
+		}
+		if forwarded.encoding != encoding {
+			t.Errorf("%s: 重新压缩后应带Content-Encoding，实际为%q", encoding, forwarded.encoding)
+		}
+		decoded, err := decompressBody(forwarded.body, encoding, defaultMaxDecompressedSize)
+		if err != nil || gjson.GetBytes(decoded, "model").String() != "gpt-4o" {
+			t.Errorf("%s: 上游应收到可解压的请求体，err=%v body=%s", encoding, err, decoded)
+		}
+	}
+}
+
+func TestRawDeflateRequestBody(t *testing.T) {
+	var buf bytes.Buffer
+	writer, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	writer.Write([]byte(`{"model":"plain"}`))
+	writer.Close()
+
+	decoded, err := decompressBody(buf.Bytes(), encodingDeflate, defaultMaxDecompressedSize)
+	if err != nil || string(decoded) != `{"model":"plain"}` {
+		t.Errorf("不带zlib头的deflate数据应能解压，err=%v body=%s", err, decoded)
+	}
+}
+
+func TestDecompressedSizeLimit(t *testing.T) {
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.Limits.MaxRequestBodySize = 1024
+	r, _ := newEncodingRouter(t, serverConfig)
+
+	// 压缩后远小于限制，解压后超过限制
+	body := `{"model":"plain","input":"` + strings.Repeat("a", 100000) + `"}`
+	compressed, _ := compressBody([]byte(body), encodingGzip)
+	if len(compressed) >= 1024 {
+		t.Fatalf("压缩后应小于请求体限制，实际%d字节", len(compressed))
+	}
+	w := sendEncoded(r, "gzip", compressed)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("期望状态码413，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	if code := gjson.Get(w.Body.String(), "error.code").String(); code != "request_too_large" {
+		t.Errorf("期望错误码request_too_large，实际得到%q", code)
+	}
+}
+
+func TestInvalidGzipRequestBody(t *testing.T) {
+	r, forwarded := newEncodingRouter(t, nil)
+	w := sendEncoded(r, "gzip", []byte(`{"model":"plain"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("期望状态码400，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	if code := gjson.Get(w.Body.String(), "error.code").String(); code != "invalid_content_encoding" {
+		t.Errorf("期望错误码invalid_content_encoding，实际得到%q", code)
+	}
+	if forwarded.body != nil {
+		t.Error("解压失败的请求不应转发到上游")
+	}
+}
+
+func TestUnauthenticatedBodyNotDecoded(t *testing.T) {
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{}}, nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(s.apiKeyAuthMiddleware())
+	r.Any("/*path", s.proxyHandler)
+
+	// 未提供API Key时先返回401，不解压请求体
+	w := sendEncoded(r, "gzip", []byte(`not gzip`))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("期望状态码401，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	if code := gjson.Get(w.Body.String(), "error.code").String(); code != "missing_api_key" {
+		t.Errorf("期望错误码missing_api_key，实际得到%q", code)
+	}
+}
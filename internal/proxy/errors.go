@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/alert"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// OpenAI错误格式中的错误类型
+const (
+	errorTypeInvalidRequest = "invalid_request_error"
+	errorTypeAuthentication = "authentication_error"
+	errorTypePermission     = "permission_error"
+	errorTypeServer         = "server_error"
+)
+
+// errorBody 生成OpenAI格式的错误响应体，message按请求的Accept-Language本地化
+func errorBody(c *gin.Context, code i18n.Code, errType string, details ...interface{}) gin.H {
+	return gin.H{"error": gin.H{
+		"code":    code,
+		"type":    errType,
+		"message": i18n.T(c, code, details...),
+	}}
+}
+
+// statusErrorCode 没有具体错误码时按HTTP状态码使用的通用错误码和错误类型
+func statusErrorCode(status int) (i18n.Code, string) {
+	switch {
+	case status == http.StatusServiceUnavailable:
+		return i18n.CodeServiceUnavailable, errorTypeServer
+	case status >= http.StatusInternalServerError:
+		return i18n.CodeInternalError, errorTypeServer
+	case status == http.StatusUnauthorized:
+		return i18n.CodeUnauthorized, errorTypeAuthentication
+	case status == http.StatusForbidden:
+		return i18n.CodeForbidden, errorTypePermission
+	case status == http.StatusNotFound:
+		return i18n.CodeNotFound, errorTypeInvalidRequest
+	case status == http.StatusConflict:
+		return i18n.CodeConflict, errorTypeInvalidRequest
+	default:
+		return i18n.CodeBadRequest, errorTypeInvalidRequest
+	}
+}
+
+// abortWithError 中止请求并返回带稳定错误码的错误；访问日志和错误统计记录默认语言的信息，不随客户端语言变化
+func abortWithError(c *gin.Context, status int, code i18n.Code, errType string, details ...interface{}) {
+	c.Set("error", i18n.Message(i18n.Default, code, details...))
+	c.AbortWithStatusJSON(status, errorBody(c, code, errType, details...))
+}
+
+// respondPanic 返回处理请求时发生panic的500响应，包含请求ID便于在访问日志中查找
+func respondPanic(c *gin.Context, requestID string) {
+	body := errorBody(c, i18n.CodeInternalError, errorTypeServer)
+	body["error"].(gin.H)["request_id"] = requestID
+	c.JSON(http.StatusInternalServerError, body)
+}
+
+// SetErrorTracker 设置按模型统计错误的统计器
+func (s *Server) SetErrorTracker(tracker *stats.ErrorTracker) {
+	s.errorTracker = tracker
+}
+
+// SetErrorRateMonitor 设置按模型统计错误率并在突增时告警的监控
+func (s *Server) SetErrorRateMonitor(monitor *alert.ErrorRateMonitor) {
+	s.errorRates = monitor
+}
+
+// setErrorClass 设置当前请求的错误分类
+func setErrorClass(c *gin.Context, class stats.ErrorClass) {
+	c.Set("error_class", class)
+}
+
+// errorTrackingMiddleware 请求结束后按模型记录错误事件和错误率，只记录已配置的模型；
+// 错误率不计入客户端请求本身的错误
+func (s *Server) errorTrackingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if s.errorTracker == nil && s.errorRates == nil {
+			return
+		}
+		modelID := c.GetString("model_id")
+		if _, exists := s.config.GetModel(modelID); !exists {
+			return
+		}
+		class, message := classifyError(c)
+		if class != "" && s.errorTracker != nil {
+			s.errorTracker.Record(modelID, class, c.GetString("request_id"), message)
+		}
+		s.errorRates.Observe(modelID, class != "" && class != stats.ErrorClassClient)
+	}
+}
+
+// classifyError 根据请求上下文判断错误分类，无错误时返回空分类
+func classifyError(c *gin.Context) (stats.ErrorClass, string) {
+	// 客户端主动断开不属于上游错误
+	if c.GetBool("client_disconnected") {
+		return "", ""
+	}
+	message := c.GetString("error")
+	if value, ok := c.Get("error_class"); ok {
+		return value.(stats.ErrorClass), message
+	}
+
+	value, ok := c.Get("response_status")
+	if !ok {
+		return "", ""
+	}
+	status := value.(int)
+	if message == "" && status >= 400 {
+		message = fmt.Sprintf("上游返回状态码 %d: %s", status, c.GetString("response_body"))
+	}
+	switch {
+	case status >= 500:
+		return stats.ErrorClassUpstream5xx, message
+	case status >= 400:
+		return stats.ErrorClassUpstream4xx, message
+	default:
+		return "", ""
+	}
+}
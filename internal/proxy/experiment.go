@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// HeaderExperimentKey 固定Prompt实验变体的会话标识，相同标识的请求总是使用同一变体
+const HeaderExperimentKey = "X-Proxy-Experiment-Key"
+
+// pickPromptVariant 按权重选择Prompt实验的变体。stickyKey不为空时按加权的rendezvous哈希选择，同一会话总是得到同一变体，
+// 调整权重或增删变体时只有移入或移出相关变体的会话改变分配；否则使用intn随机选择。权重全为0时返回false
+func pickPromptVariant(modelID string, variants []config.PromptVariant, stickyKey string, intn func(n int) int) (config.PromptVariant, bool) {
+	if stickyKey != "" {
+		return rendezvousVariant(modelID, variants, stickyKey)
+	}
+	weights := make([]int, len(variants))
+	for i, variant := range variants {
+		weights[i] = variant.Weight
+	}
+	i := weightedIndex(weights, intn)
+	if i < 0 {
+		return config.PromptVariant{}, false
+	}
+	return variants[i], true
+}
+
+// rendezvousVariant 为每个权重大于0的变体计算得分 -weight/ln(u)，u为模型ID、变体ID和stickyKey的哈希映射到(0,1)的值，
+// 返回得分最高的变体，各变体被选中的概率与权重成正比
+func rendezvousVariant(modelID string, variants []config.PromptVariant, stickyKey string) (config.PromptVariant, bool) {
+	best, bestScore := -1, 0.0
+	for i, variant := range variants {
+		if variant.Weight <= 0 {
+			continue
+		}
+		hash := fnv.New64a()
+		hash.Write([]byte(modelID))
+		hash.Write([]byte{0})
+		hash.Write([]byte(variant.ID))
+		hash.Write([]byte{0})
+		hash.Write([]byte(stickyKey))
+		// 取混合后的高53位作为(0,1)内的均匀分布值
+		u := (float64(mix64(hash.Sum64())>>11) + 0.5) / (1 << 53)
+		if score := -float64(variant.Weight) / math.Log(u); best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return config.PromptVariant{}, false
+	}
+	return variants[best], true
+}
+
+// mix64 splitmix64的最终混合步骤，使FNV哈希的高位对输入末尾的变化同样敏感
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// experimentKey 返回固定变体使用的会话标识：优先使用X-Proxy-Experiment-Key请求头，其次为API Key所属的用户
+func experimentKey(c *gin.Context) string {
+	if key := c.GetHeader(HeaderExperimentKey); key != "" {
+		return key
+	}
+	if apiKey := apiKeyFromContext(c); apiKey != nil {
+		return "user:" + strconv.FormatUint(uint64(apiKey.UserID), 10)
+	}
+	return ""
+}
+
+// selectPromptVariant 模型配置了Prompt实验时选择本次请求的变体，将rc.Model替换为注入该变体Prompt的配置，
+// 并在访问日志和实验统计中记录变体ID
+func (rc *RequestContext) selectPromptVariant() {
+	variant, ok := pickPromptVariant(rc.Model.ID, rc.Model.PromptVariants, experimentKey(rc.Gin), rand.Intn)
+	if !ok {
+		return
+	}
+	// 使用默认模型时model_id为客户端原始的模型ID，统计按实际的模型ID记录
+	rc.Gin.Set("experiment_model_id", rc.Model.ID)
+	rc.Gin.Set("experiment_variant", variant.ID)
+	rc.Model = rc.Model.WithPromptVariant(variant)
+	rc.ExperimentVariant = variant.ID
+	rc.SetLogExtra("experiment_variant", variant.ID)
+}
+
+// SetExperimentTracker 设置Prompt实验的统计器
+func (s *Server) SetExperimentTracker(tracker *stats.ExperimentTracker) {
+	s.experiments = tracker
+}
+
+// experimentTrackingMiddleware 请求结束后按模型和变体记录Prompt实验的请求数、失败数和响应时间
+func (s *Server) experimentTrackingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		variant := c.GetString("experiment_variant")
+		if s.experiments == nil || variant == "" {
+			return
+		}
+		class, _ := classifyError(c)
+		s.experiments.Record(c.GetString("experiment_model_id"), variant, class != "", time.Since(start))
+	}
+}
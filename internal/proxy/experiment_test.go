@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+func TestPickPromptVariantDistribution(t *testing.T) {
+	variants := []config.PromptVariant{
+		{ID: "control", Weight: 70},
+		{ID: "paused", Weight: 0},
+		{ID: "candidate", Weight: 30},
+	}
+	want := map[string]float64{"control": 0.7, "candidate": 0.3}
+	rng := rand.New(rand.NewSource(1))
+
+	// 没有会话标识时随机选择
+	const draws = 100000
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		variant, ok := pickPromptVariant("gpt-4o", variants, "", rng.Intn)
+		if !ok {
+			t.Fatal("权重大于0时应选中变体")
+		}
+		counts[variant.ID]++
+	}
+	// 不同会话标识按哈希分配，整体比例同样符合权重
+	sticky := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		variant, _ := pickPromptVariant("gpt-4o", variants, fmt.Sprintf("conversation-%d", i), rng.Intn)
+		sticky[variant.ID]++
+	}
+	for name, counts := range map[string]map[string]int{"随机": counts, "按会话": sticky} {
+		if counts["paused"] != 0 {
+			t.Errorf("%s: 权重为0的变体不应被选中，实际选中%d次", name, counts["paused"])
+		}
+		for id, ratio := range want {
+			if got := float64(counts[id]) / draws; math.Abs(got-ratio) > 0.01 {
+				t.Errorf("%s: %s的比例 = %.4f, want %.2f±0.01", name, id, got, ratio)
+			}
+		}
+	}
+
+	if _, ok := pickPromptVariant("gpt-4o", []config.PromptVariant{{ID: "off"}}, "", rng.Intn); ok {
+		t.Error("权重全为0时不应选中变体")
+	}
+}
+
+func TestPickPromptVariantSticky(t *testing.T) {
+	variants := []config.PromptVariant{{ID: "a", Weight: 1}, {ID: "b", Weight: 1}, {ID: "c", Weight: 1}}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		first, _ := pickPromptVariant("gpt-4o", variants, key, rng.Intn)
+		for j := 0; j < 20; j++ {
+			if got, _ := pickPromptVariant("gpt-4o", variants, key, rng.Intn); got.ID != first.ID {
+				t.Fatalf("同一会话标识%s应总是选中%s，实际得到%s", key, first.ID, got.ID)
+			}
+		}
+	}
+}
+
+func TestPickPromptVariantWeightChangeKeepsAssignments(t *testing.T) {
+	before := []config.PromptVariant{{ID: "a", Weight: 1}, {ID: "b", Weight: 1}}
+	after := []config.PromptVariant{{ID: "a", Weight: 1}, {ID: "b", Weight: 2}}
+	const users = 10000
+	moved := 0
+	for i := 0; i < users; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		old, _ := pickPromptVariant("gpt-4o", before, key, nil)
+		current, _ := pickPromptVariant("gpt-4o", after, key, nil)
+		if old.ID == current.ID {
+			continue
+		}
+		// 提高b的权重只会让部分会话从a移到b
+		if old.ID != "a" {
+			t.Fatalf("会话%s从%s移到了%s", key, old.ID, current.ID)
+		}
+		moved++
+	}
+	// a的比例从1/2降为1/3，约1/6的会话改变分配
+	if ratio := float64(moved) / users; math.Abs(ratio-1.0/6) > 0.02 {
+		t.Errorf("调整权重后改变分配的会话比例 = %.4f, want %.4f±0.02", ratio, 1.0/6)
+	}
+}
+
+func TestPromptExperimentProxy(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		prompts = append(prompts, gjson.GetBytes(body, "messages.0.content").String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"assistant": {
+			ID: "assistant", Name: "Assistant", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			PromptPath:  "messages",
+			PromptValue: map[string]interface{}{"role": "system", "content": "base prompt"},
+			PromptVariants: []config.PromptVariant{
+				{ID: "concise", Weight: 1, PromptValue: "concise prompt"},
+				{ID: "detailed", Weight: 1, PromptValue: "detailed prompt"},
+			},
+		},
+		"plain": {
+			ID: "plain", Name: "Plain", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			PromptPath:  "messages",
+			PromptValue: map[string]interface{}{"role": "system", "content": "base prompt"},
+		},
+	}}
+	s := NewServer(cfg, nil)
+	tracker := stats.NewExperimentTracker()
+	s.SetExperimentTracker(tracker)
+
+	var logged []string
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+		c.Next()
+		extra, _ := c.Get("log_extra")
+		fields, _ := extra.(map[string]interface{})
+		variant, _ := fields["experiment_variant"].(string)
+		logged = append(logged, variant)
+	})
+	r.Use(s.experimentTrackingMiddleware())
+	r.Any("/*path", s.proxyHandler)
+
+	send := func(model, key string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+			strings.NewReader(`{"model":"`+model+`","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(HeaderExperimentKey, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+
+	// 同一会话的请求总是注入同一变体
+	for i := 0; i < 10; i++ {
+		send("assistant", "conversation-1")
+	}
+	for i, prompt := range prompts {
+		if prompt != prompts[0] || logged[i] != logged[0] {
+			t.Fatalf("同一会话的第%d个请求使用了不同的变体: %q/%q", i, prompt, logged[i])
+		}
+	}
+	if want := map[string]string{"concise": "concise prompt", "detailed": "detailed prompt"}[logged[0]]; prompts[0] != want {
+		t.Errorf("注入的Prompt = %q, 记录的变体 = %q", prompts[0], logged[0])
+	}
+
+	// 未配置变体的模型行为不变
+	send("plain", "conversation-1")
+	if got := prompts[len(prompts)-1]; got != "base prompt" || logged[len(logged)-1] != "" {
+		t.Errorf("未配置变体时应注入基础Prompt且不记录变体: %q %q", got, logged[len(logged)-1])
+	}
+
+	summary := tracker.Summary("assistant")
+	if len(summary) != 1 || summary[0].Variant != logged[0] || summary[0].Requests != 10 || summary[0].Errors != 0 {
+		t.Errorf("实验统计 = %+v", summary)
+	}
+	if got := tracker.Summary("plain"); len(got) != 0 {
+		t.Errorf("未配置变体的模型不应有实验统计: %+v", got)
+	}
+	if len(cfg.Models["assistant"].PromptValue.(map[string]interface{})) != 2 ||
+		cfg.Models["assistant"].PromptValue.(map[string]interface{})["content"] != "base prompt" {
+		t.Error("选择变体不应修改共享的模型配置")
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestModelHeaderInjection(t *testing.T) {
+	var forwarded http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "1")
+		w.Write([]byte(`{"object":"chat.completion"}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"assistant": {
+			ID: "assistant", Name: "Assistant", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			RequestHeaders:  map[string]string{"OpenAI-Beta": "assistants=v2", "X-Team": "proxy"},
+			ResponseHeaders: map[string]string{"X-Served-By": "ai-prompt-proxy"},
+		},
+	}}
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"assistant","messages":[]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Team", "client")
+	req.Header.Set("X-Client", "sdk")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if got := forwarded.Get("OpenAI-Beta"); got != "assistants=v2" {
+		t.Errorf("转发的请求应带有模型配置的请求头，OpenAI-Beta = %q", got)
+	}
+	if got := forwarded.Values("X-Team"); len(got) != 1 || got[0] != "proxy" {
+		t.Errorf("模型配置的请求头应覆盖客户端的同名请求头，X-Team = %v", got)
+	}
+	if got := forwarded.Get("X-Client"); got != "sdk" {
+		t.Errorf("客户端的其他请求头应保留，X-Client = %q", got)
+	}
+	if got := w.Header().Get("X-Served-By"); got != "ai-prompt-proxy" {
+		t.Errorf("响应应带有模型配置的响应头，X-Served-By = %q", got)
+	}
+	if got := w.Header().Get("X-Upstream"); got != "1" {
+		t.Errorf("上游的响应头应保留，X-Upstream = %q", got)
+	}
+}
+
+func TestModelResponseHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if strings.Contains(r.URL.Path, "stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"id\":1}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"chat.completion"}`))
+	}))
+	defer upstream.Close()
+
+	headers := map[string]string{
+		"X-Model-Version": "{model_id}@{target}",
+		"X-Request-Id":    "{request_id}",
+		"Cache-Control":   "private, max-age=60",
+	}
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"assistant": {ID: "assistant", Name: "Assistant", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			ResponseHeaders: headers},
+	}}
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"assistant","messages":[]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(s.corsMiddleware())
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Set("request_id", "req-1")
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	// 非流式和流式响应都在写入响应体之前带有模型配置的响应头
+	for _, path := range []string{"/v1/chat/completions", "/v1/stream"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body = %s", path, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Model-Version"); got != "assistant@gpt-4o" {
+			t.Errorf("%s: X-Model-Version = %q", path, got)
+		}
+		if got := w.Header().Get("X-Request-Id"); got != "req-1" {
+			t.Errorf("%s: X-Request-Id = %q", path, got)
+		}
+		if got := w.Header().Values("Cache-Control"); len(got) != 1 || got[0] != "private, max-age=60" {
+			t.Errorf("%s: 模型配置的响应头应覆盖上游的同名响应头，Cache-Control = %v", path, got)
+		}
+		if got := w.Header().Get("Access-Control-Expose-Headers"); got != "Cache-Control, X-Model-Version, X-Request-Id" {
+			t.Errorf("%s: 跨域请求应允许浏览器读取模型配置的响应头，Access-Control-Expose-Headers = %q", path, got)
+		}
+	}
+
+	// 模型配置了Access-Control-Expose-Headers时以配置为准
+	headers["Access-Control-Expose-Headers"] = "X-Model-Version"
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Model-Version" {
+		t.Errorf("Access-Control-Expose-Headers = %q, want X-Model-Version", got)
+	}
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// 对冲请求记录的访问日志扩展字段
+const (
+	extraHedged         = "hedged"             // 是否发送了对冲请求
+	extraHedgeWinner    = "hedge_winner"       // 使用的响应来自第几个请求，1为原始请求，都失败时为0
+	extraHedgeLatencies = "hedge_latencies_ms" // 各请求从发送到收到响应头（或失败、被取消）的耗时
+	extraHedgeSkipped   = "hedge_skipped"      // 没有并发名额而未发送对冲请求
+)
+
+// upstreamAttempt 一次上游请求，原始请求和对冲请求各为一次
+type upstreamAttempt struct {
+	index   int // 从1开始，1为原始请求
+	timer   *upstreamTimer
+	cancel  context.CancelFunc
+	release func() // 释放对冲请求占用的并发名额，原始请求的名额由limits阶段释放
+
+	resp    *http.Response
+	err     error
+	latency float64
+}
+
+// sendUpstream 发送上游请求，返回的finish在响应转发完成后调用，记录上游耗时。
+// 模型配置了hedge_after_ms且不是流式请求时按对冲方式发送
+func (s *Server) sendUpstream(c *gin.Context, newRequest func(context.Context) (*http.Request, error), body []byte, modelConfig *config.ModelConfig) (*http.Response, func(), error) {
+	if modelConfig.HedgeAfterMs > 0 && !isStreamRequest(body) {
+		return s.sendHedged(c, newRequest, modelConfig)
+	}
+
+	timer, ctx := newUpstreamTimer(c.Request.Context())
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	resp, err := s.httpClient.Do(req)
+	return resp, func() { timer.record(c) }, err
+}
+
+// sendHedged 发送原始请求，每等待hedge_after_ms仍未收到响应头时再发送一个相同的对冲请求，最多max_hedges个，
+// 使用最先收到响应头的请求并取消其他请求。对冲只在等待响应时发送，响应开始转发后不会再发送，
+// 请求失败后也不会重新发送；全部请求都失败时返回最后一个错误
+func (s *Server) sendHedged(c *gin.Context, newRequest func(context.Context) (*http.Request, error), modelConfig *config.ModelConfig) (*http.Response, func(), error) {
+	results := make(chan *upstreamAttempt, 1+modelConfig.MaxHedges)
+	var attempts []*upstreamAttempt
+	launch := func(release func()) error {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		timer, traceCtx := newUpstreamTimer(ctx)
+		req, err := newRequest(traceCtx)
+		if err != nil {
+			cancel()
+			release()
+			return err
+		}
+		attempt := &upstreamAttempt{index: len(attempts) + 1, timer: timer, cancel: cancel, release: release}
+		attempts = append(attempts, attempt)
+		go func() {
+			attempt.resp, attempt.err = s.httpClient.Do(req)
+			attempt.latency = elapsedMs(timer.start, time.Now())
+			results <- attempt
+		}()
+		return nil
+	}
+	if err := launch(func() {}); err != nil {
+		return nil, func() {}, err
+	}
+
+	hedgeAfter := time.Duration(modelConfig.HedgeAfterMs) * time.Millisecond
+	hedgeTimer := time.NewTimer(hedgeAfter)
+	defer hedgeTimer.Stop()
+	hedgeC := hedgeTimer.C
+	var winner, failed *upstreamAttempt
+	pending := 1
+	for winner == nil && pending > 0 {
+		select {
+		case attempt := <-results:
+			pending--
+			if attempt.err == nil {
+				winner = attempt
+			} else {
+				failed = attempt
+				attempt.release()
+			}
+		case <-hedgeC:
+			hedgeC = nil
+			// 对冲请求同样受模型并发上限限制，没有名额时不排队，继续等待已发送的请求
+			release, err := s.limiter.Acquire(c.Request.Context(), modelConfig.ID, modelConfig.MaxConcurrency, false, 0)
+			if err != nil {
+				setLogExtra(c, extraHedgeSkipped, true)
+				break
+			}
+			if err := launch(release); err != nil {
+				break
+			}
+			pending++
+			if len(attempts) <= modelConfig.MaxHedges {
+				hedgeTimer.Reset(hedgeAfter)
+				hedgeC = hedgeTimer.C
+			}
+		}
+	}
+
+	// 取消未胜出的请求，在后台等待它们结束后释放并发名额
+	for _, attempt := range attempts {
+		if attempt != winner {
+			attempt.cancel()
+		}
+	}
+	losersDone := make(chan struct{})
+	go func() {
+		defer close(losersDone)
+		for ; pending > 0; pending-- {
+			attempt := <-results
+			if attempt.resp != nil {
+				attempt.resp.Body.Close()
+			}
+			attempt.release()
+		}
+	}()
+
+	finish := func() {
+		recorded := winner
+		if recorded == nil {
+			recorded = failed
+		}
+		recorded.timer.record(c)
+		<-losersDone
+		if winner != nil {
+			winner.cancel()
+			winner.release()
+		}
+		if len(attempts) == 1 {
+			return
+		}
+		latencies := make([]float64, len(attempts))
+		for i, attempt := range attempts {
+			latencies[i] = attempt.latency
+		}
+		setLogExtra(c, extraHedged, true)
+		winnerIndex := 0
+		if winner != nil {
+			winnerIndex = winner.index
+		}
+		setLogExtra(c, extraHedgeWinner, winnerIndex)
+		setLogExtra(c, extraHedgeLatencies, latencies)
+	}
+	if winner == nil {
+		return nil, finish, failed.err
+	}
+	return winner.resp, finish, nil
+}
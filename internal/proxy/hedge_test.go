@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestHedgedRequest(t *testing.T) {
+	// 设置slowNext后下一个请求变慢，模拟原始请求偶尔卡住；其他请求立即返回
+	var slowNext atomic.Bool
+	var requests atomic.Int32
+	slowCanceled := make(chan struct{}, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		attempt := requests.Add(1)
+		if slowNext.CompareAndSwap(true, false) {
+			select {
+			case <-r.Context().Done():
+				slowCanceled <- struct{}{}
+				return
+			case <-time.After(300 * time.Millisecond):
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"attempt":` + strconv.Itoa(int(attempt)) + `}`))
+	}))
+	defer upstream.Close()
+
+	model := &config.ModelConfig{ID: "fast-chat", Name: "fast-chat", Target: "gpt-4o", Url: upstream.URL, HedgeAfterMs: 20}
+	if err := model.Validate(); err != nil {
+		t.Fatalf("模型配置验证失败: %v", err)
+	}
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{model.ID: model}}, nil)
+
+	var body string
+	var extra map[string]interface{}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Next()
+		value, _ := c.Get("log_extra")
+		extra, _ = value.(map[string]interface{})
+	})
+	r.Any("/*path", s.proxyHandler)
+	send := func(requestBody string) string {
+		t.Helper()
+		body = requestBody
+		slowNext.Store(true)
+		requests.Store(0)
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("期望状态码200，实际%d %s", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	// 原始请求超过hedge_after_ms未响应，使用对冲请求的结果并取消原始请求
+	if got := send(`{"model":"fast-chat","messages":[]}`); got != `{"attempt":2}` {
+		t.Errorf("期望返回对冲请求的响应，实际%s", got)
+	}
+	select {
+	case <-slowCanceled:
+	case <-time.After(time.Second):
+		t.Error("慢的原始请求应被取消")
+	}
+	latencies, _ := extra[extraHedgeLatencies].([]float64)
+	if extra[extraHedged] != true || extra[extraHedgeWinner] != 2 || len(latencies) != 2 {
+		t.Errorf("访问日志应记录对冲请求，实际%v", extra)
+	}
+
+	// 流式请求不对冲
+	if got := send(`{"model":"fast-chat","messages":[],"stream":true}`); got != `{"attempt":1}` {
+		t.Errorf("流式请求期望只发送原始请求，实际%s", got)
+	}
+	if _, ok := extra[extraHedged]; ok || requests.Load() != 1 {
+		t.Errorf("流式请求不应对冲，实际%d个请求 %v", requests.Load(), extra)
+	}
+
+	// 原始请求已占满并发名额时不发送对冲请求
+	model.MaxConcurrency = 1
+	if got := send(`{"model":"fast-chat","messages":[]}`); got != `{"attempt":1}` {
+		t.Errorf("没有并发名额时期望等待原始请求，实际%s", got)
+	}
+	if extra[extraHedgeSkipped] != true || requests.Load() != 1 {
+		t.Errorf("没有并发名额时应跳过对冲请求，实际%d个请求 %v", requests.Load(), extra)
+	}
+	if inFlight := s.ModelLoad(model.ID).InFlight; inFlight != 0 {
+		t.Errorf("请求结束后应释放并发名额，实际进行中%d", inFlight)
+	}
+}
@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// Idempotency-Key请求去重使用的请求头
+const (
+	HeaderIdempotencyKey   = "Idempotency-Key"           // 客户端为一次逻辑请求生成的唯一Key，重试时保持不变
+	HeaderIdempotentReplay = "X-Proxy-Idempotent-Replay" // 响应为重放第一次请求保存的响应时为true
+)
+
+// idempotencyOutcome 以Idempotency-Key开始处理请求的结果
+type idempotencyOutcome int
+
+const (
+	idempotencyProceed  idempotencyOutcome = iota // 第一次收到该Key，由当前请求转发
+	idempotencyReplay                             // 已有相同请求的响应，直接返回
+	idempotencyConflict                           // 该Key已用于内容不同的请求
+)
+
+// idempotentEntry 一个Idempotency-Key的处理状态，response为nil时第一次请求仍在处理中
+type idempotentEntry struct {
+	key      string
+	hash     string
+	modelID  string
+	response *cachedResponse
+	done     chan struct{} // 第一次请求完成或放弃时关闭
+	elem     *list.Element
+}
+
+// IdempotencyStore 按Idempotency-Key保存请求的响应，已完成的响应按LRU淘汰并在TTL后过期
+type IdempotencyStore struct {
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List // 已完成的条目，处理中的条目不计入容量
+	entries    map[string]*idempotentEntry
+	mutex      sync.Mutex
+}
+
+// NewIdempotencyStore 创建Idempotency-Key存储，maxEntries<=0时返回nil，不去重
+func NewIdempotencyStore(ttl time.Duration, maxEntries int) *IdempotencyStore {
+	if maxEntries <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &IdempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		entries:    make(map[string]*idempotentEntry),
+	}
+}
+
+// Begin 开始处理带Idempotency-Key的请求。相同Key的请求仍在处理中时等待其完成，
+// 等待期间ctx结束时返回ctx的错误
+func (s *IdempotencyStore) Begin(ctx context.Context, key, hash string) (*idempotentEntry, idempotencyOutcome, error) {
+	for {
+		s.mutex.Lock()
+		entry, ok := s.entries[key]
+		if ok && entry.response != nil && time.Now().After(entry.response.ExpiresAt) {
+			s.removeEntry(entry)
+			ok = false
+		}
+		if !ok {
+			entry = &idempotentEntry{key: key, hash: hash, done: make(chan struct{})}
+			s.entries[key] = entry
+			s.mutex.Unlock()
+			return entry, idempotencyProceed, nil
+		}
+		if entry.hash != hash {
+			s.mutex.Unlock()
+			return nil, idempotencyConflict, nil
+		}
+		if entry.response != nil {
+			s.ll.MoveToFront(entry.elem)
+			s.mutex.Unlock()
+			return entry, idempotencyReplay, nil
+		}
+		done := entry.done
+		s.mutex.Unlock()
+
+		select {
+		case <-done:
+			// 第一次请求已完成或放弃，重新检查
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+	}
+}
+
+// Complete 保存第一次请求的响应并唤醒等待的重复请求，超过容量时淘汰最久未使用的响应
+func (s *IdempotencyStore) Complete(entry *idempotentEntry, modelID string, response *cachedResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	response.ExpiresAt = time.Now().Add(s.ttl)
+	entry.modelID = modelID
+	entry.response = response
+	entry.elem = s.ll.PushFront(entry)
+	close(entry.done)
+	for s.ll.Len() > s.maxEntries {
+		s.removeEntry(s.ll.Back().Value.(*idempotentEntry))
+	}
+}
+
+// Release 第一次请求没有可保存的响应（如上游5xx、客户端断开）时释放Key，
+// 等待中的重复请求将由其中一个重新转发
+func (s *IdempotencyStore) Release(entry *idempotentEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.entries[entry.key] == entry {
+		delete(s.entries, entry.key)
+	}
+	close(entry.done)
+}
+
+// Len 返回已保存的响应数
+func (s *IdempotencyStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ll.Len()
+}
+
+// removeEntry 删除已完成的条目，调用方需持有锁
+func (s *IdempotencyStore) removeEntry(entry *idempotentEntry) {
+	s.ll.Remove(entry.elem)
+	delete(s.entries, entry.key)
+}
+
+// idempotencyRequestHash 计算请求方法、路径和原始请求体的摘要，相同Key的重试需完全一致
+func idempotencyRequestHash(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter 转发响应的同时保存响应体，供保存幂等请求的响应
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.body.Write(p[:n])
+	return n, err
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.body.WriteString(s[:n])
+	return n, err
+}
+
+// runIdempotentPipeline 带Idempotency-Key的非流式请求只转发一次：相同Key和相同请求的重试返回保存的响应，
+// 第一次请求处理中时等待其完成，相同Key但请求不同时返回422。流式请求和未带Key的请求直接执行流水线
+func (s *Server) runIdempotentPipeline(c *gin.Context) {
+	key := c.GetHeader(HeaderIdempotencyKey)
+	body := []byte(c.GetString("request_body"))
+	if key == "" || s.idempotency == nil || isStreamRequest(body) {
+		s.runPipeline(c)
+		return
+	}
+
+	// 不同API Key的请求互不影响
+	var apiKeyID uint
+	if apiKey := apiKeyFromContext(c); apiKey != nil {
+		apiKeyID = apiKey.ID
+	}
+	scopedKey := fmt.Sprintf("%d\x00%s", apiKeyID, key)
+	hash := idempotencyRequestHash(c.Request.Method, c.Request.URL.Path, body)
+
+	entry, outcome, err := s.idempotency.Begin(c.Request.Context(), scopedKey, hash)
+	if err != nil {
+		// 客户端在等待第一次请求完成时断开
+		markClientDisconnected(c)
+		c.Abort()
+		return
+	}
+	switch outcome {
+	case idempotencyConflict:
+		abortWithError(c, http.StatusUnprocessableEntity, i18n.CodeIdempotencyKeyConflict, errorTypeInvalidRequest)
+		return
+	case idempotencyReplay:
+		s.writeIdempotentReplay(c, entry)
+		return
+	}
+
+	recorder := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	completed := false
+	defer func() {
+		c.Writer = recorder.ResponseWriter
+		if !completed {
+			s.idempotency.Release(entry)
+		}
+	}()
+
+	s.runPipeline(c)
+
+	// 上游5xx、限流、转发失败、响应不完整或客户端断开时不保存，重试会重新转发
+	status := recorder.Status()
+	if !recorder.Written() || status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+		c.GetBool("client_disconnected") || c.GetBool("response_incomplete") {
+		return
+	}
+	header := recorder.Header().Clone()
+	header.Del("Content-Length")
+	s.idempotency.Complete(entry, c.GetString("model_id"), &cachedResponse{
+		StatusCode: status,
+		Header:     header,
+		Body:       recorder.body.Bytes(),
+	})
+	completed = true
+}
+
+// writeIdempotentReplay 返回第一次请求保存的响应，重放的响应不再计入错误统计
+func (s *Server) writeIdempotentReplay(c *gin.Context, entry *idempotentEntry) {
+	response := entry.response
+	for key, values := range response.Header {
+		if isCORSResponseHeader(c, key) {
+			continue
+		}
+		for _, value := range values {
+			c.Writer.Header().Add(key, value)
+		}
+	}
+	c.Header(HeaderIdempotentReplay, "true")
+	c.Set("model_id", entry.modelID)
+	c.Set("response_body", string(response.Body))
+	setLogExtra(c, "idempotent_replay", true)
+	c.Status(response.StatusCode)
+	c.Writer.Write(response.Body)
+}
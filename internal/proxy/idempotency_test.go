@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// newIdempotencyTestRouter 创建代理路由，上游按handler响应
+func newIdempotencyTestRouter(t *testing.T, handler http.HandlerFunc) (*Server, *gin.Engine) {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"dall-e-3": {ID: "dall-e-3", Name: "DALL-E 3", Target: "dall-e-3", Url: upstream.URL, Type: config.ModelTypeImage},
+	}}
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+	return s, r
+}
+
+// sendIdempotent 发送带Idempotency-Key的图片生成请求
+func sendIdempotent(r *gin.Engine, key, prompt string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations",
+		strings.NewReader(`{"model":"dall-e-3","prompt":"`+prompt+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplayAndConflict(t *testing.T) {
+	var calls atomic.Int32
+	_, r := newIdempotencyTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream-Call", strings.Repeat("i", int(n)))
+		w.Write([]byte(`{"data":[{"url":"https://example.com/cat.png"}]}`))
+	})
+
+	first := sendIdempotent(r, "order-1", "a cat")
+	if first.Code != http.StatusOK || first.Header().Get(HeaderIdempotentReplay) != "" {
+		t.Fatalf("第一次请求 = %d %v", first.Code, first.Header())
+	}
+	retry := sendIdempotent(r, "order-1", "a cat")
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() {
+		t.Errorf("重试应返回相同的响应: %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(HeaderIdempotentReplay) != "true" || retry.Header().Get("X-Upstream-Call") != "i" {
+		t.Errorf("重放的响应头 = %v", retry.Header())
+	}
+	if calls.Load() != 1 {
+		t.Errorf("上游调用次数 = %d, want 1", calls.Load())
+	}
+
+	// 相同Key但请求体不同
+	conflict := sendIdempotent(r, "order-1", "a dog")
+	if conflict.Code != http.StatusUnprocessableEntity || !strings.Contains(conflict.Body.String(), "idempotency_key_conflict") {
+		t.Errorf("相同Key不同请求体应返回422: %d %s", conflict.Code, conflict.Body.String())
+	}
+
+	// 不同的Key和未带Key的请求正常转发
+	sendIdempotent(r, "order-2", "a cat")
+	sendIdempotent(r, "", "a cat")
+	sendIdempotent(r, "", "a cat")
+	if calls.Load() != 4 {
+		t.Errorf("上游调用次数 = %d, want 4", calls.Load())
+	}
+}
+
+func TestIdempotencySkipsFailuresAndStreams(t *testing.T) {
+	var calls atomic.Int32
+	_, r := newIdempotencyTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	// 上游5xx的响应不保存，重试重新转发
+	if w := sendIdempotent(r, "retry", "a cat"); w.Code != http.StatusBadGateway {
+		t.Fatalf("第一次请求应返回502，实际得到%d", w.Code)
+	}
+	if w := sendIdempotent(r, "retry", "a cat"); w.Code != http.StatusOK || w.Header().Get(HeaderIdempotentReplay) != "" {
+		t.Errorf("5xx后重试应重新转发: %d %v", w.Code, w.Header())
+	}
+
+	// 流式请求不去重
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/images/generations",
+			strings.NewReader(`{"model":"dall-e-3","prompt":"a cat","stream":true}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(HeaderIdempotencyKey, "stream")
+		r.ServeHTTP(w, req)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("上游调用次数 = %d, want 4", calls.Load())
+	}
+}
+
+func TestIdempotencyConcurrentWait(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	_, r := newIdempotencyTestRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(`{"data":[]}`))
+	})
+
+	const clients = 5
+	responses := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = sendIdempotent(r, "concurrent", "a cat")
+		}(i)
+	}
+	// 等待第一个请求到达上游后再放行，其余请求此时应在等待
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("并发的重复请求应只转发一次，实际转发%d次", calls.Load())
+	}
+	replays := 0
+	for _, w := range responses {
+		if w.Code != http.StatusOK || w.Body.String() != `{"data":[]}` {
+			t.Errorf("响应 = %d %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get(HeaderIdempotentReplay) == "true" {
+			replays++
+		}
+	}
+	if replays != clients-1 {
+		t.Errorf("重放的响应数 = %d, want %d", replays, clients-1)
+	}
+}
+
+func TestIdempotencyStoreExpiry(t *testing.T) {
+	store := NewIdempotencyStore(20*time.Millisecond, 2)
+	ctx := context.Background()
+
+	entry, outcome, _ := store.Begin(ctx, "a", "hash")
+	if outcome != idempotencyProceed {
+		t.Fatalf("第一次请求的结果 = %v", outcome)
+	}
+	store.Complete(entry, "dall-e-3", &cachedResponse{StatusCode: http.StatusOK})
+	if _, outcome, _ := store.Begin(ctx, "a", "hash"); outcome != idempotencyReplay {
+		t.Errorf("TTL内重试应重放，实际得到%v", outcome)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	entry, outcome, _ = store.Begin(ctx, "a", "other")
+	if outcome != idempotencyProceed {
+		t.Errorf("过期后相同Key应重新转发，实际得到%v", outcome)
+	}
+	store.Release(entry)
+
+	// 超过容量时淘汰最久未使用的响应
+	for _, key := range []string{"x", "y", "z"} {
+		entry, _, _ := store.Begin(ctx, key, "hash")
+		store.Complete(entry, "", &cachedResponse{StatusCode: http.StatusOK})
+	}
+	if store.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", store.Len())
+	}
+	if _, outcome, _ := store.Begin(ctx, "x", "hash"); outcome != idempotencyProceed {
+		t.Errorf("被淘汰的Key应重新转发，实际得到%v", outcome)
+	}
+
+	// 等待期间客户端断开
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := store.Begin(waitCtx, "x", "hash"); err == nil {
+		t.Error("第一次请求未完成时等待应随ctx结束")
+	}
+}
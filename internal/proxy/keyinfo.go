@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// KeyInfoPath 查询当前API Key信息的路径，由代理直接响应
+const KeyInfoPath = "/key-info"
+
+// KeyInfo 当前API Key的状态信息，不包含完整的Key值
+type KeyInfo struct {
+	Name          string     `json:"name"`
+	KeyPreview    string     `json:"key_preview"`    // 脱敏后的Key值
+	IsEnabled     bool       `json:"is_enabled"`     // 是否启用
+	ExpiresAt     *time.Time `json:"expires_at"`     // 过期时间，null表示永不过期
+	AllowedModels []string   `json:"allowed_models"` // 允许调用的模型ID，为空表示不限制
+}
+
+// newKeyInfo 根据API Key生成状态信息
+func newKeyInfo(apiKey *db.APIKey) KeyInfo {
+	allowedModels := []string(apiKey.AllowedModels)
+	if allowedModels == nil {
+		allowedModels = []string{}
+	}
+	return KeyInfo{
+		Name:          apiKey.Name,
+		KeyPreview:    maskSecret(apiKey.KeyValue),
+		IsEnabled:     apiKey.IsEnabled,
+		ExpiresAt:     apiKey.ExpiresAt,
+		AllowedModels: allowedModels,
+	}
+}
+
+// keyInfo 返回认证中间件已验证的API Key的状态信息（GET /key-info）
+func (s *Server) keyInfo(c *gin.Context) {
+	apiKey := apiKeyFromContext(c)
+	if apiKey == nil {
+		abortWithError(c, http.StatusUnauthorized, i18n.CodeMissingAPIKey, errorTypeAuthentication)
+		return
+	}
+
+	c.JSON(http.StatusOK, newKeyInfo(apiKey))
+}
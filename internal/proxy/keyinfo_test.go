@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+func TestKeyInfo(t *testing.T) {
+	upstreamCalled := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalled = true
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat-a": {ID: "chat-a", Name: "A", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat},
+	}}
+	s := NewServer(cfg, nil)
+
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	apiKey := &db.APIKey{
+		Name:          "search-prod",
+		KeyValue:      "ak_0123456789abcdef",
+		IsEnabled:     true,
+		ExpiresAt:     &expiresAt,
+		AllowedModels: db.StringList{"chat-a"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Proxy-Key") != "" {
+			c.Set("api_key_info", apiKey)
+		}
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, KeyInfoPath, nil)
+	req.Header.Set("X-Proxy-Key", apiKey.KeyValue)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if upstreamCalled {
+		t.Error("查询API Key信息不应转发到上游")
+	}
+	if strings.Contains(w.Body.String(), apiKey.KeyValue) {
+		t.Errorf("响应不应包含完整的Key值: %s", w.Body.String())
+	}
+
+	var info KeyInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if info.Name != "search-prod" || !info.IsEnabled || info.KeyPreview != "ak_0***" {
+		t.Errorf("unexpected key info: %+v", info)
+	}
+	if info.ExpiresAt == nil || !info.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expires_at = %v, want %v", info.ExpiresAt, expiresAt)
+	}
+	if len(info.AllowedModels) != 1 || info.AllowedModels[0] != "chat-a" {
+		t.Errorf("allowed_models = %v", info.AllowedModels)
+	}
+
+	// 没有经过认证的请求返回401
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, KeyInfoPath, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("未认证请求的状态码 = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+func serveWithKey(t *testing.T, apiKey *db.APIKey, modelID string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"chat.completion"}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat-a":    {ID: "chat-a", Name: "A", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat},
+		"chat-b":    {ID: "chat-b", Name: "B", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat},
+		"team-chat": {ID: "team-chat", Name: "T", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat, Tenant: "team-a"},
+	}}
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"` + modelID + `","messages":[]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Set("api_key_info", apiKey)
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestScopedKeyAllowed(t *testing.T) {
+	w := serveWithKey(t, &db.APIKey{AllowedModels: db.StringList{"chat-a"}}, "chat-a")
+	if w.Code != http.StatusOK {
+		t.Fatalf("允许列表内的模型应转发成功，实际状态码%d，响应%s", w.Code, w.Body.String())
+	}
+}
+
+func TestScopedKeyDenied(t *testing.T) {
+	w := serveWithKey(t, &db.APIKey{AllowedModels: db.StringList{"chat-a"}}, "chat-b")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("期望状态码403，实际得到%d", w.Code)
+	}
+	if code := gjson.Get(w.Body.String(), "error.code").String(); code != "key_not_allowed_for_model" {
+		t.Errorf("期望错误码key_not_allowed_for_model，实际得到%q", code)
+	}
+}
+
+func TestUnscopedKeyUnaffected(t *testing.T) {
+	for _, modelID := range []string{"chat-a", "chat-b"} {
+		w := serveWithKey(t, &db.APIKey{}, modelID)
+		if w.Code != http.StatusOK {
+			t.Errorf("未限制模型的Key调用%s应成功，实际状态码%d", modelID, w.Code)
+		}
+	}
+}
+
+func TestCrossTenantKeyDenied(t *testing.T) {
+	// 未限制模型的Key也只能调用所属租户的模型
+	for _, tc := range []struct {
+		apiKey  *db.APIKey
+		modelID string
+	}{
+		{&db.APIKey{}, "team-chat"},
+		{&db.APIKey{TenantID: "team-a"}, "chat-a"},
+		{&db.APIKey{TenantID: "team-a", AllowedModels: db.StringList{"chat-a"}}, "chat-a"},
+	} {
+		w := serveWithKey(t, tc.apiKey, tc.modelID)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("%q租户的Key调用%s期望状态码403，实际得到%d", tc.apiKey.TenantID, tc.modelID, w.Code)
+		}
+		if code := gjson.Get(w.Body.String(), "error.code").String(); code != "model_tenant_mismatch" {
+			t.Errorf("期望错误码model_tenant_mismatch，实际得到%q", code)
+		}
+	}
+
+	if w := serveWithKey(t, &db.APIKey{TenantID: "team-a"}, "team-chat"); w.Code != http.StatusOK {
+		t.Errorf("同一租户的Key应转发成功，实际状态码%d，响应%s", w.Code, w.Body.String())
+	}
+}
+
+func TestCrossTenantModelsHidden(t *testing.T) {
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat-a":    {ID: "chat-a", Name: "A", Target: "gpt-4o", Url: "http://upstream", Type: config.ModelTypeChat, Aliases: []string{"chat-latest"}},
+		"team-chat": {ID: "team-chat", Name: "T", Target: "gpt-4o", Url: "http://upstream", Type: config.ModelTypeChat, Tenant: "team-a"},
+	}}
+	cfg.RebuildIndex()
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	get := func(apiKey *db.APIKey, path string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("api_key_info", apiKey) })
+		r.Any("/*path", s.proxyHandler)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// 未限制模型的Key也只能列出和查看所属租户的模型
+	teamKey := &db.APIKey{TenantID: "team-a"}
+	if ids := gjson.Get(get(teamKey, "/v1/models").Body.String(), "data.#.id").String(); ids != `["team-chat"]` {
+		t.Errorf("team-a的Key列出的模型 = %s, want [team-chat]", ids)
+	}
+	if ids := gjson.Get(get(&db.APIKey{}, "/v1/models").Body.String(), "data.#.id").String(); ids != `["chat-a","chat-latest"]` {
+		t.Errorf("默认租户的Key列出的模型 = %s", ids)
+	}
+	for _, path := range []string{"/v1/models/chat-a", "/v1/models/chat-latest"} {
+		if w := get(teamKey, path); w.Code != http.StatusNotFound {
+			t.Errorf("查看其他租户的模型%s期望404，实际%d", path, w.Code)
+		}
+	}
+	if w := get(teamKey, "/v1/models/team-chat"); w.Code != http.StatusOK {
+		t.Errorf("查看所属租户的模型期望200，实际%d", w.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// globalMaintenance 获取全局维护模式配置
+func (s *Server) globalMaintenance() config.MaintenanceConfig {
+	if s.configService == nil {
+		return config.MaintenanceConfig{}
+	}
+	return s.configService.GetMaintenance()
+}
+
+// maintenanceCompletion 构造OpenAI chat completion格式的维护响应，便于SDK将提示信息作为助手回复展示
+func maintenanceCompletion(id, modelID, message string, created int64) gin.H {
+	return gin.H{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": created,
+		"model":   modelID,
+		"choices": []gin.H{{
+			"index":         0,
+			"message":       gin.H{"role": "assistant", "content": message},
+			"finish_reason": "stop",
+		}},
+		"usage": gin.H{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+	}
+}
+
+// maintenanceChunk 构造OpenAI chat completion chunk格式的流式维护响应片段
+func maintenanceChunk(id, modelID string, created int64, delta gin.H, finishReason interface{}) string {
+	chunk, _ := json.Marshal(gin.H{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   modelID,
+		"choices": []gin.H{{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	})
+	return "data: " + string(chunk) + "\n\n"
+}
+
+// writeMaintenanceResponse 返回维护模式的固定响应，stream为true时以SSE格式返回
+func writeMaintenanceResponse(c *gin.Context, modelID string, maintenance config.MaintenanceConfig, stream bool) {
+	id := "chatcmpl-maintenance-" + c.GetString("request_id")
+	created := time.Now().Unix()
+	setLogExtra(c, "maintenance", true)
+	c.Set("error", "维护模式: "+maintenance.Message)
+
+	if !stream {
+		body := maintenanceCompletion(id, modelID, maintenance.Message, created)
+		if data, err := json.Marshal(body); err == nil {
+			c.Set("response_body", string(data))
+		}
+		c.JSON(maintenance.StatusCode, body)
+		return
+	}
+
+	body := maintenanceChunk(id, modelID, created, gin.H{"role": "assistant", "content": maintenance.Message}, nil) +
+		maintenanceChunk(id, modelID, created, gin.H{}, "stop") +
+		"data: [DONE]\n\n"
+	c.Set("response_body", body)
+	c.Header("Cache-Control", "no-cache")
+	c.Data(maintenance.StatusCode, "text/event-stream; charset=utf-8", []byte(body))
+}
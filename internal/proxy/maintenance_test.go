@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
+)
+
+func serveMaintenance(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {
+			ID: "chat", Name: "Chat", Target: "gpt-4o", Url: "http://127.0.0.1:1", Type: config.ModelTypeChat,
+			Maintenance: true, MaintenanceMessage: "上游故障，正在处理",
+		},
+	}}
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_id", "test")
+		c.Set("request_body", body)
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMaintenanceJSONResponse(t *testing.T) {
+	w := serveMaintenance(t, `{"model":"chat","messages":[]}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("期望状态码503，实际得到%d", w.Code)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if resp["object"] != "chat.completion" || resp["model"] != "chat" {
+		t.Errorf("响应格式不正确: %s", w.Body.String())
+	}
+	if content := gjson.GetBytes(w.Body.Bytes(), "choices.0.message.content").String(); content != "上游故障，正在处理" {
+		t.Errorf("期望维护提示作为助手回复，实际得到%q", content)
+	}
+}
+
+func TestMaintenanceSSEResponse(t *testing.T) {
+	w := serveMaintenance(t, `{"model":"chat","stream":true,"messages":[]}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("期望状态码503，实际得到%d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("期望SSE响应，实际Content-Type为%s", ct)
+	}
+
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != 3 || events[2] != "data: [DONE]" {
+		t.Fatalf("SSE事件不正确: %q", w.Body.String())
+	}
+	first := strings.TrimPrefix(events[0], "data: ")
+	if content := gjson.Get(first, "choices.0.delta.content").String(); content != "上游故障，正在处理" {
+		t.Errorf("期望第一个片段包含维护提示，实际得到%q", content)
+	}
+	if reason := gjson.Get(strings.TrimPrefix(events[1], "data: "), "choices.0.finish_reason").String(); reason != "stop" {
+		t.Errorf("期望结束片段finish_reason为stop，实际得到%q", reason)
+	}
+}
+
+func TestModelMaintenanceMode(t *testing.T) {
+	model := &config.ModelConfig{}
+	if _, ok := model.MaintenanceMode(config.MaintenanceConfig{}); ok {
+		t.Error("未开启维护模式时不应生效")
+	}
+
+	maintenance, ok := model.MaintenanceMode(config.MaintenanceConfig{Enabled: true, StatusCode: http.StatusOK})
+	if !ok || maintenance.StatusCode != http.StatusOK || maintenance.Message != config.DefaultMaintenanceMessage {
+		t.Errorf("全局维护模式配置不正确: %+v", maintenance)
+	}
+}
+
+func TestUpstreamDownFastFail(t *testing.T) {
+	model := &config.ModelConfig{
+		ID: "chat", Name: "Chat", Target: "gpt-4o", Url: "http://127.0.0.1:1", Type: config.ModelTypeChat,
+		HealthCheckInterval: 30,
+	}
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{"chat": model}}
+	s := NewServer(cfg, nil)
+	monitor := healthcheck.NewMonitor(func() []*config.ModelConfig { return []*config.ModelConfig{model} }, time.Second)
+	monitor.Check(context.Background(), model)
+	s.SetHealthMonitor(monitor)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	body := `{"model":"chat","messages":[]}`
+	r.Use(func(c *gin.Context) {
+		c.Set("request_id", "test")
+		c.Set("request_body", body)
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("期望503和Retry-After，实际%d %v", w.Code, w.Header())
+	}
+	if code := gjson.GetBytes(w.Body.Bytes(), "error.code").String(); code != "upstream_down" {
+		t.Errorf("期望错误码upstream_down，实际%s", w.Body.String())
+	}
+}
@@ -1,9 +1,16 @@
 package proxy
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
 	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,22 +18,100 @@ func APIAuthMiddleware(c *gin.Context) {
 
 }
 
-func AccessLogMiddleware(c *gin.Context) {
-	startTime := time.Now()
-	c.Next()
-	headers := make(map[string]string, len(c.Request.Header))
-	for k, v := range c.Request.Header {
+// setLogExtra 设置访问日志的扩展字段
+func setLogExtra(c *gin.Context, key string, value interface{}) {
+	extra, ok := c.Get("log_extra")
+	if !ok {
+		extra = make(map[string]interface{})
+		c.Set("log_extra", extra)
+	}
+	extra.(map[string]interface{})[key] = value
+}
+
+// setLabelLogExtra 将API Key的标签写入访问日志扩展字段，可在日志格式中以$标签名引用；
+// 之后设置的同名扩展字段（如$cache）会覆盖标签
+func setLabelLogExtra(c *gin.Context, apiKey *db.APIKey) {
+	for key, value := range apiKey.Labels {
+		setLogExtra(c, key, value)
+	}
+}
+
+// maskedValueVisible 脱敏后保留的明文字符数
+const maskedValueVisible = 4
+
+// maskSecret 只保留值的前几位，其余替换为***；带认证方案（如Bearer）的值保留方案名，
+// 过短的值全部隐藏
+func maskSecret(value string) string {
+	scheme, secret := "", value
+	if i := strings.IndexByte(value, ' '); i >= 0 {
+		scheme, secret = value[:i+1], strings.TrimLeft(value[i+1:], " ")
+	}
+	if len(secret) <= maskedValueVisible*2 {
+		return scheme + "***"
+	}
+	return scheme + secret[:maskedValueVisible] + "***"
+}
+
+// logHeaders 复制请求头用于访问日志，masked中的头部（小写名称）只记录脱敏后的值
+func logHeaders(header http.Header, masked map[string]bool) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k, v := range header {
 		headers[k] = v[0] // 只记录第一个值
+		if masked[strings.ToLower(k)] {
+			headers[k] = maskSecret(v[0])
+		}
+	}
+	return headers
+}
+
+// AccessLogMiddleware 请求结束后记录访问日志，maskHeaders中的请求头在写入日志前脱敏，
+// 包含X-Proxy-Key时$api_key同样脱敏
+func AccessLogMiddleware(maskHeaders []string) gin.HandlerFunc {
+	masked := make(map[string]bool, len(maskHeaders))
+	for _, name := range maskHeaders {
+		masked[strings.ToLower(name)] = true
 	}
-	// 记录访问日志
-	logData := logger.RequestLogData{
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		// 每个请求只抽取一次body采样值，保存响应体和各记录器写入日志时使用同一个值
+		c.Set("body_sample", logger.NewBodySample())
+		c.Next()
+		logData := requestLogData(c, startTime, masked)
+		go func() {
+			logger.GlobalLoggerManager.LogToAll(logData)
+		}()
+	}
+}
+
+// requestLogData 根据请求上下文生成访问日志数据
+func requestLogData(c *gin.Context, startTime time.Time, masked map[string]bool) logger.RequestLogData {
+	var extra map[string]interface{}
+	if value, ok := c.Get("log_extra"); ok {
+		extra = value.(map[string]interface{})
+	}
+	// 流式响应使用转发时记录的实际状态码和字节数
+	statusCode := c.Writer.Status()
+	if value, ok := c.Get("response_status"); ok && c.Writer.Written() {
+		statusCode = value.(int)
+	}
+	responseSize := int64(c.Writer.Size())
+	if value, ok := c.Get("response_size"); ok {
+		responseSize = value.(int64)
+	}
+	headers := logHeaders(c.Request.Header, masked)
+	apiKey := c.GetString("api_key")
+	if apiKey != "" && masked["x-proxy-key"] {
+		apiKey = maskSecret(apiKey)
+	}
+	return logger.RequestLogData{
 		RequestID:    c.GetString("request_id"),
 		Timestamp:    startTime,
 		Method:       c.Request.Method,
 		Path:         c.Request.URL.Path,
 		UserAgent:    c.Request.UserAgent(),
 		ClientIP:     c.GetString("client_ip"),
-		APIKey:       c.GetString("api_key"),
+		RemoteAddr:   c.GetString("remote_addr"),
+		APIKey:       apiKey,
 		UserID:       c.GetUint("user_id"),
 		RequestSize:  c.Request.ContentLength,
 		RequestBody:  c.GetString("request_body"), // 原始请求body
@@ -37,13 +122,46 @@ func AccessLogMiddleware(c *gin.Context) {
 		ProxyScheme:  c.GetString("proxy_scheme"),
 		ProxyHost:    c.GetString("proxy_host"),
 		UpstreamBody: c.GetString("proxy_body"), // 发送给上游的body
-		StatusCode:   c.Writer.Status(),
-		ResponseSize: int64(c.Writer.Size()),
+		StatusCode:   statusCode,
+		ResponseSize: responseSize,
 		ResponseTime: time.Since(startTime).Milliseconds(),
 		ResponseBody: c.GetString("response_body"), // 响应body
 		Error:        c.GetString("error"),
+		Extra:        extra,
+
+		ResponseBodyDropped: c.GetInt64("response_body_dropped"),
+		BodySample:          c.GetFloat64("body_sample"),
+	}
+}
+
+// RequestTimeoutMiddleware 为请求设置整体截止时间，超时后返回504；
+// 流式请求使用单独的streamTimeout，避免正常的长时间流式响应被提前中断，超时为0表示不限制
+func RequestTimeoutMiddleware(timeout, streamTimeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := timeout
+		if isStreamRequest([]byte(c.GetString("request_body"))) {
+			limit = streamTimeout
+		}
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limit)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		setErrorClass(c, stats.ErrorClassTimeout)
+		setLogExtra(c, "timeout", true)
+		if c.Writer.Written() {
+			c.Set("error", i18n.Message(i18n.Default, i18n.CodeRequestTimeout, limit))
+			return
+		}
+		abortWithError(c, http.StatusGatewayTimeout, i18n.CodeRequestTimeout, "timeout_error", limit)
 	}
-	go func() {
-		logger.GlobalLoggerManager.LogToAll(logData)
-	}()
 }
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+	"github.com/eolinker/ai-prompt-proxy/internal/recovery"
+)
+
+func serveWithTimeout(body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+	})
+	r.Use(RequestTimeoutMiddleware(20*time.Millisecond, 0))
+	r.POST("/v1/chat/completions", handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	return w
+}
+
+func TestRequestTimeoutMiddleware(t *testing.T) {
+	w := serveWithTimeout(`{"model":"chat"}`, func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(time.Second):
+			c.String(http.StatusOK, "ok")
+		}
+	})
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("期望超时返回504，实际得到%d", w.Code)
+	}
+	if code := gjson.Get(w.Body.String(), "error.code").String(); code != "request_timeout" {
+		t.Errorf("期望错误码request_timeout，实际响应%s", w.Body.String())
+	}
+}
+
+func TestRequestTimeoutMiddlewareExemptsStream(t *testing.T) {
+	w := serveWithTimeout(`{"model":"chat","stream":true}`, func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+			c.String(http.StatusOK, "ok")
+		}
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("流式请求不应受非流式超时限制，实际状态码%d", w.Code)
+	}
+}
+
+func TestLabelsInFormattedLogLine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	setLabelLogExtra(c, &db.APIKey{Labels: db.Labels{"team": "search", "env": "staging"}})
+	setLogExtra(c, "cache", "HIT")
+
+	extra := c.MustGet("log_extra").(map[string]interface{})
+	formatter := logger.NewLineFormatter(logger.FormatterConfig{Fields: map[string][]string{
+		"fields": {"$request_id", "$team", "$env", "$cache"},
+	}})
+	line, err := formatter.Format(&logger.RequestLogData{RequestID: "req-1", Extra: extra})
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	for _, want := range []string{"req-1", "search", "staging", "HIT"} {
+		if !strings.Contains(string(line), want) {
+			t.Errorf("日志行中缺少%q: %s", want, line)
+		}
+	}
+}
+
+func TestLogHeadersMasksSecrets(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Proxy-Key", "pk-1234567890abcdef")
+	header.Set("Authorization", "Bearer sk-abcdefghijklmnop")
+	header.Set("Api-Key", "short")
+	header.Set("Content-Type", "application/json")
+
+	masked := map[string]bool{"x-proxy-key": true, "authorization": true, "api-key": true}
+	headers := logHeaders(header, masked)
+
+	want := map[string]string{
+		"X-Proxy-Key":   "pk-1***",
+		"Authorization": "Bearer sk-a***",
+		"Api-Key":       "***",
+		"Content-Type":  "application/json",
+	}
+	for name, value := range want {
+		if headers[name] != value {
+			t.Errorf("请求头%s期望记录为%q，实际得到%q", name, value, headers[name])
+		}
+	}
+}
+
+func TestRequestLogDataMasksAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	c.Request.Header.Set("X-Proxy-Key", "pk-1234567890abcdef")
+	c.Set("api_key", "pk-1234567890abcdef")
+
+	data := requestLogData(c, time.Now(), map[string]bool{"x-proxy-key": true})
+	if data.APIKey != "pk-1***" || data.Headers["X-Proxy-Key"] != "pk-1***" {
+		t.Errorf("期望API Key脱敏后记录，实际得到api_key=%q headers=%v", data.APIKey, data.Headers)
+	}
+
+	// 不脱敏X-Proxy-Key时保持原样
+	data = requestLogData(c, time.Now(), nil)
+	if data.APIKey != "pk-1234567890abcdef" {
+		t.Errorf("未配置脱敏时期望记录原始值，实际得到%q", data.APIKey)
+	}
+}
+
+func TestPanicRecoveryResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var data logger.RequestLogData
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		startTime := time.Now()
+		c.Next()
+		data = requestLogData(c, startTime, nil)
+	})
+	r.Use(recovery.Middleware(respondPanic))
+	r.Use(func(c *gin.Context) {
+		c.Set("request_id", "req-1")
+	})
+	r.POST("/v1/chat/completions", func(c *gin.Context) {
+		panic("nil map")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if gjson.Get(body, "error.code").String() != "internal_error" || gjson.Get(body, "error.request_id").String() != "req-1" {
+		t.Errorf("期望返回带请求ID的结构化错误，实际为%s", body)
+	}
+	if data.StatusCode != http.StatusInternalServerError || data.Error != "panic: nil map" || data.Extra[logger.ExtraPanicStack] == nil {
+		t.Errorf("访问日志应记录panic信息，实际status=%d error=%q", data.StatusCode, data.Error)
+	}
+}
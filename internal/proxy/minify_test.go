@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestMinifyJSON(t *testing.T) {
+	body := "{\n  \"model\": \"gpt-4o\",\n  \"messages\": [\n    {\"role\": \"user\", \"content\": \"a  b\\n c\"}\n  ],\n  \"temperature\": 0.5\n}\n"
+	want := `{"model":"gpt-4o","messages":[{"role":"user","content":"a  b\n c"}],"temperature":0.5}`
+	if got := string(minifyJSON([]byte(body))); got != want {
+		t.Errorf("minifyJSON() = %s, want %s", got, want)
+	}
+
+	invalid := []byte("{\"model\": ")
+	if got := minifyJSON(invalid); string(got) != string(invalid) {
+		t.Errorf("无法解析的请求体应原样返回，实际得到 %q", got)
+	}
+}
+
+func TestMinifyBodyProxy(t *testing.T) {
+	var forwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"compact": {ID: "compact", Name: "Compact", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeEmbedding, MinifyBody: true},
+		"verbose": {ID: "verbose", Name: "Verbose", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeEmbedding},
+	}}
+	s := NewServer(cfg, nil)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	send := func(model string) {
+		t.Helper()
+		body := "{\n  \"model\": \"" + model + "\",\n  \"input\": [ \"hello world\", \"hi there\" ]\n}"
+		req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+
+	send("compact")
+	if want := `{"model":"gpt-4o","input":["hello world","hi there"]}`; forwarded != want {
+		t.Errorf("开启minify_body时应转发紧凑的请求体\n got: %s\nwant: %s", forwarded, want)
+	}
+
+	send("verbose")
+	if !strings.Contains(forwarded, "\n  \"input\": [ \"hello world\"") {
+		t.Errorf("未开启minify_body时应保留请求体的原有格式: %s", forwarded)
+	}
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// OpenAIModel OpenAI格式的模型信息
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// OpenAIModelList OpenAI格式的模型列表
+type OpenAIModelList struct {
+	Object string        `json:"object"`
+	Data   []OpenAIModel `json:"data"`
+}
+
+// listModels 返回当前API Key可用的模型列表（GET /v1/models）
+func (s *Server) listModels(c *gin.Context) {
+	createdTimes := s.modelCreatedTimes()
+
+	models := s.config.SnapshotModels()
+	data := make([]OpenAIModel, 0, len(models))
+	for _, model := range models {
+		if !s.modelAllowed(c, model) {
+			continue
+		}
+		data = append(data, newOpenAIModel(model, createdTimes[model.ID]))
+		// 别名也是客户端可以使用的模型ID，一并列出供SDK发现
+		for _, alias := range model.Aliases {
+			entry := newOpenAIModel(model, createdTimes[model.ID])
+			entry.ID = alias
+			data = append(data, entry)
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].ID < data[j].ID
+	})
+
+	c.JSON(http.StatusOK, OpenAIModelList{
+		Object: "list",
+		Data:   data,
+	})
+}
+
+// retrieveModel 返回单个模型信息（GET /v1/models/:id）
+func (s *Server) retrieveModel(c *gin.Context, modelID string) {
+	c.Set("model_id", modelID)
+	model, exists := s.config.GetModel(modelID)
+	if !exists || !s.modelAllowed(c, model) {
+		abortWithError(c, http.StatusNotFound, i18n.CodeModelNotFound, errorTypeInvalidRequest, modelID)
+		return
+	}
+
+	c.JSON(http.StatusOK, newOpenAIModel(model, s.modelCreatedTimes()[model.ID]))
+}
+
+// modelAllowed 检查当前请求是否可以使用该模型，API Key只能看到所属租户的模型
+func (s *Server) modelAllowed(c *gin.Context, model *config.ModelConfig) bool {
+	if apiKey := apiKeyFromContext(c); apiKey != nil && config.TenantOrDefault(apiKey.TenantID) != config.TenantOrDefault(model.Tenant) {
+		return false
+	}
+	return !model.Disabled && keyAllowsModel(c, model.ID)
+}
+
+// keyAllowsModel 检查当前请求的API Key是否允许调用该模型
+func keyAllowsModel(c *gin.Context, modelID string) bool {
+	apiKey := apiKeyFromContext(c)
+	return apiKey == nil || apiKey.AllowsModel(modelID)
+}
+
+// apiKeyFromContext 获取当前请求的API Key信息，未认证时返回nil
+func apiKeyFromContext(c *gin.Context) *db.APIKey {
+	value, exists := c.Get("api_key_info")
+	if !exists {
+		return nil
+	}
+	apiKey, _ := value.(*db.APIKey)
+	return apiKey
+}
+
+// modelCreatedTimes 从数据库获取模型的创建时间（Unix秒）
+func (s *Server) modelCreatedTimes() map[string]int64 {
+	times := make(map[string]int64)
+	if s.configService == nil {
+		return times
+	}
+
+	dbModels, err := s.configService.GetAllModelsWithTime()
+	if err != nil {
+		fmt.Printf("获取模型创建时间失败: %v\n", err)
+		return times
+	}
+	for _, dbModel := range dbModels {
+		times[dbModel.ID] = dbModel.CreatedAt.Unix()
+	}
+	return times
+}
+
+// newOpenAIModel 构建OpenAI格式的模型信息
+func newOpenAIModel(model *config.ModelConfig, created int64) OpenAIModel {
+	return OpenAIModel{
+		ID:      model.ID,
+		Object:  "model",
+		Created: created,
+		OwnedBy: "proxy",
+	}
+}
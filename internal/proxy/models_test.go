@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func newModelsTestServer() *Server {
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat-b":   {ID: "chat-b", Name: "B", Target: "gpt-4o", Url: "http://127.0.0.1:8080", Type: config.ModelTypeChat},
+		"chat-a":   {ID: "chat-a", Name: "A", Target: "gpt-4o", Url: "http://127.0.0.1:8080", Type: config.ModelTypeChat},
+		"disabled": {ID: "disabled", Name: "D", Target: "gpt-4o", Url: "http://127.0.0.1:8080", Type: config.ModelTypeChat, Disabled: true},
+	}}
+	return NewServer(cfg, nil)
+}
+
+func serveModels(s *Server, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/*path", s.proxyHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestListModels(t *testing.T) {
+	w := serveModels(newModelsTestServer(), "/v1/models")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var list OpenAIModelList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if list.Object != "list" {
+		t.Errorf("object = %q, want list", list.Object)
+	}
+	if len(list.Data) != 2 || list.Data[0].ID != "chat-a" || list.Data[1].ID != "chat-b" {
+		t.Fatalf("unexpected models: %+v", list.Data)
+	}
+	if list.Data[0].Object != "model" || list.Data[0].OwnedBy != "proxy" {
+		t.Errorf("unexpected model fields: %+v", list.Data[0])
+	}
+}
+
+func TestRetrieveModel(t *testing.T) {
+	s := newModelsTestServer()
+
+	w := serveModels(s, "/v1/models/chat-a")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var model OpenAIModel
+	if err := json.Unmarshal(w.Body.Bytes(), &model); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if model.ID != "chat-a" || model.Object != "model" {
+		t.Errorf("unexpected model: %+v", model)
+	}
+
+	for _, path := range []string{"/v1/models/unknown", "/v1/models/disabled"} {
+		if w := serveModels(s, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d, want 404", path, w.Code)
+		}
+	}
+}
+
+func TestListModelsIncludesAliases(t *testing.T) {
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"gpt-4": {ID: "gpt-4", Name: "GPT-4", Target: "gpt-4o", Url: "http://127.0.0.1:8080", Type: config.ModelTypeChat, Aliases: []string{"gpt4"}},
+	}}
+	if err := cfg.RebuildIndex(); err != nil {
+		t.Fatalf("构建模型索引失败: %v", err)
+	}
+
+	w := serveModels(NewServer(cfg, nil), "/v1/models")
+	var list OpenAIModelList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if len(list.Data) != 2 || list.Data[0].ID != "gpt-4" || list.Data[1].ID != "gpt4" {
+		t.Errorf("模型列表应包含别名且不暴露target, got %+v", list.Data)
+	}
+}
+
+func TestRetrieveModelNotFoundLocalized(t *testing.T) {
+	s := newModelsTestServer()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/*path", s.proxyHandler)
+
+	serve := func(language string) (code, message string) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models/missing", nil)
+		req.Header.Set("Accept-Language", language)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("解析响应失败: %v", err)
+		}
+		return body.Error.Code, body.Error.Message
+	}
+
+	zhCode, zhMessage := serve("zh-CN")
+	enCode, enMessage := serve("en")
+	if zhCode != "model_not_found" || enCode != "model_not_found" {
+		t.Errorf("期望两种语言的错误码都为model_not_found，实际为%s和%s", zhCode, enCode)
+	}
+	if zhMessage != "模型配置未找到: missing" || enMessage != "Model configuration not found: missing" {
+		t.Errorf("unexpected messages: %q, %q", zhMessage, enMessage)
+	}
+}
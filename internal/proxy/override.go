@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// 按请求覆盖Prompt注入的请求头，只对开启allow_prompt_override的API Key生效
+const (
+	HeaderSkipPrompt  = "X-Proxy-Skip-Prompt"  // 为true时本次请求不注入模型配置的Prompt
+	HeaderExtraPrompt = "X-Proxy-Extra-Prompt" // 追加在模型配置的Prompt之后的文本
+)
+
+// errPromptNotAppendable 模型的Prompt值不是文本，无法追加额外的Prompt
+var errPromptNotAppendable = errors.New("模型的Prompt值不是文本，不支持追加额外的Prompt")
+
+// promptOverride 本次请求对Prompt注入的覆盖
+type promptOverride struct {
+	Skip  bool   // 不注入模型配置的Prompt
+	Extra string // 追加的Prompt文本
+}
+
+// parsePromptOverride 解析覆盖Prompt的请求头，requested为false表示请求中没有覆盖头
+func parsePromptOverride(header http.Header) (override promptOverride, requested bool, err error) {
+	skip := strings.TrimSpace(header.Get(HeaderSkipPrompt))
+	override.Extra = strings.TrimSpace(header.Get(HeaderExtraPrompt))
+	if skip == "" && override.Extra == "" {
+		return override, false, nil
+	}
+	if skip != "" {
+		if override.Skip, err = strconv.ParseBool(skip); err != nil {
+			return override, true, fmt.Errorf("%s的值无效: %s", HeaderSkipPrompt, skip)
+		}
+	}
+	return override, true, nil
+}
+
+// apply 返回按覆盖调整Prompt后的模型配置副本，返回nil表示不注入Prompt。
+// 额外的Prompt以换行拼接在配置的Prompt文本之后，跳过配置的Prompt时只注入额外的Prompt
+func (o promptOverride) apply(modelConfig *config.ModelConfig) (*config.ModelConfig, error) {
+	if o.Extra == "" {
+		if o.Skip {
+			return nil, nil
+		}
+		return modelConfig, nil
+	}
+
+	appendText := func(configured string) string {
+		if o.Skip || configured == "" {
+			return o.Extra
+		}
+		return configured + "\n" + o.Extra
+	}
+
+	adjusted := *modelConfig
+	switch value := modelConfig.PromptValue.(type) {
+	case nil:
+		adjusted.Prompt = appendText(modelConfig.Prompt)
+	case string:
+		adjusted.PromptValue = appendText(value)
+	case map[string]interface{}:
+		content, ok := value["content"].(string)
+		if !ok && value["content"] != nil {
+			return nil, errPromptNotAppendable
+		}
+		message := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			message[k] = v
+		}
+		message["content"] = appendText(content)
+		adjusted.PromptValue = message
+	default:
+		return nil, errPromptNotAppendable
+	}
+	return &adjusted, nil
+}
+
+// promptOverrideConfig 根据覆盖请求头确定本次请求注入Prompt使用的模型配置，返回nil表示不注入；
+// API Key未开启allow_prompt_override时返回403的阶段错误
+func promptOverrideConfig(c *gin.Context, modelConfig *config.ModelConfig) (*config.ModelConfig, error) {
+	override, requested, err := parsePromptOverride(c.Request.Header)
+	if !requested {
+		return modelConfig, nil
+	}
+	if err != nil {
+		return nil, &StageError{Status: http.StatusBadRequest, Class: stats.ErrorClassClient, Message: err.Error()}
+	}
+	if apiKey := apiKeyFromContext(c); apiKey == nil || !apiKey.AllowPromptOverride {
+		return nil, codedStageError(c, http.StatusForbidden, stats.ErrorClassClient, i18n.CodePromptOverrideNotAllowed, errorTypePermission)
+	}
+
+	adjusted, err := override.apply(modelConfig)
+	if err != nil {
+		return nil, &StageError{Status: http.StatusBadRequest, Class: stats.ErrorClassClient, Message: err.Error()}
+	}
+	setLogExtra(c, "prompt_overridden", true)
+	setLogExtra(c, "prompt_skipped", override.Skip)
+	if override.Extra != "" {
+		setLogExtra(c, "prompt_override_text", override.Extra)
+	}
+	return adjusted, nil
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// serveWithOverride 使用指定的API Key和请求头发送聊天请求，返回响应和上游收到的请求
+func serveWithOverride(t *testing.T, apiKey *db.APIKey, headers map[string]string) (*httptest.ResponseRecorder, *http.Request, string) {
+	t.Helper()
+	var upstreamReq *http.Request
+	var upstreamBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamReq, upstreamBody = r, string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {ID: "chat", Name: "Chat", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			PromptPath: "messages", PromptValue: map[string]interface{}{"role": "system", "content": "配置的提示"}},
+	}}
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"chat","messages":[{"role":"user","content":"你好"}]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Set("api_key_info", apiKey)
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, upstreamReq, upstreamBody
+}
+
+func TestPromptOverrideAllowed(t *testing.T) {
+	apiKey := &db.APIKey{IsEnabled: true, AllowPromptOverride: true}
+
+	w, upstreamReq, body := serveWithOverride(t, apiKey, map[string]string{HeaderExtraPrompt: "只用英文回答"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望返回200，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	if got := gjson.Get(body, "messages.0.content").String(); got != "配置的提示\n只用英文回答" {
+		t.Errorf("期望额外的Prompt追加在配置的Prompt之后，实际得到%q", got)
+	}
+	if upstreamReq.Header.Get(HeaderExtraPrompt) != "" {
+		t.Error("覆盖Prompt的请求头不应转发给上游")
+	}
+
+	w, _, body = serveWithOverride(t, apiKey, map[string]string{HeaderSkipPrompt: "true"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望返回200，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	if messages := gjson.Get(body, "messages").Array(); len(messages) != 1 || messages[0].Get("role").String() != "user" {
+		t.Errorf("跳过Prompt时不应注入系统消息，实际得到%s", body)
+	}
+}
+
+func TestPromptOverrideDenied(t *testing.T) {
+	for _, apiKey := range []*db.APIKey{{IsEnabled: true}, nil} {
+		for _, header := range []string{HeaderSkipPrompt, HeaderExtraPrompt} {
+			w, upstreamReq, _ := serveWithOverride(t, apiKey, map[string]string{header: "true"})
+			if w.Code != http.StatusForbidden {
+				t.Errorf("未开启allow_prompt_override时发送%s期望返回403，实际得到%d", header, w.Code)
+			}
+			if gjson.Get(w.Body.String(), "error.code").String() != "prompt_override_not_allowed" {
+				t.Errorf("期望错误码prompt_override_not_allowed，实际得到%s", w.Body.String())
+			}
+			if upstreamReq != nil {
+				t.Error("拒绝的请求不应转发给上游")
+			}
+		}
+	}
+
+	w, _, _ := serveWithOverride(t, &db.APIKey{IsEnabled: true, AllowPromptOverride: true}, map[string]string{HeaderSkipPrompt: "maybe"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("无效的%s期望返回400，实际得到%d", HeaderSkipPrompt, w.Code)
+	}
+}
+
+func TestPromptOverrideSkipWithExtra(t *testing.T) {
+	apiKey := &db.APIKey{IsEnabled: true, AllowPromptOverride: true}
+	w, _, body := serveWithOverride(t, apiKey, map[string]string{
+		HeaderSkipPrompt:  "true",
+		HeaderExtraPrompt: "评测模式",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望返回200，实际得到%d: %s", w.Code, w.Body.String())
+	}
+	messages := gjson.Get(body, "messages").Array()
+	if len(messages) != 2 || messages[0].Get("content").String() != "评测模式" {
+		t.Errorf("跳过配置的Prompt时应只注入额外的Prompt，实际得到%s", body)
+	}
+	if strings.Contains(body, "配置的提示") {
+		t.Errorf("跳过Prompt时不应包含配置的Prompt，实际得到%s", body)
+	}
+}
+
+func TestPromptOverrideApplyShapes(t *testing.T) {
+	override := promptOverride{Extra: "额外"}
+	cases := []struct {
+		model *config.ModelConfig
+		check func(*config.ModelConfig) bool
+	}{
+		{&config.ModelConfig{Prompt: "配置"}, func(m *config.ModelConfig) bool { return m.Prompt == "配置\n额外" }},
+		{&config.ModelConfig{PromptValue: "配置"}, func(m *config.ModelConfig) bool { return m.PromptValue == "配置\n额外" }},
+		{&config.ModelConfig{}, func(m *config.ModelConfig) bool { return m.Prompt == "额外" }},
+	}
+	for _, tc := range cases {
+		adjusted, err := override.apply(tc.model)
+		if err != nil {
+			t.Fatalf("调整Prompt失败: %v", err)
+		}
+		if !tc.check(adjusted) {
+			t.Errorf("调整结果不正确: %+v", adjusted)
+		}
+	}
+
+	original := map[string]interface{}{"role": "system", "content": "配置"}
+	model := &config.ModelConfig{PromptValue: original}
+	if _, err := override.apply(model); err != nil {
+		t.Fatalf("调整Prompt失败: %v", err)
+	}
+	if original["content"] != "配置" {
+		t.Error("调整Prompt不应修改原始的模型配置")
+	}
+
+	if _, err := override.apply(&config.ModelConfig{PromptValue: []interface{}{"a"}}); err == nil {
+		t.Error("期望非文本的Prompt值不支持追加")
+	}
+}
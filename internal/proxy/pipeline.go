@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// Stage 请求处理流水线中的一个阶段。Process返回nil时继续执行下一阶段，
+// 返回*StageError时由流水线写入错误响应，返回errPipelineDone表示阶段已完成响应
+type Stage interface {
+	Name() string
+	Process(rc *RequestContext) error
+}
+
+// RequestContext 一次代理请求在各处理阶段之间共享的状态
+type RequestContext struct {
+	Gin               *gin.Context
+	Body              []byte              // 客户端的原始请求体
+	IsJSON            bool                // 请求体是否为JSON
+	ModelID           string              // 请求的模型ID，使用别名时为模型ID，使用默认模型时为客户端原始的模型ID
+	Model             *config.ModelConfig // resolve阶段解析出的模型配置
+	UseDefault        bool                // 是否使用默认模型
+	Variant           string              // 模型按权重分流时选中的变体名称，Model已替换为该变体的目标
+	ExperimentVariant string              // 模型配置Prompt实验时选中的变体ID，Model已替换为注入该变体Prompt的配置
+	Canary            bool                // 是否转发到灰度目标，Model已替换为灰度目标
+	ModifiedBody      []byte              // 转发给上游的请求体，初始为原始请求体
+	UpstreamURL       string              // rewrite阶段生成的上游URL
+
+	server   *Server
+	cleanups []func()
+}
+
+// errPipelineDone 阶段已写入正常响应（如维护模式、命中缓存），不再执行后续阶段
+var errPipelineDone = errors.New("请求已由处理阶段完成")
+
+// StageError 处理阶段失败时返回给客户端的错误
+type StageError struct {
+	Status  int              // 返回给客户端的状态码，0表示不写入响应（如客户端已断开）
+	Class   stats.ErrorClass // 错误分类，为空时按上游响应状态码分类
+	Message string           // 记录到访问日志error字段的错误信息，为空时不覆盖
+	Body    interface{}      // 响应体，为nil时按状态码返回通用错误码的OpenAI错误格式，信息为Message
+}
+
+func (e *StageError) Error() string {
+	return e.Message
+}
+
+// stageRegistry 已注册的处理阶段，按名称索引
+var stageRegistry = make(map[string]Stage)
+
+// RegisterStage 注册处理阶段，使模型配置的pipeline可以使用该阶段。只应在init中调用
+func RegisterStage(stage Stage) {
+	stageRegistry[stage.Name()] = stage
+	config.RegisterPipelineStage(stage.Name())
+}
+
+func init() {
+	for _, stage := range []Stage{injectStage{}, rewriteStage{}, cacheStage{}, limitsStage{}, forwardStage{}} {
+		RegisterStage(stage)
+	}
+}
+
+// newRequestContext 根据请求创建流水线上下文
+func (s *Server) newRequestContext(c *gin.Context) *RequestContext {
+	body := []byte(c.GetString("request_body"))
+	return &RequestContext{
+		Gin:          c,
+		Body:         body,
+		IsJSON:       isJSONRequest(c.GetHeader("Content-Type"), body),
+		ModifiedBody: body,
+		server:       s,
+	}
+}
+
+// SetBody 替换转发给上游的请求体
+func (rc *RequestContext) SetBody(body []byte) {
+	rc.ModifiedBody = body
+}
+
+// SetLogExtra 在访问日志中记录一个扩展字段
+func (rc *RequestContext) SetLogExtra(key string, value interface{}) {
+	setLogExtra(rc.Gin, key, value)
+}
+
+// Defer 注册在请求处理结束时执行的函数，按注册的相反顺序执行
+func (rc *RequestContext) Defer(fn func()) {
+	rc.cleanups = append(rc.cleanups, fn)
+}
+
+// Fail 构造阶段错误，响应体为OpenAI错误格式，错误码按状态码使用通用错误码（如bad_request、internal_error），
+// 错误信息附加在通用信息之后；需要稳定的具体错误码时使用FailCode
+func (rc *RequestContext) Fail(status int, class stats.ErrorClass, format string, args ...interface{}) *StageError {
+	return &StageError{Status: status, Class: class, Message: fmt.Sprintf(format, args...)}
+}
+
+// FailCode 构造带稳定错误码的阶段错误，响应体为OpenAI错误格式
+func (rc *RequestContext) FailCode(status int, class stats.ErrorClass, code i18n.Code, errType string, details ...interface{}) *StageError {
+	return codedStageError(rc.Gin, status, class, code, errType, details...)
+}
+
+// codedStageError 构造带稳定错误码的阶段错误，响应中的信息按请求的Accept-Language本地化，
+// 访问日志记录默认语言的信息
+func codedStageError(c *gin.Context, status int, class stats.ErrorClass, code i18n.Code, errType string, details ...interface{}) *StageError {
+	return &StageError{
+		Status:  status,
+		Class:   class,
+		Message: i18n.Message(i18n.Default, code, details...),
+		Body:    errorBody(c, code, errType, details...),
+	}
+}
+
+// finish 执行Defer注册的函数
+func (rc *RequestContext) finish() {
+	for i := len(rc.cleanups) - 1; i >= 0; i-- {
+		rc.cleanups[i]()
+	}
+}
+
+// runPipeline 先执行resolve阶段解析模型配置，再按模型配置的pipeline依次执行各阶段
+func (s *Server) runPipeline(c *gin.Context) {
+	rc := s.newRequestContext(c)
+	defer rc.finish()
+
+	if !rc.run(resolveStage{}) {
+		return
+	}
+	for _, name := range rc.Model.Stages() {
+		stage, ok := stageRegistry[name]
+		if !ok {
+			rc.fail(name, rc.FailCode(http.StatusInternalServerError, "", i18n.CodeUnknownStage, errorTypeServer, name))
+			return
+		}
+		if !rc.run(stage) {
+			return
+		}
+	}
+}
+
+// run 执行一个阶段，返回是否继续执行后续阶段
+func (rc *RequestContext) run(stage Stage) bool {
+	err := stage.Process(rc)
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, errPipelineDone) {
+		rc.fail(stage.Name(), err)
+	}
+	return false
+}
+
+// fail 在访问日志中记录失败的阶段，并写入阶段错误的响应
+func (rc *RequestContext) fail(name string, err error) {
+	c := rc.Gin
+	setLogExtra(c, "failed_stage", name)
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) {
+		stageErr = &StageError{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+	if stageErr.Class != "" {
+		setErrorClass(c, stageErr.Class)
+	}
+	if stageErr.Message != "" {
+		c.Set("error", stageErr.Message)
+	}
+	if stageErr.Status == 0 {
+		return
+	}
+	body := stageErr.Body
+	if body == nil {
+		code, errType := statusErrorCode(stageErr.Status)
+		body = errorBody(c, code, errType, stageErr.Message)
+	}
+	c.JSON(stageErr.Status, body)
+}
@@ -0,0 +1,279 @@
+package proxy
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+var updateGolden = flag.Bool("update", false, "用当前输出更新testdata中的golden文件")
+
+// goldenResult 一次代理请求转发给上游的内容和返回给客户端的响应
+type goldenResult struct {
+	UpstreamBody string `json:"upstream_body"`
+	Status       int    `json:"status"`
+	Response     string `json:"response"`
+}
+
+// pipelineGoldenCases 复用prompt_test中的模型配置和请求体
+func pipelineGoldenCases() []struct {
+	name  string
+	model *config.ModelConfig
+	body  string
+} {
+	pipelineModel := func(stages ...string) *config.ModelConfig {
+		model := newToolsModel("")
+		model.Pipeline = stages
+		return model
+	}
+	messagesModel := func() *config.ModelConfig {
+		return &config.ModelConfig{
+			ID:          "test-model",
+			Name:        "test-model",
+			Target:      "test-target",
+			PromptPath:  "messages",
+			PromptValue: map[string]interface{}{"role": "system", "content": "This is a test prompt."},
+		}
+	}
+	return []struct {
+		name  string
+		model *config.ModelConfig
+		body  string
+	}{
+		{"messages", messagesModel(), `{"model":"test-model","messages": [{"role": "user", "content": "Hello"}]}`},
+		{"messages_nil", messagesModel(), `{"model":"test-model","stream":true}`},
+		{"embedding_string", newEmbeddingModel("query: "), `{"model":"embed-custom","input":"hello"}`},
+		{"embedding_array", newEmbeddingModel("query: "), `{"model":"embed-custom","input":["a","b"]}`},
+		{"embedding_token_array", newEmbeddingModel("query: "), `{"model":"embed-custom","input":[[1,2],[3]]}`},
+		{"embedding_without_prompt", newEmbeddingModel(""), `{"model":"embed-custom","input":"hello"}`},
+		{"tools_create", newToolsModel(""), `{"model":"chat-tools","messages":[],"tools":null}`},
+		{"tools_append", newToolsModel(config.ToolsModeAppend), `{"model":"chat-tools","tools":[{"type":"function","function":{"name":"search","description":"客户端版本"}},{"type":"function","function":{"name":"weather"}}]}`},
+		{"tools_replace", newToolsModel(config.ToolsModeReplace), `{"model":"chat-tools","tools":[{"type":"function","function":{"name":"weather"}}]}`},
+		{"tools_non_array", newToolsModel(""), `{"model":"chat-tools","tools":"search"}`},
+		{"unknown_stage", pipelineModel("rewrite", "audit", "forward"), `{"model":"chat-tools"}`},
+		{"missing_rewrite_stage", pipelineModel("forward"), `{"model":"chat-tools"}`},
+	}
+}
+
+// serveGolden 通过proxyHandler转发请求，记录上游收到的请求体和客户端收到的响应
+func serveGolden(t *testing.T, model *config.ModelConfig, body string) goldenResult {
+	t.Helper()
+	var result goldenResult
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		result.UpstreamBody = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"ok"}`))
+	}))
+	defer upstream.Close()
+
+	model.Url = upstream.URL
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{model.ID: model}}, nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	result.Status = w.Code
+	result.Response = w.Body.String()
+	return result
+}
+
+func TestPipelineGolden(t *testing.T) {
+	results := make(map[string]goldenResult)
+	for _, tc := range pipelineGoldenCases() {
+		results[tc.name] = serveGolden(t, tc.model, tc.body)
+	}
+
+	path := filepath.Join("testdata", "pipeline.golden.json")
+	if *updateGolden {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			t.Fatalf("序列化结果失败: %v", err)
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatalf("写入golden文件失败: %v", err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取golden文件失败: %v", err)
+	}
+	var want map[string]goldenResult
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatalf("解析golden文件失败: %v", err)
+	}
+	for name, got := range results {
+		if !reflect.DeepEqual(got, want[name]) {
+			t.Errorf("%s的输出与golden文件不一致\n got: %+v\nwant: %+v", name, got, want[name])
+		}
+	}
+}
+
+// newStageContext 创建单独测试处理阶段使用的请求上下文
+func newStageContext(model *config.ModelConfig, body string) *RequestContext {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("request_body", body)
+
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{model.ID: model}}, nil)
+	rc := s.newRequestContext(c)
+	rc.ModelID, rc.Model = model.ID, model
+	return rc
+}
+
+func TestInjectStage(t *testing.T) {
+	model := newToolsModel("")
+	rc := newStageContext(model, `{"model":"chat-tools","messages":[]}`)
+	if err := (injectStage{}).Process(rc); err != nil {
+		t.Fatalf("inject阶段失败: %v", err)
+	}
+	if got := string(rc.ModifiedBody); !strings.Contains(got, "lookup_order") || !strings.Contains(got, `"model":"chat-tools"`) {
+		t.Errorf("inject阶段应注入tools且不替换模型ID，实际得到%s", got)
+	}
+	if string(rc.Body) != `{"model":"chat-tools","messages":[]}` {
+		t.Errorf("inject阶段不应修改原始请求体")
+	}
+
+	rc = newStageContext(model, `{"model":"chat-tools","tools":"search"}`)
+	err := (injectStage{}).Process(rc)
+	stageErr, ok := err.(*StageError)
+	if !ok || stageErr.Status != http.StatusInternalServerError {
+		t.Errorf("tools不是数组时期望500的阶段错误，实际得到%v", err)
+	}
+}
+
+func TestInjectStageDedupePrompt(t *testing.T) {
+	model := &config.ModelConfig{ID: "chat", Name: "Chat", Target: "gpt-4o", Type: config.ModelTypeChat,
+		Prompt: "你是客服助手", DedupePrompt: true}
+
+	// 模拟三轮对话：客户端每轮重发完整历史，包括上一轮请求中注入的system消息
+	history := []interface{}{}
+	for turn := 1; turn <= 3; turn++ {
+		history = append(history, map[string]interface{}{"role": "user", "content": fmt.Sprintf("问题%d", turn)})
+		body, _ := json.Marshal(map[string]interface{}{"model": "chat", "messages": history})
+		rc := newStageContext(model, string(body))
+		if err := (injectStage{}).Process(rc); err != nil {
+			t.Fatalf("第%d轮inject阶段失败: %v", turn, err)
+		}
+		messages := gjson.GetBytes(rc.ModifiedBody, "messages").Array()
+		count := 0
+		for _, message := range messages {
+			if message.Get("role").String() == "system" && message.Get("content").String() == model.Prompt {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Fatalf("第%d轮请求中应只有一条Prompt消息，实际%d条: %s", turn, count, rc.ModifiedBody)
+		}
+		if deduped := rc.Gin.MustGet("log_extra").(map[string]interface{})["prompt_deduped"]; deduped != (turn > 1) {
+			t.Errorf("第%d轮prompt_deduped = %v", turn, deduped)
+		}
+		// 客户端保存发送的消息和助手的回复
+		history = nil
+		for _, message := range messages {
+			history = append(history, message.Value())
+		}
+		history = append(history, map[string]interface{}{"role": "assistant", "content": fmt.Sprintf("回答%d", turn)})
+	}
+
+	// 多段内容形式的system消息同样识别
+	rc := newStageContext(model, `{"model":"chat","messages":[{"role":"system","content":[{"type":"text","text":"你是客服助手"}]},{"role":"user","content":"hi"}]}`)
+	if err := (injectStage{}).Process(rc); err != nil {
+		t.Fatalf("inject阶段失败: %v", err)
+	}
+	if got := len(gjson.GetBytes(rc.ModifiedBody, "messages").Array()); got != 2 {
+		t.Errorf("已有多段内容的Prompt消息时不应再注入，实际得到%s", rc.ModifiedBody)
+	}
+
+	// 未开启去重时保持原有行为
+	model.DedupePrompt = false
+	rc = newStageContext(model, `{"model":"chat","messages":[{"role":"system","content":"你是客服助手"}]}`)
+	if err := (injectStage{}).Process(rc); err != nil {
+		t.Fatalf("inject阶段失败: %v", err)
+	}
+	if got := len(gjson.GetBytes(rc.ModifiedBody, "messages").Array()); got != 2 {
+		t.Errorf("未开启去重时应照常注入，实际得到%s", rc.ModifiedBody)
+	}
+}
+
+func TestRewriteStage(t *testing.T) {
+	model := newEmbeddingModel("")
+	model.Url = "https://api.example.com/v1"
+	rc := newStageContext(model, `{"model":"embed-custom","input":"hello"}`)
+	if err := (rewriteStage{}).Process(rc); err != nil {
+		t.Fatalf("rewrite阶段失败: %v", err)
+	}
+	if got := string(rc.ModifiedBody); got != `{"model":"text-embedding-3-small","input":"hello"}` {
+		t.Errorf("rewrite阶段应替换模型ID，实际得到%s", got)
+	}
+	if rc.UpstreamURL == "" || rc.Gin.GetString("proxy_host") != "api.example.com" {
+		t.Errorf("rewrite阶段应生成上游URL，实际得到%q", rc.UpstreamURL)
+	}
+}
+
+func TestForwardStageRequiresUpstreamURL(t *testing.T) {
+	rc := newStageContext(newEmbeddingModel(""), `{"model":"embed-custom","input":"hello"}`)
+	if err := (forwardStage{}).Process(rc); err == nil {
+		t.Error("未执行rewrite阶段时forward阶段应返回错误")
+	}
+}
+
+func TestPipelineSkipsUnlistedStages(t *testing.T) {
+	model := newEmbeddingModel("query: ")
+	model.Pipeline = []string{config.PipelineStageRewrite, config.PipelineStageForward}
+	got := serveGolden(t, model, `{"model":"embed-custom","input":"hello"}`)
+	if got.UpstreamBody != `{"model":"text-embedding-3-small","input":"hello"}` {
+		t.Errorf("pipeline不包含inject时不应注入Prompt，实际得到%s", got.UpstreamBody)
+	}
+}
+
+func TestPipelineLogsFailedStage(t *testing.T) {
+	tests := []struct {
+		pipeline []string
+		body     string
+		status   int
+		stage    string
+	}{
+		{nil, `{"model":"chat-tools","tools":"search"}`, http.StatusInternalServerError, "inject"},
+		{[]string{"rewrite", "audit", "forward"}, `{"model":"chat-tools"}`, http.StatusInternalServerError, "audit"},
+		{nil, `{"model":"unknown"}`, http.StatusNotFound, "resolve"},
+	}
+	for _, tt := range tests {
+		model := newToolsModel("")
+		model.Pipeline = tt.pipeline
+		rc := newStageContext(model, tt.body)
+		rc.server.runPipeline(rc.Gin)
+
+		extra, _ := rc.Gin.Get("log_extra")
+		logged, _ := extra.(map[string]interface{})
+		if rc.Gin.Writer.Status() != tt.status || logged["failed_stage"] != tt.stage {
+			t.Errorf("pipeline %v期望在%s阶段失败并返回%d，实际得到%d，failed_stage=%v",
+				tt.pipeline, tt.stage, tt.status, rc.Gin.Writer.Status(), logged["failed_stage"])
+		}
+		if rc.Gin.GetString("error") == "" {
+			t.Errorf("%s阶段失败时应记录错误信息", tt.stage)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package proxy
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/tidwall/gjson"
 )
 
 func TestInjectPromptMessages(t *testing.T) {
@@ -37,3 +40,182 @@ func TestInjectPromptMessageNil(t *testing.T) {
 	}
 	t.Log("Result:", string(result))
 }
+
+func TestInjectPromptPosition(t *testing.T) {
+	const (
+		none     = `{"messages":[{"role":"user","content":"hi"}]}`
+		one      = `{"messages":[{"role":"system","content":"client"},{"role":"user","content":"hi"}]}`
+		multiple = `{"messages":[{"role":"system","content":"a"},{"role":"user","content":"hi"},{"role":"system","content":"b"}]}`
+		parts    = `{"messages":[{"role":"system","content":[{"type":"text","text":"client"}]}]}`
+	)
+	tests := []struct {
+		name     string
+		position config.PromptPosition
+		body     string
+		want     string
+	}{
+		{"prepend/无system", "", none, `[{"role":"system","content":"ours"},{"role":"user","content":"hi"}]`},
+		{"prepend/一条system", config.PromptPositionPrepend, one, `[{"role":"system","content":"ours"},{"role":"system","content":"client"},{"role":"user","content":"hi"}]`},
+		{"append/无system", config.PromptPositionAppend, none, `[{"role":"user","content":"hi"},{"role":"system","content":"ours"}]`},
+		{"append/多条system", config.PromptPositionAppend, multiple, `[{"role":"system","content":"a"},{"role":"user","content":"hi"},{"role":"system","content":"b"},{"role":"system","content":"ours"}]`},
+		{"replace_system/无system", config.PromptPositionReplaceSystem, none, `[{"role":"system","content":"ours"},{"role":"user","content":"hi"}]`},
+		{"replace_system/一条system", config.PromptPositionReplaceSystem, one, `[{"role":"system","content":"ours"},{"role":"user","content":"hi"}]`},
+		{"replace_system/多条system", config.PromptPositionReplaceSystem, multiple, `[{"role":"system","content":"ours"},{"role":"user","content":"hi"}]`},
+		{"merge_system/无system", config.PromptPositionMergeSystem, none, `[{"role":"system","content":"ours"},{"role":"user","content":"hi"}]`},
+		{"merge_system/一条system", config.PromptPositionMergeSystem, one, `[{"role":"system","content":"client\nours"},{"role":"user","content":"hi"}]`},
+		{"merge_system/多条system", config.PromptPositionMergeSystem, multiple, `[{"role":"system","content":"a\nours"},{"role":"user","content":"hi"},{"role":"system","content":"b"}]`},
+		{"merge_system/多段内容", config.PromptPositionMergeSystem, parts, `[{"role":"system","content":[{"type":"text","text":"client"},{"type":"text","text":"ours"}]}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.ModelConfig{ID: "chat", Name: "chat", Target: "gpt-4o", Url: "http://127.0.0.1:8080", Prompt: "ours", PromptPosition: tt.position}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("配置无效: %v", err)
+			}
+			result, err := injectPrompt([]byte(tt.body), cfg)
+			if err != nil {
+				t.Fatalf("injectPrompt failed: %v", err)
+			}
+			var got, want interface{}
+			json.Unmarshal([]byte(gjson.GetBytes(result, "messages").Raw), &got)
+			json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("messages = %s, want %s", gjson.GetBytes(result, "messages").Raw, tt.want)
+			}
+		})
+	}
+}
+
+func newEmbeddingModel(prompt string) *config.ModelConfig {
+	cfg := &config.ModelConfig{
+		ID:          "embed-custom",
+		Name:        "embed-custom",
+		Target:      "text-embedding-3-small",
+		Url:         "http://127.0.0.1:8080",
+		Type:        config.ModelTypeEmbedding,
+		PromptValue: prompt,
+	}
+	cfg.Validate()
+	return cfg
+}
+
+func TestInjectPromptEmbeddingString(t *testing.T) {
+	body := `{"model":"embed-custom","input":"hello"}`
+	result, err := injectPrompt([]byte(body), newEmbeddingModel("query: "))
+	if err != nil {
+		t.Fatalf("injectPrompt failed: %v", err)
+	}
+	if got := gjson.GetBytes(result, "input").String(); got != "query: hello" {
+		t.Errorf("input = %q, want %q", got, "query: hello")
+	}
+}
+
+func TestInjectPromptEmbeddingArray(t *testing.T) {
+	body := `{"model":"embed-custom","input":["a","b"]}`
+	result, err := injectPrompt([]byte(body), newEmbeddingModel("query: "))
+	if err != nil {
+		t.Fatalf("injectPrompt failed: %v", err)
+	}
+	if got := gjson.GetBytes(result, "input").Raw; got != `["query: a","query: b"]` {
+		t.Errorf("input = %s", got)
+	}
+}
+
+func TestInjectPromptEmbeddingTokenArray(t *testing.T) {
+	for _, body := range []string{
+		`{"model":"embed-custom","input":[1,2,3]}`,
+		`{"model":"embed-custom","input":[[1,2],[3]]}`,
+	} {
+		result, err := injectPrompt([]byte(body), newEmbeddingModel("query: "))
+		if err != nil {
+			t.Fatalf("injectPrompt failed: %v", err)
+		}
+		if string(result) != body {
+			t.Errorf("token数组应原样透传, got %s", result)
+		}
+	}
+}
+
+func TestInjectPromptEmbeddingWithoutPrompt(t *testing.T) {
+	body := `{"model":"embed-custom","input":"hello"}`
+	result, err := injectPrompt([]byte(body), newEmbeddingModel(""))
+	if err != nil {
+		t.Fatalf("injectPrompt failed: %v", err)
+	}
+	if string(result) != body {
+		t.Errorf("未配置Prompt时应原样返回, got %s", result)
+	}
+}
+
+func newToolsModel(mode config.ToolsMode) *config.ModelConfig {
+	cfg := &config.ModelConfig{
+		ID:     "chat-tools",
+		Name:   "chat-tools",
+		Target: "gpt-4o",
+		Url:    "http://127.0.0.1:8080",
+		Tools: []interface{}{
+			map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "search", "description": "内部搜索"}},
+			map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "lookup_order"}},
+		},
+		ToolsMode: mode,
+	}
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// toolNames 获取请求体tools数组中的函数名
+func toolNames(body []byte) []string {
+	var names []string
+	for _, tool := range gjson.GetBytes(body, "tools").Array() {
+		names = append(names, tool.Get("function.name").String())
+	}
+	return names
+}
+
+func TestInjectToolsCreatesArray(t *testing.T) {
+	for _, body := range []string{
+		`{"model":"chat-tools","messages":[]}`,
+		`{"model":"chat-tools","messages":[],"tools":null}`,
+	} {
+		result, err := injectTools([]byte(body), newToolsModel(""))
+		if err != nil {
+			t.Fatalf("injectTools failed: %v", err)
+		}
+		if got := toolNames(result); !reflect.DeepEqual(got, []string{"search", "lookup_order"}) {
+			t.Errorf("请求没有tools时应创建tools数组, got %v", got)
+		}
+	}
+}
+
+func TestInjectToolsAppend(t *testing.T) {
+	body := `{"model":"chat-tools","tools":[{"type":"function","function":{"name":"search","description":"客户端版本"}},{"type":"function","function":{"name":"weather"}}]}`
+	result, err := injectTools([]byte(body), newToolsModel(config.ToolsModeAppend))
+	if err != nil {
+		t.Fatalf("injectTools failed: %v", err)
+	}
+	if got := toolNames(result); !reflect.DeepEqual(got, []string{"search", "weather", "lookup_order"}) {
+		t.Errorf("append模式应保留客户端的tools并追加未定义的工具, got %v", got)
+	}
+	if desc := gjson.GetBytes(result, "tools.0.function.description").String(); desc != "客户端版本" {
+		t.Errorf("同名工具应以客户端定义为准, got %q", desc)
+	}
+}
+
+func TestInjectToolsReplace(t *testing.T) {
+	body := `{"model":"chat-tools","tools":[{"type":"function","function":{"name":"weather"}}]}`
+	result, err := injectTools([]byte(body), newToolsModel(config.ToolsModeReplace))
+	if err != nil {
+		t.Fatalf("injectTools failed: %v", err)
+	}
+	if got := toolNames(result); !reflect.DeepEqual(got, []string{"search", "lookup_order"}) {
+		t.Errorf("replace模式应替换客户端的tools, got %v", got)
+	}
+}
+
+func TestInjectToolsRejectsNonArray(t *testing.T) {
+	if _, err := injectTools([]byte(`{"tools":"search"}`), newToolsModel("")); err == nil {
+		t.Error("tools不是数组时应返回错误")
+	}
+}
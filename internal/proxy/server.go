@@ -3,54 +3,193 @@ package proxy
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"net/url"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/eolinker/ai-prompt-proxy/internal/alert"
+	"github.com/eolinker/ai-prompt-proxy/internal/clientip"
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
 	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+	"github.com/eolinker/ai-prompt-proxy/internal/recovery"
 	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/signing"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+	"github.com/eolinker/ai-prompt-proxy/internal/version"
 )
 
 // Server 代理服务器
 type Server struct {
-	config      *config.Config
-	httpClient  *http.Client
-	authService *service.AuthService
+	config        *config.Config
+	httpClient    *http.Client
+	authService   *service.AuthService
+	configService *service.ConfigService
+	serverConfig  *config.ServerConfig
+	cache         *ResponseCache
+	idempotency   *IdempotencyStore
+	limiter       *ConcurrencyLimiter
+	authorizer    *service.Authorizer
+	errorTracker  *stats.ErrorTracker
+	errorRates    *alert.ErrorRateMonitor
+	experiments   *stats.ExperimentTracker
+	canaries      *stats.ExperimentTracker
+	usage         *service.UsageRecorder
+	health        *healthcheck.Monitor
 }
 
 // NewServer 创建新的代理服务器
 func NewServer(cfg *config.Config, authService *service.AuthService) *Server {
-	return &Server{
+	s := &Server{
 		config:      cfg,
 		httpClient:  &http.Client{},
 		authService: authService,
+		cache:       NewResponseCache(config.DefaultServerConfig().Cache.MaxEntries),
+		idempotency: NewIdempotencyStore(config.DefaultServerConfig().Idempotency.TTL, config.DefaultServerConfig().Idempotency.MaxEntries),
+		limiter:     NewConcurrencyLimiter(),
+		authorizer:  service.NewAuthorizer(cfg, nil),
+	}
+	s.authorizer.SetConcurrencyState(s.limiter.InFlight)
+	return s
+}
+
+// NewServerWithService 使用配置服务和服务器配置创建新的代理服务器
+func NewServerWithService(configService *service.ConfigService, authService *service.AuthService, serverConfig *config.ServerConfig) *Server {
+	s := &Server{
+		config:        configService.GetConfig(),
+		httpClient:    newHTTPClient(serverConfig.Transport),
+		authService:   authService,
+		configService: configService,
+		serverConfig:  serverConfig,
+		cache:         NewResponseCache(serverConfig.Cache.MaxEntries),
+		idempotency:   NewIdempotencyStore(serverConfig.Idempotency.TTL, serverConfig.Idempotency.MaxEntries),
+		limiter:       NewConcurrencyLimiter(),
+		authorizer:    service.NewAuthorizer(configService.GetConfig(), configService),
+	}
+	s.authorizer.SetConcurrencyState(s.limiter.InFlight)
+	return s
+}
+
+// NewHandler 使用配置服务创建代理的HTTP处理器，不绑定端口，可直接用于httptest
+func NewHandler(configService *service.ConfigService, authService *service.AuthService, serverConfig *config.ServerConfig) http.Handler {
+	return NewServerWithService(configService, authService, serverConfig).Router()
+}
+
+// Authorizer 返回代理使用的授权检查器，供管理API模拟授权检查
+func (s *Server) Authorizer() *service.Authorizer {
+	return s.authorizer
+}
+
+// SetUsageRecorder 设置记录模型请求数和最后调用时间的记录器
+func (s *Server) SetUsageRecorder(recorder *service.UsageRecorder) {
+	s.usage = recorder
+}
+
+// SetHealthMonitor 设置上游健康检查器，最近一次检查失败的模型直接返回503，模拟授权检查时使用同一结果
+func (s *Server) SetHealthMonitor(monitor *healthcheck.Monitor) {
+	s.health = monitor
+	s.authorizer.SetHealthState(monitor.Down)
+}
+
+// newHTTPClient 根据连接配置创建上游HTTP客户端
+func newHTTPClient(cfg config.TransportConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
 	}
 }
 
 // Start 启动服务器
 func (s *Server) Start(port string) error {
+	r := s.Router()
+	if s.serverConfig != nil && s.serverConfig.Proxy.TLS.Enabled() {
+		return r.RunTLS(":"+port, s.serverConfig.Proxy.TLS.CertFile, s.serverConfig.Proxy.TLS.KeyFile)
+	}
+	return r.Run(":" + port)
+}
+
+// Router 创建包含认证、访问日志等中间件的代理路由器
+func (s *Server) Router() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+
+	trustedProxies := config.DefaultTrustedProxies
+	if s.serverConfig != nil {
+		trustedProxies = s.serverConfig.TrustedProxies
+	}
+	if err := clientip.Configure(r, trustedProxies); err != nil {
+		fmt.Printf("%v，不采信转发请求头\n", err)
+	}
 
 	// 添加中间件
 	r.Use(gin.Logger())
-	r.Use(gin.Recovery())
-	r.Use(AccessLogMiddleware)
+	maskHeaders := config.DefaultMaskHeaders
+	if s.serverConfig != nil {
+		maskHeaders = s.serverConfig.AccessLog.MaskHeaders
+	}
+	r.Use(s.corsMiddleware()) // 预检请求在访问日志和API Key验证之前返回
+	r.Use(versionMiddleware)  // GET /version无需API Key
+	r.Use(AccessLogMiddleware(maskHeaders))
+	// 在访问日志之内恢复panic，使出错的请求仍然记录日志
+	r.Use(recovery.Middleware(respondPanic))
 	r.Use(s.apiKeyAuthMiddleware()) // 添加API Key验证中间件
+	r.Use(s.errorTrackingMiddleware())
+	r.Use(s.experimentTrackingMiddleware())
+	r.Use(s.canaryTrackingMiddleware())
+	if s.serverConfig != nil {
+		r.Use(RequestTimeoutMiddleware(s.serverConfig.Limits.RequestTimeout, s.serverConfig.Limits.StreamTimeout))
+	}
 
 	// 代理所有请求
 	r.Any("/*path", s.proxyHandler)
+	return r
+}
 
-	return r.Run(":" + port)
+// VersionPath 代理返回版本信息的路径
+const VersionPath = "/version"
+
+// versionMiddleware 返回代理的版本信息。代理的其他路径都由/*path转发，不能单独注册路由，
+// 因此在API Key验证之前处理，不记录访问日志
+func versionMiddleware(c *gin.Context) {
+	if c.Request.Method != http.MethodGet || c.Request.URL.Path != VersionPath {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusOK, version.Info())
 }
 
 // apiKeyAuthMiddleware API Key验证中间件
@@ -59,43 +198,10 @@ func (s *Server) apiKeyAuthMiddleware() gin.HandlerFunc {
 		requestID := generateRequestID()
 		c.Set("request_id", requestID)
 
-		// 获取客户端IP
+		// $client_ip为按可信代理规则解析出的客户端IP，$remote_addr为直连对端的地址
 		clientIP := c.ClientIP()
-		if clientIP == "" {
-			clientIP = c.Request.RemoteAddr
-		}
-
-		forwardedFor := c.Request.Header.Get("X-Forwarded-For")
-		if forwardedFor != "" {
-			// 如果有X-Forwarded-For头部，取第一个IP作为客户端IP
-			clientIP = strings.Split(forwardedFor, ",")[0]
-		} else {
-			if clientIP == "::1" {
-				clientIP = "127.0.0.1"
-			} else {
-				// 如果是IPv6地址，转换为IPv4地址
-				if strings.HasPrefix(clientIP, "[") && strings.HasSuffix(clientIP, "]") {
-					clientIP = strings.Trim(clientIP, "[]")
-				}
-
-				if ip := net.ParseIP(clientIP); ip != nil {
-					clientIP = ip.String()
-				}
-				// 如果无法解析IP，使用RemoteAddr
-				if clientIP == "" {
-					clientIP = c.Request.RemoteAddr
-				}
-			}
-		}
-		realIP := c.GetHeader("x-real-ip") // 尝试获取真实IP头部
-		if realIP != "" {
-			clientIP = realIP
-		}
-
 		c.Set("client_ip", clientIP)
-
-		body, _ := io.ReadAll(c.Request.Body)
-		c.Set("request_body", string(body)) // 保存原始请求体到上下文)
+		c.Set("remote_addr", clientip.RemoteAddr(c))
 
 		// 尝试获取X-Proxy-Key头部
 		apiKey := c.GetHeader("X-Proxy-Key")
@@ -103,66 +209,58 @@ func (s *Server) apiKeyAuthMiddleware() gin.HandlerFunc {
 		c.Set("api_key", apiKey)
 		// 如果两种认证方式都没有提供有效凭据
 		if apiKey == "" {
-			c.Set("error", "缺少认证信息")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "缺少认证信息，请在请求头中添加X-Proxy-Key或Authorization Bearer token",
-			})
-			c.Abort()
+			abortWithError(c, http.StatusUnauthorized, i18n.CodeMissingAPIKey, errorTypeAuthentication)
 			return
 		}
 
 		// 验证API Key
 		if s.authService == nil {
-			c.Set("error", "认证服务不可用")
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "认证服务不可用",
-			})
-			c.Abort()
+			abortWithError(c, http.StatusInternalServerError, i18n.CodeAuthUnavailable, errorTypeServer)
 			return
 		}
 
 		// 从数据库获取API Key信息
 		apiKeyInfo, err := s.authService.GetAPIKeyByValue(apiKey)
 		if err != nil {
-			c.Set("error", "无效的API Key")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "无效的API Key",
-			})
-			c.Abort()
+			abortWithError(c, http.StatusUnauthorized, i18n.CodeInvalidAPIKey, errorTypeAuthentication)
 			return
 		}
 		c.Set("user_id", apiKeyInfo.UserID)
 
-		// 检查API Key是否启用
-		if !apiKeyInfo.IsEnabled {
-
-			c.Set("error", "API Key已被禁用")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "API Key已被禁用",
-			})
-			c.Abort()
+		// 检查API Key是否启用、是否过期
+		if failed := service.FirstFailure(s.authorizer.KeyChecks(apiKeyInfo, time.Now())); failed != nil {
+			abortWithError(c, failed.Status, i18n.Code(failed.Code), errorTypeAuthentication, failed.Detail)
 			return
 		}
 
-		// 检查API Key是否过期
-		if apiKeyInfo.ExpiresAt != nil && time.Now().After(*apiKeyInfo.ExpiresAt) {
-			// 记录API Key过期日志
-			c.Set("error", "API Key已过期")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "API Key已过期",
-			})
-			c.Abort()
+		if s.serverConfig != nil && s.serverConfig.Limits.MaxRequestBodySize > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.serverConfig.Limits.MaxRequestBodySize)
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				abortWithError(c, http.StatusRequestEntityTooLarge, i18n.CodeRequestTooLarge, errorTypeInvalidRequest, maxBytesErr.Limit)
+				return
+			}
+		}
+		// API Key校验通过后才读取和解压请求体，未认证的请求不会触发解压；解压后再提取模型ID和注入Prompt
+		body, ok := s.decodeRequestBody(c, body)
+		if !ok {
 			return
 		}
+		c.Set("request_body", string(body)) // 保存原始请求体到上下文)
 
-		// 更新API Key最后使用时间（异步执行，不影响请求性能）
+		// 更新API Key最后使用时间和客户端IP（异步执行，不影响请求性能）
 		go func() {
-			if err := s.authService.UpdateAPIKeyLastUsed(apiKey); err != nil {
+			if err := s.authService.UpdateAPIKeyLastUsed(apiKey, clientIP); err != nil {
 				// 记录错误但不影响请求
 				fmt.Printf("更新API Key最后使用时间失败: %v\n", err)
 			}
 		}()
 
+		setLabelLogExtra(c, apiKeyInfo)
+
 		// 将API Key信息存储到上下文中，供后续使用
 		c.Set("api_key_info", apiKeyInfo)
 		c.Set("user_id", apiKeyInfo.UserID)
@@ -190,177 +288,317 @@ func (s *Server) logRequest(data logger.RequestLogData) {
 
 // proxyHandler 代理请求处理器
 func (s *Server) proxyHandler(c *gin.Context) {
-	bodyStr := c.GetString("request_body")
-	body := []byte(bodyStr)
-	// 解析请求体以获取模型ID
-	modelID := extractModelID(body)
-	c.Set("model_id", modelID)
-	// 查找模型配置
-	modelConfig, exists := s.config.GetModel(modelID)
-	if !exists {
-		c.Set("error", fmt.Sprintf("模型配置未找到: %s", modelID))
-		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模型配置未找到: %s", modelID)})
-		return
+	// 模型列表和API Key信息请求由代理直接响应
+	if c.Request.Method == http.MethodGet {
+		if c.Request.URL.Path == "/v1/models" {
+			s.listModels(c)
+			return
+		}
+		if c.Request.URL.Path == KeyInfoPath {
+			s.keyInfo(c)
+			return
+		}
+		if modelID, ok := strings.CutPrefix(c.Request.URL.Path, "/v1/models/"); ok && modelID != "" {
+			s.retrieveModel(c, modelID)
+			return
+		}
 	}
-	c.Set("target_model", modelConfig.Target)
-	// 如果找到模型配置，注入Prompt并替换模型ID
-	modifiedBody, err := injectPrompt(body, modelConfig)
-	if err != nil {
-		// 记录注入失败的错误日志
-		c.Set("error", fmt.Sprintf("注入Prompt失败: %v", err))
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("注入Prompt失败: %v", err)})
-		return
-	}
+	// 解析模型配置后按模型的pipeline依次执行各处理阶段，带Idempotency-Key的请求只转发一次
+	s.runIdempotentPipeline(c)
+}
 
-	// 修改模型ID为目标模型ID
-	modifiedBody, err = replaceModelID(modifiedBody, modelConfig.Target)
-	if err != nil {
-		c.Set("error", fmt.Sprintf("替换模型ID失败: %v", err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("替换模型ID失败: %v", err)})
-		return
+// ModelLoad 返回模型当前进行中和排队等待的请求数
+func (s *Server) ModelLoad(modelID string) stats.ModelLoad {
+	return s.limiter.Load(modelID)
+}
+
+// lookupModel 查找请求对应的模型配置，请求使用别名时返回模型ID
+func (s *Server) lookupModel(req *http.Request, body []byte, isJSON bool) (string, *config.ModelConfig, bool) {
+	reader := &modelIDReader{req: req, body: body}
+	modelID := ""
+	if isJSON {
+		modelID = extractModelID(body)
+		if modelConfig, exists := s.config.GetModel(modelID); exists {
+			return modelConfig.ID, modelConfig, true
+		}
+	} else if _, ok := multipartBoundary(req.Header.Get("Content-Type")); ok {
+		// 音频、图片模型的multipart请求从model表单字段读取模型ID
+		modelID = reader.get(config.ModelIDSourceForm, "model")
+		if modelConfig, exists := s.config.GetModel(modelID); exists && supportsMultipart(modelConfig.Type) {
+			return modelConfig.ID, modelConfig, true
+		}
 	}
-	c.Set("modified_body", string(modifiedBody))
 
-	// 解析上游URL
-	upstreamURL := modelConfig.Url
-	parseURL, err := url.Parse(upstreamURL)
-	if err != nil {
-		// 记录URL解析失败的错误日志
-		c.Set("error", fmt.Sprintf("解析上游URL失败: %v, URL: %s", err, upstreamURL))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("解析上游URL失败: %v", err)})
-		return
+	// 按各模型配置的模型ID来源查找（查询参数、请求头、表单字段等），读到的模型必须配置了该来源；
+	// 多个来源匹配到不同模型时使用模型ID排序靠前的模型
+	var found *config.ModelConfig
+	for _, location := range s.config.ModelIDLocations() {
+		if location.Source == config.ModelIDSourceBody && !isJSON {
+			continue
+		}
+		matched, exists := s.config.GetModel(reader.get(location.Source, location.Key))
+		if !exists || matched.ModelIDSource != location.Source || matched.ModelIDKey != location.Key {
+			continue
+		}
+		if found == nil || matched.ID < found.ID {
+			found = matched
+		}
 	}
-	c.Set("proxy_url", upstreamURL)
-	c.Set("proxy_scheme", parseURL.Scheme)
-	c.Set("proxy_host", parseURL.Host)
-	c.Set("proxy_port", parseURL.Port())
-	c.Set("proxy_path", parseURL.Path)
-	c.Set("proxy_body", string(modifiedBody))
-
-	// 转发请求到上游服务
-	if err := s.forwardRequest(c, upstreamURL, modifiedBody); err != nil {
-		// forwardRequestWithLogging 内部已经处理了日志记录
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("转发请求失败: %v", err)})
-		return
+	if found != nil {
+		return found.ID, found, true
 	}
-}
 
-// forwardRequest 转发请求到上游服务
-func (s *Server) forwardRequest(c *gin.Context, upstreamURL string, body []byte) error {
-	// 创建新的请求
-	req, err := http.NewRequest(c.Request.Method, upstreamURL, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
+	return modelID, nil, false
+}
 
-	// 复制原始请求的头部
-	for key, values := range c.Request.Header {
-		for _, value := range values {
-			req.Header.Add(key, value)
+// forwardRequest 转发请求到上游服务，添加模型配置的请求头，配置了签名密钥时为请求添加签名；
+// 模型配置了hedge_after_ms时非流式请求按对冲方式发送
+func (s *Server) forwardRequest(c *gin.Context, upstreamURL string, body []byte, modelConfig *config.ModelConfig) error {
+	// 客户端发送压缩的请求体且模型开启compress_upstream时，按客户端的压缩格式重新压缩后转发
+	payload, contentEncoding := body, ""
+	if encoding := c.GetString("request_encoding"); encoding != "" && modelConfig.CompressUpstream {
+		compressed, err := compressBody(body, encoding)
+		if err != nil {
+			return err
 		}
+		payload, contentEncoding = compressed, encoding
 	}
 
-	// 更新Content-Length
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
-
-	// 发送请求
-	resp, err := s.httpClient.Do(req)
+	// 使用客户端请求的上下文，客户端断开时同时取消上游请求；记录连接、首字节和转发完成的耗时
+	newRequest := func(ctx context.Context) (*http.Request, error) {
+		return newUpstreamRequest(ctx, c, upstreamURL, payload, contentEncoding, modelConfig)
+	}
+	resp, finish, err := s.sendUpstream(c, newRequest, body, modelConfig)
+	defer finish()
 	if err != nil {
+		if !markClientDisconnected(c) {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				setErrorClass(c, stats.ErrorClassTimeout)
+			} else {
+				setErrorClass(c, stats.ErrorClassNetwork)
+			}
+		}
 		return err
 	}
 	defer resp.Body.Close()
 
 	// 复制响应头
 	for key, values := range resp.Header {
+		if isCORSResponseHeader(c, key) {
+			continue
+		}
 		for _, value := range values {
 			c.Header(key, value)
 		}
 	}
+	addResponseHeaders(c, modelConfig)
 
 	// 设置状态码
 	c.Status(resp.StatusCode)
 
-	// 检查是否为流式响应
-	if s.isStreamingResponse(resp) {
-		return s.handleStreamingResponse(c, resp)
-	}
-	bodyBuilder := strings.Builder{}
-	reader := bufio.NewReader(resp.Body)
-	for {
-		line, _, err := reader.ReadLine()
+	c.Set("response_status", resp.StatusCode)
+
+	// 检查是否为流式响应，记录实际转发的字节数供访问日志使用
+	switch mode := s.responseStreamMode(modelConfig.StreamMode, resp, body); mode {
+	case config.StreamModeSSE, config.StreamModeNDJSON:
+		setLogExtra(c, "stream_mode", string(mode))
+		size, err := s.handleStreamingResponseWithLogging(c, resp)
+		c.Set("response_size", size)
 		if err != nil {
-			break
+			c.Set("response_incomplete", true)
 		}
-		bodyBuilder.Write(line)
-		if _, err = c.Writer.Write(line); err != nil {
-			break
+		return err
+	case config.StreamModeJSONArray:
+		setLogExtra(c, "stream_mode", string(mode))
+		size, err := s.handleChunkedResponse(c, resp)
+		c.Set("response_size", size)
+		if err != nil {
+			c.Set("response_incomplete", true)
 		}
+		return err
+	}
+
+	// 原样复制响应体，同时保存到访问日志和响应缓存；读取上游或写入客户端失败时响应不完整，不缓存
+	capture := newResponseCapture(c, resp.StatusCode >= 400)
+	var cacheBody bytes.Buffer
+	sink := io.Writer(capture)
+	if c.GetString("cache_key") != "" {
+		sink = io.MultiWriter(capture, &cacheBody)
+	}
+	_, err = io.Copy(c.Writer, io.TeeReader(resp.Body, sink))
+	capture.save(c)
+	if err != nil {
+		c.Set("response_incomplete", true)
+	}
+	if markClientDisconnected(c) {
+		return c.Request.Context().Err()
 	}
-	c.Set("response_body", bodyBuilder.String())
 	if err != nil {
 		c.Set("error", err.Error())
 		return err
 	}
+	s.storeCachedResponse(c, resp, cacheBody.String())
 	return nil
 }
 
-// isStreamingResponse 检查是否为流式响应
-func (s *Server) isStreamingResponse(resp *http.Response) bool {
-	contentType := resp.Header.Get("Content-Type")
-	return strings.Contains(contentType, "text/event-stream") ||
-		strings.Contains(contentType, "application/x-ndjson") ||
-		strings.Contains(contentType, "text/plain")
-}
+// newUpstreamRequest 创建转发到上游的请求：复制客户端的请求头，添加模型配置的请求头，
+// 配置了签名密钥时为请求添加签名；contentEncoding不为空时请求体已按该格式压缩
+func newUpstreamRequest(ctx context.Context, c *gin.Context, upstreamURL string, payload []byte, contentEncoding string, modelConfig *config.ModelConfig) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, upstreamURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
 
-// handleStreamingResponse 处理流式响应
-func (s *Server) handleStreamingResponse(c *gin.Context, resp *http.Response) error {
-	// 设置流式响应的必要头部
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Cache-Control")
+	// 复制原始请求的头部
+	for key, values := range c.Request.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
 
-	// 确保响应立即发送
-	if flusher, ok := c.Writer.(http.Flusher); ok {
-		flusher.Flush()
+	// 更新Content-Length
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(payload)))
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	// 超时预算和Prompt覆盖只对代理生效，不转发给上游
+	req.Header.Del(HeaderTimeoutBudget)
+	req.Header.Del(HeaderSkipPrompt)
+	req.Header.Del(HeaderExtraPrompt)
+	// 模型配置的请求头覆盖客户端发送的同名请求头
+	for key, value := range modelConfig.RequestHeaders {
+		req.Header.Set(key, value)
 	}
 
-	// 创建缓冲读取器
-	reader := bufio.NewReader(resp.Body)
-	bodyBuilder := &strings.Builder{}
-	for {
-		// 逐行读取响应
-		line, err := reader.ReadBytes('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return fmt.Errorf("读取流式响应失败: %w", err)
-		}
+	// 去掉客户端伪造的签名头，只有配置了签名密钥的模型才由代理签名
+	req.Header.Del(signing.HeaderSignature)
+	req.Header.Del(signing.HeaderTimestamp)
+	if modelConfig.SigningSecret != "" {
+		signing.SignRequest(req, modelConfig.SigningSecret, payload, time.Now())
+	}
+	return req, nil
+}
+
+// markClientDisconnected 客户端已断开连接时记录错误并返回true，请求超时不视为断开。
+// 客户端断开不是上游故障，不计入模型错误统计
+func markClientDisconnected(c *gin.Context) bool {
+	if !errors.Is(c.Request.Context().Err(), context.Canceled) {
+		return false
+	}
+	c.Set("error", "client disconnected")
+	c.Set("client_disconnected", true)
+	setLogExtra(c, "client_disconnected", true)
+	return true
+}
 
-		// 写入响应数据
-		if _, err := c.Writer.Write(line); err != nil {
-			return fmt.Errorf("写入流式响应失败: %w", err)
+// writeCachedResponse 返回缓存的响应，并添加模型配置的响应头
+func (s *Server) writeCachedResponse(c *gin.Context, cached *cachedResponse, modelConfig *config.ModelConfig) {
+	for key, values := range cached.Header {
+		if isCORSResponseHeader(c, key) {
+			continue
+		}
+		for _, value := range values {
+			c.Header(key, value)
 		}
-		bodyBuilder.Write(line)
+	}
+	addResponseHeaders(c, modelConfig)
+	c.Header("X-Cache", "HIT")
+	setLogExtra(c, "cache", "HIT")
+	c.Set("response_body", string(cached.Body))
+	c.Status(cached.StatusCode)
+	c.Writer.Write(cached.Body)
+}
 
-		// 立即刷新缓冲区
-		if flusher, ok := c.Writer.(http.Flusher); ok {
-			flusher.Flush()
+// responseHeaderTemplate 替换模型响应头值中的{model_id}、{target}和{request_id}
+func responseHeaderTemplate(c *gin.Context) *strings.Replacer {
+	return strings.NewReplacer(
+		"{model_id}", c.GetString("model_id"),
+		"{target}", c.GetString("target_model"),
+		"{request_id}", c.GetString("request_id"),
+	)
+}
+
+// addResponseHeaders 添加模型配置的响应头，覆盖上游的同名响应头；需要在写入状态码之前调用，
+// 流式响应因此在第一次flush之前就带有这些响应头。跨域请求中模型未设置Access-Control-Expose-Headers时，
+// 自动允许浏览器读取这些响应头
+func addResponseHeaders(c *gin.Context, modelConfig *config.ModelConfig) {
+	if len(modelConfig.ResponseHeaders) == 0 {
+		return
+	}
+	template := responseHeaderTemplate(c)
+	header := c.Writer.Header()
+	names := make([]string, 0, len(modelConfig.ResponseHeaders))
+	for key, value := range modelConfig.ResponseHeaders {
+		header.Set(key, template.Replace(value))
+		names = append(names, http.CanonicalHeaderKey(key))
+	}
+
+	const exposeHeaders = "Access-Control-Expose-Headers"
+	if c.GetString("cors_origin") == "" {
+		return
+	}
+	for key := range modelConfig.ResponseHeaders {
+		if http.CanonicalHeaderKey(key) == exposeHeaders {
+			return
 		}
+	}
+	sort.Strings(names)
+	header.Set(exposeHeaders, strings.Join(names, ", "))
+}
 
-		// 检查客户端是否断开连接
-		select {
-		case <-c.Request.Context().Done():
-			return c.Request.Context().Err()
-		default:
-			// 继续处理
+// storeCachedResponse 缓存成功的非流式响应
+func (s *Server) storeCachedResponse(c *gin.Context, resp *http.Response, body string) {
+	cacheKey := c.GetString("cache_key")
+	if cacheKey == "" || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Content-Length")
+	s.cache.Set(cacheKey, &cachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       []byte(body),
+		ExpiresAt:  time.Now().Add(c.GetDuration("cache_ttl")),
+	})
+}
+
+// responseStreamMode 确定上游响应的转发方式，模型未指定时根据响应和请求判断：
+// Content-Type为SSE、NDJSON或纯文本时按行转发，分块传输的JSON响应且请求开启了stream时按json_array转发
+func (s *Server) responseStreamMode(configured config.StreamMode, resp *http.Response, requestBody []byte) config.StreamMode {
+	if configured != "" && configured != config.StreamModeAuto {
+		return configured
+	}
+	contentType := resp.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "application/x-ndjson"):
+		return config.StreamModeNDJSON
+	case s.isStreamingResponse(resp):
+		return config.StreamModeSSE
+	case strings.Contains(contentType, "application/json") && isChunked(resp) && isStreamRequest(requestBody):
+		return config.StreamModeJSONArray
+	}
+	return config.StreamModeNone
+}
+
+// isChunked 上游响应是否使用分块传输
+func isChunked(resp *http.Response) bool {
+	for _, encoding := range resp.TransferEncoding {
+		if encoding == "chunked" {
+			return true
 		}
 	}
+	return false
+}
 
-	c.Set("response_body", bodyBuilder.String())
-	return nil
+// isStreamingResponse 检查是否为流式响应
+func (s *Server) isStreamingResponse(resp *http.Response) bool {
+	contentType := resp.Header.Get("Content-Type")
+	return strings.Contains(contentType, "text/event-stream") ||
+		strings.Contains(contentType, "application/x-ndjson") ||
+		strings.Contains(contentType, "text/plain")
 }
 
 // handleStreamingResponseWithLogging 处理流式响应并返回响应大小
@@ -368,8 +606,6 @@ func (s *Server) handleStreamingResponseWithLogging(c *gin.Context, resp *http.R
 	// 设置流式响应的必要头部
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Headers", "Cache-Control")
 
 	// 确保响应立即发送
 	if flusher, ok := c.Writer.(http.Flusher); ok {
@@ -378,30 +614,54 @@ func (s *Server) handleStreamingResponseWithLogging(c *gin.Context, resp *http.R
 
 	// 创建缓冲读取器
 	reader := bufio.NewReader(resp.Body)
+	capture := newResponseCapture(c, resp.StatusCode >= 400)
+	inspector := &streamInspector{}
 	var totalSize int64
-
+	defer func() {
+		// 记录上游中途返回的错误及流是否完整结束
+		capture.save(c)
+		setLogExtra(c, "stream_end", inspector.endState())
+		if markClientDisconnected(c) {
+			setLogExtra(c, "stream_end_reason", "client_disconnect")
+			return
+		}
+		if inspector.err != "" {
+			setLogExtra(c, "stream_end_reason", "upstream_error")
+			c.Set("error", fmt.Sprintf("上游流式响应错误: %s", inspector.err))
+			return
+		}
+		setLogExtra(c, "stream_end_reason", inspector.endState())
+	}()
 	for {
 		// 逐行读取响应
 		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			inspector.inspect(line)
+
+			// 写入响应数据
+			n, err := c.Writer.Write(line)
+			totalSize += int64(n)
+			if err != nil {
+				return totalSize, fmt.Errorf("写入流式响应失败: %w", err)
+			}
+			capture.Write(line)
+
+			// 立即刷新缓冲区
+			if flusher, ok := c.Writer.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
+			// 上游请求使用客户端的上下文，客户端断开时阻塞中的读取会立即因取消而返回
+			if ctxErr := c.Request.Context().Err(); ctxErr != nil {
+				return totalSize, ctxErr
+			}
 			return totalSize, fmt.Errorf("读取流式响应失败: %w", err)
 		}
 
-		// 写入响应数据
-		n, err := c.Writer.Write(line)
-		if err != nil {
-			return totalSize, fmt.Errorf("写入流式响应失败: %w", err)
-		}
-		totalSize += int64(n)
-
-		// 立即刷新缓冲区
-		if flusher, ok := c.Writer.(http.Flusher); ok {
-			flusher.Flush()
-		}
-
 		// 检查客户端是否断开连接
 		select {
 		case <-c.Request.Context().Done():
@@ -414,6 +674,41 @@ func (s *Server) handleStreamingResponseWithLogging(c *gin.Context, resp *http.R
 	return totalSize, nil
 }
 
+// flushWriter 写入后立即刷新到客户端，同时按日志策略保存响应体
+type flushWriter struct {
+	writer  gin.ResponseWriter
+	capture *responseCapture
+}
+
+// Write 写入数据并刷新
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.capture.Write(p[:n])
+	w.writer.Flush()
+	return n, err
+}
+
+// handleChunkedResponse 不按行拆分，收到上游的数据就转发给客户端，用于分块返回的JSON数组等
+// 没有行边界的流式响应，返回响应大小
+func (s *Server) handleChunkedResponse(c *gin.Context, resp *http.Response) (int64, error) {
+	c.Header("Cache-Control", "no-cache")
+	c.Writer.Flush()
+
+	capture := newResponseCapture(c, resp.StatusCode >= 400)
+	defer capture.save(c)
+
+	size, err := io.Copy(&flushWriter{writer: c.Writer, capture: capture}, resp.Body)
+	if markClientDisconnected(c) {
+		setLogExtra(c, "stream_end_reason", "client_disconnect")
+		return size, c.Request.Context().Err()
+	}
+	if err != nil {
+		c.Set("error", fmt.Sprintf("转发流式响应失败: %v", err))
+		return size, err
+	}
+	return size, nil
+}
+
 // copyResponseWithSize 复制响应并返回响应大小
 func (s *Server) copyResponseWithSize(dst io.Writer, src io.Reader) (int64, error) {
 	return io.Copy(dst, src)
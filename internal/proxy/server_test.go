@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+func TestClientDisconnectCancelsUpstream(t *testing.T) {
+	upstreamCanceled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 读完请求体后服务端才能感知连接关闭
+		io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(upstreamCanceled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {ID: "chat", Name: "Chat", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat},
+	}}
+	s := NewServer(cfg, nil)
+
+	body := `{"model":"chat","messages":[]}`
+	var loggedError string
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Next()
+		loggedError = c.GetString("error")
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case <-upstreamCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("客户端断开后上游请求应被取消")
+	}
+	if loggedError != "client disconnected" {
+		t.Errorf("期望记录client disconnected，实际得到%q", loggedError)
+	}
+}
+
+func TestStreamClientDisconnectCancelsUpstream(t *testing.T) {
+	upstreamCanceled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[]}\n\n"))
+		w.(http.Flusher).Flush()
+		// 不再发送数据，等待代理取消请求
+		select {
+		case <-r.Context().Done():
+			close(upstreamCanceled)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {ID: "chat", Name: "Chat", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat},
+	}}
+	s := NewServer(cfg, nil)
+	tracker := stats.NewErrorTracker(time.Minute, 100)
+	s.SetErrorTracker(tracker)
+
+	body := `{"model":"chat","stream":true,"messages":[]}`
+	endReason := make(chan interface{}, 1)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Next()
+		extra, _ := c.Get("log_extra")
+		extraMap, _ := extra.(map[string]interface{})
+		endReason <- extraMap["stream_end_reason"]
+	})
+	r.Use(s.errorTrackingMiddleware())
+	r.Any("/*path", s.proxyHandler)
+	proxy := httptest.NewServer(r)
+	defer proxy.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, proxy.URL+"/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求代理失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 收到第一个SSE数据块后断开
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data:") {
+		t.Fatalf("期望收到第一个SSE数据块，实际得到%q, %v", line, err)
+	}
+	cancel()
+
+	select {
+	case <-upstreamCanceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("客户端断开后上游请求应被取消")
+	}
+	select {
+	case reason := <-endReason:
+		if reason != "client_disconnect" {
+			t.Errorf("期望stream_end_reason为client_disconnect，实际得到%v", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("客户端断开后流式处理应及时结束")
+	}
+	if total := tracker.Summary("chat").Total; total != 0 {
+		t.Errorf("客户端断开不应计入上游错误，实际记录%d次", total)
+	}
+}
+
+func TestErrorTrackingMixedFailures(t *testing.T) {
+	statuses := make(chan int, 3)
+	statuses <- http.StatusUnauthorized
+	statuses <- http.StatusTooManyRequests
+	statuses <- http.StatusBadGateway
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(<-statuses)
+		w.Write([]byte(`{"error":{"message":"failed"}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {ID: "chat", Name: "Chat", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat},
+		"bad": {
+			ID: "bad", Name: "Bad", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			PromptPath: "messages", PromptValue: 42,
+		},
+	}}
+	s := NewServer(cfg, nil)
+	tracker := stats.NewErrorTracker(time.Minute, 100)
+	s.SetErrorTracker(tracker)
+
+	gin.SetMode(gin.TestMode)
+	send := func(body string) {
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+		r.Use(s.errorTrackingMiddleware())
+		r.Any("/*path", s.proxyHandler)
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	for i := 0; i < 3; i++ {
+		send(`{"model":"chat","messages":[]}`)
+	}
+	send(`{"model":"bad","messages":[]}`)
+	send(`{"model":"unknown","messages":[]}`)
+
+	summary := tracker.Summary("chat")
+	if summary.Counts[stats.ErrorClassUpstream4xx] != 2 || summary.Counts[stats.ErrorClassUpstream5xx] != 1 {
+		t.Errorf("上游错误分类不正确: %+v", summary.Counts)
+	}
+	if tracker.Summary("bad").Counts[stats.ErrorClassInjection] != 1 {
+		t.Errorf("期望记录注入错误: %+v", tracker.Summary("bad").Counts)
+	}
+	if tracker.Summary("unknown").Total != 0 {
+		t.Error("未配置的模型不应记录错误")
+	}
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{}}, nil)
+	router := s.Router()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, VersionPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望状态码200，实际%d %s", w.Code, w.Body.String())
+	}
+	if got := gjson.Get(w.Body.String(), "go_version").String(); got != runtime.Version() {
+		t.Errorf("go_version = %q, want %q", got, runtime.Version())
+	}
+
+	// 其他方法仍需要API Key
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, VersionPath, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /version期望状态码401，实际%d", w.Code)
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	var received string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	writePromptConfig(t, dir, upstream.URL, "系统提示")
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	authService, err := service.NewAuthService(configService.GetDBManager())
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	admin, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	apiKey, err := authService.CreateAPIKey(admin.User.ID, "test", service.GenerateAPIKeyValue(), "", nil, nil)
+	if err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	handler := NewHandler(configService, authService, config.DefaultServerConfig())
+
+	tests := []struct {
+		name   string
+		key    string
+		model  string
+		status int
+	}{
+		{"缺少API Key", "", "watched", http.StatusUnauthorized},
+		{"无效的API Key", "ak_invalid", "watched", http.StatusUnauthorized},
+		{"模型不存在", apiKey.KeyValue, "missing", http.StatusNotFound},
+		{"转发", apiKey.KeyValue, "watched", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+tt.model+`","messages":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.key != "" {
+			req.Header.Set("X-Proxy-Key", tt.key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: 期望状态码%d，实际%d %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+	if !strings.Contains(received, "系统提示") || !strings.Contains(received, `"gpt-4o"`) {
+		t.Errorf("转发的请求体应注入提示并替换模型，实际%s", received)
+	}
+
+	// 每个注册的路由都应转发到上游，OPTIONS请求由代理直接响应
+	for _, route := range handler.(*gin.Engine).Routes() {
+		want := http.StatusOK
+		if route.Method == http.MethodOptions {
+			want = http.StatusNoContent
+		}
+		req := httptest.NewRequest(route.Method, "/v1/chat/completions", strings.NewReader(`{"model":"watched","messages":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Proxy-Key", apiKey.KeyValue)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s %s: 期望状态码%d，实际%d %s", route.Method, route.Path, want, w.Code, w.Body.String())
+		}
+	}
+}
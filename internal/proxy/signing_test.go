@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/signing"
+)
+
+func TestForwardRequestSigning(t *testing.T) {
+	verified := make(chan error, 2)
+	var gotSignature string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(signing.HeaderSignature)
+		verified <- signing.Verify(r, "inference-secret", body, 0, time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"chat.completion"}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"signed":   {ID: "signed", Name: "Signed", Target: "gpt-4o", Url: upstream.URL + "/v1/chat/completions", Type: config.ModelTypeChat, SigningSecret: "inference-secret"},
+		"unsigned": {ID: "unsigned", Name: "Unsigned", Target: "gpt-4o", Url: upstream.URL + "/v1/chat/completions", Type: config.ModelTypeChat},
+	}}
+	s := NewServer(cfg, nil)
+
+	send := func(modelID string) {
+		body := `{"model":"` + modelID + `","messages":[]}`
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+		r.Any("/*path", s.proxyHandler)
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		// 客户端伪造的签名头不应被透传
+		req.Header.Set(signing.HeaderSignature, "v1=forged")
+		req.Header.Set(signing.HeaderTimestamp, "1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("signed")
+	if err := <-verified; err != nil {
+		t.Errorf("上游应能验证代理的签名，实际得到%v", err)
+	}
+
+	send("unsigned")
+	if err := <-verified; err != signing.ErrMissingSignature {
+		t.Errorf("未配置签名密钥的模型不应带签名头，实际得到%v（签名%q）", err, gotSignature)
+	}
+}
@@ -0,0 +1,280 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+)
+
+// resolveStage 解析请求的模型配置并检查API Key权限和维护模式，总是最先执行
+type resolveStage struct{}
+
+func (resolveStage) Name() string { return "resolve" }
+
+func (resolveStage) Process(rc *RequestContext) error {
+	c, s := rc.Gin, rc.server
+	modelID, modelConfig, exists := s.lookupModel(c.Request, rc.Body, rc.IsJSON)
+	c.Set("model_id", modelID)
+	// 未匹配任何模型时使用默认模型，保留客户端原始的模型ID
+	if !exists {
+		if modelConfig, exists = s.config.DefaultModelConfig(); exists {
+			rc.UseDefault = true
+			rc.SetLogExtra("default_model", modelConfig.ID)
+		}
+	}
+	if !exists {
+		return rc.FailCode(http.StatusNotFound, "", i18n.CodeModelNotFound, errorTypeInvalidRequest, modelID)
+	}
+	rc.ModelID, rc.Model = modelID, modelConfig
+
+	if failed := service.FirstFailure(s.authorizer.ModelChecks(apiKeyFromContext(c), modelConfig)); failed != nil {
+		return rc.FailCode(failed.Status, stats.ErrorClassClient, i18n.Code(failed.Code), errorTypePermission, failed.Detail)
+	}
+	if s.usage != nil {
+		s.usage.Record(modelConfig.ID, time.Now())
+	}
+	// 维护模式下直接返回固定的提示信息，不转发到上游
+	if maintenance, ok := modelConfig.MaintenanceMode(s.globalMaintenance()); ok {
+		writeMaintenanceResponse(c, modelID, maintenance, rc.IsJSON && isStreamRequest(rc.Body))
+		return errPipelineDone
+	}
+	// 后台健康检查发现上游不可用时直接失败，不等待上游超时
+	if s.health.Down(modelConfig) {
+		c.Header("Retry-After", strconv.Itoa(modelConfig.HealthCheckInterval))
+		return rc.FailCode(http.StatusServiceUnavailable, stats.ErrorClassNetwork, i18n.CodeUpstreamDown, errorTypeServer)
+	}
+	// 客户端设置的超时预算覆盖排队等待和上游请求的全部时间
+	rc.Defer(s.startTimeoutBudget(c, modelConfig))
+	if len(modelConfig.Targets) > 0 {
+		rc.selectVariant()
+		modelConfig = rc.Model
+	}
+	if modelConfig.CanaryEnabled() {
+		rc.selectCanary()
+		modelConfig = rc.Model
+	}
+	if len(modelConfig.PromptVariants) > 0 {
+		rc.selectPromptVariant()
+		modelConfig = rc.Model
+	}
+	if rc.UseDefault {
+		c.Set("target_model", modelID)
+	} else {
+		c.Set("target_model", modelConfig.Target)
+	}
+	return nil
+}
+
+// injectStage 向JSON请求体注入模型配置的Prompt和tools
+type injectStage struct{}
+
+func (injectStage) Name() string { return config.PipelineStageInject }
+
+func (injectStage) Process(rc *RequestContext) error {
+	start := time.Now()
+	defer func() {
+		rc.SetLogExtra(logger.ExtraInjectTime, elapsedMs(start, time.Now()))
+	}()
+
+	// 开启allow_prompt_override的API Key可以按请求跳过或追加Prompt
+	promptConfig, err := promptOverrideConfig(rc.Gin, rc.Model)
+	if err != nil {
+		return err
+	}
+	if !rc.IsJSON {
+		// 非JSON请求体不做Prompt注入
+		return nil
+	}
+
+	body := rc.ModifiedBody
+	// replace_system会删除已有的system消息，不会产生重复的Prompt，无需去重
+	if promptConfig != nil && promptConfig.DedupePrompt && promptConfig.PromptPosition != config.PromptPositionReplaceSystem {
+		deduped := hasInjectedPrompt(body, promptConfig)
+		rc.SetLogExtra("prompt_deduped", deduped)
+		if deduped {
+			promptConfig = nil
+		}
+	}
+	if promptConfig != nil {
+		body, err = injectPrompt(body, promptConfig)
+		if promptConfig.Type == config.ModelTypeChat {
+			position := promptConfig.PromptPosition
+			if position == "" {
+				position = config.PromptPositionPrepend
+			}
+			rc.SetLogExtra("prompt_position", position)
+		}
+	}
+	if err == nil {
+		body, err = injectTools(body, rc.Model)
+	}
+	if err != nil {
+		return rc.FailCode(http.StatusInternalServerError, stats.ErrorClassInjection, i18n.CodeInjectPromptFailed, errorTypeServer, err)
+	}
+	rc.SetBody(body)
+	return nil
+}
+
+// rewriteStage 将请求中的模型ID替换为目标模型ID，并生成上游URL
+type rewriteStage struct{}
+
+func (rewriteStage) Name() string { return config.PipelineStageRewrite }
+
+func (rewriteStage) Process(rc *RequestContext) error {
+	c, modelConfig := rc.Gin, rc.Model
+	var err error
+	body := rc.ModifiedBody
+	switch {
+	case rc.UseDefault:
+		// 使用默认模型时透传原始模型ID
+	case rc.IsJSON:
+		body, err = replaceModelID(body, modelConfig.Target)
+	default:
+		// 非JSON请求体只在模型ID来源处替换模型ID
+		body, err = replaceModelIDInSource(c.Request, body, modelConfig)
+	}
+	if err != nil {
+		return rc.FailCode(http.StatusInternalServerError, stats.ErrorClassInjection, i18n.CodeRewriteModelFailed, errorTypeServer, err)
+	}
+	if rc.IsJSON && modelConfig.MinifyBody {
+		body = minifyJSON(body)
+	}
+	rc.SetBody(body)
+	c.Set("modified_body", string(body))
+
+	// 展开URL模板中的占位符后解析上游URL
+	upstreamURL, err := modelConfig.ExpandURL(rc.ModelID, c.Request.URL.Path)
+	if err != nil {
+		return rc.FailCode(http.StatusBadRequest, stats.ErrorClassClient, i18n.CodeUpstreamURLFailed, errorTypeInvalidRequest, err)
+	}
+	parseURL, err := url.Parse(upstreamURL)
+	if err != nil {
+		stageErr := rc.FailCode(http.StatusInternalServerError, "", i18n.CodeUpstreamURLFailed, errorTypeServer, err)
+		stageErr.Message = fmt.Sprintf("解析上游URL失败: %v, URL: %s", err, upstreamURL)
+		return stageErr
+	}
+	rc.UpstreamURL = upstreamURL
+	c.Set("proxy_url", upstreamURL)
+	c.Set("proxy_scheme", parseURL.Scheme)
+	c.Set("proxy_host", parseURL.Host)
+	c.Set("proxy_port", parseURL.Port())
+	c.Set("proxy_path", parseURL.Path)
+	c.Set("proxy_body", string(body))
+	return nil
+}
+
+// cacheStage 开启缓存的模型在命中时直接返回缓存的响应，流式请求不缓存
+type cacheStage struct{}
+
+func (cacheStage) Name() string { return config.PipelineStageCache }
+
+func (cacheStage) Process(rc *RequestContext) error {
+	c, modelConfig := rc.Gin, rc.Model
+	if modelConfig.CacheTTL <= 0 || isStreamRequest(rc.ModifiedBody) {
+		return nil
+	}
+	cacheScope := modelConfig.ID
+	if rc.Variant != "" {
+		// 不同变体的上游可能不同，分别缓存
+		cacheScope += "#" + rc.Variant
+	}
+	if rc.Canary {
+		cacheScope += "#canary"
+	}
+	if rc.ExperimentVariant != "" {
+		// 不同Prompt变体的响应不同，自定义pipeline中缓存可能先于注入执行
+		cacheScope += "@" + rc.ExperimentVariant
+	}
+	upstreamURL := rc.UpstreamURL
+	if upstreamURL == "" {
+		// 自定义pipeline中缓存先于rewrite执行时按同样的规则展开上游URL，失败时由rewrite阶段返回错误
+		upstreamURL, _ = modelConfig.ExpandURL(rc.ModelID, c.Request.URL.Path)
+	}
+	cacheKey := responseCacheKey(cacheScope, c.Request.Method, upstreamURL, rc.ModifiedBody)
+	if cached, ok := rc.server.cache.Get(cacheKey); ok {
+		rc.server.writeCachedResponse(c, cached, modelConfig)
+		return errPipelineDone
+	}
+	c.Set("cache_key", cacheKey)
+	c.Set("cache_ttl", time.Duration(modelConfig.CacheTTL)*time.Second)
+	c.Header("X-Cache", "MISS")
+	rc.SetLogExtra("cache", "MISS")
+	return nil
+}
+
+// limitsStage 限制模型的并发请求数，名额在请求结束（包括上游出错、客户端断开）时释放
+type limitsStage struct{}
+
+func (limitsStage) Name() string { return config.PipelineStageLimits }
+
+func (limitsStage) Process(rc *RequestContext) error {
+	c, modelConfig, limiter := rc.Gin, rc.Model, rc.server.limiter
+	queue, queueTimeout := modelConfig.QueueWait()
+	release, err := limiter.Acquire(c.Request.Context(), modelConfig.ID, modelConfig.MaxConcurrency, queue, queueTimeout)
+	if modelConfig.MaxConcurrency > 0 {
+		load := limiter.Load(modelConfig.ID)
+		rc.SetLogExtra("in_flight", load.InFlight)
+		rc.SetLogExtra("queued", load.Queued)
+	}
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			// 客户端在排队期间断开连接或请求已超时
+			markClientDisconnected(c)
+			c.Abort()
+			return &StageError{}
+		}
+		load := limiter.Load(modelConfig.ID)
+		return &StageError{
+			Status:  http.StatusTooManyRequests,
+			Class:   stats.ErrorClassClient,
+			Message: i18n.Message(i18n.Default, i18n.CodeModelOverloaded, err),
+			Body: gin.H{"error": gin.H{
+				"code":        i18n.CodeModelOverloaded,
+				"type":        "rate_limit_error",
+				"message":     i18n.T(c, i18n.CodeModelOverloaded, err),
+				"in_flight":   load.InFlight,
+				"queue_depth": load.Queued,
+			}},
+		}
+	}
+	rc.Defer(release)
+	return nil
+}
+
+// forwardStage 转发请求到上游服务并返回上游响应
+type forwardStage struct{}
+
+func (forwardStage) Name() string { return config.PipelineStageForward }
+
+func (forwardStage) Process(rc *RequestContext) error {
+	c := rc.Gin
+	if rc.UpstreamURL == "" {
+		return rc.FailCode(http.StatusInternalServerError, "", i18n.CodeUpstreamURLMissing, errorTypeServer)
+	}
+	err := rc.server.forwardRequest(c, rc.UpstreamURL, rc.ModifiedBody, rc.Model)
+	if err == nil {
+		return nil
+	}
+	// forwardRequest内部已经记录了上游错误，这里只在没有记录时补充
+	message := c.GetString("error")
+	if message == "" {
+		message = fmt.Sprintf("转发请求失败: %v", err)
+	}
+	if c.Writer.Written() || c.Request.Context().Err() != nil {
+		// 响应已开始写入（如流式响应中途失败）或客户端已断开，只记录错误
+		return &StageError{Message: message}
+	}
+	stageErr := rc.FailCode(http.StatusInternalServerError, "", i18n.CodeForwardFailed, errorTypeServer, err)
+	stageErr.Message = message
+	return stageErr
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"bytes"
+
+	"github.com/tidwall/gjson"
+)
+
+// sseDoneMarker OpenAI流式响应的结束标记
+const sseDoneMarker = "[DONE]"
+
+// streamInspector 逐行检查SSE流，识别上游中途返回的错误和结束标记
+type streamInspector struct {
+	event string // 当前事件类型
+	err   string // 上游返回的错误信息
+	done  bool   // 是否收到[DONE]结束标记
+}
+
+// inspect 检查一行SSE数据
+func (i *streamInspector) inspect(line []byte) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		// 空行表示一个事件结束
+		i.event = ""
+		return
+	}
+
+	if value, ok := sseField(line, "event"); ok {
+		i.event = string(value)
+		return
+	}
+	data, ok := sseField(line, "data")
+	if !ok {
+		return
+	}
+	if string(data) == sseDoneMarker {
+		i.done = true
+		return
+	}
+
+	if i.event == "error" {
+		i.setError(data)
+		return
+	}
+	if errResult := gjson.GetBytes(data, "error"); errResult.Exists() && errResult.Type != gjson.Null {
+		i.setError([]byte(errResult.Raw))
+	}
+}
+
+// setError 记录第一个错误，优先使用error.message
+func (i *streamInspector) setError(payload []byte) {
+	if i.err != "" {
+		return
+	}
+	for _, path := range []string{"error.message", "message"} {
+		if msg := gjson.GetBytes(payload, path); msg.Type == gjson.String && msg.String() != "" {
+			i.err = msg.String()
+			return
+		}
+	}
+	if errString := gjson.GetBytes(payload, "error"); errString.Type == gjson.String {
+		i.err = errString.String()
+		return
+	}
+	i.err = string(payload)
+}
+
+// endState 返回流的结束状态：done表示收到结束标记，truncated表示未收到结束标记就结束
+func (i *streamInspector) endState() string {
+	if i.done {
+		return "done"
+	}
+	return "truncated"
+}
+
+// sseField 解析SSE字段行，返回字段值
+func sseField(line []byte, name string) ([]byte, bool) {
+	value, ok := bytes.CutPrefix(line, []byte(name+":"))
+	if !ok {
+		return nil, false
+	}
+	return bytes.TrimPrefix(value, []byte(" ")), true
+}
@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestStreamInspector(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		err    string
+		end    string
+	}{
+		{
+			name:   "正常结束",
+			stream: "data: {\"choices\":[]}\n\ndata: [DONE]\n\n",
+			end:    "done",
+		},
+		{
+			name:   "error事件",
+			stream: "data: {\"choices\":[]}\n\nevent: error\ndata: {\"message\":\"overloaded\"}\n\n",
+			err:    "overloaded",
+			end:    "truncated",
+		},
+		{
+			name:   "data中的error对象",
+			stream: "data: {\"choices\":[]}\n\ndata: {\"error\":{\"message\":\"rate limited\",\"type\":\"rate_limit\"}}\n\ndata: [DONE]\n\n",
+			err:    "rate limited",
+			end:    "done",
+		},
+		{
+			name:   "中途截断",
+			stream: "data: {\"choices\":[]}\n\ndata: {\"choi",
+			end:    "truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspector := &streamInspector{}
+			for _, line := range strings.SplitAfter(tt.stream, "\n") {
+				inspector.inspect([]byte(line))
+			}
+			if inspector.err != tt.err {
+				t.Errorf("期望错误%q，实际得到%q", tt.err, inspector.err)
+			}
+			if inspector.endState() != tt.end {
+				t.Errorf("期望结束状态%s，实际得到%s", tt.end, inspector.endState())
+			}
+		})
+	}
+}
+
+func TestHandleStreamingResponseForwardsErrors(t *testing.T) {
+	stream := "data: {\"choices\":[]}\n\nevent: error\ndata: {\"error\":{\"message\":\"upstream overloaded\"}}"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(stream))
+	}))
+	defer upstream.Close()
+
+	resp, err := http.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("请求上游失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	s := &Server{}
+	size, err := s.handleStreamingResponseWithLogging(c, resp)
+	if err != nil {
+		t.Fatalf("处理流式响应失败: %v", err)
+	}
+	if size != int64(len(stream)) {
+		t.Errorf("期望响应大小%d，实际得到%d", len(stream), size)
+	}
+
+	if w.Body.String() != stream {
+		t.Errorf("流式数据应原样转发，实际得到%q", w.Body.String())
+	}
+	if got := c.GetString("error"); !strings.Contains(got, "upstream overloaded") {
+		t.Errorf("期望记录上游错误，实际得到%q", got)
+	}
+	extra, _ := c.Get("log_extra")
+	if end := extra.(map[string]interface{})["stream_end"]; end != "truncated" {
+		t.Errorf("期望结束状态为truncated，实际得到%v", end)
+	}
+}
+
+func TestResponseStreamMode(t *testing.T) {
+	s := &Server{}
+	response := func(contentType string, chunked bool) *http.Response {
+		resp := &http.Response{Header: http.Header{"Content-Type": {contentType}}}
+		if chunked {
+			resp.TransferEncoding = []string{"chunked"}
+		}
+		return resp
+	}
+	streamBody := []byte(`{"stream":true}`)
+
+	tests := []struct {
+		name       string
+		configured config.StreamMode
+		resp       *http.Response
+		body       []byte
+		want       config.StreamMode
+	}{
+		{"SSE", "", response("text/event-stream", true), nil, config.StreamModeSSE},
+		{"NDJSON", config.StreamModeAuto, response("application/x-ndjson", true), nil, config.StreamModeNDJSON},
+		{"分块JSON数组", "", response("application/json", true), streamBody, config.StreamModeJSONArray},
+		{"未请求流式", "", response("application/json", true), []byte(`{}`), config.StreamModeNone},
+		{"非分块JSON", "", response("application/json", false), streamBody, config.StreamModeNone},
+		{"配置覆盖", config.StreamModeJSONArray, response("application/json", false), nil, config.StreamModeJSONArray},
+		{"配置不流式", config.StreamModeNone, response("text/event-stream", true), nil, config.StreamModeNone},
+	}
+	for _, tt := range tests {
+		if got := s.responseStreamMode(tt.configured, tt.resp, tt.body); got != tt.want {
+			t.Errorf("%s: 期望%s，实际得到%s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestJSONArrayStreamForwardsChunksIncrementally(t *testing.T) {
+	firstChunk := `[{"candidates":[{"content":{"parts":[{"text":"你"}]}}]}`
+	lastChunk := `,{"candidates":[{"content":{"parts":[{"text":"好"}]}}]}]`
+	received := make(chan struct{})
+	timedOut := make(chan bool, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write([]byte(firstChunk))
+		w.(http.Flusher).Flush()
+		// 客户端收到第一个分块后才发送最后一个分块
+		select {
+		case <-received:
+			timedOut <- false
+		case <-time.After(2 * time.Second):
+			timedOut <- true
+		}
+		w.Write([]byte(lastChunk))
+	}))
+	defer upstream.Close()
+
+	model := &config.ModelConfig{ID: "gemini", Name: "gemini", Target: "gemini-1.5-pro", Url: upstream.URL, Type: config.ModelTypeChat,
+		PromptPath: "messages", PromptValue: map[string]interface{}{"role": "system", "content": "test"}}
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{"gemini": model}}, nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+	})
+	r.Any("/*path", s.proxyHandler)
+	proxyServer := httptest.NewServer(r)
+	defer proxyServer.Close()
+
+	resp, err := http.Post(proxyServer.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gemini","stream":true}`))
+	if err != nil {
+		t.Fatalf("请求代理失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	first := make([]byte, len(firstChunk))
+	if _, err := io.ReadFull(reader, first); err != nil {
+		t.Fatalf("读取第一个分块失败: %v", err)
+	}
+	if string(first) != firstChunk {
+		t.Errorf("第一个分块不一致: %s", first)
+	}
+	close(received)
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("读取剩余响应失败: %v", err)
+	}
+	if string(rest) != lastChunk {
+		t.Errorf("最后一个分块不一致: %s", rest)
+	}
+	if <-timedOut {
+		t.Error("客户端应在上游发送最后一个分块之前收到第一个分块")
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"math/rand"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// weightedIndex 按权重选择下标，pick返回[0,total)之间的数；权重全为0时返回-1
+func weightedIndex(weights []int, pick func(total int) int) int {
+	total := 0
+	for _, weight := range weights {
+		if weight > 0 {
+			total += weight
+		}
+	}
+	if total <= 0 {
+		return -1
+	}
+	n := pick(total)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if n < weight {
+			return i
+		}
+		n -= weight
+	}
+	return -1
+}
+
+// pickTarget 按权重随机选择一个目标，intn返回[0,n)的随机数；权重全为0时返回false
+func pickTarget(targets []config.WeightedTarget, intn func(n int) int) (config.WeightedTarget, bool) {
+	weights := make([]int, len(targets))
+	for i, target := range targets {
+		weights[i] = target.Weight
+	}
+	i := weightedIndex(weights, intn)
+	if i < 0 {
+		return config.WeightedTarget{}, false
+	}
+	return targets[i], true
+}
+
+// selectVariant 模型配置了按权重分流的目标时选择本次请求的目标，返回转发到该目标的模型配置，
+// 并在访问日志中记录变体名称
+func (rc *RequestContext) selectVariant() {
+	target, ok := pickTarget(rc.Model.Targets, rand.Intn)
+	if !ok {
+		return
+	}
+	rc.Model = rc.Model.WithTarget(target)
+	rc.Variant = target.Name
+	rc.SetLogExtra("variant", target.Name)
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestPickTargetDistribution(t *testing.T) {
+	targets := []config.WeightedTarget{
+		{Name: "primary", Weight: 90},
+		{Name: "paused", Weight: 0},
+		{Name: "candidate", Weight: 10},
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	const draws = 100000
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		target, ok := pickTarget(targets, rng.Intn)
+		if !ok {
+			t.Fatal("权重大于0时应选中目标")
+		}
+		counts[target.Name]++
+	}
+
+	if counts["paused"] != 0 {
+		t.Errorf("权重为0的目标不应被选中，实际选中%d次", counts["paused"])
+	}
+	for name, want := range map[string]float64{"primary": 0.9, "candidate": 0.1} {
+		got := float64(counts[name]) / draws
+		if math.Abs(got-want) > 0.01 {
+			t.Errorf("%s的比例 = %.4f, want %.2f±0.01", name, got, want)
+		}
+	}
+
+	if _, ok := pickTarget([]config.WeightedTarget{{Name: "off", Weight: 0}}, rng.Intn); ok {
+		t.Error("权重全为0时不应选中目标")
+	}
+}
+
+func TestWeightedTargetsProxy(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string) // 上游名称 -> 收到的模型ID
+	newUpstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			received[name] = append(received[name], gjson.GetBytes(body, "model").String())
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok":true}`))
+		}))
+	}
+	upstreamA, upstreamB := newUpstream("a"), newUpstream("b")
+	defer upstreamA.Close()
+	defer upstreamB.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"gpt-4o": {
+			ID: "gpt-4o", Name: "GPT-4o", Target: "gpt-4o", Url: upstreamA.URL, Type: config.ModelTypeChat,
+			Targets: []config.WeightedTarget{
+				{Name: "control", Target: "gpt-4o", Url: upstreamA.URL, Weight: 3},
+				{Name: "experiment", Target: "gpt-4o-2024-11-20", Url: upstreamB.URL, Weight: 1},
+			},
+		},
+	}}
+	s := NewServer(cfg, nil)
+
+	variants := make(map[string]int)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+		c.Next()
+		extra, _ := c.Get("log_extra")
+		fields, _ := extra.(map[string]interface{})
+		variant, _ := fields["variant"].(string)
+		variants[variant]++
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	const requests = 400
+	for i := 0; i < requests; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+			strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+
+	if variants["control"]+variants["experiment"] != requests {
+		t.Fatalf("每个请求都应记录变体名称: %v", variants)
+	}
+	if len(received["a"]) != variants["control"] || len(received["b"]) != variants["experiment"] {
+		t.Errorf("上游收到的请求数与记录的变体不一致: a=%d b=%d variants=%v", len(received["a"]), len(received["b"]), variants)
+	}
+	// 3:1的权重下对照组约占75%，400次请求时允许较宽的误差
+	if share := float64(variants["control"]) / requests; share < 0.65 || share > 0.85 {
+		t.Errorf("control的比例 = %.2f, want 约0.75", share)
+	}
+	for _, model := range received["b"] {
+		if model != "gpt-4o-2024-11-20" {
+			t.Errorf("experiment上游收到的模型ID = %q, want gpt-4o-2024-11-20", model)
+		}
+	}
+	for _, model := range received["a"] {
+		if model != "gpt-4o" {
+			t.Errorf("control上游收到的模型ID = %q, want gpt-4o", model)
+		}
+	}
+	if cfg.Models["gpt-4o"].Url != upstreamA.URL {
+		t.Error("选择变体不应修改共享的模型配置")
+	}
+}
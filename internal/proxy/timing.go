@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+// elapsedMs 返回从start开始的耗时(毫秒)，精确到微秒
+func elapsedMs(start, end time.Time) float64 {
+	return float64(end.Sub(start).Microseconds()) / 1000
+}
+
+// upstreamTimer 记录一次上游请求获得连接和收到首字节的时间
+type upstreamTimer struct {
+	start time.Time
+
+	mutex     sync.Mutex
+	connected time.Time
+	firstByte time.Time
+}
+
+// newUpstreamTimer 开始计时，返回的上下文用于上游请求
+func newUpstreamTimer(ctx context.Context) (*upstreamTimer, context.Context) {
+	t := &upstreamTimer{start: time.Now()}
+	return t, httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			t.mark(&t.connected)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	})
+}
+
+// mark 记录事件的时间，重试建立连接时只保留第一次
+func (t *upstreamTimer) mark(at *time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// record 将上游耗时写入访问日志扩展字段，未获得连接或未收到响应时不记录对应字段
+func (t *upstreamTimer) record(c *gin.Context) {
+	end := time.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.connected.IsZero() {
+		setLogExtra(c, logger.ExtraUpstreamConnect, elapsedMs(t.start, t.connected))
+	}
+	if !t.firstByte.IsZero() {
+		setLogExtra(c, logger.ExtraUpstreamTTFB, elapsedMs(t.start, t.firstByte))
+	}
+	setLogExtra(c, logger.ExtraUpstreamTime, elapsedMs(t.start, end))
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+func TestLatencyBreakdown(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat": {ID: "chat", Name: "Chat", Target: "gpt-4o", Url: upstream.URL, Type: config.ModelTypeChat,
+			Prompt: "你是助手", PromptPath: "messages", PromptValueType: config.ValueTypeArray},
+	}}
+	s := NewServer(cfg, nil)
+
+	var extra map[string]interface{}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Set("request_body", string(body))
+		c.Next()
+		extra = c.MustGet("log_extra").(map[string]interface{})
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"chat","messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	timing := func(key string) float64 {
+		value, ok := extra[key].(float64)
+		if !ok {
+			t.Fatalf("扩展字段%s应为毫秒数，实际为%#v", key, extra[key])
+		}
+		return value
+	}
+	inject, connect, ttfb, total := timing(logger.ExtraInjectTime), timing(logger.ExtraUpstreamConnect), timing(logger.ExtraUpstreamTTFB), timing(logger.ExtraUpstreamTime)
+	if inject < 0 || connect < 0 || connect > ttfb {
+		t.Errorf("连接耗时应在首字节之前: inject=%v connect=%v ttfb=%v", inject, connect, ttfb)
+	}
+	if ttfb < 30 || total < ttfb+20 {
+		t.Errorf("首字节耗时应包含上游处理时间，总耗时应包含响应体转发时间: ttfb=%v upstream=%v", ttfb, total)
+	}
+}
+
+func TestLatencyFieldsInFormattedLog(t *testing.T) {
+	formatter := logger.NewJSONFormatter(logger.FormatterConfig{Fields: map[string][]string{
+		"fields": {"$inject_time", "$upstream_connect_time", "$ttfb", "$upstream_time"},
+	}})
+	line, err := formatter.Format(&logger.RequestLogData{Extra: map[string]interface{}{
+		logger.ExtraInjectTime:   0.125,
+		logger.ExtraUpstreamTTFB: 42.5,
+		logger.ExtraUpstreamTime: 80.0,
+	}})
+	if err != nil {
+		t.Fatalf("格式化日志失败: %v", err)
+	}
+	// 未获得上游连接时（如直接返回缓存）对应字段为空
+	want := `{"inject_time":0.125,"ttfb":42.5,"upstream_connect_time":"","upstream_time":80}`
+	if got := strings.TrimSpace(string(line)); got != want {
+		t.Errorf("日志 = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+func TestTrashedModelNotServed(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	configService, err := service.NewConfigService(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	model := &config.ModelConfig{ID: "trashed", Name: "回收站模型", Target: "gpt-4o", Url: upstream.URL,
+		Type: config.ModelTypeChat, Source: config.ModelSourceAPI}
+	if err := configService.SaveModel(model); err != nil {
+		t.Fatalf("保存模型失败: %v", err)
+	}
+
+	s := NewServerWithService(configService, nil, config.DefaultServerConfig())
+	body := `{"model":"trashed","messages":[{"role":"user","content":"你好"}]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusOK {
+		t.Fatalf("删除前期望返回200，实际得到%d: %s", w.Code, w.Body.String())
+	}
+
+	// 移入回收站后立即停止代理该模型
+	if err := configService.DeleteModel("trashed"); err != nil {
+		t.Fatalf("删除模型失败: %v", err)
+	}
+	w := send()
+	if w.Code != http.StatusNotFound || gjson.Get(w.Body.String(), "error.code").String() != "model_not_found" {
+		t.Errorf("回收站中的模型期望返回404 model_not_found，实际得到%d: %s", w.Code, w.Body.String())
+	}
+
+	if _, err := configService.RestoreModel(service.GlobalScope, "trashed"); err != nil {
+		t.Fatalf("恢复模型失败: %v", err)
+	}
+	if w := send(); w.Code != http.StatusOK {
+		t.Errorf("恢复后期望返回200，实际得到%d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestURLTemplateExpansion(t *testing.T) {
+	var upstreamPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer upstream.Close()
+
+	model := &config.ModelConfig{ID: "image", Name: "Image", Target: "dalle3-prod", Type: config.ModelTypeImage,
+		Url: upstream.URL + "/openai/deployments/{target}/images/generations"}
+	if err := model.Validate(); err != nil {
+		t.Fatalf("模型配置验证失败: %v", err)
+	}
+	s := NewServer(&config.Config{Models: map[string]*config.ModelConfig{"image": model}}, nil)
+
+	body := `{"model":"image","prompt":"a cat"}`
+	var proxyURL string
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("request_body", body)
+		c.Next()
+		proxyURL = c.GetString("proxy_url")
+	})
+	r.Any("/*path", s.proxyHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if upstreamPath != "/openai/deployments/dalle3-prod/images/generations" {
+		t.Errorf("上游收到的路径 = %q", upstreamPath)
+	}
+	if want := upstream.URL + "/openai/deployments/dalle3-prod/images/generations"; proxyURL != want {
+		t.Errorf("访问日志应记录展开后的URL，proxy_url = %q, want %q", proxyURL, want)
+	}
+}
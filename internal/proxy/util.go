@@ -1,9 +1,16 @@
 package proxy
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
 	"github.com/tidwall/gjson"
@@ -11,11 +18,15 @@ import (
 )
 
 func injectPrompt(body []byte, cfg *config.ModelConfig) ([]byte, error) {
+	if cfg.Type == config.ModelTypeEmbedding {
+		return injectEmbeddingPrompt(body, cfg)
+	}
+
 	bodyStr := string(body)
 	val := cfg.PromptValue
 	valType := cfg.PromptValueType
 	promptPath := cfg.PromptPath
-	
+
 	if val == nil {
 		switch cfg.Type {
 		case config.ModelTypeChat:
@@ -34,7 +45,7 @@ func injectPrompt(body []byte, cfg *config.ModelConfig) ([]byte, error) {
 			return nil, fmt.Errorf("unsupported model type: %s", cfg.Type)
 		}
 	}
-	
+
 	// 如果PromptPath为空，根据模型类型设置默认路径
 	if promptPath == "" {
 		switch cfg.Type {
@@ -51,29 +62,13 @@ func injectPrompt(body []byte, cfg *config.ModelConfig) ([]byte, error) {
 		result := gjson.Get(bodyStr, promptPath)
 		switch {
 		case result.IsArray():
-			// 将cfg.PromptValue添加到数组首位
-			arr := result.Array()
-			vs := make([]interface{}, 0, len(arr)+1)
-			vs = append(vs, val)
-			for _, v := range arr {
-				vs = append(vs, v.Value())
-			}
-			data, err := sjson.Set(bodyStr, promptPath, vs)
-			if err != nil {
-				return nil, err
-			}
-			return []byte(data), nil
+			// 按prompt_position将cfg.PromptValue插入数组
+			return insertPromptMessage(bodyStr, promptPath, val, cfg.PromptPosition)
 		case result.Type == gjson.Null:
 			switch valType {
 			case "", config.ValueTypeArray:
-				vs := make([]interface{}, 0, +1)
-				vs = append(vs, val)
 				// 如果路径不存在，则直接设置为数组
-				data, err := sjson.Set(bodyStr, promptPath, vs)
-				if err != nil {
-					return nil, err
-				}
-				return []byte(data), nil
+				return mergeArray(bodyStr, promptPath, []interface{}{val}, true)
 			case config.ValueTypeObject:
 				// 如果路径不存在，则直接设置为对象
 				data, err := sjson.Set(bodyStr, promptPath, val)
@@ -120,6 +115,209 @@ func injectPrompt(body []byte, cfg *config.ModelConfig) ([]byte, error) {
 	}
 }
 
+// insertPromptMessage 按position将Prompt消息插入路径处的消息数组
+func insertPromptMessage(bodyStr, path string, message interface{}, position config.PromptPosition) ([]byte, error) {
+	switch position {
+	case config.PromptPositionAppend:
+		return mergeArray(bodyStr, path, []interface{}{message}, false)
+	case config.PromptPositionReplaceSystem:
+		// 删除客户端的所有system消息，再将Prompt插入到首位
+		vs := []interface{}{message}
+		for _, item := range gjson.Get(bodyStr, path).Array() {
+			if item.Get("role").String() != "system" {
+				vs = append(vs, item.Value())
+			}
+		}
+		data, err := sjson.Set(bodyStr, path, vs)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(data), nil
+	case config.PromptPositionMergeSystem:
+		content, ok := message.(map[string]interface{})["content"].(string)
+		if !ok {
+			break
+		}
+		for i, item := range gjson.Get(bodyStr, path).Array() {
+			if item.Get("role").String() != "system" {
+				continue
+			}
+			contentPath := fmt.Sprintf("%s.%d.content", path, i)
+			existing := item.Get("content")
+			var data string
+			var err error
+			switch {
+			case existing.IsArray():
+				// 多段内容的system消息追加一个文本段
+				data, err = sjson.Set(bodyStr, contentPath+".-1", map[string]interface{}{"type": "text", "text": content})
+			case existing.Type == gjson.Null || existing.String() == "":
+				data, err = sjson.Set(bodyStr, contentPath, content)
+			default:
+				data, err = sjson.Set(bodyStr, contentPath, existing.String()+"\n"+content)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return []byte(data), nil
+		}
+		// 没有system消息或Prompt内容不是文本时插入到首位
+	}
+	return mergeArray(bodyStr, path, []interface{}{message}, true)
+}
+
+// hasInjectedPrompt 判断请求的消息数组中是否已有包含Prompt的system消息，用于多轮对话中
+// 客户端重发的历史消息已带有之前注入的Prompt的情况。content为字符串时比较去掉首尾空白后的内容，
+// 为多段内容时比较任一文本段或全部文本段拼接后的内容；merge_system拼接在客户端内容之后的Prompt同样识别
+func hasInjectedPrompt(body []byte, cfg *config.ModelConfig) bool {
+	prompt := strings.TrimSpace(cfg.Prompt)
+	if prompt == "" || cfg.PromptValue != nil {
+		return false
+	}
+	path := cfg.PromptPath
+	if path == "" {
+		path = "messages"
+	}
+	matches := func(text string) bool {
+		text = strings.TrimSpace(text)
+		return text == prompt || strings.HasSuffix(text, "\n"+prompt)
+	}
+	for _, message := range gjson.GetBytes(body, path).Array() {
+		if message.Get("role").String() != "system" {
+			continue
+		}
+		content := message.Get("content")
+		if !content.IsArray() {
+			if matches(content.String()) {
+				return true
+			}
+			continue
+		}
+		var texts []string
+		for _, part := range content.Array() {
+			if part.Get("type").String() != "text" {
+				continue
+			}
+			if matches(part.Get("text").String()) {
+				return true
+			}
+			texts = append(texts, part.Get("text").String())
+		}
+		if matches(strings.Join(texts, "\n")) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeArray 将items合并到路径处的数组中，prepend为true时插入到数组首位，否则追加到末尾；
+// 路径不存在时创建数组
+func mergeArray(bodyStr, path string, items []interface{}, prepend bool) ([]byte, error) {
+	result := gjson.Get(bodyStr, path)
+	if result.Type != gjson.Null && !result.IsArray() {
+		return nil, fmt.Errorf("path %s is not an array", path)
+	}
+
+	arr := result.Array()
+	vs := make([]interface{}, 0, len(arr)+len(items))
+	if prepend {
+		vs = append(vs, items...)
+	}
+	for _, v := range arr {
+		vs = append(vs, v.Value())
+	}
+	if !prepend {
+		vs = append(vs, items...)
+	}
+	data, err := sjson.Set(bodyStr, path, vs)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+// injectTools 将模型配置的tools注入到请求的tools数组：请求中没有tools时直接创建；
+// replace模式替换客户端的tools，append模式追加客户端未定义的同名工具
+func injectTools(body []byte, cfg *config.ModelConfig) ([]byte, error) {
+	if len(cfg.Tools) == 0 {
+		return body, nil
+	}
+	bodyStr := string(body)
+
+	if cfg.ToolsMode == config.ToolsModeReplace {
+		data, err := sjson.Set(bodyStr, "tools", cfg.Tools)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(data), nil
+	}
+
+	defined := make(map[string]bool)
+	for _, tool := range gjson.Get(bodyStr, "tools").Array() {
+		if name := tool.Get("function.name"); name.Exists() {
+			defined[name.String()] = true
+		} else if name := tool.Get("name"); name.Exists() {
+			defined[name.String()] = true
+		}
+	}
+	tools := make([]interface{}, 0, len(cfg.Tools))
+	for _, tool := range cfg.Tools {
+		if name := toolName(tool); name != "" && defined[name] {
+			// 同名工具以客户端的定义为准
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return mergeArray(bodyStr, "tools", tools, false)
+}
+
+// toolName 获取工具定义中的函数名，兼容{"function":{"name":...}}和{"name":...}两种格式
+func toolName(tool interface{}) string {
+	m, _ := tool.(map[string]interface{})
+	if function, ok := m["function"].(map[string]interface{}); ok {
+		if name, ok := function["name"].(string); ok {
+			return name
+		}
+	}
+	name, _ := m["name"].(string)
+	return name
+}
+
+// injectEmbeddingPrompt 将Prompt作为前缀拼接到向量请求的input（字符串或字符串数组的每个元素）上，
+// 未配置Prompt时原样返回；token数组形式的input无法拼接文本，原样透传
+func injectEmbeddingPrompt(body []byte, cfg *config.ModelConfig) ([]byte, error) {
+	prefix := cfg.Prompt
+	if val, ok := cfg.PromptValue.(string); ok && val != "" {
+		prefix = val
+	}
+	if prefix == "" {
+		return body, nil
+	}
+
+	promptPath := cfg.PromptPath
+	if promptPath == "" {
+		promptPath = "input"
+	}
+
+	result := gjson.GetBytes(body, promptPath)
+	switch {
+	case result.Type == gjson.String:
+		return sjson.SetBytes(body, promptPath, prefix+result.String())
+	case result.IsArray():
+		items := result.Array()
+		inputs := make([]string, 0, len(items))
+		for _, item := range items {
+			if item.Type != gjson.String {
+				fmt.Printf("警告: 模型 %s 的input不是字符串数组（可能为token数组），跳过Prompt注入\n", cfg.ID)
+				return body, nil
+			}
+			inputs = append(inputs, prefix+item.String())
+		}
+		return sjson.SetBytes(body, promptPath, inputs)
+	default:
+		return body, nil
+	}
+}
+
 func replaceModelID(body []byte, target string) ([]byte, error) {
 	bodyStr := string(body)
 	result, err := sjson.Set(bodyStr, "model", target)
@@ -129,6 +327,20 @@ func replaceModelID(body []byte, target string) ([]byte, error) {
 	return []byte(result), nil
 }
 
+// minifyJSON 将JSON压缩为紧凑格式，只去掉空白，不改变字段顺序和值；无法解析时原样返回
+func minifyJSON(body []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
+
+// isStreamRequest 判断是否为流式请求
+func isStreamRequest(body []byte) bool {
+	return gjson.GetBytes(body, "stream").Bool()
+}
+
 func extractModelID(body []byte) string {
 	// 尝试从JSON中提取model字段
 	result := gjson.GetBytes(body, "model")
@@ -137,3 +349,166 @@ func extractModelID(body []byte) string {
 	}
 	return ""
 }
+
+// isJSONRequest 判断请求体是否为JSON
+func isJSONRequest(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "text/plain" {
+		// 未声明或无法识别的Content-Type，根据内容判断
+		return gjson.ValidBytes(body)
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// extractModelIDFromSource 根据模型ID来源从请求中提取模型ID
+func extractModelIDFromSource(req *http.Request, body []byte, source config.ModelIDSource, key string) string {
+	return (&modelIDReader{req: req, body: body}).get(source, key)
+}
+
+// modelIDReader 从一个请求的各来源读取模型ID，表单只在首次读取表单字段时解析一次
+type modelIDReader struct {
+	req  *http.Request
+	body []byte
+	form url.Values // 表单中的非文件字段，未解析时为nil
+}
+
+// get 读取source中key对应的值
+func (r *modelIDReader) get(source config.ModelIDSource, key string) string {
+	switch source {
+	case config.ModelIDSourceQuery:
+		return r.req.URL.Query().Get(key)
+	case config.ModelIDSourceHeader:
+		return r.req.Header.Get(key)
+	case config.ModelIDSourceForm:
+		if r.form == nil {
+			r.form = parseFormValues(r.req.Header.Get("Content-Type"), r.body)
+		}
+		return r.form.Get(key)
+	default:
+		return gjson.GetBytes(r.body, key).String()
+	}
+}
+
+// extractFormValue 从urlencoded或multipart表单中读取字段值
+func extractFormValue(contentType string, body []byte, key string) string {
+	return parseFormValues(contentType, body).Get(key)
+}
+
+// parseFormValues 解析urlencoded或multipart表单中的非文件字段，无法解析的部分被忽略
+func parseFormValues(contentType string, body []byte) url.Values {
+	values := url.Values{}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return values
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if parsed, err := url.ParseQuery(string(body)); err == nil {
+			return parsed
+		}
+	case "multipart/form-data":
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return values
+			}
+			if part.FormName() == "" || part.FileName() != "" {
+				continue
+			}
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return values
+			}
+			values.Add(part.FormName(), string(value))
+		}
+	}
+	return values
+}
+
+// replaceFormValue 替换urlencoded表单中的字段值
+func replaceFormValue(body []byte, key, value string) ([]byte, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	values.Set(key, value)
+	return []byte(values.Encode()), nil
+}
+
+// replaceModelIDInSource 在非JSON请求中将模型ID替换为目标模型ID
+func replaceModelIDInSource(req *http.Request, body []byte, cfg *config.ModelConfig) ([]byte, error) {
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch cfg.ModelIDSource {
+	case config.ModelIDSourceHeader:
+		req.Header.Set(cfg.ModelIDKey, cfg.Target)
+	case config.ModelIDSourceForm:
+		switch mediaType {
+		case "application/x-www-form-urlencoded":
+			return replaceFormValue(body, cfg.ModelIDKey, cfg.Target)
+		case "multipart/form-data":
+			return replaceMultipartField(body, params["boundary"], cfg.ModelIDKey, cfg.Target)
+		}
+	default:
+		// 音频、图片模型的multipart请求默认使用model表单字段
+		if mediaType == "multipart/form-data" && supportsMultipart(cfg.Type) {
+			return replaceMultipartField(body, params["boundary"], "model", cfg.Target)
+		}
+	}
+	// 查询参数不会转发到上游，无需替换
+	return body, nil
+}
+
+// supportsMultipart 判断模型类型是否默认支持multipart请求
+func supportsMultipart(modelType config.ModelType) bool {
+	return modelType == config.ModelTypeAudio || modelType == config.ModelTypeImage
+}
+
+// multipartBoundary 获取multipart请求的boundary
+func multipartBoundary(contentType string) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", false
+	}
+	return params["boundary"], true
+}
+
+// replaceMultipartField 重新编码multipart请求体并替换指定字段的值，保留原始boundary
+func replaceMultipartField(body []byte, boundary, key, value string) ([]byte, error) {
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return nil, fmt.Errorf("设置multipart boundary失败: %w", err)
+	}
+
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析multipart请求体失败: %w", err)
+		}
+
+		dst, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return nil, fmt.Errorf("写入multipart字段失败: %w", err)
+		}
+		if part.FormName() == key && part.FileName() == "" {
+			_, err = io.WriteString(dst, value)
+		} else {
+			_, err = io.Copy(dst, part)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("写入multipart字段失败: %w", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("写入multipart请求体失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
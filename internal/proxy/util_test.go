@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestIsJSONRequest(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"application/json", `{"model":"a"}`, true},
+		{"application/json; charset=utf-8", `{"model":"a"}`, true},
+		{"", `{"model":"a"}`, true},
+		{"", "model=a", false},
+		{"application/x-www-form-urlencoded", "model=a", false},
+		{"multipart/form-data; boundary=xyz", "--xyz", false},
+	}
+	for _, tt := range tests {
+		if got := isJSONRequest(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("isJSONRequest(%q, %q) = %v, want %v", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestExtractModelIDFromSource(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("model", "whisper-custom")
+	part, _ := writer.CreateFormFile("file", "audio.mp3")
+	part.Write([]byte("fake audio"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/v1/audio/transcriptions?model=query-model", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-Model", "header-model")
+	body := buf.Bytes()
+
+	if got := extractModelIDFromSource(req, body, config.ModelIDSourceForm, "model"); got != "whisper-custom" {
+		t.Errorf("form source = %q", got)
+	}
+	if got := extractModelIDFromSource(req, body, config.ModelIDSourceQuery, "model"); got != "query-model" {
+		t.Errorf("query source = %q", got)
+	}
+	if got := extractModelIDFromSource(req, body, config.ModelIDSourceHeader, "X-Model"); got != "header-model" {
+		t.Errorf("header source = %q", got)
+	}
+}
+
+func TestLookupModelByModelIDSource(t *testing.T) {
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"a-query":  {ID: "a-query", ModelIDSource: config.ModelIDSourceQuery, ModelIDKey: "model"},
+		"b-header": {ID: "b-header", ModelIDSource: config.ModelIDSourceHeader, ModelIDKey: "X-Model"},
+		"c-form":   {ID: "c-form", ModelIDSource: config.ModelIDSourceForm, ModelIDKey: "model_name"},
+		"d-header": {ID: "d-header", ModelIDSource: config.ModelIDSourceHeader, ModelIDKey: "X-Model"},
+	}}
+	cfg.RebuildIndex()
+	if locations := cfg.ModelIDLocations(); len(locations) != 3 {
+		t.Errorf("相同的来源和键应只保留一个，实际%+v", locations)
+	}
+	s := NewServer(cfg, nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		header string
+		form   string
+		want   string
+	}{
+		{"查询参数", "a-query", "", "", "a-query"},
+		{"多个来源匹配时使用ID靠前的模型", "a-query", "d-header", "", "a-query"},
+		{"共用来源的模型", "", "d-header", "", "d-header"},
+		{"模型未配置该来源", "b-header", "", "", ""},
+		{"表单字段", "", "", "c-form", "c-form"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/v1/completions?model="+tt.query, strings.NewReader("model_name="+tt.form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.header != "" {
+			req.Header.Set("X-Model", tt.header)
+		}
+		got := ""
+		if _, model, ok := s.lookupModel(req, []byte("model_name="+tt.form), false); ok {
+			got = model.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: 匹配的模型 = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceMultipartField(t *testing.T) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("model", "whisper-custom")
+	part, _ := writer.CreateFormFile("file", "audio.mp3")
+	part.Write([]byte("fake audio"))
+	writer.WriteField("language", "zh")
+	writer.Close()
+
+	contentType := writer.FormDataContentType()
+	boundary, ok := multipartBoundary(contentType)
+	if !ok {
+		t.Fatalf("multipartBoundary(%q) failed", contentType)
+	}
+
+	result, err := replaceMultipartField(buf.Bytes(), boundary, "model", "whisper-1")
+	if err != nil {
+		t.Fatalf("replaceMultipartField failed: %v", err)
+	}
+
+	// 原始Content-Type中的boundary仍然可以解析新的请求体
+	if got := extractFormValue(contentType, result, "model"); got != "whisper-1" {
+		t.Errorf("model = %q, want whisper-1", got)
+	}
+	if got := extractFormValue(contentType, result, "language"); got != "zh" {
+		t.Errorf("language = %q, want zh", got)
+	}
+	if !bytes.Contains(result, []byte("fake audio")) {
+		t.Error("file content lost after re-encoding")
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+)
+
+// writePromptConfig 写入注入指定系统提示的模型配置文件
+func writePromptConfig(t *testing.T, dir, upstreamURL, prompt string) {
+	t.Helper()
+	content := `models:
+  - id: "watched"
+    name: "监听模型"
+    target: "gpt-4o"
+    url: "` + upstreamURL + `"
+    type: "chat"
+    prompt_path: "messages"
+    prompt_type: "object"
+    prompt_value:
+      role: "system"
+      content: "` + prompt + `"
+`
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+}
+
+func TestProxyServesPromptFromWatchedYAML(t *testing.T) {
+	var mutex sync.Mutex
+	var lastBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		lastBody = string(body)
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	writePromptConfig(t, dir, upstream.URL, "旧的提示")
+	configService, err := service.NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+
+	// 通过管理API创建的模型不应被配置文件的重新加载影响
+	apiModel := &config.ModelConfig{ID: "api-model", Name: "API模型", Target: "gpt-4o", Url: upstream.URL,
+		Type: config.ModelTypeChat, Source: config.ModelSourceAPI}
+	if err := configService.SaveModel(apiModel); err != nil {
+		t.Fatalf("保存模型失败: %v", err)
+	}
+
+	const interval = 20 * time.Millisecond
+	if err := configService.WatchConfigDir(dir, interval, interval); err != nil {
+		t.Fatalf("启动监听失败: %v", err)
+	}
+
+	s := NewServerWithService(configService, nil, config.DefaultServerConfig())
+	body := `{"model":"watched","messages":[{"role":"user","content":"你好"}]}`
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_body", body) })
+	r.Any("/*path", s.proxyHandler)
+
+	forwarded := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("期望返回200，实际得到%d: %s", w.Code, w.Body.String())
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		return lastBody
+	}
+	if got := forwarded(); !strings.Contains(got, "旧的提示") {
+		t.Fatalf("期望转发旧的提示，实际得到%s", got)
+	}
+
+	writePromptConfig(t, dir, upstream.URL, "新的提示")
+	deadline := time.Now().Add(50 * interval)
+	for !strings.Contains(forwarded(), "新的提示") {
+		if time.Now().After(deadline) {
+			t.Fatal("修改配置文件后代理未在轮询周期内使用新的提示")
+		}
+		time.Sleep(interval)
+	}
+
+	if model, ok := configService.GetModel("api-model"); !ok || model.Source != config.ModelSourceAPI {
+		t.Errorf("通过管理API创建的模型应保留，实际得到%+v", model)
+	}
+	if model, _ := configService.GetModel("watched"); model.Source != config.ModelSourceYAML {
+		t.Errorf("期望配置文件中的模型来源为yaml，实际得到%q", model.Source)
+	}
+}
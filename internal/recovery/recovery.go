@@ -0,0 +1,92 @@
+// Package recovery 提供代理和管理服务共用的panic恢复中间件
+package recovery
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+// maxStackBytes 访问日志中记录的调用栈最大字节数
+const maxStackBytes = 4096
+
+// panics 进程启动以来两个服务恢复的panic总数
+var panics atomic.Int64
+
+// Count 返回进程启动以来恢复的panic总数
+func Count() int64 {
+	return panics.Load()
+}
+
+// Respond 写入panic请求的500响应，requestID为请求ID
+type Respond func(c *gin.Context, requestID string)
+
+// Middleware 恢复处理请求时发生的panic：计数，在访问日志中记录$error为"panic: ..."和截断的调用栈
+// ($panic_stack)，并由respond返回带请求ID的500响应。需要注册在访问日志中间件之后，
+// 使访问日志中间件正常结束并记录该请求；http.ErrAbortHandler按net/http的约定继续抛出
+func Middleware(respond Respond) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if err, ok := value.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(value)
+			}
+			panics.Add(1)
+
+			message := fmt.Sprintf("panic: %v", value)
+			stack := debug.Stack()
+			fmt.Fprintf(os.Stderr, "[Recovery] %s %s %s\n%s", c.Request.Method, c.Request.URL.Path, message, stack)
+
+			requestID := c.GetString("request_id")
+			if requestID == "" {
+				requestID = newRequestID()
+				c.Set("request_id", requestID)
+			}
+			c.Set("error", message)
+			setStack(c, stack)
+
+			// 已开始写入的响应（如流式转发中途）无法再修改状态码，只中止请求
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			respond(c, requestID)
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+// setStack 将截断后的调用栈写入访问日志扩展字段
+func setStack(c *gin.Context, stack []byte) {
+	if len(stack) > maxStackBytes {
+		stack = append(stack[:maxStackBytes:maxStackBytes], "...[truncated]"...)
+	}
+	extra, ok := c.Get("log_extra")
+	if !ok {
+		extra = make(map[string]interface{})
+		c.Set("log_extra", extra)
+	}
+	extra.(map[string]interface{})[logger.ExtraPanicStack] = string(stack)
+}
+
+// newRequestID 为没有请求ID的请求（如管理API）生成请求ID
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(bytes)
+}
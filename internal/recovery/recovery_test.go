@@ -0,0 +1,69 @@
+package recovery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/logger"
+)
+
+func TestMiddlewareRecoversPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logged struct {
+		err   string
+		extra map[string]interface{}
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		// 模拟访问日志中间件：请求处理结束后读取日志数据
+		c.Set("request_id", "req-1")
+		c.Next()
+		logged.err = c.GetString("error")
+		logged.extra = c.MustGet("log_extra").(map[string]interface{})
+	})
+	r.Use(Middleware(func(c *gin.Context, requestID string) {
+		c.JSON(http.StatusInternalServerError, gin.H{"request_id": requestID})
+	}))
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	before := Count()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError || w.Body.String() != `{"request_id":"req-1"}` {
+		t.Errorf("响应 = %d %s", w.Code, w.Body.String())
+	}
+	if logged.err != "panic: boom" {
+		t.Errorf("$error = %q, want panic: boom", logged.err)
+	}
+	stack, _ := logged.extra[logger.ExtraPanicStack].(string)
+	if !strings.Contains(stack, "recovery_test.go") || len(stack) > maxStackBytes+len("...[truncated]") {
+		t.Errorf("应记录截断后的调用栈，实际%d字节", len(stack))
+	}
+	if Count() != before+1 {
+		t.Errorf("panic计数 = %d, want %d", Count(), before+1)
+	}
+}
+
+func TestMiddlewareGeneratesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	var got string
+	r.Use(Middleware(func(c *gin.Context, requestID string) {
+		got = requestID
+		c.Status(http.StatusInternalServerError)
+	}))
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if len(got) != 32 {
+		t.Errorf("没有请求ID时应生成请求ID，实际为%q", got)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/db"
@@ -20,6 +23,13 @@ type AuthService struct {
 	dbManager  *db.Manager
 	jwtSecret  []byte
 	rsaPrivKey *rsa.PrivateKey
+
+	tokenTTL      time.Duration // 新签发token的有效期
+	tokenTTLMutex sync.RWMutex
+
+	maxFailedLogins int           // 连续登录失败多少次后锁定账户，0表示不锁定
+	lockoutDuration time.Duration // 账户锁定时长
+	lockoutMutex    sync.RWMutex
 }
 
 // Claims JWT声明
@@ -27,6 +37,13 @@ type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	IsAdmin  bool   `json:"is_admin"`
+	// Role 签发时用户的角色，升级前签发的token没有角色，按IsAdmin确定
+	Role db.Role `json:"role,omitempty"`
+	// MustChangePassword 签发时用户使用的是临时密码，管理API在用户修改密码前只允许修改密码等操作
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	// TenantID 签发时用户所属的租户，Global为true时可以管理所有租户，见Scope
+	TenantID string `json:"tenant_id,omitempty"`
+	Global   bool   `json:"global,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -64,6 +81,9 @@ type LoginResponse struct {
 	Token     string   `json:"token"`
 	User      *db.User `json:"user"`
 	ExpiresAt int64    `json:"expires_at"`
+	ExpiresIn int64    `json:"expires_in"` // token有效期（秒）
+	// MustChangePassword 用户使用的是管理员设置的临时密码，前端应要求先修改密码
+	MustChangePassword bool `json:"must_change_password"`
 }
 
 // NewAuthService 创建认证服务
@@ -84,6 +104,10 @@ func NewAuthService(dbManager *db.Manager) (*AuthService, error) {
 		dbManager:  dbManager,
 		jwtSecret:  secret,
 		rsaPrivKey: rsaPrivKey,
+		tokenTTL:   loadTokenTTL(dbManager),
+
+		maxFailedLogins: DefaultMaxFailedLogins,
+		lockoutDuration: DefaultLockoutDuration,
 	}, nil
 }
 
@@ -123,16 +147,26 @@ func (s *AuthService) CheckPassword(hashedPassword, password string) bool {
 	return err == nil
 }
 
-// GenerateToken 生成JWT token
+// GenerateToken 生成JWT token，并以token的jti记录登录会话
 func (s *AuthService) GenerateToken(user *db.User) (string, int64, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // 24小时过期
+	now := time.Now()
+	expirationTime := now.Add(s.TokenTTL())
+	jti, err := newSessionID()
+	if err != nil {
+		return "", 0, err
+	}
 	claims := &Claims{
-		UserID:   user.ID,
-		Username: user.Username,
-		IsAdmin:  user.IsAdmin,
+		UserID:             user.ID,
+		Username:           user.Username,
+		IsAdmin:            user.IsAdmin,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+		TenantID:           user.TenantID,
+		Global:             user.Global,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
@@ -141,6 +175,10 @@ func (s *AuthService) GenerateToken(user *db.User) (string, int64, error) {
 	if err != nil {
 		return "", 0, fmt.Errorf("生成token失败: %w", err)
 	}
+	session := &db.Session{JTI: jti, UserID: user.ID, IssuedAt: now, ExpiresAt: expirationTime}
+	if err := s.dbManager.CreateSession(session); err != nil {
+		return "", 0, err
+	}
 
 	return tokenString, expirationTime.Unix(), nil
 }
@@ -162,6 +200,15 @@ func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 	if !token.Valid {
 		return nil, fmt.Errorf("token无效")
 	}
+	if claims.Role == "" {
+		claims.Role = db.RoleFromAdmin(claims.IsAdmin)
+	}
+	if err := s.checkSession(claims); err != nil {
+		return nil, err
+	}
+	if err := s.refreshClaims(claims); err != nil {
+		return nil, err
+	}
 
 	return claims, nil
 }
@@ -171,17 +218,31 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 	// 获取用户
 	user, err := s.dbManager.GetUserByUsername(req.Username)
 	if err != nil {
-		return nil, fmt.Errorf("用户名或密码错误")
+		return nil, ErrInvalidCredentials
 	}
 
 	// 检查用户是否被禁用
 	if !user.IsEnabled {
-		return nil, fmt.Errorf("用户已被禁用")
+		return nil, ErrUserDisabled
+	}
+
+	// 锁定期内即使密码正确也拒绝登录
+	if err := checkLocked(user, time.Now()); err != nil {
+		return nil, err
 	}
 
 	// 验证密码
 	if !s.CheckPassword(user.Password, req.Password) {
-		return nil, fmt.Errorf("用户名或密码错误")
+		return nil, s.recordFailedLogin(user)
+	}
+
+	// 登录成功后清零失败次数和已过期的锁定
+	if user.FailedLoginCount > 0 || user.LockedUntil != nil {
+		if err := s.dbManager.UnlockUser(user.ID); err != nil {
+			fmt.Printf("清零用户登录失败次数失败: %v\n", err)
+		}
+		user.FailedLoginCount = 0
+		user.LockedUntil = nil
 	}
 
 	// 更新最后登录时间
@@ -197,9 +258,11 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 	}
 
 	return &LoginResponse{
-		Token:     token,
-		User:      user,
-		ExpiresAt: expiresAt,
+		Token:              token,
+		User:               user,
+		ExpiresAt:          expiresAt,
+		ExpiresIn:          expiresAt - time.Now().Unix(),
+		MustChangePassword: user.MustChangePassword,
 	}, nil
 }
 
@@ -213,7 +276,7 @@ func (s *AuthService) Register(req *RegisterRequest) (*LoginResponse, error) {
 
 	// 如果已有用户，不允许注册
 	if count > 0 {
-		return nil, fmt.Errorf("系统已初始化，不允许注册新用户")
+		return nil, ErrAlreadyInstalled
 	}
 
 	// 加密密码
@@ -226,7 +289,8 @@ func (s *AuthService) Register(req *RegisterRequest) (*LoginResponse, error) {
 	user := &db.User{
 		Username: req.Username,
 		Password: hashedPassword,
-		IsAdmin:  true, // 第一个用户自动设为管理员
+		Role:     db.RoleSuperuser, // 第一个用户自动设为管理员
+		Global:   true,             // 并且可以管理所有租户
 	}
 
 	if err := s.dbManager.CreateUser(user); err != nil {
@@ -243,6 +307,7 @@ func (s *AuthService) Register(req *RegisterRequest) (*LoginResponse, error) {
 		Token:     token,
 		User:      user,
 		ExpiresAt: expiresAt,
+		ExpiresIn: expiresAt - time.Now().Unix(),
 	}, nil
 }
 
@@ -333,21 +398,29 @@ func (s *AuthService) GetUserByID(id uint) (*db.User, error) {
 
 // CreateUserRequest 创建用户请求
 type CreateUserRequest struct {
-	Username string `json:"username" binding:"required"`
-	IsAdmin  bool   `json:"is_admin"`
+	Username      string  `json:"username" binding:"required"`
+	Role          db.Role `json:"role"`            // 用户角色，为空时按is_admin确定
+	IsAdmin       bool    `json:"is_admin"`        // 兼容旧版本，is_admin为true等同于角色superuser
+	AutoCreateKey bool    `json:"auto_create_key"` // 同时为用户创建第一个API Key
+	TenantID      string  `json:"tenant_id"`       // 所属租户，为空时与创建者相同（创建者为全局管理员时为默认租户）
+	Global        bool    `json:"global"`          // 是否为全局管理员，只有全局管理员可以设置
 }
 
 // CreateUserResponse 创建用户响应
 type CreateUserResponse struct {
-	User              *db.User `json:"user"`
-	GeneratedPassword string   `json:"generated_password"`
+	User              *db.User   `json:"user"`
+	GeneratedPassword string     `json:"generated_password"` // 临时密码，只在创建时返回一次，用户首次登录后需修改
+	APIKey            *db.APIKey `json:"api_key,omitempty"`  // 开启auto_create_key时自动创建的API Key
 }
 
 // UpdateUserRequest 更新用户请求
 type UpdateUserRequest struct {
-	Username  string `json:"username"`
-	IsAdmin   *bool  `json:"is_admin"`
-	IsEnabled *bool  `json:"is_enabled"`
+	Username  string   `json:"username"`
+	Role      *db.Role `json:"role"`
+	IsAdmin   *bool    `json:"is_admin"` // 兼容旧版本，true设为superuser，false时将superuser降为viewer；同时指定role时以role为准
+	IsEnabled *bool    `json:"is_enabled"`
+	TenantID  *string  `json:"tenant_id"` // 修改所属租户，用户的API Key随之转到新租户；只有全局管理员可以修改
+	Global    *bool    `json:"global"`    // 是否为全局管理员，只有全局管理员可以修改
 }
 
 // ChangePasswordRequest 修改密码请求
@@ -363,20 +436,28 @@ type AdminChangePasswordRequest struct {
 
 // UserListResponse 用户列表响应
 type UserListResponse struct {
-	Users []UserInfo `json:"users"`
-	Total int        `json:"total"`
+	Users    []UserInfo `json:"users"`
+	Total    int64      `json:"total"`     // 符合条件的用户总数
+	Page     int        `json:"page"`      // 未分页时为0
+	PageSize int        `json:"page_size"` // 未分页时为0
 }
 
 // UserInfo 用户信息（不包含密码）
 type UserInfo struct {
-	ID          uint       `json:"id"`
-	Username    string     `json:"username"`
-	IsAdmin     bool       `json:"is_admin"`
-	IsEnabled   bool       `json:"is_enabled"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	LastLoginAt *time.Time `json:"last_login_at"`
-	CreatedBy   uint       `json:"created_by"`
+	ID                 uint       `json:"id"`
+	Username           string     `json:"username"`
+	Role               db.Role    `json:"role"`
+	IsAdmin            bool       `json:"is_admin"`
+	IsEnabled          bool       `json:"is_enabled"`
+	MustChangePassword bool       `json:"must_change_password"` // 是否仍在使用临时密码
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	LastLoginAt        *time.Time `json:"last_login_at"`
+	FailedLoginCount   int        `json:"failed_login_count"` // 连续登录失败次数
+	LockedUntil        *time.Time `json:"locked_until"`       // 账户锁定到期时间，未锁定时为null
+	CreatedBy          uint       `json:"created_by"`
+	TenantID           string     `json:"tenant_id"` // 所属租户
+	Global             bool       `json:"global"`    // 是否为全局管理员
 }
 
 // 用户管理相关方法
@@ -396,12 +477,41 @@ func (s *AuthService) GenerateRandomPassword() string {
 	return string(password)
 }
 
-// CreateUser 创建用户（管理员功能）
-func (s *AuthService) CreateUser(req *CreateUserRequest, creatorID uint) (*CreateUserResponse, error) {
+// DefaultAPIKeyName 创建用户时自动创建的API Key名称
+const DefaultAPIKeyName = "default"
+
+// GenerateAPIKeyValue 生成随机的API Key值
+func GenerateAPIKeyValue() string {
+	// 生成32字节的随机数据
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		// 如果随机数生成失败，使用时间戳作为后备方案
+		return fmt.Sprintf("ak_%d", time.Now().UnixNano())
+	}
+	// 转换为十六进制字符串并添加前缀
+	return "ak_" + hex.EncodeToString(bytes)
+}
+
+// CreateUser 创建用户（管理员功能），非全局管理员只能在自己的租户中创建非全局用户
+func (s *AuthService) CreateUser(req *CreateUserRequest, creatorID uint, scope TenantScope) (*CreateUserResponse, error) {
+	role := req.Role
+	if role == "" {
+		role = db.RoleFromAdmin(req.IsAdmin)
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRole, role)
+	}
+	if req.Global && !scope.IsGlobal() {
+		return nil, ErrTenantForbidden
+	}
+	tenantID, err := resolveTenant(s.dbManager, scope, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 检查用户名是否已存在
-	_, err := s.dbManager.GetUserByUsername(req.Username)
-	if err == nil {
-		return nil, fmt.Errorf("用户名已存在")
+	if _, err := s.dbManager.GetUserByUsername(req.Username); err == nil {
+		return nil, ErrUserExists
 	}
 
 	// 生成随机密码
@@ -411,60 +521,96 @@ func (s *AuthService) CreateUser(req *CreateUserRequest, creatorID uint) (*Creat
 		return nil, fmt.Errorf("密码加密失败: %w", err)
 	}
 
-	// 创建用户
+	// 创建用户，随机生成的密码只是临时密码，用户首次登录后需修改
 	user := &db.User{
-		Username:  req.Username,
-		Password:  hashedPassword,
-		IsAdmin:   req.IsAdmin,
-		IsEnabled: true,
-		CreatedBy: creatorID,
-	}
-
-	if err := s.dbManager.CreateUser(user); err != nil {
-		return nil, fmt.Errorf("创建用户失败: %w", err)
+		Username:           req.Username,
+		Password:           hashedPassword,
+		Role:               role,
+		IsEnabled:          true,
+		MustChangePassword: true,
+		CreatedBy:          creatorID,
+		TenantID:           tenantID,
+		Global:             req.Global,
 	}
 
-	return &CreateUserResponse{
+	response := &CreateUserResponse{
 		User:              user,
 		GeneratedPassword: password,
-	}, nil
+	}
+	if !req.AutoCreateKey {
+		if err := s.dbManager.CreateUser(user); err != nil {
+			return nil, fmt.Errorf("创建用户失败: %w", err)
+		}
+		return response, nil
+	}
+
+	response.APIKey = &db.APIKey{
+		Name:      DefaultAPIKeyName,
+		KeyValue:  GenerateAPIKeyValue(),
+		IsEnabled: true,
+	}
+	if err := s.dbManager.CreateUserWithAPIKey(user, response.APIKey); err != nil {
+		return nil, err
+	}
+	return response, nil
 }
 
-// GetAllUsers 获取所有用户列表（不包括管理员账号）
-func (s *AuthService) GetAllUsers() (*UserListResponse, error) {
-	users, err := s.dbManager.GetAllUsers()
+// SearchUsers 按用户名搜索并分页获取范围内的用户列表，keyword为空时返回全部用户，
+// include_admins为false时不包括管理员账号
+func (s *AuthService) SearchUsers(scope TenantScope, keyword string, q db.UserQuery) (*UserListResponse, error) {
+	q.Tenant = scope.Filter()
+	users, total, err := s.dbManager.SearchUsers(keyword, q)
 	if err != nil {
-		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+		return nil, err
 	}
 
-	// 过滤掉管理员账号，只返回普通用户
-	var userInfos []UserInfo
+	userInfos := make([]UserInfo, 0, len(users))
 	for _, user := range users {
-		if !user.IsAdmin {
-			userInfos = append(userInfos, UserInfo{
-				ID:          user.ID,
-				Username:    user.Username,
-				IsAdmin:     user.IsAdmin,
-				IsEnabled:   user.IsEnabled,
-				CreatedAt:   user.CreatedAt,
-				UpdatedAt:   user.UpdatedAt,
-				LastLoginAt: user.LastLoginAt,
-				CreatedBy:   user.CreatedBy,
-			})
-		}
+		userInfos = append(userInfos, UserInfo{
+			ID:                 user.ID,
+			Username:           user.Username,
+			Role:               user.Role,
+			IsAdmin:            user.IsAdmin,
+			IsEnabled:          user.IsEnabled,
+			MustChangePassword: user.MustChangePassword,
+			CreatedAt:          user.CreatedAt,
+			UpdatedAt:          user.UpdatedAt,
+			LastLoginAt:        user.LastLoginAt,
+			FailedLoginCount:   user.FailedLoginCount,
+			LockedUntil:        user.LockedUntil,
+			CreatedBy:          user.CreatedBy,
+			TenantID:           user.TenantID,
+			Global:             user.Global,
+		})
 	}
 
 	return &UserListResponse{
-		Users: userInfos,
-		Total: len(userInfos),
+		Users:    userInfos,
+		Total:    total,
+		Page:     q.Page,
+		PageSize: q.PageSize,
 	}, nil
 }
 
-// UpdateUser 更新用户信息
-func (s *AuthService) UpdateUser(userID uint, req *UpdateUserRequest) error {
-	user, err := s.dbManager.GetUserByID(userID)
+// UpdateUser 更新范围内用户的信息，修改所属租户和全局管理员需要全局范围
+func (s *AuthService) UpdateUser(scope TenantScope, userID uint, req *UpdateUserRequest) error {
+	user, err := s.UserInScope(scope, userID)
 	if err != nil {
-		return fmt.Errorf("用户不存在")
+		return err
+	}
+	tenantID, global := user.TenantID, user.Global
+	if req.TenantID != nil || req.Global != nil {
+		if !scope.IsGlobal() {
+			return ErrTenantForbidden
+		}
+		if req.TenantID != nil {
+			if tenantID, err = resolveTenant(s.dbManager, scope, *req.TenantID); err != nil {
+				return err
+			}
+		}
+		if req.Global != nil {
+			global = *req.Global
+		}
 	}
 
 	// 更新用户名
@@ -472,14 +618,22 @@ func (s *AuthService) UpdateUser(userID uint, req *UpdateUserRequest) error {
 		// 检查新用户名是否已存在
 		_, err := s.dbManager.GetUserByUsername(req.Username)
 		if err == nil {
-			return fmt.Errorf("用户名已存在")
+			return ErrUserExists
 		}
 		user.Username = req.Username
 	}
 
-	// 更新管理员状态
-	if req.IsAdmin != nil {
-		user.IsAdmin = *req.IsAdmin
+	// 更新角色，兼容只传is_admin的旧版本请求
+	switch {
+	case req.Role != nil:
+		if !req.Role.Valid() {
+			return fmt.Errorf("%w: %s", ErrInvalidRole, *req.Role)
+		}
+		user.SetRole(*req.Role)
+	case req.IsAdmin != nil && *req.IsAdmin:
+		user.SetRole(db.RoleSuperuser)
+	case req.IsAdmin != nil && user.Role == db.RoleSuperuser:
+		user.SetRole(db.RoleFromAdmin(false))
 	}
 
 	// 更新启用状态
@@ -487,30 +641,36 @@ func (s *AuthService) UpdateUser(userID uint, req *UpdateUserRequest) error {
 		user.IsEnabled = *req.IsEnabled
 	}
 
-	return s.dbManager.UpdateUser(user)
+	if err := s.dbManager.UpdateUser(user); err != nil {
+		return err
+	}
+	if tenantID != user.TenantID || global != user.Global {
+		return s.dbManager.UpdateUserTenant(user.ID, tenantID, global)
+	}
+	return nil
 }
 
-// DeleteUser 删除用户
-func (s *AuthService) DeleteUser(userID uint) error {
+// DeleteUser 删除用户，用户仍有API Key时需要指定cascade同时删除，否则返回db.ErrUserHasAPIKeys
+func (s *AuthService) DeleteUser(userID uint, cascade bool) error {
 	// 检查用户是否存在
 	_, err := s.dbManager.GetUserByID(userID)
 	if err != nil {
-		return fmt.Errorf("用户不存在")
+		return ErrUserNotFound
 	}
 
-	return s.dbManager.DeleteUser(userID)
+	return s.dbManager.DeleteUser(userID, cascade)
 }
 
-// ChangePassword 用户修改自己的密码
+// ChangePassword 用户修改自己的密码，修改后不再要求更换临时密码
 func (s *AuthService) ChangePassword(userID uint, req *ChangePasswordRequest) error {
 	user, err := s.dbManager.GetUserByID(userID)
 	if err != nil {
-		return fmt.Errorf("用户不存在")
+		return ErrUserNotFound
 	}
 
 	// 验证旧密码
 	if !s.CheckPassword(user.Password, req.OldPassword) {
-		return fmt.Errorf("旧密码错误")
+		return ErrWrongOldPassword
 	}
 
 	// 加密新密码
@@ -519,15 +679,15 @@ func (s *AuthService) ChangePassword(userID uint, req *ChangePasswordRequest) er
 		return fmt.Errorf("密码加密失败: %w", err)
 	}
 
-	return s.dbManager.UpdateUserPassword(userID, hashedPassword)
+	return s.dbManager.UpdateUserPassword(userID, hashedPassword, false)
 }
 
-// AdminChangePassword 管理员修改用户密码
+// AdminChangePassword 管理员重置用户密码，重置后的密码视为临时密码，用户登录后需修改
 func (s *AuthService) AdminChangePassword(userID uint, req *AdminChangePasswordRequest) error {
 	// 检查用户是否存在
 	_, err := s.dbManager.GetUserByID(userID)
 	if err != nil {
-		return fmt.Errorf("用户不存在")
+		return ErrUserNotFound
 	}
 
 	// 加密新密码
@@ -536,7 +696,7 @@ func (s *AuthService) AdminChangePassword(userID uint, req *AdminChangePasswordR
 		return fmt.Errorf("密码加密失败: %w", err)
 	}
 
-	return s.dbManager.UpdateUserPassword(userID, hashedPassword)
+	return s.dbManager.UpdateUserPassword(userID, hashedPassword, true)
 }
 
 // UpdateUserStatus 更新用户状态
@@ -546,13 +706,65 @@ func (s *AuthService) UpdateUserStatus(userID uint, isEnabled bool) error {
 
 // API Key 管理相关方法
 
-// GetAPIKeysByUserID 获取用户的API Key列表
-func (s *AuthService) GetAPIKeysByUserID(userID uint) ([]db.APIKey, error) {
-	return s.dbManager.GetAPIKeysByUserID(userID)
+// GetAPIKeysByUserID 获取用户的API Key列表，selector不为空时只返回包含这些标签的Key
+func (s *AuthService) GetAPIKeysByUserID(userID uint, selector map[string]string) ([]db.APIKey, error) {
+	apiKeys, err := s.dbManager.GetAPIKeysByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return filterAPIKeysByLabels(apiKeys, selector), nil
+}
+
+// GetExpiringAPIKeys 获取用户在within时长内过期的API Key，已过期的Key不包含在内
+func (s *AuthService) GetExpiringAPIKeys(userID uint, within time.Duration) ([]db.APIKey, error) {
+	now := time.Now()
+	return s.dbManager.GetExpiringAPIKeys(userID, now, now.Add(within))
 }
 
-// CreateAPIKey 创建API Key
-func (s *AuthService) CreateAPIKey(userID uint, name, keyValue, expiresAt string) (*db.APIKey, error) {
+// GetAllAPIKeys 获取范围内所有用户的API Key列表，selector不为空时只返回包含这些标签的Key
+func (s *AuthService) GetAllAPIKeys(scope TenantScope, selector map[string]string) ([]db.APIKey, error) {
+	apiKeys, err := s.dbManager.GetAllAPIKeys(scope.Filter())
+	if err != nil {
+		return nil, err
+	}
+	return filterAPIKeysByLabels(apiKeys, selector), nil
+}
+
+// UpdateAPIKeyRequest 更新API Key请求
+type UpdateAPIKeyRequest struct {
+	Name          string             `json:"name"`
+	IsEnabled     *bool              `json:"is_enabled"`
+	AllowedModels *[]string          `json:"allowed_models"` // 允许调用的模型ID，空数组表示不限制
+	Labels        *map[string]string `json:"labels"`         // 标签，传入时整体替换
+
+	AllowPromptOverride *bool `json:"allow_prompt_override"` // 是否允许通过请求头跳过或追加Prompt，只有管理员可以修改
+}
+
+// normalizeModelList 去除空白和重复的模型ID
+func normalizeModelList(models []string) []string {
+	seen := make(map[string]bool, len(models))
+	var result []string
+	for _, model := range models {
+		model = strings.TrimSpace(model)
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		result = append(result, model)
+	}
+	return result
+}
+
+// CreateAPIKey 创建API Key，Key属于所属用户的租户，allowedModels为空时不限制可调用的模型
+func (s *AuthService) CreateAPIKey(userID uint, name, keyValue, expiresAt string, allowedModels []string, labels map[string]string) (*db.APIKey, error) {
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	owner, err := s.dbManager.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+
 	// 解析过期时间
 	var expiresAtTime *time.Time
 	if expiresAt != "" {
@@ -565,21 +777,88 @@ func (s *AuthService) CreateAPIKey(userID uint, name, keyValue, expiresAt string
 
 	// 创建API Key
 	apiKey := &db.APIKey{
-		UserID:    userID,
-		Name:      name,
-		KeyValue:  keyValue,
-		IsEnabled: true,
-		ExpiresAt: expiresAtTime,
+		UserID:        userID,
+		Name:          name,
+		KeyValue:      keyValue,
+		IsEnabled:     true,
+		ExpiresAt:     expiresAtTime,
+		AllowedModels: normalizeModelList(allowedModels),
+		Labels:        labels,
+		TenantID:      owner.TenantID,
 	}
 
-	err := s.dbManager.CreateAPIKey(apiKey)
-	if err != nil {
+	if err := s.dbManager.CreateAPIKey(apiKey); err != nil {
 		return nil, fmt.Errorf("创建API Key失败: %w", err)
 	}
 
 	return apiKey, nil
 }
 
+// UpdateAPIKey 更新用户自己的API Key
+func (s *AuthService) UpdateAPIKey(apiKeyID, userID uint, req *UpdateAPIKeyRequest) (*db.APIKey, error) {
+	apiKey, err := s.dbManager.GetAPIKeyByID(apiKeyID)
+	if err != nil || apiKey.UserID != userID {
+		return nil, fmt.Errorf("%w: %d", ErrAPIKeyNotFound, apiKeyID)
+	}
+	return s.applyAPIKeyUpdate(apiKey, req)
+}
+
+// UpdateAPIKeyInScope 更新范围内任意用户的API Key（管理员功能），如为其他用户的Key授予覆盖Prompt的权限
+func (s *AuthService) UpdateAPIKeyInScope(scope TenantScope, apiKeyID uint, req *UpdateAPIKeyRequest) (*db.APIKey, error) {
+	apiKey, err := s.APIKeyInScope(scope, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyAPIKeyUpdate(apiKey, req)
+}
+
+// applyAPIKeyUpdate 将请求中设置的字段应用到API Key并保存
+func (s *AuthService) applyAPIKeyUpdate(apiKey *db.APIKey, req *UpdateAPIKeyRequest) (*db.APIKey, error) {
+	if req.Name != "" {
+		apiKey.Name = req.Name
+	}
+	if req.IsEnabled != nil {
+		apiKey.IsEnabled = *req.IsEnabled
+	}
+	if req.AllowedModels != nil {
+		apiKey.AllowedModels = normalizeModelList(*req.AllowedModels)
+	}
+	if req.Labels != nil {
+		if err := ValidateLabels(*req.Labels); err != nil {
+			return nil, err
+		}
+		apiKey.Labels = *req.Labels
+	}
+	if req.AllowPromptOverride != nil {
+		apiKey.AllowPromptOverride = *req.AllowPromptOverride
+	}
+
+	if err := s.dbManager.UpdateAPIKey(apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// RotateAPIKey 将API Key的值替换为keyValue，名称、启用状态、允许的模型和标签保持不变；
+// 代理每次请求都按值查询数据库，旧值在更新后立即失效
+func (s *AuthService) RotateAPIKey(apiKeyID, userID uint, keyValue string) (*db.APIKey, error) {
+	apiKey, err := s.dbManager.GetAPIKeyByID(apiKeyID)
+	if err != nil || apiKey.UserID != userID {
+		return nil, fmt.Errorf("%w: %d", ErrAPIKeyNotFound, apiKeyID)
+	}
+
+	apiKey.KeyValue = keyValue
+	if err := s.dbManager.UpdateAPIKey(apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// GetAPIKeysByAllowedModel 获取允许列表中包含指定模型的API Key
+func (s *AuthService) GetAPIKeysByAllowedModel(modelID string) ([]db.APIKey, error) {
+	return s.dbManager.GetAPIKeysByAllowedModel(modelID)
+}
+
 // DeleteAPIKey 删除API Key
 func (s *AuthService) DeleteAPIKey(apiKeyID, userID uint) error {
 	return s.dbManager.DeleteAPIKey(apiKeyID, userID)
@@ -590,7 +869,33 @@ func (s *AuthService) GetAPIKeyByValue(keyValue string) (*db.APIKey, error) {
 	return s.dbManager.GetAPIKeyByValue(keyValue)
 }
 
-// UpdateAPIKeyLastUsed 更新API Key最后使用时间
-func (s *AuthService) UpdateAPIKeyLastUsed(keyValue string) error {
-	return s.dbManager.UpdateAPIKeyLastUsed(keyValue)
+// TransferAPIKey 将范围内的API Key转移给范围内的另一个用户（管理员功能），Key随之转到目标用户的租户，
+// Key值、过期时间和使用记录保持不变
+func (s *AuthService) TransferAPIKey(scope TenantScope, apiKeyID, toUserID uint) (*db.APIKey, error) {
+	apiKey, err := s.APIKeyInScope(scope, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := s.UserInScope(scope, toUserID)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.UserID == toUserID {
+		return nil, fmt.Errorf("API Key已属于用户: %d", toUserID)
+	}
+
+	if err := s.dbManager.TransferAPIKey(apiKeyID, toUserID, owner.TenantID); err != nil {
+		return nil, err
+	}
+	return s.dbManager.GetAPIKeyByID(apiKeyID)
+}
+
+// GetAPIKeyByID 根据ID获取API Key（包括已禁用的Key）
+func (s *AuthService) GetAPIKeyByID(apiKeyID uint) (*db.APIKey, error) {
+	return s.dbManager.GetAPIKeyByID(apiKeyID)
+}
+
+// UpdateAPIKeyLastUsed 更新API Key最后使用时间和客户端IP
+func (s *AuthService) UpdateAPIKeyLastUsed(keyValue, clientIP string) error {
+	return s.dbManager.UpdateAPIKeyLastUsed(keyValue, clientIP)
 }
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+func TestRotateAPIKey(t *testing.T) {
+	s := newTestAuthService(t)
+	owner, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+
+	created, err := s.CreateAPIKey(owner.User.ID, "search-prod", "sk-old", "", []string{"chat-a"}, map[string]string{"team": "search"})
+	if err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+
+	if _, err := s.RotateAPIKey(created.ID, owner.User.ID+1, "sk-stolen"); err == nil {
+		t.Error("期望其他用户不能重新生成API Key")
+	}
+
+	rotated, err := s.RotateAPIKey(created.ID, owner.User.ID, "sk-new")
+	if err != nil {
+		t.Fatalf("重新生成API Key失败: %v", err)
+	}
+	if rotated.ID != created.ID || rotated.Name != created.Name || !rotated.IsEnabled {
+		t.Errorf("期望保留原有记录，实际得到%+v", rotated)
+	}
+	if !reflect.DeepEqual([]string(rotated.AllowedModels), []string{"chat-a"}) || rotated.Labels["team"] != "search" {
+		t.Errorf("期望保留允许的模型和标签，实际得到%+v", rotated)
+	}
+
+	if _, err := s.GetAPIKeyByValue("sk-old"); err == nil {
+		t.Error("旧的Key值应立即失效")
+	}
+	if apiKey, err := s.GetAPIKeyByValue("sk-new"); err != nil || apiKey.ID != created.ID {
+		t.Errorf("新的Key值应能查到原记录，实际得到%+v, %v", apiKey, err)
+	}
+}
+
+func TestTokenTTL(t *testing.T) {
+	s := newTestAuthService(t)
+	if s.TokenTTL() != DefaultTokenTTL {
+		t.Fatalf("期望默认token有效期为%s，实际为%s", DefaultTokenTTL, s.TokenTTL())
+	}
+	login, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+	if login.ExpiresIn < int64(DefaultTokenTTL/time.Second)-1 {
+		t.Errorf("期望登录响应的expires_in接近默认有效期，实际为%d", login.ExpiresIn)
+	}
+
+	for _, ttl := range []time.Duration{time.Minute, MaxTokenTTL + time.Hour, time.Hour + time.Millisecond} {
+		if err := s.SetTokenTTL(ttl); err == nil {
+			t.Errorf("期望拒绝token有效期%s", ttl)
+		}
+	}
+
+	if err := s.SetTokenTTL(time.Hour); err != nil {
+		t.Fatalf("修改token有效期失败: %v", err)
+	}
+	relogin, err := s.Login(&LoginRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if relogin.ExpiresAt > time.Now().Add(time.Hour).Unix() || relogin.ExpiresIn > int64(time.Hour/time.Second) {
+		t.Errorf("期望新token按1小时过期，实际得到%+v", relogin)
+	}
+
+	// 修改有效期前签发的token按原过期时间继续有效
+	claims, err := s.ValidateToken(login.Token)
+	if err != nil {
+		t.Fatalf("修改有效期前签发的token应仍然有效: %v", err)
+	}
+	if claims.ExpiresAt.Unix() != login.ExpiresAt {
+		t.Errorf("期望旧token的过期时间不变，实际为%d", claims.ExpiresAt.Unix())
+	}
+
+	// 有效期保存在元数据中，重新创建服务后保持
+	reloaded, err := NewAuthService(s.dbManager)
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	if reloaded.TokenTTL() != time.Hour {
+		t.Errorf("期望重新加载的token有效期为1小时，实际为%s", reloaded.TokenTTL())
+	}
+}
+
+func TestCreateUserAutoCreateKey(t *testing.T) {
+	s := newTestAuthService(t)
+
+	plain, err := s.CreateUser(&CreateUserRequest{Username: "plain"}, 0, GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if plain.APIKey != nil {
+		t.Errorf("未开启auto_create_key时不应创建API Key，实际得到%+v", plain.APIKey)
+	}
+
+	created, err := s.CreateUser(&CreateUserRequest{Username: "newcomer", AutoCreateKey: true}, 0, GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if created.GeneratedPassword == "" || created.APIKey == nil || created.APIKey.KeyValue == "" {
+		t.Fatalf("期望同时返回生成的密码和API Key，实际得到%+v", created)
+	}
+	apiKey, err := s.GetAPIKeyByValue(created.APIKey.KeyValue)
+	if err != nil {
+		t.Fatalf("自动创建的API Key应可用于认证: %v", err)
+	}
+	if apiKey.UserID != created.User.ID || apiKey.Name != DefaultAPIKeyName {
+		t.Errorf("自动创建的API Key应属于新用户，实际得到%+v", apiKey)
+	}
+}
+
+func TestUpdateAPIKeyInScope(t *testing.T) {
+	s := newTestAuthService(t)
+	member, err := s.CreateUser(&CreateUserRequest{Username: "member", AutoCreateKey: true}, 0, GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	allow := true
+	req := &UpdateAPIKeyRequest{AllowPromptOverride: &allow}
+
+	if _, err := s.UpdateAPIKey(member.APIKey.ID, member.User.ID+1, req); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("期望其他用户不能更新API Key，实际得到%v", err)
+	}
+	if _, err := s.UpdateAPIKeyInScope(TenantScope("other"), member.APIKey.ID, req); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("期望不能更新范围外的API Key，实际得到%v", err)
+	}
+
+	updated, err := s.UpdateAPIKeyInScope(GlobalScope, member.APIKey.ID, req)
+	if err != nil {
+		t.Fatalf("管理员更新API Key失败: %v", err)
+	}
+	if !updated.AllowPromptOverride || updated.UserID != member.User.ID {
+		t.Errorf("期望为原用户的Key开启覆盖Prompt，实际得到%+v", updated)
+	}
+}
+
+func TestTransferAPIKey(t *testing.T) {
+	s := newTestAuthService(t)
+	leaving, err := s.CreateUser(&CreateUserRequest{Username: "leaving", AutoCreateKey: true}, 0, GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	successor, err := s.CreateUser(&CreateUserRequest{Username: "successor"}, 0, GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	keyValue := leaving.APIKey.KeyValue
+	if err := s.UpdateAPIKeyLastUsed(keyValue, "203.0.113.7"); err != nil {
+		t.Fatalf("更新最后使用信息失败: %v", err)
+	}
+	before, err := s.GetAPIKeyByID(leaving.APIKey.ID)
+	if err != nil {
+		t.Fatalf("获取API Key失败: %v", err)
+	}
+
+	if _, err := s.TransferAPIKey(GlobalScope, before.ID, successor.User.ID+100); err == nil {
+		t.Error("期望不能转移给不存在的用户")
+	}
+	if _, err := s.TransferAPIKey(GlobalScope, before.ID, leaving.User.ID); err == nil {
+		t.Error("期望不能转移给当前所属用户")
+	}
+
+	transferred, err := s.TransferAPIKey(GlobalScope, before.ID, successor.User.ID)
+	if err != nil {
+		t.Fatalf("转移API Key失败: %v", err)
+	}
+	if transferred.UserID != successor.User.ID {
+		t.Errorf("期望API Key属于%d，实际为%d", successor.User.ID, transferred.UserID)
+	}
+	if transferred.KeyHash != db.HashAPIKey(keyValue) || transferred.LastUsedIP != before.LastUsedIP ||
+		transferred.LastUsedAt == nil || !transferred.LastUsedAt.Equal(*before.LastUsedAt) ||
+		!transferred.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("转移应保留Key值和使用记录，转移前%+v，转移后%+v", before, transferred)
+	}
+
+	// 原用户没有API Key后可以直接删除，转移后的Key仍然有效
+	if err := s.DeleteUser(leaving.User.ID, false); err != nil {
+		t.Fatalf("删除用户失败: %v", err)
+	}
+	if apiKey, err := s.GetAPIKeyByValue(keyValue); err != nil || apiKey.UserID != successor.User.ID {
+		t.Errorf("转移后的API Key应仍然有效，实际得到%+v, %v", apiKey, err)
+	}
+}
+
+func TestMustChangePassword(t *testing.T) {
+	s := newTestAuthService(t)
+	admin, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+	if admin.MustChangePassword {
+		t.Error("首次安装注册的管理员不应要求修改密码")
+	}
+
+	created, err := s.CreateUser(&CreateUserRequest{Username: "newcomer"}, admin.User.ID, GlobalScope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	login, err := s.Login(&LoginRequest{Username: "newcomer", Password: created.GeneratedPassword})
+	if err != nil {
+		t.Fatalf("使用临时密码登录失败: %v", err)
+	}
+	if !login.MustChangePassword || !login.User.MustChangePassword {
+		t.Error("管理员创建的用户登录时应要求修改密码")
+	}
+	if claims, err := s.ValidateToken(login.Token); err != nil || !claims.MustChangePassword {
+		t.Errorf("token应记录需修改密码: %+v, %v", claims, err)
+	}
+
+	if err := s.ChangePassword(created.User.ID, &ChangePasswordRequest{OldPassword: created.GeneratedPassword, NewPassword: "my-secret"}); err != nil {
+		t.Fatalf("修改密码失败: %v", err)
+	}
+	login, err = s.Login(&LoginRequest{Username: "newcomer", Password: "my-secret"})
+	if err != nil || login.MustChangePassword {
+		t.Errorf("修改密码后不应再要求修改: %+v, %v", login, err)
+	}
+
+	// 管理员重置的密码同样是临时密码
+	if err := s.AdminChangePassword(created.User.ID, &AdminChangePasswordRequest{NewPassword: "reset-123"}); err != nil {
+		t.Fatalf("重置密码失败: %v", err)
+	}
+	if user, _ := s.GetUserByID(created.User.ID); !user.MustChangePassword {
+		t.Error("管理员重置密码后应要求用户修改密码")
+	}
+}
+
+func TestValidateTokenRefreshesUser(t *testing.T) {
+	s := newTestAuthService(t)
+	admin, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+	claims, err := s.ValidateToken(admin.Token)
+	if err != nil || claims.Role != db.RoleSuperuser || !claims.Scope().IsGlobal() {
+		t.Fatalf("期望超级管理员token，实际得到%+v, %v", claims, err)
+	}
+
+	// 降级和移出全局后，已签发的token立即按新的角色和租户生效
+	user, _ := s.GetUserByID(admin.User.ID)
+	user.SetRole(db.RoleViewer)
+	if err := s.dbManager.UpdateUser(user); err != nil {
+		t.Fatalf("修改角色失败: %v", err)
+	}
+	if err := s.dbManager.UpdateUserTenant(user.ID, "team-a", false); err != nil {
+		t.Fatalf("修改租户失败: %v", err)
+	}
+	claims, err = s.ValidateToken(admin.Token)
+	if err != nil {
+		t.Fatalf("校验token失败: %v", err)
+	}
+	if claims.Role != db.RoleViewer || claims.IsAdmin || claims.Scope() != TenantScope("team-a") {
+		t.Errorf("期望token按用户当前状态刷新，实际得到%+v", claims)
+	}
+
+	if err := s.dbManager.UpdateUserStatus(user.ID, false); err != nil {
+		t.Fatalf("禁用用户失败: %v", err)
+	}
+	if _, err := s.ValidateToken(admin.Token); !errors.Is(err, ErrUserDisabled) {
+		t.Errorf("期望禁用用户的token失效，实际为%v", err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestAuthService(t)
+	s.SetLockoutPolicy(3, time.Hour)
+	admin, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+
+	// 成功登录会清零之前的失败次数
+	if _, err := s.Login(&LoginRequest{Username: "admin", Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("密码错误应返回ErrInvalidCredentials，实际%v", err)
+	}
+	if _, err := s.Login(&LoginRequest{Username: "admin", Password: "password"}); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	if user, _ := s.GetUserByID(admin.User.ID); user.FailedLoginCount != 0 {
+		t.Errorf("成功登录后失败次数应清零，实际%d", user.FailedLoginCount)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Login(&LoginRequest{Username: "admin", Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("第%d次密码错误应返回ErrInvalidCredentials，实际%v", i+1, err)
+		}
+	}
+	if _, err := s.Login(&LoginRequest{Username: "admin", Password: "wrong"}); !errors.Is(err, ErrUserLocked) {
+		t.Fatalf("达到失败次数上限时应锁定账户，实际%v", err)
+	}
+
+	// 锁定期内密码正确也不能登录，锁定状态保存在数据库中，重新创建服务后仍然有效
+	restarted, err := NewAuthService(s.dbManager)
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	if _, err := restarted.Login(&LoginRequest{Username: "admin", Password: "password"}); !errors.Is(err, ErrUserLocked) {
+		t.Fatalf("锁定期内应拒绝正确的密码，实际%v", err)
+	}
+
+	if err := s.UnlockUser(admin.User.ID); err != nil {
+		t.Fatalf("解锁失败: %v", err)
+	}
+	if _, err := s.Login(&LoginRequest{Username: "admin", Password: "password"}); err != nil {
+		t.Errorf("解锁后应能登录: %v", err)
+	}
+	if err := s.UnlockUser(admin.User.ID + 100); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("解锁不存在的用户应返回ErrUserNotFound，实际%v", err)
+	}
+}
+
+func TestLoginLockoutExpires(t *testing.T) {
+	s := newTestAuthService(t)
+	s.SetLockoutPolicy(1, time.Hour)
+	admin, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+	if _, err := s.Login(&LoginRequest{Username: "admin", Password: "wrong"}); !errors.Is(err, ErrUserLocked) {
+		t.Fatalf("期望锁定账户，实际%v", err)
+	}
+
+	// 将锁定到期时间改到过去，模拟锁定已过期
+	past := time.Now().Add(-time.Minute)
+	if err := s.dbManager.GetDB().Model(&db.User{}).Where("id = ?", admin.User.ID).Update("locked_until", &past).Error; err != nil {
+		t.Fatalf("修改锁定时间失败: %v", err)
+	}
+	login, err := s.Login(&LoginRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("锁定过期后应能登录: %v", err)
+	}
+	if login.User.LockedUntil != nil {
+		t.Error("登录成功后应清除已过期的锁定")
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/i18n"
+)
+
+// 授权检查项名称
+const (
+	CheckKeyEnabled         = "key_enabled"           // API Key已启用
+	CheckKeyNotExpired      = "key_not_expired"       // API Key未过期
+	CheckModelExists        = "model_exists"          // 模型配置存在
+	CheckKeyTenant          = "key_tenant"            // API Key与模型属于同一租户
+	CheckModelEnabled       = "model_enabled"         // 模型未禁用
+	CheckKeyAllowedForModel = "key_allowed_for_model" // API Key允许调用该模型
+	CheckMaintenance        = "maintenance"           // 模型未处于维护模式
+	CheckUpstreamHealth     = "upstream_health"       // 上游最近一次健康检查未失败
+	CheckConcurrency        = "concurrency"           // 模型并发名额
+)
+
+// CheckResult 单项授权检查结果
+type CheckResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"` // 检查未执行（如前置条件不满足或未配置）
+	Message string `json:"message,omitempty"`
+	Status  int    `json:"status,omitempty"` // 未通过时代理返回的HTTP状态码
+	Code    string `json:"code,omitempty"`   // 未通过时代理返回的错误码
+	Detail  string `json:"-"`                // 附加在本地化错误信息之后的细节，如模型ID
+}
+
+// fail 将检查标记为未通过，Message为默认语言的错误信息
+func (r *CheckResult) fail(status int, code i18n.Code, detail string) {
+	r.Passed = false
+	r.Status = status
+	r.Code = string(code)
+	r.Detail = detail
+	r.Message = i18n.Message(i18n.Default, code, detail)
+}
+
+// Verdict 授权模拟结果
+type Verdict struct {
+	Allowed  bool          `json:"allowed"`
+	APIKeyID uint          `json:"api_key_id"`
+	ModelID  string        `json:"model_id"`
+	Checks   []CheckResult `json:"checks"`
+}
+
+// Authorizer 代理请求的授权检查，代理服务和管理API的模拟接口共用同一套检查逻辑
+type Authorizer struct {
+	config        *config.Config
+	configService *ConfigService
+	inFlight      func() map[string]int
+	down          func(model *config.ModelConfig) bool
+}
+
+// NewAuthorizer 创建授权检查器，configService为nil时不检查全局维护模式
+func NewAuthorizer(cfg *config.Config, configService *ConfigService) *Authorizer {
+	return &Authorizer{
+		config:        cfg,
+		configService: configService,
+	}
+}
+
+// SetConcurrencyState 设置获取各模型当前进行中请求数的函数，用于模拟并发检查
+func (a *Authorizer) SetConcurrencyState(inFlight func() map[string]int) {
+	a.inFlight = inFlight
+}
+
+// SetHealthState 设置判断模型上游最近一次健康检查是否失败的函数，用于模拟健康检查
+func (a *Authorizer) SetHealthState(down func(model *config.ModelConfig) bool) {
+	a.down = down
+}
+
+// FirstFailure 返回第一个未通过的检查，全部通过时返回nil
+func FirstFailure(checks []CheckResult) *CheckResult {
+	for i := range checks {
+		if !checks[i].Passed && !checks[i].Skipped {
+			return &checks[i]
+		}
+	}
+	return nil
+}
+
+// KeyChecks 检查API Key本身是否可用（启用、未过期）
+func (a *Authorizer) KeyChecks(apiKey *db.APIKey, now time.Time) []CheckResult {
+	enabled := CheckResult{Name: CheckKeyEnabled, Passed: true}
+	if !apiKey.IsEnabled {
+		enabled.fail(http.StatusUnauthorized, i18n.CodeAPIKeyDisabled, "")
+	}
+
+	expired := CheckResult{Name: CheckKeyNotExpired, Passed: true}
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		expired.fail(http.StatusUnauthorized, i18n.CodeAPIKeyExpired, "")
+	}
+
+	return []CheckResult{enabled, expired}
+}
+
+// ModelChecks 检查API Key是否可以调用该模型，apiKey为nil时不限制；
+// 租户检查在最前面，其他租户的模型不透露禁用等状态
+func (a *Authorizer) ModelChecks(apiKey *db.APIKey, model *config.ModelConfig) []CheckResult {
+	tenant := CheckResult{Name: CheckKeyTenant, Passed: true}
+	if apiKey != nil && config.TenantOrDefault(apiKey.TenantID) != config.TenantOrDefault(model.Tenant) {
+		tenant.fail(http.StatusForbidden, i18n.CodeModelTenantMismatch, model.ID)
+	}
+
+	enabled := CheckResult{Name: CheckModelEnabled, Passed: true}
+	if model.Disabled {
+		enabled.fail(http.StatusForbidden, i18n.CodeModelDisabled, model.ID)
+	}
+
+	allowed := CheckResult{Name: CheckKeyAllowedForModel, Passed: true}
+	if apiKey != nil && !apiKey.AllowsModel(model.ID) {
+		allowed.fail(http.StatusForbidden, i18n.CodeKeyNotAllowedForModel, model.ID)
+	}
+
+	return []CheckResult{tenant, enabled, allowed}
+}
+
+// Simulate 按代理的检查顺序对API Key和模型执行授权检查，不转发任何请求；只模拟Key、模型、
+// 维护模式、上游健康和并发检查，请求体大小、压缩格式等请求体相关的检查不模拟。
+// 与代理不同，某项未通过后仍继续执行后续检查，便于一次看到所有问题
+func (a *Authorizer) Simulate(apiKey *db.APIKey, modelID string, now time.Time) *Verdict {
+	verdict := &Verdict{
+		APIKeyID: apiKey.ID,
+		ModelID:  modelID,
+	}
+	verdict.Checks = append(verdict.Checks, a.KeyChecks(apiKey, now)...)
+
+	model, exists := a.config.GetModel(modelID)
+	modelFound := CheckResult{Name: CheckModelExists, Passed: true}
+	if !exists {
+		// 与代理一致，未匹配的模型ID使用默认模型
+		if model, exists = a.config.DefaultModelConfig(); exists {
+			modelFound.Message = fmt.Sprintf("模型未配置，使用默认模型: %s", model.ID)
+		}
+	}
+	if !exists {
+		verdict.Checks = append(verdict.Checks, CheckResult{
+			Name:    CheckModelExists,
+			Message: fmt.Sprintf("模型配置未找到: %s", modelID),
+			Status:  http.StatusNotFound,
+		})
+		for _, name := range []string{CheckKeyTenant, CheckModelEnabled, CheckKeyAllowedForModel, CheckMaintenance, CheckUpstreamHealth, CheckConcurrency} {
+			verdict.Checks = append(verdict.Checks, CheckResult{Name: name, Skipped: true, Message: "模型不存在，未检查"})
+		}
+	} else {
+		verdict.Checks = append(verdict.Checks, modelFound)
+		verdict.Checks = append(verdict.Checks, a.ModelChecks(apiKey, model)...)
+		verdict.Checks = append(verdict.Checks, a.maintenanceCheck(model), a.healthCheck(model), a.concurrencyCheck(model))
+	}
+	verdict.Allowed = FirstFailure(verdict.Checks) == nil
+	return verdict
+}
+
+// maintenanceCheck 检查模型是否处于维护模式（维护时代理直接返回固定提示）
+func (a *Authorizer) maintenanceCheck(model *config.ModelConfig) CheckResult {
+	var global config.MaintenanceConfig
+	if a.configService != nil {
+		global = a.configService.GetMaintenance()
+	}
+
+	maintenance, ok := model.MaintenanceMode(global)
+	if !ok {
+		return CheckResult{Name: CheckMaintenance, Passed: true}
+	}
+	return CheckResult{
+		Name:    CheckMaintenance,
+		Message: maintenance.Message,
+		Status:  maintenance.StatusCode,
+	}
+}
+
+// healthCheck 检查上游最近一次健康检查是否失败（失败时代理直接返回503）
+func (a *Authorizer) healthCheck(model *config.ModelConfig) CheckResult {
+	result := CheckResult{Name: CheckUpstreamHealth, Passed: true}
+	if model.HealthCheckInterval <= 0 {
+		result.Message = "未开启健康检查"
+		return result
+	}
+	if a.down == nil {
+		result.Skipped = true
+		result.Message = "无法获取健康检查状态"
+		return result
+	}
+	if a.down(model) {
+		result.fail(http.StatusServiceUnavailable, i18n.CodeUpstreamDown, "")
+	}
+	return result
+}
+
+// concurrencyCheck 根据当前进行中的请求数检查模型并发名额
+func (a *Authorizer) concurrencyCheck(model *config.ModelConfig) CheckResult {
+	result := CheckResult{Name: CheckConcurrency, Passed: true}
+	if model.MaxConcurrency <= 0 {
+		result.Message = "未限制并发"
+		return result
+	}
+	if a.inFlight == nil {
+		result.Skipped = true
+		result.Message = "无法获取当前并发状态"
+		return result
+	}
+
+	current := a.inFlight()[model.ID]
+	if current < model.MaxConcurrency {
+		result.Message = fmt.Sprintf("当前并发 %d/%d", current, model.MaxConcurrency)
+		return result
+	}
+	if queue, timeout := model.QueueWait(); queue {
+		result.Message = fmt.Sprintf("当前并发 %d/%d，请求将排队等待最多 %s", current, model.MaxConcurrency, timeout)
+		return result
+	}
+
+	result.Passed = false
+	result.Message = fmt.Sprintf("已达到模型最大并发数 %d", model.MaxConcurrency)
+	result.Status = http.StatusTooManyRequests
+	result.Code = "model_overloaded"
+	return result
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+func newTestAuthorizer() *Authorizer {
+	cfg := &config.Config{Models: map[string]*config.ModelConfig{
+		"chat-a":      {ID: "chat-a", Name: "A", Target: "gpt-4o"},
+		"chat-b":      {ID: "chat-b", Name: "B", Target: "gpt-4o"},
+		"disabled":    {ID: "disabled", Name: "D", Target: "gpt-4o", Disabled: true},
+		"maintenance": {ID: "maintenance", Name: "M", Target: "gpt-4o", Maintenance: true, MaintenanceMessage: "维护中", MaintenanceStatus: 503},
+		"limited":     {ID: "limited", Name: "L", Target: "gpt-4o", MaxConcurrency: 2},
+		"queued":      {ID: "queued", Name: "Q", Target: "gpt-4o", MaxConcurrency: 2, QueueOnLimit: true, QueueTimeout: 30},
+		"team-chat":   {ID: "team-chat", Name: "T", Target: "gpt-4o", Tenant: "team-a"},
+		"down":        {ID: "down", Name: "H", Target: "gpt-4o", HealthCheckInterval: 30},
+	}}
+	a := NewAuthorizer(cfg, nil)
+	a.SetConcurrencyState(func() map[string]int {
+		return map[string]int{"limited": 2, "queued": 2}
+	})
+	a.SetHealthState(func(model *config.ModelConfig) bool {
+		return model.ID == "down"
+	})
+	return a
+}
+
+// findCheck 查找指定名称的检查结果
+func findCheck(t *testing.T, verdict *Verdict, name string) CheckResult {
+	t.Helper()
+	for _, check := range verdict.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("结果中缺少检查项%s", name)
+	return CheckResult{}
+}
+
+// assertOnlyFailure 断言只有指定的检查未通过
+func assertOnlyFailure(t *testing.T, verdict *Verdict, name string, status int) {
+	t.Helper()
+	if verdict.Allowed {
+		t.Fatalf("期望检查%s未通过时整体不允许", name)
+	}
+	failed := findCheck(t, verdict, name)
+	if failed.Passed || failed.Skipped {
+		t.Fatalf("期望检查%s未通过，实际%+v", name, failed)
+	}
+	if failed.Status != status {
+		t.Errorf("期望检查%s的状态码为%d，实际得到%d", name, status, failed.Status)
+	}
+	for _, check := range verdict.Checks {
+		if check.Name != name && !check.Passed && !check.Skipped {
+			t.Errorf("检查%s不应失败: %+v", check.Name, check)
+		}
+	}
+}
+
+func TestSimulateAllowed(t *testing.T) {
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{ID: 1, IsEnabled: true}, "chat-a", time.Now())
+	if !verdict.Allowed {
+		t.Fatalf("期望允许调用，实际%+v", verdict.Checks)
+	}
+	for _, check := range verdict.Checks {
+		if check.Skipped {
+			t.Errorf("模型存在时不应跳过检查，实际%+v", check)
+		}
+	}
+}
+
+func TestSimulateKeyDisabled(t *testing.T) {
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{IsEnabled: false}, "chat-a", time.Now())
+	assertOnlyFailure(t, verdict, CheckKeyEnabled, http.StatusUnauthorized)
+}
+
+func TestSimulateKeyExpired(t *testing.T) {
+	expiresAt := time.Now().Add(-time.Hour)
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{IsEnabled: true, ExpiresAt: &expiresAt}, "chat-a", time.Now())
+	assertOnlyFailure(t, verdict, CheckKeyNotExpired, http.StatusUnauthorized)
+}
+
+func TestSimulateModelNotFound(t *testing.T) {
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{IsEnabled: true}, "unknown", time.Now())
+	assertOnlyFailure(t, verdict, CheckModelExists, http.StatusNotFound)
+	if check := findCheck(t, verdict, CheckKeyAllowedForModel); !check.Skipped {
+		t.Errorf("模型不存在时后续模型检查应跳过，实际%+v", check)
+	}
+}
+
+func TestSimulateModelDisabled(t *testing.T) {
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{IsEnabled: true}, "disabled", time.Now())
+	assertOnlyFailure(t, verdict, CheckModelEnabled, http.StatusForbidden)
+}
+
+func TestSimulateKeyNotAllowedForModel(t *testing.T) {
+	apiKey := &db.APIKey{IsEnabled: true, AllowedModels: db.StringList{"chat-a"}}
+	verdict := newTestAuthorizer().Simulate(apiKey, "chat-b", time.Now())
+	assertOnlyFailure(t, verdict, CheckKeyAllowedForModel, http.StatusForbidden)
+	if code := findCheck(t, verdict, CheckKeyAllowedForModel).Code; code != "key_not_allowed_for_model" {
+		t.Errorf("期望错误码key_not_allowed_for_model，实际得到%q", code)
+	}
+}
+
+func TestSimulateKeyTenant(t *testing.T) {
+	a := newTestAuthorizer()
+	// 默认租户的Key不能调用其他租户的模型，即使允许列表为空（不限制模型）
+	verdict := a.Simulate(&db.APIKey{IsEnabled: true}, "team-chat", time.Now())
+	assertOnlyFailure(t, verdict, CheckKeyTenant, http.StatusForbidden)
+	if code := findCheck(t, verdict, CheckKeyTenant).Code; code != "model_tenant_mismatch" {
+		t.Errorf("期望错误码model_tenant_mismatch，实际得到%q", code)
+	}
+	verdict = a.Simulate(&db.APIKey{IsEnabled: true, TenantID: "team-a"}, "chat-a", time.Now())
+	assertOnlyFailure(t, verdict, CheckKeyTenant, http.StatusForbidden)
+
+	// 同一租户的Key可以调用，空租户视为默认租户
+	if verdict = a.Simulate(&db.APIKey{IsEnabled: true, TenantID: "team-a"}, "team-chat", time.Now()); !verdict.Allowed {
+		t.Errorf("同一租户的Key应允许调用，实际%+v", verdict.Checks)
+	}
+	if verdict = a.Simulate(&db.APIKey{IsEnabled: true, TenantID: config.DefaultTenant}, "chat-a", time.Now()); !verdict.Allowed {
+		t.Errorf("默认租户的Key应允许调用未设置租户的模型，实际%+v", verdict.Checks)
+	}
+}
+
+func TestSimulateMaintenance(t *testing.T) {
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{IsEnabled: true}, "maintenance", time.Now())
+	assertOnlyFailure(t, verdict, CheckMaintenance, http.StatusServiceUnavailable)
+	if message := findCheck(t, verdict, CheckMaintenance).Message; message != "维护中" {
+		t.Errorf("期望维护提示为配置的信息，实际得到%q", message)
+	}
+}
+
+func TestSimulateUpstreamDown(t *testing.T) {
+	verdict := newTestAuthorizer().Simulate(&db.APIKey{IsEnabled: true}, "down", time.Now())
+	assertOnlyFailure(t, verdict, CheckUpstreamHealth, http.StatusServiceUnavailable)
+	if code := findCheck(t, verdict, CheckUpstreamHealth).Code; code != "upstream_down" {
+		t.Errorf("期望错误码upstream_down，实际得到%q", code)
+	}
+}
+
+func TestSimulateConcurrencyLimit(t *testing.T) {
+	a := newTestAuthorizer()
+	verdict := a.Simulate(&db.APIKey{IsEnabled: true}, "limited", time.Now())
+	assertOnlyFailure(t, verdict, CheckConcurrency, http.StatusTooManyRequests)
+
+	// 开启排队时达到上限不会直接拒绝
+	verdict = a.Simulate(&db.APIKey{IsEnabled: true}, "queued", time.Now())
+	if !verdict.Allowed {
+		t.Errorf("开启排队的模型达到并发上限时应允许排队，实际%+v", verdict.Checks)
+	}
+}
+
+func TestFirstFailureMatchesProxyOrder(t *testing.T) {
+	a := newTestAuthorizer()
+	expiresAt := time.Now().Add(-time.Hour)
+	failed := FirstFailure(a.KeyChecks(&db.APIKey{IsEnabled: false, ExpiresAt: &expiresAt}, time.Now()))
+	if failed == nil || failed.Name != CheckKeyEnabled {
+		t.Fatalf("期望先报告Key已禁用，实际%+v", failed)
+	}
+
+	model, _ := a.config.GetModel("disabled")
+	if failed := FirstFailure(a.ModelChecks(nil, model)); failed == nil || failed.Name != CheckModelEnabled {
+		t.Errorf("期望报告模型已禁用，实际%+v", failed)
+	}
+	model, _ = a.config.GetModel("chat-a")
+	if failed := FirstFailure(a.ModelChecks(nil, model)); failed != nil {
+		t.Errorf("未认证的请求不应受Key允许列表限制，实际%+v", failed)
+	}
+}
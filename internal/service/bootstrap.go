@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// 初始管理员引导使用的环境变量
+const (
+	EnvBootstrapUsername     = "ADMIN_BOOTSTRAP_USERNAME"
+	EnvBootstrapPassword     = "ADMIN_BOOTSTRAP_PASSWORD"
+	EnvBootstrapPasswordFile = "ADMIN_BOOTSTRAP_PASSWORD_FILE"
+)
+
+// BootstrapAdmin 用户表为空时根据环境变量创建初始管理员，供自动化部署跳过首次安装注册。
+// 已有用户或未设置用户名时不做任何事并返回nil；密码只以bcrypt哈希形式保存
+func (s *AuthService) BootstrapAdmin(lookup func(string) (string, bool)) (*db.User, error) {
+	count, err := s.dbManager.GetUserCount()
+	if err != nil {
+		return nil, fmt.Errorf("检查用户数量失败: %w", err)
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	username, _ := lookup(EnvBootstrapUsername)
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, nil
+	}
+
+	password, err := bootstrapPassword(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	user := &db.User{
+		Username:  username,
+		Password:  hashedPassword,
+		Role:      db.RoleSuperuser,
+		IsEnabled: true,
+		Global:    true, // 初始管理员为全局管理员，可以管理所有租户
+	}
+	if err := s.dbManager.CreateUser(user); err != nil {
+		return nil, fmt.Errorf("创建初始管理员失败: %w", err)
+	}
+	return user, nil
+}
+
+// bootstrapPassword 读取初始管理员密码，ADMIN_BOOTSTRAP_PASSWORD_FILE优先于ADMIN_BOOTSTRAP_PASSWORD
+func bootstrapPassword(lookup func(string) (string, bool)) (string, error) {
+	if path, ok := lookup(EnvBootstrapPasswordFile); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("读取%s失败: %w", EnvBootstrapPasswordFile, err)
+		}
+		// 去掉文件末尾的换行，密码本身的空格保持不变
+		password := strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("%s指向的文件为空", EnvBootstrapPasswordFile)
+		}
+		return password, nil
+	}
+
+	password, _ := lookup(EnvBootstrapPassword)
+	if password == "" {
+		return "", fmt.Errorf("已设置%s，但未设置%s或%s", EnvBootstrapUsername, EnvBootstrapPassword, EnvBootstrapPasswordFile)
+	}
+	return password, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+func newTestAuthService(t *testing.T) *AuthService {
+	t.Helper()
+	manager, err := db.NewManager(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+
+	s, err := NewAuthService(manager)
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	return s
+}
+
+func envLookup(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func TestBootstrapAdminEmptyDB(t *testing.T) {
+	s := newTestAuthService(t)
+
+	user, err := s.BootstrapAdmin(envLookup(map[string]string{
+		EnvBootstrapUsername: "ops",
+		EnvBootstrapPassword: "s3cret-pass",
+	}))
+	if err != nil {
+		t.Fatalf("创建初始管理员失败: %v", err)
+	}
+	if user == nil || !user.IsAdmin {
+		t.Fatalf("期望创建管理员用户，实际得到%+v", user)
+	}
+	if user.Password == "s3cret-pass" || !s.CheckPassword(user.Password, "s3cret-pass") {
+		t.Error("密码应以bcrypt哈希保存")
+	}
+
+	firstInstall, err := s.IsFirstInstall()
+	if err != nil {
+		t.Fatalf("检查首次安装失败: %v", err)
+	}
+	if firstInstall {
+		t.Error("创建初始管理员后不应再是首次安装")
+	}
+	if _, err := s.Login(&LoginRequest{Username: "ops", Password: "s3cret-pass"}); err != nil {
+		t.Errorf("初始管理员应能登录: %v", err)
+	}
+}
+
+func TestBootstrapAdminIgnoredWhenUsersExist(t *testing.T) {
+	s := newTestAuthService(t)
+	if _, err := s.Register(&RegisterRequest{Username: "existing", Password: "password1"}); err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+
+	user, err := s.BootstrapAdmin(envLookup(map[string]string{
+		EnvBootstrapUsername: "ops",
+		EnvBootstrapPassword: "s3cret-pass",
+	}))
+	if err != nil || user != nil {
+		t.Fatalf("已有用户时应忽略环境变量，实际得到%+v, %v", user, err)
+	}
+	count, err := s.dbManager.GetUserCount()
+	if err != nil {
+		t.Fatalf("获取用户数量失败: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("不应创建新用户，实际用户数%d", count)
+	}
+}
+
+func TestBootstrapAdminPasswordFile(t *testing.T) {
+	s := newTestAuthService(t)
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file-pass\n"), 0600); err != nil {
+		t.Fatalf("写入密码文件失败: %v", err)
+	}
+
+	user, err := s.BootstrapAdmin(envLookup(map[string]string{
+		EnvBootstrapUsername:     "ops",
+		EnvBootstrapPasswordFile: passwordFile,
+	}))
+	if err != nil {
+		t.Fatalf("创建初始管理员失败: %v", err)
+	}
+	if !s.CheckPassword(user.Password, "from-file-pass") {
+		t.Error("应使用密码文件内容（去掉末尾换行）作为密码")
+	}
+}
+
+func TestBootstrapAdminMissingPassword(t *testing.T) {
+	s := newTestAuthService(t)
+	if _, err := s.BootstrapAdmin(envLookup(map[string]string{EnvBootstrapUsername: "ops"})); err == nil {
+		t.Fatal("只设置用户名时应返回错误")
+	}
+
+	user, err := s.BootstrapAdmin(envLookup(nil))
+	if err != nil || user != nil {
+		t.Errorf("未设置环境变量时不应创建用户，实际得到%+v, %v", user, err)
+	}
+}
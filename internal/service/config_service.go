@@ -1,25 +1,56 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/config"
 	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"gopkg.in/yaml.v3"
 )
 
 // ConfigService 配置服务
 type ConfigService struct {
 	config *config.Config
 	db     *db.Manager
+
+	maintenance      config.MaintenanceConfig
+	maintenanceMutex sync.RWMutex
+
+	watcher     *ConfigWatcher
+	yamlModels  map[string]*config.ModelConfig // 最近一次从YAML文件加载的模型，用于比较文件变化
+	reloadMutex sync.Mutex                     // 串行化重新加载、恢复备份和增删改模型，避免数据库与内存配置交错写入
+
+	driftPolicy string       // YAML文件与数据库不一致时的处理策略
+	lastDrift   *DriftReport // 最近一次加载配置时的比较结果
+	driftMutex  sync.RWMutex
 }
 
-// NewConfigService 创建配置服务
+// maintenanceMetadataKey 全局维护模式在元数据表中的键
+const maintenanceMetadataKey = "maintenance"
+
+// NewConfigService 创建使用配置目录下SQLite数据库的配置服务
 func NewConfigService(configDir string) (*ConfigService, error) {
+	return NewConfigServiceWithDatabase(configDir, config.DatabaseConfig{}, config.DriftPolicyDBWins)
+}
+
+// NewConfigServiceWithDatabase 根据数据库配置创建配置服务，DSN为空时使用配置目录下的SQLite数据库；
+// driftPolicy为YAML文件与数据库中模型配置不一致时的处理策略，为空时使用db_wins
+func NewConfigServiceWithDatabase(configDir string, dbConfig config.DatabaseConfig, driftPolicy string) (*ConfigService, error) {
+	if driftPolicy == "" {
+		driftPolicy = config.DriftPolicyDBWins
+	}
+
 	// 创建数据库管理器
 	dbPath := filepath.Join(configDir, "db")
-	database, err := db.NewManager(dbPath)
+	database, err := db.NewManagerWithConfig(dbPath, dbConfig)
 	if err != nil {
 		return nil, fmt.Errorf("创建数据库管理器失败: %w", err)
 	}
@@ -28,28 +59,62 @@ func NewConfigService(configDir string) (*ConfigService, error) {
 		config: &config.Config{
 			Models: make(map[string]*config.ModelConfig),
 		},
-		db: database,
+		db:          database,
+		driftPolicy: driftPolicy,
 	}
 
 	// 加载配置
 	if err := service.LoadConfig(configDir); err != nil {
+		database.Close()
 		return nil, fmt.Errorf("加载配置失败: %w", err)
 	}
 
+	// 加载全局维护模式设置
+	if value, err := database.GetMetadata(maintenanceMetadataKey); err == nil {
+		if err := json.Unmarshal([]byte(value), &service.maintenance); err != nil {
+			fmt.Printf("解析维护模式配置失败: %v\n", err)
+		}
+	}
+
 	return service, nil
 }
 
-// LoadConfig 加载配置
+// LoadConfig 加载配置。数据库中有模型时以数据库为基础，与YAML文件比较后按driftPolicy处理不一致的模型；
+// 数据库为空时从YAML文件加载并迁移到数据库。读取数据库失败时直接返回错误，不会用YAML文件覆盖
 func (s *ConfigService) LoadConfig(configDir string) error {
-	// 首先尝试从数据库加载
 	dbConfigs, err := s.db.GetAllModelConfigs()
-	if err == nil && len(dbConfigs) > 0 {
-		s.config.Models = dbConfigs
+	if err != nil {
+		return fmt.Errorf("从数据库加载模型配置失败: %w", err)
+	}
+
+	if len(dbConfigs) > 0 {
 		fmt.Printf("从数据库加载了 %d 个模型配置\n", len(dbConfigs))
+
+		// 模型保存在数据库中，默认模型等全局设置仍从YAML文件读取
+		yamlConfig, err := config.LoadConfig(configDir)
+		if err != nil {
+			fmt.Printf("从YAML文件读取全局模型设置失败，跳过一致性检查: %v\n", err)
+			return s.applyModels(dbConfigs, &config.Config{})
+		}
+
+		items := compareModels(yamlConfig.Models, dbConfigs)
+		models, applied, err := s.reconcile(s.driftPolicy, items, yamlConfig.Models, dbConfigs)
+		if err != nil {
+			return fmt.Errorf("处理YAML文件与数据库中不一致的模型配置失败: %w", err)
+		}
+		report := &DriftReport{Policy: s.driftPolicy, CheckedAt: time.Now(), Items: items, Applied: applied}
+		logDrift(report)
+
+		if err := s.applyModels(models, yamlConfig); err != nil {
+			return err
+		}
+		s.driftMutex.Lock()
+		s.lastDrift = report
+		s.driftMutex.Unlock()
 		return nil
 	}
 
-	fmt.Printf("数据库中没有配置，从YAML文件加载: %v\n", err)
+	fmt.Println("数据库中没有模型配置，从YAML文件加载")
 
 	// 从YAML文件加载
 	yamlConfig, err := config.LoadConfig(configDir)
@@ -57,13 +122,15 @@ func (s *ConfigService) LoadConfig(configDir string) error {
 		return fmt.Errorf("从YAML文件加载配置失败: %w", err)
 	}
 
-	s.config.Models = yamlConfig.Models
+	if err := s.applyModels(yamlConfig.Models, yamlConfig); err != nil {
+		return err
+	}
 
 	// 将YAML配置迁移到数据库
 	if err := s.MigrateYAMLToDB(); err != nil {
 		fmt.Printf("迁移YAML配置到数据库失败: %v\n", err)
 	} else {
-		fmt.Printf("成功迁移 %d 个模型配置到数据库\n", len(s.config.Models))
+		fmt.Printf("成功迁移 %d 个模型配置到数据库\n", s.config.ModelCount())
 	}
 
 	return nil
@@ -71,7 +138,7 @@ func (s *ConfigService) LoadConfig(configDir string) error {
 
 // MigrateYAMLToDB 将YAML配置迁移到数据库
 func (s *ConfigService) MigrateYAMLToDB() error {
-	for _, model := range s.config.Models {
+	for _, model := range s.config.SnapshotModels() {
 		if err := s.db.SaveModelConfig(model); err != nil {
 			return fmt.Errorf("保存模型配置 %s 到数据库失败: %w", model.ID, err)
 		}
@@ -79,7 +146,7 @@ func (s *ConfigService) MigrateYAMLToDB() error {
 	return nil
 }
 
-// GetConfig 获取配置
+// GetConfig 获取配置，返回的指针在服务的整个生命周期内不变，重新加载、恢复备份等操作通过加锁的Replace原地替换模型
 func (s *ConfigService) GetConfig() *config.Config {
 	return s.config
 }
@@ -94,11 +161,49 @@ func (s *ConfigService) GetModel(modelID string) (*config.ModelConfig, bool) {
 	return s.config.GetModel(modelID)
 }
 
+// GetMaintenance 获取全局维护模式配置
+func (s *ConfigService) GetMaintenance() config.MaintenanceConfig {
+	s.maintenanceMutex.RLock()
+	defer s.maintenanceMutex.RUnlock()
+	return s.maintenance
+}
+
+// SetMaintenance 设置全局维护模式配置并持久化到数据库
+func (s *ConfigService) SetMaintenance(maintenance config.MaintenanceConfig) error {
+	if err := maintenance.Validate(); err != nil {
+		return fmt.Errorf("维护模式配置验证失败: %w", err)
+	}
+
+	value, err := json.Marshal(maintenance)
+	if err != nil {
+		return fmt.Errorf("序列化维护模式配置失败: %w", err)
+	}
+	if err := s.db.SetMetadata(maintenanceMetadataKey, string(value)); err != nil {
+		return fmt.Errorf("保存维护模式配置失败: %w", err)
+	}
+
+	s.maintenanceMutex.Lock()
+	s.maintenance = maintenance
+	s.maintenanceMutex.Unlock()
+	return nil
+}
+
 // GetAllModelsWithTime 获取所有模型配置（包含时间信息）
 func (s *ConfigService) GetAllModelsWithTime() ([]db.ModelConfigDB, error) {
 	return s.db.GetAllModelConfigsWithTime()
 }
 
+// QueryModelsWithTime 按条件分页查询范围内的模型配置（包含时间信息）
+func (s *ConfigService) QueryModelsWithTime(scope TenantScope, q db.ModelQuery) ([]db.ModelConfigDB, int64, error) {
+	q.Tenant = scope.Filter()
+	return s.db.QueryModelConfigs(q)
+}
+
+// GetModelUsage 获取多个模型的调用统计，没有调用记录的模型不在结果中
+func (s *ConfigService) GetModelUsage(modelIDs []string) (map[string]db.ModelUsage, error) {
+	return s.db.GetModelUsage(modelIDs)
+}
+
 // GetModelWithTime 获取单个模型配置（包含时间信息）
 func (s *ConfigService) GetModelWithTime(modelID string) (*db.ModelConfigDB, error) {
 	return s.db.GetModelConfigWithTime(modelID)
@@ -106,10 +211,16 @@ func (s *ConfigService) GetModelWithTime(modelID string) (*db.ModelConfigDB, err
 
 // SaveModel 保存模型配置
 func (s *ConfigService) SaveModel(model *config.ModelConfig) error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
 	// 验证模型配置
 	if err := model.Validate(); err != nil {
 		return fmt.Errorf("模型配置验证失败: %w", err)
 	}
+	if err := s.config.CheckAliases(model); err != nil {
+		return err
+	}
 
 	// 保存到数据库
 	if err := s.db.SaveModelConfig(model); err != nil {
@@ -124,10 +235,16 @@ func (s *ConfigService) SaveModel(model *config.ModelConfig) error {
 
 // UpdateModel 更新模型配置
 func (s *ConfigService) UpdateModel(model *config.ModelConfig) error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
 	// 验证模型配置
 	if err := model.Validate(); err != nil {
 		return fmt.Errorf("模型配置验证失败: %w", err)
 	}
+	if err := s.config.CheckAliases(model); err != nil {
+		return err
+	}
 
 	// 更新数据库
 	if err := s.db.UpdateModelConfig(model); err != nil {
@@ -142,10 +259,15 @@ func (s *ConfigService) UpdateModel(model *config.ModelConfig) error {
 
 // DeleteModel 删除模型配置
 func (s *ConfigService) DeleteModel(modelID string) error {
-	// 检查模型是否存在
-	if _, exists := s.config.GetModel(modelID); !exists {
-		return fmt.Errorf("模型 %s 不存在", modelID)
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	// 检查模型是否存在，传入别名时删除别名对应的模型
+	model, exists := s.config.GetModel(modelID)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, modelID)
 	}
+	modelID = model.ID
 
 	// 从数据库删除
 	if err := s.db.DeleteModelConfig(modelID); err != nil {
@@ -160,11 +282,173 @@ func (s *ConfigService) DeleteModel(modelID string) error {
 
 // ReloadConfig 重新加载配置
 func (s *ConfigService) ReloadConfig(configDir string) error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
 	return s.LoadConfig(configDir)
 }
 
+// ConfigChanges 一次重新加载或恢复中变化的模型ID
+type ConfigChanges struct {
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+}
+
+// Empty 是否没有任何变化
+func (c *ConfigChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+// String 变化摘要
+func (c *ConfigChanges) String() string {
+	return fmt.Sprintf("新增%v 更新%v 删除%v", c.Added, c.Updated, c.Removed)
+}
+
+// diffModels 比较两组模型配置的差异
+func diffModels(previous, current map[string]*config.ModelConfig) *ConfigChanges {
+	changes := &ConfigChanges{Added: []string{}, Updated: []string{}, Removed: []string{}}
+	for id, model := range current {
+		old, exists := previous[id]
+		switch {
+		case !exists:
+			changes.Added = append(changes.Added, id)
+		case !reflect.DeepEqual(old, model):
+			changes.Updated = append(changes.Updated, id)
+		}
+	}
+	for id := range previous {
+		if _, exists := current[id]; !exists {
+			changes.Removed = append(changes.Removed, id)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Updated)
+	sort.Strings(changes.Removed)
+	return changes
+}
+
+// ReloadFromYAML 从YAML文件重新加载配置：只应用相对上次加载的YAML有变化的模型，
+// 未在文件中变化的模型（包括通过管理API修改的）保持不变，通过管理API创建的模型始终不受影响。
+// 任一文件验证失败时返回错误且不修改当前配置。变化写入数据库后在Config的写锁内一次性替换模型表、
+// 别名索引和全局设置，并发处理的代理请求只会看到重新加载前或之后的完整配置
+func (s *ConfigService) ReloadFromYAML(configDir string) (*ConfigChanges, error) {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	yamlConfig, err := config.LoadConfig(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("从YAML文件加载配置失败: %w", err)
+	}
+
+	changes := diffModels(s.yamlModels, yamlConfig.Models)
+	changes.Added = s.skipAPIModels(changes.Added)
+	changes.Updated = s.skipAPIModels(changes.Updated)
+	changes.Removed = s.skipAPIModels(changes.Removed)
+
+	models := s.config.SnapshotModels()
+	for _, id := range append(changes.Added, changes.Updated...) {
+		models[id] = yamlConfig.Models[id]
+	}
+	var removed []string
+	for _, id := range changes.Removed {
+		if _, exists := models[id]; exists {
+			removed = append(removed, id)
+			delete(models, id)
+		}
+	}
+
+	// 与通过管理API添加的模型合并后再检查别名冲突，有冲突时不做任何修改
+	merged := &config.Config{Models: models, CaseInsensitiveModels: yamlConfig.CaseInsensitiveModels}
+	if err := merged.RebuildIndex(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range append(changes.Added, changes.Updated...) {
+		if err := s.db.SaveModelConfig(yamlConfig.Models[id]); err != nil {
+			return nil, fmt.Errorf("保存模型配置 %s 到数据库失败: %w", id, err)
+		}
+	}
+	for _, id := range removed {
+		if err := s.db.DeleteModelConfig(id); err != nil {
+			return nil, fmt.Errorf("从数据库删除模型配置 %s 失败: %w", id, err)
+		}
+	}
+
+	if err := s.applyModels(models, yamlConfig); err != nil {
+		return nil, err
+	}
+	s.yamlModels = yamlConfig.Models
+	return changes, nil
+}
+
+// skipAPIModels 过滤掉当前不是从YAML文件加载的模型（由管理API创建或来源未知），
+// YAML文件中的同名模型不会覆盖或删除它们
+func (s *ConfigService) skipAPIModels(ids []string) []string {
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if model, exists := s.config.GetModel(id); exists && model.ID == id && model.Source != config.ModelSourceYAML {
+			fmt.Printf("模型 %s 不是从配置文件加载的（来源: %q），忽略配置文件中的变化\n", id, model.Source)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	return kept
+}
+
+// applyModels 一次性替换内存中的模型表并应用YAML文件中的全局模型设置（默认模型、忽略大小写），
+// 代理并发处理的请求只会看到替换前或替换后的完整配置；存在别名冲突时返回错误，默认模型未配置时打印警告
+func (s *ConfigService) applyModels(models map[string]*config.ModelConfig, yamlConfig *config.Config) error {
+	if err := s.config.Replace(models, yamlConfig.DefaultModel, yamlConfig.CaseInsensitiveModels); err != nil {
+		return fmt.Errorf("应用模型配置失败: %w", err)
+	}
+	if yamlConfig.DefaultModel == "" {
+		return nil
+	}
+	if _, exists := s.config.GetModel(yamlConfig.DefaultModel); !exists {
+		fmt.Printf("默认模型 %s 未配置，未匹配的模型ID将返回404\n", yamlConfig.DefaultModel)
+	}
+	return nil
+}
+
+// WatchConfigDir 监听配置目录，YAML文件变化后自动调用ReloadFromYAML，
+// 新文件验证失败时保留当前配置并打印错误
+func (s *ConfigService) WatchConfigDir(configDir string, interval, debounce time.Duration) error {
+	if s.watcher != nil {
+		return fmt.Errorf("配置目录监听已启动")
+	}
+
+	// 以当前文件内容作为比较基准，启动前通过管理API做的修改不会被覆盖
+	s.reloadMutex.Lock()
+	if yamlConfig, err := config.LoadConfig(configDir); err == nil {
+		s.yamlModels = yamlConfig.Models
+	} else {
+		fmt.Printf("加载YAML配置基准失败: %v\n", err)
+	}
+	s.reloadMutex.Unlock()
+
+	watcher := NewConfigWatcher(configDir, interval, debounce, func() {
+		changes, err := s.ReloadFromYAML(configDir)
+		if err != nil {
+			fmt.Printf("配置文件变更未生效，继续使用当前配置: %v\n", err)
+			return
+		}
+		if changes.Empty() {
+			return
+		}
+		fmt.Printf("配置文件变更已生效: %s\n", changes)
+	})
+	if err := watcher.Start(); err != nil {
+		return fmt.Errorf("启动配置目录监听失败: %w", err)
+	}
+	s.watcher = watcher
+	return nil
+}
+
 // Close 关闭服务
 func (s *ConfigService) Close() error {
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -179,7 +463,7 @@ func (s *ConfigService) BackupToYAML(backupDir string) error {
 
 	// 按模型类型分组保存
 	modelsByType := make(map[string][]*config.ModelConfig)
-	for _, model := range s.config.Models {
+	for _, model := range s.config.SnapshotModels() {
 		modelType := string(model.Type)
 		modelsByType[modelType] = append(modelsByType[modelType], model)
 	}
@@ -197,29 +481,78 @@ func (s *ConfigService) BackupToYAML(backupDir string) error {
 	return nil
 }
 
-// saveModelsToYAML 保存模型列表到YAML文件
-func (s *ConfigService) saveModelsToYAML(models []*config.ModelConfig, filepath string) error {
-	// 这里可以实现YAML保存逻辑
-	// 为了简化，我们先创建一个占位符实现
-	content := "# 模型配置备份文件\n"
-	content += "# 生成时间: " + fmt.Sprintf("%v", models) + "\n"
-	content += "models:\n"
+// BackupFile 将全部模型配置备份到backupDir下带时间戳的文件，返回文件路径
+func (s *ConfigService) BackupFile(backupDir string) (string, error) {
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return "", fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	snapshot := s.config.SnapshotModels()
+	models := make([]*config.ModelConfig, 0, len(snapshot))
+	for _, model := range snapshot {
+		models = append(models, model)
+	}
+	filename := fmt.Sprintf("config_backup_%s.yaml", time.Now().Format("20060102_150405.000"))
+	path := filepath.Join(backupDir, filename)
+	if err := s.saveModelsToYAML(models, path); err != nil {
+		return "", fmt.Errorf("写入备份文件失败: %w", err)
+	}
+	return path, nil
+}
+
+// RestoreBackup 从backupDir下名为name的备份文件恢复模型配置，替换当前全部模型。
+// 备份文件验证失败时不做修改；dryRun为true时只返回差异不应用
+func (s *ConfigService) RestoreBackup(backupDir, name string, dryRun bool) (*ConfigChanges, error) {
+	if name == "" || name != filepath.Base(name) || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+		return nil, fmt.Errorf("无效的备份文件名: %s", name)
+	}
+
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	backup, err := config.LoadConfigFile(filepath.Join(backupDir, name))
+	if err != nil {
+		return nil, err
+	}
+	defaultModel, caseInsensitive := s.config.Settings()
+	backup.CaseInsensitiveModels = caseInsensitive
+	if err := backup.RebuildIndex(); err != nil {
+		return nil, err
+	}
+
+	changes := diffModels(s.config.SnapshotModels(), backup.Models)
+	if dryRun {
+		return changes, nil
+	}
+
+	if err := s.db.ReplaceModelConfigs(backup.Models); err != nil {
+		return nil, fmt.Errorf("恢复模型配置失败: %w", err)
+	}
+	if err := s.config.Replace(backup.Models, defaultModel, caseInsensitive); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
 
+// saveModelsToYAML 保存模型列表到YAML文件，格式与模型配置文件相同，可直接用LoadConfig加载
+func (s *ConfigService) saveModelsToYAML(models []*config.ModelConfig, filepath string) error {
+	fileConfig := struct {
+		Models []config.ModelConfig `yaml:"models"`
+	}{
+		Models: make([]config.ModelConfig, 0, len(models)),
+	}
 	for _, model := range models {
-		content += fmt.Sprintf("  - id: %s\n", model.ID)
-		content += fmt.Sprintf("    name: %s\n", model.Name)
-		content += fmt.Sprintf("    target: %s\n", model.Target)
-		content += fmt.Sprintf("    prompt: %v\n", model.PromptValue)
-		content += fmt.Sprintf("    url: %s\n", model.Url)
-		content += fmt.Sprintf("    type: %s\n", model.Type)
-		if model.PromptPath != "" {
-			content += fmt.Sprintf("    prompt_path: %s\n", model.PromptPath)
-		}
-		if model.PromptValueType != "" {
-			content += fmt.Sprintf("    prompt_type: %s\n", model.PromptValueType)
-		}
-		content += "\n"
+		fileConfig.Models = append(fileConfig.Models, *model.Templated())
+	}
+	sort.Slice(fileConfig.Models, func(i, j int) bool {
+		return fileConfig.Models[i].ID < fileConfig.Models[j].ID
+	})
+
+	data, err := yaml.Marshal(fileConfig)
+	if err != nil {
+		return fmt.Errorf("序列化模型配置失败: %w", err)
 	}
 
-	return os.WriteFile(filepath, []byte(content), 0644)
+	header := fmt.Sprintf("# 模型配置备份文件\n# 生成时间: %s\n", time.Now().Format(time.RFC3339))
+	return os.WriteFile(filepath, append([]byte(header), data...), 0644)
 }
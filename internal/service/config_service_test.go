@@ -0,0 +1,324 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func writeWatchConfig(t *testing.T, dir, target string) {
+	t.Helper()
+	content := []byte(`models:
+  - id: "watch-model"
+    name: "监听模型"
+    target: "` + target + `"
+    url: "https://api.openai.com/v1/chat/completions"
+`)
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), content, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+}
+
+func newWatchedConfigService(t *testing.T) (*ConfigService, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeWatchConfig(t, dir, "gpt-4o")
+
+	s, err := NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, dir
+}
+
+// waitForTarget 等待模型的目标模型变为期望值
+func waitForTarget(s *ConfigService, want string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if model, ok := s.GetModel("watch-model"); ok && model.Target == want {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestWatchConfigDirReloadsChanges(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	if err := s.WatchConfigDir(dir, 10*time.Millisecond, 30*time.Millisecond); err != nil {
+		t.Fatalf("启动监听失败: %v", err)
+	}
+
+	writeWatchConfig(t, dir, "gpt-4o-mini")
+	if !waitForTarget(s, "gpt-4o-mini", 2*time.Second) {
+		t.Fatal("配置文件修改后应自动重新加载")
+	}
+
+	// 修改应同步到数据库，重启后仍然生效
+	dbModels, err := s.GetDBManager().GetAllModelConfigs()
+	if err != nil {
+		t.Fatalf("读取数据库失败: %v", err)
+	}
+	if dbModels["watch-model"].Target != "gpt-4o-mini" {
+		t.Errorf("数据库中的配置未更新，实际得到%s", dbModels["watch-model"].Target)
+	}
+}
+
+func TestWatchConfigDirKeepsConfigOnInvalidEdit(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	if err := s.WatchConfigDir(dir, 10*time.Millisecond, 30*time.Millisecond); err != nil {
+		t.Fatalf("启动监听失败: %v", err)
+	}
+
+	// 缺少url的配置验证失败，应保留当前配置
+	broken := []byte("models:\n  - id: \"watch-model\"\n    name: \"监听模型\"\n    target: \"broken\"\n")
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), broken, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	if waitForTarget(s, "broken", 300*time.Millisecond) {
+		t.Fatal("验证失败的配置不应生效")
+	}
+	if _, ok := s.GetModel("watch-model"); !ok {
+		t.Fatal("验证失败后原有模型应保留")
+	}
+
+	// 修复后恢复自动加载
+	writeWatchConfig(t, dir, "gpt-4.1")
+	if !waitForTarget(s, "gpt-4.1", 2*time.Second) {
+		t.Fatal("修复配置后应重新加载")
+	}
+}
+
+func TestReloadFromYAMLChanges(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	if err := s.WatchConfigDir(dir, time.Hour, time.Hour); err != nil {
+		t.Fatalf("启动监听失败: %v", err)
+	}
+
+	writeWatchConfig(t, dir, "gpt-4o-mini")
+	extra := []byte("models:\n  - id: \"extra-model\"\n    name: \"新增模型\"\n    target: \"gpt-4o\"\n    url: \"https://api.openai.com/v1/chat/completions\"\n")
+	if err := os.WriteFile(filepath.Join(dir, "extra.yaml"), extra, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+
+	changes, err := s.ReloadFromYAML(dir)
+	if err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+	if len(changes.Added) != 1 || changes.Added[0] != "extra-model" {
+		t.Errorf("期望新增extra-model，实际%v", changes.Added)
+	}
+	if len(changes.Updated) != 1 || changes.Updated[0] != "watch-model" {
+		t.Errorf("期望更新watch-model，实际%v", changes.Updated)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "extra.yaml")); err != nil {
+		t.Fatalf("删除配置文件失败: %v", err)
+	}
+	changes, err = s.ReloadFromYAML(dir)
+	if err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "extra-model" {
+		t.Errorf("期望删除extra-model，实际%v", changes.Removed)
+	}
+	if _, ok := s.GetModel("extra-model"); ok {
+		t.Error("从文件中删除的模型应从配置中移除")
+	}
+}
+
+func TestReloadFromYAMLKeepsAPIModels(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	if err := s.WatchConfigDir(dir, time.Hour, time.Hour); err != nil {
+		t.Fatalf("启动监听失败: %v", err)
+	}
+
+	apiModel := &config.ModelConfig{ID: "api-model", Name: "API模型", Target: "gpt-4o",
+		Url: "https://api.openai.com/v1/chat/completions", Source: config.ModelSourceAPI}
+	if err := s.SaveModel(apiModel); err != nil {
+		t.Fatalf("保存模型失败: %v", err)
+	}
+	// 升级前保存的模型没有来源，同样不视为来自配置文件
+	legacyModel := &config.ModelConfig{ID: "legacy-model", Name: "旧模型", Target: "gpt-4o",
+		Url: "https://api.openai.com/v1/chat/completions"}
+	if err := s.SaveModel(legacyModel); err != nil {
+		t.Fatalf("保存模型失败: %v", err)
+	}
+
+	// 配置文件中出现同名模型时不覆盖通过管理API创建的模型
+	clash := []byte("models:\n  - id: \"api-model\"\n    name: \"文件模型\"\n    target: \"gpt-4o-mini\"\n    url: \"https://api.openai.com/v1/chat/completions\"\n" +
+		"  - id: \"legacy-model\"\n    name: \"文件模型\"\n    target: \"gpt-4o-mini\"\n    url: \"https://api.openai.com/v1/chat/completions\"\n")
+	if err := os.WriteFile(filepath.Join(dir, "clash.yaml"), clash, 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	changes, err := s.ReloadFromYAML(dir)
+	if err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+	if !changes.Empty() {
+		t.Errorf("期望没有变化，实际%s", changes)
+	}
+	if model, _ := s.GetModel("api-model"); model.Target != "gpt-4o" || model.Source != config.ModelSourceAPI {
+		t.Errorf("通过管理API创建的模型不应被覆盖，实际得到%+v", model)
+	}
+	if model, _ := s.GetModel("legacy-model"); model.Target != "gpt-4o" {
+		t.Errorf("来源未知的模型不应被覆盖，实际得到%+v", model)
+	}
+
+	// 文件中的同名模型被删除时也不删除API模型
+	if err := os.Remove(filepath.Join(dir, "clash.yaml")); err != nil {
+		t.Fatalf("删除配置文件失败: %v", err)
+	}
+	if _, err := s.ReloadFromYAML(dir); err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+	if _, ok := s.GetModel("api-model"); !ok {
+		t.Error("通过管理API创建的模型不应被删除")
+	}
+	if _, ok := s.GetModel("legacy-model"); !ok {
+		t.Error("来源未知的模型不应被删除")
+	}
+	if model, _ := s.GetModel("watch-model"); model.Source != config.ModelSourceYAML {
+		t.Errorf("期望配置文件中的模型来源为yaml，实际得到%q", model.Source)
+	}
+}
+
+func TestBackupToYAMLRoundTrip(t *testing.T) {
+	model := &config.ModelConfig{
+		ID:         "backup-model",
+		Name:       "备份模型",
+		Target:     "gpt-4o",
+		Prompt:     "系统提示: 保持简洁",
+		Url:        "https://api.openai.com/v1/chat/completions",
+		Type:       config.ModelTypeChat,
+		PromptPath: "messages.-1",
+		PromptValue: map[string]interface{}{
+			"role":    "system",
+			"content": "你是一个专业助手: 回答要简洁",
+		},
+		PromptValueType: config.ValueTypeObject,
+		CacheTTL:        60,
+		Source:          config.ModelSourceYAML, // 从配置目录加载的模型来源总是yaml
+	}
+	if err := model.Validate(); err != nil {
+		t.Fatalf("模型配置无效: %v", err)
+	}
+	s := &ConfigService{config: &config.Config{Models: map[string]*config.ModelConfig{model.ID: model}}}
+
+	backupDir := t.TempDir()
+	if err := s.BackupToYAML(backupDir); err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+
+	restored, err := config.LoadConfig(backupDir)
+	if err != nil {
+		t.Fatalf("加载备份失败: %v", err)
+	}
+	got, ok := restored.GetModel(model.ID)
+	if !ok {
+		t.Fatal("备份中缺少模型")
+	}
+	if !reflect.DeepEqual(got, model) {
+		t.Errorf("备份加载后的模型不一致\n got: %+v\nwant: %+v", got, model)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	backupDir := filepath.Join(dir, "backup")
+
+	path, err := s.BackupFile(backupDir)
+	if err != nil {
+		t.Fatalf("备份失败: %v", err)
+	}
+	name := filepath.Base(path)
+
+	// 备份后修改配置：更新已有模型并新增一个模型
+	updated := *s.config.Models["watch-model"]
+	updated.Target = "gpt-4o-mini"
+	if err := s.UpdateModel(&updated); err != nil {
+		t.Fatalf("更新模型失败: %v", err)
+	}
+	extra := &config.ModelConfig{ID: "extra-model", Name: "新增模型", Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions"}
+	if err := extra.Validate(); err != nil {
+		t.Fatalf("模型配置无效: %v", err)
+	}
+	if err := s.SaveModel(extra); err != nil {
+		t.Fatalf("新增模型失败: %v", err)
+	}
+
+	changes, err := s.RestoreBackup(backupDir, name, true)
+	if err != nil {
+		t.Fatalf("预检失败: %v", err)
+	}
+	if len(changes.Updated) != 1 || len(changes.Removed) != 1 || changes.Removed[0] != "extra-model" {
+		t.Errorf("预检差异不正确: %s", changes)
+	}
+	if _, ok := s.GetModel("extra-model"); !ok {
+		t.Fatal("预检不应修改当前配置")
+	}
+
+	if _, err := s.RestoreBackup(backupDir, name, false); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if _, ok := s.GetModel("extra-model"); ok {
+		t.Error("恢复后备份中不存在的模型应被删除")
+	}
+	if model, _ := s.GetModel("watch-model"); model.Target != "gpt-4o" {
+		t.Errorf("恢复后模型应回到备份时的配置，实际目标模型%s", model.Target)
+	}
+	dbModels, err := s.GetDBManager().GetAllModelConfigs()
+	if err != nil {
+		t.Fatalf("读取数据库失败: %v", err)
+	}
+	if len(dbModels) != 1 || dbModels["watch-model"].Target != "gpt-4o" {
+		t.Errorf("数据库中的配置未恢复: %v", dbModels)
+	}
+}
+
+func TestRestoreRejectsInvalidBackup(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	backupDir := filepath.Join(dir, "backup")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatalf("创建备份目录失败: %v", err)
+	}
+	broken := []byte("models:\n  - id: \"broken\"\n    name: \"缺少URL\"\n    target: \"gpt-4o\"\n")
+	if err := os.WriteFile(filepath.Join(backupDir, "broken.yaml"), broken, 0644); err != nil {
+		t.Fatalf("写入备份文件失败: %v", err)
+	}
+
+	if _, err := s.RestoreBackup(backupDir, "broken.yaml", false); err == nil {
+		t.Fatal("验证失败的备份不应恢复")
+	}
+	if _, ok := s.GetModel("watch-model"); !ok {
+		t.Error("恢复失败时应保留当前配置")
+	}
+	for _, name := range []string{"../models.yaml", "", "backup.txt"} {
+		if _, err := s.RestoreBackup(backupDir, name, true); err == nil {
+			t.Errorf("应拒绝无效的备份文件名: %q", name)
+		}
+	}
+}
+
+func TestReloadConfigReportsAliasConflict(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+	// 绕过管理API直接写入数据库，模拟数据库中已有别名冲突的模型
+	for _, id := range []string{"a", "b"} {
+		model := &config.ModelConfig{ID: id, Name: id, Target: "gpt-4o", Url: "https://api.openai.com/v1/chat/completions", Aliases: []string{"shared"}}
+		if err := model.Validate(); err != nil {
+			t.Fatalf("验证失败: %v", err)
+		}
+		if err := s.db.SaveModelConfig(model); err != nil {
+			t.Fatalf("保存模型配置失败: %v", err)
+		}
+	}
+
+	if err := s.ReloadConfig(dir); err == nil {
+		t.Fatal("别名冲突时重新加载应返回错误")
+	}
+}
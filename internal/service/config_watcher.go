@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileState 配置文件的修改时间和大小，用于判断文件是否变化
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// ConfigWatcher 定期扫描配置目录中的YAML文件，文件变化并稳定debounce时长后触发回调
+type ConfigWatcher struct {
+	dir      string
+	interval time.Duration
+	debounce time.Duration
+	onChange func()
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewConfigWatcher 创建配置目录监听器，调用Start后开始监听
+func NewConfigWatcher(dir string, interval, debounce time.Duration, onChange func()) *ConfigWatcher {
+	return &ConfigWatcher{
+		dir:      dir,
+		interval: interval,
+		debounce: debounce,
+		onChange: onChange,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 开始监听，初次扫描失败时返回错误
+func (w *ConfigWatcher) Start() error {
+	last, err := scanConfigDir(w.dir)
+	if err != nil {
+		return err
+	}
+	go w.run(last)
+	return nil
+}
+
+// Close 停止监听并等待监听协程退出
+func (w *ConfigWatcher) Close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// run 监听循环：检测到变化后记录时间，直到文件在debounce时长内不再变化才触发回调，
+// 避免编辑器分多次写入时重复加载半成品文件
+func (w *ConfigWatcher) run(last map[string]fileState) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var changedAt time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := scanConfigDir(w.dir)
+		if err != nil {
+			fmt.Printf("扫描配置目录失败: %v\n", err)
+			continue
+		}
+		if !sameFileStates(last, current) {
+			last = current
+			changedAt = time.Now()
+			continue
+		}
+		if !changedAt.IsZero() && time.Since(changedAt) >= w.debounce {
+			changedAt = time.Time{}
+			w.onChange()
+		}
+	}
+}
+
+// scanConfigDir 获取配置目录中所有YAML文件的状态
+func scanConfigDir(dir string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置目录失败: %w", err)
+	}
+
+	states := make(map[string]fileState)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".yaml") && !strings.HasSuffix(entry.Name(), ".yml") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// 文件在扫描期间被删除
+			continue
+		}
+		states[filepath.Join(dir, entry.Name())] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return states, nil
+}
+
+// sameFileStates 比较两次扫描结果是否一致
+func sameFileStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, state := range a {
+		other, ok := b[name]
+		if !ok || !state.modTime.Equal(other.modTime) || state.size != other.size {
+			return false
+		}
+	}
+	return true
+}
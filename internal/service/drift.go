@@ -0,0 +1,216 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// DriftKind 模型配置不一致的类型
+type DriftKind string
+
+const (
+	DriftConflict DriftKind = "conflict"  // YAML文件和数据库中都有该模型，但内容不同
+	DriftYAMLOnly DriftKind = "yaml_only" // 只在YAML文件中，如通过管理API删除后文件仍保留
+	DriftDBOnly   DriftKind = "db_only"   // 只在数据库中，如通过管理API创建，数据库丢失后无法恢复
+)
+
+// DriftItem 一个模型的不一致情况
+type DriftItem struct {
+	ID     string             `json:"id"`
+	Kind   DriftKind          `json:"kind"`
+	Source config.ModelSource `json:"source"`           // 数据库中记录的模型来源，yaml_only时为yaml
+	Tenant string             `json:"tenant"`           // 模型所属的租户，yaml_only时为文件中的租户
+	Fields []string           `json:"fields,omitempty"` // conflict时内容不同的字段
+}
+
+// DriftReport YAML文件与数据库中模型配置的比较结果
+type DriftReport struct {
+	Policy    string      `json:"policy"`
+	CheckedAt time.Time   `json:"checked_at"`
+	Items     []DriftItem `json:"items"`
+	Applied   []string    `json:"applied"` // 按策略写入或删除的模型ID，只比较不处理时为空
+}
+
+// driftIgnoredFields 比较时忽略的字段，来源由加载方式决定，不属于配置内容
+var driftIgnoredFields = map[string]bool{"source": true}
+
+// compareModels 比较YAML文件与数据库中的模型配置，结果按模型ID排序
+func compareModels(yamlModels, dbModels map[string]*config.ModelConfig) []DriftItem {
+	items := []DriftItem{}
+	for id, yamlModel := range yamlModels {
+		dbModel, exists := dbModels[id]
+		if !exists {
+			items = append(items, DriftItem{ID: id, Kind: DriftYAMLOnly, Source: config.ModelSourceYAML, Tenant: config.TenantOrDefault(yamlModel.Tenant)})
+			continue
+		}
+		if fields := diffModelFields(yamlModel, dbModel); len(fields) > 0 {
+			items = append(items, DriftItem{ID: id, Kind: DriftConflict, Source: dbModel.Source, Tenant: config.TenantOrDefault(dbModel.Tenant), Fields: fields})
+		}
+	}
+	for id, dbModel := range dbModels {
+		if _, exists := yamlModels[id]; !exists {
+			items = append(items, DriftItem{ID: id, Kind: DriftDBOnly, Source: dbModel.Source, Tenant: config.TenantOrDefault(dbModel.Tenant)})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// diffModelFields 返回两个模型配置中内容不同的字段（JSON字段名）。
+// 先转换为JSON再比较，避免YAML与数据库解析出的数值类型不同造成误报；空值与未设置视为相同
+func diffModelFields(a, b *config.ModelConfig) []string {
+	fa, fb := modelFields(a), modelFields(b)
+	var fields []string
+	for name, value := range fa {
+		if !reflect.DeepEqual(value, fb[name]) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range fb {
+		if _, exists := fa[name]; !exists {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// modelFields 将模型配置转换为字段名到值的映射，去掉忽略的字段和空值
+func modelFields(model *config.ModelConfig) map[string]interface{} {
+	fields := make(map[string]interface{})
+	data, err := json.Marshal(model)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fields
+	}
+	for name, value := range fields {
+		if driftIgnoredFields[name] || isEmptyValue(value) {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// isEmptyValue 是否为null、空数组或空对象
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// reconcile 按策略处理YAML文件与数据库中不一致的模型，返回处理后的模型表和写入或删除的模型ID。
+// db_wins和manual不修改数据库；yaml_wins用文件中的配置覆盖冲突的模型、添加只在文件中的模型，
+// 并删除来源为yaml但文件中已不存在的模型，通过管理API创建的模型始终保留
+func (s *ConfigService) reconcile(policy string, items []DriftItem, yamlModels, dbModels map[string]*config.ModelConfig) (map[string]*config.ModelConfig, []string, error) {
+	models := make(map[string]*config.ModelConfig, len(dbModels))
+	for id, model := range dbModels {
+		models[id] = model
+	}
+	applied := []string{}
+	if policy != config.DriftPolicyYAMLWins {
+		return models, applied, nil
+	}
+
+	for _, item := range items {
+		switch {
+		case item.Kind == DriftConflict || item.Kind == DriftYAMLOnly:
+			if err := s.db.SaveModelConfig(yamlModels[item.ID]); err != nil {
+				return nil, nil, fmt.Errorf("保存模型配置 %s 到数据库失败: %w", item.ID, err)
+			}
+			models[item.ID] = yamlModels[item.ID]
+		case item.Kind == DriftDBOnly && item.Source == config.ModelSourceYAML:
+			if err := s.db.DeleteModelConfig(item.ID); err != nil {
+				return nil, nil, fmt.Errorf("从数据库删除模型配置 %s 失败: %w", item.ID, err)
+			}
+			delete(models, item.ID)
+		default:
+			continue
+		}
+		applied = append(applied, item.ID)
+	}
+	return models, applied, nil
+}
+
+// logDrift 打印不一致的模型和处理结果
+func logDrift(report *DriftReport) {
+	if len(report.Items) == 0 {
+		return
+	}
+	for _, item := range report.Items {
+		switch item.Kind {
+		case DriftConflict:
+			fmt.Printf("模型 %s 在YAML文件和数据库中的配置不同，字段: %v\n", item.ID, item.Fields)
+		case DriftYAMLOnly:
+			fmt.Printf("模型 %s 只存在于YAML文件中\n", item.ID)
+		case DriftDBOnly:
+			fmt.Printf("模型 %s 只存在于数据库中（来源: %s）\n", item.ID, item.Source)
+		}
+	}
+	switch report.Policy {
+	case config.DriftPolicyYAMLWins:
+		fmt.Printf("按yaml_wins策略以YAML文件为准，已处理模型: %v\n", report.Applied)
+	case config.DriftPolicyManual:
+		fmt.Printf("警告: 发现 %d 个不一致的模型，drift.policy为manual，继续使用数据库中的配置，请手动处理\n", len(report.Items))
+	default:
+		fmt.Printf("按db_wins策略以数据库为准，忽略YAML文件中的 %d 处不一致\n", len(report.Items))
+	}
+}
+
+// CheckDrift 比较当前YAML文件与数据库中的模型配置，只报告不处理
+func (s *ConfigService) CheckDrift(configDir string) (*DriftReport, error) {
+	dbModels, err := s.db.GetAllModelConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("从数据库加载模型配置失败: %w", err)
+	}
+	yamlConfig, err := config.LoadConfig(configDir)
+	if err != nil {
+		return nil, fmt.Errorf("从YAML文件加载配置失败: %w", err)
+	}
+	return &DriftReport{
+		Policy:    s.driftPolicy,
+		CheckedAt: time.Now(),
+		Items:     compareModels(yamlConfig.Models, dbModels),
+		Applied:   []string{},
+	}, nil
+}
+
+// InScope 返回只包含范围内模型的比较结果，report为nil时返回nil
+func (r *DriftReport) InScope(scope TenantScope) *DriftReport {
+	if r == nil || scope.IsGlobal() {
+		return r
+	}
+	scoped := &DriftReport{Policy: r.Policy, CheckedAt: r.CheckedAt, Items: []DriftItem{}, Applied: []string{}}
+	inScope := make(map[string]bool)
+	for _, item := range r.Items {
+		if scope.Allows(item.Tenant) {
+			scoped.Items = append(scoped.Items, item)
+			inScope[item.ID] = true
+		}
+	}
+	for _, id := range r.Applied {
+		if inScope[id] {
+			scoped.Applied = append(scoped.Applied, id)
+		}
+	}
+	return scoped
+}
+
+// LastReconcile 最近一次加载配置时的比较和处理结果，从未比较过（如首次从YAML文件迁移）时返回nil
+func (s *ConfigService) LastReconcile() *DriftReport {
+	s.driftMutex.RLock()
+	defer s.driftMutex.RUnlock()
+	return s.lastDrift
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// writeDriftConfig 写入包含给定模型（ID到目标模型）的YAML配置文件
+func writeDriftConfig(t *testing.T, dir string, targets map[string]string) {
+	t.Helper()
+	content := "models:\n"
+	for id, target := range targets {
+		content += "  - id: \"" + id + "\"\n    name: \"" + id + "\"\n    target: \"" + target + "\"\n" +
+			"    url: \"https://api.openai.com/v1/chat/completions\"\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "models.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+}
+
+// openDriftService 使用指定策略打开配置目录，测试结束时关闭
+func openDriftService(t *testing.T, dir, policy string) *ConfigService {
+	t.Helper()
+	s, err := NewConfigServiceWithDatabase(dir, config.DatabaseConfig{}, policy)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// seedDriftDir 创建配置目录并从YAML文件迁移到数据库，返回目录
+func seedDriftDir(t *testing.T, targets map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeDriftConfig(t, dir, targets)
+	s, err := NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	if s.LastReconcile() != nil {
+		t.Error("首次从YAML文件迁移时不应进行一致性检查")
+	}
+	s.Close()
+	return dir
+}
+
+func driftKinds(report *DriftReport) map[string]DriftKind {
+	kinds := make(map[string]DriftKind)
+	for _, item := range report.Items {
+		kinds[item.ID] = item.Kind
+	}
+	return kinds
+}
+
+func TestLoadConfigNoDrift(t *testing.T) {
+	dir := seedDriftDir(t, map[string]string{"m1": "gpt-4o"})
+	s := openDriftService(t, dir, config.DriftPolicyDBWins)
+
+	report := s.LastReconcile()
+	if report == nil || len(report.Items) != 0 || len(report.Applied) != 0 {
+		t.Fatalf("YAML文件与数据库一致时不应有差异，实际%+v", report)
+	}
+}
+
+func TestLoadConfigConflict(t *testing.T) {
+	tests := []struct {
+		policy     string
+		wantTarget string
+		applied    []string
+	}{
+		{config.DriftPolicyDBWins, "gpt-4o", []string{}},
+		{config.DriftPolicyManual, "gpt-4o", []string{}},
+		{config.DriftPolicyYAMLWins, "gpt-4.1", []string{"m1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			dir := seedDriftDir(t, map[string]string{"m1": "gpt-4o"})
+			writeDriftConfig(t, dir, map[string]string{"m1": "gpt-4.1"})
+			s := openDriftService(t, dir, tt.policy)
+
+			report := s.LastReconcile()
+			if len(report.Items) != 1 || report.Items[0].Kind != DriftConflict {
+				t.Fatalf("期望m1冲突，实际%+v", report.Items)
+			}
+			if !reflect.DeepEqual(report.Items[0].Fields, []string{"target"}) {
+				t.Errorf("冲突字段应为target，实际%v", report.Items[0].Fields)
+			}
+			if !reflect.DeepEqual(report.Applied, tt.applied) {
+				t.Errorf("applied = %v, want %v", report.Applied, tt.applied)
+			}
+
+			model, _ := s.GetModel("m1")
+			if model.Target != tt.wantTarget {
+				t.Errorf("内存中的目标模型 = %s, want %s", model.Target, tt.wantTarget)
+			}
+			dbModels, err := s.GetDBManager().GetAllModelConfigs()
+			if err != nil {
+				t.Fatalf("读取数据库失败: %v", err)
+			}
+			if dbModels["m1"].Target != tt.wantTarget {
+				t.Errorf("数据库中的目标模型 = %s, want %s", dbModels["m1"].Target, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDeletedModelDoesNotReappear(t *testing.T) {
+	dir := seedDriftDir(t, map[string]string{"m1": "gpt-4o", "m2": "gpt-4o"})
+	s, err := NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	if err := s.DeleteModel("m2"); err != nil {
+		t.Fatalf("删除模型失败: %v", err)
+	}
+	s.Close()
+
+	for _, policy := range []string{config.DriftPolicyDBWins, config.DriftPolicyManual} {
+		s := openDriftService(t, dir, policy)
+		if _, ok := s.GetModel("m2"); ok {
+			t.Errorf("%s: 通过管理API删除的模型不应在重启后重新出现", policy)
+		}
+		if kinds := driftKinds(s.LastReconcile()); kinds["m2"] != DriftYAMLOnly {
+			t.Errorf("%s: m2应报告为yaml_only，实际%v", policy, kinds)
+		}
+		s.Close()
+	}
+
+	s = openDriftService(t, dir, config.DriftPolicyYAMLWins)
+	if _, ok := s.GetModel("m2"); !ok {
+		t.Error("yaml_wins应加载只在YAML文件中的模型")
+	}
+	report, err := s.CheckDrift(dir)
+	if err != nil {
+		t.Fatalf("检查差异失败: %v", err)
+	}
+	if len(report.Items) != 0 {
+		t.Errorf("yaml_wins处理后应没有差异，实际%+v", report.Items)
+	}
+}
+
+func TestLoadConfigDBOnlyModels(t *testing.T) {
+	dir := seedDriftDir(t, map[string]string{"m1": "gpt-4o", "m2": "gpt-4o"})
+	s, err := NewConfigService(dir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	apiModel := &config.ModelConfig{ID: "api-model", Name: "API模型", Target: "gpt-4o",
+		Url: "https://api.openai.com/v1/chat/completions", Source: config.ModelSourceAPI}
+	if err := s.SaveModel(apiModel); err != nil {
+		t.Fatalf("创建模型失败: %v", err)
+	}
+	s.Close()
+
+	// m2从YAML文件中移除
+	writeDriftConfig(t, dir, map[string]string{"m1": "gpt-4o"})
+
+	s = openDriftService(t, dir, config.DriftPolicyDBWins)
+	report := s.LastReconcile()
+	want := map[string]DriftKind{"api-model": DriftDBOnly, "m2": DriftDBOnly}
+	if kinds := driftKinds(report); !reflect.DeepEqual(kinds, want) {
+		t.Errorf("差异 = %v, want %v", kinds, want)
+	}
+	if report.Items[0].Source != config.ModelSourceAPI || report.Items[1].Source != config.ModelSourceYAML {
+		t.Errorf("应报告模型来源，实际%+v", report.Items)
+	}
+	if _, ok := s.GetModel("m2"); !ok {
+		t.Error("db_wins应保留只在数据库中的模型")
+	}
+	s.Close()
+
+	s = openDriftService(t, dir, config.DriftPolicyYAMLWins)
+	if !reflect.DeepEqual(s.LastReconcile().Applied, []string{"m2"}) {
+		t.Errorf("yaml_wins应只删除YAML来源的m2，实际%v", s.LastReconcile().Applied)
+	}
+	if _, ok := s.GetModel("m2"); ok {
+		t.Error("yaml_wins应删除文件中已移除的YAML来源模型")
+	}
+	if _, ok := s.GetModel("api-model"); !ok {
+		t.Error("通过管理API创建的模型应始终保留")
+	}
+}
+
+func TestLoadConfigFailsOnDBError(t *testing.T) {
+	dir := seedDriftDir(t, map[string]string{"m1": "gpt-4o"})
+	s := openDriftService(t, dir, config.DriftPolicyDBWins)
+	if err := s.GetDBManager().Close(); err != nil {
+		t.Fatalf("关闭数据库失败: %v", err)
+	}
+
+	// 读取数据库失败时不能退回到从YAML文件迁移
+	writeDriftConfig(t, dir, map[string]string{"m1": "gpt-4.1", "m3": "gpt-4o"})
+	if err := s.LoadConfig(dir); err == nil {
+		t.Fatal("读取数据库失败时应返回错误")
+	}
+	if _, ok := s.GetModel("m3"); ok {
+		t.Error("读取数据库失败时不应加载YAML文件中的模型")
+	}
+	if model, _ := s.GetModel("m1"); model.Target != "gpt-4o" {
+		t.Errorf("读取数据库失败时应保留当前配置，实际目标模型%s", model.Target)
+	}
+}
+
+func TestCompareModelsIgnoresEquivalentValues(t *testing.T) {
+	yamlModel := &config.ModelConfig{ID: "m", PromptValue: map[string]interface{}{"n": 1}, Aliases: []string{}, Source: config.ModelSourceYAML}
+	dbModel := &config.ModelConfig{ID: "m", PromptValue: map[string]interface{}{"n": float64(1)}, Source: config.ModelSourceAPI}
+
+	items := compareModels(map[string]*config.ModelConfig{"m": yamlModel}, map[string]*config.ModelConfig{"m": dbModel})
+	if len(items) != 0 {
+		t.Errorf("数值类型、空列表和来源不同不应视为冲突，实际%+v", items)
+	}
+}
+
+func TestDriftReportInScope(t *testing.T) {
+	yamlModels := map[string]*config.ModelConfig{
+		"a": {ID: "a", Name: "A"},
+		"b": {ID: "b", Name: "B", Tenant: "team-a"},
+	}
+	dbModels := map[string]*config.ModelConfig{
+		"b": {ID: "b", Name: "B2", Tenant: "team-a"},
+		"c": {ID: "c", Name: "C", Tenant: config.DefaultTenant},
+	}
+	report := &DriftReport{Items: compareModels(yamlModels, dbModels), Applied: []string{"a", "b"}}
+
+	scoped := report.InScope("team-a")
+	if len(scoped.Items) != 1 || scoped.Items[0].ID != "b" || !reflect.DeepEqual(scoped.Applied, []string{"b"}) {
+		t.Errorf("team-a范围内的差异 = %+v", scoped)
+	}
+	if all := report.InScope(GlobalScope); len(all.Items) != 3 {
+		t.Errorf("全局范围应包含全部差异，实际%+v", all.Items)
+	}
+	if (*DriftReport)(nil).InScope("team-a") != nil {
+		t.Error("没有比较结果时应返回nil")
+	}
+}
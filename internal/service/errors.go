@@ -0,0 +1,22 @@
+package service
+
+import "errors"
+
+// 服务层的哨兵错误，处理器通过errors.Is映射为稳定的错误码，不依赖错误信息文本
+var (
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	ErrUserDisabled       = errors.New("用户已被禁用")
+	ErrUserLocked         = errors.New("连续登录失败次数过多，账户已锁定")
+	ErrAlreadyInstalled   = errors.New("系统已初始化，不允许注册新用户")
+	ErrUserExists         = errors.New("用户名已存在")
+	ErrUserNotFound       = errors.New("用户不存在")
+	ErrWrongOldPassword   = errors.New("旧密码错误")
+	ErrAPIKeyNotFound     = errors.New("API Key不存在或无权限操作")
+	ErrModelNotFound      = errors.New("模型配置未找到")
+	ErrInvalidRole        = errors.New("不支持的用户角色")
+	ErrSessionNotFound    = errors.New("会话不存在")
+	ErrSessionRevoked     = errors.New("会话已撤销")
+	ErrWebhookNotFound    = errors.New("Webhook不存在")
+	ErrInvalidWebhook     = errors.New("Webhook配置无效")
+	ErrInvalidCatalog     = errors.New("模型目录无效")
+)
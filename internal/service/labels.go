@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// MaxLabels 单个API Key最多的标签数
+const MaxLabels = 16
+
+var (
+	// labelKeyPattern 标签名：小写字母开头，可在日志格式中作为$变量引用
+	labelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+	// labelValuePattern 标签值
+	labelValuePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.\-]{0,62}$`)
+)
+
+// ValidateLabels 验证标签名和标签值的长度和字符集
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("标签数量不能超过%d个", MaxLabels)
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("无效的标签名 %q: 须以小写字母开头，只能包含小写字母、数字和下划线，最长32个字符", key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("无效的标签值 %s=%q: 只能包含字母、数字、下划线、点和连字符，最长63个字符", key, value)
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector 解析key:value形式的标签过滤条件，多个条件需同时满足
+func ParseLabelSelector(values []string) (map[string]string, error) {
+	selector := make(map[string]string, len(values))
+	for _, value := range values {
+		key, labelValue, ok := strings.Cut(value, ":")
+		if !ok || key == "" || labelValue == "" {
+			return nil, fmt.Errorf("无效的标签过滤条件 %q，格式应为key:value", value)
+		}
+		selector[key] = labelValue
+	}
+	return selector, nil
+}
+
+// filterAPIKeysByLabels 返回包含selector中全部标签的API Key
+func filterAPIKeysByLabels(apiKeys []db.APIKey, selector map[string]string) []db.APIKey {
+	if len(selector) == 0 {
+		return apiKeys
+	}
+	filtered := make([]db.APIKey, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		if apiKey.HasLabels(selector) {
+			filtered = append(filtered, apiKey)
+		}
+	}
+	return filtered
+}
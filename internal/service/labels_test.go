@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestValidateLabels(t *testing.T) {
+	valid := map[string]string{"team": "search", "env": "prod-1.2", "cost_center": "A_01"}
+	if err := ValidateLabels(valid); err != nil {
+		t.Errorf("期望标签有效，实际得到错误: %v", err)
+	}
+
+	invalid := []map[string]string{
+		{"Team": "search"},
+		{"1team": "search"},
+		{"team-name": "search"},
+		{"team": ""},
+		{"team": "-search"},
+		{"team": "search team"},
+	}
+	for _, labels := range invalid {
+		if err := ValidateLabels(labels); err == nil {
+			t.Errorf("期望标签%v无效", labels)
+		}
+	}
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxLabels; i++ {
+		tooMany["k"+string(rune('a'+i))] = "v"
+	}
+	if err := ValidateLabels(tooMany); err == nil {
+		t.Error("期望标签数量超过上限时返回错误")
+	}
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	selector, err := ParseLabelSelector([]string{"team:search", "env:prod"})
+	if err != nil {
+		t.Fatalf("解析标签过滤条件失败: %v", err)
+	}
+	if len(selector) != 2 || selector["team"] != "search" || selector["env"] != "prod" {
+		t.Errorf("解析结果不正确: %v", selector)
+	}
+
+	for _, value := range []string{"team", "team:", ":search"} {
+		if _, err := ParseLabelSelector([]string{value}); err == nil {
+			t.Errorf("期望过滤条件%q无效", value)
+		}
+	}
+}
+
+func TestListAPIKeysByLabels(t *testing.T) {
+	s := newTestAuthService(t)
+	resp, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+	userID := resp.User.ID
+
+	keys := []struct {
+		name   string
+		labels map[string]string
+	}{
+		{"search-prod", map[string]string{"team": "search", "env": "prod"}},
+		{"search-dev", map[string]string{"team": "search", "env": "dev"}},
+		{"ads-prod", map[string]string{"team": "ads", "env": "prod"}},
+		{"unlabeled", nil},
+	}
+	for _, key := range keys {
+		if _, err := s.CreateAPIKey(userID, key.name, "sk-"+key.name, "", nil, key.labels); err != nil {
+			t.Fatalf("创建API Key %s失败: %v", key.name, err)
+		}
+	}
+
+	if _, err := s.CreateAPIKey(userID, "bad", "sk-bad", "", nil, map[string]string{"Team": "x"}); err == nil {
+		t.Error("期望创建带无效标签的API Key时返回错误")
+	}
+
+	cases := []struct {
+		selector map[string]string
+		want     int
+	}{
+		{nil, 4},
+		{map[string]string{"team": "search"}, 2},
+		{map[string]string{"team": "search", "env": "prod"}, 1},
+		{map[string]string{"team": "billing"}, 0},
+	}
+	for _, tc := range cases {
+		apiKeys, err := s.GetAPIKeysByUserID(userID, tc.selector)
+		if err != nil {
+			t.Fatalf("获取API Key列表失败: %v", err)
+		}
+		if len(apiKeys) != tc.want {
+			t.Errorf("过滤条件%v期望返回%d个API Key，实际得到%d个", tc.selector, tc.want, len(apiKeys))
+		}
+	}
+
+	all, err := s.GetAllAPIKeys(GlobalScope, map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatalf("获取全部API Key失败: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("期望返回2个prod环境的API Key，实际得到%d个", len(all))
+	}
+	for _, apiKey := range all {
+		if apiKey.User.Username != "admin" {
+			t.Errorf("期望全局列表包含所属用户信息，实际得到%+v", apiKey.User)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// 连续登录失败后锁定账户的默认配置
+const (
+	DefaultMaxFailedLogins = 5                // 连续失败多少次后锁定
+	DefaultLockoutDuration = 15 * time.Minute // 锁定时长
+)
+
+// SetLockoutPolicy 设置连续登录失败maxAttempts次后锁定账户lockout时长，maxAttempts为0时不锁定
+func (s *AuthService) SetLockoutPolicy(maxAttempts int, lockout time.Duration) {
+	s.lockoutMutex.Lock()
+	defer s.lockoutMutex.Unlock()
+	s.maxFailedLogins = maxAttempts
+	s.lockoutDuration = lockout
+}
+
+// lockoutPolicy 获取当前的账户锁定配置
+func (s *AuthService) lockoutPolicy() (int, time.Duration) {
+	s.lockoutMutex.RLock()
+	defer s.lockoutMutex.RUnlock()
+	return s.maxFailedLogins, s.lockoutDuration
+}
+
+// checkLocked 用户处于锁定期内时返回ErrUserLocked，细节为锁定到期时间
+func checkLocked(user *db.User, now time.Time) error {
+	if user.LockedUntil != nil && now.Before(*user.LockedUntil) {
+		return fmt.Errorf("%w: %s", ErrUserLocked, user.LockedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// recordFailedLogin 记录一次密码错误，达到次数上限时锁定账户并返回ErrUserLocked，否则返回ErrInvalidCredentials
+func (s *AuthService) recordFailedLogin(user *db.User) error {
+	maxAttempts, lockout := s.lockoutPolicy()
+	lockedUntil, err := s.dbManager.RecordFailedLogin(user.ID, maxAttempts, lockout)
+	if err != nil {
+		// 记录错误但不影响登录结果
+		fmt.Printf("记录用户 %s 登录失败次数失败: %v\n", user.Username, err)
+		return ErrInvalidCredentials
+	}
+	if lockedUntil != nil {
+		fmt.Printf("用户 %s 连续 %d 次登录失败，账户锁定到 %s\n", user.Username, maxAttempts, lockedUntil.Format(time.RFC3339))
+		return fmt.Errorf("%w: %s", ErrUserLocked, lockedUntil.Format(time.RFC3339))
+	}
+	return ErrInvalidCredentials
+}
+
+// UnlockUser 解除用户的账户锁定并清零登录失败次数
+func (s *AuthService) UnlockUser(userID uint) error {
+	if _, err := s.dbManager.GetUserByID(userID); err != nil {
+		return ErrUserNotFound
+	}
+	return s.dbManager.UnlockUser(userID)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+// defaultCatalog 内置的默认模型目录，格式与模型配置文件相同
+//
+//go:embed catalog/default_models.yaml
+var defaultCatalog []byte
+
+// DefaultCatalog 返回内置的默认模型目录
+func DefaultCatalog() []byte {
+	return defaultCatalog
+}
+
+// SeedResult 导入模型目录的结果
+type SeedResult struct {
+	Created []string `json:"created"` // 新创建的模型ID
+	Skipped []string `json:"skipped"` // 已存在而跳过的模型ID
+}
+
+// SeedModels 导入模型目录中尚不存在的模型，已存在的模型ID（包括与别名相同的）保持不变，
+// 重复导入不会创建新模型。导入的模型来源为api，重新加载YAML文件时不受影响；
+// 目录中全部模型通过验证后才开始导入
+func (s *ConfigService) SeedModels(catalog []byte) (*SeedResult, error) {
+	parsed, err := config.ParseConfig(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCatalog, err)
+	}
+
+	ids := make([]string, 0, len(parsed.Models))
+	for id := range parsed.Models {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := &SeedResult{Created: []string{}, Skipped: []string{}}
+	for _, id := range ids {
+		if _, exists := s.config.GetModel(id); exists {
+			result.Skipped = append(result.Skipped, id)
+			continue
+		}
+		model := parsed.Models[id]
+		model.Source = config.ModelSourceAPI
+		if err := s.SaveModel(model); err != nil {
+			return result, fmt.Errorf("导入模型 %s 失败: %w", id, err)
+		}
+		result.Created = append(result.Created, id)
+	}
+	return result, nil
+}
+
+// SeedDefaultModels 导入内置的默认模型目录
+func (s *ConfigService) SeedDefaultModels() (*SeedResult, error) {
+	return s.SeedModels(defaultCatalog)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestSeedDefaultModelsIdempotent(t *testing.T) {
+	s, dir := newWatchedConfigService(t)
+
+	catalog, err := config.ParseConfig(DefaultCatalog())
+	if err != nil {
+		t.Fatalf("内置模型目录无效: %v", err)
+	}
+	if len(catalog.Models) == 0 {
+		t.Fatal("内置模型目录不应为空")
+	}
+
+	first, err := s.SeedDefaultModels()
+	if err != nil {
+		t.Fatalf("导入默认模型失败: %v", err)
+	}
+	if len(first.Created) != len(catalog.Models) || len(first.Skipped) != 0 {
+		t.Errorf("首次导入应创建目录中的全部模型，实际%+v", first)
+	}
+	for _, id := range first.Created {
+		model, ok := s.GetModel(id)
+		if !ok || model.Source != config.ModelSourceAPI || model.Prompt != "" {
+			t.Errorf("模型%s应以api来源创建且Prompt为空，实际%+v", id, model)
+		}
+	}
+
+	second, err := s.SeedDefaultModels()
+	if err != nil {
+		t.Fatalf("再次导入默认模型失败: %v", err)
+	}
+	if len(second.Created) != 0 || !reflect.DeepEqual(second.Skipped, first.Created) {
+		t.Errorf("重复导入不应创建新模型，实际%+v", second)
+	}
+
+	// 导入的模型保存在数据库中，重新加载YAML文件后仍然存在
+	if _, err := s.ReloadFromYAML(dir); err != nil {
+		t.Fatalf("重新加载配置失败: %v", err)
+	}
+	if _, ok := s.GetModel(first.Created[0]); !ok {
+		t.Errorf("重新加载YAML文件后导入的模型%s不应被删除", first.Created[0])
+	}
+}
+
+func TestSeedModelsSkipsExisting(t *testing.T) {
+	s, _ := newWatchedConfigService(t)
+	catalog := []byte(`models:
+  - id: "watch-model"
+    name: "覆盖"
+    target: "other"
+    url: "https://example.com/v1/chat/completions"
+  - id: "custom"
+    name: "自定义"
+    target: "custom-model"
+    url: "https://example.com/v1/chat/completions"
+`)
+	result, err := s.SeedModels(catalog)
+	if err != nil {
+		t.Fatalf("导入模型目录失败: %v", err)
+	}
+	if !reflect.DeepEqual(result.Created, []string{"custom"}) || !reflect.DeepEqual(result.Skipped, []string{"watch-model"}) {
+		t.Errorf("导入结果 = %+v", result)
+	}
+	if model, _ := s.GetModel("watch-model"); model.Target != "gpt-4o" {
+		t.Errorf("已存在的模型不应被覆盖，实际target为%s", model.Target)
+	}
+
+	if _, err := s.SeedModels([]byte("models:\n  - id: \"broken\"\n")); err == nil {
+		t.Error("目录中的模型配置无效时应返回错误")
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// 服务器运行期间定期刷新锁文件的修改时间，超过ServerLockStale未刷新的锁文件视为服务器已异常退出
+const (
+	serverLockHeartbeat = 10 * time.Second
+	ServerLockStale     = 3 * serverLockHeartbeat
+)
+
+// ErrServerRunning 数据库正被运行中的服务器使用
+var ErrServerRunning = errors.New("数据库正被运行中的服务器使用")
+
+// ServerLock 服务器运行期间在SQLite数据库文件旁保存的锁文件，离线命令据此判断数据库是否正在使用
+type ServerLock struct {
+	path string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ServerLockPath 返回数据库对应的锁文件路径；只有SQLite数据库需要锁文件，其他数据库返回空字符串
+func ServerLockPath(configDir string, dbConfig config.DatabaseConfig) string {
+	if dbConfig.DSN == "" {
+		return filepath.Join(configDir, "db", "config.db.lock")
+	}
+	driver, source, err := db.ParseDSN(dbConfig.DSN)
+	if err != nil || driver != db.DriverSQLite {
+		return ""
+	}
+	// 去掉sqlite连接串中的参数
+	source, _, _ = strings.Cut(source, "?")
+	return source + ".lock"
+}
+
+// AcquireServerLock 写入锁文件并在后台定期刷新，直到调用Release；path为空时返回nil
+func AcquireServerLock(path string) (*ServerLock, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("写入服务器锁文件失败: %w", err)
+	}
+
+	lock := &ServerLock{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+	go lock.heartbeat()
+	return lock, nil
+}
+
+// heartbeat 定期刷新锁文件的修改时间
+func (l *ServerLock) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(serverLockHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// Release 停止刷新并删除锁文件
+func (l *ServerLock) Release() error {
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// CheckServerLock 锁文件存在且在ServerLockStale内刷新过时返回ErrServerRunning
+func CheckServerLock(path string) error {
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if time.Since(info.ModTime()) > ServerLockStale {
+		return nil
+	}
+	pid, _ := os.ReadFile(path)
+	return fmt.Errorf("%w (pid %s, 锁文件 %s)", ErrServerRunning, strings.TrimSpace(string(pid)), path)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// sessionTouchInterval 同一会话的最后访问时间最多每隔多久写一次数据库
+const sessionTouchInterval = time.Minute
+
+// SessionInfo 登录会话信息
+type SessionInfo struct {
+	JTI        string     `json:"jti"`
+	UserID     uint       `json:"user_id"`
+	Username   string     `json:"username"`
+	IssuedAt   time.Time  `json:"issued_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	LastSeenIP string     `json:"last_seen_ip"`
+	Current    bool       `json:"current"` // 是否为发起本次请求的会话
+}
+
+// newSessionID 生成token的jti
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成会话ID失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// checkSession 检查token对应的会话是否有效，会话已撤销或已删除（如用户被删除）时返回ErrSessionRevoked；
+// 升级前签发的token没有jti，不做检查
+func (s *AuthService) checkSession(claims *Claims) error {
+	if claims.ID == "" {
+		return nil
+	}
+	session, err := s.dbManager.GetSession(claims.ID)
+	if errors.Is(err, db.ErrSessionNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// refreshClaims 按用户的当前状态刷新token中的角色、租户和临时密码标记，
+// 使降级、移出租户等修改立即生效；用户已删除（或无法读取）时返回ErrSessionRevoked，已禁用时返回ErrUserDisabled
+func (s *AuthService) refreshClaims(claims *Claims) error {
+	user, err := s.dbManager.GetUserByID(claims.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionRevoked, err)
+	}
+	if !user.IsEnabled {
+		return ErrUserDisabled
+	}
+	claims.Username = user.Username
+	claims.Role = user.Role
+	if claims.Role == "" {
+		claims.Role = db.RoleFromAdmin(user.IsAdmin)
+	}
+	claims.IsAdmin = claims.Role == db.RoleSuperuser
+	claims.TenantID = user.TenantID
+	claims.Global = user.Global
+	claims.MustChangePassword = user.MustChangePassword
+	return nil
+}
+
+// TouchSession 记录会话的最后访问时间和客户端IP
+func (s *AuthService) TouchSession(jti, clientIP string) error {
+	return s.dbManager.TouchSession(jti, clientIP, time.Now(), sessionTouchInterval)
+}
+
+// ListSessions 获取范围内未过期且未撤销的会话，userID为0时返回所有用户的会话；
+// 租户管理员只能看到所属租户中非全局管理员用户的会话
+func (s *AuthService) ListSessions(scope TenantScope, userID uint) ([]SessionInfo, error) {
+	sessions, err := s.dbManager.ListActiveSessions(userID, scope.Filter(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, SessionInfo{
+			JTI:        session.JTI,
+			UserID:     session.UserID,
+			Username:   session.Username,
+			IssuedAt:   session.IssuedAt,
+			ExpiresAt:  session.ExpiresAt,
+			LastSeenAt: session.LastSeenAt,
+			LastSeenIP: session.LastSeenIP,
+		})
+	}
+	return infos, nil
+}
+
+// RevokeSession 撤销会话，使对应的token立即失效；userID不为0时只能撤销该用户自己的会话，
+// 租户管理员只能撤销所属租户中非全局管理员用户的会话，其他会话视为不存在
+func (s *AuthService) RevokeSession(scope TenantScope, jti string, userID uint) error {
+	session, err := s.dbManager.GetSession(jti)
+	if errors.Is(err, db.ErrSessionNotFound) || (err == nil && userID != 0 && session.UserID != userID) {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, jti)
+	}
+	if err != nil {
+		return err
+	}
+	if !scope.IsGlobal() {
+		user, err := s.dbManager.GetUserByID(session.UserID)
+		if err != nil || user.Global || !scope.Allows(user.TenantID) {
+			return fmt.Errorf("%w: %s", ErrSessionNotFound, jti)
+		}
+	}
+	return s.dbManager.RevokeSession(jti, time.Now())
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// TenantScope 管理API当前用户可以访问的租户范围，由JWT声明确定；为空表示全局管理员，可以访问所有租户
+type TenantScope string
+
+// GlobalScope 全局管理员的租户范围
+const GlobalScope TenantScope = ""
+
+// IsGlobal 是否为全局管理员的范围
+func (s TenantScope) IsGlobal() bool {
+	return s == GlobalScope
+}
+
+// Filter 数据库查询的租户过滤条件，全局范围为空表示不过滤
+func (s TenantScope) Filter() string {
+	return string(s)
+}
+
+// Allows 属于tenantID的资源是否在范围内，空租户视为默认租户
+func (s TenantScope) Allows(tenantID string) bool {
+	return s.IsGlobal() || string(s) == config.TenantOrDefault(tenantID)
+}
+
+// Model 返回范围内的模型配置（支持别名）；模型不存在和属于其他租户时同样返回false，不泄露其他租户的模型ID
+func (s TenantScope) Model(cfg *config.Config, modelID string) (*config.ModelConfig, bool) {
+	model, exists := cfg.GetModel(modelID)
+	if !exists || !s.Allows(model.Tenant) {
+		return nil, false
+	}
+	return model, true
+}
+
+// ScopeOf 用户的租户范围，全局管理员不限制租户
+func ScopeOf(user *db.User) TenantScope {
+	if user.Global {
+		return GlobalScope
+	}
+	return TenantScope(config.TenantOrDefault(user.TenantID))
+}
+
+// Scope 返回token持有者的租户范围。升级前签发的token没有租户声明，与数据迁移一致：
+// superuser为全局管理员，其他用户属于默认租户
+func (c *Claims) Scope() TenantScope {
+	switch {
+	case c.Global:
+		return GlobalScope
+	case c.TenantID != "":
+		return TenantScope(c.TenantID)
+	case c.Role == db.RoleSuperuser:
+		return GlobalScope
+	}
+	return TenantScope(config.DefaultTenant)
+}
+
+// Tenant 返回token持有者所属的租户，升级前签发的token属于默认租户
+func (c *Claims) Tenant() string {
+	return config.TenantOrDefault(c.TenantID)
+}
+
+// 租户相关的哨兵错误
+var (
+	ErrTenantForbidden = errors.New("只有全局管理员可以管理其他租户的资源")
+	ErrTenantNotFound  = errors.New("租户不存在")
+	ErrInvalidTenant   = errors.New("无效的租户ID")
+)
+
+// ListTenants 获取范围内的租户，非全局管理员只返回所属的租户
+func (s *AuthService) ListTenants(scope TenantScope) ([]db.Tenant, error) {
+	if !scope.IsGlobal() {
+		tenant, err := s.dbManager.GetTenant(scope.Filter())
+		if err != nil {
+			return nil, err
+		}
+		return []db.Tenant{*tenant}, nil
+	}
+	return s.dbManager.GetTenants()
+}
+
+// CreateTenant 创建租户，只有全局管理员可以创建
+func (s *AuthService) CreateTenant(scope TenantScope, id, name string) (*db.Tenant, error) {
+	if !scope.IsGlobal() {
+		return nil, ErrTenantForbidden
+	}
+	id = strings.TrimSpace(id)
+	if !config.ValidTenantID(id) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTenant, id)
+	}
+	tenant := &db.Tenant{ID: id, Name: strings.TrimSpace(name)}
+	if err := s.dbManager.CreateTenant(tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// resolveTenant 确定新建或修改的资源所属的租户：非全局管理员只能使用自己的租户，tenantID为空时使用范围内的租户；
+// 全局管理员可以指定任意已存在的租户，为空时使用默认租户
+func resolveTenant(dbManager *db.Manager, scope TenantScope, tenantID string) (string, error) {
+	if !scope.IsGlobal() {
+		if tenantID != "" && tenantID != scope.Filter() {
+			return "", fmt.Errorf("%w: %s", ErrTenantForbidden, tenantID)
+		}
+		return scope.Filter(), nil
+	}
+	tenantID = config.TenantOrDefault(tenantID)
+	if _, err := dbManager.GetTenant(tenantID); err != nil {
+		if errors.Is(err, db.ErrTenantNotFound) {
+			return "", fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+		}
+		return "", err
+	}
+	return tenantID, nil
+}
+
+// UserInScope 获取范围内的用户，用户不存在或属于其他租户时返回ErrUserNotFound
+func (s *AuthService) UserInScope(scope TenantScope, userID uint) (*db.User, error) {
+	user, err := s.dbManager.GetUserByID(userID)
+	if err != nil || !scope.Allows(user.TenantID) {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	return user, nil
+}
+
+// APIKeyInScope 根据ID获取范围内的API Key（包括已禁用的Key），不存在或属于其他租户时返回ErrAPIKeyNotFound
+func (s *AuthService) APIKeyInScope(scope TenantScope, apiKeyID uint) (*db.APIKey, error) {
+	apiKey, err := s.dbManager.GetAPIKeyByID(apiKeyID)
+	if err != nil || !scope.Allows(apiKey.TenantID) {
+		return nil, fmt.Errorf("%w: %d", ErrAPIKeyNotFound, apiKeyID)
+	}
+	return apiKey, nil
+}
+
+// AssignModelTenant 设置新建或修改的模型所属的租户，非全局管理员只能使用自己的租户
+func (s *ConfigService) AssignModelTenant(scope TenantScope, model *config.ModelConfig) error {
+	tenantID, err := resolveTenant(s.db, scope, model.Tenant)
+	if err != nil {
+		return err
+	}
+	model.Tenant = tenantID
+	return nil
+}
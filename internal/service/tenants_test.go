@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+func TestClaimsScope(t *testing.T) {
+	cases := []struct {
+		claims Claims
+		want   TenantScope
+	}{
+		{Claims{Global: true, TenantID: "team-a"}, GlobalScope},
+		{Claims{Role: db.RoleSuperuser, TenantID: "team-a"}, "team-a"},
+		{Claims{Role: db.RoleOperator, TenantID: "team-a"}, "team-a"},
+		// 升级前签发的token没有租户声明
+		{Claims{Role: db.RoleSuperuser}, GlobalScope},
+		{Claims{Role: db.RoleOperator}, config.DefaultTenant},
+	}
+	for _, tc := range cases {
+		if got := tc.claims.Scope(); got != tc.want {
+			t.Errorf("%+v 的租户范围期望为%q，实际为%q", tc.claims, tc.want, got)
+		}
+	}
+}
+
+func TestTenantScopedUsersAndKeys(t *testing.T) {
+	s := newTestAuthService(t)
+	admin, err := s.Register(&RegisterRequest{Username: "admin", Password: "password"})
+	if err != nil {
+		t.Fatalf("注册用户失败: %v", err)
+	}
+	if _, err := s.CreateTenant("team-a", "team-a", "A"); !errors.Is(err, ErrTenantForbidden) {
+		t.Errorf("租户管理员不能创建租户，实际%v", err)
+	}
+	if _, err := s.CreateTenant(GlobalScope, "Team A", ""); !errors.Is(err, ErrInvalidTenant) {
+		t.Errorf("期望拒绝无效的租户ID，实际%v", err)
+	}
+	if _, err := s.CreateTenant(GlobalScope, "team-a", "A"); err != nil {
+		t.Fatalf("创建租户失败: %v", err)
+	}
+	if _, err := s.CreateTenant(GlobalScope, "team-a", "A"); !errors.Is(err, db.ErrTenantExists) {
+		t.Errorf("期望租户ID重复时返回ErrTenantExists，实际%v", err)
+	}
+
+	// 租户管理员创建的用户属于同一租户，不能指定其他租户或设为全局管理员
+	scope := TenantScope("team-a")
+	alice, err := s.CreateUser(&CreateUserRequest{Username: "alice", AutoCreateKey: true}, admin.User.ID, scope)
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if alice.User.TenantID != "team-a" || alice.APIKey.TenantID != "team-a" {
+		t.Errorf("用户和API Key应属于创建者的租户，实际%q/%q", alice.User.TenantID, alice.APIKey.TenantID)
+	}
+	if _, err := s.CreateUser(&CreateUserRequest{Username: "bob", TenantID: config.DefaultTenant}, admin.User.ID, scope); !errors.Is(err, ErrTenantForbidden) {
+		t.Errorf("租户管理员不能在其他租户创建用户，实际%v", err)
+	}
+	if _, err := s.CreateUser(&CreateUserRequest{Username: "bob", Global: true}, admin.User.ID, scope); !errors.Is(err, ErrTenantForbidden) {
+		t.Errorf("租户管理员不能创建全局管理员，实际%v", err)
+	}
+	if _, err := s.CreateUser(&CreateUserRequest{Username: "bob", TenantID: "team-b"}, admin.User.ID, GlobalScope); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("期望指定不存在的租户时返回ErrTenantNotFound，实际%v", err)
+	}
+
+	// 其他租户的用户和API Key视为不存在
+	other := TenantScope(config.DefaultTenant)
+	if _, err := s.UserInScope(other, alice.User.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("期望其他租户的用户视为不存在，实际%v", err)
+	}
+	if _, err := s.APIKeyInScope(other, alice.APIKey.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("期望其他租户的API Key视为不存在，实际%v", err)
+	}
+	if err := s.UpdateUser(other, alice.User.ID, &UpdateUserRequest{Username: "mallory"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("期望不能修改其他租户的用户，实际%v", err)
+	}
+	if keys, _ := s.GetAllAPIKeys(other, nil); len(keys) != 0 {
+		t.Errorf("期望只返回范围内的API Key，实际%d个", len(keys))
+	}
+	if users, _ := s.SearchUsers(scope, "", db.UserQuery{IncludeAdmins: true}); users.Total != 1 {
+		t.Errorf("期望租户管理员只看到所属租户的用户，实际%d个", users.Total)
+	}
+	if _, err := s.TransferAPIKey(scope, alice.APIKey.ID, admin.User.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("期望不能将API Key转移给其他租户的用户，实际%v", err)
+	}
+
+	// 只有全局管理员可以修改所属租户，用户的API Key随之转到新租户
+	tenant := config.DefaultTenant
+	if err := s.UpdateUser(scope, alice.User.ID, &UpdateUserRequest{TenantID: &tenant}); !errors.Is(err, ErrTenantForbidden) {
+		t.Errorf("租户管理员不能修改用户的租户，实际%v", err)
+	}
+	if err := s.UpdateUser(GlobalScope, alice.User.ID, &UpdateUserRequest{TenantID: &tenant}); err != nil {
+		t.Fatalf("修改用户租户失败: %v", err)
+	}
+	if apiKey, err := s.APIKeyInScope(other, alice.APIKey.ID); err != nil || apiKey.TenantID != config.DefaultTenant {
+		t.Errorf("用户的API Key应转到新租户，实际%+v, %v", apiKey, err)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// 管理后台访问token有效期的配置
+const (
+	tokenTTLMetadataKey = "token_ttl_seconds" // 保存token有效期（秒）的元数据键
+
+	DefaultTokenTTL = 24 * time.Hour      // 未配置时的token有效期
+	MinTokenTTL     = 5 * time.Minute     // 允许配置的最短有效期
+	MaxTokenTTL     = 30 * 24 * time.Hour // 允许配置的最长有效期
+)
+
+// loadTokenTTL 从元数据读取token有效期，未配置或值无效时使用默认值
+func loadTokenTTL(dbManager *db.Manager) time.Duration {
+	value, err := dbManager.GetMetadata(tokenTTLMetadataKey)
+	if err != nil {
+		return DefaultTokenTTL
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || validateTokenTTL(time.Duration(seconds)*time.Second) != nil {
+		fmt.Printf("元数据%s的值无效，使用默认的token有效期: %s\n", tokenTTLMetadataKey, value)
+		return DefaultTokenTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// validateTokenTTL 检查token有效期是否在允许范围内且为整秒
+func validateTokenTTL(ttl time.Duration) error {
+	if ttl < MinTokenTTL || ttl > MaxTokenTTL {
+		return fmt.Errorf("token有效期必须在%s到%s之间", MinTokenTTL, MaxTokenTTL)
+	}
+	if ttl%time.Second != 0 {
+		return fmt.Errorf("token有效期必须为整秒")
+	}
+	return nil
+}
+
+// TokenTTL 获取新签发token的有效期
+func (s *AuthService) TokenTTL() time.Duration {
+	s.tokenTTLMutex.RLock()
+	defer s.tokenTTLMutex.RUnlock()
+	return s.tokenTTL
+}
+
+// SetTokenTTL 修改新签发token的有效期并保存到元数据。
+// 已签发的token按其自身的过期时间继续有效，不受修改影响
+func (s *AuthService) SetTokenTTL(ttl time.Duration) error {
+	if err := validateTokenTTL(ttl); err != nil {
+		return err
+	}
+	seconds := strconv.FormatInt(int64(ttl/time.Second), 10)
+	if err := s.dbManager.SetMetadata(tokenTTLMetadataKey, seconds); err != nil {
+		return fmt.Errorf("保存token有效期失败: %w", err)
+	}
+
+	s.tokenTTLMutex.Lock()
+	s.tokenTTL = ttl
+	s.tokenTTLMutex.Unlock()
+	return nil
+}
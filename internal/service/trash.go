@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// TrashedModels 获取回收站中范围内的模型配置，按删除时间从新到旧排序
+func (s *ConfigService) TrashedModels(scope TenantScope) ([]db.ModelConfigDB, error) {
+	return s.db.GetTrashedModelConfigs(scope.Filter())
+}
+
+// RestoreModel 从回收站恢复范围内最近删除的模型配置，其他租户删除的同ID模型视为不在回收站中。
+// 恢复前重新验证配置（如URL可能已不再有效），模型ID已被新模型（包括别名）使用时返回db.ErrModelIDInUse
+func (s *ConfigService) RestoreModel(scope TenantScope, modelID string) (*config.ModelConfig, error) {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	model, err := s.db.GetTrashedModelConfig(modelID, scope.Filter())
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := s.config.GetModel(modelID); exists {
+		return nil, fmt.Errorf("%w: %s", db.ErrModelIDInUse, modelID)
+	}
+	if err := model.Validate(); err != nil {
+		return nil, fmt.Errorf("模型配置验证失败: %w", err)
+	}
+	if err := s.config.CheckAliases(model); err != nil {
+		return nil, config.ValidationErrors{{Field: "aliases", Message: err.Error()}}
+	}
+
+	restored, err := s.db.RestoreModelConfig(modelID, scope.Filter())
+	if err != nil {
+		return nil, err
+	}
+	s.config.AddModel(restored)
+	return restored, nil
+}
+
+// PurgeModel 永久删除回收站中范围内的模型配置，不影响其他租户删除的同ID模型
+func (s *ConfigService) PurgeModel(scope TenantScope, modelID string) error {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+	return s.db.PurgeModelConfig(modelID, scope.Filter())
+}
+
+// TrashPurger 定期永久删除在回收站中超过保留时长的模型配置
+type TrashPurger struct {
+	dbManager *db.Manager
+	retention time.Duration
+	interval  time.Duration
+
+	started  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTrashPurger 创建回收站清理器；未设置保留时长（为0）时返回nil，回收站中的模型不会自动删除
+func NewTrashPurger(dbManager *db.Manager, cfg config.TrashConfig) *TrashPurger {
+	if cfg.Retention <= 0 || cfg.PurgeInterval <= 0 {
+		return nil
+	}
+	return &TrashPurger{
+		dbManager: dbManager,
+		retention: cfg.Retention,
+		interval:  cfg.PurgeInterval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start 立即清理一次，之后按间隔定期清理
+func (p *TrashPurger) Start() {
+	if p == nil {
+		return
+	}
+	p.started = true
+	go p.run()
+}
+
+// Close 停止定期清理
+func (p *TrashPurger) Close() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	if p.started {
+		<-p.done
+	}
+}
+
+// run 清理循环
+func (p *TrashPurger) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Purge(time.Now()); err != nil {
+			fmt.Printf("清理模型回收站失败: %v\n", err)
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge 永久删除在now之前超过保留时长删除的模型配置，返回删除的数量
+func (p *TrashPurger) Purge(now time.Time) (int64, error) {
+	n, err := p.dbManager.PurgeTrashedModelConfigs(now.Add(-p.retention))
+	if err == nil && n > 0 {
+		fmt.Printf("永久删除了回收站中 %d 个超过保留时长的模型配置\n", n)
+	}
+	return n, err
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+)
+
+func TestTrashPurger(t *testing.T) {
+	s, _ := newWatchedConfigService(t)
+	if NewTrashPurger(s.GetDBManager(), config.TrashConfig{PurgeInterval: time.Hour}) != nil {
+		t.Error("未设置保留时长时不应创建清理器")
+	}
+
+	if err := s.DeleteModel("watch-model"); err != nil {
+		t.Fatalf("删除模型失败: %v", err)
+	}
+	purger := NewTrashPurger(s.GetDBManager(), config.TrashConfig{Retention: 24 * time.Hour, PurgeInterval: time.Hour})
+	if n, err := purger.Purge(time.Now()); err != nil || n != 0 {
+		t.Errorf("保留期内的模型不应被删除: n=%d err=%v", n, err)
+	}
+	if n, err := purger.Purge(time.Now().Add(25 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("超过保留期的模型应被永久删除: n=%d err=%v", n, err)
+	}
+	if trashed, _ := s.TrashedModels(GlobalScope); len(trashed) != 0 {
+		t.Errorf("清理后回收站应为空，实际%d个", len(trashed))
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// DefaultUsageFlushInterval 模型调用统计写入数据库的默认间隔
+const DefaultUsageFlushInterval = 10 * time.Second
+
+// UsageRecorder 在内存中累计各模型的请求数和最后调用时间，定期批量写入数据库，
+// 代理处理请求时不同步写库
+type UsageRecorder struct {
+	dbManager *db.Manager
+	interval  time.Duration
+
+	mutex   sync.Mutex
+	pending map[string]db.ModelUsageDelta
+
+	started  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewUsageRecorder 创建模型调用统计记录器，调用Start后开始定期写入
+func NewUsageRecorder(dbManager *db.Manager, interval time.Duration) *UsageRecorder {
+	if interval <= 0 {
+		interval = DefaultUsageFlushInterval
+	}
+	return &UsageRecorder{
+		dbManager: dbManager,
+		interval:  interval,
+		pending:   make(map[string]db.ModelUsageDelta),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start 开始按间隔写入累计的统计
+func (r *UsageRecorder) Start() {
+	r.started = true
+	go r.run()
+}
+
+// Close 停止定期写入，并写入尚未保存的统计
+func (r *UsageRecorder) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	if r.started {
+		<-r.done
+	}
+	return r.Flush()
+}
+
+// run 写入循环，写入失败的统计保留到下一次写入
+func (r *UsageRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := r.Flush(); err != nil {
+			fmt.Printf("写入模型调用统计失败: %v\n", err)
+		}
+	}
+}
+
+// Record 记录模型的一次请求
+func (r *UsageRecorder) Record(modelID string, at time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.add(modelID, db.ModelUsageDelta{Count: 1, LastUsedAt: at})
+}
+
+// add 合并统计，调用方需持有锁
+func (r *UsageRecorder) add(modelID string, delta db.ModelUsageDelta) {
+	current := r.pending[modelID]
+	current.Count += delta.Count
+	if delta.LastUsedAt.After(current.LastUsedAt) {
+		current.LastUsedAt = delta.LastUsedAt
+	}
+	r.pending[modelID] = current
+}
+
+// Flush 将累计的统计写入数据库，失败时统计放回内存等待下一次写入
+func (r *UsageRecorder) Flush() error {
+	r.mutex.Lock()
+	pending := r.pending
+	r.pending = make(map[string]db.ModelUsageDelta)
+	r.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := r.dbManager.AddModelUsage(pending); err != nil {
+		r.mutex.Lock()
+		for modelID, delta := range pending {
+			r.add(modelID, delta)
+		}
+		r.mutex.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/alert"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+)
+
+// WebhookService 管理接收告警的Webhook
+type WebhookService struct {
+	dbManager *db.Manager
+}
+
+// NewWebhookService 创建Webhook服务
+func NewWebhookService(dbManager *db.Manager) *WebhookService {
+	return &WebhookService{dbManager: dbManager}
+}
+
+// CreateWebhookRequest 创建Webhook请求
+type CreateWebhookRequest struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url" binding:"required"`
+	Secret    string   `json:"secret"`     // 请求签名密钥，为空时不签名
+	Events    []string `json:"events"`     // 订阅的事件类型，为空表示全部
+	IsEnabled *bool    `json:"is_enabled"` // 是否启用，默认启用
+}
+
+// UpdateWebhookRequest 更新Webhook请求，为nil的字段保持不变
+type UpdateWebhookRequest struct {
+	Name      *string   `json:"name"`
+	URL       *string   `json:"url"`
+	Secret    *string   `json:"secret"` // 设置为空字符串时不再签名
+	Events    *[]string `json:"events"`
+	IsEnabled *bool     `json:"is_enabled"`
+}
+
+// validateWebhook 检查Webhook地址和订阅的事件类型
+func validateWebhook(webhook *db.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url必须是http或https地址: %s", ErrInvalidWebhook, webhook.URL)
+	}
+	for _, event := range webhook.Events {
+		if !alert.ValidEventType(event) {
+			return fmt.Errorf("%w: 不支持的事件类型: %s", ErrInvalidWebhook, event)
+		}
+	}
+	return nil
+}
+
+// ListWebhooks 获取全部Webhook
+func (s *WebhookService) ListWebhooks() ([]db.Webhook, error) {
+	return s.dbManager.ListWebhooks()
+}
+
+// GetWebhook 根据ID获取Webhook
+func (s *WebhookService) GetWebhook(id uint) (*db.Webhook, error) {
+	webhook, err := s.dbManager.GetWebhook(id)
+	if errors.Is(err, db.ErrWebhookNotFound) {
+		return nil, fmt.Errorf("%w: %d", ErrWebhookNotFound, id)
+	}
+	return webhook, err
+}
+
+// CreateWebhook 创建Webhook
+func (s *WebhookService) CreateWebhook(req *CreateWebhookRequest) (*db.Webhook, error) {
+	webhook := &db.Webhook{
+		Name:      req.Name,
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    db.StringList(req.Events),
+		IsEnabled: req.IsEnabled == nil || *req.IsEnabled,
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if err := s.dbManager.CreateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// UpdateWebhook 更新Webhook
+func (s *WebhookService) UpdateWebhook(id uint, req *UpdateWebhookRequest) (*db.Webhook, error) {
+	webhook, err := s.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		webhook.Name = *req.Name
+	}
+	if req.URL != nil {
+		webhook.URL = *req.URL
+	}
+	if req.Secret != nil {
+		webhook.Secret = *req.Secret
+	}
+	if req.Events != nil {
+		webhook.Events = db.StringList(*req.Events)
+	}
+	if req.IsEnabled != nil {
+		webhook.IsEnabled = *req.IsEnabled
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	if err := s.dbManager.UpdateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook 删除Webhook
+func (s *WebhookService) DeleteWebhook(id uint) error {
+	err := s.dbManager.DeleteWebhook(id)
+	if errors.Is(err, db.ErrWebhookNotFound) {
+		return fmt.Errorf("%w: %d", ErrWebhookNotFound, id)
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// referenceSignature 按文档描述的格式独立计算签名，用于核对Sign的实现
+func referenceSignature(secret, method, path string, timestamp int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s", method, path, timestamp, body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignMatchesReference(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"你好"}]}`
+	got := Sign("s3cret", "post", "/v1/chat/completions", 1700000000, []byte(body))
+	want := referenceSignature("s3cret", "POST", "/v1/chat/completions", 1700000000, body)
+	if got != want {
+		t.Errorf("签名与参考实现不一致\n got: %s\nwant: %s", got, want)
+	}
+
+	// 已知向量，防止签名格式被意外修改
+	if got := Sign("key", "GET", "/", 0, nil); got != referenceSignature("key", "GET", "/", 0, "") {
+		t.Errorf("空请求体的签名不正确: %s", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"model":"gpt-4o"}`)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://upstream.internal/v1/chat/completions?x=1", strings.NewReader(string(body)))
+		SignRequest(req, "s3cret", body, now)
+		return req
+	}
+
+	if err := Verify(newRequest(), "s3cret", body, 0, now.Add(time.Minute)); err != nil {
+		t.Errorf("期望签名验证通过，实际得到%v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request) ([]byte, string, time.Time)
+		want   error
+	}{
+		{"密钥错误", func(req *http.Request) ([]byte, string, time.Time) { return body, "other", now }, ErrInvalidSignature},
+		{"请求体被修改", func(req *http.Request) ([]byte, string, time.Time) { return []byte(`{}`), "s3cret", now }, ErrInvalidSignature},
+		{"路径被修改", func(req *http.Request) ([]byte, string, time.Time) {
+			req.URL.Path = "/v1/embeddings"
+			return body, "s3cret", now
+		}, ErrInvalidSignature},
+		{"超出时钟偏差", func(req *http.Request) ([]byte, string, time.Time) { return body, "s3cret", now.Add(10 * time.Minute) }, ErrTimestampSkew},
+		{"时间戳在未来", func(req *http.Request) ([]byte, string, time.Time) { return body, "s3cret", now.Add(-10 * time.Minute) }, ErrTimestampSkew},
+		{"缺少签名", func(req *http.Request) ([]byte, string, time.Time) {
+			req.Header.Del(HeaderSignature)
+			return body, "s3cret", now
+		}, ErrMissingSignature},
+		{"无效时间戳", func(req *http.Request) ([]byte, string, time.Time) {
+			req.Header.Set(HeaderTimestamp, "abc")
+			return body, "s3cret", now
+		}, ErrInvalidTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest()
+			receivedBody, secret, at := tt.modify(req)
+			if err := Verify(req, secret, receivedBody, 0, at); !errors.Is(err, tt.want) {
+				t.Errorf("期望错误%v，实际得到%v", tt.want, err)
+			}
+		})
+	}
+}
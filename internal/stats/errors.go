@@ -0,0 +1,194 @@
+package stats
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrorClass 错误分类
+type ErrorClass string
+
+const (
+	ErrorClassClient      ErrorClass = "client_error"    // 请求本身不合法（如并发受限、请求体过大）
+	ErrorClassUpstream4xx ErrorClass = "upstream_4xx"    // 上游返回4xx（如鉴权失败、限流）
+	ErrorClassUpstream5xx ErrorClass = "upstream_5xx"    // 上游返回5xx
+	ErrorClassTimeout     ErrorClass = "timeout"         // 请求超时
+	ErrorClassInjection   ErrorClass = "injection_error" // Prompt注入或模型ID替换失败
+	ErrorClassNetwork     ErrorClass = "network"         // 连接上游失败
+)
+
+// ErrorClasses 所有错误分类
+var ErrorClasses = []ErrorClass{
+	ErrorClassClient,
+	ErrorClassUpstream4xx,
+	ErrorClassUpstream5xx,
+	ErrorClassTimeout,
+	ErrorClassInjection,
+	ErrorClassNetwork,
+}
+
+const (
+	// DefaultErrorWindow 默认统计窗口
+	DefaultErrorWindow = 15 * time.Minute
+	// DefaultErrorCapacity 每个模型默认保留的错误事件数
+	DefaultErrorCapacity = 1000
+	// recentSampleCount 返回的最近错误样本数
+	recentSampleCount = 10
+	// maxMessageLength 错误信息最大长度（字符）
+	maxMessageLength = 200
+)
+
+// ErrorEvent 错误事件
+type ErrorEvent struct {
+	RequestID string     `json:"request_id"`
+	Timestamp time.Time  `json:"timestamp"`
+	Class     ErrorClass `json:"class"`
+	Message   string     `json:"message"`
+}
+
+// ErrorSummary 模型在统计窗口内的错误汇总
+type ErrorSummary struct {
+	ModelID string             `json:"model_id"`
+	Window  string             `json:"window"`
+	Total   int                `json:"total"`
+	Counts  map[ErrorClass]int `json:"counts"`
+	Recent  []ErrorEvent       `json:"recent"`
+}
+
+// errorRing 固定容量的错误事件环形缓冲区
+type errorRing struct {
+	events []ErrorEvent
+	next   int
+	full   bool
+}
+
+// add 写入事件，容量满时覆盖最旧的事件
+func (r *errorRing) add(event ErrorEvent) {
+	r.events[r.next] = event
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since 按时间从新到旧返回指定时间之后的事件
+func (r *errorRing) since(cutoff time.Time) []ErrorEvent {
+	size := r.next
+	if r.full {
+		size = len(r.events)
+	}
+
+	var result []ErrorEvent
+	for i := 1; i <= size; i++ {
+		event := r.events[(r.next-i+len(r.events))%len(r.events)]
+		if event.Timestamp.Before(cutoff) {
+			break
+		}
+		result = append(result, event)
+	}
+	return result
+}
+
+// ErrorTracker 按模型记录最近一段时间的错误事件，仅保存在内存中
+type ErrorTracker struct {
+	window   time.Duration
+	capacity int
+	rings    map[string]*errorRing
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+// NewErrorTracker 创建错误统计器
+func NewErrorTracker(window time.Duration, capacity int) *ErrorTracker {
+	if window <= 0 {
+		window = DefaultErrorWindow
+	}
+	if capacity <= 0 {
+		capacity = DefaultErrorCapacity
+	}
+	return &ErrorTracker{
+		window:   window,
+		capacity: capacity,
+		rings:    make(map[string]*errorRing),
+		now:      time.Now,
+	}
+}
+
+// Record 记录模型的错误事件，tracker为nil时忽略
+func (t *ErrorTracker) Record(modelID string, class ErrorClass, requestID, message string) {
+	if t == nil || modelID == "" {
+		return
+	}
+
+	event := ErrorEvent{
+		RequestID: requestID,
+		Timestamp: t.now(),
+		Class:     class,
+		Message:   truncate(message, maxMessageLength),
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ring, ok := t.rings[modelID]
+	if !ok {
+		ring = &errorRing{events: make([]ErrorEvent, t.capacity)}
+		t.rings[modelID] = ring
+	}
+	ring.add(event)
+}
+
+// Summary 返回模型在统计窗口内各分类的错误数和最近的错误样本
+func (t *ErrorTracker) Summary(modelID string) ErrorSummary {
+	summary := ErrorSummary{
+		ModelID: modelID,
+		Counts:  make(map[ErrorClass]int, len(ErrorClasses)),
+		Recent:  []ErrorEvent{},
+	}
+	for _, class := range ErrorClasses {
+		summary.Counts[class] = 0
+	}
+	if t == nil {
+		return summary
+	}
+	summary.Window = t.window.String()
+
+	t.mutex.Lock()
+	var events []ErrorEvent
+	if ring, ok := t.rings[modelID]; ok {
+		events = ring.since(t.now().Add(-t.window))
+	}
+	t.mutex.Unlock()
+
+	for _, event := range events {
+		summary.Counts[event.Class]++
+	}
+	summary.Total = len(events)
+
+	if len(events) > recentSampleCount {
+		events = events[:recentSampleCount]
+	}
+	summary.Recent = append(summary.Recent, events...)
+
+	return summary
+}
+
+// Forget 删除模型的错误记录
+func (t *ErrorTracker) Forget(modelID string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.rings, modelID)
+}
+
+// truncate 按字符数截断字符串
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max]) + "..."
+}
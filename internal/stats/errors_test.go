@@ -0,0 +1,72 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestErrorTrackerConcurrentMixedFailures(t *testing.T) {
+	tracker := NewErrorTracker(time.Minute, 1000)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			class := ErrorClasses[i%len(ErrorClasses)]
+			tracker.Record("chat", class, fmt.Sprintf("req-%d", i), string(class))
+			tracker.Summary("chat")
+		}(i)
+	}
+	wg.Wait()
+
+	summary := tracker.Summary("chat")
+	if summary.Total != 60 {
+		t.Errorf("期望共60个错误，实际得到%d", summary.Total)
+	}
+	for _, class := range ErrorClasses {
+		if summary.Counts[class] != 10 {
+			t.Errorf("期望%s有10个错误，实际得到%d", class, summary.Counts[class])
+		}
+	}
+	if len(summary.Recent) != recentSampleCount {
+		t.Errorf("期望返回%d个最近错误，实际得到%d", recentSampleCount, len(summary.Recent))
+	}
+	if other := tracker.Summary("other"); other.Total != 0 || len(other.Recent) != 0 {
+		t.Errorf("其他模型不应有错误记录: %+v", other)
+	}
+}
+
+func TestErrorTrackerWindowAndCapacity(t *testing.T) {
+	now := time.Now()
+	tracker := NewErrorTracker(time.Minute, 3)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record("chat", ErrorClassNetwork, "old", "old")
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 4; i++ {
+		tracker.Record("chat", ErrorClassUpstream5xx, fmt.Sprintf("new-%d", i), strings.Repeat("错", 300))
+	}
+
+	summary := tracker.Summary("chat")
+	if summary.Total != 3 || summary.Counts[ErrorClassNetwork] != 0 {
+		t.Errorf("窗口外和超出容量的事件应被淘汰，实际得到%+v", summary.Counts)
+	}
+	if summary.Recent[0].RequestID != "new-3" {
+		t.Errorf("最近的错误应排在最前，实际得到%s", summary.Recent[0].RequestID)
+	}
+	if len([]rune(summary.Recent[0].Message)) != maxMessageLength+3 {
+		t.Errorf("错误信息应被截断，实际长度%d", len([]rune(summary.Recent[0].Message)))
+	}
+}
+
+func TestNilErrorTracker(t *testing.T) {
+	var tracker *ErrorTracker
+	tracker.Record("chat", ErrorClassTimeout, "req", "timeout")
+	if summary := tracker.Summary("chat"); summary.Total != 0 {
+		t.Errorf("nil统计器应返回空汇总，实际得到%+v", summary)
+	}
+}
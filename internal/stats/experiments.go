@@ -0,0 +1,90 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// VariantStats Prompt实验一个变体的请求统计
+type VariantStats struct {
+	Variant       string `json:"variant"`
+	Requests      int64  `json:"requests"`        // 请求数
+	Errors        int64  `json:"errors"`          // 失败的请求数
+	AvgResponseMs int64  `json:"avg_response_ms"` // 平均响应时间(毫秒)
+}
+
+// variantCounter 变体的累计计数
+type variantCounter struct {
+	requests int64
+	errors   int64
+	duration time.Duration
+}
+
+// ExperimentTracker 按模型和变体累计Prompt实验的请求统计，仅保存在内存中
+type ExperimentTracker struct {
+	models map[string]map[string]*variantCounter
+	mutex  sync.Mutex
+}
+
+// NewExperimentTracker 创建Prompt实验统计器
+func NewExperimentTracker() *ExperimentTracker {
+	return &ExperimentTracker{models: make(map[string]map[string]*variantCounter)}
+}
+
+// Record 记录一次使用变体的请求，tracker为nil时忽略
+func (t *ExperimentTracker) Record(modelID, variant string, failed bool, duration time.Duration) {
+	if t == nil || modelID == "" || variant == "" {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	variants, ok := t.models[modelID]
+	if !ok {
+		variants = make(map[string]*variantCounter)
+		t.models[modelID] = variants
+	}
+	counter, ok := variants[variant]
+	if !ok {
+		counter = &variantCounter{}
+		variants[variant] = counter
+	}
+	counter.requests++
+	if failed {
+		counter.errors++
+	}
+	counter.duration += duration
+}
+
+// Summary 返回模型各变体的统计，按变体ID排序
+func (t *ExperimentTracker) Summary(modelID string) []VariantStats {
+	result := []VariantStats{}
+	if t == nil {
+		return result
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for variant, counter := range t.models[modelID] {
+		stats := VariantStats{Variant: variant, Requests: counter.requests, Errors: counter.errors}
+		if counter.requests > 0 {
+			stats.AvgResponseMs = (counter.duration / time.Duration(counter.requests)).Milliseconds()
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Variant < result[j].Variant
+	})
+	return result
+}
+
+// Forget 删除模型的实验统计
+func (t *ExperimentTracker) Forget(modelID string) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.models, modelID)
+}
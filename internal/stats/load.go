@@ -0,0 +1,8 @@
+package stats
+
+// ModelLoad 模型当前的负载
+type ModelLoad struct {
+	InFlight int `json:"in_flight"` // 进行中的上游请求数
+	Queued   int `json:"queued"`    // 排队等待的请求数
+	Limit    int `json:"limit"`     // 最大并发数，0表示不限制
+}
@@ -0,0 +1,38 @@
+// Package version 保存编译时通过-ldflags写入的版本信息，例如：
+//
+//	go build -ldflags "-X github.com/eolinker/ai-prompt-proxy/internal/version.Version=1.2.0 \
+//	  -X github.com/eolinker/ai-prompt-proxy/internal/version.GitCommit=$(git rev-parse --short HEAD) \
+//	  -X github.com/eolinker/ai-prompt-proxy/internal/version.BuildTime=$(date -u '+%Y-%m-%d_%H:%M:%S')"
+package version
+
+import "runtime"
+
+// 编译时写入的版本信息，未写入时为默认值
+var (
+	Version   = "dev"     // 版本号
+	GitCommit = "unknown" // git提交
+	BuildTime = "unknown" // 编译时间(UTC)
+)
+
+// BuildInfo 运行中程序的版本信息
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Info 返回版本信息
+func Info() BuildInfo {
+	return BuildInfo{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// String 返回一行的版本描述，用于启动日志
+func (b BuildInfo) String() string {
+	return b.Version + " (commit " + b.GitCommit + ", built " + b.BuildTime + ", " + b.GoVersion + ")"
+}
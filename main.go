@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -9,62 +10,111 @@ import (
 	"syscall"
 
 	"github.com/eolinker/ai-prompt-proxy/internal/admin"
+	"github.com/eolinker/ai-prompt-proxy/internal/alert"
+	"github.com/eolinker/ai-prompt-proxy/internal/cli"
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/db"
+	"github.com/eolinker/ai-prompt-proxy/internal/healthcheck"
 	"github.com/eolinker/ai-prompt-proxy/internal/logger"
 	"github.com/eolinker/ai-prompt-proxy/internal/proxy"
 	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/internal/stats"
+	"github.com/eolinker/ai-prompt-proxy/internal/version"
 )
 
-// initDefaultLogger 初始化默认日志记录器
-func initDefaultLogger() {
-	// 创建默认的日志配置
-	defaultConfig := logger.OutputConfig{
-		Name:        "default",
-		Driver:      "file",
-		Description: "默认访问日志",
-		Enabled:     true,
-		Type:        logger.FormatterJSON,
-		File:        "access.log",
-		Dir:         "./logs",
-		Period:      logger.PeriodHour,
-		Expire:      3, // 保留30天
-		Formatter: logger.FormatterConfig{
-			Fields: map[string][]string{
-				"default": {
-					"$request_id", "$timestamp", "$method", "$path", "$user_agent",
-					"$client_ip", "$api_key", "$user_id", "$request_size", "$request_body",
-					"$model_id", "$target_model", "$proxy_url", "$proxy_scheme", "$proxy_host",
-					"$upstream_body", "$status_code", "$response_size", "$response_time",
-					"$response_body", "$error",
-				},
-			},
-		},
-	}
-
-	// 添加默认日志记录器
-	if err := logger.GlobalLoggerManager.AddLogger("default", defaultConfig); err != nil {
-		log.Printf("初始化默认日志记录器失败: %v", err)
-	} else {
-		log.Println("默认日志记录器初始化成功")
+// initLoggers 根据服务器配置初始化日志记录器，policy为fail时任一启用的日志记录器初始化失败都返回错误
+func initLoggers(outputs []logger.OutputConfig, policy string) error {
+	for _, output := range outputs {
+		if !output.Enabled {
+			continue
+		}
+		if err := logger.GlobalLoggerManager.AddLogger(output.Name, output); err != nil {
+			if policy == config.LoggerStartupFail {
+				return fmt.Errorf("初始化日志记录器 %s 失败: %w", output.Name, err)
+			}
+			log.Printf("警告: 初始化日志记录器 %s 失败，该日志记录器的访问日志将被丢弃: %v", output.Name, err)
+		} else {
+			log.Printf("日志记录器 %s 初始化成功", output.Name)
+		}
 	}
+	return nil
 }
 
 func main() {
+	// user、apikey、model、config子命令直接操作数据库，不启动HTTP服务器
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		// 服务层的提示信息改为输出到标准错误，标准输出只包含命令结果，便于用--json时解析
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		if err := cli.Run(os.Args[1:], stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var (
-		configDir = flag.String("config", "./configs", "配置文件目录")
-		proxyPort = flag.String("proxy-port", "8080", "代理服务器端口")
-		adminPort = flag.String("admin-port", "8081", "管理API端口")
+		configFile = flag.String("config-file", "", "服务器配置文件路径(server.yaml)")
+		configDir  = flag.String("config", "./configs", "配置文件目录")
+		proxyPort  = flag.String("proxy-port", "8080", "代理服务器端口")
+		adminPort  = flag.String("admin-port", "8081", "管理API端口")
+		dbDSN      = flag.String("db-dsn", "", "数据库连接串，为空时使用配置目录下的SQLite数据库")
+		watch      = flag.Bool("watch-config", false, "监听配置目录，YAML文件变化时自动重新加载")
+
+		bootstrapAdmin = flag.Bool("bootstrap-admin", false, "根据ADMIN_BOOTSTRAP_*环境变量创建初始管理员后退出")
+		seedDefaults   = flag.Bool("seed-defaults", false, "启动时导入内置默认模型目录中尚不存在的模型")
 	)
 	flag.Parse()
+	log.Printf("AI Prompt Proxy %s", version.Info())
+
+	// 加载服务器配置：默认值 < 配置文件 < 环境变量 < 命令行参数
+	if *configFile == "" {
+		*configFile = os.Getenv(config.EnvServerConfigFile)
+	}
+	serverConfig, err := config.LoadServerConfig(*configFile)
+	if err != nil {
+		log.Fatalf("加载服务器配置失败: %v", err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "config":
+			serverConfig.ConfigDir = *configDir
+		case "proxy-port":
+			serverConfig.Proxy.Port = *proxyPort
+		case "admin-port":
+			serverConfig.Admin.Port = *adminPort
+		case "db-dsn":
+			serverConfig.Database.DSN = *dbDSN
+		case "watch-config":
+			serverConfig.Watch.Enabled = *watch
+		}
+	})
+	if err := serverConfig.Validate(); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	// 创建配置服务
-	configService, err := service.NewConfigService(*configDir)
+	configService, err := service.NewConfigServiceWithDatabase(serverConfig.ConfigDir, serverConfig.Database, serverConfig.Drift.Policy)
 	if err != nil {
 		log.Fatalf("创建配置服务失败: %v", err)
 	}
 	defer configService.Close()
 
-	// 获取配置
-	cfg := configService.GetConfig()
+	if *seedDefaults {
+		seeded, err := configService.SeedDefaultModels()
+		if err != nil {
+			log.Fatalf("导入默认模型目录失败: %v", err)
+		}
+		log.Printf("已导入默认模型目录: 新增%v 跳过%v", seeded.Created, seeded.Skipped)
+	}
+
+	if serverConfig.Watch.Enabled {
+		if err := configService.WatchConfigDir(serverConfig.ConfigDir, serverConfig.Watch.Interval, serverConfig.Watch.Debounce); err != nil {
+			log.Printf("启动配置目录监听失败: %v", err)
+		} else {
+			log.Printf("已开启配置目录监听: %s", serverConfig.ConfigDir)
+		}
+	}
 
 	// 创建认证服务（代理服务器需要用到）
 	authService, err := service.NewAuthService(configService.GetDBManager())
@@ -72,8 +122,82 @@ func main() {
 		log.Fatalf("创建认证服务失败: %v", err)
 	}
 
-	// 初始化默认日志记录器
-	initDefaultLogger()
+	// 用户表为空时根据环境变量创建初始管理员，已有用户时忽略
+	if *bootstrapAdmin && os.Getenv(service.EnvBootstrapUsername) == "" {
+		log.Fatalf("未设置环境变量 %s", service.EnvBootstrapUsername)
+	}
+	adminUser, err := authService.BootstrapAdmin(os.LookupEnv)
+	if err != nil {
+		log.Fatalf("初始化管理员失败: %v", err)
+	}
+	if adminUser != nil {
+		log.Printf("已根据环境变量创建初始管理员: %s", adminUser.Username)
+	}
+	if *bootstrapAdmin {
+		if adminUser == nil {
+			log.Println("系统已存在用户，跳过初始管理员创建")
+		}
+		return
+	}
+
+	// 运行期间保持SQLite数据库旁的锁文件，离线子命令据此拒绝在服务器运行时修改数据库
+	serverLock, err := service.AcquireServerLock(service.ServerLockPath(serverConfig.ConfigDir, serverConfig.Database))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// 初始化日志记录器
+	if err := initLoggers(serverConfig.Loggers, serverConfig.AccessLog.StartupPolicy); err != nil {
+		log.Fatalf("%v (access_log.startup_policy为fail)", err)
+	}
+
+	// 代理服务按模型统计最近的错误，供管理API查询
+	errorTracker := stats.NewErrorTracker(stats.DefaultErrorWindow, stats.DefaultErrorCapacity)
+	// 按模型和变体统计Prompt实验的请求
+	experimentTracker := stats.NewExperimentTracker()
+	// 按模型统计灰度发布两个分组的请求
+	canaryTracker := stats.NewExperimentTracker()
+
+	// 模型调用统计在内存中累计后定期批量写入数据库
+	usageRecorder := service.NewUsageRecorder(configService.GetDBManager(), service.DefaultUsageFlushInterval)
+	usageRecorder.Start()
+
+	// 定期永久删除回收站中超过保留时长的模型
+	trashPurger := service.NewTrashPurger(configService.GetDBManager(), serverConfig.Trash)
+	trashPurger.Start()
+
+	// 按模型配置的health_check_interval在后台检查上游连通性
+	healthMonitor := healthcheck.NewMonitor(func() []*config.ModelConfig {
+		snapshot := configService.GetConfig().SnapshotModels()
+		models := make([]*config.ModelConfig, 0, len(snapshot))
+		for _, model := range snapshot {
+			models = append(models, model)
+		}
+		return models
+	}, healthcheck.DefaultTimeout)
+
+	// 告警在后台发送给管理API中配置的Webhook，队列满时丢弃，不阻塞代理请求
+	webhookService := service.NewWebhookService(configService.GetDBManager())
+	alerts := alert.NewDispatcher(webhookService.ListWebhooks, serverConfig.Alerts)
+	alerts.Start()
+	healthMonitor.SetTransitionHandler(func(status healthcheck.ModelStatus) {
+		alerts.Emit(alert.HealthEvent(status))
+	})
+	errorRates := alert.NewErrorRateMonitor(serverConfig.Alerts, alerts.Emit)
+	expiryChecker := alert.NewExpiryChecker(func() ([]db.APIKey, error) {
+		return authService.GetAllAPIKeys(service.GlobalScope, nil)
+	}, serverConfig.Alerts, alerts.Emit)
+	expiryChecker.Start()
+
+	healthMonitor.Start()
+
+	proxyServer := proxy.NewServerWithService(configService, authService, serverConfig)
+	proxyServer.SetErrorTracker(errorTracker)
+	proxyServer.SetErrorRateMonitor(errorRates)
+	proxyServer.SetExperimentTracker(experimentTracker)
+	proxyServer.SetCanaryTracker(canaryTracker)
+	proxyServer.SetUsageRecorder(usageRecorder)
+	proxyServer.SetHealthMonitor(healthMonitor)
 
 	var wg sync.WaitGroup
 
@@ -81,9 +205,8 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		proxyServer := proxy.NewServer(cfg, authService)
-		log.Printf("AI Prompt Proxy 启动在端口 %s", *proxyPort)
-		if err := proxyServer.Start(*proxyPort); err != nil {
+		log.Printf("AI Prompt Proxy 启动在端口 %s", serverConfig.Proxy.Port)
+		if err := proxyServer.Start(serverConfig.Proxy.Port); err != nil {
 			log.Fatalf("启动代理服务器失败: %v", err)
 		}
 	}()
@@ -92,12 +215,18 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		adminServer, err := admin.NewAdminServerWithService(configService, *configDir, *proxyPort, *adminPort)
+		adminServer, err := admin.NewAdminServerWithService(configService, serverConfig)
 		if err != nil {
 			log.Fatalf("创建管理API服务器失败: %v", err)
 		}
-		log.Printf("管理API服务器启动在端口 %s", *adminPort)
-		if err := adminServer.Start(*adminPort); err != nil {
+		adminServer.SetErrorTracker(errorTracker)
+		adminServer.SetExperimentTracker(experimentTracker)
+		adminServer.SetCanaryTracker(canaryTracker)
+		adminServer.SetAuthorizer(proxyServer.Authorizer()) // 模拟授权检查时使用代理的实时并发状态
+		adminServer.SetModelLoad(proxyServer.ModelLoad)
+		adminServer.SetHealthMonitor(healthMonitor)
+		log.Printf("管理API服务器启动在端口 %s", serverConfig.Admin.Port)
+		if err := adminServer.Start(serverConfig.Admin.Port); err != nil {
 			log.Fatalf("启动管理API服务器失败: %v", err)
 		}
 	}()
@@ -111,6 +240,16 @@ func main() {
 		<-sigChan
 		log.Println("收到退出信号，正在关闭服务...")
 
+		healthMonitor.Close()
+		expiryChecker.Close()
+		trashPurger.Close()
+		alerts.Close()
+
+		// 写入尚未保存的模型调用统计
+		if err := usageRecorder.Close(); err != nil {
+			log.Printf("写入模型调用统计失败: %v", err)
+		}
+
 		// 关闭日志记录器
 		if err := logger.GlobalLoggerManager.Close(); err != nil {
 			log.Printf("关闭日志记录器失败: %v", err)
@@ -118,6 +257,10 @@ func main() {
 			log.Println("日志记录器已关闭")
 		}
 
+		if err := serverLock.Release(); err != nil {
+			log.Printf("删除服务器锁文件失败: %v", err)
+		}
+
 		os.Exit(0)
 	}()
 
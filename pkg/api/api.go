@@ -0,0 +1,104 @@
+package api
+
+import "time"
+
+// Response 管理API的响应格式，成功时code为0，失败时code为HTTP状态码，error_code为错误码
+type Response[T any] struct {
+	Code      int    `json:"code"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Message   string `json:"message"`
+	Data      T      `json:"data"`
+}
+
+// Role 用户角色：superuser、operator或viewer
+type Role string
+
+// Capability 角色拥有的权限，如models:write
+type Capability string
+
+// User 用户信息，不包含密码
+type User struct {
+	ID                 uint       `json:"id"`
+	Username           string     `json:"username"`
+	Role               Role       `json:"role"`
+	IsAdmin            bool       `json:"is_admin"` // 兼容旧版本，角色为superuser时为true
+	IsEnabled          bool       `json:"is_enabled"`
+	MustChangePassword bool       `json:"must_change_password"` // 使用临时密码，需修改后才能使用管理功能
+	LastLoginAt        *time.Time `json:"last_login_at"`
+	FailedLoginCount   int        `json:"failed_login_count"` // 上次成功登录或锁定后连续登录失败的次数
+	LockedUntil        *time.Time `json:"locked_until"`       // 账户锁定到期时间
+	CreatedBy          uint       `json:"created_by"`         // 创建者ID，0表示系统创建
+	TenantID           string     `json:"tenant_id"`          // 所属租户
+	Global             bool       `json:"global"`             // 全局管理员
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// LoginRequest 登录请求（POST /api/v1/auth/login）
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginResponse 登录响应
+type LoginResponse struct {
+	Token              string `json:"token"`
+	User               *User  `json:"user"`
+	ExpiresAt          int64  `json:"expires_at"`
+	ExpiresIn          int64  `json:"expires_in"`           // token有效期（秒）
+	MustChangePassword bool   `json:"must_change_password"` // 使用临时密码，应先修改密码
+}
+
+// UserInfo 用户列表中的用户信息
+type UserInfo struct {
+	ID                 uint       `json:"id"`
+	Username           string     `json:"username"`
+	Role               Role       `json:"role"`
+	IsAdmin            bool       `json:"is_admin"`
+	IsEnabled          bool       `json:"is_enabled"`
+	MustChangePassword bool       `json:"must_change_password"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	LastLoginAt        *time.Time `json:"last_login_at"`
+	FailedLoginCount   int        `json:"failed_login_count"`
+	LockedUntil        *time.Time `json:"locked_until"`
+	CreatedBy          uint       `json:"created_by"`
+	TenantID           string     `json:"tenant_id"`
+	Global             bool       `json:"global"`
+}
+
+// UserListResponse 用户列表（GET /api/v1/users）
+type UserListResponse struct {
+	Users    []UserInfo `json:"users"`
+	Total    int64      `json:"total"`     // 符合条件的用户总数
+	Page     int        `json:"page"`      // 未分页时为0
+	PageSize int        `json:"page_size"` // 未分页时为0
+}
+
+// CreateUserRequest 创建用户请求
+type CreateUserRequest struct {
+	Username      string `json:"username" binding:"required"`
+	Role          Role   `json:"role"`            // 用户角色，为空时按is_admin确定
+	IsAdmin       bool   `json:"is_admin"`        // 兼容旧版本，is_admin为true等同于角色superuser
+	AutoCreateKey bool   `json:"auto_create_key"` // 同时为用户创建第一个API Key
+	TenantID      string `json:"tenant_id"`       // 所属租户，为空时与创建者相同
+	Global        bool   `json:"global"`          // 是否为全局管理员，只有全局管理员可以设置
+}
+
+// CreateUserResponse 创建用户响应，自动创建的API Key按API Key响应格式返回完整key值
+type CreateUserResponse struct {
+	User              *User           `json:"user"`
+	GeneratedPassword string          `json:"generated_password"` // 临时密码，只在创建时返回一次
+	APIKey            *APIKeyResponse `json:"api_key,omitempty"`
+}
+
+// Profile 当前用户信息（GET /api/v1/auth/profile），capabilities为用户角色拥有的权限
+type Profile struct {
+	*User
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// ReloadResult 重新加载配置的结果（POST /api/v1/config/reload）
+type ReloadResult struct {
+	TotalModels int `json:"total_models"`
+}
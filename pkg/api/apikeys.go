@@ -0,0 +1,37 @@
+package api
+
+// APIKeyResponse API Key响应结构
+type APIKeyResponse struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	KeyValue   string `json:"key_value,omitempty"` // 只在创建时返回完整key
+	KeyPreview string `json:"key_preview"`         // 显示用的预览（前几位+***）
+	IsEnabled  bool   `json:"is_enabled"`
+	LastUsedAt string `json:"last_used_at"`
+	LastUsedIP string `json:"last_used_ip"` // 最后使用时的客户端IP
+	ExpiresAt  string `json:"expires_at"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+
+	AllowedModels []string          `json:"allowed_models"` // 允许调用的模型ID，为空表示不限制
+	Labels        map[string]string `json:"labels"`         // 标签
+
+	AllowPromptOverride bool   `json:"allow_prompt_override"` // 是否允许通过请求头跳过或追加Prompt
+	UserID              uint   `json:"user_id,omitempty"`     // 所属用户ID，仅管理员查看全部Key时返回
+	Username            string `json:"username,omitempty"`    // 所属用户名，仅管理员查看全部Key时返回
+}
+
+// CreateAPIKeyRequest 创建API Key请求结构
+type CreateAPIKeyRequest struct {
+	Name          string            `json:"name" binding:"required"`
+	KeyValue      string            `json:"key_value"`      // 可选，如果不提供则自动生成
+	ExpiresAt     string            `json:"expires_at"`     // 可选的过期时间
+	AllowedModels []string          `json:"allowed_models"` // 可选，限制Key只能调用这些模型
+	Labels        map[string]string `json:"labels"`         // 可选，标签，如{"team":"search"}
+}
+
+// APIKeyList API Key列表（GET /api/v1/api-keys）
+type APIKeyList struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+	Total   int              `json:"total"`
+}
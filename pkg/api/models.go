@@ -0,0 +1,219 @@
+// Package api 定义管理API的请求和响应结构，管理API服务器和pkg/client共用这些结构，避免两者不一致。
+// 本包不依赖服务器的内部包，服务器在internal/admin中转换，使用客户端不会引入gin、gorm和数据库驱动
+package api
+
+import "time"
+
+// 模型配置中的枚举值，取值与模型配置文件相同
+type (
+	ModelType         string // chat、completion、embedding等
+	ValueType         string // Prompt值的类型
+	ModelIDSource     string // 读取模型ID的位置
+	ToolsMode         string // 注入工具定义的方式
+	ModelSource       string // 模型来源：yaml或api
+	StreamMode        string // 流式响应的处理方式
+	HealthCheckMethod string // 上游健康检查方式：connect、head或request
+	PromptPosition    string // Prompt注入的位置
+)
+
+// WeightedTarget 按权重分流的目标
+type WeightedTarget struct {
+	Name   string `json:"name"`   // 变体名称，为空时使用目标模型ID
+	Target string `json:"target"` // 目标模型ID，为空时使用模型的target
+	Url    string `json:"url"`    // 转发的URL，为空时使用模型的url
+	Weight int    `json:"weight"` // 权重，0表示不分配
+}
+
+// PromptVariant Prompt实验的变体
+type PromptVariant struct {
+	ID          string      `json:"id"`           // 变体ID
+	Weight      int         `json:"weight"`       // 权重，0表示不分配
+	PromptValue interface{} `json:"prompt_value"` // 替换模型Prompt值的值
+}
+
+// HealthCheckResult 一个上游的健康检查结果
+type HealthCheckResult struct {
+	Target     string `json:"target"`
+	URL        string `json:"url"`
+	DNSMs      int64  `json:"dns_ms"`      // DNS解析耗时
+	ConnectMs  int64  `json:"connect_ms"`  // TCP连接耗时，不含TLS握手
+	TLSOK      *bool  `json:"tls_ok"`      // TLS握手是否成功，http上游为null
+	HTTPStatus int    `json:"http_status"` // head和request方式的响应状态码，connect方式为0
+	Healthy    bool   `json:"healthy"`
+	Error      string `json:"error"`
+}
+
+// ModelStatus 模型最近一次上游健康检查的结果，任意一个上游可用即认为模型可用
+type ModelStatus struct {
+	ModelID   string              `json:"model_id"`
+	Method    HealthCheckMethod   `json:"method"`
+	Healthy   bool                `json:"healthy"`
+	CheckedAt time.Time           `json:"checked_at"`
+	Upstreams []HealthCheckResult `json:"upstreams"`
+}
+
+// SeedResult 导入模型目录的结果（POST /api/v1/models/seed）
+type SeedResult struct {
+	Created []string `json:"created"` // 新创建的模型ID
+	Skipped []string `json:"skipped"` // 已存在而跳过的模型ID
+}
+
+// ModelResponse 模型响应结构
+type ModelResponse struct {
+	ID                  string            `json:"id"`
+	Name                string            `json:"name"`
+	Target              string            `json:"target"`
+	Prompt              string            `json:"prompt"`
+	Url                 string            `json:"url"`
+	Type                ModelType         `json:"type"`
+	PromptPath          string            `json:"prompt_path"`
+	PromptValue         interface{}       `json:"prompt_value"`
+	PromptValueType     ValueType         `json:"prompt_value_type"`
+	ModelIDSource       ModelIDSource     `json:"model_id_source"`
+	ModelIDKey          string            `json:"model_id_key"`
+	Disabled            bool              `json:"disabled"`
+	CacheTTL            int               `json:"cache_ttl"`
+	MaxConcurrency      int               `json:"max_concurrency"`
+	QueueOnLimit        bool              `json:"queue_on_limit"`
+	QueueTimeout        int               `json:"queue_timeout"`
+	Maintenance         bool              `json:"maintenance"`
+	MaintenanceMessage  string            `json:"maintenance_message"`
+	MaintenanceStatus   int               `json:"maintenance_status"`
+	Aliases             []string          `json:"aliases"`
+	SigningEnabled      bool              `json:"signing_enabled"` // 是否配置了请求签名密钥，密钥本身不返回
+	Tools               []interface{}     `json:"tools"`
+	ToolsMode           ToolsMode         `json:"tools_mode"`
+	MaxTimeoutMs        int               `json:"max_timeout_ms"`
+	RequestHeaders      map[string]string `json:"request_headers"`
+	ResponseHeaders     map[string]string `json:"response_headers"`
+	QueueTimeoutMs      int               `json:"queue_timeout_ms"`
+	Source              ModelSource       `json:"source"`              // 模型来源：yaml或api
+	Pipeline            []string          `json:"pipeline"`            // 请求处理阶段顺序，为空表示使用默认顺序
+	LastUsedAt          string            `json:"last_used_at"`        // 最后调用时间，从未调用时为空
+	TotalRequestCount   int64             `json:"total_request_count"` // 代理累计处理的请求数
+	StreamMode          StreamMode        `json:"stream_mode"`
+	Targets             []WeightedTarget  `json:"targets"`
+	MinifyBody          bool              `json:"minify_body"`
+	CompressUpstream    bool              `json:"compress_upstream"`
+	PromptVariants      []PromptVariant   `json:"prompt_variants"`
+	HealthCheckInterval int               `json:"health_check_interval"`
+	HealthCheckMethod   HealthCheckMethod `json:"health_check_method"`
+	PromptPosition      PromptPosition    `json:"prompt_position"`
+	DedupePrompt        bool              `json:"dedupe_injected_prompt"`
+	CanaryTarget        string            `json:"canary_target"`
+	CanaryUrl           string            `json:"canary_url"`
+	CanaryPercent       int               `json:"canary_percent"`
+	HedgeAfterMs        int               `json:"hedge_after_ms"`
+	MaxHedges           int               `json:"max_hedges"`
+	Tenant              string            `json:"tenant"` // 所属租户
+	Health              *ModelStatus      `json:"health"` // 最近一次上游健康检查的结果，没有检查过时为null
+	CreatedAt           string            `json:"created_at"`
+	UpdatedAt           string            `json:"updated_at"`
+}
+
+// CreateModelRequest 创建模型请求结构
+type CreateModelRequest struct {
+	ID                  string            `json:"id"`
+	Name                string            `json:"name"`
+	Target              string            `json:"target"`
+	Prompt              string            `json:"prompt"`
+	Url                 string            `json:"url"`
+	Type                ModelType         `json:"type"`
+	PromptPath          string            `json:"prompt_path"`
+	PromptValue         interface{}       `json:"prompt_value"`
+	PromptValueType     ValueType         `json:"prompt_value_type"`
+	ModelIDSource       ModelIDSource     `json:"model_id_source"`
+	ModelIDKey          string            `json:"model_id_key"`
+	Disabled            bool              `json:"disabled"`
+	CacheTTL            int               `json:"cache_ttl"`
+	MaxConcurrency      int               `json:"max_concurrency"`
+	QueueOnLimit        bool              `json:"queue_on_limit"`
+	QueueTimeout        int               `json:"queue_timeout"`
+	Maintenance         bool              `json:"maintenance"`
+	MaintenanceMessage  string            `json:"maintenance_message"`
+	MaintenanceStatus   int               `json:"maintenance_status"`
+	Aliases             []string          `json:"aliases"`
+	SigningSecret       string            `json:"signing_secret"`
+	Tools               []interface{}     `json:"tools"`
+	ToolsMode           ToolsMode         `json:"tools_mode"`
+	MaxTimeoutMs        int               `json:"max_timeout_ms"`
+	RequestHeaders      map[string]string `json:"request_headers"`
+	ResponseHeaders     map[string]string `json:"response_headers"`
+	QueueTimeoutMs      int               `json:"queue_timeout_ms"`
+	Pipeline            []string          `json:"pipeline"`
+	StreamMode          StreamMode        `json:"stream_mode"`
+	Targets             []WeightedTarget  `json:"targets"`
+	MinifyBody          bool              `json:"minify_body"`
+	CompressUpstream    bool              `json:"compress_upstream"`
+	PromptVariants      []PromptVariant   `json:"prompt_variants"`
+	HealthCheckInterval int               `json:"health_check_interval"`
+	HealthCheckMethod   HealthCheckMethod `json:"health_check_method"`
+	PromptPosition      PromptPosition    `json:"prompt_position"`
+	DedupePrompt        bool              `json:"dedupe_injected_prompt"`
+	CanaryTarget        string            `json:"canary_target"`
+	CanaryUrl           string            `json:"canary_url"`
+	CanaryPercent       int               `json:"canary_percent"`
+	HedgeAfterMs        int               `json:"hedge_after_ms"`
+	MaxHedges           int               `json:"max_hedges"`
+	Tenant              string            `json:"tenant"` // 所属租户，为空时使用当前用户的租户（全局管理员为默认租户）
+}
+
+// UpdateModelRequest 更新模型请求结构
+type UpdateModelRequest struct {
+	Name                string             `json:"name"`
+	Target              string             `json:"target"`
+	Prompt              string             `json:"prompt"`
+	Url                 string             `json:"url"`
+	Type                ModelType          `json:"type"`
+	PromptPath          string             `json:"prompt_path"`
+	PromptValue         interface{}        `json:"prompt_value"`
+	PromptValueType     ValueType          `json:"prompt_value_type"`
+	ModelIDSource       ModelIDSource      `json:"model_id_source"`
+	ModelIDKey          string             `json:"model_id_key"`
+	Disabled            *bool              `json:"disabled"`
+	CacheTTL            *int               `json:"cache_ttl"`
+	MaxConcurrency      *int               `json:"max_concurrency"`
+	QueueOnLimit        *bool              `json:"queue_on_limit"`
+	QueueTimeout        *int               `json:"queue_timeout"`
+	Maintenance         *bool              `json:"maintenance"`
+	MaintenanceMessage  *string            `json:"maintenance_message"`
+	MaintenanceStatus   *int               `json:"maintenance_status"`
+	Aliases             []string           `json:"aliases"`
+	SigningSecret       *string            `json:"signing_secret"` // 为空字符串时关闭请求签名
+	Tools               []interface{}      `json:"tools"`
+	ToolsMode           *ToolsMode         `json:"tools_mode"`
+	MaxTimeoutMs        *int               `json:"max_timeout_ms"`
+	RequestHeaders      map[string]string  `json:"request_headers"`
+	ResponseHeaders     map[string]string  `json:"response_headers"`
+	QueueTimeoutMs      *int               `json:"queue_timeout_ms"`
+	Pipeline            []string           `json:"pipeline"` // 传入空数组时恢复默认顺序
+	StreamMode          *StreamMode        `json:"stream_mode"`
+	Targets             []WeightedTarget   `json:"targets"` // 传入空数组时取消按权重分流
+	MinifyBody          *bool              `json:"minify_body"`
+	CompressUpstream    *bool              `json:"compress_upstream"`
+	PromptVariants      []PromptVariant    `json:"prompt_variants"` // 传入空数组时结束Prompt实验
+	HealthCheckInterval *int               `json:"health_check_interval"`
+	HealthCheckMethod   *HealthCheckMethod `json:"health_check_method"`
+	PromptPosition      *PromptPosition    `json:"prompt_position"`
+	DedupePrompt        *bool              `json:"dedupe_injected_prompt"`
+	CanaryTarget        *string            `json:"canary_target"` // 为空字符串时取消灰度
+	CanaryUrl           *string            `json:"canary_url"`
+	CanaryPercent       *int               `json:"canary_percent"` // 调整灰度比例，立即对新请求生效
+	HedgeAfterMs        *int               `json:"hedge_after_ms"` // 为0时关闭对冲请求
+	MaxHedges           *int               `json:"max_hedges"`
+	Tenant              *string            `json:"tenant"` // 转到其他租户，只有全局管理员可以修改
+}
+
+// TrashedModel 回收站中的模型（GET /api/v1/models/trash）
+type TrashedModel struct {
+	ModelResponse
+	DeletedAt string `json:"deleted_at"` // 移入回收站的时间
+}
+
+// ModelList 模型列表（GET /api/v1/models）
+type ModelList struct {
+	Models   []ModelResponse `json:"models"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"page_size"`
+}
@@ -0,0 +1,43 @@
+package api
+
+// CreateWebhookRequest 创建Webhook请求
+type CreateWebhookRequest struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url" binding:"required"`
+	Secret    string   `json:"secret"`     // 请求签名密钥，为空时不签名
+	Events    []string `json:"events"`     // 订阅的事件类型，为空表示全部
+	IsEnabled *bool    `json:"is_enabled"` // 是否启用，默认启用
+}
+
+// UpdateWebhookRequest 更新Webhook请求，为nil的字段保持不变
+type UpdateWebhookRequest struct {
+	Name      *string   `json:"name"`
+	URL       *string   `json:"url"`
+	Secret    *string   `json:"secret"` // 设置为空字符串时不再签名
+	Events    *[]string `json:"events"`
+	IsEnabled *bool     `json:"is_enabled"`
+}
+
+// WebhookResponse Webhook响应结构，不返回签名密钥
+type WebhookResponse struct {
+	ID        uint     `json:"id"`
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`     // 订阅的事件类型，为空表示全部
+	IsEnabled bool     `json:"is_enabled"` // 是否启用
+	SecretSet bool     `json:"secret_set"` // 是否设置了签名密钥
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// WebhookList Webhook列表（GET /api/v1/webhooks）
+type WebhookList struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+	Total    int               `json:"total"`
+}
+
+// WebhookTestResult 发送测试告警的结果（POST /api/v1/webhooks/:id/test）
+type WebhookTestResult struct {
+	EventID    string `json:"event_id"`
+	DurationMs int64  `json:"duration_ms"`
+}
@@ -0,0 +1,178 @@
+// Package client 是管理API的Go客户端，请求和响应使用与服务器相同的pkg/api结构
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+// Error 管理API返回的错误
+type Error struct {
+	StatusCode int    // HTTP状态码
+	Code       string // 错误码，如model_not_found
+	Message    string // 错误信息
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("管理API返回错误 %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Client 管理API客户端。调用Login后保存用户名和密码，token过期或被撤销导致请求返回401时自动重新登录并重试一次
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+
+	mutex    sync.Mutex
+	token    string
+	username string
+	password string
+
+	Models  *ModelsService
+	APIKeys *APIKeysService
+	Users   *UsersService
+}
+
+// Option 客户端选项
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的http.Client发送请求，默认为http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken 使用已有的token，不保存密码，token失效后不会自动重新登录
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New 创建客户端，baseURL为管理服务地址，如http://localhost:8081
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/") + "/api/v1",
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Models = &ModelsService{client: c}
+	c.APIKeys = &APIKeysService{client: c}
+	c.Users = &UsersService{client: c}
+	return c
+}
+
+// Login 登录并保存token，之后的请求使用该token
+func (c *Client) Login(ctx context.Context, username, password string) (*api.LoginResponse, error) {
+	var response api.LoginResponse
+	err := c.send(ctx, http.MethodPost, "/auth/login", nil, &api.LoginRequest{Username: username, Password: password}, &response, "")
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.token = response.Token
+	c.username, c.password = username, password
+	c.mutex.Unlock()
+	return &response, nil
+}
+
+// Token 返回当前使用的token
+func (c *Client) Token() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.token
+}
+
+// ConfigReload 重新加载配置目录中的YAML文件
+func (c *Client) ConfigReload(ctx context.Context) (*api.ReloadResult, error) {
+	var result api.ReloadResult
+	if err := c.do(ctx, http.MethodPost, "/config/reload", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do 使用当前token发送请求，返回401且保存了密码时重新登录并重试一次
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	token := c.Token()
+	err := c.send(ctx, method, path, query, body, out, token)
+	apiErr, ok := err.(*Error)
+	if !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		return err
+	}
+
+	c.mutex.Lock()
+	username, password := c.username, c.password
+	c.mutex.Unlock()
+	if username == "" {
+		return err
+	}
+	if _, loginErr := c.Login(ctx, username, password); loginErr != nil {
+		return fmt.Errorf("重新登录失败: %w", loginErr)
+	}
+	return c.send(ctx, method, path, query, body, out, c.Token())
+}
+
+// send 发送一次请求，成功时将响应的data解析到out
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body, out interface{}, token string) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	var envelope api.Response[json.RawMessage]
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if resp.StatusCode >= http.StatusBadRequest || envelope.Code != 0 {
+		return &Error{StatusCode: resp.StatusCode, Code: envelope.ErrorCode, Message: envelope.Message}
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eolinker/ai-prompt-proxy/internal/admin"
+	"github.com/eolinker/ai-prompt-proxy/internal/config"
+	"github.com/eolinker/ai-prompt-proxy/internal/service"
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+func TestClientAgainstAdminServer(t *testing.T) {
+	serverConfig := config.DefaultServerConfig()
+	serverConfig.ConfigDir = t.TempDir()
+	configService, err := service.NewConfigService(serverConfig.ConfigDir)
+	if err != nil {
+		t.Fatalf("创建配置服务失败: %v", err)
+	}
+	defer configService.Close()
+	adminServer, err := admin.NewAdminServerWithService(configService, serverConfig)
+	if err != nil {
+		t.Fatalf("创建管理API服务器失败: %v", err)
+	}
+	authService, err := service.NewAuthService(configService.GetDBManager())
+	if err != nil {
+		t.Fatalf("创建认证服务失败: %v", err)
+	}
+	if _, err := authService.Register(&service.RegisterRequest{Username: "admin", Password: "password"}); err != nil {
+		t.Fatalf("注册管理员失败: %v", err)
+	}
+	server := httptest.NewServer(adminServer.Router())
+	defer server.Close()
+
+	ctx := context.Background()
+	c := New(server.URL)
+	if _, err := c.Login(ctx, "admin", "wrong"); !isAPIError(err, http.StatusUnauthorized) {
+		t.Errorf("密码错误时期望401，实际%v", err)
+	}
+	if _, err := c.Login(ctx, "admin", "password"); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+
+	// 模型
+	created, err := c.Models.Create(ctx, &api.CreateModelRequest{
+		ID: "assistant", Name: "助手", Target: "gpt-4o-mini", Type: "chat",
+		Url: "https://api.openai.com/v1/chat/completions", Aliases: []string{"helper"},
+	})
+	if err != nil {
+		t.Fatalf("创建模型失败: %v", err)
+	}
+	if created.Source != "api" || created.CreatedAt == "" {
+		t.Errorf("创建的模型 = %+v", created)
+	}
+	name := "新助手"
+	if _, err := c.Models.Update(ctx, "assistant", &api.UpdateModelRequest{Name: name}); err != nil {
+		t.Fatalf("更新模型失败: %v", err)
+	}
+	model, err := c.Models.Get(ctx, "assistant")
+	if err != nil {
+		t.Fatalf("获取模型失败: %v", err)
+	}
+	if model.ID != "assistant" || model.Name != name {
+		t.Errorf("获取的模型 = %s %s", model.ID, model.Name)
+	}
+	list, err := c.Models.List(ctx, &ListModelsOptions{Search: "assist", Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("获取模型列表失败: %v", err)
+	}
+	if list.Total != 1 || len(list.Models) != 1 || list.PageSize != 10 {
+		t.Errorf("模型列表 = %+v", list)
+	}
+	if err := c.Models.Delete(ctx, "assistant"); err != nil {
+		t.Fatalf("删除模型失败: %v", err)
+	}
+	var apiErr *Error
+	if _, err := c.Models.Get(ctx, "assistant"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "model_not_found" {
+		t.Errorf("获取已删除的模型期望404 model_not_found，实际%v", err)
+	}
+	if trashed, err := c.Models.Trash(ctx); err != nil || len(trashed) != 1 || trashed[0].ID != "assistant" || trashed[0].DeletedAt == "" {
+		t.Errorf("回收站 = %+v, err=%v", trashed, err)
+	}
+	if restored, err := c.Models.Restore(ctx, "assistant"); err != nil || restored.Name != name {
+		t.Errorf("恢复模型 = %+v, err=%v", restored, err)
+	}
+	c.Models.Delete(ctx, "assistant")
+	if err := c.Models.Purge(ctx, "assistant"); err != nil {
+		t.Errorf("永久删除模型失败: %v", err)
+	}
+
+	// API Key
+	apiKey, err := c.APIKeys.Create(ctx, &api.CreateAPIKeyRequest{Name: "ci", Labels: map[string]string{"team": "ci"}})
+	if err != nil {
+		t.Fatalf("创建API Key失败: %v", err)
+	}
+	if apiKey.KeyValue == "" {
+		t.Error("创建API Key时应返回完整的Key值")
+	}
+	keys, err := c.APIKeys.List(ctx)
+	if err != nil || keys.Total != 1 || keys.APIKeys[0].Labels["team"] != "ci" {
+		t.Errorf("API Key列表 = %+v %v", keys, err)
+	}
+	if err := c.APIKeys.Delete(ctx, apiKey.ID); err != nil {
+		t.Errorf("删除API Key失败: %v", err)
+	}
+
+	// 用户
+	user, err := c.Users.Create(ctx, &api.CreateUserRequest{Username: "alice", AutoCreateKey: true})
+	if err != nil {
+		t.Fatalf("创建用户失败: %v", err)
+	}
+	if user.GeneratedPassword == "" {
+		t.Error("创建用户时应返回临时密码")
+	}
+	users, err := c.Users.List(ctx, &ListUsersOptions{IncludeAdmins: true})
+	if err != nil || users.Total != 2 {
+		t.Errorf("用户列表 = %+v %v", users, err)
+	}
+	if err := c.Users.Delete(ctx, user.User.ID, false); !isAPIError(err, http.StatusConflict) {
+		t.Errorf("用户仍有API Key时期望409，实际%v", err)
+	}
+	if err := c.Users.Delete(ctx, user.User.ID, true); err != nil {
+		t.Errorf("删除用户失败: %v", err)
+	}
+
+	if _, err := c.ConfigReload(ctx); err != nil {
+		t.Errorf("重新加载配置失败: %v", err)
+	}
+
+	// 会话被撤销后自动重新登录
+	oldToken := c.Token()
+	claims, err := authService.ValidateToken(oldToken)
+	if err != nil {
+		t.Fatalf("验证token失败: %v", err)
+	}
+	if err := authService.RevokeSession(service.GlobalScope, claims.ID, 0); err != nil {
+		t.Fatalf("撤销会话失败: %v", err)
+	}
+	if _, err := c.Models.List(ctx, nil); err != nil {
+		t.Errorf("token失效后应重新登录并重试，实际%v", err)
+	}
+	if c.Token() == "" || c.Token() == oldToken {
+		t.Error("重新登录后应使用新的token")
+	}
+
+	// 只有token的客户端不会重新登录
+	if _, err := New(server.URL, WithToken("invalid")).Models.List(ctx, nil); !isAPIError(err, http.StatusUnauthorized) {
+		t.Errorf("无效token期望401，实际%v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.Models.List(canceled, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("取消的context期望context.Canceled，实际%v", err)
+	}
+}
+
+// isAPIError 判断err是否为指定状态码的管理API错误
+func isAPIError(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
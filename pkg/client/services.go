@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/eolinker/ai-prompt-proxy/pkg/api"
+)
+
+// ModelsService 模型配置管理（/api/v1/models）
+type ModelsService struct {
+	client *Client
+}
+
+// ListModelsOptions 模型列表的查询条件，零值表示不限制
+type ListModelsOptions struct {
+	Type     string // 模型类型
+	Search   string // 按ID或名称搜索
+	Sort     string // 排序字段created_at、updated_at或name，可加:asc或:desc，如name:desc
+	Page     int
+	PageSize int
+}
+
+// List 获取模型列表，opts为nil时按默认排序返回第1页
+func (s *ModelsService) List(ctx context.Context, opts *ListModelsOptions) (*api.ModelList, error) {
+	query := url.Values{}
+	if opts != nil {
+		setQuery(query, "type", opts.Type)
+		setQuery(query, "search", opts.Search)
+		setQuery(query, "sort", opts.Sort)
+		setPagination(query, opts.Page, opts.PageSize)
+	}
+	var list api.ModelList
+	if err := s.client.do(ctx, http.MethodGet, "/models", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Get 根据模型ID获取模型配置
+func (s *ModelsService) Get(ctx context.Context, id string) (*api.ModelResponse, error) {
+	var model api.ModelResponse
+	if err := s.client.do(ctx, http.MethodGet, "/models/"+url.PathEscape(id), nil, nil, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Create 创建模型配置
+func (s *ModelsService) Create(ctx context.Context, req *api.CreateModelRequest) (*api.ModelResponse, error) {
+	var model api.ModelResponse
+	if err := s.client.do(ctx, http.MethodPost, "/models", nil, req, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Update 更新模型配置，req中为零值或nil的字段保持不变
+func (s *ModelsService) Update(ctx context.Context, id string, req *api.UpdateModelRequest) (*api.ModelResponse, error) {
+	var model api.ModelResponse
+	if err := s.client.do(ctx, http.MethodPut, "/models/"+url.PathEscape(id), nil, req, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Delete 删除模型配置，模型移入回收站
+func (s *ModelsService) Delete(ctx context.Context, id string) error {
+	return s.client.do(ctx, http.MethodDelete, "/models/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Trash 获取回收站中的模型，按删除时间从新到旧排序
+func (s *ModelsService) Trash(ctx context.Context) ([]api.TrashedModel, error) {
+	var models []api.TrashedModel
+	if err := s.client.do(ctx, http.MethodGet, "/models/trash", nil, nil, &models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// Restore 从回收站恢复模型配置
+func (s *ModelsService) Restore(ctx context.Context, id string) (*api.ModelResponse, error) {
+	var model api.ModelResponse
+	if err := s.client.do(ctx, http.MethodPost, "/models/"+url.PathEscape(id)+"/restore", nil, nil, &model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// Purge 永久删除回收站中的模型配置
+func (s *ModelsService) Purge(ctx context.Context, id string) error {
+	return s.client.do(ctx, http.MethodDelete, "/models/"+url.PathEscape(id)+"/purge", nil, nil, nil)
+}
+
+// APIKeysService 当前用户的API Key管理（/api/v1/api-keys）
+type APIKeysService struct {
+	client *Client
+}
+
+// List 获取当前用户的API Key列表
+func (s *APIKeysService) List(ctx context.Context) (*api.APIKeyList, error) {
+	var list api.APIKeyList
+	if err := s.client.do(ctx, http.MethodGet, "/api-keys", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Create 为当前用户创建API Key，返回结果中包含完整的Key值
+func (s *APIKeysService) Create(ctx context.Context, req *api.CreateAPIKeyRequest) (*api.APIKeyResponse, error) {
+	var apiKey api.APIKeyResponse
+	if err := s.client.do(ctx, http.MethodPost, "/api-keys", nil, req, &apiKey); err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// Delete 删除当前用户的API Key
+func (s *APIKeysService) Delete(ctx context.Context, id uint) error {
+	return s.client.do(ctx, http.MethodDelete, "/api-keys/"+strconv.FormatUint(uint64(id), 10), nil, nil, nil)
+}
+
+// UsersService 用户管理（/api/v1/users，需要管理员权限）
+type UsersService struct {
+	client *Client
+}
+
+// ListUsersOptions 用户列表的查询条件，零值表示不限制
+type ListUsersOptions struct {
+	Query         string // 按用户名搜索
+	IncludeAdmins bool   // 是否包含管理员
+	Page          int
+	PageSize      int
+}
+
+// List 获取用户列表
+func (s *UsersService) List(ctx context.Context, opts *ListUsersOptions) (*api.UserListResponse, error) {
+	query := url.Values{}
+	if opts != nil {
+		setQuery(query, "q", opts.Query)
+		if opts.IncludeAdmins {
+			query.Set("include_admins", "true")
+		}
+		setPagination(query, opts.Page, opts.PageSize)
+	}
+	var list api.UserListResponse
+	if err := s.client.do(ctx, http.MethodGet, "/users", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Create 创建用户，返回结果中包含随机生成的临时密码
+func (s *UsersService) Create(ctx context.Context, req *api.CreateUserRequest) (*api.CreateUserResponse, error) {
+	var created api.CreateUserResponse
+	if err := s.client.do(ctx, http.MethodPost, "/users", nil, req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Delete 删除用户，cascade为true时同时删除用户的API Key
+func (s *UsersService) Delete(ctx context.Context, id uint, cascade bool) error {
+	query := url.Values{}
+	if cascade {
+		query.Set("cascade", "true")
+	}
+	return s.client.do(ctx, http.MethodDelete, "/users/"+strconv.FormatUint(uint64(id), 10), query, nil, nil)
+}
+
+// setQuery 值不为空时设置查询参数
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// setPagination 设置分页参数，为0时使用服务端默认值（第1页、每页20个）
+func setPagination(query url.Values, page, pageSize int) {
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("page_size", strconv.Itoa(pageSize))
+	}
+}